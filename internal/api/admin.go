@@ -0,0 +1,151 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"museum/internal/override"
+	"museum/internal/postgres"
+)
+
+// maxAdminBody bounds an override posted to the admin API. One override is a
+// few hundred bytes.
+const maxAdminBody = 16 << 10
+
+// Overrider is the part of the store the admin API writes through. Like
+// Harvester it is separate from Catalogue, because everything else the API
+// does only reads.
+type Overrider interface {
+	Overrides(ctx context.Context, museumID int64) ([]override.Override, error)
+	AddOverride(ctx context.Context, o override.Override) (override.Override, error)
+	RemoveOverride(ctx context.Context, id int64) (override.Override, error)
+}
+
+// WithAdmin returns a Server that lets callers holding token manage overrides,
+// the same operations as "museum override". Without a token the admin routes
+// do not exist: an empty token would otherwise be a password everyone knows.
+func (s *Server) WithAdmin(store Overrider, token string) *Server {
+	if token != "" {
+		s.overrides, s.adminToken = store, token
+	}
+	return s
+}
+
+// admin guards an admin handler. An API with no admin token answers as though
+// the route did not exist, so it does not advertise one it will not serve.
+func (s *Server) admin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.overrides == nil {
+			writeError(w, http.StatusNotFound, fmt.Errorf("no such endpoint: %s", r.URL.Path))
+			return
+		}
+		// Constant time, so the comparison does not tell a caller how much of a
+		// guess was right.
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(s.adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="museum admin"`)
+			writeError(w, http.StatusUnauthorized, errors.New("a valid admin token is required"))
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		next(w, r)
+	}
+}
+
+// overridesResponse is a list of overrides.
+type overridesResponse struct {
+	Count     int                 `json:"count"`
+	Overrides []override.Override `json:"overrides"`
+}
+
+func (s *Server) handleListOverrides(w http.ResponseWriter, r *http.Request) {
+	var museumID int64
+	if raw := r.URL.Query().Get("museum_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || id <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("museum_id %q is not a museum id", raw))
+			return
+		}
+		museumID = id
+	}
+
+	overrides, err := s.overrides.Overrides(r.Context(), museumID)
+	if err != nil {
+		writeServerError(w, r, err)
+		return
+	}
+	if overrides == nil {
+		overrides = []override.Override{}
+	}
+	writeJSON(w, http.StatusOK, overridesResponse{Count: len(overrides), Overrides: overrides})
+}
+
+// overrideRequest is what a caller posts: an override without the fields the
+// store fills in.
+type overrideRequest struct {
+	Kind     override.Kind   `json:"kind"`
+	MuseumID int64           `json:"museum_id"`
+	OtherID  int64           `json:"other_id"`
+	Field    string          `json:"field"`
+	Value    json.RawMessage `json:"value"`
+	Reason   string          `json:"reason"`
+}
+
+func (s *Server) handleAddOverride(w http.ResponseWriter, r *http.Request) {
+	var request overrideRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAdminBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("body must be a JSON override: %w", err))
+		return
+	}
+
+	added, err := s.overrides.AddOverride(r.Context(), override.Override{
+		Kind:      request.Kind,
+		MuseumID:  request.MuseumID,
+		OtherID:   request.OtherID,
+		Field:     request.Field,
+		Value:     request.Value,
+		Reason:    request.Reason,
+		CreatedBy: "api",
+	})
+	if err != nil {
+		writeOverrideError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, added)
+}
+
+func (s *Server) handleRemoveOverride(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("override id %q is not a number", r.PathValue("id")))
+		return
+	}
+	removed, err := s.overrides.RemoveOverride(r.Context(), id)
+	if err != nil {
+		writeOverrideError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, removed)
+}
+
+// writeOverrideError answers a refused override with the status that says why.
+func writeOverrideError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, override.ErrInvalid):
+		writeError(w, http.StatusBadRequest, err)
+	case errors.Is(err, override.ErrConflict):
+		writeError(w, http.StatusConflict, err)
+	case errors.Is(err, postgres.ErrNotFound):
+		writeError(w, http.StatusNotFound, err)
+	default:
+		writeServerError(w, r, err)
+	}
+}
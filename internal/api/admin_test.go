@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"museum/internal/override"
+	"museum/internal/postgres"
+)
+
+// fakeOverrider holds overrides in memory, refusing what the store would.
+type fakeOverrider struct {
+	added []override.Override
+}
+
+func (f *fakeOverrider) Overrides(_ context.Context, museumID int64) ([]override.Override, error) {
+	return f.added, nil
+}
+
+func (f *fakeOverrider) AddOverride(_ context.Context, o override.Override) (override.Override, error) {
+	if err := o.Validate(); err != nil {
+		return override.Override{}, err
+	}
+	if o.MuseumID == 404 {
+		return override.Override{}, fmt.Errorf("museum 404: %w", postgres.ErrNotFound)
+	}
+	o.ID = int64(len(f.added) + 1)
+	f.added = append(f.added, o)
+	return o, nil
+}
+
+func (f *fakeOverrider) RemoveOverride(_ context.Context, id int64) (override.Override, error) {
+	return override.Override{}, fmt.Errorf("override %d: %w", id, postgres.ErrNotFound)
+}
+
+func adminRequest(s *Server, method, target, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.Routes().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdmin_RequiresTheToken(t *testing.T) {
+	store := &fakeOverrider{}
+
+	disabled := NewServer(&fakeCatalogue{}).WithAdmin(store, "")
+	if rec := adminRequest(disabled, http.MethodGet, "/v1/admin/overrides", "", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("no token configured: status = %d, want 404", rec.Code)
+	}
+
+	s := NewServer(&fakeCatalogue{}).WithAdmin(store, "s3cret")
+	for _, token := range []string{"", "s3cre", "s3cret2"} {
+		rec := adminRequest(s, http.MethodPost, "/v1/admin/overrides", token, `{"kind":"suppress","museum_id":7}`)
+		if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("token %q: status = %d, want 401 with a challenge", token, rec.Code)
+		}
+	}
+	if len(store.added) != 0 {
+		t.Fatalf("an unauthenticated request added %d overrides", len(store.added))
+	}
+}
+
+func TestAdmin_ManagesOverrides(t *testing.T) {
+	store := &fakeOverrider{}
+	s := NewServer(&fakeCatalogue{}).WithAdmin(store, "s3cret")
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"pin", `{"kind":"pin","museum_id":7,"field":"website","value":"https://example.org","reason":"aggregator"}`, http.StatusCreated},
+		{"merge", `{"kind":"merge","museum_id":7,"other_id":8}`, http.StatusCreated},
+		{"an unpinnable field", `{"kind":"pin","museum_id":7,"field":"identity","value":"x"}`, http.StatusBadRequest},
+		{"an unknown key", `{"kind":"suppress","museum_id":7,"museum":"Louvre"}`, http.StatusBadRequest},
+		{"an unknown museum", `{"kind":"suppress","museum_id":404}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := adminRequest(s, http.MethodPost, "/v1/admin/overrides", "s3cret", tt.body)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d; body %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+
+	if got := store.added; len(got) != 2 || got[0].CreatedBy != "api" || got[0].Reason != "aggregator" {
+		t.Errorf("stored = %+v, want the pin and the merge, attributed to the API", got)
+	}
+
+	rec := adminRequest(s, http.MethodGet, "/v1/admin/overrides", "s3cret", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"count":2`) {
+		t.Errorf("list: status = %d, body %s", rec.Code, rec.Body)
+	}
+	if rec.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", rec.Header().Get("Cache-Control"))
+	}
+
+	if rec := adminRequest(s, http.MethodDelete, "/v1/admin/overrides/99", "s3cret", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("removing an unknown override: status = %d, want 404", rec.Code)
+	}
+}
@@ -1,9 +1,11 @@
 // Package api serves the catalogue over HTTP.
 //
 // Every query is answered by the database. The handlers hold no index of their
-// own and no cache: Postgres maintains its indexes transactionally and has its
-// own buffer pool, which removes both the in-process cache and the class of bug
-// where a derived index silently disagreed with the records it came from.
+// own: Postgres maintains its indexes transactionally, which removes the class
+// of bug where a derived index silently disagreed with the records it came
+// from. What they do keep is answers — validated by the catalogue generation,
+// which every write advances, so a cached response can be stale only for as
+// long as it takes to notice the counter moved.
 package api
 
 import (
@@ -20,6 +22,7 @@ import (
 	"time"
 	"unicode/utf8"
 
+	"museum/internal/models"
 	"museum/internal/postgres"
 )
 
@@ -57,14 +60,46 @@ type Catalogue interface {
 	NearbyVerified(ctx context.Context, lat, lon, radiusKm float64, limit, offset int, verifiedOnly bool) (postgres.Page, error)
 	Search(ctx context.Context, query string, limit, offset int) (postgres.Page, error)
 	MuseumByID(ctx context.Context, id string) (postgres.Hit, error)
+	MuseumHistory(ctx context.Context, id string, limit, offset int) (postgres.History, error)
 	Points(ctx context.Context, west, south, east, north float64, hasBox bool, limit int) ([]postgres.Point, error)
+	PointClusters(ctx context.Context, west, south, east, north float64, hasBox bool, cellDegrees float64, rawBelow, limit int) (postgres.Clustering, error)
 	ExhibitionsNearby(ctx context.Context, lat, lon, radiusKm float64, includeUpcoming bool, limit int) ([]postgres.ExhibitionHit, error)
 	SearchExhibitions(ctx context.Context, query string, lat, lon, radiusKm float64, near, includeUpcoming bool, limit, offset int) ([]postgres.ExhibitionHit, int64, error)
 	ExhibitionCoverage(ctx context.Context, lat, lon, radiusKm float64) (postgres.Coverage, error)
 	Counts(ctx context.Context) (postgres.Counts, error)
+	Generation(ctx context.Context) (postgres.Generation, error)
+	EachMuseum(ctx context.Context, fn func(id int64, museum models.Museum)) error
+	MuseumIDs(ctx context.Context, after int64, limit int) ([]int64, error)
 	Ping(ctx context.Context) error
 }
 
+// pooledCatalogue is a catalogue with more than one database behind it, which
+// the readiness probe reports one by one.
+type pooledCatalogue interface {
+	Pools(ctx context.Context) []postgres.PoolStatus
+}
+
+// pinnedCatalogue is a catalogue that chooses where a request's reads go, so
+// they are made once per request rather than once per query. A request's
+// generation and rows then come from the same database.
+type pinnedCatalogue interface {
+	PinReader(ctx context.Context) context.Context
+}
+
+// archiveCatalogue is a catalogue that keeps exhibitions after they end,
+// which is what answering about the past needs. The others answer those
+// requests 501.
+type archiveCatalogue interface {
+	ExhibitionsOn(ctx context.Context, lat, lon, radiusKm float64, day time.Time, limit int) ([]postgres.ExhibitionHit, error)
+	MuseumExhibitions(ctx context.Context, id string, limit, offset int) ([]postgres.ExhibitionHit, int64, error)
+}
+
+// deadLetterCatalogue is a catalogue that also holds what enrichment gave up
+// on, which the health check reports.
+type deadLetterCatalogue interface {
+	DeadLetterCounts(ctx context.Context) (map[string]int64, error)
+}
+
 // placeLookup resolves a place name to coordinates.
 type placeLookup interface {
 	Resolve(ctx context.Context, name string) (postgres.Place, error)
@@ -75,13 +110,19 @@ type Server struct {
 	catalogue Catalogue
 	places    placeLookup
 	scrapes   *scrapeQueue
+	responses *responseCache
+	now       func() time.Time
+
+	// overrides is nil unless the admin API is enabled; see WithAdmin.
+	overrides  Overrider
+	adminToken string
 }
 
 // NewServer returns a Server backed by the catalogue. Without a resolver the
 // API still works; it just cannot answer "what is on in Paris" without being
 // told where Paris is.
 func NewServer(catalogue Catalogue) *Server {
-	return &Server{catalogue: catalogue}
+	return &Server{catalogue: catalogue, responses: newResponseCache(maxCachedResponses), now: time.Now}
 }
 
 // WithScraping returns a Server that can read museum websites on demand, so a
@@ -119,9 +160,11 @@ func (s *Server) Routes() http.Handler {
 		writeJSON(w, http.StatusOK, map[string]string{"status": "alive"})
 	})
 	mux.HandleFunc("GET /readyz", s.handleReady)
-	mux.HandleFunc("GET /v1/museums", s.handleMuseums)
-	mux.HandleFunc("GET /v1/museums/{id}", s.handleMuseum)
-	mux.HandleFunc("GET /v1/points", s.handlePoints)
+	mux.HandleFunc("GET /v1/museums", s.cacheable(s.handleMuseums))
+	mux.HandleFunc("GET /v1/museums/{id}", s.cacheable(s.handleMuseum))
+	mux.HandleFunc("GET /v1/museums/{id}/history", s.cacheable(s.handleMuseumHistory))
+	mux.HandleFunc("GET /v1/museums/{id}/exhibitions", s.cacheableToday(s.handleMuseumExhibitions))
+	mux.HandleFunc("GET /v1/points", s.cacheableToday(s.handlePoints))
 	mux.HandleFunc("GET /v1/places", s.handlePlaces)
 	mux.HandleFunc("GET /v1/scrape", s.handleScrape)
 	mux.HandleFunc("POST /v1/scrape", s.handleScrape)
@@ -129,8 +172,26 @@ func (s *Server) Routes() http.Handler {
 	mux.HandleFunc("GET /map/vendor/{file}", s.handleVendor)
 	mux.HandleFunc("GET /map/assets/{file}", s.handleAsset)
 	mux.HandleFunc("GET /{$}", s.handleMap)
-	mux.HandleFunc("GET /v1/exhibitions", s.handleExhibitions)
-	mux.HandleFunc("GET /v1/search", s.handleSearch)
+	mux.HandleFunc("GET /v1/exhibitions", s.cacheableToday(s.handleExhibitions))
+	mux.HandleFunc("GET /v1/search", s.cacheable(s.handleSearch))
+
+	// The catalogue as pages, for search engines: the map builds everything in
+	// the browser, so without these a crawler sees one empty document.
+	mux.HandleFunc("GET /museums/{id}", s.cacheableToday(s.handleMuseumPage))
+	mux.HandleFunc("GET /sitemap.xml", s.cacheable(s.handleSitemapIndex))
+	mux.HandleFunc("GET /sitemap/{file}", s.cacheable(s.handleSitemap))
+	mux.HandleFunc("GET /robots.txt", s.handleRobots)
+
+	// One round trip for a client that wants a museum and everything around
+	// it. Not cacheable: a POST body is not part of the URL a cache keys on.
+	mux.HandleFunc("POST /v1/graphql", s.handleGraphQL)
+	mux.HandleFunc("GET /v1/graphql", s.handleGraphQLSchema)
+
+	// Corrections by hand, for whoever holds the admin token. The only routes
+	// that write to the catalogue, and the only ones behind authentication.
+	mux.HandleFunc("GET /v1/admin/overrides", s.admin(s.handleListOverrides))
+	mux.HandleFunc("POST /v1/admin/overrides", s.admin(s.handleAddOverride))
+	mux.HandleFunc("DELETE /v1/admin/overrides/{id}", s.admin(s.handleRemoveOverride))
 
 	// The mux answers an unknown path with plain text and a wrong method with
 	// an empty body, so a client that decodes JSON on every non-2xx response
@@ -138,7 +199,19 @@ func (s *Server) Routes() http.Handler {
 	// through one handler keeps the error shape uniform.
 	mux.HandleFunc("/", s.handleNotFound)
 
-	return withMiddleware(mux)
+	return withMiddleware(s.pinReader(mux))
+}
+
+// pinReader settles which database each request reads from before any
+// handler reads.
+func (s *Server) pinReader(next http.Handler) http.Handler {
+	pinned, ok := s.catalogue.(pinnedCatalogue)
+	if !ok {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(pinned.PinReader(r.Context())))
+	})
 }
 
 // handleNotFound answers anything the routes did not claim.
@@ -316,6 +389,8 @@ type responseQuery struct {
 	// Text is the search term, echoed so a caller can tell a search apart from
 	// a plain radius query in the reply alone.
 	Text string `json:"q,omitempty"`
+	// On is the day a point-in-time query asked about.
+	On string `json:"on,omitempty"`
 }
 
 func echo(q query) responseQuery {
@@ -350,6 +425,9 @@ type museumHit struct {
 	// than the museum itself, because no geocoder could find it by name. The
 	// museum is really in that town; it is not really at that point.
 	ApproximateLocation bool `json:"approximate_location,omitempty"`
+	// RetiredAt is set on a museum none of its sources offers any more. Only
+	// /v1/museums/{id} returns one, so a link to it keeps working.
+	RetiredAt *time.Time `json:"retired_at,omitempty"`
 	// Verified means the museum is backed by a Wikipedia article — every source
 	// sets it on that basis and no other. It is a proxy for confidence, not a
 	// judgement that the record is a museum: plenty of real small museums have
@@ -449,6 +527,36 @@ type exhibitionHit struct {
 	Latitude  float64   `json:"latitude"`
 	Longitude float64   `json:"longitude"`
 	ScrapedAt time.Time `json:"scraped_at"`
+	// Archived marks an exhibition read from the archive, which holds what
+	// ended long enough ago to be history.
+	Archived bool `json:"archived,omitempty"`
+}
+
+type museumExhibitionsResponse struct {
+	Count       int             `json:"count"`
+	Total       int64           `json:"total"`
+	HasMore     bool            `json:"has_more"`
+	Exhibitions []exhibitionHit `json:"exhibitions"`
+}
+
+type historyResponse struct {
+	MuseumID  int64         `json:"museum_id"`
+	Count     int           `json:"count"`
+	Total     int64         `json:"total"`
+	HasMore   bool          `json:"has_more"`
+	Revisions []revisionHit `json:"revisions"`
+}
+
+// revisionHit is one field changed by one write. Old and New are JSON as
+// stored — a string, an array of aliases, a [lat, lon] pair — and absent where
+// there was no value.
+type revisionHit struct {
+	Field     string          `json:"field"`
+	Old       json.RawMessage `json:"old,omitempty"`
+	New       json.RawMessage `json:"new,omitempty"`
+	Source    string          `json:"source"`
+	Operation string          `json:"operation"`
+	ChangedAt time.Time       `json:"changed_at"`
 }
 
 // handleHealth reports both liveness and what the catalogue holds.
@@ -470,14 +578,24 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]any{
+	body := map[string]any{
 		"status":           "ok",
 		"museums":          counts.Museums,
 		"with_coordinates": counts.WithCoordinates,
 		"countries":        counts.Countries,
 		"exhibitions":      counts.Exhibitions,
 		"last_updated":     counts.LastUpdated,
-	})
+	}
+	// Museums enrichment gave up on are reported by stage. Failing to count
+	// them is not ill health: the catalogue answers all the same.
+	if letters, ok := s.catalogue.(deadLetterCatalogue); ok {
+		if byStage, err := letters.DeadLetterCounts(r.Context()); err != nil {
+			log.Printf("api: health dead letters failed: %v", err)
+		} else {
+			body["dead_letters"] = byStage
+		}
+	}
+	writeJSON(w, http.StatusOK, body)
 }
 
 // handleReady reports whether the catalogue can be queried.
@@ -490,12 +608,57 @@ func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
 	defer cancel()
 
-	if err := s.catalogue.Ping(ctx); err != nil {
-		log.Printf("api: not ready: %v", err)
-		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "unavailable"})
+	pooled, ok := s.catalogue.(pooledCatalogue)
+	if !ok {
+		if err := s.catalogue.Ping(ctx); err != nil {
+			log.Printf("api: not ready: %v", err)
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "unavailable"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
 		return
 	}
-	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+
+	// Ready is whether the pool the reads go to answers. A replica that is
+	// down or behind is reported, but is not unreadiness: the reads have
+	// already gone back to the primary.
+	status, ready := http.StatusServiceUnavailable, "unavailable"
+	pools := make([]poolStatus, 0, 2)
+	for _, p := range pooled.Pools(ctx) {
+		pool := poolStatus{Name: p.Name, Status: "ready", Reads: p.Reads}
+		switch {
+		case p.Err != nil:
+			log.Printf("api: %s not ready: %v", p.Name, p.Err)
+			pool.Status = "unavailable"
+		case p.Behind:
+			pool.Status = "behind"
+		}
+		if p.Name != "primary" && p.Err == nil {
+			lag := p.Lag.Seconds()
+			pool.LagSeconds = &lag
+		}
+		if p.Reads && p.Err == nil {
+			status, ready = http.StatusOK, "ready"
+		}
+		pools = append(pools, pool)
+	}
+	writeJSON(w, status, readiness{Status: ready, Pools: pools})
+}
+
+// readiness is the readiness probe's answer for a catalogue with pools.
+type readiness struct {
+	Status string       `json:"status"`
+	Pools  []poolStatus `json:"pools"`
+}
+
+// poolStatus is one database pool in the readiness probe.
+type poolStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// LagSeconds is how far a replica is behind the primary.
+	LagSeconds *float64 `json:"lag_seconds,omitempty"`
+	// Reads says the catalogue's reads are going to this pool.
+	Reads bool `json:"reads"`
 }
 
 func (s *Server) handleMuseums(w http.ResponseWriter, r *http.Request) {
@@ -516,13 +679,14 @@ func (s *Server) handleMuseums(w http.ResponseWriter, r *http.Request) {
 		museums = append(museums, museumHitFrom(hit, round2(hit.DistanceKm)))
 	}
 
-	writeJSON(w, http.StatusOK, museumResponse{
+	response := museumResponse{
 		Count:   len(museums),
 		Total:   page.Total,
 		HasMore: int64(q.offset+len(museums)) < page.Total,
 		Museums: museums,
 		Query:   echo(q),
-	})
+	}
+	writeResults(w, r, response, func() featureCollection { return museumCollection(response) })
 }
 
 // maxPoints bounds a map request. Enough to draw the world — the museums are
@@ -573,10 +737,38 @@ func (s *Server) handlePlaces(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+const (
+	// maxRawPoints is the density below which a zoomed view is sent museum by
+	// museum rather than clustered. A few thousand dots is a response measured
+	// in tens of kilobytes and a layer the map draws without effort; beyond it
+	// the dots overlap into a smear that a count says better.
+	maxRawPoints = 5_000
+
+	// clusterPixels is roughly how wide a cluster is on screen. The grid is
+	// sized from it, so a cluster covers about the same patch of screen at
+	// every zoom and the map does not go from a few blobs to a carpet of them
+	// between one scroll step and the next.
+	clusterPixels = 48
+
+	// maxZoom bounds the zoom a caller may ask to be clustered for. Past
+	// street level there is nothing left to group.
+	maxZoom = 24
+)
+
 // handlePoints returns museum positions for drawing.
+//
+// With a zoom, the view is clustered in the database whenever it holds more
+// than maxRawPoints museums, and sent point by point below that. Without one it
+// answers as it always has, with the most prominent museums up to the limit, so
+// a client that has not learned about zoom keeps working.
 func (s *Server) handlePoints(w http.ResponseWriter, r *http.Request) {
 	values := r.URL.Query()
 
+	if values.Get("zoom") != "" {
+		s.handlePointClusters(w, r)
+		return
+	}
+
 	limit := maxPoints
 	if raw := values.Get("limit"); raw != "" {
 		parsed, err := strconv.Atoi(raw)
@@ -607,13 +799,107 @@ func (s *Server) handlePoints(w http.ResponseWriter, r *http.Request) {
 		flat = append(flat, [3]float64{float64(p.ID), p.Lat, p.Lon})
 	}
 
-	writeJSON(w, http.StatusOK, map[string]any{
+	writeResults(w, r, map[string]any{
 		"count":     len(flat),
 		"truncated": len(flat) == limit,
 		"points":    flat,
+	}, func() featureCollection {
+		return pointCollection(flat, pointsQuery{BBox: values.Get("bbox"), Limit: limit})
+	})
+}
+
+// handlePointClusters answers a map view at a zoom, grouped when it is dense.
+func (s *Server) handlePointClusters(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+
+	zoom, err := parseFloat(values.Get("zoom"), "zoom")
+	if err != nil {
+		writeQueryError(w, r, err)
+		return
+	}
+	if zoom < 0 || zoom > maxZoom {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("zoom must be between 0 and %d", maxZoom))
+		return
+	}
+
+	west, south, east, north, hasBox, err := parseBBox(values.Get("bbox"))
+	if err != nil {
+		writeQueryError(w, r, err)
+		return
+	}
+
+	clustering, err := s.catalogue.PointClusters(r.Context(), west, south, east, north, hasBox,
+		clusterCellDegrees(zoom), maxRawPoints, maxPoints)
+	if err != nil {
+		writeServerError(w, r, err)
+		return
+	}
+
+	if clustering.Clusters == nil {
+		flat := make([][3]float64, 0, len(clustering.Points))
+		for _, p := range clustering.Points {
+			flat = append(flat, [3]float64{float64(p.ID), p.Lat, p.Lon})
+		}
+		writeResults(w, r, map[string]any{
+			"count":     len(flat),
+			"museums":   clustering.Museums,
+			"clustered": false,
+			"truncated": false,
+			"points":    flat,
+		}, func() featureCollection {
+			collection := pointCollection(flat, pointsQuery{BBox: values.Get("bbox"), Zoom: &zoom})
+			collection.Total = clustering.Museums
+			return collection
+		})
+		return
+	}
+
+	clusters := make([]clusterHit, 0, len(clustering.Clusters))
+	for _, c := range clustering.Clusters {
+		clusters = append(clusters, clusterHit{
+			Latitude: c.Lat, Longitude: c.Lon,
+			Museums: c.Museums, Exhibitions: c.Exhibitions,
+			Museum: clusterMuseum{ID: c.RepresentativeID, Name: c.RepresentativeName},
+		})
+	}
+	// Clusters come largest first, so a view cut at the limit has lost its
+	// smallest, whose museums the count still includes.
+	writeResults(w, r, map[string]any{
+		"count":     len(clusters),
+		"museums":   clustering.Museums,
+		"clustered": true,
+		"truncated": len(clusters) == maxPoints,
+		"clusters":  clusters,
+	}, func() featureCollection {
+		return clusterCollection(clusters, clustering.Museums, pointsQuery{BBox: values.Get("bbox"), Zoom: &zoom})
 	})
 }
 
+// clusterHit is one group of museums on the map.
+type clusterHit struct {
+	Latitude  float64 `json:"lat"`
+	Longitude float64 `json:"lon"`
+	Museums   int64   `json:"museums"`
+	// Exhibitions counts what is on show in the same cell, so a map can tell a
+	// dense but quiet area from one with something to see.
+	Exhibitions int64 `json:"exhibitions"`
+	// Museum is the cluster's most prominent member, which is what a label
+	// names and a click opens.
+	Museum clusterMuseum `json:"museum"`
+}
+
+type clusterMuseum struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// clusterCellDegrees sizes the clustering grid for a zoom level, so a cell is
+// about clusterPixels across on screen. At zoom z a web map is 512·2^z pixels
+// around the equator.
+func clusterCellDegrees(zoom float64) float64 {
+	return clusterPixels * 360 / (512 * math.Pow(2, zoom))
+}
+
 // parseBBox reads "west,south,east,north" in degrees.
 func parseBBox(raw string) (west, south, east, north float64, ok bool, err error) {
 	if raw == "" {
@@ -661,6 +947,47 @@ func (s *Server) handleMuseum(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, museumHitFrom(hit, 0))
 }
 
+// handleMuseumHistory lists what has changed on a museum, newest first, and
+// which run changed it. A numeric id is answered after the museum has been
+// merged away: that is when its history is wanted.
+func (s *Server) handleMuseumHistory(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		writeQueryError(w, r, err)
+		return
+	}
+	offset, err := parseOffset(r.URL.Query().Get("offset"))
+	if err != nil {
+		writeQueryError(w, r, err)
+		return
+	}
+
+	history, err := s.catalogue.MuseumHistory(r.Context(), r.PathValue("id"), limit, offset)
+	if errors.Is(err, postgres.ErrNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeServerError(w, r, err)
+		return
+	}
+
+	revisions := make([]revisionHit, 0, len(history.Revisions))
+	for _, rev := range history.Revisions {
+		revisions = append(revisions, revisionHit{
+			Field: rev.Field, Old: rev.Old, New: rev.New,
+			Source: rev.Source, Operation: rev.Operation, ChangedAt: rev.ChangedAt,
+		})
+	}
+	writeJSON(w, http.StatusOK, historyResponse{
+		MuseumID:  history.MuseumID,
+		Count:     len(revisions),
+		Total:     history.Total,
+		HasMore:   int64(offset+len(revisions)) < history.Total,
+		Revisions: revisions,
+	})
+}
+
 // handleSearch answers name queries.
 //
 // This is the only interface that reaches museums with no coordinates, and the
@@ -705,7 +1032,7 @@ func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	writeJSON(w, http.StatusOK, searchResponse{
+	response := searchResponse{
 		Count:   len(museums),
 		Total:   page.Total,
 		HasMore: int64(offset+len(museums)) < page.Total,
@@ -713,7 +1040,8 @@ func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 		Offset:  offset,
 		Query:   query,
 		Museums: museums,
-	})
+	}
+	writeResults(w, r, response, func() featureCollection { return searchCollection(response) })
 }
 
 func (s *Server) handleExhibitions(w http.ResponseWriter, r *http.Request) {
@@ -736,6 +1064,22 @@ func (s *Server) handleExhibitions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A day asks about the past, and is answered from the archive as well as
+	// the live table; a search by name over the past is not offered.
+	if on := values.Get("on"); on != "" {
+		archive, ok := s.catalogue.(archiveCatalogue)
+		if !ok {
+			writeError(w, http.StatusNotImplemented, errors.New("this catalogue keeps no exhibition history"))
+			return
+		}
+		if text != "" {
+			writeError(w, http.StatusBadRequest, errors.New("on cannot be combined with q"))
+			return
+		}
+		s.exhibitionsOn(w, r, archive, on)
+		return
+	}
+
 	// A search may name a place or not. Without one it searches everywhere,
 	// which is the point: someone who knows a show's name rarely knows which
 	// town it is in, and requiring a location made the name useless.
@@ -761,24 +1105,13 @@ func (s *Server) handleExhibitions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	found := make([]exhibitionHit, 0, len(hits))
-	for _, hit := range hits {
-		found = append(found, exhibitionHit{
-			Title: hit.Title, URL: hit.URL, Museum: hit.Museum,
-			MuseumWikidataID: hit.MuseumWikidataID,
-			DistanceKm:       round2(hit.DistanceKm),
-			Start:            hit.Start, End: hit.End,
-			Running: hit.Running, Upcoming: hit.Upcoming,
-			Permanent: hit.Permanent,
-			Latitude:  hit.Latitude, Longitude: hit.Longitude,
-			ScrapedAt: hit.ScrapedAt,
-		})
-	}
+	found := exhibitionHits(hits)
 
-	writeJSON(w, http.StatusOK, exhibitionResponse{
+	response := exhibitionResponse{
 		Count: len(found), Exhibitions: found, Query: echo(q),
 		Coverage: s.coverageFor(r, q, len(found)),
-	})
+	}
+	writeResults(w, r, response, func() featureCollection { return exhibitionCollection(response) })
 }
 
 // searchExhibitions answers a search by name, with or without a place.
@@ -805,6 +1138,19 @@ func (s *Server) searchExhibitions(w http.ResponseWriter, r *http.Request, text
 		return
 	}
 
+	found := exhibitionHits(hits)
+
+	echoed := echo(q)
+	echoed.Limit, echoed.Offset, echoed.Text = limit, offset, text
+
+	response := exhibitionResponse{
+		Count: len(found), Total: total, Exhibitions: found, Query: echoed,
+	}
+	writeResults(w, r, response, func() featureCollection { return exhibitionCollection(response) })
+}
+
+// exhibitionHits is hits as the API writes them.
+func exhibitionHits(hits []postgres.ExhibitionHit) []exhibitionHit {
 	found := make([]exhibitionHit, 0, len(hits))
 	for _, hit := range hits {
 		found = append(found, exhibitionHit{
@@ -816,14 +1162,77 @@ func (s *Server) searchExhibitions(w http.ResponseWriter, r *http.Request, text
 			Permanent: hit.Permanent,
 			Latitude:  hit.Latitude, Longitude: hit.Longitude,
 			ScrapedAt: hit.ScrapedAt,
+			Archived:  hit.Archived,
 		})
 	}
+	return found
+}
 
+// exhibitionsOn answers what was on show around a place on a given day, the
+// archive included.
+//
+// Coverage is left out for the reason it is left out of a search: it reports
+// the scraping of an area as it stands now, and says nothing about a day in
+// the past.
+func (s *Server) exhibitionsOn(w http.ResponseWriter, r *http.Request, archive archiveCatalogue, on string) {
+	day, err := time.Parse(time.DateOnly, on)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errors.New("on must be a date, as 2006-01-02"))
+		return
+	}
+	q, err := s.parseQuery(r)
+	if err != nil {
+		writeQueryError(w, r, err)
+		return
+	}
+
+	hits, err := archive.ExhibitionsOn(r.Context(), q.lat, q.lon, q.radiusKm, day, q.limit)
+	if err != nil {
+		writeServerError(w, r, err)
+		return
+	}
+
+	found := exhibitionHits(hits)
 	echoed := echo(q)
-	echoed.Limit, echoed.Offset, echoed.Text = limit, offset, text
+	echoed.On = on
+	response := exhibitionResponse{Count: len(found), Exhibitions: found, Query: echoed}
+	writeResults(w, r, response, func() featureCollection { return exhibitionCollection(response) })
+}
 
-	writeJSON(w, http.StatusOK, exhibitionResponse{
-		Count: len(found), Total: total, Exhibitions: found, Query: echoed,
+// handleMuseumExhibitions lists every exhibition a museum has held that the
+// catalogue knows of, the archived ones included, most recent first.
+func (s *Server) handleMuseumExhibitions(w http.ResponseWriter, r *http.Request) {
+	archive, ok := s.catalogue.(archiveCatalogue)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, errors.New("this catalogue keeps no exhibition history"))
+		return
+	}
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		writeQueryError(w, r, err)
+		return
+	}
+	offset, err := parseOffset(r.URL.Query().Get("offset"))
+	if err != nil {
+		writeQueryError(w, r, err)
+		return
+	}
+
+	hits, total, err := archive.MuseumExhibitions(r.Context(), r.PathValue("id"), limit, offset)
+	if errors.Is(err, postgres.ErrNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeServerError(w, r, err)
+		return
+	}
+
+	found := exhibitionHits(hits)
+	writeJSON(w, http.StatusOK, museumExhibitionsResponse{
+		Count: len(found), Total: total,
+		HasMore:     int64(offset+len(found)) < total,
+		Exhibitions: found,
 	})
 }
 
@@ -867,6 +1276,7 @@ func museumHitFrom(hit postgres.Hit, distanceKm float64) museumHit {
 	return museumHit{
 		ID: hit.ID, Name: m.Name, DistanceKm: distanceKm,
 		ApproximateLocation: hit.ApproximateLocation,
+		RetiredAt:           hit.RetiredAt,
 		Verified:            m.Verified,
 		Country:             m.Country, Locality: m.Locality, Description: m.Description,
 		Latitude: m.Latitude, Longitude: m.Longitude,
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strconv"
 	"testing"
 	"time"
 
@@ -34,11 +36,20 @@ type fakeCatalogue struct {
 
 	coverage         postgres.Coverage
 	lastVerifiedOnly bool
+	lastCellDegrees  float64
+
+	generation       postgres.Generation
+	nearbyCalls      int
+	exhibitionsCalls int
+	byIDCalls        int
+
+	history postgres.History
 }
 
 func (f *fakeCatalogue) NearbyVerified(_ context.Context, _, _, radiusKm float64, limit, offset int, verifiedOnly bool) (postgres.Page, error) {
 	f.lastRadiusKm, f.lastLimit, f.lastOffset = radiusKm, limit, offset
 	f.lastVerifiedOnly = verifiedOnly
+	f.nearbyCalls++
 	return postgres.Page{Hits: f.nearby, Total: int64(len(f.nearby))}, f.err
 }
 
@@ -48,6 +59,7 @@ func (f *fakeCatalogue) Search(_ context.Context, _ string, limit, offset int) (
 }
 
 func (f *fakeCatalogue) MuseumByID(_ context.Context, id string) (postgres.Hit, error) {
+	f.byIDCalls++
 	if f.err != nil {
 		return postgres.Hit{}, f.err
 	}
@@ -59,6 +71,17 @@ func (f *fakeCatalogue) MuseumByID(_ context.Context, id string) (postgres.Hit,
 	return postgres.Hit{}, postgres.ErrNotFound
 }
 
+func (f *fakeCatalogue) MuseumHistory(_ context.Context, id string, limit, offset int) (postgres.History, error) {
+	f.lastLimit, f.lastOffset = limit, offset
+	if f.err != nil {
+		return postgres.History{}, f.err
+	}
+	if id != strconv.FormatInt(f.history.MuseumID, 10) {
+		return postgres.History{}, postgres.ErrNotFound
+	}
+	return f.history, nil
+}
+
 func (f *fakeCatalogue) Points(_ context.Context, _, _, _, _ float64, _ bool, limit int) ([]postgres.Point, error) {
 	f.lastLimit = limit
 	points := make([]postgres.Point, 0, len(f.nearby))
@@ -70,12 +93,35 @@ func (f *fakeCatalogue) Points(_ context.Context, _, _, _, _ float64, _ bool, li
 	return points, f.err
 }
 
+// PointClusters clusters everything into one group once there are more points
+// than rawBelow, which is all a handler test needs to tell the two modes apart.
+func (f *fakeCatalogue) PointClusters(ctx context.Context, west, south, east, north float64, hasBox bool,
+	cellDegrees float64, rawBelow, limit int,
+) (postgres.Clustering, error) {
+	f.lastCellDegrees = cellDegrees
+	points, err := f.Points(ctx, west, south, east, north, hasBox, limit)
+	if err != nil {
+		return postgres.Clustering{}, err
+	}
+	clustering := postgres.Clustering{Museums: int64(len(points))}
+	if len(points) <= rawBelow {
+		clustering.Points = points
+		return clustering, nil
+	}
+	clustering.Clusters = []postgres.Cluster{{
+		Lat: points[0].Lat, Lon: points[0].Lon, Museums: int64(len(points)),
+		RepresentativeID: points[0].ID, RepresentativeName: f.nearby[0].Museum.Name,
+	}}
+	return clustering, nil
+}
+
 func (f *fakeCatalogue) ExhibitionCoverage(context.Context, float64, float64, float64) (postgres.Coverage, error) {
 	return f.coverage, f.err
 }
 
 func (f *fakeCatalogue) ExhibitionsNearby(_ context.Context, _, _, radiusKm float64, upcoming bool, limit int) ([]postgres.ExhibitionHit, error) {
 	f.lastRadiusKm, f.lastLimit, f.lastUpcoming = radiusKm, limit, upcoming
+	f.exhibitionsCalls++
 	return f.exhibitions, f.err
 }
 
@@ -92,6 +138,27 @@ func (f *fakeCatalogue) SearchExhibitions(_ context.Context, query string, _, _,
 func (f *fakeCatalogue) Counts(context.Context) (postgres.Counts, error) { return f.counts, f.err }
 func (f *fakeCatalogue) Ping(context.Context) error                      { return f.err }
 
+func (f *fakeCatalogue) Generation(context.Context) (postgres.Generation, error) {
+	return f.generation, nil
+}
+
+func (f *fakeCatalogue) EachMuseum(_ context.Context, fn func(int64, models.Museum)) error {
+	for _, hit := range f.nearby {
+		fn(hit.ID, hit.Museum)
+	}
+	return f.err
+}
+
+func (f *fakeCatalogue) MuseumIDs(_ context.Context, after int64, limit int) ([]int64, error) {
+	var ids []int64
+	for _, hit := range f.nearby {
+		if hit.ID > after && len(ids) < limit {
+			ids = append(ids, hit.ID)
+		}
+	}
+	return ids, f.err
+}
+
 func get(t *testing.T, c Catalogue, target string) *httptest.ResponseRecorder {
 	t.Helper()
 	rec := httptest.NewRecorder()
@@ -251,6 +318,92 @@ func TestHealth_ReportsWhatTheCatalogueHolds(t *testing.T) {
 	}
 }
 
+// deadLetterFake is a catalogue that holds dead letters.
+type deadLetterFake struct {
+	fakeCatalogue
+	letters map[string]int64
+}
+
+func (f *deadLetterFake) DeadLetterCounts(context.Context) (map[string]int64, error) {
+	return f.letters, nil
+}
+
+func TestHealth_ReportsDeadLetters(t *testing.T) {
+	c := &deadLetterFake{letters: map[string]int64{"location": 3, "store": 1}}
+
+	var body struct {
+		Status      string           `json:"status"`
+		DeadLetters map[string]int64 `json:"dead_letters"`
+	}
+	rec := get(t, c, "/health")
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if rec.Code != http.StatusOK || body.DeadLetters["location"] != 3 || body.DeadLetters["store"] != 1 {
+		t.Errorf("status %d, body %+v", rec.Code, body)
+	}
+}
+
+// pooledFake is a catalogue with a primary and a replica behind it.
+type pooledFake struct {
+	fakeCatalogue
+	pools []postgres.PoolStatus
+}
+
+func (f *pooledFake) Pools(context.Context) []postgres.PoolStatus { return f.pools }
+
+func TestReady_ReportsEachPool(t *testing.T) {
+	down := errors.New("connection refused")
+	cases := []struct {
+		name  string
+		pools []postgres.PoolStatus
+		code  int
+		want  []string
+	}{
+		{"replica serving", []postgres.PoolStatus{
+			{Name: "primary"},
+			{Name: "replica", Lag: 300 * time.Millisecond, Reads: true},
+		}, http.StatusOK, []string{"ready", "ready"}},
+		// A replica that is behind or down is reported, and the reads have
+		// gone to the primary, so the server is still ready.
+		{"replica behind", []postgres.PoolStatus{
+			{Name: "primary", Reads: true},
+			{Name: "replica", Lag: 2 * time.Minute, Behind: true},
+		}, http.StatusOK, []string{"ready", "behind"}},
+		{"replica down", []postgres.PoolStatus{
+			{Name: "primary", Reads: true},
+			{Name: "replica", Err: down},
+		}, http.StatusOK, []string{"ready", "unavailable"}},
+		{"primary down", []postgres.PoolStatus{
+			{Name: "primary", Err: down, Reads: true},
+		}, http.StatusServiceUnavailable, []string{"unavailable"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := get(t, &pooledFake{pools: tc.pools}, "/readyz")
+			if rec.Code != tc.code {
+				t.Errorf("status = %d, want %d", rec.Code, tc.code)
+			}
+			var body readiness
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			var got []string
+			for _, p := range body.Pools {
+				got = append(got, p.Status)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("pools = %v, want %v; body %s", got, tc.want, rec.Body)
+			}
+		})
+	}
+
+	rec := get(t, &pooledFake{pools: cases[1].pools}, "/readyz")
+	var body readiness
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if lag := body.Pools[1].LagSeconds; lag == nil || *lag != 120 || body.Pools[1].Reads || !body.Pools[0].Reads {
+		t.Errorf("replica behind = %s, want its lag and the reads on the primary", rec.Body)
+	}
+}
+
 // Exhibitions were reachable only through a location. Someone who knew the name
 // of a show but not the town it was in — the ordinary case for anything
 // touring, or anything a friend mentioned — could not ask at all.
@@ -292,3 +445,227 @@ func TestExhibitions_SearchByName(t *testing.T) {
 		t.Errorf("radius reached the query as %v, want 5", c.lastRadiusKm)
 	}
 }
+
+// A world view used to be the 40,000 most prominent museums and nothing to say
+// where the other hundred thousand were. With a zoom, a dense view comes back
+// as clusters that account for every museum in it, and a sparse one still
+// comes back point by point.
+func TestPoints_ClustersDenseViewsWhenGivenAZoom(t *testing.T) {
+	dense := make([]postgres.Hit, maxRawPoints+1)
+	for i := range dense {
+		dense[i] = postgres.Hit{ID: int64(i + 1),
+			Museum: models.Museum{Name: "Museum", Latitude: 48.86, Longitude: 2.33}}
+	}
+
+	rec := get(t, &fakeCatalogue{nearby: dense}, "/v1/points?zoom=2")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	var clustered struct {
+		Clustered bool         `json:"clustered"`
+		Truncated bool         `json:"truncated"`
+		Museums   int64        `json:"museums"`
+		Clusters  []clusterHit `json:"clusters"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &clustered); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !clustered.Clustered || len(clustered.Clusters) != 1 {
+		t.Fatalf("a dense view was not clustered: %s", rec.Body)
+	}
+	if clustered.Museums != int64(len(dense)) || clustered.Clusters[0].Museums != int64(len(dense)) {
+		t.Errorf("clusters account for %d museums, want %d", clustered.Museums, len(dense))
+	}
+	if clustered.Truncated {
+		t.Error("one cluster was reported truncated")
+	}
+
+	sparse := &fakeCatalogue{nearby: dense[:3]}
+	rec = get(t, sparse, "/v1/points?zoom=12&bbox=2.2,48.8,2.4,48.9")
+	var raw struct {
+		Clustered bool         `json:"clustered"`
+		Points    [][3]float64 `json:"points"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &raw); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if raw.Clustered || len(raw.Points) != 3 {
+		t.Errorf("a sparse view was not sent point by point: %s", rec.Body)
+	}
+	// A grid twelve zoom levels in is four thousand times finer than at the
+	// top; a cell size that did not shrink would cluster a city into one blob.
+	if want := clusterCellDegrees(12); sparse.lastCellDegrees != want {
+		t.Errorf("cell size = %v, want %v", sparse.lastCellDegrees, want)
+	}
+
+	for _, bad := range []string{"zoom=-1", "zoom=99", "zoom=near"} {
+		if rec := get(t, &fakeCatalogue{}, "/v1/points?"+bad); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", bad, rec.Code)
+		}
+	}
+}
+
+// clusterLimit clusters a view into as many cells as the limit allows.
+type clusterLimit struct{ *fakeCatalogue }
+
+func (clusterLimit) PointClusters(_ context.Context, _, _, _, _ float64, _ bool, _ float64, _, limit int) (postgres.Clustering, error) {
+	clustering := postgres.Clustering{Museums: int64(limit) + 1, Clusters: make([]postgres.Cluster, limit)}
+	for i := range clustering.Clusters {
+		clustering.Clusters[i] = postgres.Cluster{Museums: 1, RepresentativeID: int64(i + 1)}
+	}
+	clustering.Clusters[0].Museums = 2
+	return clustering, nil
+}
+
+// A view with more cells than the limit says so, as a raw one does.
+func TestPoints_ReportsTruncatedClusters(t *testing.T) {
+	rec := get(t, clusterLimit{&fakeCatalogue{}}, "/v1/points?zoom=8")
+	var body struct {
+		Clustered bool `json:"clustered"`
+		Truncated bool `json:"truncated"`
+		Count     int  `json:"count"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !body.Clustered || !body.Truncated || body.Count != maxPoints {
+		t.Errorf("clustered %v, truncated %v, count %d; want %d clusters reported truncated",
+			body.Clustered, body.Truncated, body.Count, maxPoints)
+	}
+}
+
+func TestMuseumHistory(t *testing.T) {
+	changed := time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC)
+	c := &fakeCatalogue{history: postgres.History{
+		MuseumID: 42,
+		Total:    3,
+		Revisions: []postgres.Revision{
+			{Field: "website", Old: json.RawMessage(`"https://tate.org.uk"`), New: json.RawMessage(`"https://www.tate.org.uk"`),
+				Source: "crawl 2026-03-01T02:00:00Z", Operation: "update", ChangedAt: changed},
+			{Field: "location", New: json.RawMessage(`[51.5076, -0.0994]`),
+				Source: "locate 2026-02-27T09:00:00Z", Operation: "update", ChangedAt: changed.Add(-48 * time.Hour)},
+		},
+	}}
+
+	rec := get(t, c, "/v1/museums/42/history?limit=2")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	var body historyResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.MuseumID != 42 || body.Count != 2 || !body.HasMore {
+		t.Errorf("response = %+v, want two of three revisions of museum 42", body)
+	}
+	website := body.Revisions[0]
+	if website.Field != "website" || website.Source != "crawl 2026-03-01T02:00:00Z" ||
+		string(website.New) != `"https://www.tate.org.uk"` {
+		t.Errorf("first revision = %+v", website)
+	}
+	if located := body.Revisions[1]; located.Old != nil || string(located.New) != `[51.5076,-0.0994]` {
+		t.Errorf("location revision = old %s, new %s; want no old value and the pair as JSON", located.Old, located.New)
+	}
+
+	if rec := get(t, c, "/v1/museums/7/history"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown museum: status = %d, want 404", rec.Code)
+	}
+	if rec := get(t, c, "/v1/museums/42/history?offset=20000"); rec.Code != http.StatusBadRequest {
+		t.Errorf("offset past the cap: status = %d, want 400", rec.Code)
+	}
+}
+
+// archiveFake is a catalogue that keeps exhibitions after they end.
+type archiveFake struct {
+	fakeCatalogue
+	day    time.Time
+	past   []postgres.ExhibitionHit
+	total  int64
+	museum string
+}
+
+func (f *archiveFake) ExhibitionsOn(_ context.Context, _, _, _ float64, day time.Time, _ int) ([]postgres.ExhibitionHit, error) {
+	f.day = day
+	return f.past, nil
+}
+
+func (f *archiveFake) MuseumExhibitions(_ context.Context, id string, _, _ int) ([]postgres.ExhibitionHit, int64, error) {
+	if id != f.museum {
+		return nil, 0, postgres.ErrNotFound
+	}
+	return f.past, f.total, nil
+}
+
+func TestExhibitions_OnADayReadsTheArchive(t *testing.T) {
+	c := &archiveFake{museum: "Q193375", total: 3, past: []postgres.ExhibitionHit{
+		{Exhibition: exhibitions.Exhibition{Title: "Kusama", Running: true}, Archived: true},
+	}}
+
+	rec := get(t, c, "/v1/exhibitions?lat=51.5&lon=-0.1&on=2024-03-01")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	var body exhibitionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if !c.day.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) || body.Query.On != "2024-03-01" {
+		t.Errorf("asked about %s, echoed %q; want 2024-03-01", c.day, body.Query.On)
+	}
+	if body.Count != 1 || !body.Exhibitions[0].Archived {
+		t.Errorf("exhibitions = %+v, want the archived one", body.Exhibitions)
+	}
+
+	for _, path := range []string{
+		"/v1/exhibitions?lat=51.5&lon=-0.1&on=last-spring",
+		"/v1/exhibitions?on=2024-03-01&q=kusama",
+	} {
+		if rec := get(t, c, path); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", path, rec.Code)
+		}
+	}
+
+	rec = get(t, c, "/v1/museums/Q193375/exhibitions?limit=1")
+	var page museumExhibitionsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || page.Count != 1 || page.Total != 3 || !page.HasMore {
+		t.Errorf("museum exhibitions = %d %+v, want one of three", rec.Code, page)
+	}
+	if rec := get(t, c, "/v1/museums/Q1/exhibitions"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown museum: status = %d, want 404", rec.Code)
+	}
+
+	// A catalogue without an archive says so rather than answering with
+	// today's exhibitions.
+	if rec := get(t, &fakeCatalogue{}, "/v1/exhibitions?lat=51.5&lon=-0.1&on=2024-03-01"); rec.Code != http.StatusNotImplemented {
+		t.Errorf("no archive: status = %d, want 501", rec.Code)
+	}
+}
+
+func TestMuseumByIDMarksARetiredMuseum(t *testing.T) {
+	retired := time.Date(2026, 5, 1, 3, 0, 0, 0, time.UTC)
+	c := &fakeCatalogue{nearby: []postgres.Hit{
+		{ID: 9, Museum: models.Museum{Name: "Musée fermé", WikidataID: "Q9"}, RetiredAt: &retired},
+		{ID: 10, Museum: models.Museum{Name: "Musée ouvert", WikidataID: "Q10"}},
+	}}
+
+	var body map[string]any
+	rec := get(t, c, "/v1/museums/Q9")
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["retired_at"] != "2026-05-01T03:00:00Z" {
+		t.Errorf("retired_at = %v, want the retirement time", body["retired_at"])
+	}
+
+	body = nil
+	rec = get(t, c, "/v1/museums/Q10")
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := body["retired_at"]; ok {
+		t.Errorf("a museum still in the catalogue carries retired_at: %s", rec.Body)
+	}
+}
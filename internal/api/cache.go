@@ -0,0 +1,279 @@
+package api
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"museum/internal/postgres"
+)
+
+const (
+	// cacheControl lets browsers and any proxy in between reuse a read for a
+	// minute, and revalidate it after that. A minute is short against how often
+	// the catalogue actually changes — a sweep writes a site's listings a few
+	// times a day — and long against how often the map asks the same question.
+	cacheControl = "public, max-age=60"
+
+	// maxCachedResponses bounds the in-process response cache by entry count.
+	maxCachedResponses = 512
+
+	// maxCachedBody is the largest response kept. A place query for a big city
+	// at the maximum limit is a few hundred kilobytes; anything larger is a
+	// request unusual enough that keeping it would only evict the common ones.
+	maxCachedBody = 1 << 20
+)
+
+// cacheable marks a read whose answer is a function of its URL and the
+// catalogue alone, so it can be validated against the catalogue generation.
+//
+// Every response it passes gets a strong ETag naming the generation it was
+// read from, and a conditional request for the same generation is answered
+// 304 without touching the handler. Nothing is validated on a failed read:
+// only a 200 carries the headers, so an error is never cached as an answer.
+//
+// Requests naming a place are also kept in process. Those are the hot ones —
+// place=London arrives far more often than any particular coordinate pair —
+// and each costs a place lookup as well as the query itself.
+func (s *Server) cacheable(next http.HandlerFunc) http.HandlerFunc {
+	return s.validated(next, false)
+}
+
+// cacheableToday marks a read that also depends on the date: what is on show,
+// and so what is counted or listed as running, changes at midnight with no
+// write to the catalogue. Its validators and cache key name the day as well
+// as the generation, so yesterday's answer is not revalidated today.
+func (s *Server) cacheableToday(next http.HandlerFunc) http.HandlerFunc {
+	return s.validated(next, true)
+}
+
+func (s *Server) validated(next http.HandlerFunc, dated bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		generation, err := s.catalogue.Generation(r.Context())
+		if err != nil {
+			// Serving uncached is always correct; only the saving is lost.
+			log.Printf("api: catalogue generation unavailable, serving uncached: %v", err)
+			next(w, r)
+			return
+		}
+
+		// GeoJSON is negotiated, so the same URL has two representations: each
+		// needs its own tag, and a cache between here and the client needs
+		// telling that the answer depends on Accept.
+		variant := ""
+		if wantsGeoJSON(r) {
+			variant = "geo"
+		}
+		w.Header().Add("Vary", "Accept")
+
+		var day time.Time
+		if dated {
+			// The day as the catalogue reckons it: the database compares
+			// against current_date, in local time.
+			now := s.now()
+			day = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		}
+		validators := validatorsFor(generation, variant, day)
+		if validators.matches(r) {
+			validators.apply(w.Header())
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		var key string
+		if r.URL.Query().Get("place") != "" {
+			// Encode sorts the parameters, so the order a client wrote them in
+			// does not split one query across several entries.
+			key = fmt.Sprintf("%s %s?%s", validators.etag, r.URL.Path, r.URL.Query().Encode())
+			if hit, ok := s.responses.get(key); ok {
+				w.Header().Set("Content-Type", hit.contentType)
+				validators.apply(w.Header())
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write(hit.body)
+				return
+			}
+		}
+
+		recorder := &validatingWriter{ResponseWriter: w, validators: validators, keep: key != ""}
+		next(recorder, r)
+
+		if key != "" && recorder.status == http.StatusOK && !recorder.overflowed {
+			s.responses.put(key, cachedResponse{
+				contentType: recorder.Header().Get("Content-Type"),
+				body:        recorder.body.Bytes(),
+			})
+		}
+	}
+}
+
+// validators are the headers a response is revalidated against.
+type validators struct {
+	etag         string
+	lastModified time.Time
+}
+
+// validatorsFor names the generation, and the day when it is not zero. A dated
+// answer was last modified at midnight if the catalogue has not changed since.
+func validatorsFor(generation postgres.Generation, variant string, day time.Time) validators {
+	tag := fmt.Sprintf("g%d", generation.Number)
+	modified := generation.ChangedAt
+	if !day.IsZero() {
+		tag += "-d" + day.Format("20060102")
+		if day.After(modified) {
+			modified = day
+		}
+	}
+	if variant != "" {
+		tag += "-" + variant
+	}
+	return validators{
+		etag:         `"` + tag + `"`,
+		lastModified: modified.UTC().Truncate(time.Second),
+	}
+}
+
+func (v validators) apply(header http.Header) {
+	header.Set("ETag", v.etag)
+	header.Set("Cache-Control", cacheControl)
+	if !v.lastModified.IsZero() {
+		header.Set("Last-Modified", v.lastModified.Format(http.TimeFormat))
+	}
+}
+
+// matches reports whether the client already holds this generation.
+//
+// If-None-Match wins when both are sent, as RFC 9110 requires: a generation
+// is exact, while a timestamp at one-second resolution is not.
+func (v validators) matches(r *http.Request) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || sameEntity(candidate, v.etag) {
+				return true
+			}
+		}
+		return false
+	}
+
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || v.lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	return !v.lastModified.After(since)
+}
+
+// sameEntity compares two entity tags weakly, which is what If-None-Match
+// asks for. The compressed representation carries its own tag (see
+// gzipWriter), and a client revalidating it is asking about the same
+// generation.
+func sameEntity(a, b string) bool {
+	normalise := func(tag string) string {
+		tag = strings.TrimPrefix(tag, "W/")
+		return strings.Replace(tag, gzipETagSuffix+`"`, `"`, 1)
+	}
+	return normalise(a) == normalise(b)
+}
+
+// validatingWriter adds the validators to a successful response, and keeps a
+// copy of its body when the response is to be cached.
+type validatingWriter struct {
+	http.ResponseWriter
+	validators validators
+	keep       bool
+
+	status     int
+	body       bytes.Buffer
+	overflowed bool
+}
+
+func (v *validatingWriter) WriteHeader(status int) {
+	if v.status != 0 {
+		return
+	}
+	v.status = status
+	if status == http.StatusOK {
+		v.validators.apply(v.Header())
+	}
+	v.ResponseWriter.WriteHeader(status)
+}
+
+func (v *validatingWriter) Write(b []byte) (int, error) {
+	if v.status == 0 {
+		v.WriteHeader(http.StatusOK)
+	}
+	if v.keep && !v.overflowed {
+		if v.body.Len()+len(b) > maxCachedBody {
+			v.overflowed = true
+			v.body = bytes.Buffer{}
+		} else {
+			v.body.Write(b)
+		}
+	}
+	return v.ResponseWriter.Write(b)
+}
+
+// cachedResponse is one response body held in process.
+type cachedResponse struct {
+	contentType string
+	body        []byte
+}
+
+// responseCache is a bounded, least-recently-used store of response bodies.
+//
+// Keys carry the tag of the response, and so the generation it was read at
+// and, for a dated read, the day: a write to the catalogue or midnight
+// invalidates every entry at once without anything being told. The old keys
+// are simply never asked for again, and age out as new ones arrive.
+type responseCache struct {
+	mu      sync.Mutex
+	max     int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key      string
+	response cachedResponse
+}
+
+func newResponseCache(max int) *responseCache {
+	return &responseCache{max: max, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (c *responseCache) get(key string) (cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return cachedResponse{}, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).response, true
+}
+
+func (c *responseCache) put(key string, response cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*cacheEntry).response = response
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, response: response})
+	for c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"museum/internal/models"
+	"museum/internal/postgres"
+)
+
+// fixedPlaces resolves every name to the same spot.
+type fixedPlaces struct{}
+
+func (fixedPlaces) Resolve(_ context.Context, name string) (postgres.Place, error) {
+	return postgres.Place{Query: name, DisplayName: name, Latitude: 51.5, Longitude: -0.12, RadiusKm: 5, Found: true}, nil
+}
+
+func cachingCatalogue() *fakeCatalogue {
+	return &fakeCatalogue{
+		nearby: []postgres.Hit{{Museum: models.Museum{Name: "British Museum", Latitude: 51.52, Longitude: -0.13}}},
+		generation: postgres.Generation{
+			Number:    7,
+			ChangedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		},
+	}
+}
+
+func serve(handler http.Handler, target string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCache_ValidatesReadsAgainstTheGeneration(t *testing.T) {
+	c := cachingCatalogue()
+	routes := NewServer(c).Routes()
+
+	first := serve(routes, "/v1/museums?lat=51.5&lon=-0.12", nil)
+	if first.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", first.Code, first.Body)
+	}
+	etag := first.Header().Get("ETag")
+	if etag != `"g7"` {
+		t.Errorf("ETag = %q, want the generation", etag)
+	}
+	if first.Header().Get("Last-Modified") != "Sun, 01 Mar 2026 12:00:00 GMT" {
+		t.Errorf("Last-Modified = %q", first.Header().Get("Last-Modified"))
+	}
+	if first.Header().Get("Cache-Control") == "" {
+		t.Error("no Cache-Control on a cacheable read")
+	}
+
+	again := serve(routes, "/v1/museums?lat=51.5&lon=-0.12", map[string]string{"If-None-Match": etag})
+	if again.Code != http.StatusNotModified {
+		t.Fatalf("revalidating the same generation: status = %d, want 304", again.Code)
+	}
+	if again.Body.Len() != 0 {
+		t.Errorf("a 304 carried a body: %s", again.Body)
+	}
+	if c.nearbyCalls != 1 {
+		t.Errorf("the query ran %d times; a 304 should not reach the database", c.nearbyCalls)
+	}
+
+	c.generation.Number++
+	after := serve(routes, "/v1/museums?lat=51.5&lon=-0.12", map[string]string{"If-None-Match": etag})
+	if after.Code != http.StatusOK {
+		t.Errorf("after a write: status = %d, want a fresh 200", after.Code)
+	}
+}
+
+func TestCache_AnswersIfModifiedSince(t *testing.T) {
+	routes := NewServer(cachingCatalogue()).Routes()
+
+	rec := serve(routes, "/v1/museums?lat=51.5&lon=-0.12",
+		map[string]string{"If-Modified-Since": "Sun, 01 Mar 2026 12:00:00 GMT"})
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("unchanged since: status = %d, want 304", rec.Code)
+	}
+
+	rec = serve(routes, "/v1/museums?lat=51.5&lon=-0.12",
+		map[string]string{"If-Modified-Since": "Sun, 01 Mar 2026 11:59:59 GMT"})
+	if rec.Code != http.StatusOK {
+		t.Errorf("changed since: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestCache_RecognisesTheCompressedTag(t *testing.T) {
+	routes := NewServer(cachingCatalogue()).Routes()
+
+	zipped := serve(routes, "/v1/museums?lat=51.5&lon=-0.12", map[string]string{"Accept-Encoding": "gzip"})
+	etag := zipped.Header().Get("ETag")
+	if etag != `"g7-gzip"` {
+		t.Fatalf("compressed ETag = %q, want its own tag", etag)
+	}
+
+	rec := serve(routes, "/v1/museums?lat=51.5&lon=-0.12",
+		map[string]string{"Accept-Encoding": "gzip", "If-None-Match": etag})
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("revalidating the compressed tag: status = %d, want 304", rec.Code)
+	}
+}
+
+func TestCache_DoesNotValidateErrors(t *testing.T) {
+	rec := get(t, cachingCatalogue(), "/v1/museums?lat=500&lon=0")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+	if rec.Header().Get("ETag") != "" || rec.Header().Get("Cache-Control") != "" {
+		t.Errorf("an error response was made cacheable: %v", rec.Header())
+	}
+}
+
+func TestCache_KeepsPlaceQueriesInProcess(t *testing.T) {
+	c := cachingCatalogue()
+	routes := NewServer(c).WithPlaces(fixedPlaces{}).Routes()
+
+	for _, target := range []string{
+		"/v1/museums?place=London&radius_km=5",
+		"/v1/museums?radius_km=5&place=London",
+	} {
+		if rec := serve(routes, target, nil); rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body = %s", target, rec.Code, rec.Body)
+		}
+	}
+	if c.nearbyCalls != 1 {
+		t.Errorf("the query ran %d times; the repeat should come from the cache", c.nearbyCalls)
+	}
+
+	c.generation.Number++
+	serve(routes, "/v1/museums?place=London&radius_km=5", nil)
+	if c.nearbyCalls != 2 {
+		t.Errorf("the query ran %d times; a new generation must not be answered from the old one", c.nearbyCalls)
+	}
+}
+
+func TestCache_DatedReadsGoStaleAtMidnight(t *testing.T) {
+	server := NewServer(cachingCatalogue())
+	clock := time.Date(2026, 10, 18, 23, 59, 0, 0, time.Local)
+	server.now = func() time.Time { return clock }
+	routes := server.Routes()
+
+	const target = "/v1/exhibitions?lat=51.5&lon=-0.12"
+	first := serve(routes, target, nil)
+	if first.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", first.Code, first.Body)
+	}
+	etag := first.Header().Get("ETag")
+	if etag != `"g7-d20261018"` {
+		t.Errorf("ETag = %q, want the generation and the day", etag)
+	}
+	modified := first.Header().Get("Last-Modified")
+	if again := serve(routes, target, map[string]string{"If-None-Match": etag}); again.Code != http.StatusNotModified {
+		t.Errorf("revalidating the same day: status = %d, want 304", again.Code)
+	}
+
+	clock = clock.Add(2 * time.Minute)
+	if next := serve(routes, target, map[string]string{"If-None-Match": etag}); next.Code != http.StatusOK {
+		t.Errorf("revalidating yesterday's tag: status = %d, want a fresh 200", next.Code)
+	}
+	if next := serve(routes, target, map[string]string{"If-Modified-Since": modified}); next.Code != http.StatusOK {
+		t.Errorf("unchanged since yesterday: status = %d, want a fresh 200", next.Code)
+	}
+
+	// Reads that do not depend on the day keep their tag across midnight.
+	if museums := serve(routes, "/v1/museums?lat=51.5&lon=-0.12", nil); museums.Header().Get("ETag") != `"g7"` {
+		t.Errorf("undated ETag = %q", museums.Header().Get("ETag"))
+	}
+}
+
+func TestResponseCache_EvictsTheLeastRecentlyUsed(t *testing.T) {
+	cache := newResponseCache(2)
+	cache.put("a", cachedResponse{body: []byte("a")})
+	cache.put("b", cachedResponse{body: []byte("b")})
+	cache.get("a")
+	cache.put("c", cachedResponse{body: []byte("c")})
+
+	if _, ok := cache.get("b"); ok {
+		t.Error("b survived, though it was the least recently used")
+	}
+	if _, ok := cache.get("a"); !ok {
+		t.Error("a was evicted, though it had just been read")
+	}
+}
+
+// pinningCatalogue records which pool each read was pinned to.
+type pinningCatalogue struct {
+	*fakeCatalogue
+	pins  int
+	reads []any
+}
+
+type pinKey struct{}
+
+func (p *pinningCatalogue) PinReader(ctx context.Context) context.Context {
+	p.pins++
+	return context.WithValue(ctx, pinKey{}, p.pins)
+}
+
+func (p *pinningCatalogue) Generation(ctx context.Context) (postgres.Generation, error) {
+	p.reads = append(p.reads, ctx.Value(pinKey{}))
+	return p.fakeCatalogue.Generation(ctx)
+}
+
+func (p *pinningCatalogue) NearbyVerified(ctx context.Context, lat, lon, radiusKm float64, limit, offset int, verifiedOnly bool) (postgres.Page, error) {
+	p.reads = append(p.reads, ctx.Value(pinKey{}))
+	return p.fakeCatalogue.NearbyVerified(ctx, lat, lon, radiusKm, limit, offset, verifiedOnly)
+}
+
+func TestCache_TagsAndRowsComeFromOnePool(t *testing.T) {
+	c := &pinningCatalogue{fakeCatalogue: cachingCatalogue()}
+	routes := NewServer(c).Routes()
+
+	if rec := serve(routes, "/v1/museums?lat=51.5&lon=-0.12", nil); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if c.pins != 1 || len(c.reads) != 2 || c.reads[0] != 1 || c.reads[1] != 1 {
+		t.Errorf("pinned %d times, reads pinned to %v; want the generation and the rows on one pin", c.pins, c.reads)
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"museum/internal/memory"
+	"museum/pkg/location"
+)
+
+// These run the handlers against a real catalogue rather than canned results,
+// so ordering, paging and filtering are what a caller would get. The demo
+// catalogue is used: it holds real museums, and exhibitions kept current.
+
+func memoryCatalogue(t *testing.T) *memory.Catalogue {
+	t.Helper()
+	c, err := memory.Demo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestMuseums_PagesThroughARealCatalogue(t *testing.T) {
+	c := memoryCatalogue(t)
+
+	var first, second museumResponse
+	for target, into := range map[string]*museumResponse{
+		"/v1/museums?lat=48.8606&lon=2.3376&radius_km=2&limit=4":          &first,
+		"/v1/museums?lat=48.8606&lon=2.3376&radius_km=2&limit=4&offset=4": &second,
+	} {
+		rec := get(t, c, target)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body = %s", target, rec.Code, rec.Body)
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), into); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+
+	if first.Count != 4 || first.Total != 6 || !first.HasMore {
+		t.Errorf("first page: count %d, total %d, has_more %v; want 4 of 6 and more", first.Count, first.Total, first.HasMore)
+	}
+	if second.Count != 2 || second.HasMore {
+		t.Errorf("second page: count %d, has_more %v; want the last 2", second.Count, second.HasMore)
+	}
+	all := append(first.Museums, second.Museums...)
+	for i := 1; i < len(all); i++ {
+		if all[i].DistanceKm < all[i-1].DistanceKm {
+			t.Errorf("%s (%v km) after %s (%v km): not nearest first",
+				all[i].Name, all[i].DistanceKm, all[i-1].Name, all[i-1].DistanceKm)
+		}
+	}
+}
+
+func TestExhibitions_UpcomingOnlyWhenAsked(t *testing.T) {
+	c := memoryCatalogue(t)
+
+	count := func(target string) (running, upcoming int) {
+		t.Helper()
+		rec := get(t, c, target)
+		var body exhibitionResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: %v (%s)", target, err, rec.Body)
+		}
+		for _, e := range body.Exhibitions {
+			if e.Upcoming {
+				upcoming++
+			} else {
+				running++
+			}
+		}
+		return running, upcoming
+	}
+
+	if running, upcoming := count("/v1/exhibitions?lat=48.8606&lon=2.3376&radius_km=5"); running != 4 || upcoming != 0 {
+		t.Errorf("by default: %d running, %d upcoming; want 4 and none", running, upcoming)
+	}
+	if running, upcoming := count("/v1/exhibitions?lat=48.8606&lon=2.3376&radius_km=5&upcoming=true"); running != 4 || upcoming != 1 {
+		t.Errorf("with upcoming=true: %d running, %d upcoming; want 4 and 1", running, upcoming)
+	}
+}
+
+func TestSearch_FindsAMisspeltName(t *testing.T) {
+	rec := get(t, memoryCatalogue(t), "/v1/search?q=rijksmusem")
+	var body searchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v (%s)", err, rec.Body)
+	}
+	if body.Count == 0 || body.Museums[0].Name != "Rijksmuseum" {
+		t.Errorf("rijksmusem = %s, want the Rijksmuseum first", rec.Body)
+	}
+}
+
+func TestMuseums_PlaceResolvedFromTheCatalogueTowns(t *testing.T) {
+	c := memoryCatalogue(t)
+	offline := location.GeocoderFunc(func(context.Context, string) (*location.NominatimLocation, error) {
+		return nil, location.ErrNoResults
+	})
+	server := NewServer(c).WithPlaces(NewPlaceResolver(c, offline))
+
+	rec := httptest.NewRecorder()
+	server.Routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/museums?place=amsterdaam", nil))
+	var body museumResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v (%s)", err, rec.Body)
+	}
+	if body.Total != 4 {
+		t.Errorf("amsterdaam = %s, want the four Amsterdam museums", rec.Body)
+	}
+}
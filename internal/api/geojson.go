@@ -0,0 +1,162 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// geoJSONType is the media type of RFC 7946 GeoJSON.
+const geoJSONType = "application/geo+json"
+
+// wantsGeoJSON reports whether a request asked for GeoJSON, by Accept header
+// or by format=geojson. The parameter exists because the places people paste a
+// URL into — geojson.io, QGIS's "add layer from URL", a browser tab — cannot
+// set a header.
+func wantsGeoJSON(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "geojson" {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), geoJSONType)
+}
+
+// featureCollection is a result set as GeoJSON.
+//
+// The counts, the query echo and the coverage report ride along as foreign
+// members, which RFC 7946 allows and every reader ignores. They are what let
+// the same response be paged, and checked for clamping, in either format.
+type featureCollection struct {
+	Type     string          `json:"type"`
+	Count    int             `json:"count"`
+	Total    int64           `json:"total,omitempty"`
+	HasMore  bool            `json:"has_more,omitempty"`
+	Query    any             `json:"query,omitempty"`
+	Coverage *coverageReport `json:"coverage,omitempty"`
+	Features []feature       `json:"features"`
+}
+
+type feature struct {
+	Type       string    `json:"type"`
+	ID         any       `json:"id,omitempty"`
+	Geometry   *geometry `json:"geometry"`
+	Properties any       `json:"properties"`
+}
+
+type geometry struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// pointAt is a GeoJSON point, or nil for something with no position. GeoJSON
+// orders coordinates longitude first, the opposite of everything else here.
+func pointAt(lat, lon float64, located bool) *geometry {
+	if !located {
+		return nil
+	}
+	return &geometry{Type: "Point", Coordinates: [2]float64{lon, lat}}
+}
+
+// writeResults answers in the format the request asked for. The collection is
+// built only when GeoJSON was wanted.
+func writeResults(w http.ResponseWriter, r *http.Request, body any, collection func() featureCollection) {
+	if !wantsGeoJSON(r) {
+		writeJSON(w, http.StatusOK, body)
+		return
+	}
+
+	features := collection()
+	features.Type = "FeatureCollection"
+	if features.Features == nil {
+		features.Features = []feature{}
+	}
+
+	w.Header().Set("Content-Type", geoJSONType)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(features); err != nil {
+		log.Printf("api: write response: %v", err)
+	}
+}
+
+func museumCollection(response museumResponse) featureCollection {
+	collection := featureCollection{
+		Count: response.Count, Total: response.Total, HasMore: response.HasMore, Query: response.Query,
+	}
+	for _, hit := range response.Museums {
+		collection.Features = append(collection.Features, feature{
+			Type: "Feature", ID: hit.ID,
+			// A radius query only ever finds museums with a position.
+			Geometry:   pointAt(hit.Latitude, hit.Longitude, true),
+			Properties: hit,
+		})
+	}
+	return collection
+}
+
+func searchCollection(response searchResponse) featureCollection {
+	collection := featureCollection{
+		Count: response.Count, Total: response.Total, HasMore: response.HasMore,
+		Query: map[string]any{"q": response.Query, "limit": response.Limit, "offset": response.Offset},
+	}
+	for _, hit := range response.Museums {
+		// Search is the one way to reach a museum with no position, and those
+		// stay in the collection with a null geometry rather than vanishing:
+		// a layer that silently drops a quarter of the results is worse than
+		// one that cannot draw them.
+		collection.Features = append(collection.Features, feature{
+			Type: "Feature", ID: hit.ID,
+			Geometry:   pointAt(hit.Latitude, hit.Longitude, hit.Locatable),
+			Properties: hit,
+		})
+	}
+	return collection
+}
+
+func exhibitionCollection(response exhibitionResponse) featureCollection {
+	collection := featureCollection{
+		Count: response.Count, Total: response.Total, Query: response.Query, Coverage: response.Coverage,
+	}
+	for _, hit := range response.Exhibitions {
+		collection.Features = append(collection.Features, feature{
+			Type: "Feature", ID: hit.URL,
+			Geometry:   pointAt(hit.Latitude, hit.Longitude, hit.Latitude != 0 || hit.Longitude != 0),
+			Properties: hit,
+		})
+	}
+	return collection
+}
+
+// pointCollection draws the packed triples /v1/points sends as features. The
+// id is the only property a point has.
+func pointCollection(points [][3]float64, query any) featureCollection {
+	collection := featureCollection{Count: len(points), Query: query}
+	for _, p := range points {
+		id := int64(p[0])
+		collection.Features = append(collection.Features, feature{
+			Type: "Feature", ID: id,
+			Geometry:   pointAt(p[1], p[2], true),
+			Properties: map[string]int64{"id": id},
+		})
+	}
+	return collection
+}
+
+func clusterCollection(clusters []clusterHit, museums int64, query any) featureCollection {
+	collection := featureCollection{Count: len(clusters), Total: museums, Query: query}
+	for _, c := range clusters {
+		collection.Features = append(collection.Features, feature{
+			Type:       "Feature",
+			Geometry:   pointAt(c.Latitude, c.Longitude, true),
+			Properties: c,
+		})
+	}
+	return collection
+}
+
+// pointsQuery echoes a /v1/points request, which has a box and a zoom rather
+// than a centre and a radius.
+type pointsQuery struct {
+	BBox  string   `json:"bbox,omitempty"`
+	Zoom  *float64 `json:"zoom,omitempty"`
+	Limit int      `json:"limit,omitempty"`
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"museum/internal/models"
+	"museum/internal/postgres"
+	"museum/pkg/exhibitions"
+)
+
+type collectionBody struct {
+	Type     string          `json:"type"`
+	Count    int             `json:"count"`
+	Query    json.RawMessage `json:"query"`
+	Features []struct {
+		Type     string `json:"type"`
+		Geometry *struct {
+			Type        string     `json:"type"`
+			Coordinates [2]float64 `json:"coordinates"`
+		} `json:"geometry"`
+		Properties map[string]any `json:"properties"`
+	} `json:"features"`
+}
+
+func decodeCollection(t *testing.T, body []byte) collectionBody {
+	t.Helper()
+	var collection collectionBody
+	if err := json.Unmarshal(body, &collection); err != nil {
+		t.Fatalf("decode: %v\n%s", err, body)
+	}
+	if collection.Type != "FeatureCollection" {
+		t.Fatalf("type = %q, want FeatureCollection", collection.Type)
+	}
+	return collection
+}
+
+func TestGeoJSON_MuseumsByAcceptHeader(t *testing.T) {
+	c := &fakeCatalogue{nearby: []postgres.Hit{
+		{ID: 3, Museum: models.Museum{Name: "Louvre Museum", Latitude: 48.86, Longitude: 2.33}},
+	}}
+
+	rec := serve(NewServer(c).Routes(), "/v1/museums?lat=48.8566&lon=2.3522",
+		map[string]string{"Accept": "application/geo+json"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Content-Type"); got != geoJSONType {
+		t.Errorf("content-type = %q, want %q", got, geoJSONType)
+	}
+
+	collection := decodeCollection(t, rec.Body.Bytes())
+	if len(collection.Features) != 1 {
+		t.Fatalf("features = %d, want 1", len(collection.Features))
+	}
+	point := collection.Features[0]
+	if point.Geometry == nil || point.Geometry.Coordinates != [2]float64{2.33, 48.86} {
+		t.Errorf("geometry = %+v, want [lon, lat]", point.Geometry)
+	}
+	if point.Properties["name"] != "Louvre Museum" {
+		t.Errorf("properties = %v, want the museum's fields", point.Properties)
+	}
+	if len(collection.Query) == 0 {
+		t.Error("no query echo on the collection")
+	}
+}
+
+func TestGeoJSON_UnlocatableSearchHitsHaveNullGeometry(t *testing.T) {
+	c := &fakeCatalogue{search: []postgres.Hit{
+		{ID: 1, Museum: models.Museum{Name: "Placed", Latitude: 57.7, Longitude: 11.97}},
+		{ID: 2, Museum: models.Museum{Name: "Nowhere"}},
+	}}
+
+	rec := get(t, c, "/v1/search?q=museum&format=geojson")
+	collection := decodeCollection(t, rec.Body.Bytes())
+	if len(collection.Features) != 2 {
+		t.Fatalf("features = %d, want both hits kept", len(collection.Features))
+	}
+	if collection.Features[0].Geometry == nil {
+		t.Error("a placed museum lost its geometry")
+	}
+	if collection.Features[1].Geometry != nil {
+		t.Errorf("an unlocatable museum was given a position: %+v", collection.Features[1].Geometry)
+	}
+}
+
+func TestGeoJSON_ExhibitionsAndPoints(t *testing.T) {
+	c := &fakeCatalogue{
+		nearby: []postgres.Hit{{ID: 9, Museum: models.Museum{Name: "Orsay", Latitude: 48.86, Longitude: 2.32}}},
+		exhibitions: []postgres.ExhibitionHit{{Exhibition: exhibitions.Exhibition{
+			Title: "Monet", URL: "https://example.org/monet", Latitude: 48.86, Longitude: 2.32,
+		}}},
+	}
+
+	exhibits := decodeCollection(t, get(t, c, "/v1/exhibitions?lat=48.86&lon=2.32&format=geojson").Body.Bytes())
+	if len(exhibits.Features) != 1 || exhibits.Features[0].Properties["title"] != "Monet" {
+		t.Errorf("exhibitions = %+v", exhibits.Features)
+	}
+
+	points := decodeCollection(t, get(t, c, "/v1/points?format=geojson").Body.Bytes())
+	if len(points.Features) != 1 || points.Features[0].Geometry.Coordinates != [2]float64{2.32, 48.86} {
+		t.Errorf("points = %+v", points.Features)
+	}
+}
+
+func TestGeoJSON_HasItsOwnETag(t *testing.T) {
+	routes := NewServer(cachingCatalogue()).Routes()
+
+	plain := serve(routes, "/v1/museums?lat=51.5&lon=-0.12", nil)
+	geo := serve(routes, "/v1/museums?lat=51.5&lon=-0.12", map[string]string{"Accept": geoJSONType})
+	if plain.Header().Get("ETag") == geo.Header().Get("ETag") {
+		t.Errorf("both representations tagged %s; a cache would serve one for the other", geo.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,673 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"museum/pkg/graphql"
+)
+
+const (
+	// maxGraphQLBody bounds a request body. A real query is a few hundred
+	// bytes; a hand-written one with every field spelled out is a few
+	// kilobytes.
+	maxGraphQLBody = 64 << 10
+
+	// maxQueryCost bounds how many records one query may ask for, counted
+	// before anything runs: every list contributes its limit, multiplied by the
+	// size of the lists it sits inside. A hundred museums with ten exhibitions
+	// each costs 1,100. The same query over 500 museums with their fifty
+	// nearest neighbours would cost 25,500 — the kind of request that has to
+	// be refused up front, because once it is running the only bound left is
+	// the deadline.
+	maxQueryCost = 10_000
+
+	// maxQueryDepth bounds how deeply selections may nest. museum → nearby →
+	// exhibitions → museum is four; nothing useful goes past eight.
+	maxQueryDepth = 8
+
+	// defaultNestedLimit is the list size for a field beneath another, when a
+	// query does not give one. Small, because it multiplies.
+	defaultNestedLimit = 10
+)
+
+// graphQLRequest is the body of POST /v1/graphql.
+type graphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+type graphQLError struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+type graphQLResponse struct {
+	Data   any            `json:"data,omitempty"`
+	Errors []graphQLError `json:"errors,omitempty"`
+}
+
+// handleGraphQL answers a GraphQL query over the catalogue.
+//
+// It exists for clients that want a museum, its address, what is on there and
+// what is nearby in one round trip instead of four. Everything it reads comes
+// through the same Catalogue methods the REST handlers use, under the same
+// caps: a limit is clamped to maxLimit, a radius past maxRadiusKm or an offset
+// past maxOffset is refused, and a query whose total size would exceed
+// maxQueryCost is refused before it runs. It is read-only; a mutation is an
+// error.
+//
+// A request that cannot run at all — malformed, invalid against the schema,
+// too expensive — answers 400 with errors and no data. One that ran answers
+// 200, with errors alongside whatever data could be read.
+func (s *Server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var request graphQLRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGraphQLBody))
+	decoder.UseNumber()
+	if err := decoder.Decode(&request); err != nil {
+		writeGraphQLError(w, http.StatusBadRequest, fmt.Errorf("body must be a JSON object with a query: %w", err))
+		return
+	}
+	if strings.TrimSpace(request.Query) == "" {
+		writeGraphQLError(w, http.StatusBadRequest, errors.New("query is required"))
+		return
+	}
+
+	doc, err := graphql.Parse(request.Query)
+	if err != nil {
+		writeGraphQLError(w, http.StatusBadRequest, err)
+		return
+	}
+	op, err := doc.Operation(request.OperationName)
+	if err != nil {
+		writeGraphQLError(w, http.StatusBadRequest, err)
+		return
+	}
+	if op.Kind != "query" {
+		writeGraphQLError(w, http.StatusBadRequest,
+			fmt.Errorf("%s is not supported; the catalogue is read-only", op.Kind))
+		return
+	}
+
+	variables, err := coerceVariables(op.Variables, request.Variables)
+	if err != nil {
+		writeGraphQLError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	exec := &executor{server: s, r: r, variables: variables}
+	plan, cost, err := exec.plan(queryType, op.Selections, 1, 1)
+	if err != nil {
+		writeGraphQLError(w, http.StatusBadRequest, err)
+		return
+	}
+	if cost > maxQueryCost {
+		writeGraphQLError(w, http.StatusBadRequest, fmt.Errorf(
+			"query could return %d records; the limit is %d — lower a limit or select fewer lists", cost, maxQueryCost))
+		return
+	}
+
+	data := exec.run(queryType, plan, []any{rootValue{}}, nil)
+	writeJSON(w, http.StatusOK, graphQLResponse{Data: data[0], Errors: exec.errors})
+}
+
+// handleGraphQLSchema prints the schema, so the endpoint documents itself.
+func (s *Server) handleGraphQLSchema(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(schemaSDL()))
+}
+
+func writeGraphQLError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, graphQLResponse{Errors: []graphQLError{{Message: err.Error()}}})
+}
+
+// gqlType is an object type in the schema.
+type gqlType struct {
+	name        string
+	description string
+	fields      map[string]*gqlField
+	// order is the order the fields are printed in.
+	order []string
+}
+
+// gqlField is one field of an object type.
+//
+// Every resolver is a batch resolver: it is handed every parent the field was
+// selected on at once and returns one value per parent. That is what keeps a
+// list of a hundred museums each asking for its exhibitions from becoming a
+// hundred queries — the resolver sees all hundred and decides how few queries
+// will answer them.
+type gqlField struct {
+	description string
+	// typ is the field's type as the schema prints it, e.g. "[Museum!]!".
+	typ string
+	// object is the field's object type, or nil for a scalar.
+	object *gqlType
+	list   bool
+	args   []gqlArg
+	// defaultLimit is the list size assumed when limit is not given.
+	defaultLimit int
+	resolve      func(e *executor, parents []any, args map[string]any) ([]any, error)
+}
+
+type gqlArg struct {
+	name string
+	// typ is a scalar name, with "!" when required.
+	typ string
+	def any
+}
+
+func (a gqlArg) required() bool { return strings.HasSuffix(a.typ, "!") }
+func (a gqlArg) scalar() string { return strings.TrimSuffix(a.typ, "!") }
+
+// rootValue is the parent of the top-level fields.
+type rootValue struct{}
+
+// step is one validated field, ready to run.
+type step struct {
+	key      string
+	name     string
+	field    *gqlField
+	args     map[string]any
+	children []*step
+}
+
+// executor runs one query.
+type executor struct {
+	server    *Server
+	r         *http.Request
+	variables map[string]any
+	errors    []graphQLError
+}
+
+// plan validates a selection against its type, resolves its arguments, and
+// totals what it could return. multiplicity is how many parents the selection
+// will run for, at most.
+func (e *executor) plan(t *gqlType, fields []*graphql.Field, multiplicity, depth int) ([]*step, int, error) {
+	if depth > maxQueryDepth {
+		return nil, 0, fmt.Errorf("query nests more than %d levels deep", maxQueryDepth)
+	}
+	fields, err := e.merge(t, fields)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var (
+		steps []*step
+		cost  int
+	)
+	for _, f := range fields {
+		if f.Name == "__typename" {
+			steps = append(steps, &step{key: f.ResponseKey(), name: f.Name})
+			continue
+		}
+
+		def, ok := t.fields[f.Name]
+		if !ok {
+			return nil, 0, fmt.Errorf("%s has no field %q", t.name, f.Name)
+		}
+		if def.object == nil && len(f.Selections) > 0 {
+			return nil, 0, fmt.Errorf("%s.%s is a %s and has no fields to select", t.name, f.Name, def.typ)
+		}
+		if def.object != nil && len(f.Selections) == 0 {
+			return nil, 0, fmt.Errorf("%s.%s is a %s; select the fields you want from it", t.name, f.Name, def.typ)
+		}
+		args, err := e.arguments(t, f, def)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		st := &step{key: f.ResponseKey(), name: f.Name, field: def, args: args}
+		steps = append(steps, st)
+
+		if def.object != nil {
+			size := multiplicity
+			if def.list {
+				size *= listSize(def, args)
+			}
+			children, childCost, err := e.plan(def.object, f.Selections, size, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			st.children = children
+			cost += size + childCost
+		}
+	}
+	return steps, cost, nil
+}
+
+// merge applies @include and @skip, checks fragment type conditions, and
+// folds fields selected twice under one key into one — typically an id asked
+// for directly and again by a fragment.
+func (e *executor) merge(t *gqlType, fields []*graphql.Field) ([]*graphql.Field, error) {
+	var (
+		out   []*graphql.Field
+		byKey = map[string]*graphql.Field{}
+	)
+	for _, f := range fields {
+		include, err := e.included(f)
+		if err != nil {
+			return nil, err
+		}
+		if !include {
+			continue
+		}
+		if f.On != "" && f.On != t.name {
+			return nil, fmt.Errorf("a fragment on %s cannot be spread inside %s", f.On, t.name)
+		}
+
+		key := f.ResponseKey()
+		existing, seen := byKey[key]
+		if !seen {
+			copied := *f
+			byKey[key] = &copied
+			out = append(out, &copied)
+			continue
+		}
+		if existing.Name != f.Name {
+			return nil, fmt.Errorf("%q is selected as both %s and %s; alias one of them", key, existing.Name, f.Name)
+		}
+		existing.Selections = append(append([]*graphql.Field(nil), existing.Selections...), f.Selections...)
+	}
+	return out, nil
+}
+
+// included evaluates a field's @include and @skip directives.
+func (e *executor) included(f *graphql.Field) (bool, error) {
+	include := true
+	for _, d := range f.Directives {
+		if d.Name != "include" && d.Name != "skip" {
+			return false, fmt.Errorf("directive @%s is not supported", d.Name)
+		}
+		if len(d.Arguments) != 1 || d.Arguments[0].Name != "if" {
+			return false, fmt.Errorf("@%s takes one argument, if", d.Name)
+		}
+		value, _, err := e.value(d.Arguments[0].Value, "Boolean")
+		if err != nil {
+			return false, fmt.Errorf("@%s: %w", d.Name, err)
+		}
+		flag, ok := value.(bool)
+		if !ok {
+			return false, fmt.Errorf("@%s needs a Boolean", d.Name)
+		}
+		if d.Name == "include" {
+			include = include && flag
+		} else {
+			include = include && !flag
+		}
+	}
+	return include, nil
+}
+
+// arguments resolves a field's arguments against its definition: variables
+// substituted, types checked, defaults filled in, and the same bounds applied
+// as the REST parameters of the same names.
+func (e *executor) arguments(t *gqlType, f *graphql.Field, def *gqlField) (map[string]any, error) {
+	given := map[string]graphql.Value{}
+	for _, arg := range f.Arguments {
+		given[arg.Name] = arg.Value
+	}
+
+	args := map[string]any{}
+	for _, spec := range def.args {
+		raw, ok := given[spec.name]
+		delete(given, spec.name)
+
+		var (
+			value   any
+			present bool
+		)
+		if ok {
+			var err error
+			if value, present, err = e.value(raw, spec.scalar()); err != nil {
+				return nil, fmt.Errorf("%s.%s(%s:): %w", t.name, f.Name, spec.name, err)
+			}
+		}
+		if !present {
+			if spec.required() {
+				return nil, fmt.Errorf("%s.%s needs %s", t.name, f.Name, spec.name)
+			}
+			if spec.def == nil {
+				continue
+			}
+			value = spec.def
+		}
+
+		checked, err := checkBounds(spec.name, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", t.name, f.Name, err)
+		}
+		args[spec.name] = checked
+	}
+
+	for name := range given {
+		return nil, fmt.Errorf("%s.%s has no argument %q", t.name, f.Name, name)
+	}
+	return args, nil
+}
+
+// checkBounds applies the REST API's limits to the argument of the same name,
+// so the two interfaces cannot disagree about what is too much.
+func checkBounds(name string, value any) (any, error) {
+	switch name {
+	case "limit":
+		limit := value.(int64)
+		if limit < 1 {
+			return nil, errors.New("limit must be a positive whole number")
+		}
+		return min(limit, maxLimit), nil
+	case "offset":
+		offset := value.(int64)
+		if offset < 0 {
+			return nil, errors.New("offset must be a whole number of zero or more")
+		}
+		if offset > maxOffset {
+			return nil, fmt.Errorf("offset must be %d or less", maxOffset)
+		}
+	case "radiusKm":
+		radius := value.(float64)
+		if radius <= 0 {
+			return nil, errors.New("radiusKm must be greater than zero")
+		}
+		if radius > maxRadiusKm {
+			return nil, fmt.Errorf("radiusKm must be %d or less", maxRadiusKm)
+		}
+	case "lat":
+		if lat := value.(float64); lat < -90 || lat > 90 {
+			return nil, errors.New("lat must be between -90 and 90")
+		}
+	case "lon":
+		if lon := value.(float64); lon < -180 || lon > 180 {
+			return nil, errors.New("lon must be between -180 and 180")
+		}
+	case "q":
+		if len([]rune(value.(string))) > maxQueryRunes {
+			return nil, fmt.Errorf("q must be %d characters or fewer", maxQueryRunes)
+		}
+	case "place", "name":
+		if len(value.(string)) > maxPlaceNameChars {
+			return nil, fmt.Errorf("%s must be %d characters or fewer", name, maxPlaceNameChars)
+		}
+	}
+	return value, nil
+}
+
+// value resolves a literal or variable to a Go value of the given scalar type.
+// present is false for a variable that was not supplied, and for null.
+func (e *executor) value(raw graphql.Value, scalar string) (value any, present bool, err error) {
+	if name, ok := raw.(graphql.Variable); ok {
+		value, ok := e.variables[string(name)]
+		if !ok {
+			return nil, false, nil
+		}
+		raw = value
+	}
+	if raw == nil {
+		return nil, false, nil
+	}
+	value, err = coerceScalar(scalar, raw)
+	return value, err == nil, err
+}
+
+// coerceVariables checks the supplied variables against their declarations.
+func coerceVariables(defs []graphql.VariableDefinition, supplied map[string]any) (map[string]any, error) {
+	values := map[string]any{}
+	for _, def := range defs {
+		scalar := strings.TrimSuffix(def.Type, "!")
+		if !isScalar(scalar) {
+			return nil, fmt.Errorf("$%s: variables of type %s are not supported", def.Name, def.Type)
+		}
+
+		raw, ok := supplied[def.Name]
+		if !ok || raw == nil {
+			if def.HasDefault && def.Default != nil {
+				raw, ok = def.Default, true
+			}
+		}
+		if !ok || raw == nil {
+			if def.NonNull {
+				return nil, fmt.Errorf("$%s is required", def.Name)
+			}
+			continue
+		}
+
+		value, err := coerceScalar(scalar, raw)
+		if err != nil {
+			return nil, fmt.Errorf("$%s: %w", def.Name, err)
+		}
+		values[def.Name] = value
+	}
+	return values, nil
+}
+
+func isScalar(name string) bool {
+	switch name {
+	case "Int", "Float", "String", "Boolean", "ID":
+		return true
+	}
+	return false
+}
+
+// coerceScalar converts a literal or a decoded JSON value to a scalar: int64
+// for Int, float64 for Float, string for String and ID, bool for Boolean.
+func coerceScalar(scalar string, raw any) (any, error) {
+	switch scalar {
+	case "Int":
+		switch v := raw.(type) {
+		case int64:
+			return v, nil
+		case json.Number:
+			if n, err := v.Int64(); err == nil {
+				return n, nil
+			}
+		}
+	case "Float":
+		switch v := raw.(type) {
+		case float64:
+			return v, nil
+		case int64:
+			return float64(v), nil
+		case json.Number:
+			if f, err := v.Float64(); err == nil {
+				return f, nil
+			}
+		}
+	case "String":
+		if v, ok := raw.(string); ok {
+			return v, nil
+		}
+	case "ID":
+		switch v := raw.(type) {
+		case string:
+			return v, nil
+		case int64:
+			return fmt.Sprint(v), nil
+		case json.Number:
+			if _, err := v.Int64(); err == nil {
+				return v.String(), nil
+			}
+		}
+	case "Boolean":
+		if v, ok := raw.(bool); ok {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("expected %s", scalar)
+}
+
+// listSize is the most items a list field can return.
+func listSize(def *gqlField, args map[string]any) int {
+	if limit, ok := args["limit"].(int64); ok {
+		return int(limit)
+	}
+	if def.defaultLimit > 0 {
+		return def.defaultLimit
+	}
+	return 1
+}
+
+// run executes a planned selection for every parent at once, returning one
+// object per parent.
+//
+// Each field's resolver runs once for the whole set of parents, and the
+// objects it returns — across all of them — become the parents of the next
+// level down. A query's database work is therefore proportional to its depth
+// and to how well each resolver batches, not to how many results it has.
+func (e *executor) run(t *gqlType, steps []*step, parents []any, path []any) []any {
+	objects := make([]*orderedObject, len(parents))
+	for i := range objects {
+		objects[i] = &orderedObject{}
+	}
+
+	for _, st := range steps {
+		if st.name == "__typename" {
+			for _, object := range objects {
+				object.set(st.key, t.name)
+			}
+			continue
+		}
+
+		fieldPath := append(append([]any(nil), path...), st.key)
+		values, err := st.field.resolve(e, parents, st.args)
+		if err != nil {
+			message := publicError(err)
+			if message == "internal error" {
+				log.Printf("api: graphql %v: %v", fieldPath, err)
+			}
+			e.errors = append(e.errors, graphQLError{Message: message, Path: fieldPath})
+			values = make([]any, len(parents))
+		}
+
+		if st.field.object != nil {
+			values = e.descend(st, values, fieldPath)
+		}
+		for i, object := range objects {
+			object.set(st.key, values[i])
+		}
+	}
+
+	out := make([]any, len(objects))
+	for i, object := range objects {
+		out[i] = object
+	}
+	return out
+}
+
+// descend runs a field's selection over everything its resolver returned, and
+// puts the results back where they came from.
+func (e *executor) descend(st *step, values []any, path []any) []any {
+	var children []any
+	for _, value := range values {
+		if st.field.list {
+			items, _ := value.([]any)
+			children = append(children, items...)
+		} else if value != nil {
+			children = append(children, value)
+		}
+	}
+	resolved := e.run(st.field.object, st.children, children, path)
+
+	out := make([]any, len(values))
+	next := 0
+	for i, value := range values {
+		if st.field.list {
+			items, _ := value.([]any)
+			list := make([]any, len(items))
+			for j := range items {
+				list[j] = resolved[next]
+				next++
+			}
+			out[i] = list
+		} else if value != nil {
+			out[i] = resolved[next]
+			next++
+		}
+	}
+	return out
+}
+
+// publicError is what a resolver error may tell the caller. A fault in the
+// request — an unknown place — is theirs to see; anything from the database is
+// logged rather than repeated, for the reason writeServerError gives.
+func publicError(err error) string {
+	var public *gqlPublicError
+	if errors.As(err, &public) {
+		return public.Error()
+	}
+	return "internal error"
+}
+
+// gqlPublicError marks an error safe to show the caller.
+type gqlPublicError struct{ err error }
+
+func (p *gqlPublicError) Error() string { return p.err.Error() }
+func (p *gqlPublicError) Unwrap() error { return p.err }
+
+// orderedObject is a response object whose keys keep the order they were
+// selected in, as GraphQL requires and a Go map would not.
+type orderedObject struct {
+	keys   []string
+	values []any
+}
+
+func (o *orderedObject) set(key string, value any) {
+	o.keys = append(o.keys, key)
+	o.values = append(o.values, value)
+}
+
+func (o *orderedObject) MarshalJSON() ([]byte, error) {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return []byte(b.String()), nil
+}
+
+// schemaSDL prints the schema in GraphQL's schema language.
+func schemaSDL() string {
+	var b strings.Builder
+	for i, t := range []*gqlType{queryType, museumType, addressType, exhibitionType, placeType} {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		fmt.Fprintf(&b, "\"\"\"%s\"\"\"\ntype %s {\n", t.description, t.name)
+		for _, name := range t.order {
+			f := t.fields[name]
+			fmt.Fprintf(&b, "  \"%s\"\n  %s", f.description, name)
+			if len(f.args) > 0 {
+				var args []string
+				for _, a := range f.args {
+					arg := a.name + ": " + a.typ
+					if a.def != nil {
+						arg += fmt.Sprintf(" = %v", a.def)
+					}
+					args = append(args, arg)
+				}
+				fmt.Fprintf(&b, "(%s)", strings.Join(args, ", "))
+			}
+			fmt.Fprintf(&b, ": %s\n", f.typ)
+		}
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,681 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"museum/internal/models"
+	"museum/internal/postgres"
+	"museum/pkg/geo"
+)
+
+// The schema. Types are declared here and their fields wired up in init,
+// because the graph is cyclic — a museum has exhibitions, an exhibition has a
+// museum — and Go will not let package-level initialisers refer to each other
+// in a loop.
+var (
+	queryType      = &gqlType{name: "Query", description: "The catalogue, read-only."}
+	museumType     = &gqlType{name: "Museum", description: "A museum in the catalogue."}
+	addressType    = &gqlType{name: "Address", description: "A museum's postal address."}
+	exhibitionType = &gqlType{name: "Exhibition", description: "An exhibition listing, as read from a museum's website."}
+	placeType      = &gqlType{name: "Place", description: "A named place, resolved to a centre and an extent."}
+)
+
+// museumAddress is the parent of Address's fields.
+type museumAddress struct {
+	address  models.Address
+	locality string
+	country  string
+}
+
+func init() {
+	locatedArgs := func() []gqlArg {
+		return []gqlArg{
+			{name: "lat", typ: "Float"}, {name: "lon", typ: "Float"}, {name: "place", typ: "String"},
+			{name: "radiusKm", typ: "Float"},
+			{name: "limit", typ: "Int", def: int64(defaultLimit)}, {name: "offset", typ: "Int", def: int64(0)},
+		}
+	}
+
+	define(queryType,
+		"museum", &gqlField{
+			description: "A museum by its id or its Wikidata id; null when there is none.",
+			typ:         "Museum", object: museumType,
+			args:    []gqlArg{{name: "id", typ: "ID!"}},
+			resolve: resolveMuseum,
+		},
+		"museums", &gqlField{
+			description: "Museums around a point or a named place, nearest first.",
+			typ:         "[Museum!]!", object: museumType, list: true, defaultLimit: defaultLimit,
+			args:    append(locatedArgs(), gqlArg{name: "verified", typ: "Boolean", def: false}),
+			resolve: resolveMuseums,
+		},
+		"search", &gqlField{
+			description: "Museums by name, best match first. The only way to reach a museum with no position.",
+			typ:         "[Museum!]!", object: museumType, list: true, defaultLimit: defaultLimit,
+			args: []gqlArg{
+				{name: "q", typ: "String!"},
+				{name: "limit", typ: "Int", def: int64(defaultLimit)}, {name: "offset", typ: "Int", def: int64(0)},
+			},
+			resolve: resolveSearch,
+		},
+		"exhibitions", &gqlField{
+			description: "Exhibitions around a point or a named place, or by title anywhere.",
+			typ:         "[Exhibition!]!", object: exhibitionType, list: true, defaultLimit: defaultLimit,
+			args: append(locatedArgs(),
+				gqlArg{name: "q", typ: "String"}, gqlArg{name: "upcoming", typ: "Boolean"}),
+			resolve: resolveExhibitions,
+		},
+		"place", &gqlField{
+			description: "A place by name; null when no geocoder knows it.",
+			typ:         "Place", object: placeType,
+			args:    []gqlArg{{name: "name", typ: "String!"}},
+			resolve: resolvePlace,
+		},
+	)
+
+	define(museumType,
+		"id", scalar("ID!", "Stable across requests and re-crawls.", func(h postgres.Hit) any { return fmt.Sprint(h.ID) }),
+		"name", scalar("String!", "", func(h postgres.Hit) any { return h.Museum.Name }),
+		"country", scalar("String", "", func(h postgres.Hit) any { return nonEmpty(h.Museum.Country) }),
+		"locality", scalar("String", "", func(h postgres.Hit) any { return nonEmpty(h.Museum.Locality) }),
+		"description", scalar("String", "", func(h postgres.Hit) any { return nonEmpty(h.Museum.Description) }),
+		"latitude", scalar("Float", "Null for a museum with no position.", func(h postgres.Hit) any {
+			return located(h.Museum, h.Museum.Latitude)
+		}),
+		"longitude", scalar("Float", "Null for a museum with no position.", func(h postgres.Hit) any {
+			return located(h.Museum, h.Museum.Longitude)
+		}),
+		"approximateLocation", scalar("Boolean!", "The position is the museum's town, not the museum.",
+			func(h postgres.Hit) any { return h.ApproximateLocation }),
+		"verified", scalar("Boolean!", "Backed by a Wikipedia article.", func(h postgres.Hit) any { return h.Museum.Verified }),
+		"website", scalar("String", "", func(h postgres.Hit) any { return nonEmpty(h.Museum.Website) }),
+		"wikipediaUrl", scalar("String", "", func(h postgres.Hit) any { return nonEmpty(h.Museum.WikipediaURL) }),
+		"wikidataId", scalar("String", "", func(h postgres.Hit) any { return nonEmpty(h.Museum.WikidataID) }),
+		"classes", scalar("[String!]!", "What kind of thing the museum is, in its source's words.",
+			func(h postgres.Hit) any { return nonNil(h.Museum.Classes) }),
+		"sources", scalar("[String!]!", "Every catalogue the record was seen in.",
+			func(h postgres.Hit) any { return nonNil(h.Museum.Sources) }),
+		"aliases", scalar("[String!]!", "Other names a source recorded.",
+			func(h postgres.Hit) any { return nonNil(h.Museum.AlsoKnownAs) }),
+		"distanceKm", scalar("Float", "Distance from the point searched around, where there was one.",
+			func(h postgres.Hit) any {
+				if h.DistanceKm == 0 {
+					return nil
+				}
+				return round2(h.DistanceKm)
+			}),
+		"address", &gqlField{
+			description: "Null when the geocoder returned none.",
+			typ:         "Address", object: addressType,
+			resolve: each(func(h postgres.Hit) any {
+				if h.Museum.Address.IsZero() {
+					return nil
+				}
+				return museumAddress{address: h.Museum.Address, locality: h.Museum.Locality, country: h.Museum.Country}
+			}),
+		},
+		"exhibitions", &gqlField{
+			description: "What is on at the museum, soonest to close first.",
+			typ:         "[Exhibition!]!", object: exhibitionType, list: true, defaultLimit: defaultNestedLimit,
+			args: []gqlArg{
+				{name: "upcoming", typ: "Boolean", def: true},
+				{name: "limit", typ: "Int", def: int64(defaultNestedLimit)},
+			},
+			resolve: resolveMuseumExhibitions,
+		},
+		"nearby", &gqlField{
+			description: "Other museums close by, nearest first.",
+			typ:         "[Museum!]!", object: museumType, list: true, defaultLimit: defaultNestedLimit,
+			args: []gqlArg{
+				{name: "radiusKm", typ: "Float", def: 1.0},
+				{name: "limit", typ: "Int", def: int64(defaultNestedLimit)},
+				{name: "verified", typ: "Boolean", def: false},
+			},
+			resolve: resolveNearby,
+		},
+	)
+
+	address := func(get func(a museumAddress) string) func(museumAddress) any {
+		return func(a museumAddress) any { return nonEmpty(get(a)) }
+	}
+	define(addressType,
+		"street", scalar("String", "", address(func(a museumAddress) string { return a.address.Street() })),
+		"postcode", scalar("String", "", address(func(a museumAddress) string { return a.address.Postcode })),
+		"locality", scalar("String", "", address(func(a museumAddress) string { return a.locality })),
+		"country", scalar("String", "", address(func(a museumAddress) string { return a.country })),
+	)
+
+	define(exhibitionType,
+		"title", scalar("String!", "", func(e postgres.ExhibitionHit) any { return e.Title }),
+		"url", scalar("String!", "", func(e postgres.ExhibitionHit) any { return e.URL }),
+		"museumName", scalar("String", "The venue as the listing named it.",
+			func(e postgres.ExhibitionHit) any { return nonEmpty(e.Museum) }),
+		"museumWikidataId", scalar("String", "Which museum, as opposed to what it was called.",
+			func(e postgres.ExhibitionHit) any { return nonEmpty(e.MuseumWikidataID) }),
+		"start", scalar("String", "ISO 8601; null when the listing gave none.", func(e postgres.ExhibitionHit) any {
+			if e.Start == nil {
+				return nil
+			}
+			return e.Start.Format("2006-01-02")
+		}),
+		"end", scalar("String", "ISO 8601; null when the listing gave none.", func(e postgres.ExhibitionHit) any {
+			if e.End == nil {
+				return nil
+			}
+			return e.End.Format("2006-01-02")
+		}),
+		"running", scalar("Boolean!", "", func(e postgres.ExhibitionHit) any { return e.Running }),
+		"upcoming", scalar("Boolean!", "", func(e postgres.ExhibitionHit) any { return e.Upcoming }),
+		"permanent", scalar("Boolean!", "Always on, which is why it has no dates.",
+			func(e postgres.ExhibitionHit) any { return e.Permanent }),
+		"latitude", scalar("Float", "", func(e postgres.ExhibitionHit) any { return e.Latitude }),
+		"longitude", scalar("Float", "", func(e postgres.ExhibitionHit) any { return e.Longitude }),
+		"scrapedAt", scalar("String!", "When the listing was read, RFC 3339.",
+			func(e postgres.ExhibitionHit) any { return e.ScrapedAt.UTC().Format(time.RFC3339) }),
+		"distanceKm", scalar("Float", "Distance from the point searched around, where there was one.",
+			func(e postgres.ExhibitionHit) any {
+				if e.DistanceKm == 0 {
+					return nil
+				}
+				return round2(e.DistanceKm)
+			}),
+		"museum", &gqlField{
+			description: "The museum the exhibition is at; null when the listing names none the catalogue holds.",
+			typ:         "Museum", object: museumType,
+			resolve: resolveExhibitionMuseum,
+		},
+	)
+
+	define(placeType,
+		"name", scalar("String!", "The name the geocoder gave back, which says which Springfield it was.",
+			func(p postgres.Place) any { return p.DisplayName }),
+		"latitude", scalar("Float!", "", func(p postgres.Place) any { return p.Latitude }),
+		"longitude", scalar("Float!", "", func(p postgres.Place) any { return p.Longitude }),
+		"radiusKm", scalar("Float!", "The place's extent, and the default radius for its lists.",
+			func(p postgres.Place) any { return p.RadiusKm }),
+		"museums", &gqlField{
+			description: "Museums in the place, nearest its centre first.",
+			typ:         "[Museum!]!", object: museumType, list: true, defaultLimit: defaultNestedLimit,
+			args: []gqlArg{
+				{name: "radiusKm", typ: "Float"},
+				{name: "limit", typ: "Int", def: int64(defaultNestedLimit)},
+				{name: "offset", typ: "Int", def: int64(0)},
+				{name: "verified", typ: "Boolean", def: false},
+			},
+			resolve: resolvePlaceMuseums,
+		},
+		"exhibitions", &gqlField{
+			description: "Exhibitions in the place, soonest to close first.",
+			typ:         "[Exhibition!]!", object: exhibitionType, list: true, defaultLimit: defaultNestedLimit,
+			args: []gqlArg{
+				{name: "radiusKm", typ: "Float"},
+				{name: "upcoming", typ: "Boolean", def: false},
+				{name: "limit", typ: "Int", def: int64(defaultNestedLimit)},
+			},
+			resolve: resolvePlaceExhibitions,
+		},
+	)
+}
+
+// define adds fields to a type, in the order given.
+func define(t *gqlType, fields ...any) {
+	t.fields = map[string]*gqlField{}
+	for i := 0; i < len(fields); i += 2 {
+		name := fields[i].(string)
+		t.fields[name] = fields[i+1].(*gqlField)
+		t.order = append(t.order, name)
+	}
+}
+
+// scalar is a field read straight off its parent.
+func scalar[T any](typ, description string, get func(T) any) *gqlField {
+	return &gqlField{typ: typ, description: description, resolve: each(get)}
+}
+
+// each lifts a function of one parent into a batch resolver.
+func each[T any](get func(T) any) func(*executor, []any, map[string]any) ([]any, error) {
+	return func(_ *executor, parents []any, _ map[string]any) ([]any, error) {
+		out := make([]any, len(parents))
+		for i, parent := range parents {
+			out[i] = get(parent.(T))
+		}
+		return out, nil
+	}
+}
+
+func nonEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+func located(m models.Museum, coordinate float64) any {
+	if !m.HasCoordinates() {
+		return nil
+	}
+	return coordinate
+}
+
+// public marks an error as the caller's to see.
+func public(format string, args ...any) error {
+	return &gqlPublicError{err: fmt.Errorf(format, args...)}
+}
+
+// intArg and the others read an argument arguments has already checked.
+func intArg(args map[string]any, name string) int {
+	value, _ := args[name].(int64)
+	return int(value)
+}
+
+func floatArg(args map[string]any, name string) (float64, bool) {
+	value, ok := args[name].(float64)
+	return value, ok
+}
+
+func stringArg(args map[string]any, name string) string {
+	value, _ := args[name].(string)
+	return strings.TrimSpace(value)
+}
+
+func boolArg(args map[string]any, name string) (bool, bool) {
+	value, ok := args[name].(bool)
+	return value, ok
+}
+
+// hitsOf converts a page to resolver values.
+func hitsOf(hits []postgres.Hit) []any {
+	out := make([]any, len(hits))
+	for i, hit := range hits {
+		out[i] = hit
+	}
+	return out
+}
+
+func showsOf(shows []postgres.ExhibitionHit) []any {
+	out := make([]any, len(shows))
+	for i, show := range shows {
+		out[i] = show
+	}
+	return out
+}
+
+func resolveMuseum(e *executor, parents []any, args map[string]any) ([]any, error) {
+	hit, err := e.server.catalogue.MuseumByID(e.r.Context(), stringArg(args, "id"))
+	if errors.Is(err, postgres.ErrNotFound) {
+		return []any{nil}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []any{hit}, nil
+}
+
+// centre resolves where a located list is about: coordinates if given, else a
+// place, with the radius defaulting as the REST API's does.
+func (e *executor) centre(args map[string]any) (lat, lon, radiusKm float64, err error) {
+	lat, hasLat := floatArg(args, "lat")
+	lon, hasLon := floatArg(args, "lon")
+	radiusKm, hasRadius := floatArg(args, "radiusKm")
+
+	switch {
+	case hasLat && hasLon:
+		if !hasRadius {
+			radiusKm = defaultRadiusKm
+		}
+		return lat, lon, radiusKm, nil
+	case hasLat || hasLon:
+		return 0, 0, 0, public("lat and lon must be given together")
+	case stringArg(args, "place") != "":
+		place, err := e.place(stringArg(args, "place"))
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		if !hasRadius {
+			radiusKm = place.RadiusKm
+		}
+		return place.Latitude, place.Longitude, radiusKm, nil
+	default:
+		return 0, 0, 0, public("give lat and lon, or a place")
+	}
+}
+
+// place resolves a name, with an unknown one reported to the caller rather
+// than logged.
+func (e *executor) place(name string) (postgres.Place, error) {
+	if e.server.places == nil {
+		return postgres.Place{}, public("place lookup is not configured on this server; pass lat and lon")
+	}
+	place, err := e.server.places.Resolve(e.r.Context(), name)
+	if errors.Is(err, postgres.ErrPlaceUnknown) {
+		return postgres.Place{}, public("no place called %q is known", name)
+	}
+	return place, err
+}
+
+func resolveMuseums(e *executor, _ []any, args map[string]any) ([]any, error) {
+	lat, lon, radius, err := e.centre(args)
+	if err != nil {
+		return nil, err
+	}
+	verified, _ := boolArg(args, "verified")
+	page, err := e.server.catalogue.NearbyVerified(e.r.Context(), lat, lon, radius,
+		intArg(args, "limit"), intArg(args, "offset"), verified)
+	if err != nil {
+		return nil, err
+	}
+	return []any{hitsOf(page.Hits)}, nil
+}
+
+func resolveSearch(e *executor, _ []any, args map[string]any) ([]any, error) {
+	q := stringArg(args, "q")
+	if q == "" {
+		return nil, public("q must not be empty")
+	}
+	page, err := e.server.catalogue.Search(e.r.Context(), q, intArg(args, "limit"), intArg(args, "offset"))
+	if err != nil {
+		return nil, err
+	}
+	return []any{hitsOf(page.Hits)}, nil
+}
+
+// resolveExhibitions follows /v1/exhibitions: upcoming shows are left out
+// unless asked for, except in a search by title, and a title search may name
+// no place at all.
+func resolveExhibitions(e *executor, _ []any, args map[string]any) ([]any, error) {
+	text := stringArg(args, "q")
+	upcoming, given := boolArg(args, "upcoming")
+	if text != "" && !given {
+		upcoming = true
+	}
+
+	_, hasLat := floatArg(args, "lat")
+	_, hasLon := floatArg(args, "lon")
+	located := hasLat || hasLon || stringArg(args, "place") != ""
+
+	var (
+		lat, lon, radius float64
+		err              error
+	)
+	if located || text == "" {
+		if lat, lon, radius, err = e.centre(args); err != nil {
+			return nil, err
+		}
+	}
+
+	if text != "" {
+		hits, _, err := e.server.catalogue.SearchExhibitions(e.r.Context(), text, lat, lon, radius,
+			located, upcoming, intArg(args, "limit"), intArg(args, "offset"))
+		if err != nil {
+			return nil, err
+		}
+		return []any{showsOf(hits)}, nil
+	}
+
+	if intArg(args, "offset") != 0 {
+		return nil, public("offset applies only to a search by q")
+	}
+	hits, err := e.server.catalogue.ExhibitionsNearby(e.r.Context(), lat, lon, radius, upcoming, intArg(args, "limit"))
+	if err != nil {
+		return nil, err
+	}
+	return []any{showsOf(hits)}, nil
+}
+
+func resolvePlace(e *executor, _ []any, args map[string]any) ([]any, error) {
+	name := stringArg(args, "name")
+	if e.server.places == nil {
+		return nil, public("place lookup is not configured on this server")
+	}
+	place, err := e.server.places.Resolve(e.r.Context(), name)
+	if errors.Is(err, postgres.ErrPlaceUnknown) {
+		return []any{nil}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []any{place}, nil
+}
+
+func resolvePlaceMuseums(e *executor, parents []any, args map[string]any) ([]any, error) {
+	verified, _ := boolArg(args, "verified")
+	out := make([]any, len(parents))
+	for i, parent := range parents {
+		place := parent.(postgres.Place)
+		radius, ok := floatArg(args, "radiusKm")
+		if !ok {
+			radius = place.RadiusKm
+		}
+		page, err := e.server.catalogue.NearbyVerified(e.r.Context(), place.Latitude, place.Longitude,
+			radius, intArg(args, "limit"), intArg(args, "offset"), verified)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = hitsOf(page.Hits)
+	}
+	return out, nil
+}
+
+func resolvePlaceExhibitions(e *executor, parents []any, args map[string]any) ([]any, error) {
+	upcoming, _ := boolArg(args, "upcoming")
+	out := make([]any, len(parents))
+	for i, parent := range parents {
+		place := parent.(postgres.Place)
+		radius, ok := floatArg(args, "radiusKm")
+		if !ok {
+			radius = place.RadiusKm
+		}
+		hits, err := e.server.catalogue.ExhibitionsNearby(e.r.Context(), place.Latitude, place.Longitude,
+			radius, upcoming, intArg(args, "limit"))
+		if err != nil {
+			return nil, err
+		}
+		out[i] = showsOf(hits)
+	}
+	return out, nil
+}
+
+// resolveMuseumExhibitions finds the listings of every museum in the batch.
+//
+// Museums in one answer are usually near each other — they came from one
+// radius query — so a single query around all of them is tried first, and
+// split out by museum afterwards. It is only trusted when it covers every
+// museum within the API's radius cap and came back short of maxLimit; a
+// batch spread wider than that, or dense enough to fill the limit, falls back
+// to one query per distinct position.
+func resolveMuseumExhibitions(e *executor, parents []any, args map[string]any) ([]any, error) {
+	upcoming, _ := boolArg(args, "upcoming")
+	limit := intArg(args, "limit")
+
+	var positions [][2]float64
+	for _, parent := range parents {
+		if m := parent.(postgres.Hit).Museum; m.HasCoordinates() {
+			positions = append(positions, [2]float64{m.Latitude, m.Longitude})
+		}
+	}
+
+	var shows []postgres.ExhibitionHit
+	batched := false
+	if lat, lon, spread, ok := enclosing(positions); ok && spread+museumPageRadiusKm <= maxRadiusKm {
+		hits, err := e.server.catalogue.ExhibitionsNearby(e.r.Context(), lat, lon,
+			spread+museumPageRadiusKm, upcoming, maxLimit)
+		if err != nil {
+			return nil, err
+		}
+		if len(hits) < maxLimit {
+			shows, batched = hits, true
+		}
+	}
+
+	byPosition := map[[2]float64][]postgres.ExhibitionHit{}
+	out := make([]any, len(parents))
+	for i, parent := range parents {
+		hit := parent.(postgres.Hit)
+		m := hit.Museum
+		if !m.HasCoordinates() {
+			out[i] = []any{}
+			continue
+		}
+
+		candidates := shows
+		if !batched {
+			position := [2]float64{m.Latitude, m.Longitude}
+			found, seen := byPosition[position]
+			if !seen {
+				var err error
+				found, err = e.server.catalogue.ExhibitionsNearby(e.r.Context(),
+					m.Latitude, m.Longitude, museumPageRadiusKm, upcoming, museumPageExhibitions)
+				if err != nil {
+					return nil, err
+				}
+				byPosition[position] = found
+			}
+			candidates = found
+		}
+
+		mine := []any{}
+		for _, show := range candidates {
+			if len(mine) == limit {
+				break
+			}
+			if geo.DistanceKm(m.Latitude, m.Longitude, show.Latitude, show.Longitude) > museumPageRadiusKm ||
+				!belongsTo(show, m) {
+				continue
+			}
+			show.DistanceKm = 0
+			mine = append(mine, show)
+		}
+		out[i] = mine
+	}
+	return out, nil
+}
+
+// resolveNearby lists the museums around each museum in the batch, once per
+// distinct position and argument set.
+func resolveNearby(e *executor, parents []any, args map[string]any) ([]any, error) {
+	radius, _ := floatArg(args, "radiusKm")
+	verified, _ := boolArg(args, "verified")
+	limit := intArg(args, "limit")
+
+	byPosition := map[[2]float64][]postgres.Hit{}
+	out := make([]any, len(parents))
+	for i, parent := range parents {
+		hit := parent.(postgres.Hit)
+		if !hit.Museum.HasCoordinates() {
+			out[i] = []any{}
+			continue
+		}
+
+		position := [2]float64{hit.Museum.Latitude, hit.Museum.Longitude}
+		found, seen := byPosition[position]
+		if !seen {
+			// One more than asked for, since the museum itself is in the answer.
+			page, err := e.server.catalogue.NearbyVerified(e.r.Context(), position[0], position[1],
+				radius, min(limit+1, maxLimit), 0, verified)
+			if err != nil {
+				return nil, err
+			}
+			found = page.Hits
+			byPosition[position] = found
+		}
+
+		others := []any{}
+		for _, other := range found {
+			if len(others) == limit {
+				break
+			}
+			if other.ID != hit.ID {
+				others = append(others, other)
+			}
+		}
+		out[i] = others
+	}
+	return out, nil
+}
+
+// resolveExhibitionMuseum finds the museum behind each listing, by Wikidata id.
+//
+// Listings in one answer are near each other, so the museums around them are
+// read in one query and matched; MuseumByID is the fallback, once per museum,
+// for whatever that misses. A listing with no position of its own is left to
+// the fallback rather than counted at 0,0, which would stretch the circle
+// across the globe and send every other museum to the fallback too.
+func resolveExhibitionMuseum(e *executor, parents []any, _ map[string]any) ([]any, error) {
+	var positions [][2]float64
+	wanted := map[string]bool{}
+	for _, parent := range parents {
+		show := parent.(postgres.ExhibitionHit)
+		if show.MuseumWikidataID == "" {
+			continue
+		}
+		wanted[show.MuseumWikidataID] = true
+		if lat, lon, ok := show.Position(); ok {
+			positions = append(positions, [2]float64{lat, lon})
+		}
+	}
+
+	museums := map[string]any{}
+	if lat, lon, spread, ok := enclosing(positions); ok && spread+museumPageRadiusKm <= maxRadiusKm {
+		page, err := e.server.catalogue.NearbyVerified(e.r.Context(), lat, lon,
+			spread+museumPageRadiusKm, maxLimit, 0, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, hit := range page.Hits {
+			if id := hit.Museum.WikidataID; wanted[id] {
+				hit.DistanceKm = 0
+				museums[id] = hit
+			}
+		}
+	}
+	for id := range wanted {
+		if _, ok := museums[id]; ok {
+			continue
+		}
+		hit, err := e.server.catalogue.MuseumByID(e.r.Context(), id)
+		switch {
+		case errors.Is(err, postgres.ErrNotFound):
+			museums[id] = nil
+		case err != nil:
+			return nil, err
+		default:
+			museums[id] = hit
+		}
+	}
+
+	out := make([]any, len(parents))
+	for i, parent := range parents {
+		if id := parent.(postgres.ExhibitionHit).MuseumWikidataID; id != "" {
+			out[i] = museums[id]
+		}
+	}
+	return out, nil
+}
+
+// enclosing returns a circle around a set of positions: their mean, and the
+// distance from it to the farthest.
+func enclosing(positions [][2]float64) (lat, lon, radiusKm float64, ok bool) {
+	if len(positions) == 0 {
+		return 0, 0, 0, false
+	}
+	for _, p := range positions {
+		lat += p[0]
+		lon += p[1]
+	}
+	lat /= float64(len(positions))
+	lon /= float64(len(positions))
+	for _, p := range positions {
+		radiusKm = max(radiusKm, geo.DistanceKm(lat, lon, p[0], p[1]))
+	}
+	return lat, lon, radiusKm, true
+}
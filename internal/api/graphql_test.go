@@ -0,0 +1,219 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"museum/internal/models"
+	"museum/internal/postgres"
+	"museum/pkg/exhibitions"
+)
+
+func postGraphQL(t *testing.T, s *Server, query string, variables map[string]any) (int, graphQLResult) {
+	t.Helper()
+	body, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/v1/graphql", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	s.Routes().ServeHTTP(rec, req)
+
+	var result graphQLResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decode %s: %v", rec.Body, err)
+	}
+	return rec.Code, result
+}
+
+type graphQLResult struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []graphQLError  `json:"errors"`
+}
+
+// museumsNearTheRiver are three museums a few hundred metres apart, each with
+// a listing at its own address.
+func museumsNearTheRiver() *fakeCatalogue {
+	museum := func(id int64, name, qid string, lat, lon float64) postgres.Hit {
+		return postgres.Hit{ID: id, Museum: models.Museum{
+			Name: name, WikidataID: qid, Latitude: lat, Longitude: lon, Verified: true,
+		}}
+	}
+	show := func(title, venue, qid string, lat, lon float64) postgres.ExhibitionHit {
+		return postgres.ExhibitionHit{Exhibition: exhibitions.Exhibition{
+			Title: title, URL: "https://example.org/" + title, Museum: venue,
+			MuseumWikidataID: qid, Latitude: lat, Longitude: lon, Running: true,
+		}}
+	}
+	return &fakeCatalogue{
+		nearby: []postgres.Hit{
+			museum(1, "Tate Modern", "Q193375", 51.5076, -0.0994),
+			museum(2, "Shakespeare's Globe", "Q714322", 51.5081, -0.0972),
+			museum(3, "Bankside Gallery", "Q4856800", 51.5079, -0.1010),
+		},
+		exhibitions: []postgres.ExhibitionHit{
+			show("Electric Dreams", "Tate Modern", "Q193375", 51.5076, -0.0994),
+			show("Hamlet", "Shakespeare's Globe", "Q714322", 51.5081, -0.0972),
+		},
+	}
+}
+
+func TestGraphQL_AnswersNestedQueriesInOneRoundTrip(t *testing.T) {
+	c := museumsNearTheRiver()
+	status, result := postGraphQL(t, NewServer(c), `
+		query Bankside($lat: Float!) {
+			museums(lat: $lat, lon: -0.0994, radiusKm: 1) {
+				id
+				name
+				exhibitions { title museum { name } }
+				nearby(limit: 2) { name }
+			}
+		}`, map[string]any{"lat": 51.5076})
+	if status != http.StatusOK || len(result.Errors) > 0 {
+		t.Fatalf("status = %d, errors = %v", status, result.Errors)
+	}
+
+	var data struct {
+		Museums []struct {
+			ID          string
+			Name        string
+			Exhibitions []struct {
+				Title  string
+				Museum struct{ Name string }
+			}
+			Nearby []struct{ Name string }
+		}
+	}
+	if err := json.Unmarshal(result.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Museums) != 3 {
+		t.Fatalf("museums = %d, want 3", len(data.Museums))
+	}
+	tate := data.Museums[0]
+	if tate.ID != "1" || len(tate.Exhibitions) != 1 || tate.Exhibitions[0].Title != "Electric Dreams" {
+		t.Errorf("Tate Modern = %+v, want its own listing only", tate)
+	}
+	if tate.Exhibitions[0].Museum.Name != "Tate Modern" {
+		t.Errorf("exhibition's museum = %q", tate.Exhibitions[0].Museum.Name)
+	}
+	if len(data.Museums[2].Exhibitions) != 0 {
+		t.Errorf("Bankside Gallery has %d listings, want none", len(data.Museums[2].Exhibitions))
+	}
+	for _, other := range tate.Nearby {
+		if other.Name == "Tate Modern" {
+			t.Error("a museum is listed as near itself")
+		}
+	}
+
+	// Fields keep the order they were asked for in.
+	if !strings.HasPrefix(string(result.Data), `{"museums":[{"id":"1","name":"Tate Modern","exhibitions"`) {
+		t.Errorf("data = %s, want fields in query order", result.Data)
+	}
+}
+
+func TestGraphQL_BatchesNestedLookups(t *testing.T) {
+	c := museumsNearTheRiver()
+	status, result := postGraphQL(t, NewServer(c), `{
+		museums(lat: 51.5076, lon: -0.0994) { name exhibitions { title } }
+	}`, nil)
+	if status != http.StatusOK || len(result.Errors) > 0 {
+		t.Fatalf("status = %d, errors = %v", status, result.Errors)
+	}
+	// The three museums stand close enough together for one query to cover
+	// all of them.
+	if c.exhibitionsCalls != 1 {
+		t.Errorf("exhibition queries = %d, want 1 for the whole batch", c.exhibitionsCalls)
+	}
+}
+
+// A listing with no position is looked up on its own, and leaves the others
+// to the one query around them.
+func TestGraphQL_ExhibitionMuseumsIgnoreUnplacedListings(t *testing.T) {
+	c := museumsNearTheRiver()
+	unplaced := c.exhibitions[1]
+	unplaced.Title, unplaced.Latitude, unplaced.Longitude = "Touring", 0, 0
+	c.exhibitions = append(c.exhibitions, unplaced)
+
+	status, result := postGraphQL(t, NewServer(c), `{
+		exhibitions(lat: 51.5076, lon: -0.0994) { title museum { name } }
+	}`, nil)
+	if status != http.StatusOK || len(result.Errors) > 0 {
+		t.Fatalf("status = %d, errors = %v", status, result.Errors)
+	}
+	var data struct {
+		Exhibitions []struct {
+			Title  string
+			Museum struct{ Name string }
+		}
+	}
+	if err := json.Unmarshal(result.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	for _, show := range data.Exhibitions {
+		if show.Museum.Name == "" {
+			t.Errorf("%s has no museum", show.Title)
+		}
+	}
+	if c.byIDCalls != 0 {
+		t.Errorf("museums looked up one by one = %d, want all found by the one query", c.byIDCalls)
+	}
+}
+
+func TestGraphQL_RefusesWhatRESTWouldRefuse(t *testing.T) {
+	cases := map[string]string{
+		"radius past the cap":   `{ museums(lat: 51.5, lon: -0.1, radiusKm: 80) { name } }`,
+		"offset past the cap":   `{ search(q: "tate", offset: 20000) { name } }`,
+		"over the cost budget":  `{ museums(lat: 51.5, lon: -0.1, limit: 500) { nearby(limit: 50) { name } } }`,
+		"a mutation":            `mutation { deleteMuseum(id: 1) { id } }`,
+		"an unknown field":      `{ museums(lat: 51.5, lon: -0.1) { founded } }`,
+		"an object with no set": `{ museum(id: "1") }`,
+		"a missing argument":    `{ search { name } }`,
+		"malformed":             `{ museums(lat: 51.5 { name } }`,
+	}
+	for name, query := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := museumsNearTheRiver()
+			status, result := postGraphQL(t, NewServer(c), query, nil)
+			if status != http.StatusBadRequest || len(result.Errors) == 0 {
+				t.Errorf("status = %d, errors = %v, want 400 with an error", status, result.Errors)
+			}
+			if c.nearbyCalls != 0 {
+				t.Error("a refused query reached the catalogue")
+			}
+		})
+	}
+}
+
+func TestGraphQL_ClampsLimitsAsRESTDoes(t *testing.T) {
+	c := museumsNearTheRiver()
+	status, result := postGraphQL(t, NewServer(c), `{ museums(lat: 51.5, lon: -0.1, limit: 5000) { name } }`, nil)
+	if status != http.StatusOK || len(result.Errors) > 0 {
+		t.Fatalf("status = %d, errors = %v", status, result.Errors)
+	}
+	if c.lastLimit != maxLimit {
+		t.Errorf("limit reached the catalogue as %d, want %d", c.lastLimit, maxLimit)
+	}
+}
+
+func TestGraphQL_ResolvesPlaces(t *testing.T) {
+	c := museumsNearTheRiver()
+	status, result := postGraphQL(t, NewServer(c).WithPlaces(fixedPlaces{}),
+		`{ place(name: "London") { name radiusKm museums(limit: 2) { name } } }`, nil)
+	if status != http.StatusOK || len(result.Errors) > 0 {
+		t.Fatalf("status = %d, errors = %v", status, result.Errors)
+	}
+	if c.lastRadiusKm != 5 {
+		t.Errorf("radius = %v, want the place's own extent", c.lastRadiusKm)
+	}
+
+	// Without a resolver the field fails on its own, with a reason.
+	_, result = postGraphQL(t, NewServer(c), `{ place(name: "London") { name } }`, nil)
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, "not configured") {
+		t.Errorf("errors = %v, want place lookup reported as unavailable", result.Errors)
+	}
+}
@@ -85,8 +85,10 @@ type gzipWriter struct {
 func compressible(contentType string) bool {
 	switch {
 	case strings.HasPrefix(contentType, "application/json"),
+		strings.HasPrefix(contentType, geoJSONType),
 		strings.HasPrefix(contentType, "text/"),
 		strings.HasPrefix(contentType, "application/javascript"),
+		strings.HasPrefix(contentType, "application/xml"),
 		strings.HasPrefix(contentType, "image/svg+xml"):
 		return true
 	default:
@@ -116,9 +118,19 @@ func (g *gzipWriter) decide() {
 	header.Del("Content-Length")
 	header.Set("Content-Encoding", "gzip")
 	header.Add("Vary", "Accept-Encoding")
+
+	// A strong ETag names exact bytes, and these are no longer the bytes it
+	// was computed for. The compressed representation gets a tag of its own;
+	// sameEntity treats the two as one when a client revalidates.
+	if etag := header.Get("ETag"); strings.HasPrefix(etag, `"`) && strings.HasSuffix(etag, `"`) {
+		header.Set("ETag", strings.TrimSuffix(etag, `"`)+gzipETagSuffix+`"`)
+	}
 	g.zip = gzip.NewWriter(g.ResponseWriter)
 }
 
+// gzipETagSuffix marks the entity tag of a compressed representation.
+const gzipETagSuffix = "-gzip"
+
 func (g *gzipWriter) Write(b []byte) (int, error) {
 	if !g.decided {
 		// A handler that writes without WriteHeader has already had its
@@ -168,13 +180,14 @@ func withTimeout(next http.Handler) http.Handler {
 // which is a browser application; without these headers it cannot read a single
 // response. The data is public and read-only, so any origin may have it — there
 // are no cookies, no credentials and no state to protect. Preflight is answered
-// here because the routes register GET only, so the mux answers OPTIONS with
-// 405 and a browser reads that as a refusal.
+// here because no route registers OPTIONS, so the mux answers it with 405 and
+// a browser reads that as a refusal. POST is allowed for GraphQL, whose
+// queries are reads sent as a body.
 func withCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		header := w.Header()
 		header.Set("Access-Control-Allow-Origin", "*")
-		header.Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+		header.Set("Access-Control-Allow-Methods", "GET, HEAD, POST, OPTIONS")
 		header.Set("Access-Control-Allow-Headers", "Content-Type")
 		header.Set("Access-Control-Max-Age", "86400")
 		header.Set("Vary", "Origin")
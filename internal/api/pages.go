@@ -0,0 +1,386 @@
+package api
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"museum/internal/models"
+	"museum/internal/postgres"
+	"museum/internal/search"
+	"museum/pkg/exhibitions"
+)
+
+const (
+	// sitemapPageSize is the most URLs one sitemap file may carry, by the
+	// protocol's own limit.
+	sitemapPageSize = 50_000
+
+	// museumPageRadiusKm is how far from a museum its page looks for listings.
+	// The radius is only how they are found; belongsTo decides whose they are.
+	museumPageRadiusKm = 1
+
+	// museumPageExhibitions caps the listings on one page.
+	museumPageExhibitions = 100
+)
+
+// museumTemplate renders one museum as a page a search engine can read.
+//
+// Everything public used to be behind the single-page map, which builds its
+// content in the browser from JSON — so to a crawler the catalogue was one
+// empty page. html/template rather than string building for the same reason
+// the map builds nodes rather than markup: the names and titles on the page
+// come from Wikidata, OpenStreetMap and museums' own websites.
+//
+//go:embed web/museum.html
+var museumTemplateSource string
+
+var museumTemplate = template.Must(template.New("museum").Parse(museumTemplateSource))
+
+// museumPage is what the template is given.
+type museumPage struct {
+	Museum         museumHit
+	Place          string
+	Summary        string
+	Address        string
+	Canonical      string
+	MapURL         string
+	Exhibitions    []pageExhibition
+	StructuredData template.JS
+}
+
+type pageExhibition struct {
+	Title string
+	URL   string
+	When  string
+}
+
+// handleMuseumPage renders a museum as HTML, for search engines and for
+// anyone following a link without JavaScript.
+func (s *Server) handleMuseumPage(w http.ResponseWriter, r *http.Request) {
+	setPagePolicy(w.Header())
+
+	hit, err := s.catalogue.MuseumByID(r.Context(), r.PathValue("id"))
+	if errors.Is(err, postgres.ErrNotFound) {
+		http.Error(w, "No such museum.", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeServerError(w, r, err)
+		return
+	}
+
+	// A page without its listings is still worth serving; the museum is the
+	// part a search engine came for.
+	shows, err := s.exhibitionsOf(r, hit)
+	if err != nil {
+		log.Printf("api: exhibitions for museum page %d: %v", hit.ID, err)
+	}
+
+	page := buildMuseumPage(baseURL(r), hit, shows)
+
+	var body bytes.Buffer
+	if err := museumTemplate.Execute(&body, page); err != nil {
+		writeServerError(w, r, fmt.Errorf("render museum %d: %w", hit.ID, err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body.Bytes())
+}
+
+// exhibitionsOf returns what is on at a museum, found the way the map's museum
+// card finds them: nearby, then narrowed to the listings that are its own.
+func (s *Server) exhibitionsOf(r *http.Request, hit postgres.Hit) ([]postgres.ExhibitionHit, error) {
+	if !hit.Museum.HasCoordinates() {
+		return nil, nil
+	}
+	nearby, err := s.catalogue.ExhibitionsNearby(r.Context(),
+		hit.Museum.Latitude, hit.Museum.Longitude, museumPageRadiusKm, true, museumPageExhibitions)
+	if err != nil {
+		return nil, err
+	}
+
+	var mine []postgres.ExhibitionHit
+	for _, show := range nearby {
+		if belongsTo(show, hit.Museum) {
+			mine = append(mine, show)
+		}
+	}
+	return mine, nil
+}
+
+// belongsTo decides whether a listing is this museum's. By Wikidata id where
+// both sides have one; otherwise by name, which is the best the listing offers.
+func belongsTo(show postgres.ExhibitionHit, museum models.Museum) bool {
+	if show.MuseumWikidataID != "" && museum.WikidataID != "" {
+		return show.MuseumWikidataID == museum.WikidataID
+	}
+	name := func(s string) string { return strings.TrimPrefix(search.Normalize(s), "the ") }
+	return name(show.Museum) != "" && name(show.Museum) == name(museum.Name)
+}
+
+func buildMuseumPage(base string, hit postgres.Hit, shows []postgres.ExhibitionHit) museumPage {
+	m := hit.Museum
+	page := museumPage{
+		Museum:    museumHitFrom(hit, 0),
+		Place:     joinNonEmpty(", ", m.Locality, m.Country),
+		Address:   joinNonEmpty(", ", m.Address.Street(), joinNonEmpty(" ", m.Address.Postcode, m.Locality), m.Country),
+		Canonical: fmt.Sprintf("%s/museums/%d", base, hit.ID),
+		Summary:   m.Description,
+	}
+	if page.Summary == "" && page.Place != "" {
+		page.Summary = m.Name + ", a museum in " + page.Place + "."
+	}
+	if m.HasCoordinates() {
+		page.MapURL = fmt.Sprintf("/map#m=%d&view=15/%.5f/%.5f", hit.ID, m.Latitude, m.Longitude)
+	}
+	for _, show := range shows {
+		page.Exhibitions = append(page.Exhibitions, pageExhibition{
+			Title: show.Title, URL: show.URL, When: showDates(show.Exhibition),
+		})
+	}
+	page.StructuredData = structuredData(page, hit, shows)
+	return page
+}
+
+// showDates says when a listing is on, in the words a visitor would use.
+func showDates(show exhibitions.Exhibition) string {
+	const layout = "2 Jan 2006"
+	switch {
+	case show.Permanent:
+		return "permanent"
+	case show.Start != nil && show.End != nil:
+		return show.Start.Format(layout) + " – " + show.End.Format(layout)
+	case show.End != nil:
+		return "until " + show.End.Format(layout)
+	case show.Start != nil:
+		return "from " + show.Start.Format(layout)
+	default:
+		return ""
+	}
+}
+
+// schema.org's vocabulary, as much of it as a museum page uses.
+type (
+	ldMuseum struct {
+		Context     string         `json:"@context"`
+		Type        string         `json:"@type"`
+		Name        string         `json:"name"`
+		URL         string         `json:"url"`
+		Description string         `json:"description,omitempty"`
+		AltNames    []string       `json:"alternateName,omitempty"`
+		SameAs      []string       `json:"sameAs,omitempty"`
+		Address     *ldAddress     `json:"address,omitempty"`
+		Geo         *ldGeo         `json:"geo,omitempty"`
+		Events      []ldExhibition `json:"event,omitempty"`
+	}
+	ldAddress struct {
+		Type     string `json:"@type"`
+		Street   string `json:"streetAddress,omitempty"`
+		Postcode string `json:"postalCode,omitempty"`
+		Locality string `json:"addressLocality,omitempty"`
+		Country  string `json:"addressCountry,omitempty"`
+	}
+	ldGeo struct {
+		Type      string  `json:"@type"`
+		Latitude  float64 `json:"latitude"`
+		Longitude float64 `json:"longitude"`
+	}
+	ldExhibition struct {
+		Type      string  `json:"@type"`
+		Name      string  `json:"name"`
+		URL       string  `json:"url,omitempty"`
+		StartDate string  `json:"startDate,omitempty"`
+		EndDate   string  `json:"endDate,omitempty"`
+		Location  ldVenue `json:"location"`
+	}
+	ldVenue struct {
+		Type string `json:"@type"`
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	}
+)
+
+// structuredData describes the museum and its exhibitions as JSON-LD.
+//
+// Safe to place inside a script element as it stands: encoding/json escapes
+// <, > and &, so nothing in a scraped title can close the element early.
+func structuredData(page museumPage, hit postgres.Hit, shows []postgres.ExhibitionHit) template.JS {
+	m := hit.Museum
+	doc := ldMuseum{
+		Context: "https://schema.org", Type: "Museum",
+		Name: m.Name, URL: page.Canonical, Description: m.Description,
+		AltNames: m.AlsoKnownAs,
+	}
+	for _, link := range []string{m.Website, m.WikipediaURL} {
+		if link != "" {
+			doc.SameAs = append(doc.SameAs, link)
+		}
+	}
+	if m.WikidataID != "" {
+		doc.SameAs = append(doc.SameAs, "https://www.wikidata.org/wiki/"+m.WikidataID)
+	}
+	if page.Address != "" {
+		doc.Address = &ldAddress{Type: "PostalAddress", Street: m.Address.Street(),
+			Postcode: m.Address.Postcode, Locality: m.Locality, Country: m.Country}
+	}
+	if m.HasCoordinates() {
+		doc.Geo = &ldGeo{Type: "GeoCoordinates", Latitude: m.Latitude, Longitude: m.Longitude}
+	}
+	for _, show := range shows {
+		event := ldExhibition{Type: "ExhibitionEvent", Name: show.Title, URL: show.URL,
+			Location: ldVenue{Type: "Museum", Name: m.Name, URL: page.Canonical}}
+		if show.Start != nil {
+			event.StartDate = show.Start.Format(time.DateOnly)
+		}
+		if show.End != nil {
+			event.EndDate = show.End.Format(time.DateOnly)
+		}
+		doc.Events = append(doc.Events, event)
+	}
+
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		// Nothing in the document can fail to encode; an empty object keeps
+		// the page valid if that ever stops being true.
+		return template.JS("{}")
+	}
+	return template.JS(encoded)
+}
+
+// The sitemap protocol's two documents: an index of files, and a file of URLs.
+type (
+	sitemapIndex struct {
+		XMLName  xml.Name       `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+		Sitemaps []sitemapEntry `xml:"sitemap"`
+	}
+	sitemapEntry struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod,omitempty"`
+	}
+	urlSet struct {
+		XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+		URLs    []sitemapURL `xml:"url"`
+	}
+	sitemapURL struct {
+		Loc string `xml:"loc"`
+	}
+)
+
+// handleSitemapIndex lists the sitemap files, one per sitemapPageSize current
+// museums.
+//
+// Each file is named for the id its museums follow, so serving it is one
+// keyset page rather than a walk of the catalogue up to it. Finding where the
+// pages start walks the ids once, a page at a time, by the same query the
+// files are served from: a retired museum is neither counted nor listed.
+func (s *Server) handleSitemapIndex(w http.ResponseWriter, r *http.Request) {
+	counts, err := s.catalogue.Counts(r.Context())
+	if err != nil {
+		writeServerError(w, r, err)
+		return
+	}
+
+	var lastMod string
+	if counts.LastUpdated != nil {
+		lastMod = counts.LastUpdated.UTC().Format(time.RFC3339)
+	}
+
+	base := baseURL(r)
+	index := sitemapIndex{}
+	for after := int64(0); ; {
+		ids, err := s.catalogue.MuseumIDs(r.Context(), after, sitemapPageSize)
+		if err != nil {
+			writeServerError(w, r, err)
+			return
+		}
+		// An empty catalogue still has its one, empty, file.
+		if len(ids) > 0 || after == 0 {
+			index.Sitemaps = append(index.Sitemaps, sitemapEntry{
+				Loc: fmt.Sprintf("%s/sitemap/%d.xml", base, after), LastMod: lastMod,
+			})
+		}
+		if len(ids) < sitemapPageSize {
+			break
+		}
+		after = ids[len(ids)-1]
+	}
+	writeXML(w, r, index)
+}
+
+// handleSitemap lists the URLs of the current museums after the id the file
+// is named for, a page of them.
+func (s *Server) handleSitemap(w http.ResponseWriter, r *http.Request) {
+	after, err := strconv.ParseInt(strings.TrimSuffix(r.PathValue("file"), ".xml"), 10, 64)
+	if err != nil || after < 0 || !strings.HasSuffix(r.PathValue("file"), ".xml") {
+		writeError(w, http.StatusNotFound, errors.New("no such sitemap"))
+		return
+	}
+
+	ids, err := s.catalogue.MuseumIDs(r.Context(), after, sitemapPageSize)
+	if err != nil {
+		writeServerError(w, r, err)
+		return
+	}
+	if len(ids) == 0 && after > 0 {
+		writeError(w, http.StatusNotFound, errors.New("no such sitemap"))
+		return
+	}
+	base := baseURL(r)
+	set := urlSet{}
+	for _, id := range ids {
+		set.URLs = append(set.URLs, sitemapURL{Loc: fmt.Sprintf("%s/museums/%d", base, id)})
+	}
+	writeXML(w, r, set)
+}
+
+// handleRobots points crawlers at the sitemap.
+func (s *Server) handleRobots(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "User-agent: *\nDisallow: /v1/\nSitemap: %s/sitemap.xml\n", baseURL(r))
+}
+
+func writeXML(w http.ResponseWriter, r *http.Request, body any) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := xml.NewEncoder(&buf).Encode(body); err != nil {
+		writeServerError(w, r, fmt.Errorf("encode sitemap: %w", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// baseURL is the scheme and host the request arrived at, which is what the
+// absolute URLs in a sitemap and a canonical link must name. A proxy that
+// terminates TLS says so in X-Forwarded-Proto.
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+	kept := parts[:0:0]
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, sep)
+}
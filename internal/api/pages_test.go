@@ -0,0 +1,140 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"museum/internal/models"
+	"museum/internal/postgres"
+	"museum/pkg/exhibitions"
+)
+
+func TestMuseumPage_RendersTheMuseumAndItsExhibitions(t *testing.T) {
+	closes := time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)
+	c := &fakeCatalogue{
+		nearby: []postgres.Hit{{ID: 42, Museum: models.Museum{
+			Name: "Rijksmuseum", Country: "Netherlands", Locality: "Amsterdam",
+			WikidataID: "Q190804", Latitude: 52.36, Longitude: 4.885,
+			Address: models.Address{Road: "Museumstraat", HouseNumber: "1", Postcode: "1071 XX"},
+		}}},
+		exhibitions: []postgres.ExhibitionHit{
+			{Exhibition: exhibitions.Exhibition{Title: "Vermeer </script><script>alert(1)</script>",
+				URL: "https://www.rijksmuseum.nl/en/vermeer", MuseumWikidataID: "Q190804", End: &closes}},
+			{Exhibition: exhibitions.Exhibition{Title: "Next door's show",
+				URL: "https://example.org/show", MuseumWikidataID: "Q1"}},
+		},
+	}
+
+	rec := get(t, c, "/museums/Q190804")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if rec.Header().Get("Content-Security-Policy") != contentPolicy {
+		t.Error("the museum page does not carry the map's security policy")
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{"<h1>Rijksmuseum</h1>", "Museumstraat", "/map#m=42", "until 31 Dec 2026",
+		`<link rel="canonical" href="http://example.com/museums/42">`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page lacks %q", want)
+		}
+	}
+	if strings.Contains(body, "Next door") {
+		t.Error("a neighbouring venue's listing was shown as this museum's")
+	}
+	if strings.Contains(body, "<script>alert(1)") {
+		t.Error("a scraped title reached the page unescaped")
+	}
+
+	ld := regexp.MustCompile(`(?s)<script type="application/ld\+json">(.*?)</script>`).FindStringSubmatch(body)
+	if ld == nil {
+		t.Fatal("no JSON-LD on the page")
+	}
+	var doc struct {
+		Type   string `json:"@type"`
+		Name   string `json:"name"`
+		Events []struct {
+			Type    string `json:"@type"`
+			EndDate string `json:"endDate"`
+		} `json:"event"`
+	}
+	if err := json.Unmarshal([]byte(ld[1]), &doc); err != nil {
+		t.Fatalf("JSON-LD does not parse: %v\n%s", err, ld[1])
+	}
+	if doc.Type != "Museum" || doc.Name != "Rijksmuseum" {
+		t.Errorf("JSON-LD describes %q %q", doc.Type, doc.Name)
+	}
+	if len(doc.Events) != 1 || doc.Events[0].Type != "ExhibitionEvent" || doc.Events[0].EndDate != "2026-12-31" {
+		t.Errorf("events = %+v, want the one exhibition closing 2026-12-31", doc.Events)
+	}
+}
+
+func TestMuseumPage_UnknownIsNotFound(t *testing.T) {
+	rec := get(t, &fakeCatalogue{}, "/museums/Q404")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
+
+func TestSitemap_ListsEveryMuseum(t *testing.T) {
+	c := &fakeCatalogue{
+		counts: postgres.Counts{Museums: 2},
+		nearby: []postgres.Hit{
+			{ID: 1, Museum: models.Museum{Name: "Louvre"}},
+			{ID: 7, Museum: models.Museum{Name: "Orsay"}},
+		},
+	}
+
+	index := get(t, c, "/sitemap.xml")
+	if index.Code != http.StatusOK {
+		t.Fatalf("index status = %d", index.Code)
+	}
+	if !strings.Contains(index.Body.String(), "<loc>http://example.com/sitemap/0.xml</loc>") {
+		t.Errorf("index does not point at the first page:\n%s", index.Body)
+	}
+	if strings.Count(index.Body.String(), "<sitemap>") != 1 {
+		t.Errorf("index lists a page that would be empty:\n%s", index.Body)
+	}
+
+	page := get(t, c, "/sitemap/0.xml")
+	if page.Code != http.StatusOK {
+		t.Fatalf("page status = %d", page.Code)
+	}
+	for _, want := range []string{"http://example.com/museums/1", "http://example.com/museums/7"} {
+		if !strings.Contains(page.Body.String(), want) {
+			t.Errorf("sitemap lacks %s", want)
+		}
+	}
+
+	if rec := get(t, c, "/sitemap/7.xml"); rec.Code != http.StatusNotFound {
+		t.Errorf("page past the end: status = %d, want 404", rec.Code)
+	}
+}
+
+// A file holds a page of museums, and the next starts after its last.
+func TestSitemap_PagesByID(t *testing.T) {
+	c := &fakeCatalogue{counts: postgres.Counts{Museums: sitemapPageSize + 1}}
+	for id := int64(1); id <= sitemapPageSize+1; id++ {
+		c.nearby = append(c.nearby, postgres.Hit{ID: id * 2})
+	}
+
+	index := get(t, c, "/sitemap.xml").Body.String()
+	last := fmt.Sprintf("http://example.com/sitemap/%d.xml", 2*sitemapPageSize)
+	if strings.Count(index, "<sitemap>") != 2 || !strings.Contains(index, last) {
+		t.Fatalf("index lists %d files, want 2 with the second at %s", strings.Count(index, "<sitemap>"), last)
+	}
+
+	page := get(t, c, fmt.Sprintf("/sitemap/%d.xml", 2*sitemapPageSize)).Body.String()
+	if strings.Count(page, "<url>") != 1 || !strings.Contains(page, fmt.Sprintf("/museums/%d<", 2*(sitemapPageSize+1))) {
+		t.Errorf("second page = %s, want only the last museum", page)
+	}
+	if first := get(t, c, "/sitemap/0.xml").Body.String(); strings.Count(first, "<url>") != sitemapPageSize {
+		t.Errorf("first page holds %d museums, want %d", strings.Count(first, "<url>"), sitemapPageSize)
+	}
+}
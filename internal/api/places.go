@@ -30,9 +30,6 @@ type PlaceCache interface {
 	LocalityPlace(ctx context.Context, query string) (postgres.Place, error)
 }
 
-// Geocoder turns a place name into a location. Satisfied by pkg/location.
-type Geocoder func(ctx context.Context, query string) (*location.NominatimLocation, error)
-
 // PlaceResolver turns "Paris" into coordinates, remembering what it learns.
 //
 // This is what makes the catalogue answerable by the question people actually
@@ -43,13 +40,13 @@ type Geocoder func(ctx context.Context, query string) (*location.NominatimLocati
 // found 34 museums where a radius around the city centre finds 123.
 type PlaceResolver struct {
 	cache    PlaceCache
-	geocode  Geocoder
+	geocoder location.Geocoder
 	fallback float64
 }
 
-// NewPlaceResolver returns a resolver backed by cache and geocode.
-func NewPlaceResolver(cache PlaceCache, geocode Geocoder) *PlaceResolver {
-	return &PlaceResolver{cache: cache, geocode: geocode, fallback: defaultRadiusKm}
+// NewPlaceResolver returns a resolver backed by cache and geocoder.
+func NewPlaceResolver(cache PlaceCache, geocoder location.Geocoder) *PlaceResolver {
+	return &PlaceResolver{cache: cache, geocoder: geocoder, fallback: defaultRadiusKm}
 }
 
 // Resolve returns the place a name refers to.
@@ -76,7 +73,7 @@ func (r *PlaceResolver) Resolve(ctx context.Context, name string) (postgres.Plac
 		return cached, nil
 	}
 
-	found, err := r.geocode(ctx, name)
+	found, err := r.geocoder.Geocode(ctx, name)
 	if errors.Is(err, location.ErrNoResults) {
 		// The geocoder matches exactly, so a typo returns nothing at all —
 		// while a museum search for a name spelled just as badly succeeds,
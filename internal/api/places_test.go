@@ -46,14 +46,14 @@ func (c *fakeCache) LocalityPlace(_ context.Context, query string) (postgres.Pla
 func TestPlaceResolver_ResolvesAndCaches(t *testing.T) {
 	cache := newFakeCache()
 	calls := 0
-	geocode := func(context.Context, string) (*location.NominatimLocation, error) {
+	geocode := location.GeocoderFunc(func(context.Context, string) (*location.NominatimLocation, error) {
 		calls++
 		return &location.NominatimLocation{
 			Lat: "48.8566", Lon: "2.3522",
 			DisplayName: "Paris, Ile-de-France, France",
 			BoundingBox: []string{"48.8156", "48.9022", "2.2242", "2.4699"},
 		}, nil
-	}
+	})
 
 	resolver := NewPlaceResolver(cache, geocode)
 	ctx := context.Background()
@@ -91,10 +91,10 @@ func TestPlaceResolver_ResolvesAndCaches(t *testing.T) {
 func TestPlaceResolver_CachesFailures(t *testing.T) {
 	cache := newFakeCache()
 	calls := 0
-	geocode := func(context.Context, string) (*location.NominatimLocation, error) {
+	geocode := location.GeocoderFunc(func(context.Context, string) (*location.NominatimLocation, error) {
 		calls++
 		return nil, location.ErrNoResults
-	}
+	})
 
 	resolver := NewPlaceResolver(cache, geocode)
 	ctx := context.Background()
@@ -114,9 +114,9 @@ func TestPlaceResolver_CachesFailures(t *testing.T) {
 // should retry, and the name must not be cached as unknown.
 func TestPlaceResolver_DoesNotCacheTransportFailures(t *testing.T) {
 	cache := newFakeCache()
-	geocode := func(context.Context, string) (*location.NominatimLocation, error) {
+	geocode := location.GeocoderFunc(func(context.Context, string) (*location.NominatimLocation, error) {
 		return nil, errors.New("connection refused")
-	}
+	})
 
 	resolver := NewPlaceResolver(cache, geocode)
 
@@ -136,9 +136,9 @@ func TestPlaceResolver_DoesNotCacheTransportFailures(t *testing.T) {
 // request falls back to the default radius rather than guessing.
 func TestPlaceResolver_FallsBackWhenNoBoundingBox(t *testing.T) {
 	cache := newFakeCache()
-	geocode := func(context.Context, string) (*location.NominatimLocation, error) {
+	geocode := location.GeocoderFunc(func(context.Context, string) (*location.NominatimLocation, error) {
 		return &location.NominatimLocation{Lat: "48.8566", Lon: "2.3522", DisplayName: "Paris"}, nil
-	}
+	})
 
 	place, err := NewPlaceResolver(cache, geocode).Resolve(context.Background(), "Paris")
 	if err != nil {
@@ -152,12 +152,12 @@ func TestPlaceResolver_FallsBackWhenNoBoundingBox(t *testing.T) {
 // A country-sized box must not turn into a country-sized query.
 func TestPlaceResolver_ClampsHugeBoundingBox(t *testing.T) {
 	cache := newFakeCache()
-	geocode := func(context.Context, string) (*location.NominatimLocation, error) {
+	geocode := location.GeocoderFunc(func(context.Context, string) (*location.NominatimLocation, error) {
 		return &location.NominatimLocation{
 			Lat: "46.6", Lon: "2.3", DisplayName: "France",
 			BoundingBox: []string{"41.3", "51.1", "-5.1", "9.6"},
 		}, nil
-	}
+	})
 
 	place, err := NewPlaceResolver(cache, geocode).Resolve(context.Background(), "France")
 	if err != nil {
@@ -177,9 +177,9 @@ func TestPlaceResolver_FallsBackToKnownLocalities(t *testing.T) {
 		DisplayName: "Gothenburg", Latitude: 57.7072, Longitude: 11.967,
 		RadiusKm: 12, Found: true,
 	}
-	geocode := func(context.Context, string) (*location.NominatimLocation, error) {
+	geocode := location.GeocoderFunc(func(context.Context, string) (*location.NominatimLocation, error) {
 		return nil, location.ErrNoResults
-	}
+	})
 
 	place, err := NewPlaceResolver(cache, geocode).Resolve(context.Background(), "gothenborg sweden")
 	if err != nil {
@@ -201,9 +201,9 @@ func TestPlaceResolver_FallsBackToKnownLocalities(t *testing.T) {
 // confidently with the wrong town is harder to notice than a 404.
 func TestPlaceResolver_FallbackStillReportsUnknown(t *testing.T) {
 	cache := newFakeCache() // locality nil, so the fallback finds nothing
-	geocode := func(context.Context, string) (*location.NominatimLocation, error) {
+	geocode := location.GeocoderFunc(func(context.Context, string) (*location.NominatimLocation, error) {
 		return nil, location.ErrNoResults
-	}
+	})
 
 	_, err := NewPlaceResolver(cache, geocode).Resolve(context.Background(), "qqzzxx")
 	if !errors.Is(err, postgres.ErrPlaceUnknown) {
@@ -32,8 +32,11 @@ function area({ lat, lon, radiusKm }, extra = {}) {
 	});
 }
 
-export function points(bbox, signal) {
-	const query = "limit=40000" + (bbox ? "&bbox=" + bbox.map(v => v.toFixed(4)).join(",") : "");
+// points asks for the museums in view. With a zoom the server groups a dense
+// view into clusters and sends a sparse one point by point, so the answer is
+// either `points` or `clusters` and `clustered` says which.
+export function points(bbox, zoom, signal) {
+	const query = "zoom=" + zoom.toFixed(1) + (bbox ? "&bbox=" + bbox.map(v => v.toFixed(4)).join(",") : "");
 	return getJSON("/v1/points?" + query, { signal });
 }
 
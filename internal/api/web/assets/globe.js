@@ -184,14 +184,19 @@ function addLayerSafely(spec) {
 
 /* ---- layers ------------------------------------------------------------- */
 
+// CLUSTER_SCALE enlarges a dot by how many museums it stands for: 1 for a
+// single museum, about 3.3 for a thousand.
+const CLUSTER_SCALE = ["+", 1, ["*", 0.33, ["ln", ["max", 1, ["coalesce", ["get", "museums"], 1]]]]];
+
 export function build({ onPick, onPickVenue }) {
 	map.addSource("museums", {
 		type: "geojson",
 		data: EMPTY,
-		// Not clustered. Clustering replaced the map with a few dozen large
-		// discs and hid the thing worth looking at — that the museums themselves
-		// trace the cities. Forty thousand small circles is a shape the GPU
-		// draws in one pass and a person reads at a glance.
+		// Not clustered here. Client-side clustering replaced the map with a
+		// few dozen large discs and hid the thing worth looking at — that the
+		// museums themselves trace the cities. The server groups a dense view
+		// into a fine grid instead, and each group is drawn as a dot a little
+		// larger for what it stands for, so the cities still show through.
 	});
 	map.addSource("venues", { type: "geojson", data: EMPTY });
 
@@ -220,8 +225,12 @@ export function build({ onPick, onPickVenue }) {
 			"circle-color": ["interpolate", ["linear"], ["zoom"], 2, "#ffc477", 8, "#ffb454"],
 			// Exponential rather than linear: apparent size should track the
 			// geometric nature of zoom, or the middle zooms look lumpy.
+			//
+			// A cluster grows with the log of its size, so a capital reads as
+			// heavier than a village without swallowing the country around it.
 			"circle-radius": ["interpolate", ["exponential", 1.35], ["zoom"],
-				1.4, 0.8, 4, 1.9, 8, 3.6, 12, 5.5, 16, 8],
+				1.4, ["*", 0.8, CLUSTER_SCALE], 4, ["*", 1.9, CLUSTER_SCALE],
+				8, ["*", 3.6, CLUSTER_SCALE], 12, 5.5, 16, 8],
 			// A hair of blur below zoom 6 stops sub-pixel dots aliasing into
 			// squares, and is what lets overlap read as density rather than mush.
 			"circle-blur": ["interpolate", ["linear"], ["zoom"], 1.4, 0.35, 6, 0.15, 10, 0],
@@ -425,7 +434,7 @@ export async function loadPoints() {
 	const controller = new AbortController();
 	inFlight = controller;
 
-	const result = await api.points(box, controller.signal);
+	const result = await api.points(box, map.getZoom(), controller.signal);
 	if (controller !== inFlight) return;
 	inFlight = null;
 
@@ -437,18 +446,27 @@ export async function loadPoints() {
 	const source = map.getSource("museums");
 	if (!source) return;
 
-	source.setData({
-		type: "FeatureCollection",
-		features: result.data.points.map(p => ({
+	// A cluster is drawn at the centre of its museums and picks as its most
+	// prominent one, so clicking a group still opens something rather than
+	// nothing.
+	const features = result.data.clustered
+		? result.data.clusters.map(c => ({
+			type: "Feature",
+			geometry: { type: "Point", coordinates: [c.lon, c.lat] },
+			properties: { id: c.museum.id, museums: c.museums, exhibitions: c.exhibitions },
+		}))
+		: result.data.points.map(p => ({
 			type: "Feature",
 			geometry: { type: "Point", coordinates: [p[2], p[1]] },
 			properties: { id: p[0] },
-		})),
-	});
+		}));
+	source.setData({ type: "FeatureCollection", features });
 
 	lastBox = box;
-	lastTruncated = Boolean(result.data.truncated);
-	hud.museums(result.data.count, result.data.truncated);
+	// A clustered answer is as incomplete as a truncated one: the grid it was
+	// grouped on belongs to this zoom, and a closer look separates it.
+	lastTruncated = Boolean(result.data.truncated || result.data.clustered);
+	hud.museums(result.data.museums ?? result.data.count, result.data.truncated, result.data.clustered);
 }
 
 /* ---- venues with something on show -------------------------------------- */
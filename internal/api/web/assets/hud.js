@@ -23,11 +23,11 @@ export function say(message) {
 	live.textContent = message;
 }
 
-export function museums(total, truncated) {
-	clear(count).append(
-		el("b", { text: total.toLocaleString() }),
-		truncated ? " museums — the most notable here; zoom in for the rest" : " museums shown",
-	);
+export function museums(total, truncated, clustered) {
+	let note = " museums shown";
+	if (clustered) note = " museums — grouped at this scale; zoom in to separate them";
+	else if (truncated) note = " museums — the most notable here; zoom in for the rest";
+	clear(count).append(el("b", { text: total.toLocaleString() }), note);
 }
 
 // failed replaces the count with something that says so and offers the retry.
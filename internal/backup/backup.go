@@ -0,0 +1,228 @@
+// Package backup takes and restores whole-catalogue backups: the database's
+// tables and the object store's museum records, together, under one manifest.
+//
+// The two used to be backed up apart, if at all. A pg_dump ran on a loop into
+// a directory, and the bucket was left to a tar of the MinIO volume somebody
+// might remember to take. Restoring meant pairing a dump with a bucket from
+// some other moment, and the pair disagreed: museums the database had merged
+// reappeared at the next reindex, and enrichment the bucket held was missing
+// from the tables.
+//
+// A backup is a directory in the same bucket, backups/<id>/:
+//
+//	manifest.json                 what follows, with a checksum of each part
+//	tables/<table>.copy.gz        one table's rows, in COPY's text format
+//	objects/<prefix>.tar.gz       every object under one prefix, by key
+//
+// The manifest is written last, so a backup without one was interrupted and
+// is never offered for restore. The tables are copied in one repeatable-read
+// transaction and agree with one another exactly; the objects are read after
+// them, and the manifest records when.
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"museum/internal/postgres"
+)
+
+// Prefix is where backups are kept in the bucket.
+const Prefix = "backups/"
+
+// format is the layout this package writes. A manifest in a layout it does not
+// know is refused rather than half understood.
+const format = 1
+
+// idLayout names a backup after the moment it started: sortable, and free of
+// the colons some tools mangle in a key.
+const idLayout = "20060102T150405Z"
+
+// ErrNotFound reports a backup that does not exist, or never finished.
+var ErrNotFound = errors.New("no such backup")
+
+// Bucket is the object store a backup reads from and is written to.
+type Bucket interface {
+	// Put writes r at key; a size of -1 means the length is not known.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Open reads the object at key, wrapping storage.ErrNotFound when there
+	// is none.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// ETag is the hex MD5 of an object written in one part.
+	ETag(ctx context.Context, key string) (string, error)
+	// List calls fn with every key under prefix.
+	List(ctx context.Context, prefix string, fn func(key string) error) error
+	Remove(ctx context.Context, key string) error
+}
+
+// Manifest describes one complete backup.
+type Manifest struct {
+	Format int    `json:"format"`
+	ID     string `json:"id"`
+	// Label names the run that took it, e.g. "backup 2026-03-01T02:00:00Z".
+	Label      string    `json:"label,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	// SchemaVersion is the migration the tables were at.
+	SchemaVersion int `json:"schema_version"`
+
+	// Tables are in the order they are restored in.
+	Tables  []Part `json:"tables"`
+	Objects []Part `json:"objects,omitempty"`
+}
+
+// Part is one file of a backup.
+type Part struct {
+	// Name is the table, or the object prefix.
+	Name string `json:"name"`
+	// Columns are a table's, in the order its rows hold them.
+	Columns []string `json:"columns,omitempty"`
+	Key     string   `json:"key"`
+	// Count is the rows in a table, or the objects under a prefix.
+	Count int64 `json:"count"`
+	// Bytes and SHA256 are of the part as stored, compressed.
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256"`
+}
+
+// Bytes is the size of the whole backup as stored.
+func (m Manifest) Bytes() int64 {
+	var total int64
+	for _, p := range slices.Concat(m.Tables, m.Objects) {
+		total += p.Bytes
+	}
+	return total
+}
+
+// Rows is how many rows the backup's tables hold.
+func (m Manifest) Rows() int64 {
+	var total int64
+	for _, p := range m.Tables {
+		total += p.Count
+	}
+	return total
+}
+
+// table is a table part as the database takes it.
+func (p Part) table() postgres.Table { return postgres.Table{Name: p.Name, Columns: p.Columns} }
+
+// idAt names a backup started at t.
+func idAt(t time.Time) string { return t.UTC().Format(idLayout) }
+
+// startedAt is when the backup named id started.
+func startedAt(id string) (time.Time, bool) {
+	t, err := time.Parse(idLayout, id)
+	return t, err == nil
+}
+
+func manifestKey(id string) string { return Prefix + id + "/manifest.json" }
+
+func tableKey(id, table string) string { return Prefix + id + "/tables/" + table + ".copy.gz" }
+
+func objectsKey(id, prefix string) string {
+	return Prefix + id + "/objects/" + strings.Trim(prefix, "/") + ".tar.gz"
+}
+
+// List returns every complete backup, newest first.
+func List(ctx context.Context, bucket Bucket) ([]Manifest, error) {
+	dirs, err := listDirs(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifests []Manifest
+	for id, keys := range dirs {
+		if !slices.Contains(keys, manifestKey(id)) {
+			continue
+		}
+		m, err := Load(ctx, bucket, id)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, m)
+	}
+	slices.SortFunc(manifests, func(a, b Manifest) int { return strings.Compare(b.ID, a.ID) })
+	return manifests, nil
+}
+
+// listDirs lists the keys under Prefix by the backup they belong to.
+func listDirs(ctx context.Context, bucket Bucket) (map[string][]string, error) {
+	dirs := map[string][]string{}
+	err := bucket.List(ctx, Prefix, func(key string) error {
+		id, _, ok := strings.Cut(strings.TrimPrefix(key, Prefix), "/")
+		if ok {
+			dirs[id] = append(dirs[id], key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list backups: %w", err)
+	}
+	return dirs, nil
+}
+
+// Load reads a backup's manifest. "latest" names the newest complete backup.
+func Load(ctx context.Context, bucket Bucket, id string) (Manifest, error) {
+	if id == "latest" {
+		all, err := List(ctx, bucket)
+		if err != nil {
+			return Manifest{}, err
+		}
+		if len(all) == 0 {
+			return Manifest{}, fmt.Errorf("%w: there are none in %s", ErrNotFound, Prefix)
+		}
+		return all[0], nil
+	}
+
+	r, err := bucket.Open(ctx, manifestKey(id))
+	if err != nil {
+		return Manifest{}, fmt.Errorf("%w %s: %w", ErrNotFound, id, err)
+	}
+	defer r.Close()
+
+	var m Manifest
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return Manifest{}, fmt.Errorf("backup %s: manifest: %w", id, err)
+	}
+	if m.Format != format {
+		return Manifest{}, fmt.Errorf("backup %s is in format %d, and this binary reads only %d", id, m.Format, format)
+	}
+	return m, nil
+}
+
+// saveManifest writes the manifest, completing the backup.
+func saveManifest(ctx context.Context, bucket Bucket, m Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return bucket.Put(ctx, manifestKey(m.ID), bytes.NewReader(data), int64(len(data)))
+}
+
+// remove deletes the keys of one backup, the manifest first: a backup
+// interrupted while being deleted is then incomplete rather than offered for
+// restore with parts missing.
+func remove(ctx context.Context, bucket Bucket, id string, keys []string) error {
+	slices.SortStableFunc(keys, func(a, b string) int {
+		switch {
+		case a == manifestKey(id):
+			return -1
+		case b == manifestKey(id):
+			return 1
+		}
+		return 0
+	})
+	for _, key := range keys {
+		if err := bucket.Remove(ctx, key); err != nil {
+			return fmt.Errorf("delete backup %s: %w", id, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"museum/internal/storage"
+)
+
+// memoryBucket is a bucket in a map, with S3's ETags.
+type memoryBucket struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	puts    int
+}
+
+func newMemoryBucket() *memoryBucket { return &memoryBucket{objects: map[string][]byte{}} }
+
+func (b *memoryBucket) Put(_ context.Context, key string, r io.Reader, _ int64) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.objects[key] = data
+	b.puts++
+	return nil
+}
+
+func (b *memoryBucket) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	data, ok := b.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", key, storage.ErrNotFound)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (b *memoryBucket) ETag(_ context.Context, key string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	data, ok := b.objects[key]
+	if !ok {
+		return "", fmt.Errorf("%s: %w", key, storage.ErrNotFound)
+	}
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (b *memoryBucket) List(_ context.Context, prefix string, fn func(string) error) error {
+	b.mu.Lock()
+	keys := slices.Sorted(maps.Keys(b.objects))
+	b.mu.Unlock()
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			if err := fn(key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (b *memoryBucket) Remove(_ context.Context, key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.objects, key)
+	return nil
+}
+
+// objectsBackup backs up the bucket's raw_data/ as Take would, and returns
+// the manifest, with no tables.
+func objectsBackup(t *testing.T, bucket *memoryBucket, at time.Time) Manifest {
+	t.Helper()
+	ctx := context.Background()
+	m := Manifest{Format: format, ID: idAt(at), StartedAt: at}
+	part, err := writePart(ctx, bucket, objectsKey(m.ID, "raw_data"), func(w io.Writer) (int64, error) {
+		return archive(ctx, bucket, "raw_data", w)
+	})
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	part.Name = "raw_data"
+	m.Objects = []Part{part}
+	if err := saveManifest(ctx, bucket, m); err != nil {
+		t.Fatalf("manifest: %v", err)
+	}
+	return m
+}
+
+func TestRestoreObjects(t *testing.T) {
+	ctx := context.Background()
+	bucket := newMemoryBucket()
+	for i := range 40 {
+		bucket.objects[fmt.Sprintf("raw_data/fr/museum-%02d.json", i)] = []byte(fmt.Sprintf(`{"name":"Museum %d"}`, i))
+	}
+	bucket.objects["enriched_data/fr/museum-00.json"] = []byte(`{}`)
+
+	m := objectsBackup(t, bucket, time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC))
+	if m.Objects[0].Count != 40 {
+		t.Fatalf("archived %d objects, want the 40 under raw_data/", m.Objects[0].Count)
+	}
+	if err := Check(ctx, bucket, m); err != nil {
+		t.Fatalf("check a sound backup: %v", err)
+	}
+
+	// The catalogue moves on: one museum changes, one is lost, one is new.
+	bucket.objects["raw_data/fr/museum-03.json"] = []byte(`{"name":"Renamed"}`)
+	delete(bucket.objects, "raw_data/fr/museum-07.json")
+	bucket.objects["raw_data/fr/museum-99.json"] = []byte(`{"name":"New"}`)
+	bucket.puts = 0
+
+	done, err := RestoreObjects(ctx, bucket, m, false)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if done.Written != 2 || done.Unchanged != 38 || done.Removed != 0 {
+		t.Errorf("restore = %+v, want 2 written, 38 unchanged, none removed", done)
+	}
+	// Rewriting the unchanged ones would send them all back to the enricher.
+	if bucket.puts != 2 {
+		t.Errorf("%d objects rewritten, want only the 2 that differed", bucket.puts)
+	}
+	if got := string(bucket.objects["raw_data/fr/museum-03.json"]); got != `{"name":"Museum 3"}` {
+		t.Errorf("museum-03 = %s, want it as backed up", got)
+	}
+	if _, ok := bucket.objects["raw_data/fr/museum-99.json"]; !ok {
+		t.Error("an object the backup does not hold was removed without -prune")
+	}
+
+	done, err = RestoreObjects(ctx, bucket, m, true)
+	if err != nil {
+		t.Fatalf("restore with prune: %v", err)
+	}
+	if done.Written != 0 || done.Removed != 1 {
+		t.Errorf("restore with prune = %+v, want nothing written and 1 removed", done)
+	}
+	if _, ok := bucket.objects["raw_data/fr/museum-99.json"]; ok {
+		t.Error("prune left an object the backup does not hold")
+	}
+	if _, ok := bucket.objects["enriched_data/fr/museum-00.json"]; !ok {
+		t.Error("prune reached outside the prefixes backed up")
+	}
+}
+
+func TestCorruptPartsAreRefused(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name   string
+		damage func(b *memoryBucket, key string)
+	}{
+		{"flipped byte", func(b *memoryBucket, key string) {
+			b.objects[key][len(b.objects[key])/2] ^= 0xff
+		}},
+		{"truncated", func(b *memoryBucket, key string) {
+			b.objects[key] = b.objects[key][:len(b.objects[key])-10]
+		}},
+		// Still a valid gzip stream, so only the checksum notices.
+		{"trailing bytes", func(b *memoryBucket, key string) {
+			b.objects[key] = append(b.objects[key], 0, 0, 0, 0)
+		}},
+		{"missing", func(b *memoryBucket, key string) { delete(b.objects, key) }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			bucket := newMemoryBucket()
+			for i := range 5 {
+				bucket.objects[fmt.Sprintf("raw_data/nl/m%d.json", i)] = bytes.Repeat([]byte{byte('a' + i)}, 200)
+			}
+			m := objectsBackup(t, bucket, time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC))
+			tc.damage(bucket, m.Objects[0].Key)
+
+			if err := Check(ctx, bucket, m); !errors.Is(err, ErrCorrupt) {
+				t.Errorf("Check = %v, want ErrCorrupt", err)
+			}
+			if _, err := RestoreObjects(ctx, bucket, m, true); err == nil {
+				t.Error("restored from a damaged backup")
+			}
+		})
+	}
+}
+
+func TestLoadLatestIgnoresUnfinishedBackups(t *testing.T) {
+	ctx := context.Background()
+	bucket := newMemoryBucket()
+	bucket.objects["raw_data/x.json"] = []byte(`{}`)
+	older := objectsBackup(t, bucket, time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC))
+	// A later backup that never wrote its manifest.
+	bucket.objects[tableKey(idAt(time.Date(2026, 3, 2, 2, 0, 0, 0, time.UTC)), "museums")] = []byte("partial")
+
+	m, err := Load(ctx, bucket, "latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ID != older.ID {
+		t.Errorf("latest = %s, want %s, the newest that finished", m.ID, older.ID)
+	}
+	if _, err := Load(ctx, bucket, "20260302T020000Z"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Load(unfinished) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestPolicySplit(t *testing.T) {
+	// A backup every 12 hours for 60 days, newest first.
+	newest := time.Date(2026, 3, 31, 14, 0, 0, 0, time.UTC)
+	var backups []Manifest
+	for i := range 120 {
+		at := newest.Add(-time.Duration(i) * 12 * time.Hour)
+		backups = append(backups, Manifest{ID: idAt(at), StartedAt: at})
+	}
+	ids := func(ms []Manifest) []string {
+		var out []string
+		for _, m := range ms {
+			out = append(out, m.ID)
+		}
+		return out
+	}
+
+	keep, drop := Policy{Last: 3}.Split(backups)
+	if want := ids(backups[:3]); !slices.Equal(ids(keep), want) || len(drop) != 117 {
+		t.Errorf("keep-last 3 kept %v", ids(keep))
+	}
+
+	// Three days: the newest of the 31st, 30th and 29th.
+	keep, _ = Policy{Daily: 3}.Split(backups)
+	if want := []string{"20260331T140000Z", "20260330T140000Z", "20260329T140000Z"}; !slices.Equal(ids(keep), want) {
+		t.Errorf("keep-daily 3 kept %v, want %v", ids(keep), want)
+	}
+
+	// Every month there is; the series starts on 31 January.
+	keep, _ = Policy{Monthly: 5}.Split(backups)
+	if want := []string{"20260331T140000Z", "20260228T140000Z", "20260131T140000Z"}; !slices.Equal(ids(keep), want) {
+		t.Errorf("keep-monthly 5 kept %v, want %v", ids(keep), want)
+	}
+
+	// The rules add up, and a backup two rules keep is kept once.
+	keep, _ = Policy{Last: 2, Daily: 2, Weekly: 2}.Split(backups)
+	if want := []string{"20260331T140000Z", "20260331T020000Z", "20260330T140000Z", "20260329T140000Z"}; !slices.Equal(ids(keep), want) {
+		t.Errorf("combined policy kept %v, want %v", ids(keep), want)
+	}
+
+	if keep, drop := (Policy{}).Split(backups); len(keep) != len(backups) || len(drop) != 0 {
+		t.Errorf("an empty policy kept %d and dropped %d; it must keep everything", len(keep), len(drop))
+	}
+}
+
+func TestPrune(t *testing.T) {
+	ctx := context.Background()
+	bucket := newMemoryBucket()
+	bucket.objects["raw_data/x.json"] = []byte(`{}`)
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	for day := 1; day <= 5; day++ {
+		objectsBackup(t, bucket, time.Date(2026, 3, day, 2, 0, 0, 0, time.UTC))
+	}
+	abandoned := idAt(now.Add(-48 * time.Hour))
+	running := idAt(now.Add(-time.Hour))
+	bucket.objects[tableKey(abandoned, "museums")] = []byte("partial")
+	bucket.objects[tableKey(running, "museums")] = []byte("partial")
+
+	deleted, err := Prune(ctx, bucket, Policy{Last: 2}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(deleted)
+	if want := []string{"20260301T020000Z", "20260302T020000Z", "20260303T020000Z", abandoned}; !slices.Equal(deleted, want) {
+		t.Errorf("pruned %v, want %v", deleted, want)
+	}
+
+	left, _ := List(ctx, bucket)
+	if len(left) != 2 || left[0].ID != "20260305T020000Z" {
+		t.Errorf("left %d backups, newest %v", len(left), left)
+	}
+	if _, ok := bucket.objects[tableKey(running, "museums")]; !ok {
+		t.Error("pruned a backup that may still be being written")
+	}
+	for key := range bucket.objects {
+		if strings.Contains(key, "20260301") {
+			t.Errorf("%s survived its backup being pruned", key)
+		}
+	}
+}
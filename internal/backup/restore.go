@@ -0,0 +1,331 @@
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"museum/internal/postgres"
+	"museum/internal/storage"
+)
+
+// ErrCorrupt reports a part that is not what the manifest says it is.
+var ErrCorrupt = errors.New("backup is corrupt")
+
+// RestoreTables fills db, which must hold no catalogue, from the backup's
+// tables. Each part is checked against its checksum as it is read, and a part
+// that fails leaves the database as it was.
+func RestoreTables(ctx context.Context, db *postgres.Store, bucket Bucket, m Manifest) (int64, error) {
+	tables := make([]postgres.Table, len(m.Tables))
+	parts := map[string]Part{}
+	for i, p := range m.Tables {
+		tables[i] = p.table()
+		parts[p.Name] = p
+	}
+
+	rows, err := db.Restore(ctx, m.SchemaVersion, tables, func(t postgres.Table) (io.ReadCloser, error) {
+		return openPart(ctx, bucket, parts[t.Name])
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, p := range m.Tables {
+		if rows[p.Name] != p.Count {
+			return total, fmt.Errorf("%w: %s restored %d rows, and the manifest says %d",
+				ErrCorrupt, p.Name, rows[p.Name], p.Count)
+		}
+		total += p.Count
+	}
+	return total, nil
+}
+
+// ObjectsRestored is what restoring a backup's objects did.
+type ObjectsRestored struct {
+	// Written were missing from the bucket or differed from the backup.
+	Written int64
+	// Unchanged were already in the bucket as the backup has them.
+	Unchanged int64
+	// Removed were in the bucket and not in the backup.
+	Removed int64
+}
+
+// RestoreObjects puts the backup's objects back in the bucket.
+//
+// An object already there as the backup has it is left alone. Writing under
+// raw_data/ announces the object to the enricher, and rewriting every museum
+// in a live bucket would send the whole catalogue back through geocoding.
+//
+// With prune, objects under the backed-up prefixes that the backup does not
+// hold are deleted, leaving the bucket as it was when the backup was taken.
+func RestoreObjects(ctx context.Context, bucket Bucket, m Manifest, prune bool) (ObjectsRestored, error) {
+	var done ObjectsRestored
+	for _, p := range m.Objects {
+		// Read through once before writing anything. The archive's end comes
+		// before the checksum can be confirmed, and a damaged one found only
+		// then would have written half its objects.
+		if n, err := countIn(ctx, bucket, p, countEntries); err != nil {
+			return done, err
+		} else if n != p.Count {
+			return done, fmt.Errorf("%w: %s holds %d objects, and the manifest says %d", ErrCorrupt, p.Key, n, p.Count)
+		}
+		kept, err := unpack(ctx, bucket, p, &done)
+		if err != nil {
+			return done, err
+		}
+		if !prune {
+			continue
+		}
+		var extra []string
+		if err := bucket.List(ctx, p.Name+"/", func(key string) error {
+			if !kept[key] {
+				extra = append(extra, key)
+			}
+			return nil
+		}); err != nil {
+			return done, err
+		}
+		for _, key := range extra {
+			if err := bucket.Remove(ctx, key); err != nil {
+				return done, err
+			}
+			done.Removed++
+		}
+	}
+	return done, nil
+}
+
+// unpack writes one archive's objects, returning the keys it held.
+func unpack(ctx context.Context, bucket Bucket, p Part, done *ObjectsRestored) (map[string]bool, error) {
+	r, err := openPart(ctx, bucket, p)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		slots    = make(chan struct{}, fetchConcurrency)
+		firstErr error
+		errOnce  sync.Once
+		written  atomic.Int64
+		same     atomic.Int64
+		kept     = map[string]bool{}
+	)
+	fail := func(err error) {
+		errOnce.Do(func() { firstErr = err; cancel() })
+	}
+
+	tr := tar.NewReader(r)
+	for ctx.Err() == nil {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fail(fmt.Errorf("%s: %w", p.Key, err))
+			break
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			fail(fmt.Errorf("%s: %w", p.Key, err))
+			break
+		}
+		kept[header.Name] = true
+
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(key string, data []byte) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			sum := md5.Sum(data)
+			switch etag, err := bucket.ETag(ctx, key); {
+			case err == nil && etag == hex.EncodeToString(sum[:]):
+				same.Add(1)
+				return
+			case err != nil && !errors.Is(err, storage.ErrNotFound):
+				fail(err)
+				return
+			}
+			if err := bucket.Put(ctx, key, bytes.NewReader(data), int64(len(data))); err != nil {
+				fail(err)
+				return
+			}
+			written.Add(1)
+		}(header.Name, data)
+	}
+	wg.Wait()
+
+	done.Written += written.Load()
+	done.Unchanged += same.Load()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if n := int64(len(kept)); n != p.Count {
+		return nil, fmt.Errorf("%w: %s holds %d objects, and the manifest says %d", ErrCorrupt, p.Key, n, p.Count)
+	}
+	return kept, nil
+}
+
+// Check reads every part of the backup and compares it with the manifest:
+// its size and checksum as stored, and the rows or objects it holds.
+func Check(ctx context.Context, bucket Bucket, m Manifest) error {
+	for _, p := range m.Tables {
+		rows, err := countIn(ctx, bucket, p, countLines)
+		if err != nil {
+			return err
+		}
+		if rows != p.Count {
+			return fmt.Errorf("%w: %s holds %d rows, and the manifest says %d", ErrCorrupt, p.Key, rows, p.Count)
+		}
+	}
+	for _, p := range m.Objects {
+		objects, err := countIn(ctx, bucket, p, countEntries)
+		if err != nil {
+			return err
+		}
+		if objects != p.Count {
+			return fmt.Errorf("%w: %s holds %d objects, and the manifest says %d", ErrCorrupt, p.Key, objects, p.Count)
+		}
+	}
+	return nil
+}
+
+// countIn reads one part through, counting what it holds.
+func countIn(ctx context.Context, bucket Bucket, p Part, count func(io.Reader) (int64, error)) (int64, error) {
+	r, err := openPart(ctx, bucket, p)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	n, err := count(r)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", p.Key, err)
+	}
+	// Whatever the counter left unread still has to be checksummed.
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return 0, fmt.Errorf("%s: %w", p.Key, err)
+	}
+	return n, nil
+}
+
+// countLines counts COPY rows, which are one to a line: a newline inside a
+// value is written as \n.
+func countLines(r io.Reader) (int64, error) {
+	var n int64
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), 64<<20)
+	for scanner.Scan() {
+		n++
+	}
+	return n, scanner.Err()
+}
+
+// countEntries counts the files in a tar.
+func countEntries(r io.Reader) (int64, error) {
+	var n int64
+	tr := tar.NewReader(r)
+	for {
+		_, err := tr.Next()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+// openPart opens a part decompressed, checking it against the manifest as it
+// is read: the read that reaches the end fails if what was stored is not what
+// was written.
+func openPart(ctx context.Context, bucket Bucket, p Part) (io.ReadCloser, error) {
+	stored, err := bucket.Open(ctx, p.Key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s is missing: %w", ErrCorrupt, p.Key, err)
+	}
+	checked := &checkedReader{r: stored, part: p, digest: sha256.New()}
+	gz, err := gzip.NewReader(checked)
+	if err != nil {
+		stored.Close()
+		return nil, fmt.Errorf("%w: %s: %w", ErrCorrupt, p.Key, err)
+	}
+	return &partReader{Reader: gz, gz: gz, stored: stored, checked: checked}, nil
+}
+
+// partReader is a part being read, decompressed.
+type partReader struct {
+	io.Reader
+	gz      *gzip.Reader
+	stored  io.Closer
+	checked *checkedReader
+}
+
+// Read reads until the end of the compressed stream, and then, because gzip
+// stops at its trailer without reading on, checks that nothing follows it.
+// A stream that fails to decompress is read to its end as well, so damage is
+// reported as the checksum mismatch it is rather than as a flate error.
+func (r *partReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err != nil && !errors.Is(err, ErrCorrupt) {
+		if _, drainErr := io.Copy(io.Discard, r.checked); drainErr != nil {
+			return n, drainErr
+		}
+	}
+	return n, err
+}
+
+func (r *partReader) Close() error {
+	r.gz.Close()
+	return r.stored.Close()
+}
+
+// checkedReader passes a part's stored bytes through, and at their end
+// fails unless they match the manifest.
+type checkedReader struct {
+	r      io.Reader
+	part   Part
+	digest hash.Hash
+	n      int64
+}
+
+func (c *checkedReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.digest.Write(p[:n])
+	c.n += int64(n)
+	if err == io.EOF {
+		sum := hex.EncodeToString(c.digest.Sum(nil))
+		if c.n != c.part.Bytes || !strings.EqualFold(sum, c.part.SHA256) {
+			return n, fmt.Errorf("%w: %s is %d bytes with checksum %s, and the manifest says %d bytes with %s",
+				ErrCorrupt, c.part.Key, c.n, sum[:12], c.part.Bytes, c.part.SHA256[:min(12, len(c.part.SHA256))])
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,114 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Policy says which backups to keep: the newest Last, and the newest in each
+// of the latest Daily days, Weekly weeks and Monthly months that have one. A
+// backup any rule keeps is kept.
+//
+// Counting days that have a backup, rather than the days back from today,
+// means a week with the backups failing does not quietly age out everything
+// taken before it.
+type Policy struct {
+	Last    int
+	Daily   int
+	Weekly  int
+	Monthly int
+}
+
+// keepsAll reports a policy with no rules, which keeps everything rather
+// than nothing.
+func (p Policy) keepsAll() bool {
+	return p.Last <= 0 && p.Daily <= 0 && p.Weekly <= 0 && p.Monthly <= 0
+}
+
+// Split divides backups, newest first, into those the policy keeps and those
+// it does not. The newest is always kept.
+func (p Policy) Split(backups []Manifest) (keep, drop []Manifest) {
+	if p.keepsAll() {
+		return backups, nil
+	}
+
+	type rule struct {
+		limit  int
+		period func(time.Time) string
+		seen   map[string]bool
+	}
+	rules := []*rule{
+		{limit: p.Last, period: func(t time.Time) string { return t.Format(time.RFC3339Nano) }},
+		{limit: p.Daily, period: func(t time.Time) string { return t.Format(time.DateOnly) }},
+		{limit: p.Weekly, period: func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{limit: p.Monthly, period: func(t time.Time) string { return t.Format("2006-01") }},
+	}
+	for _, r := range rules {
+		r.seen = map[string]bool{}
+	}
+
+	for i, b := range backups {
+		kept := i == 0
+		at := b.StartedAt.UTC()
+		for _, r := range rules {
+			period := r.period(at)
+			if r.seen[period] || len(r.seen) >= r.limit {
+				continue
+			}
+			r.seen[period] = true
+			kept = true
+		}
+		if kept {
+			keep = append(keep, b)
+		} else {
+			drop = append(drop, b)
+		}
+	}
+	return keep, drop
+}
+
+// abandonedAfter is how old a backup without a manifest must be before Prune
+// takes it for one that was interrupted, rather than one still being written.
+const abandonedAfter = 24 * time.Hour
+
+// Prune deletes the backups the policy does not keep, and what is left of
+// interrupted ones, returning the ids deleted.
+func Prune(ctx context.Context, bucket Bucket, policy Policy, now time.Time) ([]string, error) {
+	dirs, err := listDirs(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	all, err := List(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	_, drop := policy.Split(all)
+
+	var deleted []string
+	for _, m := range drop {
+		if err := remove(ctx, bucket, m.ID, dirs[m.ID]); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, m.ID)
+	}
+
+	complete := map[string]bool{}
+	for _, m := range all {
+		complete[m.ID] = true
+	}
+	for id, keys := range dirs {
+		started, ok := startedAt(id)
+		if complete[id] || !ok || now.Sub(started) < abandonedAfter {
+			continue
+		}
+		if err := remove(ctx, bucket, id, keys); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, id)
+	}
+	return deleted, nil
+}
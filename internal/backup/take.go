@@ -0,0 +1,220 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"time"
+
+	"museum/internal/postgres"
+	"museum/internal/storage"
+)
+
+// fetchConcurrency bounds the objects read at once while archiving. A prefix
+// is tens of thousands of small objects, and read one at a time the archive
+// is all round trips.
+const fetchConcurrency = 16
+
+// Take backs up the database's tables and every object under prefixes, and
+// returns the manifest of the backup once it is complete.
+func Take(ctx context.Context, db *postgres.Store, bucket Bucket, prefixes []string, label string) (Manifest, error) {
+	started := time.Now().UTC().Truncate(time.Second)
+	m := Manifest{Format: format, ID: idAt(started), Label: label, StartedAt: started}
+
+	if _, err := Load(ctx, bucket, m.ID); err == nil {
+		return Manifest{}, fmt.Errorf("backup %s already exists", m.ID)
+	}
+
+	err := m.takeTables(ctx, db, bucket)
+	for _, prefix := range prefixes {
+		if err != nil {
+			break
+		}
+		var part Part
+		part, err = writePart(ctx, bucket, objectsKey(m.ID, prefix), func(w io.Writer) (int64, error) {
+			return archive(ctx, bucket, prefix, w)
+		})
+		part.Name = prefix
+		m.Objects = append(m.Objects, part)
+	}
+	if err == nil {
+		m.FinishedAt = time.Now().UTC()
+		err = saveManifest(ctx, bucket, m)
+	}
+	if err != nil {
+		// What was written is no use without its manifest. Clear it now
+		// rather than leave it for Prune, which would wait a day to be sure it
+		// is not a backup still running.
+		if dirs, listErr := listDirs(context.WithoutCancel(ctx), bucket); listErr == nil {
+			remove(context.WithoutCancel(ctx), bucket, m.ID, dirs[m.ID])
+		}
+		return Manifest{}, fmt.Errorf("backup %s: %w", m.ID, err)
+	}
+	return m, nil
+}
+
+// takeTables copies every table, from one snapshot.
+func (m *Manifest) takeTables(ctx context.Context, db *postgres.Store, bucket Bucket) error {
+	snapshot, err := db.Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	defer snapshot.Close(ctx)
+
+	m.SchemaVersion = snapshot.Version
+	for _, table := range snapshot.Tables {
+		part, err := writePart(ctx, bucket, tableKey(m.ID, table.Name), func(w io.Writer) (int64, error) {
+			gz := gzip.NewWriter(w)
+			rows, err := snapshot.CopyOut(ctx, table, gz)
+			if err != nil {
+				return 0, err
+			}
+			return rows, gz.Close()
+		})
+		if err != nil {
+			return err
+		}
+		part.Name, part.Columns = table.Name, table.Columns
+		m.Tables = append(m.Tables, part)
+	}
+	return nil
+}
+
+// writePart streams what fill writes to key, measuring and checksumming it on
+// the way. fill returns the count the part records.
+func writePart(ctx context.Context, bucket Bucket, key string, fill func(io.Writer) (int64, error)) (Part, error) {
+	pr, pw := io.Pipe()
+	uploaded := make(chan error, 1)
+	go func() {
+		err := bucket.Put(ctx, key, pr, -1)
+		// Unblocks fill if the upload gave up before reading everything.
+		pr.CloseWithError(cmpErr(err, errors.New("upload stopped early")))
+		uploaded <- err
+	}()
+
+	digest := sha256.New()
+	size := &counter{}
+	count, err := fill(io.MultiWriter(pw, digest, size))
+	pw.CloseWithError(err)
+	if uploadErr := <-uploaded; err == nil {
+		err = uploadErr
+	}
+	if err != nil {
+		return Part{}, err
+	}
+	return Part{Key: key, Count: count, Bytes: size.n, SHA256: hex.EncodeToString(digest.Sum(nil))}, nil
+}
+
+// cmpErr returns err, or fallback when err is nil.
+func cmpErr(err, fallback error) error {
+	if err != nil {
+		return err
+	}
+	return fallback
+}
+
+// counter counts the bytes written through it.
+type counter struct{ n int64 }
+
+func (c *counter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+// fetched is one object read for the archive.
+type fetched struct {
+	key  string
+	data []byte
+	err  error
+}
+
+// archive writes every object under prefix to w as a gzipped tar, by key in
+// order, and returns how many it wrote. An object deleted between the listing
+// and the read is left out.
+func archive(ctx context.Context, bucket Bucket, prefix string, w io.Writer) (int64, error) {
+	var keys []string
+	if err := bucket.List(ctx, prefix+"/", func(key string) error {
+		keys = append(keys, key)
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	slices.Sort(keys)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Reads run ahead of the writer, at most fetchConcurrency of them, and
+	// are written in the order they were started.
+	pending := make(chan chan fetched, fetchConcurrency)
+	go func() {
+		defer close(pending)
+		for _, key := range keys {
+			result := make(chan fetched, 1)
+			select {
+			case pending <- result:
+			case <-ctx.Done():
+				return
+			}
+			go func() { result <- read(ctx, bucket, key) }()
+		}
+	}()
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	var written, vanished int64
+	for result := range pending {
+		object := <-result
+		switch {
+		case errors.Is(object.err, storage.ErrNotFound):
+			vanished++
+			continue
+		case object.err != nil:
+			return written, object.err
+		}
+		header := &tar.Header{
+			Name: object.key, Mode: 0o644, Size: int64(len(object.data)),
+			Typeflag: tar.TypeReg, Format: tar.FormatPAX,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return written, err
+		}
+		if _, err := tw.Write(object.data); err != nil {
+			return written, err
+		}
+		written++
+	}
+	if err := ctx.Err(); err != nil {
+		return written, err
+	}
+	if err := tw.Close(); err != nil {
+		return written, err
+	}
+	if vanished > 0 {
+		log.Printf("%d objects under %s were deleted while being backed up", vanished, prefix)
+	}
+	return written, gz.Close()
+}
+
+// read fetches one object whole.
+func read(ctx context.Context, bucket Bucket, key string) fetched {
+	r, err := bucket.Open(ctx, key)
+	if err != nil {
+		return fetched{key: key, err: err}
+	}
+	defer r.Close()
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r); err != nil {
+		return fetched{key: key, err: fmt.Errorf("read %s: %w", key, err)}
+	}
+	return fetched{key: key, data: buf.Bytes()}
+}
@@ -0,0 +1,352 @@
+// Package catalogtest is the behaviour every implementation of api.Catalogue
+// must share, written once and run against each of them.
+//
+// The API is served from Postgres and from an exported SQLite file, and the two
+// answer the same requests with different engines: PostGIS against an R-tree,
+// pg_trgm against FTS5. A handler test with a fake catalogue says nothing about
+// whether they agree. These tests load the same museums and exhibitions into
+// each and ask the same questions, so a difference in ordering, paging or
+// filtering fails here rather than on a kiosk.
+package catalogtest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"museum/internal/api"
+	"museum/internal/models"
+	"museum/internal/postgres"
+	"museum/pkg/exhibitions"
+)
+
+// Loader returns a catalogue holding exactly the museums and exhibitions
+// given. Ids are the implementation's to assign.
+type Loader func(t *testing.T, museums []models.Museum, shows []exhibitions.Exhibition) api.Catalogue
+
+// Positions used by the fixture and the queries.
+var (
+	louvre    = [2]float64{48.8606, 2.3376}
+	amsterdam = [2]float64{52.3600, 4.8852}
+)
+
+// Museums is the fixture: five museums in central Paris, two in Amsterdam, and
+// one nobody has placed.
+func Museums() []models.Museum {
+	return []models.Museum{
+		{Name: "Louvre Museum", Country: "France", Locality: "Paris", WikidataID: "Q19675",
+			Website: "https://www.louvre.fr/", Verified: true, Sitelinks: 167,
+			Latitude: louvre[0], Longitude: louvre[1], Sources: []string{"wikidata"}},
+		{Name: "Musée d'Orsay", Country: "France", Locality: "Paris", WikidataID: "Q23402",
+			AlsoKnownAs: []string{"Orsay"}, Verified: true, Sitelinks: 90,
+			Latitude: 48.8600, Longitude: 2.3266, Sources: []string{"wikidata"}},
+		{Name: "Musée de la Chasse et de la Nature", Country: "France", Locality: "Paris",
+			Latitude: 48.8613, Longitude: 2.3581, Sources: []string{"wikipedia-list"}},
+		{Name: "Musée Carnavalet", Country: "France", Locality: "Paris", WikidataID: "Q1129710",
+			Verified: true, Sitelinks: 40, Latitude: 48.8575, Longitude: 2.3624, Sources: []string{"wikidata"}},
+		{Name: "Musée de l'Orangerie", Country: "France", Locality: "Paris", WikidataID: "Q237258",
+			Verified: true, Sitelinks: 50, Latitude: 48.8638, Longitude: 2.3226, Sources: []string{"wikidata"}},
+		{Name: "Rijksmuseum", Country: "Netherlands", Locality: "Amsterdam", WikidataID: "Q190804",
+			Verified: true, Sitelinks: 150, Latitude: amsterdam[0], Longitude: amsterdam[1], Sources: []string{"wikidata"}},
+		{Name: "Van Gogh Museum", Country: "Netherlands", Locality: "Amsterdam", WikidataID: "Q224124",
+			Verified: true, Sitelinks: 120, Latitude: 52.3584, Longitude: 4.8811, Sources: []string{"wikidata"}},
+		{Name: "Musée imaginaire", Country: "France", Sources: []string{"wikipedia-list"}},
+	}
+}
+
+// Exhibitions is the fixture's listings, dated from today: four current or
+// coming up in Paris, one that has closed, and one in Amsterdam.
+func Exhibitions() []exhibitions.Exhibition {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	day := func(n int) *time.Time {
+		d := today.AddDate(0, 0, n)
+		return &d
+	}
+	show := func(slug, title, museum string, at [2]float64, start, end *time.Time) exhibitions.Exhibition {
+		return exhibitions.Exhibition{
+			URL: "https://example.org/" + slug, SourcePage: "https://example.org/",
+			Title: title, Museum: museum, Start: start, End: end,
+			Latitude: at[0], Longitude: at[1], ScrapedAt: today,
+		}
+	}
+	permanent := show("permanent", "Egyptian Antiquities", "Louvre Museum", louvre, nil, nil)
+	permanent.Permanent = true
+	return []exhibitions.Exhibition{
+		show("closing", "Drawings from the Cabinet", "Louvre Museum", louvre, day(-30), day(5)),
+		show("later", "Impressionist Landscapes", "Musée d'Orsay", [2]float64{48.8600, 2.3266}, day(-10), day(60)),
+		show("coming", "Sculpture of the Renaissance", "Louvre Museum", louvre, day(10), day(90)),
+		show("over", "Last Season's Show", "Louvre Museum", louvre, day(-90), day(-3)),
+		permanent,
+		show("seasons", "Van Gogh and the Seasons", "Van Gogh Museum", [2]float64{52.3584, 4.8811}, day(-5), day(30)),
+	}
+}
+
+// Run checks a catalogue implementation against the fixture.
+func Run(t *testing.T, load Loader) {
+	c := load(t, Museums(), Exhibitions())
+	ctx := context.Background()
+
+	names := func(hits []postgres.Hit) []string {
+		out := make([]string, len(hits))
+		for i, h := range hits {
+			out[i] = h.Museum.Name
+		}
+		return out
+	}
+
+	t.Run("nearby orders by distance and pages with a total", func(t *testing.T) {
+		page, err := c.NearbyVerified(ctx, louvre[0], louvre[1], 3, 10, 0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"Louvre Museum", "Musée d'Orsay", "Musée de l'Orangerie",
+			"Musée de la Chasse et de la Nature", "Musée Carnavalet"}
+		if got := names(page.Hits); !slices.Equal(got, want) || page.Total != 5 {
+			t.Fatalf("nearby = %v (total %d), want %v", got, page.Total, want)
+		}
+		if page.Hits[0].DistanceKm > 0.01 || page.Hits[4].DistanceKm < 1.5 || page.Hits[4].DistanceKm > 2.5 {
+			t.Errorf("distances %.3f … %.3f km, want 0 to about 1.9", page.Hits[0].DistanceKm, page.Hits[4].DistanceKm)
+		}
+
+		second, err := c.NearbyVerified(ctx, louvre[0], louvre[1], 3, 2, 2, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := names(second.Hits); !slices.Equal(got, want[2:4]) || second.Total != 5 {
+			t.Errorf("second page = %v (total %d), want %v of 5", got, second.Total, want[2:4])
+		}
+
+		verified, err := c.NearbyVerified(ctx, louvre[0], louvre[1], 3, 10, 0, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if verified.Total != 4 || slices.Contains(names(verified.Hits), "Musée de la Chasse et de la Nature") {
+			t.Errorf("verified only = %v, want the four verified museums", names(verified.Hits))
+		}
+	})
+
+	t.Run("search ranks exact, alias and misspelt names", func(t *testing.T) {
+		for query, want := range map[string]string{
+			"Louvre Museum":      "Louvre Museum",
+			"orsay":              "Musée d'Orsay",
+			"rijksmusem":         "Rijksmuseum",
+			"van gogh amsterdam": "Van Gogh Museum",
+		} {
+			page, err := c.Search(ctx, query, 5, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Hits) == 0 || page.Hits[0].Museum.Name != want {
+				t.Errorf("search %q = %v, want %s first", query, names(page.Hits), want)
+			}
+		}
+
+		page, err := c.Search(ctx, "musee", 2, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Hits) != 2 || page.Total < 5 {
+			t.Errorf("search musee = %v (total %d), want a page of two out of at least five", names(page.Hits), page.Total)
+		}
+		if page, _ := c.Search(ctx, "xylophone", 5, 0); len(page.Hits) != 0 {
+			t.Errorf("search xylophone = %v, want nothing", names(page.Hits))
+		}
+	})
+
+	t.Run("search honours phrases and exclusions", func(t *testing.T) {
+		page, err := c.Search(ctx, "musee -orsay", 10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := names(page.Hits); len(got) < 4 || slices.Contains(got, "Musée d'Orsay") {
+			t.Errorf("search musee -orsay = %v, want the other musées and not Orsay", got)
+		}
+
+		page, err = c.Search(ctx, `"van gogh" museum`, 10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := names(page.Hits); !slices.Equal(got, []string{"Van Gogh Museum"}) {
+			t.Errorf(`search "van gogh" museum = %v, want only the Van Gogh Museum`, got)
+		}
+
+		hits, _, err := c.SearchExhibitions(ctx, `"the seasons"`, 0, 0, 0, false, false, 10, 0)
+		if err != nil || len(hits) != 1 || hits[0].Title != "Van Gogh and the Seasons" {
+			t.Errorf(`exhibitions "the seasons" = %v, %v; want the one show`, hits, err)
+		}
+		if hits, _, _ := c.SearchExhibitions(ctx, "seasons -gogh", 0, 0, 0, false, false, 10, 0); len(hits) != 0 {
+			t.Errorf("exhibitions seasons -gogh = %v, want nothing", hits)
+		}
+	})
+
+	t.Run("museum by id", func(t *testing.T) {
+		hit, err := c.MuseumByID(ctx, "Q19675")
+		if err != nil || hit.Museum.Name != "Louvre Museum" || hit.Museum.Website != "https://www.louvre.fr/" {
+			t.Fatalf("Q19675 = %+v, %v; want the Louvre", hit.Museum, err)
+		}
+		again, err := c.MuseumByID(ctx, strconv.FormatInt(hit.ID, 10))
+		if err != nil || again.Museum.WikidataID != "Q19675" {
+			t.Errorf("by numeric id %d = %+v, %v; want the Louvre again", hit.ID, again.Museum, err)
+		}
+		if _, err := c.MuseumByID(ctx, "Q1"); !errors.Is(err, postgres.ErrNotFound) {
+			t.Errorf("unknown id: err = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("points are the placed museums, most prominent first", func(t *testing.T) {
+		paris, err := c.Points(ctx, 2.2, 48.8, 2.4, 48.9, true, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(paris) != 5 {
+			t.Errorf("Paris box holds %d points, want 5", len(paris))
+		}
+
+		world, err := c.Points(ctx, 0, 0, 0, 0, false, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, p := range world {
+			hit, err := c.MuseumByID(ctx, strconv.FormatInt(p.ID, 10))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, hit.Museum.Name)
+		}
+		if want := []string{"Louvre Museum", "Rijksmuseum", "Van Gogh Museum"}; !slices.Equal(got, want) {
+			t.Errorf("top three points = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("clusters group by cell and count current exhibitions", func(t *testing.T) {
+		clustering, err := c.PointClusters(ctx, 0, 0, 0, 0, false, 1, 3, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if clustering.Museums != 7 || len(clustering.Clusters) != 2 {
+			t.Fatalf("clustering = %+v, want two clusters of seven placed museums", clustering)
+		}
+		paris := clustering.Clusters[0]
+		if paris.Museums != 5 || paris.RepresentativeName != "Louvre Museum" || paris.Exhibitions != 4 {
+			t.Errorf("Paris cluster = %+v, want five museums led by the Louvre with four exhibitions", paris)
+		}
+		if amsterdam := clustering.Clusters[1]; amsterdam.Museums != 2 || amsterdam.Exhibitions != 1 {
+			t.Errorf("Amsterdam cluster = %+v, want two museums and one exhibition", amsterdam)
+		}
+
+		sparse, err := c.PointClusters(ctx, 0, 0, 0, 0, false, 1, 100, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sparse.Points) != 7 || len(sparse.Clusters) != 0 {
+			t.Errorf("a sparse view = %d points, %d clusters; want every point", len(sparse.Points), len(sparse.Clusters))
+		}
+	})
+
+	t.Run("exhibitions nearby close soonest first", func(t *testing.T) {
+		titles := func(hits []postgres.ExhibitionHit) []string {
+			out := make([]string, len(hits))
+			for i, h := range hits {
+				out[i] = h.Title
+			}
+			return out
+		}
+
+		running, err := c.ExhibitionsNearby(ctx, louvre[0], louvre[1], 5, false, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"Drawings from the Cabinet", "Impressionist Landscapes", "Egyptian Antiquities"}
+		if got := titles(running); !slices.Equal(got, want) {
+			t.Errorf("running = %v, want %v", got, want)
+		}
+
+		all, err := c.ExhibitionsNearby(ctx, louvre[0], louvre[1], 5, true, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = []string{"Drawings from the Cabinet", "Impressionist Landscapes", "Sculpture of the Renaissance", "Egyptian Antiquities"}
+		if got := titles(all); !slices.Equal(got, want) {
+			t.Fatalf("with upcoming = %v, want %v", got, want)
+		}
+		if coming := all[2]; !coming.Upcoming || coming.Running {
+			t.Errorf("an exhibition opening in ten days: running %v, upcoming %v", coming.Running, coming.Upcoming)
+		}
+		if permanent := all[3]; !permanent.Running || permanent.Upcoming {
+			t.Errorf("a permanent display: running %v, upcoming %v", permanent.Running, permanent.Upcoming)
+		}
+	})
+
+	t.Run("exhibition search", func(t *testing.T) {
+		hits, total, err := c.SearchExhibitions(ctx, "seasons", 0, 0, 0, false, false, 10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 1 || len(hits) != 1 || hits[0].Title != "Van Gogh and the Seasons" {
+			t.Errorf("search seasons = %+v (total %d), want the one show", hits, total)
+		}
+		if _, total, _ := c.SearchExhibitions(ctx, "seasons", louvre[0], louvre[1], 10, true, false, 10, 0); total != 0 {
+			t.Errorf("search seasons near the Louvre found %d, want none", total)
+		}
+		// The venue matches too, below any title.
+		hits, _, err = c.SearchExhibitions(ctx, "louvre", 0, 0, 0, false, true, 10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(hits) != 3 {
+			t.Errorf("search louvre = %d current shows, want the three at the Louvre", len(hits))
+		}
+	})
+
+	t.Run("coverage counts the museums in an area", func(t *testing.T) {
+		coverage, err := c.ExhibitionCoverage(ctx, louvre[0], louvre[1], 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if coverage.MuseumsInArea != 5 || coverage.MuseumsWithSite != 1 {
+			t.Errorf("coverage = %+v, want five museums, one with a site", coverage)
+		}
+	})
+
+	t.Run("counts and the whole catalogue", func(t *testing.T) {
+		counts, err := c.Counts(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if counts.Museums != 8 || counts.WithCoordinates != 7 || counts.WithWebsite != 1 || counts.Countries != 2 {
+			t.Errorf("counts = %+v, want 8 museums, 7 placed, 1 with a website, in 2 countries", counts)
+		}
+
+		var ids []int64
+		if err := c.EachMuseum(ctx, func(id int64, _ models.Museum) { ids = append(ids, id) }); err != nil {
+			t.Fatal(err)
+		}
+		if len(ids) != 8 || !slices.IsSorted(ids) {
+			t.Errorf("EachMuseum visited %v, want eight ids in order", ids)
+		}
+
+		// Keyset pages cover the same ids, in order, each after the last.
+		var paged []int64
+		for after := int64(0); ; {
+			page, err := c.MuseumIDs(ctx, after, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			paged = append(paged, page...)
+			if len(page) < 3 {
+				break
+			}
+			after = page[len(page)-1]
+		}
+		if !slices.Equal(paged, ids) {
+			t.Errorf("MuseumIDs paged through %v, want %v", paged, ids)
+		}
+		if err := c.Ping(ctx); err != nil {
+			t.Errorf("ping: %v", err)
+		}
+	})
+}
@@ -11,6 +11,7 @@ import (
 	"unicode"
 
 	"museum/internal/models"
+	"museum/internal/override"
 )
 
 // Merger accumulates museums from multiple sources, folding records that
@@ -32,16 +33,20 @@ import (
 // museum-within-a-building cases put genuinely distinct museums within metres
 // of each other, and a proximity rule merges them wrongly.
 //
+// Overrides made by hand outrank all of it; see Honour.
+//
 // A Merger is safe for concurrent use so several source goroutines can feed it.
 type Merger struct {
 	mu         sync.Mutex
+	rules      *override.Rules
 	byWikidata map[string]int
 	byName     map[string]int
 	// ambiguous marks name keys that more than one distinct museum answers to,
 	// which are therefore evidence of nothing. See lookup.
-	ambiguous map[string]struct{}
-	museums   []*models.Museum
-	merged    int
+	ambiguous  map[string]struct{}
+	museums    []*models.Museum
+	merged     int
+	suppressed int
 }
 
 // NewMerger returns an empty Merger.
@@ -53,6 +58,17 @@ func NewMerger() *Merger {
 	}
 }
 
+// Honour makes the merger follow the catalogue's overrides: a suppressed record
+// is dropped, one a forced merge names is folded into the museum it was merged
+// with, and two records a split keeps apart are never folded together however
+// alike their names. Call it before the first Add.
+func (m *Merger) Honour(rules *override.Rules) *Merger {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rules = rules
+	return m
+}
+
 // Add records a museum, merging it into an existing entry when it matches one.
 //
 // Names are cleaned here because this is the one point every source passes
@@ -67,6 +83,12 @@ func (m *Merger) Add(museum models.Museum) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if m.rules.Suppressed(museum) {
+		m.suppressed++
+		return
+	}
+	museum = m.rules.Apply(museum)
+
 	keys := nameKeys(museum)
 
 	if idx, ok := m.lookup(museum, keys); ok {
@@ -107,6 +129,9 @@ func (m *Merger) lookup(museum models.Museum, keys []string) (int, bool) {
 		// Never merge two records that carry different Wikidata ids: a name
 		// match is weaker evidence than an explicit disagreement.
 		existing := m.museums[idx]
+		if m.rules.Apart(*existing, museum) {
+			continue
+		}
 		if existing.WikidataID == "" || museum.WikidataID == "" || existing.WikidataID == museum.WikidataID {
 			return idx, true
 		}
@@ -152,6 +177,13 @@ func (m *Merger) Stats() (distinct, merged int) {
 	return len(m.museums), m.merged
 }
 
+// Suppressed reports how many incoming records an override kept out.
+func (m *Merger) Suppressed() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.suppressed
+}
+
 // mergeInto folds src into dst, filling gaps without overwriting facts already
 // established by an earlier source.
 func mergeInto(dst *models.Museum, src models.Museum) {
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"museum/internal/models"
+	"museum/internal/override"
 )
 
 func TestMerger_MergesOnWikidataID(t *testing.T) {
@@ -351,3 +352,34 @@ func TestMerger_StillMergesOnADistinctiveAlias(t *testing.T) {
 		t.Errorf("Sources = %v, want both", museums[0].Sources)
 	}
 }
+
+func TestMerger_HonoursOverrides(t *testing.T) {
+	var rules override.Rules
+	// Not a museum: a hotel OpenStreetMap tagged as one.
+	rules.Suppress(override.Ref{NameKey: override.NameKey("Museum Hotel", "Turkey")})
+	// The list page's name for a museum Wikidata knows by another.
+	rules.Redirect(override.Ref{NameKey: override.NameKey("Galata Maritime Museum", "Italy")},
+		models.Museum{Name: "Galata Museo del Mare", Country: "Italy", WikidataID: "Q1916826"})
+	// Two museums with the same name in the same town, one with an id.
+	rules.KeepApart(override.Ref{WikidataID: "Q5", NameKey: override.NameKey("Town Museum", "Norway")},
+		override.Ref{NameKey: override.NameKey("Town Museum", "Norway")})
+
+	m := NewMerger().Honour(&rules)
+	m.Add(models.Museum{Name: "Museum Hotel", Country: "Turkey"})
+	m.Add(models.Museum{Name: "Galata Museo del Mare", Country: "Italy", WikidataID: "Q1916826", Sources: []string{"wikidata"}})
+	m.Add(models.Museum{Name: "Galata Maritime Museum", Country: "Italy", Website: "https://galatamuseodelmare.it", Sources: []string{"lists"}})
+	m.Add(models.Museum{Name: "Town Museum", Country: "Norway", WikidataID: "Q5"})
+	m.Add(models.Museum{Name: "Town Museum", Country: "Norway"})
+
+	museums := m.Museums()
+	if len(museums) != 3 {
+		t.Fatalf("got %d museums, want 3: %+v", len(museums), museums)
+	}
+	if m.Suppressed() != 1 {
+		t.Errorf("suppressed = %d, want 1", m.Suppressed())
+	}
+	galata := museums[0]
+	if galata.Website == "" || !slices.Contains(galata.AlsoKnownAs, "Galata Maritime Museum") {
+		t.Errorf("the redirected record was not folded in: %+v", galata)
+	}
+}
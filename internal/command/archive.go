@@ -0,0 +1,114 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"museum/internal/postgres"
+)
+
+// defaultArchiveAge is how long an exhibition stays live after it ends. Long
+// enough that last season's shows are still found by the ordinary reads —
+// people look up what they just missed — and that a listing retired by a bad
+// read is still there for the next good one to restore.
+const defaultArchiveAge = 180 * 24 * time.Hour
+
+// archiveCommand moves long-ended exhibitions out of the live table.
+//
+// The sweep does this on its own schedule; this is for running it by hand,
+// and for seeing beforehand what it would move.
+func archiveCommand() Command {
+	return Command{
+		Name:    "archive",
+		Summary: "Move exhibitions that ended long ago into the archive",
+		Usage:   "[-older-than 180d] [-dry-run]",
+		Run:     runArchive,
+	}
+}
+
+func runArchive(ctx context.Context, args []string) error {
+	fs := newFlagSet("archive", "[-older-than 180d] [-dry-run]", os.Stderr)
+	olderThan := age(defaultArchiveAge)
+	fs.Var(&olderThan, "older-than", "archive exhibitions that ended longer ago than this (as 180d or 4320h)")
+	dryRun := fs.Bool("dry-run", false, "count what would be archived, and move nothing")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireNoArgs("archive", fs.Args()); err != nil {
+		return err
+	}
+
+	db, err := database(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return archiveExhibitions(ctx, db, time.Duration(olderThan), *dryRun)
+}
+
+// archiveExhibitions archives what ended longer ago than olderThan, and says
+// what it moved.
+func archiveExhibitions(ctx context.Context, db *postgres.Store, olderThan time.Duration, dryRun bool) error {
+	cutoff := time.Now().Add(-olderThan)
+	archived, err := db.ArchiveExhibitions(ctx, cutoff, dryRun)
+	for _, year := range archived.Years() {
+		log.Printf("  %d  %7d exhibitions", year, archived.ByYear[year])
+	}
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		log.Printf("Dry run: %d exhibitions ended before %s and would be archived, none were",
+			archived.Total, cutoff.Format(time.DateOnly))
+		return nil
+	}
+	log.Printf("Archived %d exhibitions that ended before %s", archived.Total, cutoff.Format(time.DateOnly))
+	return nil
+}
+
+// age is a duration flag that also takes days, as "180d": retention is
+// thought of in days, and "4320h" is nobody's way of writing six months.
+type age time.Duration
+
+func (a *age) String() string {
+	d := time.Duration(*a)
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return d.String()
+}
+
+func (a *age) Set(s string) error {
+	d, err := parseAge(s)
+	if err != nil {
+		return err
+	}
+	*a = age(d)
+	return nil
+}
+
+// parseAge reads a whole number of days, as "180d", or anything
+// time.ParseDuration reads. An age is never negative.
+func parseAge(s string) (time.Duration, error) {
+	var (
+		d   time.Duration
+		err error
+	)
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%q is not an age: want days, as 180d, or a duration, as 72h", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,32 @@
+package command
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAge(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want time.Duration
+	}{
+		{"180d", 180 * 24 * time.Hour},
+		{"0d", 0},
+		{"36h", 36 * time.Hour},
+		{"90m", 90 * time.Minute},
+	} {
+		if got, err := parseAge(tc.in); err != nil || got != tc.want {
+			t.Errorf("parseAge(%q) = %v, %v; want %v", tc.in, got, err, tc.want)
+		}
+	}
+	for _, bad := range []string{"", "d", "6 months", "-3d", "1.5d", "-1h"} {
+		if _, err := parseAge(bad); err == nil {
+			t.Errorf("parseAge(%q) accepted", bad)
+		}
+	}
+
+	var a age
+	if err := a.Set("180d"); err != nil || a.String() != "180d" {
+		t.Errorf("180d reads back as %s, %v", a.String(), err)
+	}
+}
@@ -0,0 +1,156 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"museum/internal/backup"
+	"museum/internal/keys"
+	"museum/internal/postgres"
+	"museum/pkg/graceful"
+)
+
+// backupCommand takes a backup of the database and the bucket together.
+func backupCommand() Command {
+	return Command{
+		Name:    "backup",
+		Summary: "Back up the database and the stored museums together, and prune old backups",
+		Usage:   "[-every 24h] [-verify] [-tables-only] [-keep-last 7] [-keep-daily 7] [-keep-weekly 4] [-keep-monthly 6] | -list",
+		Run:     runBackup,
+	}
+}
+
+func runBackup(ctx context.Context, args []string) error {
+	fs := newFlagSet("backup", "[-every 24h] [-verify] [-tables-only] [-keep-last 7] [-keep-daily 7] [-keep-weekly 4] [-keep-monthly 6] | -list", os.Stderr)
+	var (
+		every      = fs.Duration("every", 0, "keep running, taking a backup this often; 0 takes one and stops")
+		verify     = fs.Bool("verify", false, "restore each backup into a scratch schema and audit it once taken")
+		tablesOnly = fs.Bool("tables-only", false, "leave out the stored museums under "+keys.RawPrefix+"/ and "+keys.EnrichedPrefix+"/, and their "+keys.HistoryPrefix+"/")
+		list       = fs.Bool("list", false, "list the backups in the bucket instead of taking one")
+		policy     backup.Policy
+	)
+	fs.IntVar(&policy.Last, "keep-last", 7, "keep this many of the newest backups")
+	fs.IntVar(&policy.Daily, "keep-daily", 7, "keep the newest backup of each of this many days")
+	fs.IntVar(&policy.Weekly, "keep-weekly", 4, "keep the newest backup of each of this many weeks")
+	fs.IntVar(&policy.Monthly, "keep-monthly", 6, "keep the newest backup of each of this many months; all zero keeps everything")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireNoArgs("backup", fs.Args()); err != nil {
+		return err
+	}
+
+	store, bucketName, err := museumStore()
+	if err != nil {
+		return err
+	}
+	bucket := store.Bucket(bucketName)
+	if *list {
+		return listBackups(ctx, bucket)
+	}
+
+	db, err := database(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	prefixes := []string{keys.RawPrefix, keys.EnrichedPrefix, keys.HistoryPrefix}
+	if *tablesOnly {
+		prefixes = nil
+	}
+
+	ctx, cancel := graceful.Context(ctx)
+	defer cancel()
+
+	if *every <= 0 {
+		return takeBackup(ctx, db, bucket, prefixes, policy, *verify)
+	}
+
+	log.Printf("Backing up every %s to %s/%s", *every, bucketName, backup.Prefix)
+	for {
+		// A failed backup must not stop the schedule: the database may only
+		// be restarting, and the next attempt should still happen.
+		if err := takeBackup(ctx, db, bucket, prefixes, policy, *verify); err != nil {
+			log.Printf("Backup FAILED: %v", err)
+		}
+		if !wait(ctx, *every) {
+			return nil
+		}
+	}
+}
+
+// takeBackup takes one backup, verifies it if asked, and prunes the ones the
+// policy no longer keeps. Pruning follows only a backup that succeeded, so a
+// run of failures cannot delete the last good backups.
+func takeBackup(ctx context.Context, db *postgres.Store, bucket backup.Bucket, prefixes []string, policy backup.Policy, verify bool) error {
+	start := time.Now()
+	m, err := backup.Take(ctx, db, bucket, prefixes, runLabel(ctx))
+	if err != nil {
+		return err
+	}
+	log.Printf("Took backup %s in %s: %d rows in %d tables, %s",
+		m.ID, time.Since(start).Round(time.Second), m.Rows(), len(m.Tables), describeObjects(m))
+
+	if verify {
+		report, err := backup.Verify(ctx, db, bucket, m)
+		if err != nil {
+			return fmt.Errorf("backup %s does not restore: %w", m.ID, err)
+		}
+		log.Printf("Backup %s restores: %d museums, %d errors and %d warnings in the audit",
+			m.ID, report.Museums, report.Errors(), report.Warnings())
+	}
+
+	deleted, err := backup.Prune(ctx, bucket, policy, time.Now())
+	for _, id := range deleted {
+		log.Printf("Pruned backup %s", id)
+	}
+	return err
+}
+
+// listBackups prints the backups in the bucket, newest first.
+func listBackups(ctx context.Context, bucket backup.Bucket) error {
+	all, err := backup.List(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if len(all) == 0 {
+		fmt.Println("No backups.")
+		return nil
+	}
+	for _, m := range all {
+		fmt.Printf("%s  schema %-3d %9s  %10d rows  %s\n", m.ID, m.SchemaVersion,
+			formatSize(m.Bytes()), m.Rows(), describeObjects(m))
+	}
+	return nil
+}
+
+// describeObjects summarises the objects a backup holds.
+func describeObjects(m backup.Manifest) string {
+	if len(m.Objects) == 0 {
+		return "no objects"
+	}
+	var n int64
+	for _, p := range m.Objects {
+		n += p.Count
+	}
+	return fmt.Sprintf("%d objects", n)
+}
+
+// formatSize prints a byte count the way ls -h does.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	size, suffix := float64(n), "KMGT"
+	i := -1
+	for size >= unit && i < len(suffix)-1 {
+		size /= unit
+		i++
+	}
+	return fmt.Sprintf("%.1f %cB", size, suffix[i])
+}
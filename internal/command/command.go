@@ -13,8 +13,10 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"os"
 	"sort"
 	"strings"
+	"time"
 
 	"museum/internal/env"
 	"museum/internal/keys"
@@ -41,26 +43,55 @@ func all() []Command {
 	return []Command{
 		serveCommand(),
 		enrichCommand(),
+		relayCommand(),
 		crawlCommand(),
 		refreshCommand(),
 		sweepCommand(),
 		locateCommand(),
+		geocacheCommand(),
+		outboxCommand(),
 		reindexCommand(),
 		verifyCommand(),
 		queryCommand(),
+		overrideCommand(),
+		retiredCommand(),
+		diffCommand(),
+		archiveCommand(),
+		exportCommand(),
+		backupCommand(),
+		restoreCommand(),
+		migrateCommand(),
 	}
 }
 
 // Lookup finds a command by name.
+//
+// The command it returns labels its context with the run — the command's name
+// and when it started — so whatever the run writes can be traced back to it.
 func Lookup(name string) (Command, bool) {
 	for _, cmd := range all() {
 		if cmd.Name == name {
+			run := cmd.Run
+			cmd.Run = func(ctx context.Context, args []string) error {
+				label := fmt.Sprintf("%s %s", name, time.Now().UTC().Format(time.RFC3339))
+				return run(context.WithValue(ctx, runLabelKey{}, label), args)
+			}
 			return cmd, true
 		}
 	}
 	return Command{}, false
 }
 
+// runLabelKey carries the run's label in a context.
+type runLabelKey struct{}
+
+// runLabel names the run a context belongs to, e.g. "crawl
+// 2026-03-01T02:00:00Z".
+func runLabel(ctx context.Context) string {
+	label, _ := ctx.Value(runLabelKey{}).(string)
+	return label
+}
+
 // Names returns every command name, sorted.
 func Names() []string {
 	names := make([]string, 0, len(all()))
@@ -103,34 +134,91 @@ func newFlagSet(cmd string, usage string, w io.Writer) *flag.FlagSet {
 //
 // Connection settings come from the environment rather than from flags so that
 // one set of variables configures every subcommand identically, whether it runs
-// from a shell, a container or a scheduler.
+// from a shell, a container or a scheduler. MUSEUM_STORE chooses the store —
+// s3://bucket, or file:///path for a directory — and without it the bucket is
+// MUSEUM_BUCKET_NAME on the MinIO the MINIO_* variables describe, as it always
+// was. MUSEUM_STORE_ENCODING compresses what is written, with gzip or zstd;
+// anything stored is read whatever it was written with.
 func museumStore() (*storage.S3Service[models.Museum], string, error) {
 	env.LoadEnv()
 
-	bucket, err := env.LookupEnv("MUSEUM_BUCKET_NAME")
+	encoding, err := storage.ParseEncoding(os.Getenv("MUSEUM_STORE_ENCODING"))
 	if err != nil {
-		return nil, "", err
+		return nil, "", fmt.Errorf("MUSEUM_STORE_ENCODING: %w", err)
 	}
 
-	store, err := storage.NewS3Service(keys.Museum)
-	if err != nil {
-		return nil, "", err
+	var (
+		store  *storage.S3Service[models.Museum]
+		bucket string
+	)
+	if location := os.Getenv("MUSEUM_STORE"); location != "" {
+		blob, name, err := storage.OpenBlob(location)
+		if err != nil {
+			return nil, "", err
+		}
+		store, bucket = storage.NewService(blob, keys.Museum), name
+	} else {
+		if bucket, err = env.LookupEnv("MUSEUM_BUCKET_NAME"); err != nil {
+			return nil, "", err
+		}
+		if store, err = storage.NewS3Service(keys.Museum); err != nil {
+			return nil, "", err
+		}
 	}
+	store.SetEncoding(encoding)
 	return store, bucket, nil
 }
 
-// database opens the catalogue database.
+// database opens the catalogue database, refusing one whose schema is not the
+// version this binary was built for.
 //
 // Like the object-storage settings, the connection string comes from the
 // environment so one set of variables configures every subcommand identically.
 func database(ctx context.Context) (*postgres.Store, error) {
+	db, err := openDatabase(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.CheckSchema(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// openDatabase opens the catalogue database whatever its schema, for the one
+// command whose job is to change it.
+func openDatabase(ctx context.Context) (*postgres.Store, error) {
 	env.LoadEnv()
 
 	dsn, err := env.LookupEnv("DATABASE_URL")
 	if err != nil {
 		return nil, err
 	}
-	return postgres.Open(ctx, dsn)
+	return postgres.OpenAs(ctx, dsn, runLabel(ctx))
+}
+
+// parseInterspersed parses flags wherever they fall among the positional
+// arguments, returning the positional ones, so "history Q19675 -limit 20"
+// means what it says; the flag package alone stops at the first positional
+// argument and leaves the rest unparsed. After "--" everything is positional,
+// which is how a value starting with a minus sign is given.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
 }
 
 // requireNoArgs reports an error when a command was given positional arguments
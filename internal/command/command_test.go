@@ -0,0 +1,35 @@
+package command
+
+import (
+	"io"
+	"slices"
+	"testing"
+)
+
+func TestParseInterspersed(t *testing.T) {
+	cases := []struct {
+		name   string
+		args   []string
+		want   []string
+		reason string
+	}{
+		{"flags first", []string{"-reason", "a hotel", "12"}, []string{"12"}, "a hotel"},
+		{"flags last", []string{"12", "website", "-", "-reason", "an aggregator"}, []string{"12", "website", "-"}, "an aggregator"},
+		{"flags between", []string{"12", "-reason", "moved", "location", "48.86,2.34"}, []string{"12", "location", "48.86,2.34"}, "moved"},
+		// A value with a leading minus sign would otherwise be read as a flag.
+		{"after --", []string{"12", "location", "--", "-33.86,151.21"}, []string{"12", "location", "-33.86,151.21"}, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := newFlagSet("override set", "ID FIELD VALUE", io.Discard)
+			reason := fs.String("reason", "", "")
+			got, err := parseInterspersed(fs, tc.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tc.want) || *reason != tc.reason {
+				t.Errorf("got %q, reason %q; want %q, reason %q", got, *reason, tc.want, tc.reason)
+			}
+		})
+	}
+}
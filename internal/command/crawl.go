@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"museum/internal/collect"
+	"museum/internal/keys"
 	"museum/internal/models"
 	"museum/internal/postgres"
 	"museum/pkg/graceful"
@@ -31,13 +32,13 @@ func crawlCommand() Command {
 	return Command{
 		Name:    "crawl",
 		Summary: "Build the catalogue from Wikidata, Wikipedia and OpenStreetMap",
-		Usage:   "[-sources wikidata,category,lists,osm]",
+		Usage:   "[-sources wikidata,category,lists,osm] [-retire-after N]",
 		Run:     runCrawl,
 	}
 }
 
 func runCrawl(ctx context.Context, args []string) error {
-	fs := newFlagSet("crawl", "[-sources wikidata,category,lists,osm] [-languages en,es,…]", os.Stderr)
+	fs := newFlagSet("crawl", "[-sources wikidata,category,lists,osm] [-languages en,es,…] [-retire-after N]", os.Stderr)
 	sources := fs.String("sources", "wikidata,category,lists",
 		"comma-separated sources: wikidata, category, lists, osm")
 	// English only by default. Every extra edition is a full category walk and
@@ -46,6 +47,13 @@ func runCrawl(ctx context.Context, args []string) error {
 	// by accident. "all" is the shorthand for every edition known.
 	languages := fs.String("languages", wikipedia.DefaultLanguage,
 		"comma-separated Wikipedia editions for the category source, or \"all\"")
+	// Three, so a museum survives a source's bad week: one run can be short
+	// without being cut short, and a museum a source drops by mistake is
+	// usually back by the next.
+	retireAfter := fs.Int("retire-after", 3,
+		"retire museums every source has left out of this many complete runs; 0 never retires")
+	retireMost := fs.Int("retire-most", 0,
+		"refuse to retire more museums than this at once; 0 means 2% of the catalogue, at least 100")
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
@@ -88,13 +96,27 @@ func runCrawl(ctx context.Context, args []string) error {
 	saver := newCheckpointer(ctx)
 	defer saver.close()
 
+	// Overrides made by hand are honoured while merging, not only when saving:
+	// a forced split has to stop two records meeting here, because once they
+	// are one record there is nothing left for the database to keep apart.
 	merger := collect.NewMerger()
-	collectSources(ctx, enabled, editions, merger, saver.add)
+	if saver.db != nil {
+		rules, err := saver.db.OverrideRules(ctx)
+		if err != nil {
+			log.Printf("Cannot read overrides: %v (merging without them)", err)
+		} else {
+			merger.Honour(rules)
+		}
+	}
+	seen := collectSources(ctx, enabled, editions, merger, saver.add)
 	saver.flush()
 
 	distinct, folded := merger.Stats()
 	log.Printf("Collected %d distinct museums (%d records merged across sources) in %s",
 		distinct, folded, time.Since(start))
+	if suppressed := merger.Suppressed(); suppressed > 0 {
+		log.Printf("Left out %d records suppressed by an override", suppressed)
+	}
 
 	museums := merger.Museums()
 
@@ -104,14 +126,27 @@ func runCrawl(ctx context.Context, args []string) error {
 	writeCtx, cancelWrite := context.WithTimeout(context.WithoutCancel(ctx), writeTimeout)
 	defer cancelWrite()
 
-	stored := store.StoreFromChannel(writeCtx, bucket, stream(writeCtx, museums))
+	var (
+		writtenMu sync.Mutex
+		written   []string
+	)
+	stored := store.StoreFromChannel(writeCtx, bucket, stream(writeCtx, museums), func(key string) {
+		writtenMu.Lock()
+		defer writtenMu.Unlock()
+		written = append(written, key)
+	})
+	packCatalogue(writeCtx, store.Bucket(bucket), keys.RawPrefix)
+
+	// What the crawl wrote is what needs enriching: an unchanged record was
+	// enriched when it was last written.
+	queueEnrichment(writeCtx, saver.db, bucket, written)
 
 	// Written again at the end, and deliberately: the checkpoints hold each
 	// record as its own source saw it, while this holds the merged form, with
 	// aliases and sources unioned across sources and the best coordinates kept.
 	// The upsert is idempotent, so this refines what is already stored rather
 	// than duplicating it — and if it fails, the checkpointed records remain.
-	loadIntoDatabase(writeCtx, museums)
+	loadIntoDatabase(writeCtx, museums, seen, retirement{after: *retireAfter, most: *retireMost})
 
 	log.Printf("Finished in %s: stored %d museums in bucket %q", time.Since(start), stored, bucket)
 	return nil
@@ -283,7 +318,11 @@ func (c *checkpointer) close() {
 // object storage, which is the durable copy, and "museum reindex" can load them
 // afterwards. Losing a crawl because the database was briefly unreachable would
 // be a poor trade.
-func loadIntoDatabase(ctx context.Context, museums []models.Museum) {
+//
+// Once the merges have run, what each source reported is recorded against the
+// museums it names, which restores any retired museum seen again and retires
+// the ones no source offers any more.
+func loadIntoDatabase(ctx context.Context, museums []models.Museum, seen *sightings, retire retirement) {
 	db, err := database(ctx)
 	if err != nil {
 		log.Printf("Skipping the database load: %v (run \"museum reindex\" once it is reachable)", err)
@@ -355,6 +394,10 @@ func loadIntoDatabase(ctx context.Context, museums []models.Museum) {
 	if aliases > 0 {
 		log.Printf("Merged %d museums recorded under a name another row already knew", aliases)
 	}
+
+	// After the merges, so a sighting lands on the row a record ended up in
+	// rather than on one about to be folded away.
+	retireMuseums(ctx, db, seen, retire)
 }
 
 // collectSources runs every enabled source concurrently and feeds the merger.
@@ -367,8 +410,9 @@ func loadIntoDatabase(ctx context.Context, museums []models.Museum) {
 // The category source runs once per language edition. Those crawls are the only
 // way to reach the museums English Wikipedia has no article for, which is most
 // of them: 35,352 against 19,802.
-func collectSources(ctx context.Context, enabled, languages []string, merger *collect.Merger, onMuseum func(models.Museum)) {
+func collectSources(ctx context.Context, enabled, languages []string, merger *collect.Merger, onMuseum func(models.Museum)) *sightings {
 	var wg sync.WaitGroup
+	seen := newSightings()
 
 	// One Wikipedia client for every source that needs one, so they share both
 	// the rate limiter and the connection pool.
@@ -378,10 +422,18 @@ func collectSources(ctx context.Context, enabled, languages []string, merger *co
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
+			var tags []string
 			for museum := range stream() {
+				tags = seen.note(museum, tags)
 				merger.Add(museum)
 				onMuseum(museum)
 			}
+			// A stream that ends because the crawl was cancelled has not
+			// listed everything its source offers, and its silence about a
+			// museum says nothing.
+			if ctx.Err() != nil {
+				seen.cutShort(tags)
+			}
 			log.Printf("source %q finished", label)
 		}()
 	}
@@ -409,6 +461,7 @@ func collectSources(ctx context.Context, enabled, languages []string, merger *co
 	}
 
 	wg.Wait()
+	return seen
 }
 
 // normalizeBatch is how many records are held back to be normalised together.
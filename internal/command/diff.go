@@ -0,0 +1,208 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"museum/internal/storage"
+)
+
+// diffCommand shows how a stored record changed between the versions kept
+// of it.
+//
+// A museum that moved, lost its website or changed its name did so because a
+// source said so; the versions under history/ say when, and this says what,
+// field by field, without anyone having to fetch two objects and compare them
+// by eye.
+func diffCommand() Command {
+	return Command{
+		Name:    "diff",
+		Summary: "Show how a stored museum record changed, field by field, version by version",
+		Usage:   "[-since DATE] KEY",
+		Run:     runDiff,
+	}
+}
+
+func runDiff(ctx context.Context, args []string) error {
+	fs := newFlagSet("diff", "[-since DATE] KEY", os.Stderr)
+	var since moment
+	fs.Var(&since, "since", "start from the version current on this date, as 2026-03-01 or an RFC 3339 time")
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errors.New("diff takes one key, as raw_data/france/louvre.json")
+	}
+	key := args[0]
+
+	store, bucket, err := museumStore()
+	if err != nil {
+		return err
+	}
+	versions, err := store.Versions(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		if _, err := store.Blob().ETag(ctx, bucket, key); err != nil {
+			return err
+		}
+		fmt.Printf("%s has no history: it was last written before versions were kept.\n", key)
+		return nil
+	}
+	versions = versionsSince(versions, time.Time(since))
+
+	previous, err := readFields(ctx, store, bucket, versions[0])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s, %s\n", key, versionLabel(versions[0]))
+	if len(versions) == 1 {
+		fmt.Println("  unchanged since")
+		return nil
+	}
+	for _, version := range versions[1:] {
+		current, err := readFields(ctx, store, bucket, version)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s:\n", versionLabel(version))
+		changes := diffFields(previous, current)
+		if len(changes) == 0 {
+			// Same fields, differently encoded: a key reordered or a number
+			// written another way.
+			fmt.Println("  no field changed")
+		}
+		for _, c := range changes {
+			switch {
+			case c.Old == "":
+				fmt.Printf("  + %s: %s\n", c.Path, c.New)
+			case c.New == "":
+				fmt.Printf("  - %s: %s\n", c.Path, c.Old)
+			default:
+				fmt.Printf("    %s: %s → %s\n", c.Path, c.Old, c.New)
+			}
+		}
+		previous = current
+	}
+	return nil
+}
+
+// versionsSince drops the versions superseded before since, keeping the one
+// current then to compare the later ones with. A zero since keeps them all.
+func versionsSince(versions []storage.Version, since time.Time) []storage.Version {
+	start := 0
+	for i, v := range versions {
+		if !v.At.After(since) {
+			start = i
+		}
+	}
+	return versions[start:]
+}
+
+// versionLabel says when a version was written.
+func versionLabel(v storage.Version) string {
+	if v.At.IsZero() {
+		return "as it was before versions were kept"
+	}
+	return "as written " + v.At.Local().Format("2006-01-02 15:04:05")
+}
+
+// readFields reads a version and flattens it.
+func readFields(ctx context.Context, store interface {
+	GetJSON(ctx context.Context, bucket, key string, out any) error
+}, bucket string, v storage.Version) (map[string]string, error) {
+	var value any
+	if err := store.GetJSON(ctx, bucket, v.Key, &value); err != nil {
+		return nil, err
+	}
+	fields := map[string]string{}
+	flatten("", value, fields)
+	return fields, nil
+}
+
+// flatten records every leaf of a decoded JSON value by its path — "name",
+// "location.lat", "sources[2]" — as its JSON encoding. An empty object or
+// list is a leaf too, so one emptied is reported rather than vanishing.
+func flatten(path string, value any, fields map[string]string) {
+	switch v := value.(type) {
+	case map[string]any:
+		if len(v) > 0 {
+			for name, child := range v {
+				if path != "" {
+					name = path + "." + name
+				}
+				flatten(name, child, fields)
+			}
+			return
+		}
+	case []any:
+		if len(v) > 0 {
+			for i, child := range v {
+				flatten(path+"["+strconv.Itoa(i)+"]", child, fields)
+			}
+			return
+		}
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		encoded = []byte(fmt.Sprint(value))
+	}
+	fields[path] = string(encoded)
+}
+
+// fieldChange is one field that differs between two versions. Old is empty
+// for a field added, New for one removed.
+type fieldChange struct {
+	Path     string
+	Old, New string
+}
+
+// diffFields lists the fields that differ, by path.
+func diffFields(old, new map[string]string) []fieldChange {
+	var changes []fieldChange
+	paths := slices.Sorted(maps.Keys(old))
+	for path := range new {
+		if _, ok := old[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	slices.Sort(paths)
+	for _, path := range paths {
+		if old[path] != new[path] {
+			changes = append(changes, fieldChange{Path: path, Old: old[path], New: new[path]})
+		}
+	}
+	return changes
+}
+
+// moment is a flag naming a point in time: a date, meaning its first moment
+// in UTC, or an RFC 3339 time. Unset, it is the zero time.
+type moment time.Time
+
+func (m *moment) String() string {
+	if m == nil || time.Time(*m).IsZero() {
+		return ""
+	}
+	return time.Time(*m).Format(time.RFC3339)
+}
+
+func (m *moment) Set(s string) error {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		t, err = time.Parse(time.RFC3339, s)
+	}
+	if err != nil {
+		return fmt.Errorf("%q is not a date: want 2026-03-01 or 2026-03-01T12:00:00Z", s)
+	}
+	*m = moment(t)
+	return nil
+}
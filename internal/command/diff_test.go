@@ -0,0 +1,60 @@
+package command
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"museum/internal/storage"
+)
+
+func TestDiffFields(t *testing.T) {
+	fields := func(doc string) map[string]string {
+		t.Helper()
+		var value any
+		if err := json.Unmarshal([]byte(doc), &value); err != nil {
+			t.Fatal(err)
+		}
+		out := map[string]string{}
+		flatten("", value, out)
+		return out
+	}
+	old := fields(`{"name":"Louvre","location":{"lat":48.86,"lon":2.33},"sources":["wikidata","osm"],"phone":"+33 1","tags":["art"]}`)
+	new := fields(`{"name":"Louvre Museum","location":{"lat":48.86,"lon":2.34},"sources":["wikidata"],"website":"https://louvre.fr","tags":[]}`)
+
+	want := []fieldChange{
+		{Path: "location.lon", Old: "2.33", New: "2.34"},
+		{Path: "name", Old: `"Louvre"`, New: `"Louvre Museum"`},
+		{Path: "phone", Old: `"+33 1"`},
+		{Path: "sources[1]", Old: `"osm"`},
+		{Path: "tags", New: "[]"},
+		{Path: "tags[0]", Old: `"art"`},
+		{Path: "website", New: `"https://louvre.fr"`},
+	}
+	if got := diffFields(old, new); !slices.Equal(got, want) {
+		t.Errorf("diffFields =\n%v\nwant\n%v", got, want)
+	}
+	if got := diffFields(old, old); len(got) != 0 {
+		t.Errorf("a version compared with itself differs in %v", got)
+	}
+}
+
+func TestVersionsSince(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC) }
+	versions := []storage.Version{{Key: "before"}, {Key: "2nd", At: day(2)}, {Key: "5th", At: day(5)}}
+	for _, tc := range []struct {
+		since time.Time
+		first string
+	}{
+		{time.Time{}, "before"},
+		{day(1), "before"},
+		{day(3), "2nd"},
+		{day(5), "5th"},
+		{day(9), "5th"},
+	} {
+		if got := versionsSince(versions, tc.since); got[0].Key != tc.first {
+			t.Errorf("since %s starts at %s, want %s", tc.since.Format(time.DateOnly), got[0].Key, tc.first)
+		}
+	}
+}
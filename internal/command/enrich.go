@@ -2,14 +2,19 @@ package command
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"sync"
+	"time"
 
 	"museum/internal/enrich"
 	"museum/internal/env"
 	"museum/internal/keys"
 	"museum/internal/models"
+	"museum/internal/postgres"
 	"museum/internal/service"
 	"museum/internal/storage"
 	"museum/pkg/graceful"
@@ -21,7 +26,7 @@ func enrichCommand() Command {
 	return Command{
 		Name:    "enrich",
 		Summary: "Consume storage events and enrich museums with geocoding",
-		Usage:   "",
+		Usage:   "[-queue kafka|postgres|journal] [-pack-every 10m] | replay [-stage NAME] [-since DATE]",
 		Run:     runEnrich,
 	}
 }
@@ -29,64 +34,172 @@ func enrichCommand() Command {
 // museumItem is the concrete item type flowing through the enrichment pipeline.
 type museumItem = enrich.Item[*models.Museum]
 
+// enrichRetry is how a step or a load failing transiently is retried before
+// it is recorded as a dead letter. The retries are inline, holding up every
+// museum behind the failing one, so they ride out a blip and no more: two,
+// seconds apart. A geocoder that is down for longer is waited out by the dead
+// letters, which "enrich replay" runs again once it is back.
+var enrichRetry = enrich.Retry{Attempts: 3, Backoff: 2 * time.Second}
+
 func runEnrich(ctx context.Context, args []string) error {
-	fs := newFlagSet("enrich", "", os.Stderr)
+	if len(args) > 0 && args[0] == "replay" {
+		return runEnrichReplay(ctx, args[1:])
+	}
+
+	fs := newFlagSet("enrich", "[-queue kafka|postgres|journal] [-pack-every 10m]", os.Stderr)
+	queueFlag := fs.String("queue", "",
+		"take the objects to enrich from kafka, as MinIO notifies it, from the postgres queue crawl and reindex fill, or from the journal of a store in a directory (default $ENRICH_QUEUE, or kafka)")
+	packEvery := fs.Duration("pack-every", 10*time.Minute, "repack "+keys.EnrichedPrefix+"/ this often, and on exit; 0 never packs")
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 	if err := requireNoArgs("enrich", fs.Args()); err != nil {
 		return err
 	}
-
-	rawStore, bucket, err := museumStore()
+	queue, err := enrichQueueName(*queueFlag)
 	if err != nil {
 		return err
 	}
 
-	broker, err := env.LookupEnv("KAFKA_BROKER_LOCAL")
+	rawStore, bucket, err := museumStore()
 	if err != nil {
 		return err
 	}
-	topic, err := env.LookupEnv("KAFKA_TOPIC")
+
+	ctx, cancel := graceful.Context(ctx)
+	defer cancel()
+
+	// The database holds the dead letters, and the queue if that is where the
+	// work comes from. An enricher reading Kafka runs without it, and logs
+	// what it gives up on instead.
+	db, err := database(ctx)
 	if err != nil {
-		return err
+		if queue == "postgres" {
+			return err
+		}
+		log.Printf("Dead letters will only be logged: %v", err)
+		db = nil
+	} else {
+		defer db.Close()
 	}
-	group, err := env.LookupEnv("KAFKA_GROUP_ID")
+	letters := &deadLetters{db: db, halt: func(err error) {
+		log.Printf("Stopping, so the object is delivered again: %v", err)
+		cancel()
+	}}
+
+	source, stop, err := enrichSource(queue, db, rawStore.Blob(), bucket)
 	if err != nil {
 		return err
 	}
+	defer stop()
 
-	ctx, cancel := graceful.Context(ctx)
-	defer cancel()
+	source.StartConsuming(ctx)
 
-	log.Printf("Connecting to Kafka broker %s, topic %s, group %s", broker, topic, group)
-	consumer, err := kafkaclient.NewKafkaConsumer(topic, group, broker)
-	if err != nil {
-		return fmt.Errorf("create kafka consumer: %w", err)
-	}
-	defer consumer.Stop()
+	iterator := service.NewIterator(source, loadMuseum(rawStore))
+	iterator.SetRetry(enrichRetry)
+	iterator.SetOnFailed(letters.record)
 
-	enrichedStore, err := storage.NewS3Service(keys.EnrichedMuseum)
+	geocoder, err := newGeocoding(db)
 	if err != nil {
 		return err
 	}
+	pipeline := enrichPipeline(rawStore, bucket, geocoder)
 
-	consumer.StartConsuming(ctx)
+	// The packs a reindex reads are brought up to date in the background, as
+	// enrichment trickles in, rather than after a run that never ends.
+	var packing sync.WaitGroup
+	if *packEvery > 0 {
+		packing.Go(func() {
+			packPeriodically(ctx, rawStore.Bucket(bucket), keys.EnrichedPrefix, *packEvery)
+		})
+	}
 
-	iterator := service.NewIterator(consumer, func(ctx context.Context, b, key string) (*models.Museum, error) {
-		return rawStore.GetObject(ctx, b, key)
-	})
+	processed := pipeline.Process(ctx, pipelineItems(ctx, iterator.Objects(ctx), letters.record))
+	cancel()
+	packing.Wait()
+	log.Printf("Enricher exiting after processing %d museums", processed)
+	return letters.err
+}
 
+// enrichPipeline geocodes a museum, looks up the place it was matched to,
+// finds its address if it still has none, and writes the result under
+// enriched_data/ in the raw records' store. The stage names are what dead
+// letters are filed under and "enrich replay -stage" selects.
+func enrichPipeline(rawStore *storage.S3Service[models.Museum], bucket string, geocoder *geocoding) *enrich.Pipeline[museumItem] {
+	enrichedStore := storage.NewService(rawStore.Blob(), keys.EnrichedMuseum)
+	enrichedStore.SetEncoding(rawStore.Encoding())
 	sink := &s3Sink{store: enrichedStore, bucket: bucket}
+
 	pipeline := enrich.NewPipeline(
-		enrich.NewStage(StepLocation),
-		enrich.NewStage(StepLocationDetails),
-		enrich.NewStage(sink.Store),
+		enrich.NewStage(geocoder.StepLocation).Named("location"),
+		enrich.NewStage(geocoder.StepLocationDetails).Named("details"),
+		enrich.NewStage(geocoder.StepAddress).Named("address"),
+		enrich.NewStage(sink.Store).Named("store"),
 	)
+	pipeline.SetRetry(enrichRetry)
+	return pipeline
+}
 
-	processed := pipeline.Process(ctx, pipelineItems(iterator.Objects(ctx)))
-	log.Printf("Enricher exiting after processing %d museums", processed)
-	return nil
+// loadMuseum reads the raw record an event names. A record that is gone or
+// does not decode is a permanent failure: reading it again finds the same.
+func loadMuseum(rawStore *storage.S3Service[models.Museum]) service.LoaderFunc[*models.Museum] {
+	return func(ctx context.Context, bucket, key string) (*models.Museum, error) {
+		museum, err := rawStore.GetObject(ctx, bucket, key)
+		var (
+			syntax   *json.SyntaxError
+			mismatch *json.UnmarshalTypeError
+		)
+		if storage.IsNotFound(err) || errors.As(err, &syntax) || errors.As(err, &mismatch) {
+			return nil, enrich.Permanent(err)
+		}
+		return museum, err
+	}
+}
+
+// messageSource is where the enricher learns which objects to enrich.
+type messageSource interface {
+	service.MessageIterator
+	StartConsuming(ctx context.Context)
+}
+
+// enrichSource opens the named queue, and returns it with what closes it.
+func enrichSource(queue string, db *postgres.Store, blob storage.Blob, bucket string) (messageSource, func(), error) {
+	switch queue {
+	case "postgres":
+		log.Printf("Taking objects to enrich from the Postgres queue")
+		return newEnrichQueue(db), func() {}, nil
+	case "journal":
+		folder, ok := blob.(*storage.FileBlob)
+		if !ok {
+			return nil, nil, errors.New("only a store in a directory has a journal: set MUSEUM_STORE=file:///path")
+		}
+		source, err := newEnrichJournal(folder, bucket)
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Printf("Taking objects to enrich from the store's journal")
+		return source, func() {}, nil
+	}
+
+	broker, err := env.LookupEnv("KAFKA_BROKER_LOCAL")
+	if err != nil {
+		return nil, nil, err
+	}
+	topic, err := env.LookupEnv("KAFKA_TOPIC")
+	if err != nil {
+		return nil, nil, err
+	}
+	group, err := env.LookupEnv("KAFKA_GROUP_ID")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Printf("Connecting to Kafka broker %s, topic %s, group %s", broker, topic, group)
+	consumer, err := kafkaclient.NewKafkaConsumer(topic, group, broker)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create kafka consumer: %w", err)
+	}
+	return consumer, consumer.Stop, nil
 }
 
 // pipelineItems adapts the stream of objects fetched from storage into pipeline
@@ -95,19 +208,71 @@ func runEnrich(ctx context.Context, args []string) error {
 // Each item carries its acknowledgement forward, so the Kafka offset advances
 // only once the museum has been through every stage and written back. Advancing
 // it earlier would drop museums whose enrichment was interrupted: the crawl does
-// not re-emit an event for an object that already exists, so nothing would ever
-// bring them back.
-func pipelineItems(in <-chan *service.FetchedObject[*models.Museum]) <-chan *museumItem {
+// not re-emit an event for an object whose record has not changed, so nothing
+// would ever bring them back.
+//
+// A stage that gave up is reported to failed, and the item is acknowledged only
+// if every report was recorded: one that was not is left unacknowledged rather
+// than acknowledged with no trace of its failure. Whether that brings it back
+// is up to the source: the Postgres queue's lease lapses, while Kafka needs the
+// enricher to stop before a later commit passes it, which deadLetters sees to.
+func pipelineItems(ctx context.Context, in <-chan *service.FetchedObject[*models.Museum], failed service.FailureFunc) <-chan *museumItem {
 	out := make(chan *museumItem)
 
 	go func() {
 		defer close(out)
 		for obj := range in {
 			item := enrich.NewItem(obj.Data)
-			item.OnDone = obj.Ack
+			// The reports all come before OnDone, and one at a time.
+			unrecorded := false
+			item.OnFailed = func(f enrich.Failure) {
+				if err := failed(ctx, obj.Bucket, obj.Key, f); err != nil {
+					log.Printf("Cannot record the failure of %s/%s: %v (leaving it unacknowledged)", obj.Bucket, obj.Key, err)
+					unrecorded = true
+				}
+			}
+			item.OnDone = func() {
+				if !unrecorded {
+					obj.Ack()
+				}
+			}
 			out <- item
 		}
 	}()
 
 	return out
 }
+
+// deadLetters records what enrichment gave up on. Without a database it can
+// only log them.
+type deadLetters struct {
+	db *postgres.Store
+
+	// halt, when set, is called once with the first letter that could not be
+	// written. Leaving the object unacknowledged is not enough on Kafka: its
+	// offsets are committed per partition and cumulatively, so the next
+	// museum to finish would commit past it and nothing would bring it back.
+	// The enricher stops instead, and starts again from the oldest offset
+	// not committed.
+	halt   func(error)
+	halted sync.Once
+	err    error
+}
+
+// record is a service.FailureFunc.
+func (d *deadLetters) record(ctx context.Context, bucket, key string, f enrich.Failure) error {
+	if d.db == nil {
+		log.Printf("Dead letter: %s/%s failed in %s after %d attempts: %v", bucket, key, f.Stage, f.Attempts, f.Err)
+		return nil
+	}
+	err := d.db.SaveDeadLetter(ctx, postgres.DeadLetter{
+		Bucket: bucket, Key: key, Stage: f.Stage, Error: f.Err.Error(), Attempts: f.Attempts,
+	})
+	if err != nil && d.halt != nil && ctx.Err() == nil {
+		d.halted.Do(func() {
+			d.err = fmt.Errorf("cannot record the failure of %s/%s in %s: %w", bucket, key, f.Stage, err)
+			d.halt(d.err)
+		})
+	}
+	return err
+}
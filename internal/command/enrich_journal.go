@@ -0,0 +1,100 @@
+package command
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+
+	"museum/internal/keys"
+	"museum/internal/storage"
+)
+
+// journalCursor is the name the enricher saves its place in the journal under.
+const journalCursor = "enrich"
+
+// enrichJournal is the enricher's message source for a store in a directory.
+//
+// A folder sends no bucket notifications; its journal is the stand-in. Each
+// raw record put since the enricher last saved its place is handed on in the
+// shape a MinIO notification has, with the offset of the entry after it as
+// its offset, so service.Iterator and everything after it are the same as
+// with Kafka. Committing a message saves that offset, and a restarted
+// enricher resumes from the last one saved: delivery is at least once, as it
+// is from Kafka. Enriched records are journalled too, and are passed over as
+// MinIO's notifications, scoped to raw_data/, pass over them.
+type enrichJournal struct {
+	blob     *storage.FileBlob
+	bucket   string
+	messages chan kafka.Message
+
+	mu    sync.Mutex
+	saved int64
+}
+
+func newEnrichJournal(blob *storage.FileBlob, bucket string) (*enrichJournal, error) {
+	saved, err := blob.Cursor(journalCursor)
+	if err != nil {
+		return nil, err
+	}
+	return &enrichJournal{blob: blob, bucket: bucket, messages: make(chan kafka.Message), saved: saved}, nil
+}
+
+// Messages implements service.MessageIterator.
+func (j *enrichJournal) Messages() <-chan kafka.Message { return j.messages }
+
+// CommitOffset implements service.MessageIterator by saving the offset the
+// enricher resumes from. Like Kafka's, the offset is cumulative, and one older
+// than the offset saved already leaves it where it is.
+func (j *enrichJournal) CommitOffset(_ context.Context, msg kafka.Message) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if msg.Offset <= j.saved {
+		return nil
+	}
+	if err := j.blob.SetCursor(journalCursor, msg.Offset); err != nil {
+		return err
+	}
+	j.saved = msg.Offset
+	return nil
+}
+
+// StartConsuming follows the journal until ctx ends, and then closes Messages.
+// It reads to the end, waits the queue's poll interval and reads on from
+// there. A failed read is logged and tried again after the same wait.
+func (j *enrichJournal) StartConsuming(ctx context.Context) {
+	j.mu.Lock()
+	offset := j.saved
+	j.mu.Unlock()
+
+	go func() {
+		defer close(j.messages)
+		for {
+			next, err := j.blob.Changes(offset, func(c storage.Change) error {
+				if c.Op != "put" || !strings.HasPrefix(c.Key, keys.RawPrefix+"/") {
+					return nil
+				}
+				value, err := objectCreated(j.bucket, c.Key)
+				if err != nil {
+					log.Printf("Skipping %s: %v", c.Key, err)
+					return nil
+				}
+				select {
+				case j.messages <- kafka.Message{Offset: c.Next, Value: value}:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+			offset = next
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Cannot follow the store's journal: %v", err)
+			}
+			if !wait(ctx, queuePoll) {
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,61 @@
+package command
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"museum/internal/service"
+	"museum/internal/storage"
+)
+
+// Raw records written to a folder reach the loader in the order they were
+// written, enriched ones do not, and a restarted enricher resumes after the
+// last record it acknowledged.
+func TestEnrichJournal_FollowsTheFolder(t *testing.T) {
+	blob, err := storage.NewFileBlob(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	put := func(key string) {
+		t.Helper()
+		if err := blob.Put(ctx, "museum", key, strings.NewReader(`{}`), 2, storage.Attrs{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put("raw_data/france/louvre.json")
+	put("enriched_data/france/louvre.json")
+	put("raw_data/italy/uffizi.json")
+
+	follow := func(want ...string) {
+		t.Helper()
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		journal, err := newEnrichJournal(blob, "museum")
+		if err != nil {
+			t.Fatal(err)
+		}
+		journal.StartConsuming(ctx)
+		iterator := service.NewIterator(journal, func(_ context.Context, _, key string) (string, error) {
+			return key, nil
+		})
+		objects := iterator.Objects(ctx)
+		for _, key := range want {
+			select {
+			case obj := <-objects:
+				if obj.Data != key {
+					t.Errorf("loaded %s, want %s", obj.Data, key)
+				}
+				obj.Ack()
+			case <-time.After(5 * time.Second):
+				t.Fatalf("%s was not delivered", key)
+			}
+		}
+	}
+	follow("raw_data/france/louvre.json", "raw_data/italy/uffizi.json")
+
+	put("raw_data/spain/prado.json")
+	follow("raw_data/spain/prado.json")
+}
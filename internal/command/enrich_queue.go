@@ -0,0 +1,181 @@
+package command
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/notification"
+	"github.com/segmentio/kafka-go"
+
+	"museum/internal/env"
+	"museum/internal/postgres"
+)
+
+// How the enricher works through the Postgres queue.
+//
+// The lease is far longer than a claim takes to enrich: at Nominatim's one
+// request a second a museum costs two seconds, so a batch is done in well under
+// a minute. What it bounds is how long the objects of an enricher that died
+// wait before another takes them.
+const (
+	queueBatch = 8
+	queueLease = 15 * time.Minute
+	queuePoll  = 5 * time.Second
+)
+
+// generationHeader carries a claimed row's generation on the message standing
+// for it, for CommitOffset to hand back.
+const generationHeader = "enrich-queue-generation"
+
+// queueStore is the part of the database the queue reader needs.
+type queueStore interface {
+	ClaimEnrichment(ctx context.Context, limit int, leaseFor time.Duration) ([]postgres.QueuedObject, error)
+	AckEnrichment(ctx context.Context, id, generation int64) error
+}
+
+// enrichQueue is the enricher's message source when it runs without Kafka.
+//
+// It reads the enrich_queue table that crawl and reindex fill, and hands each
+// claimed object on in the shape a MinIO notification has, so service.Iterator
+// and everything after it are the same whichever source feeds them. Delivery
+// is at least once, as it is from Kafka: a row is deleted only when the
+// museum has been written back, and a row claimed by an enricher that stopped
+// first falls due again when its lease runs out.
+type enrichQueue struct {
+	db       queueStore
+	messages chan kafka.Message
+}
+
+func newEnrichQueue(db queueStore) *enrichQueue {
+	return &enrichQueue{db: db, messages: make(chan kafka.Message)}
+}
+
+// Messages implements service.MessageIterator.
+func (q *enrichQueue) Messages() <-chan kafka.Message { return q.messages }
+
+// CommitOffset implements service.MessageIterator by deleting the row, unless
+// the object was queued again while it was being enriched.
+func (q *enrichQueue) CommitOffset(ctx context.Context, msg kafka.Message) error {
+	for _, h := range msg.Headers {
+		if h.Key != generationHeader {
+			continue
+		}
+		generation, err := strconv.ParseInt(string(h.Value), 10, 64)
+		if err != nil {
+			return err
+		}
+		return q.db.AckEnrichment(ctx, msg.Offset, generation)
+	}
+	return errors.New("message has no queue generation")
+}
+
+// StartConsuming claims objects until ctx ends, and then closes Messages.
+// A failed claim is logged and tried again after the poll interval.
+func (q *enrichQueue) StartConsuming(ctx context.Context) {
+	go func() {
+		defer close(q.messages)
+		for {
+			claimed, err := q.db.ClaimEnrichment(ctx, queueBatch, queueLease)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Cannot claim from the enrich queue: %v", err)
+			}
+			if len(claimed) == 0 {
+				if !wait(ctx, queuePoll) {
+					return
+				}
+				continue
+			}
+			for _, object := range claimed {
+				if object.Attempts > 1 {
+					log.Printf("Enriching %s/%s again (attempt %d)", object.Bucket, object.Key, object.Attempts)
+				}
+				msg, err := queuedMessage(object)
+				if err != nil {
+					log.Printf("Skipping %s/%s: %v", object.Bucket, object.Key, err)
+					continue
+				}
+				// What was claimed and not handed on is left to its lease.
+				select {
+				case q.messages <- msg:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+}
+
+// queuedMessage is a claimed object dressed as the notification MinIO sends
+// when an object is written, with the row's id as its offset.
+func queuedMessage(object postgres.QueuedObject) (kafka.Message, error) {
+	value, err := objectCreated(object.Bucket, object.Key)
+	if err != nil {
+		return kafka.Message{}, err
+	}
+	return kafka.Message{
+		Offset: object.ID,
+		Value:  value,
+		Headers: []kafka.Header{
+			{Key: generationHeader, Value: []byte(strconv.FormatInt(object.Generation, 10))},
+		},
+	}, nil
+}
+
+// objectCreated is the body of the notification MinIO sends when the object
+// at key is written.
+func objectCreated(bucket, key string) ([]byte, error) {
+	var record notification.Event
+	record.EventName = string(notification.ObjectCreatedPut)
+	record.S3.Bucket.Name = bucket
+	record.S3.Object.Key = url.QueryEscape(key)
+	return json.Marshal(notification.Info{Records: []notification.Event{record}})
+}
+
+// enrichQueueName names where enrichers take their work from: the queue flag when
+// one was given, and otherwise ENRICH_QUEUE, which enrich, crawl and reindex
+// share so the writers fill the queue only where an enricher reads it. Kafka
+// is the default, as it always was.
+func enrichQueueName(flag string) (string, error) {
+	env.LoadEnv()
+	queue := cmp.Or(flag, os.Getenv("ENRICH_QUEUE"), "kafka")
+	if queue != "kafka" && queue != "postgres" && queue != "journal" {
+		return "", fmt.Errorf("unknown enrichment queue %q: want kafka, postgres or journal", queue)
+	}
+	return queue, nil
+}
+
+// queueEnrichment asks for the objects at keys to be enriched, when the
+// enrichers read the Postgres queue. With Kafka the bucket's notifications
+// already carry the objects, and rows queued with nobody to claim them would
+// only pile up. A failure is logged rather than returned, since the objects
+// themselves are stored; an enricher reading the queue is sent them by the
+// next reindex.
+func queueEnrichment(ctx context.Context, db *postgres.Store, bucket string, keys []string) {
+	if db == nil || len(keys) == 0 {
+		return
+	}
+	queue, err := enrichQueueName("")
+	if err != nil {
+		log.Printf("Not queueing %d objects for enrichment: %v", len(keys), err)
+		return
+	}
+	if queue != "postgres" {
+		return
+	}
+	queued, err := db.EnqueueEnrichment(ctx, bucket, keys)
+	if err != nil {
+		log.Printf("Could not queue %d objects for enrichment: %v", len(keys), err)
+		return
+	}
+	if queued > 0 {
+		log.Printf("Queued %d objects for enrichment", queued)
+	}
+}
@@ -0,0 +1,93 @@
+package command
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"museum/internal/postgres"
+	"museum/internal/service"
+)
+
+// fakeQueue hands out what it holds once, and records the acknowledgements.
+type fakeQueue struct {
+	mu      sync.Mutex
+	waiting []postgres.QueuedObject
+	acked   chan [2]int64
+}
+
+func (f *fakeQueue) ClaimEnrichment(_ context.Context, limit int, _ time.Duration) ([]postgres.QueuedObject, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := min(limit, len(f.waiting))
+	claimed := f.waiting[:n]
+	f.waiting = f.waiting[n:]
+	return claimed, nil
+}
+
+func (f *fakeQueue) AckEnrichment(_ context.Context, id, generation int64) error {
+	f.acked <- [2]int64{id, generation}
+	return nil
+}
+
+// A claimed row reaches the loader as the object it names, key escaping and
+// all, and acknowledging it acknowledges the generation claimed.
+func TestEnrichQueue_FeedsTheIterator(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := &fakeQueue{
+		waiting: []postgres.QueuedObject{{ID: 7, Generation: 3, Bucket: "museum", Key: "raw_data/france/musée d'orsay+.json"}},
+		acked:   make(chan [2]int64, 1),
+	}
+	queue := newEnrichQueue(db)
+	queue.StartConsuming(ctx)
+
+	iterator := service.NewIterator(queue, func(_ context.Context, bucket, key string) (string, error) {
+		return bucket + "/" + key, nil
+	})
+	objects := iterator.Objects(ctx)
+
+	select {
+	case obj := <-objects:
+		if want := "museum/raw_data/france/musée d'orsay+.json"; obj.Data != want {
+			t.Errorf("loaded %q, want %q", obj.Data, want)
+		}
+		obj.Ack()
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing was delivered")
+	}
+
+	select {
+	case got := <-db.acked:
+		if got != [2]int64{7, 3} {
+			t.Errorf("acknowledged %v, want row 7 at generation 3", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing was acknowledged")
+	}
+}
+
+// The flag wins, then ENRICH_QUEUE, then Kafka, which is what decides whether
+// crawl and reindex queue anything.
+func TestEnrichQueueName(t *testing.T) {
+	t.Setenv("ENRICH_QUEUE", "")
+	if queue, err := enrichQueueName(""); err != nil || queue != "kafka" {
+		t.Errorf("unset = %q, %v; want kafka", queue, err)
+	}
+	t.Setenv("ENRICH_QUEUE", "postgres")
+	if queue, err := enrichQueueName(""); err != nil || queue != "postgres" {
+		t.Errorf("ENRICH_QUEUE=postgres = %q, %v; want postgres", queue, err)
+	}
+	if queue, err := enrichQueueName("kafka"); err != nil || queue != "kafka" {
+		t.Errorf("-queue kafka over ENRICH_QUEUE = %q, %v; want kafka", queue, err)
+	}
+	if queue, err := enrichQueueName("journal"); err != nil || queue != "journal" {
+		t.Errorf("-queue journal = %q, %v; want journal", queue, err)
+	}
+	t.Setenv("ENRICH_QUEUE", "rabbitmq")
+	if _, err := enrichQueueName(""); err == nil {
+		t.Error("an unknown queue was accepted")
+	}
+}
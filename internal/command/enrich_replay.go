@@ -0,0 +1,174 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"museum/internal/enrich"
+	"museum/internal/models"
+	"museum/internal/postgres"
+	"museum/internal/service"
+	"museum/internal/storage"
+	"museum/pkg/graceful"
+)
+
+// deadObject is one stored object with the dead letters being replayed for it.
+type deadObject struct {
+	bucket  string
+	key     string
+	letters []postgres.DeadLetter
+}
+
+// runEnrichReplay sends the objects enrichment gave up on through the pipeline
+// again, here and now rather than through a queue, so it works the same
+// whichever queue the enricher reads. An object that gets through has its dead
+// letters deleted; one that fails again has them updated.
+func runEnrichReplay(ctx context.Context, args []string) error {
+	fs := newFlagSet("enrich replay", "[-stage NAME] [-since DATE]", os.Stderr)
+	stage := fs.String("stage", "", "replay only what failed in this stage: load, location, details, address or store")
+	var since moment
+	fs.Var(&since, "since", "replay only what last failed at or after this date")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireNoArgs("enrich replay", fs.Args()); err != nil {
+		return err
+	}
+
+	rawStore, _, err := museumStore()
+	if err != nil {
+		return err
+	}
+	db, err := database(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, cancel := graceful.Context(ctx)
+	defer cancel()
+
+	letters, err := db.DeadLetters(ctx, *stage, time.Time(since))
+	if err != nil {
+		return err
+	}
+	if len(letters) == 0 {
+		log.Printf("No dead letters to replay")
+		return nil
+	}
+
+	// An object can have failed in more than one stage, and is enriched once.
+	var (
+		objects []*deadObject
+		byKey   = map[[2]string]*deadObject{}
+	)
+	for _, letter := range letters {
+		id := [2]string{letter.Bucket, letter.Key}
+		object, ok := byKey[id]
+		if !ok {
+			object = &deadObject{bucket: letter.Bucket, key: letter.Key}
+			byKey[id] = object
+			objects = append(objects, object)
+		}
+		object.letters = append(object.letters, letter)
+	}
+	log.Printf("Replaying %d dead letters for %d objects", len(letters), len(objects))
+
+	geocoder, err := newGeocoding(db)
+	if err != nil {
+		return err
+	}
+	replay := &replayer{db: db, recorder: &deadLetters{db: db}, load: loadMuseum(rawStore), geocoder: geocoder}
+	for _, object := range objects {
+		if ctx.Err() != nil {
+			return fmt.Errorf("interrupted; %d of %d objects replayed", replay.replayed+replay.failed, len(objects))
+		}
+		replay.object(ctx, rawStore, object)
+	}
+	log.Printf("Replayed %d objects: %d got through, %d failed again", len(objects), replay.replayed, replay.failed)
+	return nil
+}
+
+// replayer runs dead objects through enrichment one at a time.
+type replayer struct {
+	db       *postgres.Store
+	recorder *deadLetters
+	load     service.LoaderFunc[*models.Museum]
+	geocoder *geocoding
+
+	replayed int
+	failed   int
+}
+
+// object replays one dead object. Its letters are deleted for the stages that
+// now succeed, which is all of them when it gets through; a stage that fails
+// again updates its letter instead.
+func (r *replayer) object(ctx context.Context, rawStore *storage.S3Service[models.Museum], object *deadObject) {
+	var museum *models.Museum
+	attempts, err := enrichRetry.Run(ctx, func() (err error) {
+		museum, err = r.load(ctx, object.bucket, object.key)
+		return err
+	})
+	if ctx.Err() != nil {
+		return
+	}
+	if storage.IsNotFound(err) {
+		// Nothing is left to enrich, so nothing is left to fail.
+		log.Printf("%s/%s is gone; dropping its dead letters", object.bucket, object.key)
+		r.settle(ctx, object, nil)
+		r.replayed++
+		return
+	}
+	if err != nil {
+		r.fail(ctx, object, enrich.Failure{Stage: service.LoadStage, Err: err, Attempts: attempts})
+		return
+	}
+
+	failedIn := map[string]bool{}
+	item := enrich.NewItem(museum)
+	item.OnFailed = func(f enrich.Failure) {
+		failedIn[f.Stage] = true
+		if err := r.recorder.record(ctx, object.bucket, object.key, f); err != nil {
+			log.Printf("Cannot record the failure of %s/%s: %v", object.bucket, object.key, err)
+		}
+	}
+	in := make(chan *museumItem, 1)
+	in <- item
+	close(in)
+	enrichPipeline(rawStore, object.bucket, r.geocoder).Process(ctx, in)
+
+	if ctx.Err() != nil {
+		return
+	}
+	r.settle(ctx, object, failedIn)
+	if len(failedIn) > 0 {
+		r.failed++
+		return
+	}
+	r.replayed++
+}
+
+// fail records that an object failed again before reaching the pipeline. Its
+// other letters stay: none of their stages was run.
+func (r *replayer) fail(ctx context.Context, object *deadObject, f enrich.Failure) {
+	r.failed++
+	if err := r.recorder.record(ctx, object.bucket, object.key, f); err != nil {
+		log.Printf("Cannot record the failure of %s/%s: %v", object.bucket, object.key, err)
+	}
+}
+
+// settle deletes the object's letters for every stage not in failedIn.
+func (r *replayer) settle(ctx context.Context, object *deadObject, failedIn map[string]bool) {
+	var ids []int64
+	for _, letter := range object.letters {
+		if !failedIn[letter.Stage] {
+			ids = append(ids, letter.ID)
+		}
+	}
+	if err := r.db.DeleteDeadLetters(ctx, ids); err != nil {
+		log.Printf("Cannot delete the dead letters of %s/%s: %v", object.bucket, object.key, err)
+	}
+}
@@ -7,25 +7,29 @@ import (
 	"log"
 	"strings"
 
+	"museum/internal/enrich"
 	"museum/internal/models"
 	"museum/internal/storage"
 	"museum/pkg/location"
+	"museum/pkg/osm"
 )
 
-// StepLocation geocodes the museum against Nominatim and merges the result into
-// the item.
+// StepLocation geocodes the museum and merges the result into the item.
 //
 // The query is built from the museum's own metadata rather than its name alone:
 // "Louvre" on its own matches places worldwide, while name + locality + country
 // pins it down.
-func StepLocation(ctx context.Context, item *museumItem) error {
-	loc, err := location.Geocode(ctx, geocodeQuery(item.Object))
+func (g *geocoding) StepLocation(ctx context.Context, item *museumItem) error {
+	loc, err := g.Geocode(ctx, geocodeQuery(item.Object))
 	if err != nil {
 		if errors.Is(err, location.ErrNoResults) {
 			// Not every museum is in OpenStreetMap; that is not a failure.
 			item.Set("geocoded", false)
 			return nil
 		}
+		if errors.Is(err, location.ErrRejected) {
+			return enrich.Permanent(err)
+		}
 		return err
 	}
 
@@ -74,21 +78,100 @@ func slicesContainsFold(values []string, s string) bool {
 // The osm_type / osm_id keys are the JSON field names produced by merging a
 // NominatimLocation, and osm_id arrives as a JSON number, so it is read through
 // the item's typed accessors rather than asserted directly.
-func StepLocationDetails(ctx context.Context, item *museumItem) error {
-	osmType, hasType := item.String("osm_type")
-	osmID, hasID := item.Int64("osm_id")
-	if !hasType || !hasID {
-		// Nothing was geocoded; there is nothing to look up.
+//
+// Only Nominatim has details to give, so without it among the geocoders the
+// step does nothing.
+func (g *geocoding) StepLocationDetails(ctx context.Context, item *museumItem) error {
+	if g.details == nil {
+		return nil
+	}
+	osmType, _ := item.String("osm_type")
+	osmID, _ := item.Int64("osm_id")
+	if osmType == "" || osmID == 0 {
+		// Nothing was geocoded, or what placed it was not an OpenStreetMap
+		// element; there is nothing to look up.
 		return nil
 	}
 
-	details, err := location.PlaceDetails(ctx, osmType, osmID)
+	details, err := g.details.PlaceDetails(ctx, osmType, osmID)
+	if errors.Is(err, location.ErrRejected) {
+		return enrich.Permanent(err)
+	}
 	if err != nil {
 		return err
 	}
 	return item.Merge(details)
 }
 
+// StepAddress reverse geocodes a museum that has a position but no address,
+// which is most of what Wikipedia supplies coordinates for: geocoding was
+// never asked about them, and a geocoder's answer to a name is often a town.
+//
+// A museum that came from OpenStreetMap is read by its own element first, so
+// the address is the one mapped on the museum rather than on whatever
+// building is nearest its pin; the position is asked about only when the
+// element has gone or no geocoder can read one.
+//
+// Finding no address records nothing: the enricher works on objects, not on
+// catalogue rows with an address_checked_at to set. The next run asks again,
+// and the geocode cache answers it, holding the miss for
+// GEOCODE_CACHE_MISS_TTL and a town-only answer for GEOCODE_CACHE_TTL. An
+// enricher with no database has no cache, and asks the geocoders each time.
+// "locate -reverse" works on the catalogue, and marks the museum instead.
+func (g *geocoding) StepAddress(ctx context.Context, item *museumItem) error {
+	museum := item.Object
+	if museum == nil || museum.Address.Street() != "" || museum.Address.Postcode != "" {
+		return nil
+	}
+	lat, lon := museum.Latitude, museum.Longitude
+	if !museum.HasCoordinates() {
+		lat, lon = coordinatesFrom(item.Results())
+	}
+	if lat == 0 && lon == 0 {
+		return nil
+	}
+
+	found, err := g.findAddress(ctx, museum.SourcePage, lat, lon)
+	switch {
+	case errors.Is(err, location.ErrNoResults):
+		return nil
+	case errors.Is(err, location.ErrRejected):
+		return enrich.Permanent(err)
+	case err != nil:
+		return err
+	}
+
+	if found.Address.Street() == "" && found.Address.Postcode == "" {
+		// A town and a country say nothing the museum does not already.
+		return nil
+	}
+	museum.Address = found.Address
+	if museum.Locality == "" {
+		museum.Locality = found.Locality()
+	}
+	item.Set("address_geocoder", found.Geocoder)
+	return nil
+}
+
+// findAddress asks for the address of the OpenStreetMap element sourcePage
+// names, then for the one at lat, lon. It returns ErrNoResults when neither
+// is known, or when the geocoders can do neither.
+func (g *geocoding) findAddress(ctx context.Context, sourcePage string, lat, lon float64) (*location.NominatimLocation, error) {
+	if osmType, osmID, ok := osm.ElementOf(sourcePage); ok && g.lookup != nil {
+		found, err := g.lookup.Lookup(ctx, osmType, osmID)
+		if err == nil && !found.Address.IsZero() {
+			return found, nil
+		}
+		if err != nil && !errors.Is(err, location.ErrNoResults) {
+			return nil, err
+		}
+	}
+	if g.reverse == nil {
+		return nil, location.ErrNoResults
+	}
+	return g.reverse.Reverse(ctx, lat, lon)
+}
+
 // s3Sink writes finished items back to object storage.
 type s3Sink struct {
 	store  *storage.S3Service[models.EnrichedMuseum]
@@ -104,7 +187,7 @@ type s3Sink struct {
 // the topic the enricher is consuming.
 func (s *s3Sink) Store(ctx context.Context, item *museumItem) error {
 	if item.Object == nil {
-		return fmt.Errorf("cannot store enriched record: museum is nil")
+		return enrich.Permanent(errors.New("cannot store enriched record: museum is nil"))
 	}
 
 	record := models.EnrichedMuseum{
@@ -0,0 +1,165 @@
+package command
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"museum/internal/memory"
+	"museum/internal/postgres"
+	"museum/internal/sqlite"
+	"museum/pkg/exhibitions"
+)
+
+// exportCommand writes the catalogue out of the database, for serving where
+// the database is not.
+func exportCommand() Command {
+	return Command{
+		Name:    "export",
+		Summary: "Write the catalogue to a file that can be served without the database",
+		Usage:   "[-format sqlite|ndjson] [-out FILE]",
+		Run:     runExport,
+	}
+}
+
+func runExport(ctx context.Context, args []string) error {
+	fs := newFlagSet("export", "[-format sqlite|ndjson] [-out FILE]", os.Stderr)
+	format := fs.String("format", "sqlite", "file format: sqlite, or ndjson for a file served from memory")
+	out := fs.String("out", "", "file to write, museums.FORMAT by default; replaced only once the export has finished")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireNoArgs("export", fs.Args()); err != nil {
+		return err
+	}
+	if *format != "sqlite" && *format != "ndjson" {
+		return fmt.Errorf("export: unknown format %q", *format)
+	}
+	if *out == "" {
+		*out = "museums." + *format
+	}
+
+	db, err := database(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if *format == "ndjson" {
+		return exportNDJSON(ctx, db, *out)
+	}
+	return exportSQLite(ctx, db, *out)
+}
+
+// exportSQLite copies the catalogue into a SQLite file for "serve -catalogue".
+//
+// Exhibitions that have closed are left out: no query returns them, and on a
+// long-running database they are most of the table.
+func exportSQLite(ctx context.Context, db *postgres.Store, path string) error {
+	start := time.Now()
+
+	// Read first, so that a write landing during the export leaves the file
+	// claiming an older generation than it holds. A client then revalidates
+	// once too often, which is harmless; the other way round it would keep a
+	// response the file has since overtaken.
+	generation, err := db.Generation(ctx)
+	if err != nil {
+		return err
+	}
+
+	w, err := sqlite.Create(ctx, path)
+	if err != nil {
+		return err
+	}
+	var museums, shows int
+	err = db.EachCatalogueRow(ctx, func(row postgres.CatalogueRow) error {
+		museums++
+		return w.AddMuseum(ctx, row)
+	})
+	if err == nil {
+		err = db.EachLiveExhibition(ctx, func(e exhibitions.Exhibition) error {
+			shows++
+			return w.AddExhibition(ctx, e)
+		})
+	}
+	if err == nil {
+		err = db.EachSiteAttempt(ctx, func(site string, at time.Time) error {
+			return w.AddSiteAttempt(ctx, site, at)
+		})
+	}
+	if err == nil {
+		err = db.EachPlace(ctx, func(p postgres.Place) error {
+			return w.AddPlace(ctx, p)
+		})
+	}
+	if err == nil {
+		err = w.SetGeneration(ctx, generation)
+	}
+	if err != nil {
+		w.Abort()
+		return fmt.Errorf("export: %w", err)
+	}
+	if err := w.Close(ctx); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+
+	log.Printf("Exported %d museums and %d exhibitions to %s in %s",
+		museums, shows, path, time.Since(start).Round(time.Second))
+	return nil
+}
+
+// exportNDJSON writes the catalogue as one JSON record per line, the form
+// "serve -catalogue FILE.ndjson" loads into memory. Like the SQLite file it
+// leaves out exhibitions that have closed.
+func exportNDJSON(ctx context.Context, db *postgres.Store, path string) error {
+	start := time.Now()
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	buffered := bufio.NewWriter(f)
+	encoder := json.NewEncoder(buffered)
+	encoder.SetEscapeHTML(false)
+
+	var museums, shows int
+	err = db.EachCatalogueRow(ctx, func(row postgres.CatalogueRow) error {
+		museums++
+		return encoder.Encode(memory.Record{
+			ID: row.ID, Museum: &row.Museum, RetiredAt: row.RetiredAt,
+			ApproximateLocation: row.ApproximateLocation,
+		})
+	})
+	if err == nil {
+		err = db.EachLiveExhibition(ctx, func(e exhibitions.Exhibition) error {
+			shows++
+			return encoder.Encode(memory.Record{Exhibition: &e})
+		})
+	}
+	if err == nil {
+		err = db.EachSiteAttempt(ctx, func(site string, at time.Time) error {
+			return encoder.Encode(memory.Record{Site: site, AttemptedAt: &at})
+		})
+	}
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("export: %w", err)
+	}
+
+	log.Printf("Exported %d museums and %d exhibitions to %s in %s",
+		museums, shows, path, time.Since(start).Round(time.Second))
+	return nil
+}
@@ -0,0 +1,175 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"museum/internal/models"
+	"museum/internal/postgres"
+	"museum/pkg/graceful"
+	"museum/pkg/location"
+)
+
+// geocacheCommand looks after the cache of geocoders' answers that enrich,
+// locate and the API share.
+func geocacheCommand() Command {
+	return Command{
+		Name:    "geocache",
+		Summary: "Report on, purge or warm the cache of geocoders' answers",
+		Usage:   "(stats [-json] | purge [-provider NAME] [-misses] [-all] | warm [-country NAME] [-limit N])",
+		Run:     runGeocache,
+	}
+}
+
+func runGeocache(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("geocache needs an action: stats, purge or warm")
+	}
+	action, rest := args[0], args[1:]
+
+	db, err := database(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch action {
+	case "stats":
+		return geocacheStats(ctx, db, rest)
+	case "purge":
+		return geocachePurge(ctx, db, rest)
+	case "warm":
+		return geocacheWarm(ctx, db, rest)
+	default:
+		return fmt.Errorf("unknown action %q, want stats, purge or warm", action)
+	}
+}
+
+func geocacheStats(ctx context.Context, db *postgres.Store, args []string) error {
+	fs := newFlagSet("geocache stats", "[-json]", os.Stderr)
+	asJSON := fs.Bool("json", false, "emit JSON instead of a table")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireNoArgs("geocache stats", fs.Args()); err != nil {
+		return err
+	}
+
+	stats, err := db.GeocodeCacheStats(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		if stats == nil {
+			stats = []postgres.GeocodeCacheStats{}
+		}
+		return emitJSON(stats)
+	}
+	if len(stats) == 0 {
+		fmt.Println("The geocode cache is empty.")
+		return nil
+	}
+	fmt.Printf("%-10s %-8s %9s %9s %9s %11s  %s\n", "PROVIDER", "KIND", "ENTRIES", "MISSES", "EXPIRED", "HITS", "OLDEST")
+	for _, st := range stats {
+		fmt.Printf("%-10s %-8s %9d %9d %9d %11d  %s\n", st.Provider, st.Kind,
+			st.Entries, st.Misses, st.Expired, st.Hits, st.Oldest.Local().Format("2006-01-02"))
+	}
+	return nil
+}
+
+func geocachePurge(ctx context.Context, db *postgres.Store, args []string) error {
+	fs := newFlagSet("geocache purge", "[-provider NAME] [-misses] [-all]", os.Stderr)
+	var purge postgres.GeocodeCachePurge
+	fs.StringVar(&purge.Provider, "provider", "", "only this provider's answers: nominatim or photon")
+	fs.BoolVar(&purge.Misses, "misses", false, "also remove remembered misses, so they are asked again")
+	fs.BoolVar(&purge.All, "all", false, "remove every entry, not only the expired ones")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireNoArgs("geocache purge", fs.Args()); err != nil {
+		return err
+	}
+
+	purged, err := db.PurgeGeocodeCache(ctx, purge)
+	if err != nil {
+		return err
+	}
+	log.Printf("Purged %d geocode cache entries", purged)
+	return nil
+}
+
+// geocacheWarm asks the geocoders what enrichment will ask them about the
+// catalogue's museums, so a re-enrich after a re-crawl answers from the cache
+// rather than at the geocoder's pace. What is already cached costs nothing.
+func geocacheWarm(ctx context.Context, db *postgres.Store, args []string) error {
+	fs := newFlagSet("geocache warm", "[-country NAME] [-limit N]", os.Stderr)
+	country := fs.String("country", "", "only museums in this country")
+	limit := fs.Int("limit", 1000, "how many museums to ask about")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireNoArgs("geocache warm", fs.Args()); err != nil {
+		return err
+	}
+	if *limit < 1 {
+		return errors.New("limit must be a positive whole number")
+	}
+
+	ctx, cancel := graceful.Context(ctx)
+	defer cancel()
+
+	// The queries are gathered first, so the catalogue is not held open for
+	// as long as the geocoders take.
+	var queries []string
+	seen := map[string]bool{}
+	if err := db.EachMuseum(ctx, func(_ int64, m models.Museum) {
+		if len(queries) >= *limit || *country != "" && !strings.EqualFold(m.Country, *country) {
+			return
+		}
+		query := geocodeQuery(&m)
+		if key := location.CacheKey(query); key != "" && !seen[key] {
+			seen[key] = true
+			queries = append(queries, query)
+		}
+	}); err != nil {
+		return err
+	}
+	if len(queries) == 0 {
+		log.Println("Nothing to warm: no museum matches")
+		return nil
+	}
+
+	geocoder, err := newGeocoding(db)
+	if err != nil {
+		return err
+	}
+	log.Printf("Warming the geocode cache with %d queries (at most %s for those not yet cached)",
+		len(queries), (time.Duration(len(queries)) * geocoder.interval).Round(time.Second))
+
+	start := time.Now()
+	var found, missing, failed int
+	for _, query := range queries {
+		if ctx.Err() != nil {
+			log.Printf("Interrupted after %d of %d queries", found+missing+failed, len(queries))
+			return nil
+		}
+		_, err := geocoder.Geocode(ctx, query)
+		switch {
+		case err == nil:
+			found++
+		case errors.Is(err, location.ErrNoResults):
+			missing++
+		default:
+			log.Printf("  %q: %v", query, err)
+			failed++
+		}
+	}
+	log.Printf("Finished in %s: %d found, %d not found, %d failed and not cached",
+		time.Since(start).Round(time.Second), found, missing, failed)
+	return nil
+}
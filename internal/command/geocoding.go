@@ -0,0 +1,157 @@
+package command
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"museum/internal/postgres"
+	"museum/pkg/location"
+)
+
+// geocoding is the geocoder the commands share, built from GEOCODERS: one
+// provider, or several asked in turn.
+type geocoding struct {
+	location.Geocoder
+	// details is Nominatim's place details lookup, nil when Nominatim is not
+	// one of the providers. No other provider has one.
+	details location.Detailer
+	// reverse finds the address at a position, nil when no provider can: a
+	// GeoNames dump knows towns, not streets.
+	reverse location.ReverseGeocoder
+	// lookup reads an OpenStreetMap element by its id. Like details, only
+	// Nominatim has it.
+	lookup location.ElementLookup
+	// interval is how far apart the first provider takes requests, which is
+	// what a run's length is bounded by.
+	interval time.Duration
+}
+
+// defaultPhotonInterval spaces requests to a Photon server. It has no usage
+// policy to honour when self-hosted, so this only keeps a run from
+// monopolising it.
+const defaultPhotonInterval = 50 * time.Millisecond
+
+// newGeocoding builds the geocoder GEOCODERS names, Nominatim alone if it is
+// unset. Each provider is rate-limited on its own, at the interval its
+// variable sets:
+//
+//	nominatim  NOMINATIM_URL (the public instance), NOMINATIM_INTERVAL (1.1s)
+//	photon     PHOTON_URL (required), PHOTON_INTERVAL (50ms)
+//	geonames   GEONAMES_FILE (required): a cities500 or allCountries dump
+//
+// With db, Nominatim's and Photon's answers are kept in its geocode cache for
+// GEOCODE_CACHE_TTL, and misses for GEOCODE_CACHE_MISS_TTL; a TTL of 0 stops
+// them being kept. GeoNames is already local and is not cached. Without db,
+// as for an enricher that cannot reach one, every question goes upstream.
+func newGeocoding(db *postgres.Store) (*geocoding, error) {
+	names := os.Getenv("GEOCODERS")
+	if names == "" {
+		names = "nominatim"
+	}
+
+	cache := func(_ string, provider location.Geocoder) location.Geocoder { return provider }
+	if db != nil {
+		ttl, err := geocodeCacheTTL()
+		if err != nil {
+			return nil, err
+		}
+		cache = func(name string, provider location.Geocoder) location.Geocoder {
+			return location.Cached(name, provider, db, ttl)
+		}
+	}
+
+	g := &geocoding{}
+	var providers []location.Geocoder
+	for i, name := range strings.Split(names, ",") {
+		var (
+			provider location.Geocoder
+			interval time.Duration
+			err      error
+		)
+		switch name = strings.TrimSpace(name); name {
+		case "nominatim":
+			interval, err = envDuration("NOMINATIM_INTERVAL", location.NominatimInterval)
+			if err != nil {
+				return nil, err
+			}
+			baseURL := cmp.Or(os.Getenv("NOMINATIM_URL"), location.PublicNominatim)
+			if baseURL == location.PublicNominatim {
+				interval = max(interval, location.NominatimInterval)
+			}
+			provider = cache(name, location.NewNominatim(baseURL, interval))
+			g.details, _ = provider.(location.Detailer)
+			g.lookup, _ = provider.(location.ElementLookup)
+		case "photon":
+			baseURL := os.Getenv("PHOTON_URL")
+			if baseURL == "" {
+				return nil, fmt.Errorf("GEOCODERS names photon: set PHOTON_URL to its server")
+			}
+			interval, err = envDuration("PHOTON_INTERVAL", defaultPhotonInterval)
+			if err != nil {
+				return nil, err
+			}
+			provider = cache(name, location.NewPhoton(baseURL, interval))
+		case "geonames":
+			file := os.Getenv("GEONAMES_FILE")
+			if file == "" {
+				return nil, fmt.Errorf("GEOCODERS names geonames: set GEONAMES_FILE to a GeoNames dump")
+			}
+			started := time.Now()
+			geonames, err := location.LoadGeoNames(file)
+			if err != nil {
+				return nil, err
+			}
+			log.Printf("Loaded GeoNames from %s in %s", file, time.Since(started).Round(time.Millisecond))
+			provider = geonames
+		default:
+			return nil, fmt.Errorf("unknown geocoder %q in GEOCODERS: want nominatim, photon or geonames", name)
+		}
+		if i == 0 {
+			g.interval = interval
+		}
+		providers = append(providers, provider)
+	}
+
+	if len(providers) == 1 {
+		g.Geocoder = providers[0]
+		g.reverse, _ = providers[0].(location.ReverseGeocoder)
+	} else {
+		chain := location.NewChain(providers...)
+		g.Geocoder = chain
+		if chain.Reverses() {
+			g.reverse = chain
+		}
+	}
+	return g, nil
+}
+
+// geocodeCacheTTL reads how long the geocode cache keeps answers, and misses.
+func geocodeCacheTTL() (location.CacheTTL, error) {
+	found, err := envDuration("GEOCODE_CACHE_TTL", location.DefaultCacheTTL.Found)
+	if err != nil {
+		return location.CacheTTL{}, err
+	}
+	notFound, err := envDuration("GEOCODE_CACHE_MISS_TTL", location.DefaultCacheTTL.NotFound)
+	if err != nil {
+		return location.CacheTTL{}, err
+	}
+	return location.CacheTTL{Found: found, NotFound: notFound}, nil
+}
+
+// envDuration reads a duration from the environment, or returns fallback if
+// the variable is unset.
+func envDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s=%q: want a duration such as 1s or 200ms", key, value)
+	}
+	return d, nil
+}
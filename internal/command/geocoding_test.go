@@ -0,0 +1,174 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"museum/internal/enrich"
+	"museum/internal/models"
+	"museum/pkg/location"
+)
+
+func TestNewGeocoding(t *testing.T) {
+	dump := filepath.Join(t.TempDir(), "cities500.txt")
+	row := "2711537\tGothenburg\tGothenburg\t\t57.70716\t11.96679\tP\tPPLA\tSE\t\t28\t1480\t\t\t572799\t\t10\tEurope/Stockholm\t2023-01-01\n"
+	if err := os.WriteFile(dump, []byte(row), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PHOTON_URL", "http://photon.internal:2322")
+	t.Setenv("GEONAMES_FILE", dump)
+
+	t.Setenv("GEOCODERS", "")
+	g, err := newGeocoding(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := g.Geocoder.(*location.Nominatim); !ok || g.details == nil || g.lookup == nil || g.reverse == nil ||
+		g.interval != location.NominatimInterval {
+		t.Errorf("unset = %T, details %v, every %s; want Nominatim alone at its policy's rate", g.Geocoder, g.details, g.interval)
+	}
+
+	t.Setenv("GEOCODERS", "photon, geonames")
+	t.Setenv("PHOTON_INTERVAL", "10ms")
+	if g, err = newGeocoding(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := g.Geocoder.(*location.Chain); !ok || g.details != nil || g.interval != 10*time.Millisecond {
+		t.Errorf("photon, geonames = %T, details %v, every %s", g.Geocoder, g.details, g.interval)
+	}
+	if g.reverse == nil || g.lookup != nil {
+		t.Errorf("photon, geonames: reverse %v, lookup %v; want Photon to reverse and nothing to look up", g.reverse, g.lookup)
+	}
+
+	// A GeoNames dump has no addresses to reverse geocode into.
+	t.Setenv("GEOCODERS", "geonames")
+	if g, err = newGeocoding(nil); err != nil || g.reverse != nil {
+		t.Errorf("geonames alone: reverse %v, %v", g.reverse, err)
+	}
+
+	// The public instance is never asked faster than its policy allows.
+	t.Setenv("GEOCODERS", "nominatim")
+	t.Setenv("NOMINATIM_INTERVAL", "100ms")
+	if g, err = newGeocoding(nil); err != nil || g.interval != location.NominatimInterval {
+		t.Errorf("public nominatim at 100ms = every %v, %v", g.interval, err)
+	}
+
+	for _, bad := range []string{"bing", "nominatim,,photon"} {
+		t.Setenv("GEOCODERS", bad)
+		if _, err := newGeocoding(nil); err == nil {
+			t.Errorf("GEOCODERS=%q was accepted", bad)
+		}
+	}
+	t.Setenv("GEOCODERS", "photon")
+	t.Setenv("PHOTON_URL", "")
+	if _, err := newGeocoding(nil); err == nil {
+		t.Error("photon without PHOTON_URL was accepted")
+	}
+}
+
+// addresses answers lookups and reverse geocodes from fixed maps, counting
+// what it is asked.
+type addresses struct {
+	elements map[string]models.Address
+	at       models.Address
+	lookups  int
+	reverses int
+}
+
+func (a *addresses) Geocode(context.Context, string) (*location.NominatimLocation, error) {
+	return nil, location.ErrNoResults
+}
+
+func (a *addresses) Lookup(_ context.Context, osmType string, osmID int64) (*location.NominatimLocation, error) {
+	a.lookups++
+	address, ok := a.elements[fmt.Sprintf("%s/%d", osmType, osmID)]
+	if !ok {
+		return nil, location.ErrNoResults
+	}
+	return &location.NominatimLocation{Address: address, Geocoder: "nominatim"}, nil
+}
+
+func (a *addresses) Reverse(context.Context, float64, float64) (*location.NominatimLocation, error) {
+	a.reverses++
+	if a.at.IsZero() {
+		return nil, location.ErrNoResults
+	}
+	return &location.NominatimLocation{Address: a.at, Geocoder: "nominatim"}, nil
+}
+
+func TestStepAddress(t *testing.T) {
+	mapped := models.Address{HouseNumber: "37", Road: "Vasagatan", Postcode: "411 37", City: "Gothenburg"}
+	nearest := models.Address{HouseNumber: "39", Road: "Vasagatan", City: "Gothenburg"}
+	source := &addresses{elements: map[string]models.Address{"way/28563412": mapped}, at: nearest}
+	g := &geocoding{lookup: source, reverse: source}
+	ctx := context.Background()
+
+	// The museum's own element is preferred over the building nearest its pin.
+	item := enrich.NewItem(&models.Museum{Name: "Röhsska museet", Latitude: 57.6984, Longitude: 11.9741, SourcePage: "way/28563412"})
+	if err := g.StepAddress(ctx, item); err != nil {
+		t.Fatal(err)
+	}
+	if item.Object.Address != mapped || item.Object.Locality != "Gothenburg" || source.reverses != 0 {
+		t.Errorf("address = %+v, locality %q, %d reverse geocodes", item.Object.Address, item.Object.Locality, source.reverses)
+	}
+
+	// A museum from a Wikipedia list has no element, so its position is asked.
+	item = enrich.NewItem(&models.Museum{Name: "Museum", Latitude: 57.6984, Longitude: 11.9741,
+		SourcePage: "https://en.wikipedia.org/wiki/List_of_museums_in_Sweden"})
+	if err := g.StepAddress(ctx, item); err != nil {
+		t.Fatal(err)
+	}
+	if item.Object.Address != nearest || source.reverses != 1 {
+		t.Errorf("address = %+v after %d reverse geocodes", item.Object.Address, source.reverses)
+	}
+
+	// Nothing is asked for a museum with an address, or without a position.
+	for _, museum := range []*models.Museum{
+		{Name: "Addressed", Latitude: 1, Longitude: 1, Address: models.Address{Postcode: "411 37"}},
+		{Name: "Unplaced"},
+	} {
+		if err := g.StepAddress(ctx, enrich.NewItem(museum)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if source.lookups != 1 || source.reverses != 1 {
+		t.Errorf("%d lookups and %d reverse geocodes, want 1 of each", source.lookups, source.reverses)
+	}
+
+	// A position with no address is not a failure.
+	source.at = models.Address{}
+	if err := g.StepAddress(ctx, enrich.NewItem(&models.Museum{Name: "At sea", Latitude: 1, Longitude: 1})); err != nil {
+		t.Errorf("no address: %v", err)
+	}
+
+	// Nor is it recorded: the next run asks again, and the cached miss answers.
+	cached := location.Cached("photon", source, geocodeCache{}, location.DefaultCacheTTL)
+	g = &geocoding{reverse: cached.(location.ReverseGeocoder)}
+	before := source.reverses
+	for range 2 {
+		item := enrich.NewItem(&models.Museum{Name: "At sea", Latitude: 1, Longitude: 1})
+		if err := g.StepAddress(ctx, item); err != nil || !item.Object.Address.IsZero() {
+			t.Errorf("no address = %+v, %v", item.Object.Address, err)
+		}
+	}
+	if asked := source.reverses - before; asked != 1 {
+		t.Errorf("asked %d times about a position with no address, want once", asked)
+	}
+}
+
+// geocodeCache is a location.CacheStore in a map, with no expiry.
+type geocodeCache map[string][]byte
+
+func (c geocodeCache) CachedGeocode(_ context.Context, provider, kind, key string) ([]byte, bool, error) {
+	response, ok := c[provider+" "+kind+" "+key]
+	return response, ok, nil
+}
+
+func (c geocodeCache) CacheGeocode(_ context.Context, provider, kind, key string, response []byte, _ time.Duration) error {
+	c[provider+" "+kind+" "+key] = response
+	return nil
+}
@@ -14,12 +14,14 @@ import (
 	"museum/pkg/location"
 )
 
-// locateCommand geocodes museums the catalogue holds but cannot place.
+// locateCommand geocodes museums the catalogue holds but cannot place, or with
+// -reverse, finds addresses for museums it can place but cannot give a street
+// for.
 func locateCommand() Command {
 	return Command{
 		Name:    "locate",
-		Summary: "Geocode museums that have no coordinates",
-		Usage:   "[-locality NAME] [-country NAME] [-limit N] [-dry-run]",
+		Summary: "Geocode museums that have no coordinates, or no address",
+		Usage:   "[-reverse] [-locality NAME] [-country NAME] [-limit N] [-dry-run]",
 		Run:     runLocate,
 	}
 }
@@ -32,7 +34,7 @@ func locateCommand() Command {
 // through the event pipeline, so a record that was loaded without a position
 // stayed that way with no means of repair.
 func runLocate(ctx context.Context, args []string) error {
-	fs := newFlagSet("locate", "[-locality NAME] [-country NAME] [-limit N] [-dry-run]", os.Stderr)
+	fs := newFlagSet("locate", "[-reverse] [-locality NAME] [-country NAME] [-limit N] [-dry-run]", os.Stderr)
 	var (
 		locality = fs.String("locality", "", "only museums whose town matches this")
 		country  = fs.String("country", "", "only museums in this country")
@@ -40,6 +42,8 @@ func runLocate(ctx context.Context, args []string) error {
 		dryRun   = fs.Bool("dry-run", false, "report what would be geocoded, without calling the geocoder")
 		townOnly = fs.Bool("town-centres", false,
 			"skip the geocoder and place every museum at the centre of its recorded town")
+		reverse = fs.Bool("reverse", false,
+			"find addresses for museums that have coordinates but no street or postcode")
 	)
 	if err := fs.Parse(args); err != nil {
 		return err
@@ -50,6 +54,9 @@ func runLocate(ctx context.Context, args []string) error {
 	if *limit < 1 {
 		return errors.New("limit must be a positive whole number")
 	}
+	if *reverse && *townOnly {
+		return errors.New("-reverse and -town-centres do not go together: one places museums, the other addresses them")
+	}
 
 	db, err := database(ctx)
 	if err != nil {
@@ -60,6 +67,10 @@ func runLocate(ctx context.Context, args []string) error {
 	ctx, cancel := graceful.Context(ctx)
 	defer cancel()
 
+	if *reverse {
+		return locateAddresses(ctx, db, *locality, *country, *limit, *dryRun)
+	}
+
 	start := time.Now()
 
 	// Town-centre placement runs before the unplaced-museum check, because it
@@ -94,13 +105,19 @@ func runLocate(ctx context.Context, args []string) error {
 		return nil
 	}
 
+	geocoder, err := newGeocoding(db)
+	if err != nil {
+		return err
+	}
+
 	// The geocoder's advertised one request per second is a floor, not a
 	// promise: a run of 114 museums took 79 minutes because the public instance
 	// kept refusing and the backoff kept widening. Saying so up front matters,
 	// because the honest estimate for tens of thousands is weeks rather than
-	// hours, and -town-centres exists precisely to avoid that.
+	// hours, and -town-centres and the other geocoders exist precisely to
+	// avoid that.
 	log.Printf("Locating %d museums (at least %s, and in practice far longer if the geocoder throttles)",
-		len(pending), (time.Duration(len(pending)) * 1100 * time.Millisecond).Round(time.Second))
+		len(pending), (time.Duration(len(pending)) * geocoder.interval).Round(time.Second))
 
 	if *dryRun {
 		for _, m := range pending {
@@ -130,7 +147,7 @@ func runLocate(ctx context.Context, args []string) error {
 			approximate bool
 		)
 
-		found, err := location.Geocode(ctx, query)
+		found, err := geocoder.Geocode(ctx, query)
 		switch {
 		case err == nil:
 			if lat, lon, err = found.Coordinates(); err != nil {
@@ -138,6 +155,9 @@ func runLocate(ctx context.Context, args []string) error {
 				unresolved++
 				continue
 			}
+			// A geocoder that matched only the town, as GeoNames always
+			// does, has placed the museum no better than its town centre.
+			approximate = found.Approximate()
 
 		case errors.Is(err, location.ErrNoResults):
 			// The geocoder does not know this museum by name — many are
@@ -177,6 +197,81 @@ func runLocate(ctx context.Context, args []string) error {
 	return nil
 }
 
+// locateAddresses reverse geocodes stored museums that have a position but
+// neither a street nor a postcode, the way the enrichment pipeline's address
+// stage does for museums passing through it.
+//
+// A museum whose position has no address is marked as asked about, so the
+// next run spends the geocoder's rate limit on museums it has not tried. One
+// that failed for any other reason is left to be tried again.
+func locateAddresses(ctx context.Context, db *postgres.Store, locality, country string, limit int, dryRun bool) error {
+	pending, err := db.UnaddressedMuseums(ctx, locality, country, limit)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		log.Println("Nothing to address: every matching museum has a street or postcode, or has been asked about")
+		return nil
+	}
+
+	geocoder, err := newGeocoding(db)
+	if err != nil {
+		return err
+	}
+	if geocoder.reverse == nil && geocoder.lookup == nil {
+		return errors.New("no geocoder in GEOCODERS can reverse geocode: name nominatim or photon")
+	}
+
+	log.Printf("Addressing %d museums (at least %s, and in practice longer if the geocoder throttles)",
+		len(pending), (time.Duration(len(pending)) * geocoder.interval).Round(time.Second))
+
+	if dryRun {
+		for _, m := range pending {
+			log.Printf("  would reverse geocode %q (%.5f, %.5f)", m.Name, m.Latitude, m.Longitude)
+		}
+		return nil
+	}
+
+	start := time.Now()
+	var addressed, nothing, failed int
+	for _, m := range pending {
+		if ctx.Err() != nil {
+			log.Printf("Interrupted: addressed %d of %d", addressed, len(pending))
+			return nil
+		}
+
+		found, err := geocoder.findAddress(ctx, m.SourcePage, m.Latitude, m.Longitude)
+		var address models.Address
+		switch {
+		case err == nil:
+			address = found.Address
+		case errors.Is(err, location.ErrNoResults):
+		default:
+			log.Printf("  %q: %v", m.Name, err)
+			failed++
+			continue
+		}
+		// An answer with only a town and a country is no address; the museum
+		// is marked as asked about all the same.
+		if address.Street() == "" && address.Postcode == "" {
+			address = models.Address{}
+		}
+
+		if err := db.SetAddress(ctx, m.ID, address); err != nil {
+			return err
+		}
+		if address.IsZero() {
+			nothing++
+			continue
+		}
+		addressed++
+	}
+
+	log.Printf("Finished in %s: addressed %d, %d have no address to find, %d failed and will be tried again",
+		time.Since(start).Round(time.Second), addressed, nothing, failed)
+	return nil
+}
+
 // townCentre resolves a town to a position, remembering what it resolves.
 //
 // The centre comes from museums already placed in that town rather than from a
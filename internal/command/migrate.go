@@ -0,0 +1,156 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"museum/internal/postgres"
+)
+
+// migrateCommand moves the database schema between versions.
+//
+// It is the only command that changes the schema. Everything else checks the
+// database is at the version it was built for and refuses to run otherwise, so
+// a deploy is "migrate up, then roll the services" and a half-finished one
+// fails loudly at start rather than quietly on some later query.
+func migrateCommand() Command {
+	return Command{
+		Name:    "migrate",
+		Summary: "Apply, revert or inspect database schema migrations",
+		Usage:   "(up [-to N] | down [-steps 1] | status | plan [-to N] [-sql])",
+		Run:     runMigrate,
+	}
+}
+
+func runMigrate(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("migrate needs an action: up, down, status or plan")
+	}
+	action, rest := args[0], args[1:]
+
+	db, err := openDatabase(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch action {
+	case "up":
+		return migrateUp(ctx, db, rest)
+	case "down":
+		return migrateDown(ctx, db, rest)
+	case "status":
+		return migrateStatus(ctx, db, rest)
+	case "plan":
+		return migratePlan(ctx, db, rest)
+	default:
+		return fmt.Errorf("unknown action %q, want up, down, status or plan", action)
+	}
+}
+
+func migrateUp(ctx context.Context, db *postgres.Store, args []string) error {
+	fs := newFlagSet("migrate up", "[-to N]", os.Stderr)
+	to := fs.Int("to", 0, "stop at this version; 0 for the latest")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireNoArgs("migrate up", fs.Args()); err != nil {
+		return err
+	}
+
+	applied, err := db.MigrateUp(ctx, *to)
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		log.Printf("schema already at the requested version; nothing to apply")
+		return nil
+	}
+	for _, m := range applied {
+		log.Printf("applied %04d_%s", m.Version, m.Name)
+	}
+	return nil
+}
+
+func migrateDown(ctx context.Context, db *postgres.Store, args []string) error {
+	fs := newFlagSet("migrate down", "[-steps 1]", os.Stderr)
+	steps := fs.Int("steps", 1, "how many migrations to revert, newest first")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireNoArgs("migrate down", fs.Args()); err != nil {
+		return err
+	}
+
+	reverted, err := db.MigrateDown(ctx, *steps)
+	if err != nil {
+		return err
+	}
+	for _, m := range reverted {
+		log.Printf("reverted %04d_%s", m.Version, m.Name)
+	}
+	return nil
+}
+
+func migrateStatus(ctx context.Context, db *postgres.Store, args []string) error {
+	if err := requireNoArgs("migrate status", args); err != nil {
+		return err
+	}
+
+	status, current, err := db.SchemaStatus(ctx)
+	if err != nil {
+		return err
+	}
+	for _, m := range status {
+		state := "pending"
+		if m.Applied {
+			state = "applied " + m.AppliedAt.Local().Format("2006-01-02 15:04")
+		}
+		var notes []string
+		if m.Modified {
+			notes = append(notes, "changed since it was applied")
+		}
+		if m.Unknown {
+			notes = append(notes, "not known to this binary")
+		}
+		if !m.Unknown && !m.Reversible() {
+			notes = append(notes, "irreversible")
+		}
+		fmt.Printf("%04d  %-32s %-24s %s\n", m.Version, m.Name, state, strings.Join(notes, "; "))
+	}
+	fmt.Printf("\ndatabase at version %d; this binary expects %d\n", current, postgres.SchemaVersion())
+	return nil
+}
+
+func migratePlan(ctx context.Context, db *postgres.Store, args []string) error {
+	fs := newFlagSet("migrate plan", "[-to N] [-sql]", os.Stderr)
+	var (
+		to      = fs.Int("to", 0, "plan up to this version; 0 for the latest")
+		showSQL = fs.Bool("sql", false, "print each migration's statements")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireNoArgs("migrate plan", fs.Args()); err != nil {
+		return err
+	}
+
+	todo, err := db.PendingMigrations(ctx, *to)
+	if err != nil {
+		return err
+	}
+	if len(todo) == 0 {
+		fmt.Println("Nothing to apply.")
+		return nil
+	}
+	for _, m := range todo {
+		fmt.Printf("%04d_%s\n", m.Version, m.Name)
+		if *showSQL {
+			fmt.Printf("\n%s\n", m.Up)
+		}
+	}
+	return nil
+}
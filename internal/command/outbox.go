@@ -0,0 +1,125 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"museum/internal/postgres"
+)
+
+// outboxCommand looks after the change events waiting for "museum relay".
+func outboxCommand() Command {
+	return Command{
+		Name:    "outbox",
+		Summary: "Report on, switch or purge the change events waiting to be relayed",
+		Usage:   "(status [-json] | enable | disable | purge [-older-than 168h] [-all])",
+		Run:     runOutbox,
+	}
+}
+
+func runOutbox(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("outbox needs an action: status, enable, disable or purge")
+	}
+	action, rest := args[0], args[1:]
+
+	db, err := database(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch action {
+	case "status":
+		return outboxStatus(ctx, db, rest)
+	case "enable", "disable":
+		return outboxSwitch(ctx, db, action, rest)
+	case "purge":
+		return outboxPurge(ctx, db, rest)
+	default:
+		return fmt.Errorf("unknown action %q, want status, enable, disable or purge", action)
+	}
+}
+
+func outboxStatus(ctx context.Context, db *postgres.Store, args []string) error {
+	fs := newFlagSet("outbox status", "[-json]", os.Stderr)
+	asJSON := fs.Bool("json", false, "emit JSON instead of text")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireNoArgs("outbox status", fs.Args()); err != nil {
+		return err
+	}
+
+	st, err := db.Outbox(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return emitJSON(st)
+	}
+	state := "off: changes write no events"
+	if st.Enabled {
+		state = "on"
+	}
+	fmt.Printf("Outbox %s\n", state)
+	if st.Pending == 0 {
+		fmt.Println("No events waiting.")
+		return nil
+	}
+	fmt.Printf("%d events waiting, the oldest since %s (%s ago)\n", st.Pending,
+		st.Oldest.Local().Format(time.DateTime), time.Since(st.Oldest).Round(time.Minute))
+	return nil
+}
+
+// outboxSwitch turns the keeping of events on or off. Off suits a deployment
+// with no Kafka, where nothing would ever read them; the relay turns it back
+// on as it starts.
+func outboxSwitch(ctx context.Context, db *postgres.Store, action string, args []string) error {
+	if err := requireNoArgs("outbox "+action, args); err != nil {
+		return err
+	}
+	enable := action == "enable"
+	was, err := db.SetOutbox(ctx, enable)
+	if err != nil {
+		return err
+	}
+	switch {
+	case was == enable:
+		log.Printf("The outbox was already %s", action+"d")
+	case enable:
+		log.Println("Switched the outbox on: changes from now on write events for the relay")
+	default:
+		log.Println("Switched the outbox off: changes write no events until it is switched on or a relay starts")
+	}
+	return nil
+}
+
+func outboxPurge(ctx context.Context, db *postgres.Store, args []string) error {
+	fs := newFlagSet("outbox purge", "[-older-than 168h] [-all]", os.Stderr)
+	olderThan := fs.Duration("older-than", 7*24*time.Hour, "delete the events that have waited longer than this")
+	all := fs.Bool("all", false, "delete every event waiting, however new")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireNoArgs("outbox purge", fs.Args()); err != nil {
+		return err
+	}
+	if *olderThan < 0 {
+		return errors.New("-older-than cannot be negative")
+	}
+	if *all {
+		*olderThan = 0
+	}
+
+	purged, err := db.PurgeOutbox(ctx, *olderThan)
+	if err != nil {
+		return err
+	}
+	log.Printf("Purged %d outbox events", purged)
+	return nil
+}
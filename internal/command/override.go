@@ -0,0 +1,191 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"museum/internal/override"
+	"museum/internal/postgres"
+)
+
+// overrideCommand manages the corrections made to the catalogue by hand.
+//
+// A fix made directly in the database lasted until the next crawl wrote the
+// source's version back. An override is the same fix recorded as a rule the
+// crawl, the reindex and every merge honour, so it is made once.
+func overrideCommand() Command {
+	return Command{
+		Name:    "override",
+		Summary: "Pin, merge, split or suppress museums by hand, so crawls leave the fix alone",
+		Usage:   "(set ID FIELD VALUE | merge KEEP OTHER | split ID OTHER | suppress ID | list [ID] | remove OVERRIDE) [-reason TEXT]",
+		Run:     runOverride,
+	}
+}
+
+func runOverride(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("override needs an action: set, merge, split, suppress, list or remove")
+	}
+	action, rest := args[0], args[1:]
+
+	db, err := database(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch action {
+	case "set", "merge", "split", "suppress":
+		return overrideAdd(ctx, db, action, rest)
+	case "list":
+		return overrideList(ctx, db, rest)
+	case "remove":
+		return overrideRemove(ctx, db, rest)
+	default:
+		return fmt.Errorf("unknown action %q, want set, merge, split, suppress, list or remove", action)
+	}
+}
+
+// overrideSynopses are the positional arguments of each way to add one.
+var overrideSynopses = map[string]string{
+	"set":      "ID FIELD VALUE",
+	"merge":    "KEEP OTHER",
+	"split":    "ID OTHER",
+	"suppress": "ID",
+}
+
+func overrideAdd(ctx context.Context, db *postgres.Store, action string, args []string) error {
+	synopsis := overrideSynopses[action]
+	fs := newFlagSet("override "+action, synopsis+" [-reason TEXT]", os.Stderr)
+	reason := fs.String("reason", "", "why, for whoever finds the override later")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != len(strings.Fields(synopsis)) {
+		return fmt.Errorf("override %s takes %s", action, synopsis)
+	}
+
+	o := override.Override{Reason: *reason, CreatedBy: operator()}
+	if o.MuseumID, err = museumID(positional[0]); err != nil {
+		return err
+	}
+	switch action {
+	case "set":
+		o.Kind, o.Field = override.Pin, positional[1]
+		if o.Value, err = override.ParseValue(o.Field, positional[2]); err != nil {
+			return err
+		}
+	case "merge", "split":
+		o.Kind = override.Merge
+		if action == "split" {
+			o.Kind = override.Split
+		}
+		if o.OtherID, err = museumID(positional[1]); err != nil {
+			return err
+		}
+	case "suppress":
+		o.Kind = override.Suppress
+	}
+
+	added, err := db.AddOverride(ctx, o)
+	if err != nil {
+		return err
+	}
+	log.Printf("override %d in force: %s", added.ID, describeOverride(added))
+	return nil
+}
+
+func overrideList(ctx context.Context, db *postgres.Store, args []string) error {
+	fs := newFlagSet("override list", "[ID] [-json]", os.Stderr)
+	asJSON := fs.Bool("json", false, "emit JSON instead of a table")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	var id int64
+	switch len(positional) {
+	case 0:
+	case 1:
+		if id, err = museumID(positional[0]); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("override list takes at most one museum id")
+	}
+
+	overrides, err := db.Overrides(ctx, id)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return emitJSON(overrides)
+	}
+	if len(overrides) == 0 {
+		fmt.Println("No overrides.")
+		return nil
+	}
+	for _, o := range overrides {
+		fmt.Printf("%5d  %s  %-12s %s\n", o.ID, o.CreatedAt.Local().Format("2006-01-02"),
+			truncate(o.CreatedBy, 12), describeOverride(o))
+		if o.Reason != "" {
+			fmt.Printf("%31s%s\n", "", o.Reason)
+		}
+	}
+	return nil
+}
+
+func overrideRemove(ctx context.Context, db *postgres.Store, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("override remove takes one override id, as \"override list\" shows it")
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("override id %q is not a number", args[0])
+	}
+	removed, err := db.RemoveOverride(ctx, id)
+	if err != nil {
+		return err
+	}
+	log.Printf("override %d removed: %s; what it did stays until a source says otherwise", removed.ID, describeOverride(removed))
+	return nil
+}
+
+// describeOverride says what an override does, in one line.
+func describeOverride(o override.Override) string {
+	switch o.Kind {
+	case override.Pin:
+		return fmt.Sprintf("museum %d %s pinned to %s", o.MuseumID, o.Field, o.Value)
+	case override.Merge:
+		return fmt.Sprintf("museum %d merged into %d", o.OtherID, o.MuseumID)
+	case override.Split:
+		return fmt.Sprintf("museums %d and %d kept apart", o.MuseumID, o.OtherID)
+	case override.Suppress:
+		return fmt.Sprintf("museum %d suppressed", o.MuseumID)
+	default:
+		return string(o.Kind)
+	}
+}
+
+// museumID reads a museum's numeric id. Overrides are made by id, because an
+// override outlives the names and Wikidata ids a museum may be given later.
+func museumID(arg string) (int64, error) {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("museum id %q is not a number; \"query search -json\" shows the ids", arg)
+	}
+	return id, nil
+}
+
+// operator names whoever is running the command, for the override's record.
+func operator() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return "cli"
+}
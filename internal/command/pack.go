@@ -0,0 +1,40 @@
+package command
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"museum/internal/storage"
+)
+
+// packTimeout bounds the final repack of an enricher shutting down, which
+// runs after its context is cancelled.
+const packTimeout = 5 * time.Minute
+
+// packCatalogue brings the packs of prefix up to date. A failure is logged
+// rather than returned: the loose objects are the catalogue, and a reindex
+// reads whatever the packs do not hold from them.
+func packCatalogue(ctx context.Context, bucket *storage.Bucket, prefix string) {
+	start := time.Now()
+	stats, err := bucket.Pack(ctx, prefix)
+	if err != nil {
+		log.Printf("Packing %s failed: %v (reindex reads it loose until the next pack)", prefix, err)
+		return
+	}
+	if stats.Rewritten > 0 {
+		log.Printf("Packed %s in %s: %d of %d countries rewritten, %d objects read",
+			prefix, time.Since(start).Round(time.Millisecond), stats.Rewritten, stats.Shards, stats.Fetched)
+	}
+}
+
+// packPeriodically repacks prefix every interval until ctx ends, and once
+// more then, so what was written last is packed too.
+func packPeriodically(ctx context.Context, bucket *storage.Bucket, prefix string, every time.Duration) {
+	for wait(ctx, every) {
+		packCatalogue(ctx, bucket, prefix)
+	}
+	final, cancel := context.WithTimeout(context.WithoutCancel(ctx), packTimeout)
+	defer cancel()
+	packCatalogue(final, bucket, prefix)
+}
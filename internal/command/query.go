@@ -3,6 +3,7 @@ package command
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -10,7 +11,6 @@ import (
 	"time"
 
 	"museum/internal/postgres"
-	"museum/pkg/location"
 )
 
 // queryCommand answers catalogue questions from the terminal.
@@ -21,15 +21,15 @@ import (
 func queryCommand() Command {
 	return Command{
 		Name:    "query",
-		Summary: "Look up museums or exhibitions by location, or search by name",
-		Usage:   "(museums|exhibitions|search) ...",
+		Summary: "Look up museums or exhibitions by location, search by name, or show a museum's history",
+		Usage:   "(museums|exhibitions|search|history) ...",
 		Run:     runQuery,
 	}
 }
 
 func runQuery(ctx context.Context, args []string) error {
 	if len(args) == 0 {
-		return fmt.Errorf("query needs a subject: museums, exhibitions or search")
+		return fmt.Errorf("query needs a subject: museums, exhibitions, search or history")
 	}
 	subject, rest := args[0], args[1:]
 
@@ -44,8 +44,10 @@ func runQuery(ctx context.Context, args []string) error {
 		return queryByName(ctx, db, rest)
 	case "museums", "exhibitions":
 		return queryByLocation(ctx, db, subject, rest)
+	case "history":
+		return queryHistory(ctx, db, rest)
 	default:
-		return fmt.Errorf("unknown subject %q, want museums, exhibitions or search", subject)
+		return fmt.Errorf("unknown subject %q, want museums, exhibitions, search or history", subject)
 	}
 }
 
@@ -97,6 +99,55 @@ func queryByName(ctx context.Context, db *postgres.Store, args []string) error {
 	return nil
 }
 
+// queryHistory lists what changed on one museum, newest first, and which run
+// changed it — the first thing to look at when a record has gone wrong.
+func queryHistory(ctx context.Context, db *postgres.Store, args []string) error {
+	fs := newFlagSet("query history", "ID [-limit 50] [-json]", os.Stderr)
+	var (
+		limit  = fs.Int("limit", 50, "maximum revisions")
+		asJSON = fs.Bool("json", false, "emit JSON instead of a table")
+	)
+	ids, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(ids) != 1 {
+		return fmt.Errorf("query history needs one museum id, numeric or Wikidata")
+	}
+
+	history, err := db.MuseumHistory(ctx, ids[0], *limit, 0)
+	if errors.Is(err, postgres.ErrNotFound) {
+		return fmt.Errorf("no museum %s, and no history of one", ids[0])
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("museum %d: %d of %d revisions", history.MuseumID, len(history.Revisions), history.Total)
+
+	if *asJSON {
+		return emitJSON(history)
+	}
+	if len(history.Revisions) == 0 {
+		fmt.Println("No changes recorded since history began.")
+		return nil
+	}
+	for _, r := range history.Revisions {
+		fmt.Printf("%s  %-32s %-6s %-20s %s \u2192 %s\n",
+			r.ChangedAt.Local().Format("2006-01-02 15:04"), truncate(r.Source, 32), r.Operation, r.Field,
+			revisionValue(r.Old), revisionValue(r.New))
+	}
+	return nil
+}
+
+// revisionValue renders one side of a revision for the table: the JSON as
+// stored, cut short, or a dash where there was no value.
+func revisionValue(value json.RawMessage) string {
+	if len(value) == 0 {
+		return "-"
+	}
+	return truncate(string(value), 60)
+}
+
 // queryByLocation answers a radius query.
 func queryByLocation(ctx context.Context, db *postgres.Store, subject string, args []string) error {
 	fs := newFlagSet("query "+subject, "(-place NAME | -lat N -lon N) [-radius 3] [-json]", os.Stderr)
@@ -119,7 +170,7 @@ func queryByLocation(ctx context.Context, db *postgres.Store, subject string, ar
 		return fmt.Errorf("radius must be greater than zero")
 	}
 
-	centreLat, centreLon, label, err := resolveCentre(ctx, *place, *lat, *lon)
+	centreLat, centreLon, label, err := resolveCentre(ctx, db, *place, *lat, *lon)
 	if err != nil {
 		return err
 	}
@@ -172,9 +223,13 @@ func queryByLocation(ctx context.Context, db *postgres.Store, subject string, ar
 
 // resolveCentre turns the flags into a search origin, geocoding a place name
 // when one was given.
-func resolveCentre(ctx context.Context, place string, lat, lon float64) (float64, float64, string, error) {
+func resolveCentre(ctx context.Context, db *postgres.Store, place string, lat, lon float64) (float64, float64, string, error) {
 	if place != "" {
-		loc, err := location.Geocode(ctx, place)
+		geocoder, err := newGeocoding(db)
+		if err != nil {
+			return 0, 0, "", err
+		}
+		loc, err := geocoder.Geocode(ctx, place)
 		if err != nil {
 			return 0, 0, "", fmt.Errorf("cannot locate %q: %w", place, err)
 		}
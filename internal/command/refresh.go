@@ -13,7 +13,6 @@ import (
 	"museum/internal/postgres"
 	"museum/pkg/exhibitions"
 	"museum/pkg/graceful"
-	"museum/pkg/location"
 )
 
 const (
@@ -181,7 +180,12 @@ func selectMuseums(ctx context.Context, all bool, place string, lat, lon, radius
 	}
 
 	if place != "" {
-		loc, err := location.Geocode(ctx, place)
+		// One name a run is not worth opening the database for the cache.
+		geocoder, err := newGeocoding(nil)
+		if err != nil {
+			return nil, err
+		}
+		loc, err := geocoder.Geocode(ctx, place)
 		if err != nil {
 			return nil, err
 		}
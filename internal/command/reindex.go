@@ -12,6 +12,7 @@ import (
 
 	"museum/internal/keys"
 	"museum/internal/models"
+	"museum/internal/override"
 	"museum/internal/storage"
 	"museum/pkg/graceful"
 )
@@ -21,14 +22,16 @@ func reindexCommand() Command {
 	return Command{
 		Name:    "reindex",
 		Summary: "Load the stored catalogue into the database and refresh its indexes",
-		Usage:   "[-batch 2000]",
+		Usage:   "[-batch 2000] [-as-of DATE]",
 		Run:     runReindex,
 	}
 }
 
 func runReindex(ctx context.Context, args []string) error {
-	fs := newFlagSet("reindex", "[-batch 2000]", os.Stderr)
+	fs := newFlagSet("reindex", "[-batch 2000] [-as-of DATE]", os.Stderr)
 	batchSize := fs.Int("batch", 2000, "museums per database round trip")
+	var asOf moment
+	fs.Var(&asOf, "as-of", "load the catalogue as it stood on this date, from the versions kept of it")
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
@@ -53,9 +56,14 @@ func runReindex(ctx context.Context, args []string) error {
 	defer cancel()
 
 	start := time.Now()
-	log.Printf("Reading the catalogue from %s/%s ...", bucket, keys.RawPrefix)
+	if at := time.Time(asOf); !at.IsZero() {
+		log.Printf("Reading the catalogue from %s/%s as it stood at %s ...",
+			bucket, keys.RawPrefix, at.Format(time.RFC3339))
+	} else {
+		log.Printf("Reading the catalogue from %s/%s ...", bucket, keys.RawPrefix)
+	}
 
-	catalogue, err := readCatalogue(ctx, store, bucket)
+	catalogue, err := readCatalogue(ctx, store, bucket, time.Time(asOf))
 	if err != nil {
 		return err
 	}
@@ -66,39 +74,82 @@ func runReindex(ctx context.Context, args []string) error {
 		return errors.New("nothing to load")
 	}
 
-	// Every museum is loaded, including the ones with no position: they are
-	// unreachable by radius query but findable by name, and dropping them here
-	// would make a quarter of the catalogue invisible.
+	written, err := loadCatalogue(ctx, db, catalogue.everything, *batchSize, time.Time(asOf))
+	if err != nil {
+		return err
+	}
+
+	// Only the catalogue of today is worth enriching: an -as-of load reads
+	// records that have since been replaced.
+	if time.Time(asOf).IsZero() {
+		queueEnrichment(ctx, db, bucket, catalogue.unenriched)
+	}
+
+	counts, err := db.Counts(ctx)
+	if err != nil {
+		return err
+	}
+	log.Printf("Loaded %d museums in %s: %d in the database, %d with coordinates, %d countries",
+		written, time.Since(start).Round(time.Second),
+		counts.Museums, counts.WithCoordinates, counts.Countries)
+	return nil
+}
+
+// catalogueLoader is the part of the database a reindex writes to.
+type catalogueLoader interface {
+	SaveMuseums(ctx context.Context, museums []models.Museum) (int64, error)
+	MergeDuplicates(ctx context.Context) (int64, error)
+	RetireAbsent(ctx context.Context, present []override.Ref, reason string) (retired, restored int64, err error)
+}
+
+// loadCatalogue saves museums in batches and folds the duplicates together,
+// returning how many rows were written.
+//
+// Every museum is loaded, including the ones with no position: they are
+// unreachable by radius query but findable by name, and dropping them here
+// would make a quarter of the catalogue invisible.
+//
+// Saving alone leaves whatever else the database holds, so a load of the
+// catalogue of a past day, asOf, then retires every museum it did not name:
+// the ones added since. The ones it named that were retired since come back.
+func loadCatalogue(ctx context.Context, db catalogueLoader, museums []models.Museum, batchSize int, asOf time.Time) (int64, error) {
 	var written int64
-	for start := 0; start < len(catalogue.everything); start += *batchSize {
+	for start := 0; start < len(museums); start += batchSize {
 		if ctx.Err() != nil {
-			return errors.New("interrupted; the load is incomplete, run again")
+			return written, errors.New("interrupted; the load is incomplete, run again")
 		}
-		end := min(start+*batchSize, len(catalogue.everything))
+		end := min(start+batchSize, len(museums))
 
-		n, err := db.SaveMuseums(ctx, catalogue.everything[start:end])
+		n, err := db.SaveMuseums(ctx, museums[start:end])
 		if err != nil {
-			return err
+			return written, err
 		}
 		written += n
 	}
 
 	removed, err := db.MergeDuplicates(ctx)
 	if err != nil {
-		return err
+		return written, err
 	}
 	if removed > 0 {
 		log.Printf("Merged %d duplicate records", removed)
 	}
 
-	counts, err := db.Counts(ctx)
+	if asOf.IsZero() {
+		return written, nil
+	}
+	present := make([]override.Ref, len(museums))
+	for i, m := range museums {
+		present[i] = override.RefOf(m)
+	}
+	retired, restored, err := db.RetireAbsent(ctx, present,
+		"absent from the catalogue as of "+asOf.Format(time.RFC3339))
 	if err != nil {
-		return err
+		return written, err
 	}
-	log.Printf("Loaded %d museums in %s: %d in the database, %d with coordinates, %d countries",
-		written, time.Since(start).Round(time.Second),
-		counts.Museums, counts.WithCoordinates, counts.Countries)
-	return nil
+	log.Printf("Retired %d museums added since %s, and restored %d retired since",
+		retired, asOf.Format(time.RFC3339), restored)
+	return written, nil
 }
 
 // catalogueRead is the outcome of assembling the canonical catalogue.
@@ -110,6 +161,10 @@ type catalogueRead struct {
 	// checking only the indexed ones would hide the problem being reported.
 	everything []models.Museum
 
+	// unenriched are the keys of the raw records with no enriched copy, which
+	// a reindex of the present queues for the enricher.
+	unenriched []string
+
 	read     int
 	enriched int
 	unplaced int
@@ -121,7 +176,11 @@ type catalogueRead struct {
 // adds a postal address, an official website and — where the geocoder can place
 // a museum the sources could not — coordinates, and building the index from raw
 // records alone threw all of that away.
-func readCatalogue(ctx context.Context, store museumStorage, bucket string) (catalogueRead, error) {
+//
+// The records are read from the packs under packs/ wherever those hold them
+// as they are now, which is most of them after a crawl. A non-zero asOf reads
+// every record as it stood then, from the versions kept under history/.
+func readCatalogue(ctx context.Context, store museumStorage, bucket string, asOf time.Time) (catalogueRead, error) {
 	var (
 		mu       sync.Mutex
 		byKey    = map[string]models.Museum{}
@@ -129,7 +188,13 @@ func readCatalogue(ctx context.Context, store museumStorage, bucket string) (cat
 		result   catalogueRead
 	)
 
-	err := store.EachObject(ctx, bucket, keys.RawPrefix+"/", func(key string, museum models.Museum) {
+	each := store.EachObjectPacked
+	if !asOf.IsZero() {
+		each = func(ctx context.Context, bucket, prefix string, fn func(string, models.Museum)) error {
+			return store.EachObjectAsOf(ctx, bucket, prefix, asOf, fn)
+		}
+	}
+	err := each(ctx, bucket, keys.RawPrefix+"/", func(key string, museum models.Museum) {
 		mu.Lock()
 		defer mu.Unlock()
 		result.read++
@@ -141,7 +206,7 @@ func readCatalogue(ctx context.Context, store museumStorage, bucket string) (cat
 
 	// Enriched records are keyed by the same country/name slug, so they line up
 	// with the raw ones they supersede.
-	err = eachEnriched(ctx, store, bucket, func(e models.EnrichedMuseum) {
+	err = eachEnriched(ctx, store, bucket, asOf, func(e models.EnrichedMuseum) {
 		mu.Lock()
 		defer mu.Unlock()
 
@@ -160,6 +225,8 @@ func readCatalogue(ctx context.Context, store museumStorage, bucket string) (cat
 	for key, museum := range byKey {
 		if enriched[key] {
 			result.enriched++
+		} else {
+			result.unenriched = append(result.unenriched, key)
 		}
 		result.everything = append(result.everything, museum)
 		if !museum.HasCoordinates() {
@@ -174,20 +241,31 @@ func readCatalogue(ctx context.Context, store museumStorage, bucket string) (cat
 // museumStorage is the reading surface reindex needs, so the assembly logic can
 // be exercised without object storage.
 type museumStorage interface {
-	EachObject(ctx context.Context, bucket, prefix string, fn func(key string, m models.Museum)) error
+	EachObjectPacked(ctx context.Context, bucket, prefix string, fn func(key string, m models.Museum)) error
+	EachObjectAsOf(ctx context.Context, bucket, prefix string, at time.Time, fn func(key string, m models.Museum)) error
+}
+
+// blobStorage is storage that can open a store of another record type on the
+// same blob.
+type blobStorage interface {
+	Blob() storage.Blob
 }
 
-// eachEnriched walks the enriched records. It opens its own client because the
+// eachEnriched walks the enriched records. It opens its own service because the
 // generic store is typed to one record shape, and enriched records are a
-// different one.
-func eachEnriched(ctx context.Context, _ museumStorage, bucket string, fn func(models.EnrichedMuseum)) error {
-	store, err := storage.NewS3Service(keys.EnrichedMuseum)
-	if err != nil {
-		return err
+// different one; it is opened on the raw records' blob, so both are read from
+// the same place. A non-zero asOf reads them as they stood then.
+func eachEnriched(ctx context.Context, raw museumStorage, bucket string, asOf time.Time, fn func(models.EnrichedMuseum)) error {
+	blobbed, ok := raw.(blobStorage)
+	if !ok {
+		return errors.New("the museum storage has no enriched records")
 	}
-	return store.EachObject(ctx, bucket, keys.EnrichedPrefix+"/", func(_ string, e models.EnrichedMuseum) {
-		fn(e)
-	})
+	store := storage.NewService(blobbed.Blob(), keys.EnrichedMuseum)
+	visit := func(_ string, e models.EnrichedMuseum) { fn(e) }
+	if !asOf.IsZero() {
+		return store.EachObjectAsOf(ctx, bucket, keys.EnrichedPrefix+"/", asOf, visit)
+	}
+	return store.EachObjectPacked(ctx, bucket, keys.EnrichedPrefix+"/", visit)
 }
 
 // mergeEnriched folds the enrichment results back onto the museum record.
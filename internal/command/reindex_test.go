@@ -1,9 +1,15 @@
 package command
 
 import (
+	"context"
+	"slices"
 	"testing"
+	"time"
 
+	"museum/internal/keys"
 	"museum/internal/models"
+	"museum/internal/override"
+	"museum/internal/storage"
 )
 
 func TestMergeEnriched(t *testing.T) {
@@ -124,6 +130,120 @@ func TestFloatFrom(t *testing.T) {
 	}
 }
 
+// A crawl into a folder is reindexed from it, enriched records and all, with
+// no object store running.
+func TestReadCatalogue_FromAFolder(t *testing.T) {
+	ctx := context.Background()
+	blob, err := storage.NewFileBlob(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := storage.NewService(blob, keys.Museum)
+	for _, m := range []models.Museum{
+		{Name: "Jihad Museum", Country: "Afghanistan"},
+		{Name: "Louvre", Country: "France", Latitude: 48.8606, Longitude: 2.3376},
+	} {
+		if _, err := raw.StoreObject(ctx, "museum", m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	enriched := storage.NewService(blob, keys.EnrichedMuseum)
+	if err := enriched.PutObject(ctx, "museum", models.EnrichedMuseum{
+		Museum: models.Museum{Name: "Jihad Museum", Country: "Afghanistan"},
+		Data:   map[string]any{"lat": "34.3458129", "lon": "62.1877057"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Once loose, and once from the packs a crawl and the enricher write.
+	for _, packed := range []bool{false, true} {
+		if packed {
+			for _, prefix := range []string{keys.RawPrefix, keys.EnrichedPrefix} {
+				if _, err := raw.Bucket("museum").Pack(ctx, prefix); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		catalogue, err := readCatalogue(ctx, raw, "museum", time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if catalogue.read != 2 || catalogue.enriched != 1 || catalogue.unplaced != 0 || len(catalogue.museums) != 2 {
+			t.Errorf("packed %v: read %d, %d enriched, %d unplaced, %d indexable; want both, one enriched and placed by it",
+				packed, catalogue.read, catalogue.enriched, catalogue.unplaced, len(catalogue.museums))
+		}
+		if want := keys.Museum(models.Museum{Name: "Louvre", Country: "France"}); len(catalogue.unenriched) != 1 || catalogue.unenriched[0] != want {
+			t.Errorf("packed %v: unenriched = %v, want [%s]", packed, catalogue.unenriched, want)
+		}
+	}
+}
+
+// loadedCatalogue is a catalogueLoader holding museums by identity, with
+// whether each is retired.
+type loadedCatalogue map[override.Ref]bool
+
+func (l loadedCatalogue) SaveMuseums(_ context.Context, museums []models.Museum) (int64, error) {
+	for _, m := range museums {
+		l[override.RefOf(m)] = false
+	}
+	return int64(len(museums)), nil
+}
+
+func (l loadedCatalogue) MergeDuplicates(context.Context) (int64, error) { return 0, nil }
+
+func (l loadedCatalogue) RetireAbsent(_ context.Context, present []override.Ref, _ string) (retired, restored int64, err error) {
+	for ref, wasRetired := range l {
+		keep := slices.Contains(present, ref)
+		switch {
+		case !keep && !wasRetired:
+			retired++
+		case keep && wasRetired:
+			restored++
+		}
+		l[ref] = !keep
+	}
+	return retired, restored, nil
+}
+
+// A reindex as of a past day leaves the database holding the catalogue of
+// that day, not that day's museums on top of today's.
+func TestLoadCatalogue_AsOfRetiresMuseumsAddedSince(t *testing.T) {
+	ctx := context.Background()
+	blob, err := storage.NewFileBlob(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := storage.NewService(blob, keys.Museum)
+	louvre := models.Museum{Name: "Louvre", Country: "France", WikidataID: "Q19675"}
+	if _, err := raw.StoreObject(ctx, "museum", louvre); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	asOf := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	liberation := models.Museum{Name: "Musée de la Libération de Paris", Country: "France", WikidataID: "Q3329602"}
+	if _, err := raw.StoreObject(ctx, "museum", liberation); err != nil {
+		t.Fatal(err)
+	}
+
+	db := loadedCatalogue{}
+	for _, at := range []time.Time{{}, asOf} {
+		catalogue, err := readCatalogue(ctx, raw, "museum", at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadCatalogue(ctx, db, catalogue.everything, 1, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if retired, ok := db[override.RefOf(liberation)]; !ok || !retired {
+		t.Errorf("the museum added since is loaded %v, retired %v; want it gone", ok, retired)
+	}
+	if retired := db[override.RefOf(louvre)]; retired {
+		t.Error("the museum of the day was retired")
+	}
+}
+
 func TestCommandRegistry(t *testing.T) {
 	// Every command must be reachable by the name help advertises, or the
 	// binary documents a command it cannot run.
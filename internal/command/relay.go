@@ -0,0 +1,171 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"museum/internal/env"
+	"museum/internal/postgres"
+	"museum/pkg/graceful"
+	"museum/pkg/kafkaclient"
+)
+
+// defaultTopicPrefix is what the topics events are published to begin with
+// when KAFKA_EVENTS_TOPIC_PREFIX does not say otherwise.
+const defaultTopicPrefix = "catalogue."
+
+// relayCommand publishes the catalogue's change events to Kafka.
+func relayCommand() Command {
+	return Command{
+		Name:    "relay",
+		Summary: "Publish catalogue and exhibition change events to Kafka",
+		Usage:   "[-batch 500] [-every 2s] [-once]",
+		Run:     runRelay,
+	}
+}
+
+// eventEnvelope is an event as it is published: what happened, to which
+// museum or exhibition, and the shape of data, which is versioned per type.
+type eventEnvelope struct {
+	ID         int64           `json:"id"`
+	Type       string          `json:"type"`
+	Version    int             `json:"version"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Key        string          `json:"key"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// runRelay moves events from the outbox to Kafka until it is stopped.
+//
+// The database writes the events in the transaction that makes each change,
+// so nothing here can lose one or publish one that was rolled back; the relay
+// only has to deliver them. It publishes a batch, deletes it, and repeats
+// while there is a backlog, then polls. Running two is safe: the second waits
+// on the first's batch rather than publishing it again.
+func runRelay(ctx context.Context, args []string) error {
+	fs := newFlagSet("relay", "[-batch 500] [-every 2s] [-once]", os.Stderr)
+	batch := fs.Int("batch", 500, "publish at most this many events per transaction")
+	every := fs.Duration("every", 2*time.Second, "look for new events this often once the outbox is empty")
+	once := fs.Bool("once", false, "publish what is waiting and exit, rather than running until stopped")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireNoArgs("relay", fs.Args()); err != nil {
+		return err
+	}
+	if *batch < 1 {
+		return fmt.Errorf("-batch must be at least 1")
+	}
+
+	broker, err := env.LookupEnv("KAFKA_BROKER_LOCAL")
+	if err != nil {
+		return err
+	}
+	prefix := defaultTopicPrefix
+	if p, ok := os.LookupEnv("KAFKA_EVENTS_TOPIC_PREFIX"); ok {
+		prefix = p
+	}
+
+	db, err := database(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, cancel := graceful.Context(ctx)
+	defer cancel()
+
+	// Events are kept only while something relays them, and this does.
+	if was, err := db.SetOutbox(ctx, true); err != nil {
+		return err
+	} else if !was {
+		log.Printf("Switched the outbox on: changes from now on are published")
+	}
+
+	producer := kafkaclient.NewKafkaProducer(broker)
+	defer producer.Close()
+
+	publish := func(ctx context.Context, events []postgres.OutboxEvent) error {
+		msgs := make([]kafka.Message, len(events))
+		for i, e := range events {
+			msg, err := eventMessage(prefix, e)
+			if err != nil {
+				return err
+			}
+			msgs[i] = msg
+		}
+		if err := producer.Publish(ctx, msgs...); err != nil {
+			return fmt.Errorf("publish %d events: %w", len(msgs), err)
+		}
+		return nil
+	}
+
+	log.Printf("Relaying events to %s, topics %s*", broker, prefix)
+	var total int
+	for ctx.Err() == nil {
+		n, err := db.RelayOutbox(ctx, *batch, publish)
+		total += n
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			if *once {
+				return err
+			}
+			log.Printf("relay: %v", err)
+		} else {
+			if n > 0 {
+				log.Printf("Published %d events", n)
+			}
+			// A full batch means more are probably waiting.
+			if n == *batch {
+				continue
+			}
+			if *once {
+				break
+			}
+		}
+		if !wait(ctx, *every) {
+			break
+		}
+	}
+	log.Printf("Relay exiting after publishing %d events", total)
+	return nil
+}
+
+// eventMessage is the Kafka message for an event, on its type's topic. The
+// key is the museum's or exhibition's, so within a topic each one's events
+// stay on one partition, in order. Kafka orders nothing across topics, so a
+// consumer of several orders by the envelope's id, the order the relay
+// publishes in. The headers let a consumer of a topic with several versions
+// skip what it does not read without decoding it.
+func eventMessage(prefix string, e postgres.OutboxEvent) (kafka.Message, error) {
+	value, err := json.Marshal(eventEnvelope{
+		ID:         e.ID,
+		Type:       e.Type,
+		Version:    e.Version,
+		OccurredAt: e.OccurredAt.UTC(),
+		Key:        e.Key,
+		Data:       e.Data,
+	})
+	if err != nil {
+		return kafka.Message{}, fmt.Errorf("encode event %d: %w", e.ID, err)
+	}
+	return kafka.Message{
+		Topic: prefix + e.Type,
+		Key:   []byte(e.Key),
+		Value: value,
+		Headers: []kafka.Header{
+			{Key: "event-type", Value: []byte(e.Type)},
+			{Key: "event-version", Value: []byte(strconv.Itoa(e.Version))},
+		},
+		Time: e.OccurredAt,
+	}, nil
+}
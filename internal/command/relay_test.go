@@ -0,0 +1,47 @@
+package command
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"museum/internal/postgres"
+)
+
+// An event is published under its museum's key, to its type's topic, with
+// the envelope a consumer decodes and the headers it can filter on.
+func TestEventMessage(t *testing.T) {
+	at := time.Date(2026, 3, 1, 2, 0, 0, 0, time.FixedZone("CET", 3600))
+	msg, err := eventMessage("catalogue.", postgres.OutboxEvent{
+		ID: 42, Aggregate: "museum", Key: "1207", Type: "museum.geocoded", Version: 1,
+		Data: json.RawMessage(`{"id":1207,"location":[57.6984,11.9741]}`), OccurredAt: at,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Topic != "catalogue.museum.geocoded" || string(msg.Key) != "1207" {
+		t.Errorf("topic %q, key %q", msg.Topic, msg.Key)
+	}
+	headers := map[string]string{}
+	for _, h := range msg.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	if headers["event-type"] != "museum.geocoded" || headers["event-version"] != "1" {
+		t.Errorf("headers = %v", headers)
+	}
+
+	var got eventEnvelope
+	if err := json.Unmarshal(msg.Value, &got); err != nil {
+		t.Fatalf("value %s: %v", msg.Value, err)
+	}
+	if got.ID != 42 || got.Type != "museum.geocoded" || got.Version != 1 || got.Key != "1207" {
+		t.Errorf("envelope = %+v", got)
+	}
+	if !got.OccurredAt.Equal(at) || got.OccurredAt.Location() != time.UTC {
+		t.Errorf("occurred_at = %v, want %v in UTC", got.OccurredAt, at)
+	}
+	if string(got.Data) != `{"id":1207,"location":[57.6984,11.9741]}` {
+		t.Errorf("data = %s", got.Data)
+	}
+}
@@ -0,0 +1,105 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"museum/internal/backup"
+	"museum/pkg/graceful"
+)
+
+// restoreCommand puts a backup back, or proves that it would go back.
+func restoreCommand() Command {
+	return Command{
+		Name:    "restore",
+		Summary: "Restore a backup into an empty database, or check that one restores",
+		Usage:   "[-into SCHEMA] [-tables-only] [-prune] [-verify [-fail-on never]] ID|latest",
+		Run:     runRestore,
+	}
+}
+
+func runRestore(ctx context.Context, args []string) error {
+	fs := newFlagSet("restore", "[-into SCHEMA] [-tables-only] [-prune] [-verify [-fail-on never]] ID|latest", os.Stderr)
+	var (
+		into       = fs.String("into", "", "restore the tables into this schema, created if need be, instead of the database's own")
+		tablesOnly = fs.Bool("tables-only", false, "restore the tables and leave the bucket alone")
+		prune      = fs.Bool("prune", false, "delete stored museums the backup does not hold, leaving the bucket as it was")
+		verify     = fs.Bool("verify", false, "restore into a scratch schema, audit it and drop it, changing nothing")
+		samples    = fs.Int("samples", 5, "-verify: example records to show per audit check (0 for none)")
+		failOn     = fs.String("fail-on", "never", "-verify: exit non-zero when audit findings reach this severity: error, warning or never")
+	)
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return errors.New("restore needs one backup id, or latest; \"museum backup -list\" lists them")
+	}
+	if *verify && (*into != "" || *prune) {
+		return errors.New("restore -verify changes nothing, so it takes neither -into nor -prune")
+	}
+
+	store, bucketName, err := museumStore()
+	if err != nil {
+		return err
+	}
+	bucket := store.Bucket(bucketName)
+
+	// The database is opened whatever its schema: an empty one has none, and
+	// the restore brings it to the backup's and then to this binary's.
+	db, err := openDatabase(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, cancel := graceful.Context(ctx)
+	defer cancel()
+
+	m, err := backup.Load(ctx, bucket, rest[0])
+	if err != nil {
+		return err
+	}
+	log.Printf("Backup %s, taken %s at schema version %d", m.ID, m.StartedAt.Local().Format(time.DateTime), m.SchemaVersion)
+
+	if *verify {
+		report, err := backup.Verify(ctx, db, bucket, m)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Backup %s restores: %d rows, %s.\n", m.ID, m.Rows(), describeObjects(m))
+		printReport(os.Stdout, report, *samples)
+		return exitStatus(report, *failOn)
+	}
+
+	target := db
+	if *into != "" {
+		if target, err = db.InSchema(ctx, *into); err != nil {
+			return err
+		}
+		defer target.Close()
+	}
+
+	start := time.Now()
+	rows, err := backup.RestoreTables(ctx, target, bucket, m)
+	if err != nil {
+		return err
+	}
+	log.Printf("Restored %d rows in %d tables in %s", rows, len(m.Tables), time.Since(start).Round(time.Second))
+
+	if *tablesOnly || len(m.Objects) == 0 {
+		return nil
+	}
+	start = time.Now()
+	done, err := backup.RestoreObjects(ctx, bucket, m, *prune)
+	if err != nil {
+		return err
+	}
+	log.Printf("Restored the stored museums in %s: %d written, %d already as backed up, %d removed",
+		time.Since(start).Round(time.Second), done.Written, done.Unchanged, done.Removed)
+	return nil
+}
@@ -0,0 +1,175 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"museum/internal/collect"
+	"museum/internal/models"
+	"museum/internal/override"
+	"museum/internal/postgres"
+)
+
+// retiredCommand reports the museums retired because their sources stopped
+// offering them.
+//
+// Retirement hides a museum rather than deleting it, so a wrong one costs
+// nothing to undo, but only if someone notices. This is where to look.
+func retiredCommand() Command {
+	return Command{
+		Name:    "retired",
+		Summary: "List the museums retired because no source offers them any more, and why",
+		Usage:   "[-limit 100] [-json]",
+		Run:     runRetired,
+	}
+}
+
+func runRetired(ctx context.Context, args []string) error {
+	fs := newFlagSet("retired", "[-limit 100] [-json]", os.Stderr)
+	limit := fs.Int("limit", 100, "how many to list, most recently retired first")
+	asJSON := fs.Bool("json", false, "emit JSON instead of a table")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireNoArgs("retired", fs.Args()); err != nil {
+		return err
+	}
+
+	db, err := database(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	retired, err := db.RetiredMuseums(ctx, *limit)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		if retired == nil {
+			retired = []postgres.RetiredMuseum{}
+		}
+		return emitJSON(retired)
+	}
+	if len(retired) == 0 {
+		fmt.Println("No museums are retired.")
+		return nil
+	}
+	for _, m := range retired {
+		fmt.Printf("%7d  %s  %-40s %s\n", m.ID, m.RetiredAt.Local().Format("2006-01-02"),
+			truncate(m.Name, 40), m.Country)
+		fmt.Printf("%20s%s\n", "", m.Reason)
+		for _, source := range slices.Sorted(maps.Keys(m.LastSeen)) {
+			fmt.Printf("%20s%s last reported it %s\n", "", source, m.LastSeen[source].Local().Format("2006-01-02"))
+		}
+	}
+	fmt.Println("A retired museum is restored by the first crawl whose sources report it again.")
+	return nil
+}
+
+// sightings is what each source reported in one crawl, kept so the database can
+// tell which museums the sources have stopped offering. It is safe for every
+// source goroutine to use at once.
+type sightings struct {
+	started time.Time
+
+	mu sync.Mutex
+	// seen holds the Ref of every record under each source tag it carried.
+	seen map[string][]override.Ref
+	// cut holds the tags of streams that ended before their source was done.
+	cut map[string]bool
+}
+
+func newSightings() *sightings {
+	return &sightings{started: time.Now(), seen: map[string][]override.Ref{}, cut: map[string]bool{}}
+}
+
+// note records one record, and returns tags with the record's own added: the
+// tags the calling stream has produced so far.
+//
+// The name is cleaned as the merger cleans it, so the Ref is the one the row
+// the record becomes will be found by.
+func (s *sightings) note(m models.Museum, tags []string) []string {
+	ref := override.RefOf(models.Museum{Name: collect.CleanName(m.Name), Country: m.Country, WikidataID: m.WikidataID})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tag := range m.Sources {
+		s.seen[tag] = append(s.seen[tag], ref)
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// cutShort marks tags as reported by a stream that did not finish.
+func (s *sightings) cutShort(tags []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tag := range tags {
+		s.cut[tag] = true
+	}
+}
+
+// retirement is how the crawl retires museums: after how many complete runs
+// without them, and how many at most at once.
+type retirement struct {
+	after int
+	most  int
+}
+
+// retireMuseums records a crawl's sightings and then retires what no source
+// offers any more.
+//
+// Failures are logged rather than returned, like the rest of the database
+// load. A run whose sightings could not all be recorded retires nothing: the
+// museums it failed to record would count as unseen.
+func retireMuseums(ctx context.Context, db *postgres.Store, seen *sightings, retire retirement) {
+	for _, source := range slices.Sorted(maps.Keys(seen.seen)) {
+		run, restored, err := db.RecordCrawlRun(ctx, postgres.CrawlRun{
+			Source:    source,
+			Label:     runLabel(ctx),
+			StartedAt: seen.started,
+			Finished:  !seen.cut[source],
+		}, seen.seen[source])
+		if err != nil {
+			log.Printf("Cannot record what %s reported: %v (retiring nothing this run)", source, err)
+			return
+		}
+		if !run.Complete {
+			log.Printf("Run of %s is incomplete (%d museums); it will not count towards retirement", source, run.Seen)
+		}
+		if restored > 0 {
+			log.Printf("Restored %d retired museums %s reports again", restored, source)
+		}
+	}
+
+	if retire.after <= 0 {
+		return
+	}
+	most := retire.most
+	if most <= 0 {
+		counts, err := db.Counts(ctx)
+		if err != nil {
+			log.Printf("Cannot size the catalogue: %v (retiring nothing this run)", err)
+			return
+		}
+		most = max(100, int(counts.Museums/50))
+	}
+	retired, err := db.RetireVanishedMuseums(ctx, retire.after, most)
+	if err != nil {
+		log.Printf("Retirement skipped: %v", err)
+		return
+	}
+	if retired > 0 {
+		log.Printf("Retired %d museums no source has reported in %d complete runs; \"museum retired\" lists them",
+			retired, retire.after)
+	}
+}
@@ -0,0 +1,36 @@
+package command
+
+import (
+	"slices"
+	"testing"
+
+	"museum/internal/models"
+	"museum/internal/override"
+)
+
+func TestSightings(t *testing.T) {
+	seen := newSightings()
+
+	var tags []string
+	tags = seen.note(models.Museum{Name: "  Tate Modern ", Country: "United Kingdom", WikidataID: "Q193375",
+		Sources: []string{"wikidata"}}, tags)
+	tags = seen.note(models.Museum{Name: "Petrie Museum", Country: "United Kingdom",
+		Sources: []string{"wikidata"}}, tags)
+	if !slices.Equal(tags, []string{"wikidata"}) {
+		t.Errorf("tags = %v, want the one source the stream produced", tags)
+	}
+
+	want := []override.Ref{
+		{WikidataID: "Q193375", NameKey: "tate modern|United Kingdom"},
+		{NameKey: "petrie museum|United Kingdom"},
+	}
+	if !slices.Equal(seen.seen["wikidata"], want) {
+		t.Errorf("seen = %+v, want %+v", seen.seen["wikidata"], want)
+	}
+
+	other := seen.note(models.Museum{Name: "Musée d'Orsay", Country: "France", Sources: []string{"openstreetmap"}}, nil)
+	seen.cutShort(other)
+	if !seen.cut["openstreetmap"] || seen.cut["wikidata"] {
+		t.Errorf("cut = %v, want only the stream that was cut short", seen.cut)
+	}
+}
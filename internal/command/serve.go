@@ -7,9 +7,12 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"museum/internal/api"
+	"museum/internal/memory"
+	"museum/internal/sqlite"
 	"museum/pkg/graceful"
 	"museum/pkg/location"
 )
@@ -24,18 +27,21 @@ func serveCommand() Command {
 	return Command{
 		Name:    "serve",
 		Summary: "Run the HTTP API",
-		Usage:   "[-addr :8090]",
+		Usage:   "[-addr :8090] [-catalogue FILE | -demo]",
 		Run:     runServe,
 	}
 }
 
 func runServe(ctx context.Context, args []string) error {
-	fs := newFlagSet("serve", "[-addr :8090]", os.Stderr)
+	fs := newFlagSet("serve", "[-addr :8090] [-catalogue FILE | -demo]", os.Stderr)
 	var (
 		addr         = fs.String("addr", ":8090", "address to listen on")
+		catalogue    = fs.String("catalogue", "", "serve read-only from a file written by \"museum export\" instead of the database")
+		demo         = fs.Bool("demo", false, "serve the small demo catalogue from memory, needing no database")
 		readTimeout  = fs.Duration("read-timeout", 10*time.Second, "per-request read timeout")
 		writeTimeout = fs.Duration("write-timeout", 30*time.Second, "per-request write timeout")
 		idleTimeout  = fs.Duration("idle-timeout", 60*time.Second, "keep-alive idle timeout")
+		maxLag       = fs.Duration("max-replica-lag", 30*time.Second, "read from DATABASE_READ_URL only while it is at most this far behind the primary")
 	)
 	if err := fs.Parse(args); err != nil {
 		return err
@@ -44,22 +50,14 @@ func runServe(ctx context.Context, args []string) error {
 		return err
 	}
 
-	db, err := database(ctx)
+	if *demo && *catalogue != "" {
+		return errors.New("serve: -demo and -catalogue are alternatives")
+	}
+	apiServer, closeCatalogue, err := newAPIServer(ctx, *catalogue, *demo, *maxLag)
 	if err != nil {
 		return err
 	}
-	defer db.Close()
-
-	// The resolver is what lets a caller ask for "Paris" instead of a
-	// coordinate pair. It geocodes through the shared rate-limited client and
-	// caches into the same database, so a name costs one upstream call ever
-	// rather than one per request.
-	//
-	// Scraping on demand is what makes a city nobody has looked at fill in when
-	// someone does, rather than simply reading as empty.
-	apiServer := api.NewServer(db).
-		WithPlaces(api.NewPlaceResolver(db, location.Geocode)).
-		WithScraping(db)
+	defer closeCatalogue()
 	defer apiServer.Close()
 
 	server := &http.Server{
@@ -108,8 +106,8 @@ func runServe(ctx context.Context, args []string) error {
 	// requests are still legitimately running: Shutdown returns "context
 	// deadline exceeded", the command exits non-zero, and a clean SIGTERM looks
 	// to the orchestrator like a crash. Worse, returning here runs the deferred
-	// db.Close() and pulls the pool out from under the handlers still using it,
-	// so every draining request fails — the opposite of graceful.
+	// close and pulls the pool out from under the handlers still using it, so
+	// every draining request fails — the opposite of graceful.
 	shutdownCtx, cancelShutdown := context.WithTimeout(
 		context.WithoutCancel(ctx), drainTimeout)
 	defer cancelShutdown()
@@ -124,3 +122,79 @@ func runServe(ctx context.Context, args []string) error {
 	log.Println("Server stopped")
 	return nil
 }
+
+// newAPIServer builds the API over the database, over a catalogue file when one
+// is named, or over the demo catalogue, and returns what releases the catalogue
+// afterwards.
+func newAPIServer(ctx context.Context, catalogueFile string, demo bool, maxLag time.Duration) (*api.Server, func(), error) {
+	// A copy of the catalogue is served where the database and often the
+	// network are not, so place names are answered from the copy's own towns,
+	// and from the names the database had resolved where the copy holds them,
+	// never from a geocoder. There is nothing to scrape into and nothing for
+	// an administrator to edit.
+	switch {
+	case demo:
+		cat, err := memory.Demo()
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Printf("Serving the demo catalogue from memory")
+		return api.NewServer(cat).WithPlaces(api.NewPlaceResolver(cat, offlineGeocode)), func() {}, nil
+	case strings.HasSuffix(catalogueFile, ".ndjson") || strings.HasSuffix(catalogueFile, ".jsonl"):
+		cat, err := memory.LoadFile(catalogueFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Printf("Serving the catalogue from %s, in memory", catalogueFile)
+		return api.NewServer(cat).WithPlaces(api.NewPlaceResolver(cat, offlineGeocode)), func() {}, nil
+	case catalogueFile != "":
+		cat, err := sqlite.Open(ctx, catalogueFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Printf("Serving the catalogue from %s, read-only", catalogueFile)
+		server := api.NewServer(cat).WithPlaces(api.NewPlaceResolver(cat, offlineGeocode))
+		return server, func() { cat.Close() }, nil
+	}
+
+	db, err := database(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	// A replica takes the API's reads off the primary, which the sweep, the
+	// crawls and the reindex keep busy with writes. Only here: every other
+	// command reads what it has just written, and must read the primary.
+	if dsn := os.Getenv("DATABASE_READ_URL"); dsn != "" {
+		if err := db.AttachReplica(ctx, dsn, maxLag); err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		log.Printf("Reading from the replica while it is within %s of the primary", maxLag)
+	}
+
+	// The resolver is what lets a caller ask for "Paris" instead of a
+	// coordinate pair. It geocodes through the shared rate-limited client and
+	// caches into the same database, so a name costs one upstream call ever
+	// rather than one per request.
+	//
+	// Scraping on demand is what makes a city nobody has looked at fill in when
+	// someone does, rather than simply reading as empty.
+	//
+	// The admin API is there only when a token is configured for it.
+	geocoder, err := newGeocoding(db)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	server := api.NewServer(db).
+		WithPlaces(api.NewPlaceResolver(db, geocoder)).
+		WithScraping(db).
+		WithAdmin(db, os.Getenv("MUSEUM_ADMIN_TOKEN"))
+	return server, db.Close, nil
+}
+
+// offlineGeocode is the geocoder for a server that must not reach one. It
+// finds nothing, which sends the resolver to the catalogue's own towns.
+var offlineGeocode = location.GeocoderFunc(func(context.Context, string) (*location.NominatimLocation, error) {
+	return nil, location.ErrNoResults
+})
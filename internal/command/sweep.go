@@ -25,24 +25,31 @@ import (
 //
 // It runs continuously by default, like "enrich", because keeping data fresh
 // is not a thing that finishes. -once makes it a batch job for a cron instead.
+//
+// Running continuously, it also archives what ended long ago, every so often:
+// the sweep is what retires listings, so it is the process that always runs
+// where they accumulate. A cron running -once runs "archive" itself.
 func sweepCommand() Command {
 	return Command{
 		Name:    "sweep",
 		Summary: "Keep exhibitions current by rereading the sites expected to be stale",
-		Usage:   "[-once] [-dry-run] [-batch 200] [-concurrency 8] [-rate 60]",
+		Usage:   "[-once] [-dry-run] [-batch 200] [-concurrency 8] [-rate 60] [-archive-every 24h] [-archive-older-than 180d]",
 		Run:     runSweepCommand,
 	}
 }
 
 func runSweepCommand(ctx context.Context, args []string) error {
-	fs := newFlagSet("sweep", "[-once] [-dry-run] [-batch 200] [-concurrency 8] [-rate 60]", os.Stderr)
+	fs := newFlagSet("sweep", "[-once] [-dry-run] [-batch 200] [-concurrency 8] [-rate 60] [-archive-every 24h] [-archive-older-than 180d]", os.Stderr)
 	var (
 		once        = fs.Bool("once", false, "read one batch and stop, instead of running continuously")
 		dryRun      = fs.Bool("dry-run", false, "list what would be read, and why, without reading it")
 		batch       = fs.Int("batch", 200, "how many sites to claim at a time")
 		concurrency = fs.Int("concurrency", 8, "how many museum sites to read at once")
 		rate        = fs.Float64("rate", 60, "ceiling on sites read per minute (0 for no limit)")
+		archive     = archiving{olderThan: age(defaultArchiveAge)}
 	)
+	fs.DurationVar(&archive.every, "archive-every", 24*time.Hour, "how often to archive long-ended exhibitions while running continuously (0 never)")
+	fs.Var(&archive.olderThan, "archive-older-than", "archive exhibitions that ended longer ago than this (as 180d)")
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
@@ -56,7 +63,7 @@ func runSweepCommand(ctx context.Context, args []string) error {
 	if *dryRun || *once {
 		return runSweepOnce(ctx, *batch, *concurrency, *dryRun)
 	}
-	return runSweepLoop(ctx, *batch, *concurrency, *rate)
+	return runSweepLoop(ctx, *batch, *concurrency, *rate, archive)
 }
 
 // runSweepOnce reads a single batch, for a cron that would rather schedule the
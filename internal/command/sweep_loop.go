@@ -38,7 +38,7 @@ const (
 // database and in the scheduler, so the process holds no state of its own and
 // a restart resumes exactly where it left off — which is what makes it safe to
 // stop and safe to run more than one of.
-func runSweepLoop(ctx context.Context, batch, concurrency int, rate float64) error {
+func runSweepLoop(ctx context.Context, batch, concurrency int, rate float64, archive archiving) error {
 	db, err := database(ctx)
 	if err != nil {
 		return err
@@ -59,6 +59,8 @@ func runSweepLoop(ctx context.Context, batch, concurrency int, rate float64) err
 		batch, concurrency, rate)
 
 	for ctx.Err() == nil {
+		archive.runIfDue(ctx, db)
+
 		// Newly crawled museums enter here, at the top of every cycle, rather
 		// than through a separate seeding step somebody has to remember.
 		if fresh, err := db.DiscoverSites(ctx); err != nil {
@@ -94,6 +96,27 @@ func runSweepLoop(ctx context.Context, batch, concurrency int, rate float64) err
 	return nil
 }
 
+// archiving is the sweeper's schedule for archiving long-ended exhibitions.
+type archiving struct {
+	// every is how often to archive; zero never does.
+	every     time.Duration
+	olderThan age
+	last      time.Time
+}
+
+// runIfDue archives when the last run is every ago, the first time included.
+// A failure is logged and waits for the next turn like a success: the archive
+// is housekeeping, and the sweep's own work must not stop for it.
+func (a *archiving) runIfDue(ctx context.Context, db *postgres.Store) {
+	if a.every <= 0 || time.Since(a.last) < a.every {
+		return
+	}
+	a.last = time.Now()
+	if err := archiveExhibitions(ctx, db, time.Duration(a.olderThan), false); err != nil {
+		log.Printf("sweep: archiving: %v", err)
+	}
+}
+
 // sweepBatch reads one claimed batch, bounded by the pacer.
 func sweepBatch(ctx context.Context, runner *sweep.Runner, claimed []sweep.Target, concurrency int, pace *pacer) totals {
 	reports := make(chan sweep.Report, len(claimed))
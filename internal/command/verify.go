@@ -56,7 +56,7 @@ func runVerify(ctx context.Context, args []string) error {
 	// position: a record that cannot be placed is exactly the kind of problem
 	// worth reporting, and checking only the locatable ones would hide it.
 	var museums []models.Museum
-	if err := db.EachMuseum(ctx, func(m models.Museum) { museums = append(museums, m) }); err != nil {
+	if err := db.EachMuseum(ctx, func(_ int64, m models.Museum) { museums = append(museums, m) }); err != nil {
 		return err
 	}
 
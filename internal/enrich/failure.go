@@ -0,0 +1,67 @@
+package enrich
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// permanentError marks a failure retrying cannot cure.
+type permanentError struct{ err error }
+
+func (p permanentError) Error() string { return p.err.Error() }
+func (p permanentError) Unwrap() error { return p.err }
+
+// Permanent marks err as a failure that would only recur if the step were run
+// again: a request the remote refused as malformed, a record that does not
+// decode. Anything not marked is taken to be transient and is retried.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether err, or anything it wraps, was marked Permanent.
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+// Failure is a step that gave up on an item: at once if its error was
+// permanent, and otherwise once the retries ran out.
+type Failure struct {
+	Stage    string
+	Err      error
+	Attempts int
+}
+
+// Retry is how a transient failure is retried: up to Attempts runs in all,
+// the first retry after Backoff and each later one after twice the one before.
+// The zero value runs a step once.
+type Retry struct {
+	Attempts int
+	Backoff  time.Duration
+}
+
+// Run calls fn until it succeeds, fails permanently, or has been called
+// Attempts times, and returns its last error with the number of calls made.
+// A cancelled ctx stops the waiting and returns ctx's error.
+func (r Retry) Run(ctx context.Context, fn func() error) (attempts int, err error) {
+	wait := r.Backoff
+	for {
+		attempts++
+		err = fn()
+		if err == nil || IsPermanent(err) || attempts >= r.Attempts || ctx.Err() != nil {
+			return attempts, err
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return attempts, ctx.Err()
+		case <-timer.C:
+		}
+		wait *= 2
+	}
+}
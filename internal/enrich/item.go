@@ -22,6 +22,11 @@ type Item[T any] struct {
 	// finished, which is what makes acknowledging a message safe.
 	OnDone func()
 
+	// OnFailed, when set, is called for every step that gave up on the item,
+	// before OnDone. Calls are never concurrent, though steps are.
+	OnFailed func(Failure)
+	failMu   sync.Mutex
+
 	mu      sync.Mutex
 	results map[string]any
 }
@@ -103,6 +108,15 @@ func (i *Item[T]) Int64(key string) (int64, bool) {
 	}
 }
 
+// failed reports a step that gave up, one report at a time.
+func (i *Item[T]) failed(f Failure) {
+	i.failMu.Lock()
+	defer i.failMu.Unlock()
+	if i.OnFailed != nil {
+		i.OnFailed(f)
+	}
+}
+
 // done invokes OnDone exactly once. The pipeline calls it after the last stage,
 // whatever the outcome: an item whose enrichment failed is still finished with,
 // and holding its acknowledgement back would stall the source.
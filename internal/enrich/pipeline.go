@@ -2,6 +2,7 @@ package enrich
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"sync"
 )
@@ -10,6 +11,10 @@ import (
 // pipeline has finished with them. enrich.Item satisfies it.
 type doneSignaller interface{ done() }
 
+// failureReporter is implemented by item types that want to hear of the steps
+// that gave up on them. enrich.Item satisfies it.
+type failureReporter interface{ failed(Failure) }
+
 // signalDone notifies an item that every stage has run, if it cares.
 func signalDone[T any](item *T) {
 	if signaller, ok := any(item).(doneSignaller); ok {
@@ -19,12 +24,14 @@ func signalDone[T any](item *T) {
 
 // Pipeline coordinates the execution of a sequence of stages for items flowing
 // through a channel. For each incoming item, steps within the same stage run in
-// parallel, and stages themselves run sequentially. Any step errors are logged
-// and do not stop processing of the current item.
+// parallel, and stages themselves run sequentially. A step that fails is
+// retried as the pipeline's Retry allows; one that still fails is logged and
+// reported to the item, and does not stop processing of the current item.
 //
 // Pipeline is generic over the item type T.
 type Pipeline[T any] struct {
 	stages []Stage[T]
+	retry  Retry
 }
 
 // NewPipeline constructs a Pipeline from the provided stages. Stages will be
@@ -33,12 +40,17 @@ func NewPipeline[T any](stages ...Stage[T]) *Pipeline[T] {
 	return &Pipeline[T]{stages: stages}
 }
 
+// SetRetry sets how a step failing transiently is retried. Without it a step
+// runs once.
+func (p *Pipeline[T]) SetRetry(r Retry) { p.retry = r }
+
 // Process consumes items from in until the channel is closed or ctx is
 // cancelled. For each item:
 //   - All steps in a stage are started concurrently and must complete before
 //     the next stage begins (a stage barrier).
-//   - Errors returned by steps are logged and ignored so that one failed
-//     enrichment does not discard the rest of the item's results.
+//   - A step that fails is retried, unless its error is Permanent. One that
+//     gives up is logged and reported to the item, and the item goes on, so
+//     that one failed enrichment does not discard the rest of its results.
 //   - A cancelled context stops the pipeline between items; steps already
 //     running are given the same context and are expected to return promptly.
 //
@@ -73,14 +85,28 @@ func (p *Pipeline[T]) Process(ctx context.Context, in <-chan *T) int {
 func (p *Pipeline[T]) processItem(ctx context.Context, item *T) {
 	defer signalDone(item)
 
-	for _, stage := range p.stages {
+	for n, stage := range p.stages {
+		name := stage.name
+		if name == "" {
+			name = fmt.Sprintf("stage %d", n+1)
+		}
 		var wg sync.WaitGroup
 		for _, step := range stage.steps {
 			wg.Add(1)
 			go func(step Step[T]) {
 				defer wg.Done()
-				if err := step(ctx, item); err != nil {
-					log.Printf("Step failed: %v", err)
+				attempts, err := p.retry.Run(ctx, func() error { return step(ctx, item) })
+				if err == nil {
+					return
+				}
+				// An interrupted step has not failed, and is not reported as
+				// having failed.
+				if ctx.Err() != nil {
+					return
+				}
+				log.Printf("Step in %s failed after %d attempts: %v", name, attempts, err)
+				if reporter, ok := any(item).(failureReporter); ok {
+					reporter.failed(Failure{Stage: name, Err: err, Attempts: attempts})
 				}
 			}(step)
 		}
@@ -212,3 +212,60 @@ func TestItem_DoneIsIdempotent(t *testing.T) {
 		t.Errorf("OnDone called %d times, want exactly 1", calls)
 	}
 }
+
+// A transient failure is retried until it passes; a permanent one is reported
+// at once, under its stage's name, and the item still goes on to later stages.
+func TestPipeline_RetriesAndReportsFailures(t *testing.T) {
+	flaky := 0
+	stepFlaky := func(_ context.Context, i *item) error {
+		if flaky++; flaky < 3 {
+			return errors.New("503")
+		}
+		i.Set("flaky", true)
+		return nil
+	}
+	refused := 0
+	stepRefused := func(_ context.Context, _ *item) error {
+		refused++
+		return Permanent(errors.New("400"))
+	}
+
+	p := NewPipeline(
+		NewStage(stepFlaky).Named("flaky"),
+		NewStage(stepRefused).Named("refused"),
+		NewStage(stepAddFoo),
+	)
+	p.SetRetry(Retry{Attempts: 3, Backoff: time.Millisecond})
+
+	var failures []Failure
+	input := newItem()
+	input.OnFailed = func(f Failure) { failures = append(failures, f) }
+	in := make(chan *item, 1)
+	in <- input
+	close(in)
+	p.Process(context.Background(), in)
+
+	if flaky != 3 || refused != 1 {
+		t.Errorf("ran the flaky step %d times and the refused one %d, want 3 and 1", flaky, refused)
+	}
+	if len(failures) != 1 || failures[0].Stage != "refused" || failures[0].Attempts != 1 || !IsPermanent(failures[0].Err) {
+		t.Errorf("failures = %+v, want the refused stage once", failures)
+	}
+	if _, ok := input.String("foo"); !ok {
+		t.Error("a failed stage stopped the stages after it")
+	}
+}
+
+func TestRetry_GivesUp(t *testing.T) {
+	calls := 0
+	attempts, err := Retry{Attempts: 2, Backoff: time.Millisecond}.Run(context.Background(), func() error {
+		calls++
+		return errors.New("503")
+	})
+	if err == nil || attempts != 2 || calls != 2 {
+		t.Errorf("attempts %d, calls %d, err %v; want two and the error", attempts, calls, err)
+	}
+	if attempts, _ := (Retry{}).Run(context.Background(), func() error { return errors.New("503") }); attempts != 1 {
+		t.Errorf("the zero Retry made %d attempts, want 1", attempts)
+	}
+}
@@ -10,7 +10,9 @@ import (
 // Step represents a single enrichment operation that mutates the given item.
 // Implementations should be safe to run concurrently with other steps in the
 // same stage operating on the same item. If a step fails, it should return an
-// error; the pipeline will log the error and continue.
+// error, marked Permanent if running it again would fail the same way; the
+// pipeline retries the others as its Retry allows, reports the failure, and
+// continues.
 // The context can be used to observe cancellation or timeouts.
 //
 // The item pointer allows steps to modify the entity in-place to accumulate
@@ -28,6 +30,7 @@ type Step[T any] func(ctx context.Context, item *T) error
 // Note: Step functions must coordinate on shared fields if they might write to
 // the same location concurrently.
 type Stage[T any] struct {
+	name  string
 	steps []Step[T]
 }
 
@@ -36,3 +39,10 @@ type Stage[T any] struct {
 func NewStage[T any](steps ...Step[T]) Stage[T] {
 	return Stage[T]{steps: steps}
 }
+
+// Named returns the stage under name, which is what its failures are reported
+// as.
+func (s Stage[T]) Named(name string) Stage[T] {
+	s.name = name
+	return s
+}
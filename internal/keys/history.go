@@ -0,0 +1,59 @@
+package keys
+
+import (
+	"strings"
+	"time"
+)
+
+// HistoryPrefix holds the earlier versions of everything under RawPrefix and
+// EnrichedPrefix. It is outside both, so the bucket notification on RawPrefix
+// does not fire for a version being kept.
+const HistoryPrefix = "history"
+
+// versionTime is how a version's key records when it was written: fixed
+// width, so the versions of one object list oldest first.
+const versionTime = "20060102T150405.000000000Z"
+
+// Versioned reports whether writes to key keep the versions they replace.
+func Versioned(key string) bool {
+	return strings.HasPrefix(key, RawPrefix+"/") || strings.HasPrefix(key, EnrichedPrefix+"/")
+}
+
+// Version is where the content of key written at a moment is kept:
+// history/<key>/<when>-<sum>.json, sum being a prefix of its SHA-256. The
+// moment is UTC; the zero time stands for a version from before history was
+// kept, whose moment nobody recorded.
+func Version(key string, at time.Time, sum string) string {
+	if len(sum) > 16 {
+		sum = sum[:16]
+	}
+	return HistoryPrefix + "/" + key + "/" + at.UTC().Format(versionTime) + "-" + sum + ".json"
+}
+
+// VersionsOf is the prefix the versions of key are listed under.
+func VersionsOf(key string) string {
+	return HistoryPrefix + "/" + key + "/"
+}
+
+// ParseVersion reads a version's key back into the key it is a version of,
+// when it was written and the prefix of its sum.
+func ParseVersion(version string) (key string, at time.Time, sum string, ok bool) {
+	rest, found := strings.CutPrefix(version, HistoryPrefix+"/")
+	if !found {
+		return "", time.Time{}, "", false
+	}
+	slash := strings.LastIndex(rest, "/")
+	if slash < 0 {
+		return "", time.Time{}, "", false
+	}
+	key, name := rest[:slash], strings.TrimSuffix(rest[slash+1:], ".json")
+	stamp, sum, found := strings.Cut(name, "-")
+	if !found || key == "" {
+		return "", time.Time{}, "", false
+	}
+	at, err := time.Parse(versionTime, stamp)
+	if err != nil {
+		return "", time.Time{}, "", false
+	}
+	return key, at, sum, true
+}
@@ -0,0 +1,32 @@
+package keys
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVersionRoundTrip(t *testing.T) {
+	const key = "raw_data/france/louvre.json"
+	at := time.Date(2026, 3, 1, 12, 30, 0, 5, time.FixedZone("CET", 3600))
+	version := Version(key, at, "0123456789abcdef0123")
+	if want := "history/raw_data/france/louvre.json/20260301T113000.000000005Z-0123456789abcdef.json"; version != want {
+		t.Fatalf("Version = %s, want %s", version, want)
+	}
+	got, gotAt, sum, ok := ParseVersion(version)
+	if !ok || got != key || !gotAt.Equal(at) || sum != "0123456789abcdef" {
+		t.Errorf("ParseVersion = %s, %v, %s, %v", got, gotAt, sum, ok)
+	}
+
+	_, zero, _, ok := ParseVersion(Version(key, time.Time{}, "ab"))
+	if !ok || !zero.IsZero() {
+		t.Errorf("the version from before history parsed as %v, %v", zero, ok)
+	}
+	for _, bad := range []string{key, "history/louvre.json", "history/" + key + "/yesterday-ab.json"} {
+		if _, _, _, ok := ParseVersion(bad); ok {
+			t.Errorf("ParseVersion(%q) accepted it", bad)
+		}
+	}
+	if Versioned("geo/index.json") || !Versioned(key) || !Versioned("enriched_data/x/y.json") {
+		t.Error("Versioned disagrees about which prefixes keep history")
+	}
+}
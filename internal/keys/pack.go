@@ -0,0 +1,29 @@
+package keys
+
+import "strings"
+
+// PackPrefix holds the packed snapshots of RawPrefix and EnrichedPrefix: one
+// gzipped NDJSON file per country, and a manifest saying what each holds.
+// Like HistoryPrefix it is outside both, so writing a pack notifies no one.
+const PackPrefix = "packs"
+
+// PackShard is the shard a stored record is packed in: the country segment
+// of its key. A key with no country segment is not packed.
+func PackShard(key string) (string, bool) {
+	_, rest, found := strings.Cut(key, "/")
+	if !found {
+		return "", false
+	}
+	shard, _, found := strings.Cut(rest, "/")
+	return shard, found && shard != ""
+}
+
+// Pack is where the shard of prefix is packed.
+func Pack(prefix, shard string) string {
+	return PackPrefix + "/" + prefix + "/" + shard + ".ndjson.gz"
+}
+
+// PackManifest is where the manifest of prefix's packs is kept.
+func PackManifest(prefix string) string {
+	return PackPrefix + "/" + prefix + "/manifest.json.gz"
+}
@@ -0,0 +1,394 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"museum/internal/models"
+	"museum/internal/postgres"
+	"museum/internal/search"
+	"museum/pkg/exhibitions"
+	"museum/pkg/geo"
+)
+
+// Ping always succeeds: there is nothing to lose contact with.
+func (c *Catalogue) Ping(context.Context) error { return nil }
+
+// Counts summarises the catalogue, counted when it was built.
+func (c *Catalogue) Counts(context.Context) (postgres.Counts, error) { return c.counts, nil }
+
+// Generation is fixed: the catalogue never changes once built.
+func (c *Catalogue) Generation(context.Context) (postgres.Generation, error) { return c.gen, nil }
+
+// hit is a museum as a query returns it.
+func hit(row postgres.CatalogueRow) postgres.Hit {
+	return postgres.Hit{
+		ID: row.ID, Museum: row.Museum,
+		ApproximateLocation: row.ApproximateLocation, RetiredAt: row.RetiredAt,
+	}
+}
+
+// NearbyVerified returns the museums within radiusKm of a point, nearest first.
+func (c *Catalogue) NearbyVerified(_ context.Context, lat, lon, radiusKm float64, limit, offset int, verifiedOnly bool) (postgres.Page, error) {
+	var found []postgres.Hit
+	for _, row := range c.museums {
+		m := row.Museum
+		if row.RetiredAt != nil || !located(m.Latitude, m.Longitude) || (verifiedOnly && !m.Verified) {
+			continue
+		}
+		if d := geo.DistanceKm(lat, lon, m.Latitude, m.Longitude); d <= radiusKm {
+			h := hit(row)
+			h.DistanceKm = d
+			found = append(found, h)
+		}
+	}
+	slices.SortFunc(found, func(a, b postgres.Hit) int {
+		return cmp.Or(cmp.Compare(a.DistanceKm, b.DistanceKm), cmp.Compare(a.ID, b.ID))
+	})
+	return page(found, limit, offset), nil
+}
+
+// Search returns the museums whose name, aliases or locality match a query,
+// best first, scored as the database scores them. Phrases and exclusions
+// apply as they do there, unstemmed; descriptions and classes filter but do
+// not find.
+func (c *Catalogue) Search(_ context.Context, query string, limit, offset int) (postgres.Page, error) {
+	parsed := search.ParseQuery(query)
+	term := search.Normalize(parsed.Text)
+	if term == "" {
+		return postgres.Page{}, nil
+	}
+
+	type scored struct {
+		hit        postgres.Hit
+		normalized string
+	}
+	var found []scored
+	for _, row := range c.museums {
+		if row.RetiredAt != nil {
+			continue
+		}
+		score, ok := search.ScoreMuseum(term, candidate(row))
+		if !ok {
+			continue
+		}
+		m := row.Museum
+		if !parsed.Admits(search.MuseumDocument(m.Name, m.AlsoKnownAs, m.Description, m.Classes, m.Locality)) {
+			continue
+		}
+		h := hit(row)
+		h.Score = score
+		found = append(found, scored{h, row.Normalized})
+	}
+	slices.SortFunc(found, func(a, b scored) int {
+		return cmp.Or(
+			cmp.Compare(b.hit.Score, a.hit.Score),
+			cmp.Compare(len(a.normalized), len(b.normalized)),
+			cmp.Compare(a.hit.Museum.Name, b.hit.Museum.Name),
+			cmp.Compare(a.hit.ID, b.hit.ID))
+	})
+
+	hits := make([]postgres.Hit, len(found))
+	for i, s := range found {
+		hits[i] = s.hit
+	}
+	return page(hits, limit, offset), nil
+}
+
+// candidate is a museum as search scores it.
+func candidate(row postgres.CatalogueRow) search.Candidate {
+	return search.Candidate{
+		Normalized:         row.Normalized,
+		SearchText:         row.SearchText,
+		LocalityNormalized: row.LocalityNormalized,
+		Aliases:            row.AliasesNormalized,
+		Sitelinks:          row.Museum.Sitelinks,
+		Located:            located(row.Museum.Latitude, row.Museum.Longitude),
+	}
+}
+
+// MuseumByID returns one museum by numeric or Wikidata id, retired or not.
+func (c *Catalogue) MuseumByID(_ context.Context, id string) (postgres.Hit, error) {
+	numeric, err := strconv.ParseInt(id, 10, 64)
+	isNumeric := err == nil
+	for _, row := range c.museums {
+		if (isNumeric && row.ID == numeric) || (id != "" && row.Museum.WikidataID == id) {
+			return hit(row), nil
+		}
+	}
+	return postgres.Hit{}, fmt.Errorf("museum %q: %w", id, postgres.ErrNotFound)
+}
+
+// MuseumHistory answers with no revisions: a catalogue loaded from a file has
+// no history, only a present.
+func (c *Catalogue) MuseumHistory(ctx context.Context, id string, _, _ int) (postgres.History, error) {
+	h, err := c.MuseumByID(ctx, id)
+	if err != nil {
+		return postgres.History{}, err
+	}
+	return postgres.History{MuseumID: h.ID}, nil
+}
+
+// inBox reports whether a position falls in a box, or whether there is no box.
+func inBox(lat, lon, west, south, east, north float64, hasBox bool) bool {
+	return !hasBox || (lat >= south && lat <= north && lon >= west && lon <= east)
+}
+
+// placed returns the museums a map shows, most prominent first.
+func (c *Catalogue) placed(west, south, east, north float64, hasBox bool) []postgres.CatalogueRow {
+	var rows []postgres.CatalogueRow
+	for _, row := range c.museums {
+		m := row.Museum
+		if row.RetiredAt == nil && located(m.Latitude, m.Longitude) &&
+			inBox(m.Latitude, m.Longitude, west, south, east, north, hasBox) {
+			rows = append(rows, row)
+		}
+	}
+	slices.SortFunc(rows, func(a, b postgres.CatalogueRow) int {
+		return cmp.Or(cmp.Compare(b.Museum.Sitelinks, a.Museum.Sitelinks), cmp.Compare(a.ID, b.ID))
+	})
+	return rows
+}
+
+// Points returns museum positions for drawing, most prominent first.
+func (c *Catalogue) Points(_ context.Context, west, south, east, north float64, hasBox bool, limit int) ([]postgres.Point, error) {
+	rows := c.placed(west, south, east, north, hasBox)
+	points := make([]postgres.Point, 0, min(limit, len(rows)))
+	for _, row := range rows[:min(limit, len(rows))] {
+		points = append(points, postgres.Point{ID: row.ID, Lat: row.Museum.Latitude, Lon: row.Museum.Longitude})
+	}
+	return points, nil
+}
+
+// PointClusters answers a map view with the database's grid: cells floored,
+// centres the mean of their members, the most prominent museum representing
+// each.
+func (c *Catalogue) PointClusters(ctx context.Context, west, south, east, north float64, hasBox bool, cellDegrees float64, rawBelow, limit int) (postgres.Clustering, error) {
+	rows := c.placed(west, south, east, north, hasBox)
+	clustering := postgres.Clustering{Museums: int64(len(rows))}
+	if len(rows) <= rawBelow {
+		points, err := c.Points(ctx, west, south, east, north, hasBox, rawBelow)
+		clustering.Points = points
+		return clustering, err
+	}
+
+	type cell struct{ x, y float64 }
+	at := func(lat, lon float64) cell {
+		return cell{math.Floor(lon / cellDegrees), math.Floor(lat / cellDegrees)}
+	}
+	type group struct {
+		latSum, lonSum float64
+		cluster        postgres.Cluster
+	}
+	groups := map[cell]*group{}
+	for _, row := range rows {
+		m := row.Museum
+		key := at(m.Latitude, m.Longitude)
+		g, ok := groups[key]
+		if !ok {
+			// Rows are most prominent first, so the first in is the
+			// representative.
+			g = &group{cluster: postgres.Cluster{RepresentativeID: row.ID, RepresentativeName: m.Name}}
+			groups[key] = g
+		}
+		g.latSum += m.Latitude
+		g.lonSum += m.Longitude
+		g.cluster.Museums++
+	}
+
+	day := today()
+	for _, e := range c.shows {
+		if !located(e.Latitude, e.Longitude) || (e.End != nil && dateOf(*e.End).Before(day)) ||
+			!inBox(e.Latitude, e.Longitude, west, south, east, north, hasBox) {
+			continue
+		}
+		if g, ok := groups[at(e.Latitude, e.Longitude)]; ok {
+			g.cluster.Exhibitions++
+		}
+	}
+
+	for _, g := range groups {
+		g.cluster.Lat = g.latSum / float64(g.cluster.Museums)
+		g.cluster.Lon = g.lonSum / float64(g.cluster.Museums)
+		clustering.Clusters = append(clustering.Clusters, g.cluster)
+	}
+	slices.SortFunc(clustering.Clusters, func(a, b postgres.Cluster) int {
+		return cmp.Or(cmp.Compare(b.Museums, a.Museums), cmp.Compare(a.RepresentativeID, b.RepresentativeID))
+	})
+	clustering.Clusters = clustering.Clusters[:min(limit, len(clustering.Clusters))]
+	return clustering, nil
+}
+
+// today is the current date, as the database's current_date.
+func today() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// current reports whether a listing is one the database would return today:
+// not over, and started unless upcoming ones were asked for. It marks the
+// listing running or upcoming as the database's queries do.
+func current(e exhibitions.Exhibition, includeUpcoming bool) (postgres.ExhibitionHit, bool) {
+	day := today()
+	if e.End != nil && dateOf(*e.End).Before(day) {
+		return postgres.ExhibitionHit{}, false
+	}
+	if !includeUpcoming && e.Start != nil && dateOf(*e.Start).After(day) {
+		return postgres.ExhibitionHit{}, false
+	}
+	now := time.Now()
+	hit := postgres.ExhibitionHit{Exhibition: e}
+	hit.Running = e.Permanent || e.Start == nil || !e.Start.After(now)
+	hit.Upcoming = !e.Permanent && e.Start != nil && e.Start.After(now)
+	return hit, true
+}
+
+// dateOf is the calendar date of a time, as the database stores it.
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// ExhibitionsNearby returns what is on show within radiusKm, soonest to close
+// first.
+func (c *Catalogue) ExhibitionsNearby(_ context.Context, lat, lon, radiusKm float64, includeUpcoming bool, limit int) ([]postgres.ExhibitionHit, error) {
+	var found []postgres.ExhibitionHit
+	for _, e := range c.shows {
+		if !located(e.Latitude, e.Longitude) {
+			continue
+		}
+		hit, ok := current(e, includeUpcoming)
+		if !ok {
+			continue
+		}
+		if hit.DistanceKm = geo.DistanceKm(lat, lon, e.Latitude, e.Longitude); hit.DistanceKm <= radiusKm {
+			found = append(found, hit)
+		}
+	}
+	slices.SortFunc(found, func(a, b postgres.ExhibitionHit) int {
+		return cmp.Or(compareEnds(a.End, b.End), cmp.Compare(a.DistanceKm, b.DistanceKm))
+	})
+	return found[:min(limit, len(found))], nil
+}
+
+// SearchExhibitions finds what is on show by name, best match first, scored as
+// the database scores it, with phrases and exclusions as Search applies them.
+func (c *Catalogue) SearchExhibitions(_ context.Context, query string, lat, lon, radiusKm float64, near, includeUpcoming bool, limit, offset int) ([]postgres.ExhibitionHit, int64, error) {
+	parsed := search.ParseQuery(query)
+	term := strings.ToLower(strings.TrimSpace(parsed.Text))
+	if term == "" {
+		return nil, 0, nil
+	}
+
+	type scored struct {
+		hit   postgres.ExhibitionHit
+		score float64
+	}
+	var found []scored
+	for _, e := range c.shows {
+		score, ok := search.ScoreExhibition(term, e.Title, e.Museum)
+		if !ok || !parsed.Admits(search.Normalize(e.Title+" | "+e.Museum)) {
+			continue
+		}
+		hit, ok := current(e, includeUpcoming)
+		if !ok {
+			continue
+		}
+		if near {
+			if !located(e.Latitude, e.Longitude) {
+				continue
+			}
+			if hit.DistanceKm = geo.DistanceKm(lat, lon, e.Latitude, e.Longitude); hit.DistanceKm > radiusKm {
+				continue
+			}
+		}
+		found = append(found, scored{hit, score})
+	}
+	slices.SortFunc(found, func(a, b scored) int {
+		return cmp.Or(cmp.Compare(b.score, a.score),
+			cmp.Compare(a.hit.DistanceKm, b.hit.DistanceKm),
+			compareEnds(a.hit.End, b.hit.End))
+	})
+
+	total := int64(len(found))
+	found = found[min(offset, len(found)):]
+	found = found[:min(limit, len(found))]
+	hits := make([]postgres.ExhibitionHit, len(found))
+	for i, s := range found {
+		hits[i] = s.hit
+	}
+	return hits, total, nil
+}
+
+// ExhibitionCoverage reports what is known about an area: how many museums it
+// holds, retired ones included as in the database, how many have a site to
+// read, and when one was last read.
+func (c *Catalogue) ExhibitionCoverage(_ context.Context, lat, lon, radiusKm float64) (postgres.Coverage, error) {
+	var coverage postgres.Coverage
+	for _, row := range c.museums {
+		m := row.Museum
+		if !located(m.Latitude, m.Longitude) || geo.DistanceKm(lat, lon, m.Latitude, m.Longitude) > radiusKm {
+			continue
+		}
+		coverage.MuseumsInArea++
+		if row.Site == "" {
+			continue
+		}
+		coverage.MuseumsWithSite++
+		if at, ok := c.attempts[row.Site]; ok && (coverage.LastScraped == nil || at.After(*coverage.LastScraped)) {
+			coverage.LastScraped = &at
+		}
+	}
+	return coverage, nil
+}
+
+// EachMuseum visits the whole catalogue in id order.
+func (c *Catalogue) EachMuseum(_ context.Context, fn func(id int64, museum models.Museum)) error {
+	for _, row := range c.museums {
+		fn(row.ID, row.Museum)
+	}
+	return nil
+}
+
+// MuseumIDs returns the ids of at most limit current museums after the id
+// after, in order.
+func (c *Catalogue) MuseumIDs(_ context.Context, after int64, limit int) ([]int64, error) {
+	start, _ := slices.BinarySearchFunc(c.museums, after+1, func(row postgres.CatalogueRow, id int64) int {
+		return cmp.Compare(row.ID, id)
+	})
+	var ids []int64
+	for _, row := range c.museums[start:] {
+		if len(ids) == limit {
+			break
+		}
+		if row.RetiredAt == nil {
+			ids = append(ids, row.ID)
+		}
+	}
+	return ids, nil
+}
+
+// page cuts one page from a complete, ordered result.
+func page(hits []postgres.Hit, limit, offset int) postgres.Page {
+	total := int64(len(hits))
+	hits = hits[min(offset, len(hits)):]
+	return postgres.Page{Hits: hits[:min(limit, len(hits))], Total: total}
+}
+
+// compareEnds orders closing dates soonest first, with no closing date last.
+func compareEnds(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	return a.Compare(*b)
+}
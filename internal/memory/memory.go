@@ -0,0 +1,225 @@
+// Package memory holds a catalogue in memory and answers the API from it.
+//
+// It exists for the places a database is a burden: a frontend developer who
+// wants the API on a laptop without Docker, and the handler tests, which until
+// now ran against canned results that ignored their input. Queries are
+// answered faithfully rather than approximately. Distances are measured,
+// results ordered and paged as the database orders and pages them, exhibitions
+// filtered against today, and names scored with the database's own search
+// formula. The catalogtest suite holds it to that.
+//
+// Everything is scanned: there is no index. That is plenty for a demo or a
+// test, and the wrong tool for the full catalogue, which "museum export
+// -format sqlite" serves instead.
+package memory
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"museum/internal/models"
+	"museum/internal/postgres"
+	"museum/pkg/exhibitions"
+	"museum/test_data"
+)
+
+// Record is one line of a catalogue file: a museum, an exhibition, or when a
+// museum site was last read. Exactly one of them is set.
+//
+// It is the form "museum export -format ndjson" writes and Load reads, one
+// JSON object per line, so a file can be cut down with grep or written by hand.
+type Record struct {
+	// ID is the museum's id. A museum without one is numbered after the
+	// highest id in the file.
+	ID        int64          `json:"id,omitempty"`
+	Museum    *models.Museum `json:"museum,omitempty"`
+	RetiredAt *time.Time     `json:"retired_at,omitempty"`
+	// ApproximateLocation marks a position that is the museum's town centre.
+	ApproximateLocation bool `json:"approximate_location,omitempty"`
+
+	Exhibition *exhibitions.Exhibition `json:"exhibition,omitempty"`
+
+	Site        string     `json:"site,omitempty"`
+	AttemptedAt *time.Time `json:"attempted_at,omitempty"`
+}
+
+// Catalogue is a catalogue held in memory. It is read-only once built, apart
+// from the place names it learns while serving, and safe for concurrent use.
+type Catalogue struct {
+	// museums is in id order, which is the order EachMuseum promises.
+	museums []postgres.CatalogueRow
+	shows   []exhibitions.Exhibition
+	// attempts is when each site was last read, by site.
+	attempts map[string]time.Time
+
+	counts postgres.Counts
+	gen    postgres.Generation
+	towns  postgres.Towns
+
+	placesMu sync.Mutex
+	places   map[string]postgres.Place
+}
+
+// New returns a catalogue holding the museums, numbered from 1 in the order
+// given, and the exhibitions.
+func New(museums []models.Museum, shows []exhibitions.Exhibition) *Catalogue {
+	records := make([]Record, 0, len(museums)+len(shows))
+	for i := range museums {
+		records = append(records, Record{ID: int64(i + 1), Museum: &museums[i]})
+	}
+	for i := range shows {
+		records = append(records, Record{Exhibition: &shows[i]})
+	}
+	c, _ := build(records)
+	return c
+}
+
+// Load reads a catalogue file.
+func Load(r io.Reader) (*Catalogue, error) {
+	var records []Record
+	scanner := bufio.NewScanner(r)
+	// A museum with a long description is well over the default 64 KB line.
+	scanner.Buffer(make([]byte, 0, 64<<10), 4<<20)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(text, &rec); err != nil {
+			return nil, fmt.Errorf("catalogue line %d: %w", line, err)
+		}
+		if rec.Museum == nil && rec.Exhibition == nil && rec.Site == "" {
+			return nil, fmt.Errorf("catalogue line %d: neither a museum, an exhibition nor a site", line)
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read catalogue: %w", err)
+	}
+	return build(records)
+}
+
+// LoadFile reads a catalogue file from disk.
+func LoadFile(path string) (*Catalogue, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open catalogue: %w", err)
+	}
+	defer f.Close()
+
+	c, err := Load(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Demo returns the catalogue in test_data, with its exhibitions moved forward
+// so that what was on when it was written is on today. Without the move, every
+// listing in it would have closed within months of it being written.
+func Demo() (*Catalogue, error) {
+	c, err := Load(bytes.NewReader(test_data.Catalogue))
+	if err != nil {
+		return nil, fmt.Errorf("demo catalogue: %w", err)
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	for i := range c.shows {
+		e := &c.shows[i]
+		days := int(today.Sub(e.ScrapedAt.UTC().Truncate(24*time.Hour)).Hours() / 24)
+		e.Start, e.End = shiftDate(e.Start, days), shiftDate(e.End, days)
+		e.ScrapedAt = e.ScrapedAt.AddDate(0, 0, days)
+	}
+	for site, at := range c.attempts {
+		c.attempts[site] = at.AddDate(0, 0, int(today.Sub(at.UTC().Truncate(24*time.Hour)).Hours()/24))
+	}
+	return c, nil
+}
+
+// shiftDate moves a date by whole days, nil staying nil.
+func shiftDate(d *time.Time, days int) *time.Time {
+	if d == nil {
+		return nil
+	}
+	moved := d.AddDate(0, 0, days)
+	return &moved
+}
+
+// errDuplicateID reports two museums given the same id.
+var errDuplicateID = errors.New("duplicate museum id")
+
+// build assembles a catalogue from records.
+func build(records []Record) (*Catalogue, error) {
+	c := &Catalogue{
+		attempts: map[string]time.Time{},
+		places:   map[string]postgres.Place{},
+		gen:      postgres.Generation{Number: 1, ChangedAt: time.Now()},
+	}
+
+	var next int64
+	for _, rec := range records {
+		next = max(next, rec.ID)
+	}
+	seen := map[int64]bool{}
+	for _, rec := range records {
+		switch {
+		case rec.Museum != nil:
+			id := rec.ID
+			if id == 0 {
+				next++
+				id = next
+			}
+			if seen[id] {
+				return nil, fmt.Errorf("%w: %d", errDuplicateID, id)
+			}
+			seen[id] = true
+			row := postgres.CatalogueRowOf(id, *rec.Museum)
+			row.RetiredAt = rec.RetiredAt
+			row.ApproximateLocation = rec.ApproximateLocation
+			c.museums = append(c.museums, row)
+		case rec.Exhibition != nil:
+			c.shows = append(c.shows, *rec.Exhibition)
+		case rec.Site != "" && rec.AttemptedAt != nil:
+			c.attempts[rec.Site] = *rec.AttemptedAt
+		}
+	}
+	slices.SortFunc(c.museums, func(a, b postgres.CatalogueRow) int { return cmp.Compare(a.ID, b.ID) })
+
+	countries := map[string]bool{}
+	for _, row := range c.museums {
+		m := row.Museum
+		c.counts.Museums++
+		if located(m.Latitude, m.Longitude) {
+			c.counts.WithCoordinates++
+			c.towns.Add(m.Locality, row.LocalityNormalized, m.Country, m.Latitude, m.Longitude)
+		}
+		if m.Website != "" {
+			c.counts.WithWebsite++
+		}
+		if m.Country != "" {
+			countries[m.Country] = true
+		}
+		if c.counts.LastUpdated == nil || row.UpdatedAt.After(*c.counts.LastUpdated) {
+			updated := row.UpdatedAt
+			c.counts.LastUpdated = &updated
+		}
+	}
+	c.counts.Countries = int64(len(countries))
+	c.counts.Exhibitions = int64(len(c.shows))
+	return c, nil
+}
+
+// located reports whether a position is set. 0,0 is the database's "unset"
+// too: it is in the Gulf of Guinea, and no museum is there.
+func located(lat, lon float64) bool {
+	return lat != 0 || lon != 0
+}
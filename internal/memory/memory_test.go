@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"museum/internal/api"
+	"museum/internal/catalogtest"
+	"museum/internal/models"
+	"museum/pkg/exhibitions"
+)
+
+func TestCatalogue(t *testing.T) {
+	catalogtest.Run(t, func(_ *testing.T, museums []models.Museum, shows []exhibitions.Exhibition) api.Catalogue {
+		return New(museums, shows)
+	})
+}
+
+func TestLoad(t *testing.T) {
+	const file = `{"id": 7, "museum": {"name": "Rijksmuseum", "country": "Netherlands", "latitude": 52.36, "longitude": 4.8852}}
+
+{"museum": {"name": "Van Gogh Museum", "country": "Netherlands"}, "retired_at": "2026-03-01T00:00:00Z"}
+{"exhibition": {"title": "Seasons", "url": "https://example.org/seasons", "scraped_at": "2026-01-15T06:00:00Z"}}
+{"site": "rijksmuseum.nl", "attempted_at": "2026-01-15T06:00:00Z"}
+`
+	c, err := Load(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	counts, _ := c.Counts(ctx)
+	if counts.Museums != 2 || counts.WithCoordinates != 1 || counts.Exhibitions != 1 {
+		t.Errorf("counts = %+v, want two museums, one placed, one exhibition", counts)
+	}
+	// A museum without an id is numbered after the highest one given.
+	hit, err := c.MuseumByID(ctx, "8")
+	if err != nil || hit.Museum.Name != "Van Gogh Museum" || hit.RetiredAt == nil {
+		t.Errorf("museum 8 = %+v, %v; want the retired Van Gogh Museum", hit, err)
+	}
+
+	if _, err := Load(strings.NewReader(`{"id": 1, "museum": {"name": "A"}}` + "\n" + `{"id": 1, "museum": {"name": "B"}}`)); !errors.Is(err, errDuplicateID) {
+		t.Errorf("duplicate ids: err = %v, want errDuplicateID", err)
+	}
+	if _, err := Load(strings.NewReader(`{"id": 1}`)); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("an empty record: err = %v, want it reported by line", err)
+	}
+}
+
+func TestDemo(t *testing.T) {
+	c, err := Demo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	paris, err := c.LocalityPlace(ctx, "paris")
+	if err != nil || paris.DisplayName != "Paris" {
+		t.Fatalf("paris = %+v, %v; the demo has to resolve its own towns", paris, err)
+	}
+
+	// Moved to the present, what was on when the file was written is on now,
+	// and what was coming is still coming.
+	shows, err := c.ExhibitionsNearby(ctx, paris.Latitude, paris.Longitude, paris.RadiusKm, true, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var running, upcoming int
+	for _, s := range shows {
+		if s.Upcoming {
+			upcoming++
+		} else if s.Running {
+			running++
+		}
+	}
+	if running < 3 || upcoming != 1 {
+		t.Errorf("paris has %d running and %d upcoming exhibitions, want at least 3 and 1", running, upcoming)
+	}
+
+	coverage, err := c.ExhibitionCoverage(ctx, paris.Latitude, paris.Longitude, paris.RadiusKm)
+	if err != nil || coverage.LastScraped == nil {
+		t.Errorf("paris coverage = %+v, %v; want the Louvre's last read", coverage, err)
+	}
+}
@@ -0,0 +1,30 @@
+package memory
+
+import (
+	"context"
+
+	"museum/internal/postgres"
+)
+
+// LookupPlace returns a place resolved earlier in the process's life.
+func (c *Catalogue) LookupPlace(_ context.Context, query string) (postgres.Place, bool, error) {
+	c.placesMu.Lock()
+	defer c.placesMu.Unlock()
+	place, ok := c.places[query]
+	return place, ok, nil
+}
+
+// SavePlace remembers a resolved place until the process exits.
+func (c *Catalogue) SavePlace(_ context.Context, place postgres.Place) error {
+	c.placesMu.Lock()
+	defer c.placesMu.Unlock()
+	c.places[place.Query] = place
+	return nil
+}
+
+// LocalityPlace resolves a name against the catalogue's own towns, by the
+// database's rules. With no geocoder, as under "serve -demo", it is how
+// "Paris" is found at all.
+func (c *Catalogue) LocalityPlace(_ context.Context, query string) (postgres.Place, error) {
+	return c.towns.Resolve(query)
+}
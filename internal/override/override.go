@@ -0,0 +1,310 @@
+// Package override describes the corrections made to the catalogue by hand,
+// and decides which incoming records they apply to.
+//
+// A fix made directly in the database did not last: the next crawl or reindex
+// wrote the source's version back through the upsert, and the merges could
+// fold the corrected row into another. An override is a fix recorded as a rule
+// rather than as a value, so every write path can consult it: the upsert and
+// the in-process merger ask this package, and the SQL merges read the same
+// table themselves.
+package override
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"museum/internal/models"
+	"museum/internal/search"
+)
+
+// Kind is what an override does.
+type Kind string
+
+const (
+	// Pin holds one field of one museum at a value, whatever the sources say.
+	Pin Kind = "pin"
+	// Merge makes two museums one: OtherID is folded into MuseumID, and any
+	// record that would recreate it is folded in again.
+	Merge Kind = "merge"
+	// Split keeps two museums apart, whatever their names have in common.
+	Split Kind = "split"
+	// Suppress removes a record that is not a museum, and keeps it out.
+	Suppress Kind = "suppress"
+)
+
+// Errors an override can be refused with.
+var (
+	// ErrInvalid reports an override that cannot mean anything: an unknown
+	// kind, a field that cannot be pinned, a museum merged with itself.
+	ErrInvalid = errors.New("invalid override")
+	// ErrConflict reports an override that contradicts one already in force,
+	// such as a merge of two museums a split keeps apart.
+	ErrConflict = errors.New("conflicting override")
+)
+
+// Override is one correction.
+type Override struct {
+	ID       int64 `json:"id"`
+	Kind     Kind  `json:"kind"`
+	MuseumID int64 `json:"museum_id"`
+	// OtherID is the second museum of a merge or a split: the one folded in,
+	// or the one kept apart.
+	OtherID int64 `json:"other_id,omitempty"`
+	// Field and Value are a pin's: the field, and its value as JSON in the
+	// form the museum's history uses — a string, a boolean, a [lat, lon] pair,
+	// or null for a field held empty.
+	Field string          `json:"field,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+	// Ref is how the museum a merge folded in, or a suppression removed, is
+	// recognised when a source offers it again. Those rows are gone, so this
+	// is kept from when they were not.
+	Ref       *Ref      `json:"ref,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedBy string    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Pinnable lists the fields a pin may hold. Derived columns are left out —
+// they follow from these — and so are the lists, which are unions of what
+// every source said and have no single value to hold.
+var Pinnable = []string{
+	"name", "country", "locality", "description", "website", "wikipedia_url",
+	"street", "postcode", "location", "verified",
+}
+
+// Validate reports whether the override means something, before anything is
+// looked up.
+func (o Override) Validate() error {
+	if o.MuseumID <= 0 {
+		return fmt.Errorf("%w: no museum", ErrInvalid)
+	}
+	switch o.Kind {
+	case Pin:
+		if !slices.Contains(Pinnable, o.Field) {
+			return fmt.Errorf("%w: %q cannot be pinned; pinnable fields are %s",
+				ErrInvalid, o.Field, strings.Join(Pinnable, ", "))
+		}
+		if _, err := Decode(o.Field, o.Value); err != nil {
+			return err
+		}
+	case Merge, Split:
+		if o.OtherID <= 0 {
+			return fmt.Errorf("%w: a %s needs two museums", ErrInvalid, o.Kind)
+		}
+		if o.OtherID == o.MuseumID {
+			return fmt.Errorf("%w: museum %d cannot be %sd with itself", ErrInvalid, o.MuseumID, o.Kind)
+		}
+	case Suppress:
+	default:
+		return fmt.Errorf("%w: unknown kind %q, want pin, merge, split or suppress", ErrInvalid, o.Kind)
+	}
+	return nil
+}
+
+// Decode checks a pinned value against its field, returning it as Go: a
+// string, a bool, a [2]float64 position, or nil for a field held empty.
+func Decode(field string, value json.RawMessage) (any, error) {
+	if len(value) == 0 || string(value) == "null" {
+		if field == "name" || field == "verified" {
+			return nil, fmt.Errorf("%w: %s cannot be pinned empty", ErrInvalid, field)
+		}
+		return nil, nil
+	}
+	switch field {
+	case "verified":
+		var v bool
+		if err := json.Unmarshal(value, &v); err != nil {
+			return nil, fmt.Errorf("%w: verified takes true or false", ErrInvalid)
+		}
+		return v, nil
+	case "location":
+		var v [2]float64
+		if err := json.Unmarshal(value, &v); err != nil {
+			return nil, fmt.Errorf("%w: location takes [lat, lon]", ErrInvalid)
+		}
+		if v[0] < -90 || v[0] > 90 || v[1] < -180 || v[1] > 180 {
+			return nil, fmt.Errorf("%w: %v is not a position", ErrInvalid, v)
+		}
+		return v, nil
+	default:
+		var v string
+		if err := json.Unmarshal(value, &v); err != nil {
+			return nil, fmt.Errorf("%w: %s takes a string", ErrInvalid, field)
+		}
+		if strings.TrimSpace(v) == "" {
+			return nil, fmt.Errorf("%w: pin %s to null rather than to an empty string", ErrInvalid, field)
+		}
+		return v, nil
+	}
+}
+
+// ParseValue reads a pinned value as typed on a command line: "48.86,2.34"
+// for a location, true or false for verified, "-" for a field held empty, and
+// anything else as it stands.
+func ParseValue(field, text string) (json.RawMessage, error) {
+	if text == "-" {
+		return json.RawMessage("null"), nil
+	}
+	switch field {
+	case "verified":
+		v, err := strconv.ParseBool(text)
+		if err != nil {
+			return nil, fmt.Errorf("%w: verified takes true or false, got %q", ErrInvalid, text)
+		}
+		return json.Marshal(v)
+	case "location":
+		lat, lon, ok := strings.Cut(text, ",")
+		if !ok {
+			return nil, fmt.Errorf("%w: location takes LAT,LON, got %q", ErrInvalid, text)
+		}
+		var pos [2]float64
+		var err error
+		if pos[0], err = strconv.ParseFloat(strings.TrimSpace(lat), 64); err == nil {
+			pos[1], err = strconv.ParseFloat(strings.TrimSpace(lon), 64)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: location takes LAT,LON, got %q", ErrInvalid, text)
+		}
+		return json.Marshal(pos)
+	default:
+		return json.Marshal(text)
+	}
+}
+
+// Ref recognises a museum among incoming records: by Wikidata id when both
+// sides have one, and otherwise by name and country. It is the same rule the
+// database's identity column follows, so a record the override matches is one
+// the upsert would have written to the same row.
+type Ref struct {
+	WikidataID string `json:"wikidata_id,omitempty"`
+	// NameKey is the normalised name and the country, as in identity.
+	NameKey string `json:"name_key"`
+}
+
+// RefOf returns the Ref a museum record is recognised by.
+func RefOf(m models.Museum) Ref {
+	return Ref{WikidataID: strings.TrimSpace(m.WikidataID), NameKey: NameKey(m.Name, m.Country)}
+}
+
+// NameKey is a name and a country in the form the identity column holds them.
+func NameKey(name, country string) string {
+	country = strings.TrimSpace(country)
+	if strings.EqualFold(country, "unknown") {
+		country = ""
+	}
+	return search.Normalize(strings.TrimSpace(name)) + "|" + country
+}
+
+// Matches reports whether a record is the museum the Ref recognises. Two
+// Wikidata ids decide it outright; otherwise the name and country must agree.
+func (r Ref) Matches(m models.Museum) bool {
+	if id := strings.TrimSpace(m.WikidataID); r.WikidataID != "" && id != "" {
+		return r.WikidataID == id
+	}
+	return r.NameKey != "|" && r.NameKey == NameKey(m.Name, m.Country)
+}
+
+// Rules are the overrides in force, reduced to what an incoming record is
+// checked against. Pins are not among them: the database applies those to
+// every write itself.
+//
+// The zero value, and a nil *Rules, hold no overrides.
+type Rules struct {
+	suppressed []Ref
+	redirects  []redirect
+	splits     [][2]Ref
+}
+
+// redirect folds records recognised by from into a museum that stands under
+// another identity.
+type redirect struct {
+	from Ref
+	into models.Museum
+}
+
+// Suppress keeps records recognised by ref out of the catalogue.
+func (r *Rules) Suppress(ref Ref) {
+	r.suppressed = append(r.suppressed, ref)
+}
+
+// Redirect folds records recognised by from into the museum into, which needs
+// only its name, Wikidata id and country.
+//
+// A merge is the obvious case. A pinned name or country is the other: it
+// changes the row's identity, so without a redirect from the old one the next
+// crawl would recreate the museum under the name it was corrected from.
+func (r *Rules) Redirect(from Ref, into models.Museum) {
+	r.redirects = append(r.redirects, redirect{from: from, into: into})
+}
+
+// KeepApart stops records recognised by a being merged with ones recognised by
+// b.
+func (r *Rules) KeepApart(a, b Ref) {
+	r.splits = append(r.splits, [2]Ref{a, b})
+}
+
+// Len is how many rules there are.
+func (r *Rules) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.suppressed) + len(r.redirects) + len(r.splits)
+}
+
+// Suppressed reports whether a record is one a suppression keeps out.
+func (r *Rules) Suppressed(m models.Museum) bool {
+	if r == nil {
+		return false
+	}
+	for _, ref := range r.suppressed {
+		if ref.Matches(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply rewrites a record a merge or a pin has redirected so it carries the
+// identity of the museum it belongs to, keeping its own name as an alias. The
+// upsert and the merger then fold it in the way they would fold any other
+// record of that museum. A record no rule names is returned unchanged.
+func (r *Rules) Apply(m models.Museum) models.Museum {
+	if r == nil {
+		return m
+	}
+	for _, rd := range r.redirects {
+		if !rd.from.Matches(m) || RefOf(rd.into).Matches(m) {
+			continue
+		}
+		if m.Name != rd.into.Name && !slices.Contains(m.AlsoKnownAs, m.Name) {
+			m.AlsoKnownAs = append(slices.Clone(m.AlsoKnownAs), m.Name)
+		}
+		m.Name, m.WikidataID, m.Country = rd.into.Name, rd.into.WikidataID, rd.into.Country
+		return m
+	}
+	return m
+}
+
+// Apart reports whether a split forbids merging two records.
+//
+// Two records with the same Wikidata id are never apart: they are one museum by
+// its own authority, and a split between two rows cannot have meant them.
+func (r *Rules) Apart(a, b models.Museum) bool {
+	if r == nil {
+		return false
+	}
+	if id := strings.TrimSpace(a.WikidataID); id != "" && id == strings.TrimSpace(b.WikidataID) {
+		return false
+	}
+	for _, pair := range r.splits {
+		if (pair[0].Matches(a) && pair[1].Matches(b)) || (pair[1].Matches(a) && pair[0].Matches(b)) {
+			return true
+		}
+	}
+	return false
+}
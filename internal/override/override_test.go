@@ -0,0 +1,102 @@
+package override
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"museum/internal/models"
+)
+
+func TestRefMatches(t *testing.T) {
+	ref := RefOf(models.Museum{Name: "Musée d'Orsay", Country: "France", WikidataID: "Q23402"})
+
+	tests := []struct {
+		name   string
+		museum models.Museum
+		want   bool
+	}{
+		{"same id", models.Museum{Name: "Orsay Museum", WikidataID: "Q23402"}, true},
+		{"another id outranks the name", models.Museum{Name: "Musée d'Orsay", Country: "France", WikidataID: "Q1"}, false},
+		{"no id, same name spelled plainly", models.Museum{Name: "Musee d Orsay", Country: "France"}, true},
+		{"no id, another country", models.Museum{Name: "Musée d'Orsay", Country: "Belgium"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ref.Matches(tt.museum); got != tt.want {
+				t.Errorf("Matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if (Ref{NameKey: NameKey("", "")}).Matches(models.Museum{}) {
+		t.Error("an empty ref matched an empty record")
+	}
+}
+
+func TestRulesApply(t *testing.T) {
+	var rules Rules
+	keeper := models.Museum{Name: "Tate Modern", Country: "United Kingdom", WikidataID: "Q193375"}
+	rules.Redirect(Ref{NameKey: NameKey("Bankside Gallery of Modern Art", "United Kingdom")}, keeper)
+
+	got := rules.Apply(models.Museum{Name: "Bankside Gallery of Modern Art", Country: "United Kingdom",
+		AlsoKnownAs: []string{"Bankside"}})
+	if got.Name != keeper.Name || got.WikidataID != keeper.WikidataID {
+		t.Errorf("redirected record = %+v, want the keeper's identity", got)
+	}
+	if len(got.AlsoKnownAs) != 2 || got.AlsoKnownAs[1] != "Bankside Gallery of Modern Art" {
+		t.Errorf("aliases = %q, want its own name kept", got.AlsoKnownAs)
+	}
+
+	other := models.Museum{Name: "Tate Britain", Country: "United Kingdom"}
+	if rules.Apply(other).Name != "Tate Britain" {
+		t.Error("a record no rule names was rewritten")
+	}
+	if (*Rules)(nil).Apply(other).Name != "Tate Britain" || (*Rules)(nil).Suppressed(other) {
+		t.Error("nil rules should do nothing")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		override Override
+		want     error
+	}{
+		{"pin a website", Override{Kind: Pin, MuseumID: 1, Field: "website", Value: json.RawMessage(`"https://example.org"`)}, nil},
+		{"pin a website empty", Override{Kind: Pin, MuseumID: 1, Field: "website", Value: json.RawMessage(`null`)}, nil},
+		{"pin a position", Override{Kind: Pin, MuseumID: 1, Field: "location", Value: json.RawMessage(`[48.86, 2.33]`)}, nil},
+		{"pin off the globe", Override{Kind: Pin, MuseumID: 1, Field: "location", Value: json.RawMessage(`[91, 2.33]`)}, ErrInvalid},
+		{"pin a derived column", Override{Kind: Pin, MuseumID: 1, Field: "search_text", Value: json.RawMessage(`"x"`)}, ErrInvalid},
+		{"pin a name empty", Override{Kind: Pin, MuseumID: 1, Field: "name", Value: json.RawMessage(`null`)}, ErrInvalid},
+		{"merge with itself", Override{Kind: Merge, MuseumID: 1, OtherID: 1}, ErrInvalid},
+		{"split with nothing", Override{Kind: Split, MuseumID: 1}, ErrInvalid},
+		{"suppress", Override{Kind: Suppress, MuseumID: 1}, nil},
+		{"unknown kind", Override{Kind: "delete", MuseumID: 1}, ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.override.Validate(); !errors.Is(err, tt.want) {
+				t.Errorf("Validate = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	tests := []struct{ field, text, want string }{
+		{"website", "https://example.org", `"https://example.org"`},
+		{"website", "-", `null`},
+		{"verified", "true", `true`},
+		{"location", "48.8606, 2.3376", `[48.8606,2.3376]`},
+	}
+	for _, tt := range tests {
+		got, err := ParseValue(tt.field, tt.text)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("ParseValue(%s, %q) = %s, %v; want %s", tt.field, tt.text, got, err, tt.want)
+		}
+	}
+	if _, err := ParseValue("location", "somewhere"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("a location without a comma: err = %v", err)
+	}
+}
@@ -0,0 +1,369 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// The exhibition archive holds what ended long ago, out of the live table.
+//
+// An exhibition that closed, or whose listing was retired, stays live for a
+// while: a show that closed on Sunday is still what someone asks about on
+// Monday, and a listing retired by a bad read is restored by the next good one
+// only while its row is there to restore. After that it is history, and
+// history is read differently — by date and by museum, never as "what is on" —
+// so it is moved to exhibitions_archive, partitioned by the year it ended, and
+// read back through the exhibitions_ever view.
+
+// archiveBatch is how many exhibitions one statement moves. Each batch is its
+// own transaction, so a first run over years of accumulated rows neither holds
+// one enormous transaction open nor locks the live table for its length.
+const archiveBatch = 5000
+
+// sameVenueMeters is how far a listing without a Wikidata id may be from a
+// museum of its name and still be that museum's. A listing is saved at its
+// museum's position, so anything near is the same building, give or take a
+// later correction of where that building is; a namesake is another town.
+const sameVenueMeters = 2000
+
+// archiveColumns are the columns an exhibition keeps in the archive, in the
+// order both tables declare them.
+const archiveColumns = `url, title, museum, museum_wikidata_id, starts_on, ends_on, location,
+       source_page, scraped_at, permanent, first_seen_at, last_seen_at,
+       retired_at, source, site, text_config`
+
+// Archived is what an archive run moved, or would move.
+type Archived struct {
+	// ByYear counts the exhibitions by the year they ended, which is the
+	// partition each went to.
+	ByYear map[int]int64
+	// Total is the sum of ByYear.
+	Total int64
+}
+
+// Years are the years moved, oldest first.
+func (a Archived) Years() []int {
+	return slices.Sorted(maps.Keys(a.ByYear))
+}
+
+func (a *Archived) add(year int, n int64) {
+	if a.ByYear == nil {
+		a.ByYear = map[int]int64{}
+	}
+	a.ByYear[year] += n
+	a.Total += n
+}
+
+// ArchiveExhibitions moves the exhibitions that ended before cutoff out of the
+// live table and into the archive, and reports how many went to each year. A
+// dry run counts them and moves nothing.
+//
+// An exhibition ended on its closing date, or on the day its listing was
+// retired if that came first. A permanent display that is still listed has
+// done neither, and stays however old it is.
+func (s *Store) ArchiveExhibitions(ctx context.Context, cutoff time.Time, dryRun bool) (Archived, error) {
+	var archived Archived
+	if dryRun {
+		rows, err := s.pool.Query(ctx, `
+SELECT extract(year FROM least(ends_on, retired_at::date))::int, count(*)
+FROM exhibitions
+WHERE least(ends_on, retired_at::date) < $1
+GROUP BY 1`, cutoff)
+		if err != nil {
+			return Archived{}, fmt.Errorf("archive exhibitions: %w", err)
+		}
+		var (
+			year int
+			n    int64
+		)
+		if _, err := pgx.ForEachRow(rows, []any{&year, &n}, func() error {
+			archived.add(year, n)
+			return nil
+		}); err != nil {
+			return Archived{}, fmt.Errorf("archive exhibitions: %w", err)
+		}
+		return archived, nil
+	}
+
+	if err := s.partitionArchive(ctx, cutoff); err != nil {
+		return Archived{}, err
+	}
+
+	// The live rows are locked SKIP LOCKED, so a sweep saving a site's
+	// listings is neither blocked by the move nor blocks it; what it holds is
+	// left for the next batch or the next run.
+	//
+	// A URL archived before with the same end is the same run archived twice
+	// — restored by a read, then retired again the same day — and the newer
+	// copy replaces it.
+	stmt := `
+WITH moved AS (
+    DELETE FROM exhibitions
+    WHERE url IN (SELECT url FROM exhibitions
+                  WHERE least(ends_on, retired_at::date) < $1
+                  LIMIT $2
+                  FOR UPDATE SKIP LOCKED)
+    RETURNING ` + archiveColumns + `, least(ends_on, retired_at::date) AS ended_on
+), filed AS (
+    INSERT INTO exhibitions_archive (` + archiveColumns + `, ended_on)
+    SELECT * FROM moved
+    ON CONFLICT (url, ended_on) DO UPDATE
+       SET title = excluded.title, museum = excluded.museum,
+           museum_wikidata_id = excluded.museum_wikidata_id,
+           starts_on = excluded.starts_on, ends_on = excluded.ends_on,
+           location = excluded.location, source_page = excluded.source_page,
+           scraped_at = excluded.scraped_at, permanent = excluded.permanent,
+           first_seen_at = excluded.first_seen_at, last_seen_at = excluded.last_seen_at,
+           retired_at = excluded.retired_at, source = excluded.source,
+           site = excluded.site, text_config = excluded.text_config,
+           archived_at = now()
+    RETURNING ended_on
+)
+SELECT extract(year FROM ended_on)::int, count(*) FROM filed GROUP BY 1`
+
+	defer func() {
+		if archived.Total > 0 {
+			s.bumpGeneration(ctx)
+		}
+	}()
+	for {
+		rows, err := s.pool.Query(ctx, stmt, cutoff, archiveBatch)
+		if err != nil {
+			return archived, fmt.Errorf("archive exhibitions: %w", err)
+		}
+		var (
+			year  int
+			n     int64
+			batch int64
+		)
+		if _, err := pgx.ForEachRow(rows, []any{&year, &n}, func() error {
+			archived.add(year, n)
+			batch += n
+			return nil
+		}); err != nil {
+			return archived, fmt.Errorf("archive exhibitions: %w", err)
+		}
+		if batch < archiveBatch {
+			return archived, nil
+		}
+	}
+}
+
+// partitionArchive makes sure the archive has a partition for every year a run
+// before cutoff will move, and for every year in the default partition.
+//
+// Rows reach the default partition when no partition for their year exists,
+// which a restore into a fresh schema does to the whole archive. A partition
+// for a year cannot be attached while the default holds rows of that year, so
+// creating one first moves them into it; this is also what files a restored
+// archive by year again.
+func (s *Store) partitionArchive(ctx context.Context, cutoff time.Time) error {
+	rows, err := s.pool.Query(ctx, `
+SELECT DISTINCT extract(year FROM least(ends_on, retired_at::date))::int
+FROM exhibitions
+WHERE least(ends_on, retired_at::date) < $1
+UNION
+SELECT DISTINCT extract(year FROM ended_on)::int
+FROM exhibitions_archive_default`, cutoff)
+	if err != nil {
+		return fmt.Errorf("archive partitions: %w", err)
+	}
+	years, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return fmt.Errorf("archive partitions: %w", err)
+	}
+	for _, year := range years {
+		if err := s.archivePartition(ctx, year); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archivePartition creates the archive's partition for a year, unless it
+// exists, moving in whatever the default partition holds for that year.
+func (s *Store) archivePartition(ctx context.Context, year int) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("archive partition %d: %w", year, err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Taken before looking, and conflicting with itself, so two archivers
+	// cannot both find the year missing and both try to attach it.
+	if _, err := tx.Exec(ctx, `LOCK TABLE exhibitions_archive IN SHARE UPDATE EXCLUSIVE MODE`); err != nil {
+		return fmt.Errorf("archive partition %d: %w", year, err)
+	}
+
+	name := "exhibitions_archive_" + strconv.Itoa(year)
+	var exists bool
+	if err := tx.QueryRow(ctx, `
+SELECT EXISTS (SELECT 1 FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid
+               WHERE i.inhparent = 'exhibitions_archive'::regclass AND c.relname = $1)`,
+		name).Scan(&exists); err != nil {
+		return fmt.Errorf("archive partition %d: %w", year, err)
+	}
+	if exists {
+		return nil
+	}
+
+	from, to := fmt.Sprintf("%04d-01-01", year), fmt.Sprintf("%04d-01-01", year+1)
+	for _, stmt := range []string{
+		fmt.Sprintf(`CREATE TABLE %s (LIKE exhibitions_archive INCLUDING DEFAULTS)`, name),
+		fmt.Sprintf(`
+WITH moved AS (DELETE FROM exhibitions_archive_default
+               WHERE ended_on >= '%s' AND ended_on < '%s' RETURNING *)
+INSERT INTO %s SELECT * FROM moved`, from, to, name),
+		fmt.Sprintf(`ALTER TABLE exhibitions_archive ATTACH PARTITION %s FOR VALUES FROM ('%s') TO ('%s')`,
+			name, from, to),
+	} {
+		if _, err := tx.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("archive partition %d: %w", year, err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("archive partition %d: %w", year, err)
+	}
+	return nil
+}
+
+// ExhibitionsOn returns what was on show within radiusKm on a given day, from
+// the live table and the archive alike, soonest to close first.
+//
+// "On" needs evidence: an exhibition counts from its opening date, or when it
+// has none from the day the catalogue first saw it listed — a listing without
+// dates found in 2025 says nothing about 2019.
+func (s *Store) ExhibitionsOn(ctx context.Context, lat, lon, radiusKm float64, day time.Time, limit int) ([]ExhibitionHit, error) {
+	const stmt = `
+SELECT url, title, coalesce(museum,''), coalesce(museum_wikidata_id,''),
+       starts_on, ends_on, coalesce(source_page,''), scraped_at, permanent,
+       ST_Y(location::geometry), ST_X(location::geometry),
+       ST_Distance(location, $1::geography) / 1000.0 AS distance_km, archived
+FROM exhibitions_ever
+WHERE location IS NOT NULL
+  AND ST_DWithin(location, $1::geography, $2)
+  AND coalesce(starts_on, first_seen_at::date) <= $3
+  AND (ended_on IS NULL OR ended_on >= $3)
+ORDER BY ends_on NULLS LAST, distance_km
+LIMIT $4`
+
+	point := fmt.Sprintf("SRID=4326;POINT(%v %v)", lon, lat)
+	rows, err := s.reader(ctx).Query(ctx, stmt, point, radiusKm*1000, day, limit)
+	if err != nil {
+		return nil, fmt.Errorf("exhibitions on %s: %w", day.Format(time.DateOnly), err)
+	}
+	defer rows.Close()
+
+	var hits []ExhibitionHit
+	for rows.Next() {
+		hit, err := scanArchivedHit(rows)
+		if err != nil {
+			return nil, err
+		}
+		// Running on the day asked about, which is what the caller asked.
+		hit.Running = true
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+// MuseumExhibitions returns every exhibition a museum has held that the
+// catalogue knows of, live and archived, the most recently ended first and
+// those still on ahead of them all, with how many there are in total.
+//
+// The id is the numeric id or a Wikidata id, as for MuseumByID. Listings are
+// matched to the museum by Wikidata id where it has one, and otherwise by its
+// name among the listings that have none, which is how they were saved. A
+// listing carries its museum's position but not its country, so nearness
+// stands in for the country half of the museum's identity, and two museums of
+// one name in different places keep their own histories.
+func (s *Store) MuseumExhibitions(ctx context.Context, id string, limit, offset int) ([]ExhibitionHit, int64, error) {
+	var numeric *int64
+	if parsed, err := strconv.ParseInt(id, 10, 64); err == nil {
+		numeric = &parsed
+	}
+	var (
+		qid, name string
+		location  *string
+	)
+	err := s.reader(ctx).QueryRow(ctx, `
+SELECT coalesce(wikidata_id, ''), name, ST_AsEWKT(location) FROM museums
+WHERE ($1::bigint IS NOT NULL AND id = $1::bigint) OR wikidata_id = $2
+LIMIT 1`, numeric, id).Scan(&qid, &name, &location)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, 0, fmt.Errorf("museum %q: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("museum exhibitions: %w", err)
+	}
+
+	// Each branch is its own statement, so the Wikidata id keeps its index.
+	match, args := `museum_wikidata_id = $3`, []any{qid}
+	if qid == "" {
+		match = `coalesce(museum_wikidata_id, '') = ''
+  AND lower(btrim(museum)) = lower(btrim($3::text))
+  AND ($4::geography IS NULL OR location IS NULL OR ST_DWithin(location, $4::geography, $5))`
+		args = []any{name, location, sameVenueMeters}
+	}
+	stmt := `
+SELECT url, title, coalesce(museum,''), coalesce(museum_wikidata_id,''),
+       starts_on, ends_on, coalesce(source_page,''), scraped_at, permanent,
+       ST_Y(location::geometry), ST_X(location::geometry),
+       0::float8, archived, count(*) OVER ()
+FROM exhibitions_ever
+WHERE ` + match + `
+ORDER BY ended_on DESC NULLS FIRST, starts_on DESC NULLS LAST, url
+LIMIT $1 OFFSET $2`
+
+	rows, err := s.reader(ctx).Query(ctx, stmt, append([]any{limit, offset}, args...)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("museum exhibitions: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		hits  []ExhibitionHit
+		total int64
+		now   = time.Now()
+	)
+	for rows.Next() {
+		hit, err := scanArchivedHit(rows, &total)
+		if err != nil {
+			return nil, 0, err
+		}
+		current := !hit.Archived && (hit.End == nil || !hit.End.Before(now.Truncate(24*time.Hour)))
+		hit.Running = current && (hit.Permanent || hit.Start == nil || !hit.Start.After(now))
+		hit.Upcoming = current && !hit.Permanent && hit.Start != nil && hit.Start.After(now)
+		hits = append(hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("museum exhibitions: %w", err)
+	}
+	return hits, total, nil
+}
+
+// scanArchivedHit reads a row of exhibitions_ever, and whatever follows it
+// into extra.
+func scanArchivedHit(rows pgx.Rows, extra ...any) (ExhibitionHit, error) {
+	var (
+		hit      ExhibitionHit
+		lat, lon *float64
+	)
+	dest := append([]any{&hit.URL, &hit.Title, &hit.Museum, &hit.MuseumWikidataID,
+		&hit.Start, &hit.End, &hit.SourcePage, &hit.ScrapedAt, &hit.Permanent,
+		&lat, &lon, &hit.DistanceKm, &hit.Archived}, extra...)
+	if err := rows.Scan(dest...); err != nil {
+		return ExhibitionHit{}, fmt.Errorf("scan exhibition: %w", err)
+	}
+	if lat != nil && lon != nil {
+		hit.Latitude, hit.Longitude = *lat, *lon
+	}
+	return hit, nil
+}
@@ -0,0 +1,143 @@
+package postgres
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"museum/internal/models"
+	"museum/pkg/exhibitions"
+)
+
+func TestArchiveExhibitions_MovesWhatEndedLongAgoAndKeepsItReadable(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	if _, err := store.SaveMuseums(ctx, []models.Museum{{Name: "Tate Modern", Country: "United Kingdom",
+		Latitude: 51.5076, Longitude: -0.0994, WikidataID: "Q193375"}}); err != nil {
+		t.Fatalf("save museum: %v", err)
+	}
+	day := func(y int, m time.Month, d int) *time.Time {
+		t := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+	show := func(slug string, start, end *time.Time) exhibitions.Exhibition {
+		e := listing("tate.example", slug, slug, end)
+		e.Start, e.MuseumWikidataID = start, "Q193375"
+		e.Latitude, e.Longitude = 51.5076, -0.0994
+		return e
+	}
+	lastWeek := now.AddDate(0, 0, -7)
+	nextMonth := now.AddDate(0, 1, 0)
+	saveAt(t, store, now,
+		show("kusama", day(2023, 5, 1), day(2023, 9, 30)),
+		show("hockney", day(2024, 2, 1), day(2024, 6, 30)),
+		show("closed-last-week", day(2024, 2, 1), &lastWeek),
+		show("on-now", nil, &nextMonth),
+	)
+
+	cutoff := now.AddDate(0, 0, -180)
+	planned, err := store.ArchiveExhibitions(ctx, cutoff, true)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if planned.Total != 2 || planned.ByYear[2023] != 1 || planned.ByYear[2024] != 1 {
+		t.Errorf("dry run = %+v, want one from 2023 and one from 2024", planned)
+	}
+	if counts, _ := store.Counts(ctx); counts.Exhibitions != 4 {
+		t.Errorf("a dry run left %d exhibitions live, want all 4", counts.Exhibitions)
+	}
+
+	archived, err := store.ArchiveExhibitions(ctx, cutoff, false)
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	if archived.Total != 2 {
+		t.Errorf("archived %d, want 2", archived.Total)
+	}
+	var partitions int
+	if err := store.pool.QueryRow(ctx, `
+SELECT count(*) FROM pg_inherits WHERE inhparent = 'exhibitions_archive'::regclass`).Scan(&partitions); err != nil {
+		t.Fatal(err)
+	}
+	if partitions != 3 {
+		t.Errorf("%d partitions, want 2023, 2024 and the default", partitions)
+	}
+	if again, err := store.ArchiveExhibitions(ctx, cutoff, false); err != nil || again.Total != 0 {
+		t.Errorf("second run = %+v, %v; want nothing left to move", again, err)
+	}
+
+	// Gone from the reads about now, and still there for the reads about then.
+	live, err := store.ExhibitionsNearby(ctx, 51.5076, -0.0994, 1, true, 10)
+	if err != nil || len(live) != 1 || live[0].Title != "on-now" {
+		t.Errorf("live = %+v, %v; want only what is on", live, err)
+	}
+	then, err := store.ExhibitionsOn(ctx, 51.5076, -0.0994, 1, *day(2024, 3, 15), 10)
+	if err != nil {
+		t.Fatalf("exhibitions on: %v", err)
+	}
+	var titles []string
+	for _, hit := range then {
+		titles = append(titles, hit.Title)
+	}
+	if len(then) != 2 || !then[0].Archived || then[0].Title != "hockney" || then[1].Title != "closed-last-week" {
+		t.Errorf("on 2024-03-15 = %v, want hockney from the archive, then the live one open since February", titles)
+	}
+
+	all, total, err := store.MuseumExhibitions(ctx, "Q193375", 10, 0)
+	if err != nil || total != 4 || len(all) != 4 {
+		t.Fatalf("museum exhibitions = %d of %d, %v; want all four", len(all), total, err)
+	}
+	if all[0].Title != "on-now" || !all[0].Running || all[3].Title != "kusama" || !all[3].Archived {
+		t.Errorf("museum exhibitions in order %v..%v, want what is on first and the oldest last", all[0], all[3])
+	}
+}
+
+func TestMuseumExhibitions_MatchesAMuseumWithoutAWikidataID(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	if _, err := store.SaveMuseums(ctx, []models.Museum{
+		{Name: "Musée des Arts Forains", Country: "France", Latitude: 48.8336, Longitude: 2.3873},
+		{Name: "Musée des Arts Forains", Country: "Canada", Latitude: 45.5019, Longitude: -73.5674},
+	}); err != nil {
+		t.Fatalf("save museums: %v", err)
+	}
+	var paris int64
+	if err := store.pool.QueryRow(ctx,
+		`SELECT id FROM museums WHERE country = 'France'`).Scan(&paris); err != nil {
+		t.Fatalf("find museum: %v", err)
+	}
+
+	at := func(slug, museum string, lat, lon float64, end *time.Time) exhibitions.Exhibition {
+		e := listing("forains.example", slug, slug, end)
+		e.Museum, e.Latitude, e.Longitude = museum, lat, lon
+		return e
+	}
+	longAgo := now.AddDate(-2, 0, 0)
+	nextMonth := now.AddDate(0, 1, 0)
+	saveAt(t, store, now,
+		at("carousels", "Musée des Arts Forains", 48.8336, 2.3873, &nextMonth),
+		at("fairground-organs", "Musée des Arts Forains", 48.8336, 2.3873, &longAgo),
+		at("montreal", "Musée des Arts Forains", 45.5019, -73.5674, &nextMonth),
+		at("elsewhere", "Pavillons de Bercy", 48.8336, 2.3873, &nextMonth),
+	)
+	if _, err := store.ArchiveExhibitions(ctx, now.AddDate(0, 0, -180), false); err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+
+	hits, total, err := store.MuseumExhibitions(ctx, strconv.FormatInt(paris, 10), 10, 0)
+	if err != nil {
+		t.Fatalf("museum exhibitions: %v", err)
+	}
+	var titles []string
+	for _, hit := range hits {
+		titles = append(titles, hit.Title)
+	}
+	if total != 2 || len(hits) != 2 || hits[0].Title != "carousels" || hits[1].Title != "fairground-organs" || !hits[1].Archived {
+		t.Errorf("museum exhibitions = %v of %d, want its own listing and its archived one, not the namesake's or a neighbour's", titles, total)
+	}
+}
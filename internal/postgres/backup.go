@@ -0,0 +1,392 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrNotEmpty reports a restore into a schema that already holds a catalogue.
+var ErrNotEmpty = errors.New("schema is not empty")
+
+// Table is one of the catalogue's tables, as a backup copies it: by name,
+// and by the columns that hold data rather than derive it. Generated columns
+// are left out; the database recomputes them as the rows go back in, and
+// refuses them if offered.
+type Table struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+}
+
+// Snapshot is a consistent read of every table: one repeatable-read
+// transaction, so rows copied from museums and from the tables that refer to
+// them describe the same moment however long the copy takes.
+type Snapshot struct {
+	conn *pgxpool.Conn
+	tx   pgx.Tx
+
+	// Version is the schema version the tables are at.
+	Version int
+	// Tables are in an order they can be restored in, each after the tables
+	// it refers to.
+	Tables []Table
+}
+
+// Snapshot begins a snapshot. Close it when done.
+func (s *Store) Snapshot(ctx context.Context) (*Snapshot, error) {
+	if err := s.CheckSchema(ctx); err != nil {
+		return nil, err
+	}
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: %w", err)
+	}
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		conn.Release()
+		return nil, fmt.Errorf("snapshot: %w", err)
+	}
+
+	tables, err := catalogueTables(ctx, tx)
+	if err != nil {
+		tx.Rollback(ctx)
+		conn.Release()
+		return nil, fmt.Errorf("snapshot: %w", err)
+	}
+	return &Snapshot{conn: conn, tx: tx, Version: SchemaVersion(), Tables: tables}, nil
+}
+
+// CopyOut writes the table's rows to w in COPY's text format, returning how
+// many there were.
+func (sn *Snapshot) CopyOut(ctx context.Context, table Table, w io.Writer) (int64, error) {
+	stmt := fmt.Sprintf("COPY (SELECT %s FROM %s) TO STDOUT",
+		columnList(table.Columns), pgx.Identifier{table.Name}.Sanitize())
+	tag, err := sn.tx.Conn().PgConn().CopyTo(ctx, w, stmt)
+	if err != nil {
+		return 0, fmt.Errorf("copy %s out: %w", table.Name, err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// Close ends the snapshot.
+func (sn *Snapshot) Close(ctx context.Context) {
+	sn.tx.Rollback(ctx)
+	sn.conn.Release()
+}
+
+// Restore fills an empty schema from a snapshot taken at version, opening
+// each table's COPY text with open, and returns the rows restored by table.
+// A schema that "museum migrate up" has just created counts as empty, so a
+// restore can follow a deploy onto a new database.
+//
+// The schema is moved to the snapshot's version first, so the rows land in
+// the tables they came out of, then up to this binary's. A snapshot from a
+// newer binary cannot be restored: this one does not know its tables.
+//
+// The rows go in with the tables' triggers off. They record history, hold
+// pinned fields and write outbox events on every write, and the rows being
+// restored already carry the history and the pins they had. A restore is not
+// a change to the catalogue, so nothing is published for it — nor anything
+// that was waiting to be when the backup was taken. Those events were
+// published since, or describe a catalogue the restore has just replaced, and
+// backups no longer take them; ones older backups took are copied, so the
+// part is still checked, and then discarded.
+func (s *Store) Restore(ctx context.Context, version int, tables []Table, open func(Table) (io.ReadCloser, error)) (map[string]int64, error) {
+	if version > SchemaVersion() {
+		return nil, fmt.Errorf("%w: the backup is at version %d and this binary knows only up to %d",
+			ErrSchemaAhead, version, SchemaVersion())
+	}
+	current, err := s.emptyVersion(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("restore: %w", err)
+	}
+	if current > version {
+		if _, err := s.MigrateDown(ctx, current-version); err != nil {
+			return nil, fmt.Errorf("restore: %w", err)
+		}
+	}
+	if _, err := s.MigrateUp(ctx, version); err != nil {
+		return nil, fmt.Errorf("restore: %w", err)
+	}
+
+	rows := map[string]int64{}
+	err = pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		names := make([]string, len(tables))
+		for i, t := range tables {
+			names[i] = pgx.Identifier{t.Name}.Sanitize()
+		}
+		// The migrations seed a few rows of their own, the generation counter
+		// among them; the snapshot has its own copy of each.
+		if _, err := tx.Exec(ctx, "TRUNCATE "+strings.Join(names, ", ")); err != nil {
+			return err
+		}
+		for _, name := range names {
+			if _, err := tx.Exec(ctx, "ALTER TABLE "+name+" DISABLE TRIGGER USER"); err != nil {
+				return err
+			}
+		}
+
+		for _, t := range tables {
+			n, err := copyIn(ctx, tx, t, open)
+			if err != nil {
+				return err
+			}
+			rows[t.Name] = n
+		}
+
+		if slices.ContainsFunc(tables, func(t Table) bool { return t.Name == outboxTable }) {
+			if _, err := tx.Exec(ctx, "DELETE FROM "+outboxTable); err != nil {
+				return err
+			}
+		}
+
+		for _, t := range tables {
+			if err := resetSequences(ctx, tx, t.Name); err != nil {
+				return err
+			}
+		}
+		for _, name := range names {
+			if _, err := tx.Exec(ctx, "ALTER TABLE "+name+" ENABLE TRIGGER USER"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("restore: %w", err)
+	}
+
+	if _, err := s.MigrateUp(ctx, 0); err != nil {
+		return nil, fmt.Errorf("restore: %w", err)
+	}
+	// Clients hold responses tagged with generations this database may since
+	// have passed; moving on from the restored one is the best available.
+	s.bumpGeneration(ctx)
+	return rows, nil
+}
+
+// emptyVersion returns the version of a schema holding no catalogue, and
+// ErrNotEmpty for one that does. Only the generation counter, which the
+// migrations seed, may have a row.
+func (s *Store) emptyVersion(ctx context.Context) (int, error) {
+	var (
+		version int
+		full    []string
+	)
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		tables, err := catalogueTables(ctx, tx)
+		if err != nil || len(tables) == 0 {
+			return err
+		}
+		for _, t := range tables {
+			var rows bool
+			stmt := "SELECT EXISTS (SELECT 1 FROM " + pgx.Identifier{t.Name}.Sanitize() + ")"
+			if err := tx.QueryRow(ctx, stmt).Scan(&rows); err != nil {
+				return err
+			}
+			if rows && t.Name != "catalogue_generation" {
+				full = append(full, t.Name)
+			}
+		}
+		// The tables are here, so the bookkeeping is too, and this finds
+		// the schema's own rather than one further along the search path.
+		return tx.QueryRow(ctx, `SELECT coalesce(max(version), 0) FROM schema_migrations`).Scan(&version)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(full) > 0 {
+		return 0, fmt.Errorf("%w: %s already hold rows", ErrNotEmpty, strings.Join(full, ", "))
+	}
+	return version, nil
+}
+
+// copyIn loads one table's rows.
+func copyIn(ctx context.Context, tx pgx.Tx, table Table, open func(Table) (io.ReadCloser, error)) (int64, error) {
+	r, err := open(table)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	stmt := fmt.Sprintf("COPY %s (%s) FROM STDIN",
+		pgx.Identifier{table.Name}.Sanitize(), columnList(table.Columns))
+	tag, err := tx.Conn().PgConn().CopyFrom(ctx, r, stmt)
+	if err != nil {
+		return 0, fmt.Errorf("copy %s in: %w", table.Name, err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// resetSequences moves each of a table's serial columns past the highest id
+// restored, so the next row inserted does not collide with one of them.
+func resetSequences(ctx context.Context, tx pgx.Tx, table string) error {
+	rows, err := tx.Query(ctx, `
+SELECT a.attname, pg_get_serial_sequence(quote_ident($1), a.attname)
+FROM pg_attribute a
+WHERE a.attrelid = quote_ident($1)::regclass AND a.attnum > 0 AND NOT a.attisdropped
+  AND pg_get_serial_sequence(quote_ident($1), a.attname) IS NOT NULL`, table)
+	if err != nil {
+		return err
+	}
+	type serial struct{ column, sequence string }
+	serials, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (serial, error) {
+		var s serial
+		return s, row.Scan(&s.column, &s.sequence)
+	})
+	if err != nil {
+		return fmt.Errorf("sequences of %s: %w", table, err)
+	}
+
+	for _, s := range serials {
+		stmt := fmt.Sprintf("SELECT setval($1, coalesce(max(%s), 0) + 1, false) FROM %s",
+			pgx.Identifier{s.column}.Sanitize(), pgx.Identifier{table}.Sanitize())
+		if _, err := tx.Exec(ctx, stmt, s.sequence); err != nil {
+			return fmt.Errorf("reset %s: %w", s.sequence, err)
+		}
+	}
+	return nil
+}
+
+// outboxTable holds change events waiting for the relay, which are not part
+// of the catalogue and are not backed up.
+const outboxTable = "outbox_events"
+
+// catalogueTables lists the tables in the current schema, in restore order.
+// The migrations' own bookkeeping is left out, as is the outbox, and so are
+// tables an extension owns: PostGIS keeps spatial_ref_sys beside the
+// catalogue in public, and installs it again itself.
+func catalogueTables(ctx context.Context, q querier) ([]Table, error) {
+	rows, err := q.Query(ctx, `
+SELECT c.relname,
+       array(SELECT a.attname::text FROM pg_attribute a
+             WHERE a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped AND a.attgenerated = ''
+             ORDER BY a.attnum)
+FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = current_schema()
+  AND c.relkind IN ('r', 'p') AND NOT c.relispartition
+  AND c.relname NOT IN ('schema_migrations', $1)
+  AND NOT EXISTS (SELECT 1 FROM pg_depend d
+                  WHERE d.classid = 'pg_class'::regclass AND d.objid = c.oid AND d.deptype = 'e')`, outboxTable)
+	if err != nil {
+		return nil, err
+	}
+	tables, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Table, error) {
+		var t Table
+		return t, row.Scan(&t.Name, &t.Columns)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = q.Query(ctx, `
+SELECT child.relname, parent.relname
+FROM pg_constraint k
+JOIN pg_class child ON child.oid = k.conrelid
+JOIN pg_class parent ON parent.oid = k.confrelid
+JOIN pg_namespace n ON n.oid = child.relnamespace
+WHERE k.contype = 'f' AND n.nspname = current_schema() AND k.conrelid <> k.confrelid`)
+	if err != nil {
+		return nil, err
+	}
+	refers := map[string][]string{}
+	var child, parent string
+	if _, err := pgx.ForEachRow(rows, []any{&child, &parent}, func() error {
+		refers[child] = append(refers[child], parent)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return restoreOrder(tables, refers), nil
+}
+
+// restoreOrder sorts tables so each comes after every table it refers to,
+// and otherwise by name, so two backups of one schema list it alike.
+func restoreOrder(tables []Table, refers map[string][]string) []Table {
+	slices.SortFunc(tables, func(a, b Table) int { return strings.Compare(a.Name, b.Name) })
+
+	var (
+		ordered []Table
+		placed  = map[string]bool{}
+	)
+	for len(ordered) < len(tables) {
+		progressed := false
+		for _, t := range tables {
+			if placed[t.Name] {
+				continue
+			}
+			ready := true
+			for _, parent := range refers[t.Name] {
+				if !placed[parent] && parent != t.Name {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				ordered = append(ordered, t)
+				placed[t.Name] = true
+				progressed = true
+			}
+		}
+		if !progressed {
+			// A cycle of references, which the schema does not have. Put the
+			// rest in by name and let the constraints say what is wrong.
+			for _, t := range tables {
+				if !placed[t.Name] {
+					ordered = append(ordered, t)
+					placed[t.Name] = true
+				}
+			}
+		}
+	}
+	return ordered
+}
+
+// columnList quotes and joins column names.
+func columnList(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = pgx.Identifier{c}.Sanitize()
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// InSchema returns a store on the same database working in schema, which it
+// creates if need be. The catalogue's tables are then read from and written
+// to there, out of the way of the ones in use.
+//
+// public stays on the search path after it, because PostGIS and pg_trgm
+// install their functions there.
+func (s *Store) InSchema(ctx context.Context, schema string) (*Store, error) {
+	if _, err := s.pool.Exec(ctx, "CREATE SCHEMA IF NOT EXISTS "+pgx.Identifier{schema}.Sanitize()); err != nil {
+		return nil, fmt.Errorf("create schema %s: %w", schema, err)
+	}
+
+	config := s.pool.Config()
+	config.ConnConfig.RuntimeParams["search_path"] = pgx.Identifier{schema}.Sanitize() + ", public"
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("connect to schema %s: %w", schema, err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("connect to schema %s: %w", schema, err)
+	}
+	return &Store{pool: pool}, nil
+}
+
+// DropSchema removes a schema and everything in it.
+func (s *Store) DropSchema(ctx context.Context, schema string) error {
+	if _, err := s.pool.Exec(ctx, "DROP SCHEMA IF EXISTS "+pgx.Identifier{schema}.Sanitize()+" CASCADE"); err != nil {
+		return fmt.Errorf("drop schema %s: %w", schema, err)
+	}
+	return nil
+}
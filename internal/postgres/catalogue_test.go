@@ -0,0 +1,26 @@
+package postgres_test
+
+import (
+	"context"
+	"testing"
+
+	"museum/internal/api"
+	"museum/internal/catalogtest"
+	"museum/internal/models"
+	"museum/internal/postgres"
+	"museum/pkg/exhibitions"
+)
+
+func TestCatalogue(t *testing.T) {
+	catalogtest.Run(t, func(t *testing.T, museums []models.Museum, shows []exhibitions.Exhibition) api.Catalogue {
+		store := postgres.TestStore(t)
+		ctx := context.Background()
+		if _, err := store.SaveMuseums(ctx, museums); err != nil {
+			t.Fatalf("save museums: %v", err)
+		}
+		if _, err := store.SaveExhibitions(ctx, shows); err != nil {
+			t.Fatalf("save exhibitions: %v", err)
+		}
+		return store
+	})
+}
@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DeadLetter is an enrichment that gave up on a stored object in one stage.
+type DeadLetter struct {
+	ID     int64
+	Bucket string
+	Key    string
+	Stage  string
+	Error  string
+	// Attempts counts every attempt the stage has made at the object, across
+	// deliveries and replays.
+	Attempts int
+	FailedAt time.Time
+}
+
+// SaveDeadLetter records that a stage gave up on an object. One already
+// recorded for the object and stage takes the new error and time, and adds
+// the attempts.
+func (s *Store) SaveDeadLetter(ctx context.Context, d DeadLetter) error {
+	if _, err := s.pool.Exec(ctx, `
+INSERT INTO enrich_dead_letters (bucket, key, stage, error, attempts)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (bucket, key, stage) DO UPDATE
+   SET error     = EXCLUDED.error,
+       attempts  = enrich_dead_letters.attempts + EXCLUDED.attempts,
+       failed_at = now()`,
+		d.Bucket, d.Key, d.Stage, d.Error, d.Attempts); err != nil {
+		return fmt.Errorf("save dead letter for %s/%s: %w", d.Bucket, d.Key, err)
+	}
+	return nil
+}
+
+// DeadLetters returns the dead letters of stage, or of every stage if it is
+// empty, that last failed at or after since, oldest first.
+func (s *Store) DeadLetters(ctx context.Context, stage string, since time.Time) ([]DeadLetter, error) {
+	rows, err := s.pool.Query(ctx, `
+SELECT id, bucket, key, stage, error, attempts, failed_at
+  FROM enrich_dead_letters
+ WHERE ($1 = '' OR stage = $1) AND failed_at >= $2
+ ORDER BY failed_at, id`, stage, since)
+	if err != nil {
+		return nil, fmt.Errorf("read dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	var letters []DeadLetter
+	for rows.Next() {
+		var d DeadLetter
+		if err := rows.Scan(&d.ID, &d.Bucket, &d.Key, &d.Stage, &d.Error, &d.Attempts, &d.FailedAt); err != nil {
+			return nil, fmt.Errorf("scan dead letter: %w", err)
+		}
+		letters = append(letters, d)
+	}
+	return letters, rows.Err()
+}
+
+// DeleteDeadLetters removes the dead letters with the given ids, once what
+// they record has been got through.
+func (s *Store) DeleteDeadLetters(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if _, err := s.pool.Exec(ctx, `DELETE FROM enrich_dead_letters WHERE id = ANY($1)`, ids); err != nil {
+		return fmt.Errorf("delete dead letters: %w", err)
+	}
+	return nil
+}
+
+// DeadLetterCounts returns how many dead letters each stage holds.
+func (s *Store) DeadLetterCounts(ctx context.Context) (map[string]int64, error) {
+	rows, err := s.pool.Query(ctx, `SELECT stage, count(*) FROM enrich_dead_letters GROUP BY stage`)
+	if err != nil {
+		return nil, fmt.Errorf("count dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	counts := map[string]int64{}
+	for rows.Next() {
+		var (
+			stage string
+			n     int64
+		)
+		if err := rows.Scan(&stage, &n); err != nil {
+			return nil, fmt.Errorf("scan dead letter count: %w", err)
+		}
+		counts[stage] = n
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDeadLetters(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+
+	for _, d := range []DeadLetter{
+		{Bucket: "museum", Key: "raw_data/france/louvre.json", Stage: "location", Error: "nominatim returned 503", Attempts: 3},
+		{Bucket: "museum", Key: "raw_data/italy/uffizi.json", Stage: "store", Error: "connection refused", Attempts: 3},
+		// Failing again in the same stage updates the letter.
+		{Bucket: "museum", Key: "raw_data/france/louvre.json", Stage: "location", Error: "nominatim returned 400", Attempts: 1},
+	} {
+		if err := store.SaveDeadLetter(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	counts, err := store.DeadLetterCounts(ctx)
+	if err != nil || counts["location"] != 1 || counts["store"] != 1 {
+		t.Fatalf("counts = %v, %v", counts, err)
+	}
+
+	located, err := store.DeadLetters(ctx, "location", time.Time{})
+	if err != nil || len(located) != 1 {
+		t.Fatalf("location letters = %+v, %v", located, err)
+	}
+	if got := located[0]; got.Error != "nominatim returned 400" || got.Attempts != 4 {
+		t.Errorf("letter = %+v, want the last error and every attempt", got)
+	}
+	if later, _ := store.DeadLetters(ctx, "", time.Now().Add(time.Hour)); len(later) != 0 {
+		t.Errorf("letters since an hour from now = %+v", later)
+	}
+
+	if err := store.DeleteDeadLetters(ctx, []int64{located[0].ID}); err != nil {
+		t.Fatal(err)
+	}
+	if rest, _ := store.DeadLetters(ctx, "", time.Time{}); len(rest) != 1 || rest[0].Stage != "store" {
+		t.Errorf("after the delete = %+v", rest)
+	}
+}
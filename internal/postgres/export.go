@@ -0,0 +1,182 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"museum/internal/models"
+	"museum/internal/search"
+	"museum/pkg/exhibitions"
+)
+
+// This file reads the catalogue out whole, for copies of it served without
+// the database: the SQLite file "museum export" builds.
+
+// CatalogueRow is one museum with everything a copy of the catalogue needs to
+// answer the API's queries: the record, and the derived columns the upsert
+// stores beside it, so a copy searches what the database searches.
+type CatalogueRow struct {
+	ID                  int64
+	Museum              models.Museum
+	ApproximateLocation bool
+	RetiredAt           *time.Time
+	UpdatedAt           time.Time
+
+	Normalized         string
+	SearchText         string
+	LocalityNormalized string
+	AliasesNormalized  []string
+	// Site is the host the museum publishes on, as in the site column.
+	Site string
+}
+
+// CatalogueRowOf derives a row from a record the way the upsert would store it,
+// for a copy built from records rather than read from the database.
+func CatalogueRowOf(id int64, m models.Museum) CatalogueRow {
+	return CatalogueRow{
+		ID:                 id,
+		Museum:             m,
+		UpdatedAt:          time.Now(),
+		Normalized:         search.Normalize(m.Name),
+		SearchText:         searchText(m),
+		LocalityNormalized: search.Normalize(m.Locality),
+		AliasesNormalized:  normalizedAliases(m.AlsoKnownAs),
+		Site:               exhibitions.SiteKey(m.Website),
+	}
+}
+
+// EachCatalogueRow streams every museum, retired ones included, in id order.
+func (s *Store) EachCatalogueRow(ctx context.Context, fn func(CatalogueRow) error) error {
+	const stmt = `
+SELECT id, name, coalesce(country,''), coalesce(locality,''), coalesce(description,''),
+       coalesce(website,''), coalesce(wikipedia_url,''), coalesce(wikidata_id,''),
+       aliases, sources, classes, verified, street, postcode, sitelinks,
+       location_approximate, ST_Y(location::geometry), ST_X(location::geometry),
+       retired_at, updated_at,
+       normalized, search_text, locality_normalized, aliases_normalized, coalesce(site, '')
+FROM museums
+ORDER BY id`
+
+	rows, err := s.pool.Query(ctx, stmt)
+	if err != nil {
+		return fmt.Errorf("export museums: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			row              CatalogueRow
+			m                = &row.Museum
+			lat, lon         *float64
+			street, postcode string
+		)
+		if err := rows.Scan(
+			&row.ID, &m.Name, &m.Country, &m.Locality, &m.Description,
+			&m.Website, &m.WikipediaURL, &m.WikidataID,
+			&m.AlsoKnownAs, &m.Sources, &m.Classes, &m.Verified, &street, &postcode, &m.Sitelinks,
+			&row.ApproximateLocation, &lat, &lon, &row.RetiredAt, &row.UpdatedAt,
+			&row.Normalized, &row.SearchText, &row.LocalityNormalized, &row.AliasesNormalized, &row.Site,
+		); err != nil {
+			return fmt.Errorf("export museums: %w", err)
+		}
+		m.Address.Road, m.Address.Postcode = street, postcode
+		if lat != nil && lon != nil {
+			m.Latitude, m.Longitude = *lat, *lon
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// EachLiveExhibition streams the exhibitions a query could still return: not
+// retired, and not yet over.
+func (s *Store) EachLiveExhibition(ctx context.Context, fn func(exhibitions.Exhibition) error) error {
+	const stmt = `
+SELECT url, title, coalesce(museum,''), coalesce(museum_wikidata_id,''),
+       starts_on, ends_on, coalesce(source_page,''), scraped_at, permanent,
+       ST_Y(location::geometry), ST_X(location::geometry)
+FROM exhibitions
+WHERE retired_at IS NULL
+  AND (ends_on IS NULL OR ends_on >= current_date)
+ORDER BY url`
+
+	rows, err := s.pool.Query(ctx, stmt)
+	if err != nil {
+		return fmt.Errorf("export exhibitions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			e        exhibitions.Exhibition
+			lat, lon *float64
+		)
+		if err := rows.Scan(&e.URL, &e.Title, &e.Museum, &e.MuseumWikidataID,
+			&e.Start, &e.End, &e.SourcePage, &e.ScrapedAt, &e.Permanent, &lat, &lon); err != nil {
+			return fmt.Errorf("export exhibitions: %w", err)
+		}
+		if lat != nil && lon != nil {
+			e.Latitude, e.Longitude = *lat, *lon
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// EachSiteAttempt streams when each museum site was last read, which is what
+// the coverage report tells a caller.
+func (s *Store) EachSiteAttempt(ctx context.Context, fn func(site string, at time.Time) error) error {
+	rows, err := s.pool.Query(ctx,
+		`SELECT site, last_attempt_at FROM site_scrapes WHERE last_attempt_at IS NOT NULL ORDER BY site`)
+	if err != nil {
+		return fmt.Errorf("export site attempts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			site string
+			at   time.Time
+		)
+		if err := rows.Scan(&site, &at); err != nil {
+			return fmt.Errorf("export site attempts: %w", err)
+		}
+		if err := fn(site, at); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// EachPlace streams the resolved place names still within their TTL, so a copy
+// can answer "Paris" without a geocoder.
+func (s *Store) EachPlace(ctx context.Context, fn func(Place) error) error {
+	const stmt = `
+SELECT query, display_name, coalesce(ST_Y(location::geometry), 0), coalesce(ST_X(location::geometry), 0),
+       radius_km, found
+FROM places
+WHERE resolved_at > now() - $1::interval
+ORDER BY query`
+
+	rows, err := s.pool.Query(ctx, stmt, placeTTL.String())
+	if err != nil {
+		return fmt.Errorf("export places: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p Place
+		if err := rows.Scan(&p.Query, &p.DisplayName, &p.Latitude, &p.Longitude, &p.RadiusKm, &p.Found); err != nil {
+			return fmt.Errorf("export places: %w", err)
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
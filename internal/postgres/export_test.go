@@ -0,0 +1,6 @@
+package postgres
+
+// TestStore exposes testStore to the external tests, which run the
+// catalogtest suite and cannot import it from inside the package: catalogtest
+// imports the API, which imports this package.
+var TestStore = testStore
@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Generation identifies one state of the catalogue. Two reads that see the
+// same number saw the same data.
+type Generation struct {
+	Number    int64
+	ChangedAt time.Time
+}
+
+// generationTTL is how long a generation read is reused.
+//
+// Short, because it is the window in which a response can be served as current
+// after a write on another process has made it stale. Long enough that a burst
+// of requests costs one read between them rather than one each, which is the
+// whole point of having a counter rather than asking the tables.
+const generationTTL = 2 * time.Second
+
+// cachedGeneration is a generation read from one pool, and when.
+type cachedGeneration struct {
+	generation Generation
+	at         time.Time
+}
+
+// Generation returns the catalogue's current generation, cached briefly.
+//
+// Read from wherever the catalogue's reads go. With a replica, the primary's
+// number can be ahead of the rows being served, and a response built from the
+// replica would be cached under a generation it does not hold. For the same
+// reason each pool's number is cached apart: a request pinned to a lagging
+// replica must not be handed the number last read from the primary.
+func (s *Store) Generation(ctx context.Context) (Generation, error) {
+	pool := s.reader(ctx)
+	s.generationMu.RLock()
+	cached := s.generations[pool]
+	s.generationMu.RUnlock()
+
+	if time.Since(cached.at) < generationTTL {
+		return cached.generation, nil
+	}
+
+	var current Generation
+	if err := pool.QueryRow(ctx,
+		`SELECT generation, changed_at FROM catalogue_generation`,
+	).Scan(&current.Number, &current.ChangedAt); err != nil {
+		return Generation{}, fmt.Errorf("catalogue generation: %w", err)
+	}
+
+	s.generationMu.Lock()
+	if s.generations == nil {
+		s.generations = map[*pgxpool.Pool]cachedGeneration{}
+	}
+	s.generations[pool] = cachedGeneration{current, time.Now()}
+	s.generationMu.Unlock()
+
+	return current, nil
+}
+
+// bumpGeneration records that the catalogue changed.
+//
+// Called after the write rather than inside it, so the writes keep their
+// batches and their row-by-row fallback as they are. The order is what keeps
+// it safe: a reader that sees the new number is guaranteed to see the new
+// rows, and the worst a reader between the two can do is cache the new rows
+// under the old number — which the bump then invalidates.
+//
+// A failed bump is logged rather than returned. The write it follows has
+// already succeeded, and reporting it as failed would invite a retry of work
+// that is done; the cost of the miss is responses that stay cached until the
+// next write bumps the counter.
+func (s *Store) bumpGeneration(ctx context.Context) {
+	if _, err := s.pool.Exec(ctx,
+		`UPDATE catalogue_generation SET generation = generation + 1, changed_at = now()`,
+	); err != nil {
+		log.Printf("postgres: cannot advance the catalogue generation: %v", err)
+		return
+	}
+
+	// This process at least need not wait out the TTL to see its own write,
+	// on the primary or, once it has replayed it, on the replica.
+	s.generationMu.Lock()
+	clear(s.generations)
+	s.generationMu.Unlock()
+}
@@ -0,0 +1,178 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// geocodeHitBatch and geocodeHitInterval bound how many hits a process holds
+// before writing them, and for how long: counting each hit with an UPDATE
+// turned every cached answer into a write.
+const (
+	geocodeHitBatch    = 100
+	geocodeHitInterval = time.Minute
+)
+
+// geocodeEntry names one cache entry.
+type geocodeEntry struct{ provider, kind, key string }
+
+// geocodeHits are the hits counted since they were last written.
+type geocodeHits struct {
+	mu      sync.Mutex
+	pending map[geocodeEntry]int64
+	total   int
+	since   time.Time
+}
+
+// CachedGeocode returns a geocoder's remembered answer, nil for a remembered
+// "nothing found". The second result is false when the question has not been
+// asked, or its answer has expired. A hit is counted in memory and written
+// with others later; see flushGeocodeHits.
+func (s *Store) CachedGeocode(ctx context.Context, provider, kind, key string) ([]byte, bool, error) {
+	var response []byte
+	err := s.pool.QueryRow(ctx, `
+SELECT response FROM geocode_cache
+ WHERE provider = $1 AND kind = $2 AND key = $3 AND expires_at > now()`,
+		provider, kind, key).Scan(&response)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, false, nil
+	case err != nil:
+		return nil, false, fmt.Errorf("read geocode cache for %s %s %q: %w", provider, kind, key, err)
+	}
+	s.countGeocodeHit(ctx, geocodeEntry{provider, kind, key})
+	return response, true, nil
+}
+
+// countGeocodeHit adds a hit, writing the batch when it is full or old.
+func (s *Store) countGeocodeHit(ctx context.Context, entry geocodeEntry) {
+	h := &s.geocodeHits
+	h.mu.Lock()
+	if h.pending == nil {
+		h.pending = map[geocodeEntry]int64{}
+		h.since = time.Now()
+	}
+	h.pending[entry]++
+	h.total++
+	due := h.total >= geocodeHitBatch || time.Since(h.since) >= geocodeHitInterval
+	h.mu.Unlock()
+	if due {
+		if err := s.flushGeocodeHits(ctx); err != nil {
+			log.Printf("postgres: %v", err)
+		}
+	}
+}
+
+// flushGeocodeHits writes the hits counted so far. They are a statistic: a
+// batch that cannot be written is dropped rather than held.
+func (s *Store) flushGeocodeHits(ctx context.Context) error {
+	h := &s.geocodeHits
+	h.mu.Lock()
+	pending := h.pending
+	h.pending, h.total = nil, 0
+	h.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	var providers, kinds, keys []string
+	var counts []int64
+	for entry, n := range pending {
+		providers = append(providers, entry.provider)
+		kinds = append(kinds, entry.kind)
+		keys = append(keys, validUTF8(entry.key))
+		counts = append(counts, n)
+	}
+	if _, err := s.pool.Exec(ctx, `
+UPDATE geocode_cache c SET hits = c.hits + h.n
+  FROM unnest($1::text[], $2::text[], $3::text[], $4::bigint[]) AS h(provider, kind, key, n)
+ WHERE c.provider = h.provider AND c.kind = h.kind AND c.key = h.key`,
+		providers, kinds, keys, counts); err != nil {
+		return fmt.Errorf("count %d geocode cache hits: %w", len(pending), err)
+	}
+	return nil
+}
+
+// CacheGeocode remembers a geocoder's answer for ttl, replacing any earlier
+// one. A nil response remembers that nothing was found.
+func (s *Store) CacheGeocode(ctx context.Context, provider, kind, key string, response []byte, ttl time.Duration) error {
+	if _, err := s.pool.Exec(ctx, `
+INSERT INTO geocode_cache (provider, kind, key, response, expires_at)
+VALUES ($1, $2, $3, $4, now() + make_interval(secs => $5))
+ON CONFLICT (provider, kind, key) DO UPDATE
+   SET response   = EXCLUDED.response,
+       cached_at  = now(),
+       expires_at = EXCLUDED.expires_at,
+       hits       = 0`,
+		provider, kind, validUTF8(key), response, ttl.Seconds()); err != nil {
+		return fmt.Errorf("cache %s %s %q: %w", provider, kind, key, err)
+	}
+	return nil
+}
+
+// GeocodeCacheStats describes what the cache holds for one provider and kind
+// of question.
+type GeocodeCacheStats struct {
+	Provider string
+	Kind     string
+	Entries  int64
+	// Misses are the entries remembering that nothing was found.
+	Misses int64
+	// Expired entries no longer answer, and are kept until purged.
+	Expired int64
+	Hits    int64
+	Oldest  time.Time
+}
+
+// GeocodeCacheStats returns what the cache holds, by provider and kind,
+// counting the hits this process has yet to write.
+func (s *Store) GeocodeCacheStats(ctx context.Context) ([]GeocodeCacheStats, error) {
+	if err := s.flushGeocodeHits(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := s.pool.Query(ctx, `
+SELECT provider, kind, count(*),
+       count(*) FILTER (WHERE response IS NULL),
+       count(*) FILTER (WHERE expires_at <= now()),
+       coalesce(sum(hits), 0)::bigint, min(cached_at)
+  FROM geocode_cache
+ GROUP BY provider, kind
+ ORDER BY provider, kind`)
+	if err != nil {
+		return nil, fmt.Errorf("geocode cache stats: %w", err)
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (GeocodeCacheStats, error) {
+		var st GeocodeCacheStats
+		return st, row.Scan(&st.Provider, &st.Kind, &st.Entries, &st.Misses, &st.Expired, &st.Hits, &st.Oldest)
+	})
+}
+
+// GeocodeCachePurge selects the entries PurgeGeocodeCache removes. The zero
+// value removes only the expired ones.
+type GeocodeCachePurge struct {
+	// Provider limits the purge to one provider's answers.
+	Provider string
+	// Misses removes the remembered misses too, so they are asked again.
+	Misses bool
+	// All removes every selected entry, expired or not.
+	All bool
+}
+
+// PurgeGeocodeCache removes cache entries, returning how many.
+func (s *Store) PurgeGeocodeCache(ctx context.Context, p GeocodeCachePurge) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `
+DELETE FROM geocode_cache
+ WHERE ($1 = '' OR provider = $1)
+   AND ($3 OR expires_at <= now() OR ($2 AND response IS NULL))`,
+		p.Provider, p.Misses, p.All)
+	if err != nil {
+		return 0, fmt.Errorf("purge geocode cache: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
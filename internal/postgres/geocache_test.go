@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGeocodeCache(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+
+	if _, ok, err := store.CachedGeocode(ctx, "nominatim", "search", "gothenburg, sweden"); ok || err != nil {
+		t.Fatalf("empty cache answered: %v, %v", ok, err)
+	}
+
+	for _, entry := range []struct {
+		provider, kind, key string
+		response            []byte
+		ttl                 time.Duration
+	}{
+		{"nominatim", "search", "gothenburg, sweden", []byte(`{"name":"Gothenburg","lat":"57.70716"}`), time.Hour},
+		{"nominatim", "search", "atlantis", nil, time.Hour},
+		{"photon", "reverse", "57.69840,11.97410", []byte(`{"name":"Röhsska museet"}`), time.Hour},
+		// Expired as soon as it is written.
+		{"nominatim", "details", "W28563412", []byte(`{"osm_id":28563412}`), time.Microsecond},
+	} {
+		if err := store.CacheGeocode(ctx, entry.provider, entry.kind, entry.key, entry.response, entry.ttl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(time.Millisecond)
+
+	response, ok, err := store.CachedGeocode(ctx, "nominatim", "search", "gothenburg, sweden")
+	if err != nil || !ok || len(response) == 0 {
+		t.Fatalf("cached answer = %s, %v, %v", response, ok, err)
+	}
+	if response, ok, _ := store.CachedGeocode(ctx, "nominatim", "search", "atlantis"); !ok || response != nil {
+		t.Errorf("cached miss = %s, %v; want a nil answer that is held", response, ok)
+	}
+	if _, ok, _ := store.CachedGeocode(ctx, "nominatim", "details", "W28563412"); ok {
+		t.Error("an expired entry answered")
+	}
+	// Answers are per provider.
+	if _, ok, _ := store.CachedGeocode(ctx, "photon", "search", "gothenburg, sweden"); ok {
+		t.Error("Photon answered from Nominatim's entry")
+	}
+
+	// Reads do not write: the hits wait in memory until a batch is due.
+	var written int64
+	var ttl float64
+	if err := store.pool.QueryRow(ctx, `
+SELECT hits, extract(epoch FROM expires_at - cached_at)::float8 FROM geocode_cache
+ WHERE provider = 'nominatim' AND kind = 'search' AND key = 'gothenburg, sweden'`).Scan(&written, &ttl); err != nil {
+		t.Fatal(err)
+	}
+	if written != 0 {
+		t.Errorf("hits written on read = %d, want them held", written)
+	}
+	if ttl < 3599 || ttl > 3601 {
+		t.Errorf("entry kept for %.0fs, want an hour", ttl)
+	}
+
+	stats, err := store.GeocodeCacheStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byKind := map[string]GeocodeCacheStats{}
+	for _, st := range stats {
+		byKind[st.Provider+" "+st.Kind] = st
+	}
+	if st := byKind["nominatim search"]; st.Entries != 2 || st.Misses != 1 || st.Hits != 2 {
+		t.Errorf("nominatim search = %+v, want 2 entries, 1 miss, 2 hits", st)
+	}
+	if st := byKind["nominatim details"]; st.Expired != 1 {
+		t.Errorf("nominatim details = %+v, want its entry expired", st)
+	}
+
+	purged, err := store.PurgeGeocodeCache(ctx, GeocodeCachePurge{})
+	if err != nil || purged != 1 {
+		t.Errorf("purge expired = %d, %v; want 1", purged, err)
+	}
+	purged, err = store.PurgeGeocodeCache(ctx, GeocodeCachePurge{Provider: "nominatim", Misses: true})
+	if err != nil || purged != 1 {
+		t.Errorf("purge nominatim misses = %d, %v; want 1", purged, err)
+	}
+	purged, err = store.PurgeGeocodeCache(ctx, GeocodeCachePurge{All: true})
+	if err != nil || purged != 2 {
+		t.Errorf("purge all = %d, %v; want the 2 left", purged, err)
+	}
+}
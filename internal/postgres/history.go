@@ -0,0 +1,109 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Revision is one field of one museum changed by one write.
+type Revision struct {
+	ID       int64
+	MuseumID int64
+	Field    string
+	// Old and New are the field's values as JSON, nil where there was none:
+	// Old on an insert, New on a delete or a cleared field.
+	Old json.RawMessage
+	New json.RawMessage
+	// Source is the run that made the change, e.g. "crawl
+	// 2026-03-01T02:00:00Z", "locate 2026-03-02T09:14:00Z" or "manual".
+	Source string
+	// Operation is insert, update or delete. A delete is almost always a merge
+	// folding the row into another.
+	Operation string
+	ChangedAt time.Time
+}
+
+// History is a page of a museum's revisions, newest first.
+type History struct {
+	MuseumID  int64
+	Revisions []Revision
+	// Total is how many revisions the museum has altogether.
+	Total int64
+}
+
+// MuseumHistory returns what has changed on a museum, newest first.
+//
+// The id is the numeric id or a Wikidata id, as for MuseumByID, with one
+// difference: a numeric id is answered even when the museum no longer exists.
+// A merged-away row's history is the record of what was merged, and is most
+// wanted precisely when the row is gone.
+func (s *Store) MuseumHistory(ctx context.Context, id string, limit, offset int) (History, error) {
+	museumID, err := s.revisionSubject(ctx, id)
+	if err != nil {
+		return History{}, err
+	}
+
+	const stmt = `
+SELECT id, field, old_value, new_value, source, operation, changed_at, count(*) OVER ()
+FROM museum_revisions
+WHERE museum_id = $1
+ORDER BY id DESC
+LIMIT $2 OFFSET $3`
+
+	rows, err := s.reader(ctx).Query(ctx, stmt, museumID, limit, offset)
+	if err != nil {
+		return History{}, fmt.Errorf("museum history: %w", err)
+	}
+	defer rows.Close()
+
+	history := History{MuseumID: museumID}
+	for rows.Next() {
+		r := Revision{MuseumID: museumID}
+		var before, after []byte
+		if err := rows.Scan(&r.ID, &r.Field, &before, &after, &r.Source, &r.Operation, &r.ChangedAt, &history.Total); err != nil {
+			return History{}, fmt.Errorf("museum history: %w", err)
+		}
+		r.Old, r.New = before, after
+		history.Revisions = append(history.Revisions, r)
+	}
+	if err := rows.Err(); err != nil {
+		return History{}, fmt.Errorf("museum history: %w", err)
+	}
+
+	// An empty page past the end still belongs to a museum with a history;
+	// only one with none at all needs telling apart from an unknown id.
+	if history.Total == 0 && offset == 0 {
+		var exists bool
+		if err := s.reader(ctx).QueryRow(ctx,
+			`SELECT EXISTS (SELECT 1 FROM museums WHERE id = $1)`, museumID).Scan(&exists); err != nil {
+			return History{}, fmt.Errorf("museum history: %w", err)
+		}
+		if !exists {
+			return History{}, fmt.Errorf("museum %q: %w", id, ErrNotFound)
+		}
+	}
+	return history, nil
+}
+
+// revisionSubject resolves an id to the museum_id revisions are filed under.
+func (s *Store) revisionSubject(ctx context.Context, id string) (int64, error) {
+	if numeric, err := strconv.ParseInt(id, 10, 64); err == nil {
+		return numeric, nil
+	}
+
+	var museumID int64
+	err := s.pool.QueryRow(ctx, `SELECT id FROM museums WHERE wikidata_id = $1`, id).Scan(&museumID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("museum %q: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("museum history: %w", err)
+	}
+	return museumID, nil
+}
@@ -0,0 +1,407 @@
+package postgres
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// The schema is a sequence of numbered migrations, each applied once and
+// recorded in schema_migrations.
+//
+// It used to be one idempotent file applied in full on every start. That held
+// while the schema only grew, and stopped holding in two ways: processes
+// starting together deadlocked on its ALTER TABLEs, and data repairs — taking
+// dates off exhibition titles — ran again on every boot of every process, for
+// ever, to rewrite nothing. Worse, nothing recorded which schema a database
+// had, so an old binary happily served from a table whose meaning had changed
+// under it.
+//
+// A migration is NNNN_name.up.sql, with an optional NNNN_name.down.sql that
+// undoes it. Numbers are contiguous from 1. A migration without a down file
+// cannot be reverted: a data repair has nothing to restore, and reverting the
+// baseline would drop the catalogue.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration is one step of the schema.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	// Down is empty for a migration that cannot be reverted.
+	Down string
+}
+
+// Checksum identifies the migration's content, so an edit to one that has
+// already been applied somewhere can be noticed rather than silently ignored.
+func (m Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Reversible reports whether the migration can be undone.
+func (m Migration) Reversible() bool { return m.Down != "" }
+
+var migrations = mustLoadMigrations(migrationFiles)
+
+// Migrations returns every migration this binary knows, in order.
+func Migrations() []Migration { return append([]Migration(nil), migrations...) }
+
+// SchemaVersion is the version this binary was built for: the last migration
+// it carries.
+func SchemaVersion() int { return len(migrations) }
+
+// mustLoadMigrations reads the embedded migrations, failing at start on a
+// malformed set: a gap or a duplicate number is a mistake in this repository,
+// not something a database should ever be asked to make sense of.
+func mustLoadMigrations(files fs.FS) []Migration {
+	found, err := loadMigrations(files)
+	if err != nil {
+		panic(err)
+	}
+	return found
+}
+
+func loadMigrations(files fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(files, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, name := range names {
+		base := path.Base(name)
+		stem, direction, ok := strings.Cut(strings.TrimSuffix(base, ".sql"), ".")
+		number, label, hasLabel := strings.Cut(stem, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || !hasLabel || err != nil || version < 1 || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migration %s: name must be NNNN_name.up.sql or NNNN_name.down.sql", base)
+		}
+
+		body, err := fs.ReadFile(files, name)
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: label}
+			byVersion[version] = m
+		}
+		if m.Name != label {
+			return nil, fmt.Errorf("migration %d is named both %q and %q", version, m.Name, label)
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	out := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	for i, m := range out {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migrations must be numbered from 1 without gaps; %d follows %d", m.Version, i)
+		}
+	}
+	return out, nil
+}
+
+var (
+	// ErrSchemaBehind reports a database that has not had this binary's
+	// migrations applied.
+	ErrSchemaBehind = errors.New("database schema is older than this binary")
+	// ErrSchemaAhead reports a database migrated by a newer binary than this.
+	ErrSchemaAhead = errors.New("database schema is newer than this binary")
+)
+
+// schemaLock is an arbitrary constant identifying the migration lock. Every
+// process changing the schema takes the same one.
+const schemaLock = 0x6D757365756D01 // "museum" and a version byte
+
+// The bookkeeping table. Created outside any migration, because it is what
+// migrations are recorded in.
+const createMigrationsTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version    integer PRIMARY KEY,
+    name       text        NOT NULL,
+    checksum   text        NOT NULL,
+    applied_at timestamptz NOT NULL DEFAULT now()
+)`
+
+// AppliedMigration is a migration as a database records it.
+type AppliedMigration struct {
+	Migration
+	// Applied is false for a migration the database has not had.
+	Applied   bool
+	AppliedAt time.Time
+	// Modified is true when the migration has changed since it was applied.
+	Modified bool
+	// Unknown is true for a migration the database has and this binary does
+	// not: it was applied by a newer one.
+	Unknown bool
+}
+
+// SchemaStatus reports every migration this binary knows and every one the
+// database records, in version order, along with the database's version.
+func (s *Store) SchemaStatus(ctx context.Context) ([]AppliedMigration, int, error) {
+	applied, err := appliedMigrations(ctx, s.pool)
+	if err != nil {
+		return nil, 0, fmt.Errorf("schema status: %w", err)
+	}
+
+	var (
+		status  []AppliedMigration
+		current int
+	)
+	for _, m := range migrations {
+		entry := AppliedMigration{Migration: m}
+		if row, ok := applied[m.Version]; ok {
+			entry.Applied, entry.AppliedAt = true, row.AppliedAt
+			entry.Modified = row.checksum != m.Checksum()
+			current = max(current, m.Version)
+		}
+		status = append(status, entry)
+	}
+	for version, row := range applied {
+		if version > SchemaVersion() {
+			status = append(status, AppliedMigration{
+				Migration: Migration{Version: version, Name: row.Name},
+				Applied:   true, AppliedAt: row.AppliedAt, Unknown: true,
+			})
+			current = max(current, version)
+		}
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Version < status[j].Version })
+	return status, current, nil
+}
+
+// CheckSchema reports whether the database is at exactly the version this
+// binary was built for.
+//
+// The long-running services refuse to start otherwise. Serving from an older
+// schema fails on the first query that reads a new column, which is the lucky
+// case; serving from a newer one can succeed while misreading what the columns
+// now mean.
+func (s *Store) CheckSchema(ctx context.Context) error {
+	_, current, err := s.SchemaStatus(ctx)
+	if err != nil {
+		return err
+	}
+	switch {
+	case current < SchemaVersion():
+		return fmt.Errorf("%w: it is at version %d and this binary needs %d; run \"museum migrate up\"",
+			ErrSchemaBehind, current, SchemaVersion())
+	case current > SchemaVersion():
+		return fmt.Errorf("%w: it is at version %d and this binary knows only up to %d; deploy the newer binary",
+			ErrSchemaAhead, current, SchemaVersion())
+	}
+	return nil
+}
+
+// PendingMigrations returns what MigrateUp would apply to reach target, in
+// order. A target of zero means the latest.
+func (s *Store) PendingMigrations(ctx context.Context, target int) ([]Migration, error) {
+	applied, err := appliedMigrations(ctx, s.pool)
+	if err != nil {
+		return nil, fmt.Errorf("plan migrations: %w", err)
+	}
+	return pending(applied, target)
+}
+
+// MigrateUp applies every migration the database has not had, up to target,
+// and returns the ones it applied. A target of zero means the latest.
+//
+// Everything runs in one transaction under an advisory lock, so two processes
+// migrating at once apply each migration once between them, and a migration
+// that fails leaves the database exactly as it found it rather than halfway
+// through the set.
+func (s *Store) MigrateUp(ctx context.Context, target int) ([]Migration, error) {
+	var applied []Migration
+	err := s.withSchemaLock(ctx, func(tx pgx.Tx) error {
+		recorded, err := appliedMigrations(ctx, tx)
+		if err != nil {
+			return err
+		}
+		todo, err := pending(recorded, target)
+		if err != nil {
+			return err
+		}
+		for _, m := range todo {
+			if _, err := tx.Exec(ctx, m.Up); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			if _, err := tx.Exec(ctx,
+				`INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+				m.Version, m.Name, m.Checksum()); err != nil {
+				return fmt.Errorf("record migration %d: %w", m.Version, err)
+			}
+			applied = append(applied, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("migrate up: %w", err)
+	}
+	if len(applied) > 0 {
+		s.bumpGeneration(ctx)
+	}
+	return applied, nil
+}
+
+// MigrateDown reverts the latest steps migrations, newest first, and returns
+// the ones it reverted. It refuses, reverting nothing, when any of them has no
+// down file.
+func (s *Store) MigrateDown(ctx context.Context, steps int) ([]Migration, error) {
+	if steps < 1 {
+		return nil, errors.New("migrate down: steps must be at least 1")
+	}
+
+	var reverted []Migration
+	err := s.withSchemaLock(ctx, func(tx pgx.Tx) error {
+		recorded, err := appliedMigrations(ctx, tx)
+		if err != nil {
+			return err
+		}
+		current := 0
+		for version := range recorded {
+			current = max(current, version)
+		}
+		if current > SchemaVersion() {
+			return fmt.Errorf("%w: version %d was applied by a newer binary, which must revert it",
+				ErrSchemaAhead, current)
+		}
+		if steps > current {
+			return fmt.Errorf("the database is at version %d; it cannot go down %d", current, steps)
+		}
+
+		for version := current; version > current-steps; version-- {
+			m := migrations[version-1]
+			if !m.Reversible() {
+				return fmt.Errorf("migration %d_%s cannot be reverted", m.Version, m.Name)
+			}
+			if _, err := tx.Exec(ctx, m.Down); err != nil {
+				return fmt.Errorf("revert migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			if _, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, m.Version); err != nil {
+				return fmt.Errorf("record revert of %d: %w", m.Version, err)
+			}
+			reverted = append(reverted, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("migrate down: %w", err)
+	}
+	if len(reverted) > 0 {
+		s.bumpGeneration(ctx)
+	}
+	return reverted, nil
+}
+
+// withSchemaLock runs fn in a transaction holding the migration lock, with the
+// bookkeeping table in place.
+func (s *Store) withSchemaLock(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Held until the transaction ends, which is what serialises the migrators.
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", int64(schemaLock)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, createMigrationsTable); err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// appliedRow is one row of schema_migrations.
+type appliedRow struct {
+	Name      string
+	checksum  string
+	AppliedAt time.Time
+}
+
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// appliedMigrations reads schema_migrations. A database that has never been
+// migrated has no such table, and is at version zero.
+func appliedMigrations(ctx context.Context, q querier) (map[int]appliedRow, error) {
+	var exists bool
+	if err := q.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, err
+	}
+	applied := map[int]appliedRow{}
+	if !exists {
+		return applied, nil
+	}
+
+	rows, err := q.Query(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			version int
+			row     appliedRow
+		)
+		if err := rows.Scan(&version, &row.Name, &row.checksum, &row.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = row
+	}
+	return applied, rows.Err()
+}
+
+// pending lists the migrations between what a database has and target.
+func pending(applied map[int]appliedRow, target int) ([]Migration, error) {
+	if target == 0 {
+		target = SchemaVersion()
+	}
+	if target < 0 || target > SchemaVersion() {
+		return nil, fmt.Errorf("no migration %d; this binary knows 1 to %d", target, SchemaVersion())
+	}
+	for version := range applied {
+		if version > SchemaVersion() {
+			return nil, fmt.Errorf("%w: version %d is applied", ErrSchemaAhead, version)
+		}
+	}
+
+	var todo []Migration
+	for _, m := range migrations[:target] {
+		if _, ok := applied[m.Version]; !ok {
+			todo = append(todo, m)
+		}
+	}
+	return todo, nil
+}
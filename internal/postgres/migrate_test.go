@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	found, err := loadMigrations(fstest.MapFS{
+		"migrations/0002_second.up.sql":   {Data: []byte("SELECT 2")},
+		"migrations/0001_first.up.sql":    {Data: []byte("SELECT 1")},
+		"migrations/0002_second.down.sql": {Data: []byte("SELECT -2")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 || found[0].Name != "first" || found[1].Name != "second" {
+		t.Fatalf("migrations = %+v, want first then second", found)
+	}
+	if found[0].Reversible() || !found[1].Reversible() {
+		t.Error("only the migration with a down file is reversible")
+	}
+
+	broken := map[string]fstest.MapFS{
+		"a gap":            {"migrations/0001_a.up.sql": {Data: []byte("x")}, "migrations/0003_c.up.sql": {Data: []byte("x")}},
+		"a down only":      {"migrations/0001_a.down.sql": {Data: []byte("x")}},
+		"an unnumbered":    {"migrations/first.up.sql": {}},
+		"two names":        {"migrations/0001_a.up.sql": {Data: []byte("x")}, "migrations/0001_b.down.sql": {}},
+		"a wrong suffix":   {"migrations/0001_a.sideways.sql": {}},
+		"numbering from 0": {"migrations/0000_a.up.sql": {Data: []byte("x")}},
+	}
+	for name, files := range broken {
+		if _, err := loadMigrations(files); err == nil {
+			t.Errorf("%s: loaded without error", name)
+		}
+	}
+}
+
+// The embedded set is checked at start, but a test says so before anything
+// ships.
+func TestEmbeddedMigrations(t *testing.T) {
+	if SchemaVersion() < 3 {
+		t.Fatalf("SchemaVersion = %d, want the baseline and what followed it", SchemaVersion())
+	}
+	for _, m := range Migrations() {
+		if strings.TrimSpace(m.Up) == "" {
+			t.Errorf("migration %d_%s is empty", m.Version, m.Name)
+		}
+	}
+	if Migrations()[0].Reversible() {
+		t.Error("the baseline must not be reversible; reverting it would drop the catalogue")
+	}
+}
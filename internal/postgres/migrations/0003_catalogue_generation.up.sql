@@ -0,0 +1,18 @@
+-- A counter that moves whenever the catalogue does.
+--
+-- The API answers the same few questions over and over — museums in London,
+-- exhibitions in Paris — and the answers change only when a crawl, a reindex
+-- or a sweep writes. Every write that can change a response bumps this, so
+-- "has anything changed since generation N" is one primary-key read, and the
+-- number is what the API's ETags are made of. One row, enforced: a second
+-- would make "the" generation ambiguous.
+--
+-- IF NOT EXISTS because the table predates this migration on databases built
+-- while the schema was still applied on every start.
+CREATE TABLE IF NOT EXISTS catalogue_generation (
+    singleton  boolean PRIMARY KEY DEFAULT true CHECK (singleton),
+    generation bigint      NOT NULL DEFAULT 1,
+    changed_at timestamptz NOT NULL DEFAULT now()
+);
+
+INSERT INTO catalogue_generation DEFAULT VALUES ON CONFLICT DO NOTHING;
@@ -0,0 +1,4 @@
+DROP TRIGGER museums_revisions ON museums;
+DROP FUNCTION record_museum_revision();
+DROP FUNCTION museum_revision_fields(museums);
+DROP TABLE museum_revisions;
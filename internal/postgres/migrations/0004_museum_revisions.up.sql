@@ -0,0 +1,99 @@
+-- What each museum looked like before, field by field, and which run changed it.
+--
+-- The upsert overwrites columns in place and the merges delete the losing row,
+-- so when a record went wrong — a museum moved to another continent, a website
+-- replaced by a listing aggregator — there was no way to see what it had been
+-- or which run had done it. Every write path is covered by one trigger rather
+-- than by each query recording its own changes: there are a dozen of them,
+-- several are single statements that update and delete in one CTE, and a
+-- history that some writes forget to keep is worse than none, because it is
+-- believed.
+--
+-- museum_id has no foreign key. A merged-away row's history is exactly what is
+-- wanted afterwards, and it must survive the row.
+CREATE TABLE museum_revisions (
+    id         bigserial PRIMARY KEY,
+    museum_id  bigint      NOT NULL,
+    field      text        NOT NULL,
+    -- JSON so an array stays an array and a position stays a pair. Null on
+    -- the side that had no value: old_value on an insert, new_value on a
+    -- delete or a cleared field.
+    old_value  jsonb,
+    new_value  jsonb,
+    -- Who wrote it: the run's label ("crawl 2026-03-01T02:00:00Z"), taken from
+    -- the session's application_name, or museum.source where a transaction
+    -- sets one for itself.
+    source     text        NOT NULL,
+    operation  text        NOT NULL CHECK (operation IN ('insert', 'update', 'delete')),
+    changed_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX museum_revisions_museum_idx ON museum_revisions (museum_id, id DESC);
+
+-- "What did that run do" is the other question asked of this table.
+CREATE INDEX museum_revisions_source_idx ON museum_revisions (source, changed_at);
+
+-- The fields worth a history, as JSON. Derived columns — normalized,
+-- search_text, identity, site — are left out: they follow from these, and
+-- recording them would triple the table to say nothing new.
+CREATE FUNCTION museum_revision_fields(m museums) RETURNS jsonb
+LANGUAGE sql STABLE AS $$
+    SELECT jsonb_build_object(
+        'name',                 m.name,
+        'wikidata_id',          nullif(m.wikidata_id, ''),
+        'country',              m.country,
+        'locality',             nullif(m.locality, ''),
+        'description',          nullif(m.description, ''),
+        'website',              nullif(m.website, ''),
+        'wikipedia_url',        nullif(m.wikipedia_url, ''),
+        'street',               nullif(m.street, ''),
+        'postcode',             nullif(m.postcode, ''),
+        'aliases',              to_jsonb(m.aliases),
+        'sources',              to_jsonb(m.sources),
+        'classes',              to_jsonb(m.classes),
+        'verified',             m.verified,
+        'location',             CASE WHEN m.location IS NULL THEN NULL ELSE jsonb_build_array(
+                                    round(ST_Y(m.location::geometry)::numeric, 6),
+                                    round(ST_X(m.location::geometry)::numeric, 6)) END,
+        'location_approximate', m.location_approximate
+    )
+$$;
+
+CREATE FUNCTION record_museum_revision() RETURNS trigger
+LANGUAGE plpgsql AS $$
+DECLARE
+    who       text := coalesce(nullif(current_setting('museum.source', true), ''),
+                               nullif(current_setting('application_name', true), ''),
+                               session_user);
+    row_id    bigint;
+    before    jsonb := '{}';
+    after     jsonb := '{}';
+    key       text;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        row_id := OLD.id;
+    ELSE
+        row_id := NEW.id;
+    END IF;
+    IF TG_OP <> 'INSERT' THEN
+        before := museum_revision_fields(OLD);
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        after := museum_revision_fields(NEW);
+    END IF;
+
+    FOR key IN SELECT jsonb_object_keys(before || after) LOOP
+        IF coalesce(before -> key, 'null') IS DISTINCT FROM coalesce(after -> key, 'null') THEN
+            INSERT INTO museum_revisions (museum_id, field, old_value, new_value, source, operation)
+            VALUES (row_id, key,
+                    nullif(before -> key, 'null'), nullif(after -> key, 'null'),
+                    who, lower(TG_OP));
+        END IF;
+    END LOOP;
+    RETURN NULL;
+END
+$$;
+
+CREATE TRIGGER museums_revisions
+    AFTER INSERT OR UPDATE OR DELETE ON museums
+    FOR EACH ROW EXECUTE FUNCTION record_museum_revision();
@@ -0,0 +1,4 @@
+DROP TRIGGER museums_pins ON museums;
+DROP FUNCTION apply_museum_pins();
+DROP FUNCTION museums_kept_apart(bigint, bigint);
+DROP TABLE museum_overrides;
@@ -0,0 +1,86 @@
+-- Corrections made by hand, kept as rules every write path consults.
+--
+-- A fix made directly in museums lasted until the next crawl or reindex: the
+-- upsert wrote the source's version back, and the merges could fold the fixed
+-- row into another. Four kinds of correction cover what has had to be fixed:
+--
+--   pin      one field of one museum held at a value
+--   merge    other_id folded into museum_id, and kept folded in
+--   split    museum_id and other_id never merged, whatever their names share
+--   suppress museum_id removed as not a museum, and kept out
+--
+-- Like the history, museum_id has no foreign key: a merge and a suppression
+-- delete the row they name, and the rule has to outlive it to keep it deleted.
+CREATE TABLE museum_overrides (
+    id          bigserial   PRIMARY KEY,
+    kind        text        NOT NULL CHECK (kind IN ('pin', 'merge', 'split', 'suppress')),
+    museum_id   bigint      NOT NULL,
+    other_id    bigint,
+    -- A pin's field, and its value as JSON in the form museum_revisions uses.
+    field       text,
+    value       jsonb,
+    -- The same pin as the columns it sets, which is what the trigger below
+    -- applies. Worked out by the application, because a pinned name also
+    -- sets its normalised form, and only Go can normalise.
+    assignments jsonb,
+    -- How the row a merge folded in or a suppression removed is recognised
+    -- when a source offers it again: its Wikidata id, and its name and
+    -- country in the form identity holds them. A pinned name or country keeps
+    -- the identity it replaced here, for the same reason.
+    ref_wikidata_id text,
+    ref_name_key    text,
+    reason      text        NOT NULL DEFAULT '',
+    created_by  text        NOT NULL,
+    created_at  timestamptz NOT NULL DEFAULT now(),
+
+    CHECK ((kind = 'pin') = (field IS NOT NULL AND assignments IS NOT NULL)),
+    CHECK ((kind IN ('merge', 'split')) = (other_id IS NOT NULL))
+);
+
+-- One pin per field: pinning again replaces the value.
+CREATE UNIQUE INDEX museum_overrides_pin_idx ON museum_overrides (museum_id, field) WHERE kind = 'pin';
+
+-- One split per pair, whichever way round it was given.
+CREATE UNIQUE INDEX museum_overrides_split_idx
+    ON museum_overrides (least(museum_id, other_id), greatest(museum_id, other_id)) WHERE kind = 'split';
+
+CREATE INDEX museum_overrides_museum_idx ON museum_overrides (museum_id);
+
+-- Whether a split keeps two rows apart, for the merges to ask.
+CREATE FUNCTION museums_kept_apart(a bigint, b bigint) RETURNS boolean
+LANGUAGE sql STABLE AS $$
+    SELECT EXISTS (
+        SELECT 1 FROM museum_overrides
+        WHERE kind = 'split'
+          AND least(museum_id, other_id) = least(a, b)
+          AND greatest(museum_id, other_id) = greatest(a, b))
+$$;
+
+-- Pins are applied to every update of a pinned row, whichever statement made
+-- it: the upsert, a merge folding another row in, or a hand-run UPDATE. Only
+-- updates, because a row being inserted has no id a pin could name.
+CREATE FUNCTION apply_museum_pins() RETURNS trigger
+LANGUAGE plpgsql AS $$
+DECLARE
+    pins jsonb;
+BEGIN
+    SELECT jsonb_object_agg(a.key, a.value) INTO pins
+    FROM museum_overrides o, jsonb_each(o.assignments) a
+    WHERE o.kind = 'pin' AND o.museum_id = NEW.id;
+    IF pins IS NULL THEN
+        RETURN NEW;
+    END IF;
+
+    NEW := jsonb_populate_record(NEW, pins);
+    -- search_text is rebuilt from the incoming record's names, which a pinned
+    -- name overrides; without this the museum could not be found by it.
+    IF pins ? 'normalized' AND strpos(NEW.search_text, NEW.normalized) = 0 THEN
+        NEW.search_text := NEW.search_text || ' ' || NEW.normalized;
+    END IF;
+    RETURN NEW;
+END
+$$;
+
+CREATE TRIGGER museums_pins
+    BEFORE UPDATE ON museums
+    FOR EACH ROW EXECUTE FUNCTION apply_museum_pins();
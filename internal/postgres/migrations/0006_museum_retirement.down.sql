@@ -0,0 +1,28 @@
+-- The history's fields as 0004 defined them, before the columns they read go.
+CREATE OR REPLACE FUNCTION museum_revision_fields(m museums) RETURNS jsonb
+LANGUAGE sql STABLE AS $$
+    SELECT jsonb_build_object(
+        'name',                 m.name,
+        'wikidata_id',          nullif(m.wikidata_id, ''),
+        'country',              m.country,
+        'locality',             nullif(m.locality, ''),
+        'description',          nullif(m.description, ''),
+        'website',              nullif(m.website, ''),
+        'wikipedia_url',        nullif(m.wikipedia_url, ''),
+        'street',               nullif(m.street, ''),
+        'postcode',             nullif(m.postcode, ''),
+        'aliases',              to_jsonb(m.aliases),
+        'sources',              to_jsonb(m.sources),
+        'classes',              to_jsonb(m.classes),
+        'verified',             m.verified,
+        'location',             CASE WHEN m.location IS NULL THEN NULL ELSE jsonb_build_array(
+                                    round(ST_Y(m.location::geometry)::numeric, 6),
+                                    round(ST_X(m.location::geometry)::numeric, 6)) END,
+        'location_approximate', m.location_approximate
+    )
+$$;
+
+DROP TABLE museum_sightings;
+DROP TABLE crawl_runs;
+DROP INDEX museums_retired_idx;
+ALTER TABLE museums DROP COLUMN retired_reason, DROP COLUMN retired_at;
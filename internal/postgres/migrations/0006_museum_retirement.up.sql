@@ -0,0 +1,71 @@
+-- Museums no source offers any more, and the crawl runs that decide it.
+--
+-- The upsert only ever adds and updates, so a museum that closed, or a record
+-- a source withdrew as a mistake, stayed in the catalogue for good. Retirement
+-- is decided per source rather than by age: a museum is retired once every
+-- source that ever reported it has completed several runs without it, so one
+-- source being down, or one run being cut short, retires nothing.
+--
+-- A retired museum is hidden, not deleted. Its page still resolves, its
+-- history and overrides still point at it, and a source that offers it again
+-- restores it by clearing retired_at.
+ALTER TABLE museums
+    ADD COLUMN retired_at     timestamptz,
+    ADD COLUMN retired_reason text;
+
+CREATE INDEX museums_retired_idx ON museums (retired_at) WHERE retired_at IS NOT NULL;
+
+-- One row per source per crawl. Only complete runs count towards retirement:
+-- a run that was interrupted, or saw far fewer museums than the one before,
+-- says more about the source than about the museums it left out.
+CREATE TABLE crawl_runs (
+    id          bigserial   PRIMARY KEY,
+    -- The source tag museums carry in sources: "wikidata", "openstreetmap".
+    source      text        NOT NULL,
+    -- The run label, as the history records it.
+    label       text        NOT NULL,
+    started_at  timestamptz NOT NULL,
+    finished_at timestamptz NOT NULL DEFAULT now(),
+    seen        integer     NOT NULL,
+    complete    boolean     NOT NULL
+);
+
+CREATE INDEX crawl_runs_complete_idx ON crawl_runs (source, id) WHERE complete;
+
+-- The last run of each source that reported each museum. One row per pair, so
+-- the table stays the size of the catalogue however many crawls have run.
+CREATE TABLE museum_sightings (
+    museum_id bigint NOT NULL REFERENCES museums (id) ON DELETE CASCADE,
+    source    text   NOT NULL,
+    run_id    bigint NOT NULL REFERENCES crawl_runs (id),
+    PRIMARY KEY (museum_id, source)
+);
+
+CREATE INDEX museum_sightings_run_idx ON museum_sightings (run_id);
+
+-- Retirement and restoration belong in a museum's history like any other
+-- change, so the fields are added to the ones recorded.
+CREATE OR REPLACE FUNCTION museum_revision_fields(m museums) RETURNS jsonb
+LANGUAGE sql STABLE AS $$
+    SELECT jsonb_build_object(
+        'name',                 m.name,
+        'wikidata_id',          nullif(m.wikidata_id, ''),
+        'country',              m.country,
+        'locality',             nullif(m.locality, ''),
+        'description',          nullif(m.description, ''),
+        'website',              nullif(m.website, ''),
+        'wikipedia_url',        nullif(m.wikipedia_url, ''),
+        'street',               nullif(m.street, ''),
+        'postcode',             nullif(m.postcode, ''),
+        'aliases',              to_jsonb(m.aliases),
+        'sources',              to_jsonb(m.sources),
+        'classes',              to_jsonb(m.classes),
+        'verified',             m.verified,
+        'location',             CASE WHEN m.location IS NULL THEN NULL ELSE jsonb_build_array(
+                                    round(ST_Y(m.location::geometry)::numeric, 6),
+                                    round(ST_X(m.location::geometry)::numeric, 6)) END,
+        'location_approximate', m.location_approximate,
+        'retired_at',           m.retired_at,
+        'retired_reason',       m.retired_reason
+    )
+$$;
@@ -0,0 +1,5 @@
+ALTER TABLE exhibitions DROP COLUMN document, DROP COLUMN text_config;
+ALTER TABLE museums DROP COLUMN document;
+DROP FUNCTION museum_document(text, text[], text, text, text[], text);
+DROP FUNCTION catalogue_tsquery(text);
+DROP FUNCTION text_search_config(text);
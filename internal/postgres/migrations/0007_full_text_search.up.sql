@@ -0,0 +1,140 @@
+-- Full-text search over what the catalogue says about a museum, alongside the
+-- trigram search over what it is called.
+--
+-- Trigrams match names and nothing else. A museum's description ("working life
+-- museum in Gothenburg Municipality") and its Wikidata classes never reached a
+-- query, so "steamship museum gothenburg" found nothing, although the catalogue
+-- knows exactly which steamship that is. Those words need matching as words:
+-- stemmed, so "ships" reaches "ship", and with phrases and negation, neither of
+-- which trigram similarity can express.
+--
+-- Stemming is per language, and the language is per field. Descriptions and
+-- classes come from Wikidata and Wikipedia in English whatever the museum, so
+-- they are stemmed as English; a name, and an exhibition's title, are in the
+-- language of the country they stand in, so they are stemmed as that.
+
+-- The text search configuration for a country's language, for the languages
+-- Postgres ships a stemmer for. Elsewhere the words are kept as they are.
+-- Countries are matched by their English names, which is how every source
+-- records them.
+CREATE FUNCTION text_search_config(country text) RETURNS regconfig
+LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+    SELECT CASE lower(coalesce(country, ''))
+        WHEN 'united kingdom' THEN 'english'
+        WHEN 'united states' THEN 'english'
+        WHEN 'united states of america' THEN 'english'
+        WHEN 'ireland' THEN 'english'
+        WHEN 'canada' THEN 'english'
+        WHEN 'australia' THEN 'english'
+        WHEN 'new zealand' THEN 'english'
+        WHEN 'south africa' THEN 'english'
+        WHEN 'france' THEN 'french'
+        WHEN 'monaco' THEN 'french'
+        WHEN 'luxembourg' THEN 'french'
+        WHEN 'belgium' THEN 'french'
+        WHEN 'germany' THEN 'german'
+        WHEN 'austria' THEN 'german'
+        WHEN 'switzerland' THEN 'german'
+        WHEN 'liechtenstein' THEN 'german'
+        WHEN 'netherlands' THEN 'dutch'
+        WHEN 'kingdom of the netherlands' THEN 'dutch'
+        WHEN 'sweden' THEN 'swedish'
+        WHEN 'norway' THEN 'norwegian'
+        WHEN 'denmark' THEN 'danish'
+        WHEN 'finland' THEN 'finnish'
+        WHEN 'spain' THEN 'spanish'
+        WHEN 'mexico' THEN 'spanish'
+        WHEN 'argentina' THEN 'spanish'
+        WHEN 'chile' THEN 'spanish'
+        WHEN 'colombia' THEN 'spanish'
+        WHEN 'peru' THEN 'spanish'
+        WHEN 'italy' THEN 'italian'
+        WHEN 'san marino' THEN 'italian'
+        WHEN 'vatican city' THEN 'italian'
+        WHEN 'portugal' THEN 'portuguese'
+        WHEN 'brazil' THEN 'portuguese'
+        WHEN 'hungary' THEN 'hungarian'
+        WHEN 'romania' THEN 'romanian'
+        WHEN 'russia' THEN 'russian'
+        WHEN 'turkey' THEN 'turkish'
+        ELSE 'simple'
+    END::regconfig
+$$;
+
+-- A query as every configuration text_search_config can return reads it,
+-- joined with OR, so one tsquery matches a document in any of them and one
+-- index serves them all.
+--
+-- The language a query is typed in is unknown. Stemming it as every language
+-- the catalogue holds, and accepting a match in any, finds the French title
+-- stemmed as French and the English description stemmed as English. The cost
+-- is the occasional match where one language's stem of a word is another's
+-- stem of a different one, which the trigram score then ranks low.
+--
+-- websearch_to_tsquery is the syntax people already type into search boxes:
+-- "quoted phrases", -negation, and OR.
+CREATE FUNCTION catalogue_tsquery(query text) RETURNS tsquery
+LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+    SELECT websearch_to_tsquery('simple', query)
+        || websearch_to_tsquery('english', query)
+        || websearch_to_tsquery('french', query)
+        || websearch_to_tsquery('german', query)
+        || websearch_to_tsquery('dutch', query)
+        || websearch_to_tsquery('swedish', query)
+        || websearch_to_tsquery('norwegian', query)
+        || websearch_to_tsquery('danish', query)
+        || websearch_to_tsquery('finnish', query)
+        || websearch_to_tsquery('spanish', query)
+        || websearch_to_tsquery('italian', query)
+        || websearch_to_tsquery('portuguese', query)
+        || websearch_to_tsquery('hungarian', query)
+        || websearch_to_tsquery('romanian', query)
+        || websearch_to_tsquery('russian', query)
+        || websearch_to_tsquery('turkish', query)
+$$;
+
+-- What a museum's document holds, weighted so the name counts most: the name
+-- and aliases (A) in the country's language, the description and classes (B)
+-- and the town (C) in English.
+--
+-- Declared immutable so a generated column can use it. array_to_string is
+-- only stable, because formatting an element can depend on settings, but a
+-- text element formats as itself.
+CREATE FUNCTION museum_document(name text, aliases text[], country text,
+                                description text, classes text[], locality text)
+RETURNS tsvector
+LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+    SELECT setweight(to_tsvector(text_search_config(country),
+                                 coalesce(name, '') || ' ' || array_to_string(aliases, ' ')), 'A')
+        || setweight(to_tsvector('english',
+                                 coalesce(description, '') || ' ' || array_to_string(classes, ' ')), 'B')
+        || setweight(to_tsvector('english', coalesce(locality, '')), 'C')
+$$;
+
+-- Generated rather than written by the application, as search_text is:
+-- names, descriptions and classes reach the table through the upsert, the
+-- mergers and the overrides, and a column the database derives cannot be
+-- forgotten by any of them.
+ALTER TABLE museums ADD COLUMN document tsvector
+    GENERATED ALWAYS AS (museum_document(name, aliases, country, description, classes, locality)) STORED;
+
+CREATE INDEX museums_document_idx ON museums USING gin (document);
+
+-- An exhibition's title is in its venue's language, and the venue's country is
+-- on the museum, not the listing. The configuration is therefore stored, set
+-- when the listing is saved, and the document generated from it.
+ALTER TABLE exhibitions ADD COLUMN text_config regconfig NOT NULL DEFAULT 'simple';
+
+UPDATE exhibitions e
+SET text_config = text_search_config(m.country)
+FROM museums m
+WHERE m.identity = e.museum_wikidata_id
+  AND e.museum_wikidata_id <> '';
+
+ALTER TABLE exhibitions ADD COLUMN document tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector(text_config, title), 'A')
+     || setweight(to_tsvector(text_config, coalesce(museum, '')), 'B')
+    ) STORED;
+
+CREATE INDEX exhibitions_document_idx ON exhibitions USING gin (document);
@@ -0,0 +1,15 @@
+-- Archived exhibitions go back to the live table rather than being lost with
+-- it. Only those whose URL is not live again: the live row is the newer run.
+INSERT INTO exhibitions (url, title, museum, museum_wikidata_id, starts_on, ends_on, location,
+                         source_page, scraped_at, permanent, first_seen_at, last_seen_at,
+                         retired_at, source, site, text_config)
+SELECT DISTINCT ON (url)
+       url, title, museum, museum_wikidata_id, starts_on, ends_on, location,
+       source_page, scraped_at, permanent, first_seen_at, last_seen_at,
+       retired_at, source, site, text_config
+FROM exhibitions_archive
+ORDER BY url, ended_on DESC
+ON CONFLICT (url) DO NOTHING;
+
+DROP VIEW exhibitions_ever;
+DROP TABLE exhibitions_archive;
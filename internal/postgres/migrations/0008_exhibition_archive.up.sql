@@ -0,0 +1,61 @@
+-- Exhibitions that ended long ago, moved out of the live table.
+--
+-- Nothing ever left exhibitions: a show that closed three years ago, or a
+-- listing retired when its site dropped it, sat beside the current ones in the
+-- GiST and trigram indexes every read walks, and exhibitions_live_idx was all
+-- that kept "what is on now" from scanning the lot. They are still history —
+-- what was showing in Lyon last spring, and what a museum has put on over the
+-- years, are questions worth answering — so they are moved, not deleted.
+--
+-- Partitioned by the year the exhibition ended, which is how the archive is
+-- read: a point in time touches the partitions from that year on, and the
+-- years that only grow older are never written again. Partitions are created
+-- by the archiver as the years it moves call for them; the default partition
+-- catches whatever arrives otherwise, which is what a restore into a fresh
+-- schema does, until the archiver next runs and files those rows by year.
+CREATE TABLE exhibitions_archive (
+    url                text        NOT NULL,
+    title              text        NOT NULL,
+    museum             text,
+    museum_wikidata_id text,
+    starts_on          date,
+    ends_on            date,
+    location           geography(Point, 4326),
+    source_page        text,
+    scraped_at         timestamptz NOT NULL,
+    permanent          boolean     NOT NULL DEFAULT false,
+    first_seen_at      timestamptz NOT NULL,
+    last_seen_at       timestamptz NOT NULL,
+    retired_at         timestamptz,
+    source             text        NOT NULL DEFAULT 'scraped',
+    site               text        NOT NULL DEFAULT '',
+    text_config        regconfig   NOT NULL DEFAULT 'simple',
+
+    -- The day it stopped being on: its closing date, or the day its listing
+    -- was retired if that came first. The partition key.
+    ended_on           date        NOT NULL,
+    archived_at        timestamptz NOT NULL DEFAULT now(),
+
+    -- A URL can be archived more than once: a page reused for next year's
+    -- edition of a show goes live again, ends again and is archived again,
+    -- and both runs are history. What cannot repeat is the same run twice.
+    PRIMARY KEY (url, ended_on)
+) PARTITION BY RANGE (ended_on);
+
+CREATE TABLE exhibitions_archive_default PARTITION OF exhibitions_archive DEFAULT;
+
+CREATE INDEX exhibitions_archive_location_idx ON exhibitions_archive USING gist (location);
+CREATE INDEX exhibitions_archive_museum_idx ON exhibitions_archive (museum_wikidata_id);
+
+-- Every exhibition the catalogue has held, live and archived, for the reads
+-- that ask about the past. The live table's own reads go on using it alone.
+CREATE VIEW exhibitions_ever AS
+SELECT url, title, museum, museum_wikidata_id, starts_on, ends_on, location,
+       source_page, scraped_at, permanent, first_seen_at, retired_at,
+       least(ends_on, retired_at::date) AS ended_on, false AS archived
+FROM exhibitions
+UNION ALL
+SELECT url, title, museum, museum_wikidata_id, starts_on, ends_on, location,
+       source_page, scraped_at, permanent, first_seen_at, retired_at,
+       ended_on, true AS archived
+FROM exhibitions_archive;
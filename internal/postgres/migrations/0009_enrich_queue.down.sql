@@ -0,0 +1 @@
+DROP TABLE enrich_queue;
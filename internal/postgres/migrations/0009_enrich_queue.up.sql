@@ -0,0 +1,25 @@
+-- Work for the enricher, for running it without Kafka.
+--
+-- The Kafka path needs a broker and a bucket notification wired to it, and a
+-- notification that was never configured fails silently: the enricher simply
+-- waits. Here the writers say what needs enriching themselves — a crawl the
+-- museums it wrote, a reindex the ones with no enriched copy — and workers
+-- claim rows under a lease, so one that dies mid-museum leaves its rows to
+-- fall due again rather than losing them.
+CREATE TABLE enrich_queue (
+    id           bigserial   PRIMARY KEY,
+    bucket       text        NOT NULL,
+    key          text        NOT NULL,
+    -- Bumped each time the object is queued again. An acknowledgement names
+    -- the generation it claimed, so a worker finishing an older version of an
+    -- object cannot remove the row asking for the newer one.
+    generation   bigint      NOT NULL DEFAULT 1,
+    enqueued_at  timestamptz NOT NULL DEFAULT now(),
+    -- When the row may next be claimed: now for new work, the end of the
+    -- lease for work in progress.
+    available_at timestamptz NOT NULL DEFAULT now(),
+    attempts     integer     NOT NULL DEFAULT 0,
+    UNIQUE (bucket, key)
+);
+
+CREATE INDEX enrich_queue_available_idx ON enrich_queue (available_at, id);
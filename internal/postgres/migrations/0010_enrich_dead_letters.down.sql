@@ -0,0 +1 @@
+DROP TABLE enrich_dead_letters;
@@ -0,0 +1,19 @@
+-- Enrichments that gave up, kept so they can be looked at and run again.
+--
+-- A museum whose geocoding kept failing used to be logged and acknowledged,
+-- and was enriched again only if the process happened to crash first. Here it
+-- is recorded instead: one row per object and stage, with the last error and
+-- how many attempts it has cost across every delivery, until "museum enrich
+-- replay" gets it through.
+CREATE TABLE enrich_dead_letters (
+    id        bigserial   PRIMARY KEY,
+    bucket    text        NOT NULL,
+    key       text        NOT NULL,
+    stage     text        NOT NULL,
+    error     text        NOT NULL,
+    attempts  integer     NOT NULL,
+    failed_at timestamptz NOT NULL DEFAULT now(),
+    UNIQUE (bucket, key, stage)
+);
+
+CREATE INDEX enrich_dead_letters_failed_idx ON enrich_dead_letters (failed_at);
@@ -0,0 +1,7 @@
+DROP TRIGGER exhibitions_outbox ON exhibitions;
+DROP TRIGGER museums_outbox ON museums;
+DROP FUNCTION record_exhibition_event();
+DROP FUNCTION record_museum_event();
+DROP FUNCTION exhibition_event_data(exhibitions);
+DROP FUNCTION museum_event_data(museums);
+DROP TABLE outbox_events;
@@ -0,0 +1,134 @@
+-- Changes to the catalogue, waiting to be published to Kafka.
+--
+-- Other services want to hear when a museum is added, placed, merged or
+-- retired, and when an exhibition is first seen, moves its dates or is
+-- retired. Publishing from the code that makes the change would lose events
+-- whenever the broker was down, or publish changes that were then rolled
+-- back. An event is written here instead, in the same transaction as the
+-- change, and "museum relay" publishes what is here and deletes it.
+--
+-- As with museum_revisions, the events are written by triggers rather than by
+-- each query: there are a dozen write paths, and an event stream that some of
+-- them forget is worse than none. Merges are the exception. Only the merge
+-- knows which museum a deleted row was folded into, so each merge statement
+-- writes its own museum.merged events. No other delete is published.
+CREATE TABLE outbox_events (
+    id          bigserial   PRIMARY KEY,
+    -- What the event is about, "museum" or "exhibition", which names the
+    -- topic it is published to.
+    aggregate   text        NOT NULL,
+    -- The museum's id or the exhibition's URL. It is the message key, so the
+    -- events about one museum share a partition and arrive in order.
+    key         text        NOT NULL,
+    type        text        NOT NULL,
+    -- The version of data's shape for this type. A change a consumer could
+    -- trip on is a new version, not an edit to this one.
+    version     integer     NOT NULL,
+    data        jsonb       NOT NULL,
+    occurred_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- What a museum event says about the museum.
+CREATE FUNCTION museum_event_data(m museums) RETURNS jsonb
+LANGUAGE sql STABLE AS $$
+    SELECT jsonb_build_object(
+        'id',                   m.id,
+        'wikidata_id',          nullif(m.wikidata_id, ''),
+        'name',                 m.name,
+        'country',              m.country,
+        'locality',             nullif(m.locality, ''),
+        'website',              nullif(m.website, ''),
+        'location',             CASE WHEN m.location IS NULL THEN NULL ELSE jsonb_build_array(
+                                    round(ST_Y(m.location::geometry)::numeric, 6),
+                                    round(ST_X(m.location::geometry)::numeric, 6)) END,
+        'location_approximate', m.location_approximate,
+        'retired_at',           m.retired_at,
+        'retired_reason',       m.retired_reason
+    )
+$$;
+
+-- What an exhibition event says about the exhibition.
+CREATE FUNCTION exhibition_event_data(e exhibitions) RETURNS jsonb
+LANGUAGE sql STABLE AS $$
+    SELECT jsonb_build_object(
+        'url',                e.url,
+        'title',              e.title,
+        'museum',             e.museum,
+        'museum_wikidata_id', nullif(e.museum_wikidata_id, ''),
+        'starts_on',          e.starts_on,
+        'ends_on',            e.ends_on,
+        'permanent',          e.permanent,
+        'location',           CASE WHEN e.location IS NULL THEN NULL ELSE jsonb_build_array(
+                                  round(ST_Y(e.location::geometry)::numeric, 6),
+                                  round(ST_X(e.location::geometry)::numeric, 6)) END,
+        'first_seen_at',      e.first_seen_at,
+        'retired_at',         e.retired_at
+    )
+$$;
+
+-- A museum is added when inserted, geocoded when it gains a position or a
+-- precise one replaces an approximate one, and retired or restored as
+-- retired_at is set or cleared. A crawl rewrites every museum it sees, so
+-- anything else an update changes is left to museum_revisions.
+CREATE FUNCTION record_museum_event() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO outbox_events (aggregate, key, type, version, data)
+        VALUES ('museum', NEW.id::text, 'museum.added', 1, museum_event_data(NEW));
+        RETURN NULL;
+    END IF;
+
+    IF NEW.location IS NOT NULL
+       AND (OLD.location IS NULL OR (OLD.location_approximate AND NOT NEW.location_approximate)) THEN
+        INSERT INTO outbox_events (aggregate, key, type, version, data)
+        VALUES ('museum', NEW.id::text, 'museum.geocoded', 1, museum_event_data(NEW));
+    END IF;
+    IF OLD.retired_at IS NULL AND NEW.retired_at IS NOT NULL THEN
+        INSERT INTO outbox_events (aggregate, key, type, version, data)
+        VALUES ('museum', NEW.id::text, 'museum.retired', 1, museum_event_data(NEW));
+    ELSIF OLD.retired_at IS NOT NULL AND NEW.retired_at IS NULL THEN
+        INSERT INTO outbox_events (aggregate, key, type, version, data)
+        VALUES ('museum', NEW.id::text, 'museum.restored', 1, museum_event_data(NEW));
+    END IF;
+    RETURN NULL;
+END
+$$;
+
+CREATE TRIGGER museums_outbox
+    AFTER INSERT OR UPDATE ON museums
+    FOR EACH ROW EXECUTE FUNCTION record_museum_event();
+
+-- An exhibition is seen when inserted, has its dates changed when either date
+-- moves, and is retired or restored as retired_at is set or cleared. The
+-- dates_changed event carries the dates it had before.
+CREATE FUNCTION record_exhibition_event() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO outbox_events (aggregate, key, type, version, data)
+        VALUES ('exhibition', NEW.url, 'exhibition.seen', 1, exhibition_event_data(NEW));
+        RETURN NULL;
+    END IF;
+
+    IF OLD.starts_on IS DISTINCT FROM NEW.starts_on OR OLD.ends_on IS DISTINCT FROM NEW.ends_on THEN
+        INSERT INTO outbox_events (aggregate, key, type, version, data)
+        VALUES ('exhibition', NEW.url, 'exhibition.dates_changed', 1,
+                exhibition_event_data(NEW) || jsonb_build_object(
+                    'previous_starts_on', OLD.starts_on,
+                    'previous_ends_on',   OLD.ends_on));
+    END IF;
+    IF OLD.retired_at IS NULL AND NEW.retired_at IS NOT NULL THEN
+        INSERT INTO outbox_events (aggregate, key, type, version, data)
+        VALUES ('exhibition', NEW.url, 'exhibition.retired', 1, exhibition_event_data(NEW));
+    ELSIF OLD.retired_at IS NOT NULL AND NEW.retired_at IS NULL THEN
+        INSERT INTO outbox_events (aggregate, key, type, version, data)
+        VALUES ('exhibition', NEW.url, 'exhibition.restored', 1, exhibition_event_data(NEW));
+    END IF;
+    RETURN NULL;
+END
+$$;
+
+CREATE TRIGGER exhibitions_outbox
+    AFTER INSERT OR UPDATE ON exhibitions
+    FOR EACH ROW EXECUTE FUNCTION record_exhibition_event();
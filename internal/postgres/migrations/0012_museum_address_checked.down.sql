@@ -0,0 +1 @@
+ALTER TABLE museums DROP COLUMN address_checked_at;
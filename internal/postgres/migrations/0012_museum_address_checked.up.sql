@@ -0,0 +1,8 @@
+-- When a museum's address was last looked for by its position.
+--
+-- Reverse geocoding fills in the street and postcode of museums that have a
+-- location but no address. Many are in places no map has addresses for, and
+-- without a record of having asked, every run would spend the geocoder's rate
+-- limit asking about them again. A museum found to have an address needs no
+-- mark: it is no longer one without.
+ALTER TABLE museums ADD COLUMN address_checked_at timestamptz;
@@ -0,0 +1,23 @@
+-- Geocoders' answers, kept so a question is asked of them once.
+--
+-- Enrichment, locate and the API each asked Nominatim for themselves, and
+-- only the API remembered, in places. Re-enriching after a re-crawl asked
+-- every question again at one a second. Answers are kept here by provider,
+-- kind of question and normalised key, misses included: a museum no map knows
+-- would otherwise cost a request on every run. Each entry carries its own
+-- expiry, so misses can be kept for less than what was found.
+CREATE TABLE geocode_cache (
+    provider   text        NOT NULL,
+    -- search, reverse, lookup or details.
+    kind       text        NOT NULL,
+    key        text        NOT NULL,
+    -- The answer as the geocoder gave it, or NULL for "nothing found".
+    response   jsonb,
+    cached_at  timestamptz NOT NULL DEFAULT now(),
+    expires_at timestamptz NOT NULL,
+    -- How many times the entry has answered in place of the geocoder.
+    hits       bigint      NOT NULL DEFAULT 0,
+    PRIMARY KEY (provider, kind, key)
+);
+
+CREATE INDEX geocode_cache_expires_idx ON geocode_cache (expires_at);
@@ -0,0 +1,3 @@
+DROP TRIGGER outbox_events_switch ON outbox_events;
+DROP FUNCTION keep_outbox_event();
+DROP TABLE outbox_settings;
@@ -0,0 +1,37 @@
+-- Whether the outbox is kept at all.
+--
+-- Events are deleted as the relay publishes them, so the outbox stays small
+-- only where a relay runs. A deployment without Kafka had every change to the
+-- catalogue written here and never read, a row per museum per crawl, for as
+-- long as it ran. The events are now written only while this says so. It is
+-- checked by a trigger on the outbox itself rather than by each writer, so the
+-- merges' own events are covered with the triggers' without a second list of
+-- places to keep.
+--
+-- "museum relay" switches it on as it starts, and "museum outbox disable"
+-- off. It starts on, so a deployment relaying today loses nothing across the
+-- upgrade, unless the outbox already holds events more than a day old: then
+-- nothing has been relaying them.
+CREATE TABLE outbox_settings (
+    -- One row, and only one.
+    singleton  boolean     PRIMARY KEY DEFAULT true CHECK (singleton),
+    enabled    boolean     NOT NULL,
+    changed_at timestamptz NOT NULL DEFAULT now()
+);
+
+INSERT INTO outbox_settings (enabled)
+SELECT NOT EXISTS (SELECT 1 FROM outbox_events WHERE occurred_at < now() - interval '1 day');
+
+CREATE FUNCTION keep_outbox_event() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    IF coalesce((SELECT enabled FROM outbox_settings), false) THEN
+        RETURN NEW;
+    END IF;
+    RETURN NULL;
+END;
+$$;
+
+CREATE TRIGGER outbox_events_switch
+    BEFORE INSERT ON outbox_events
+    FOR EACH ROW EXECUTE FUNCTION keep_outbox_event();
@@ -0,0 +1,139 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// OutboxEvent is a change to the catalogue waiting to be published. Events
+// are written by the database, in the transaction that made the change; see
+// migration 0011.
+type OutboxEvent struct {
+	ID int64
+	// Aggregate is what the event is about, "museum" or "exhibition".
+	Aggregate string
+	// Key is the museum's id or the exhibition's URL.
+	Key        string
+	Type       string
+	Version    int
+	Data       json.RawMessage
+	OccurredAt time.Time
+}
+
+// RelayOutbox hands the oldest events waiting, at most limit of them, to
+// publish in the order they were written, and deletes them once it returns
+// without error. It reports how many were published.
+//
+// The events stay locked while publish runs, so a second relay waits for the
+// first rather than publishing the same events out of order. If publish fails,
+// or the process dies before the delete commits, the events are published
+// again by the next call: delivery is at least once, and a consumer tells a
+// repeat by the event's id.
+func (s *Store) RelayOutbox(ctx context.Context, limit int, publish func(context.Context, []OutboxEvent) error) (int, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("relay outbox: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+SELECT id, aggregate, key, type, version, data, occurred_at
+  FROM outbox_events
+ ORDER BY id
+ LIMIT $1
+   FOR UPDATE`, limit)
+	if err != nil {
+		return 0, fmt.Errorf("relay outbox: %w", err)
+	}
+	var events []OutboxEvent
+	for rows.Next() {
+		var e OutboxEvent
+		if err := rows.Scan(&e.ID, &e.Aggregate, &e.Key, &e.Type, &e.Version, &e.Data, &e.OccurredAt); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan outbox event: %w", err)
+		}
+		events = append(events, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("relay outbox: %w", err)
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	if err := publish(ctx, events); err != nil {
+		return 0, err
+	}
+
+	ids := make([]int64, len(events))
+	for i, e := range events {
+		ids[i] = e.ID
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM outbox_events WHERE id = ANY($1)`, ids); err != nil {
+		return 0, fmt.Errorf("relay outbox: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("relay outbox: %w", err)
+	}
+	return len(events), nil
+}
+
+// OutboxStatus is whether change events are being kept, and what is waiting.
+type OutboxStatus struct {
+	Enabled bool `json:"enabled"`
+	// Pending is how many events wait for the relay, and Oldest when the
+	// first of them was written; zero when none wait.
+	Pending int64     `json:"pending"`
+	Oldest  time.Time `json:"oldest,omitzero"`
+}
+
+// Outbox reports the outbox's switch and backlog.
+func (s *Store) Outbox(ctx context.Context) (OutboxStatus, error) {
+	var (
+		st     OutboxStatus
+		oldest *time.Time
+	)
+	err := s.pool.QueryRow(ctx, `
+SELECT coalesce((SELECT enabled FROM outbox_settings), false), count(*), min(occurred_at)
+  FROM outbox_events`).Scan(&st.Enabled, &st.Pending, &oldest)
+	if err != nil {
+		return OutboxStatus{}, fmt.Errorf("outbox status: %w", err)
+	}
+	if oldest != nil {
+		st.Oldest = *oldest
+	}
+	return st, nil
+}
+
+// SetOutbox switches the keeping of change events on or off, and reports
+// whether it was on. While it is off, changes to the catalogue write no
+// events, and nothing a relay started later can publish them.
+func (s *Store) SetOutbox(ctx context.Context, enabled bool) (bool, error) {
+	var was bool
+	err := s.pool.QueryRow(ctx, `
+WITH previous AS (SELECT enabled FROM outbox_settings)
+UPDATE outbox_settings
+   SET enabled = $1, changed_at = CASE WHEN enabled = $1 THEN changed_at ELSE now() END
+RETURNING (SELECT enabled FROM previous)`, enabled).Scan(&was)
+	if err != nil {
+		return false, fmt.Errorf("switch outbox: %w", err)
+	}
+	return was, nil
+}
+
+// PurgeOutbox deletes the events that have waited longer than olderThan for a
+// relay, all of them when it is zero, and returns how many it deleted. They
+// are lost to consumers for good, which is the point: a backlog that old was
+// left by a relay that stopped, and replaying it would announce as news what
+// the catalogue has long since moved past.
+func (s *Store) PurgeOutbox(ctx context.Context, olderThan time.Duration) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `
+DELETE FROM outbox_events WHERE occurred_at < now() - make_interval(secs => $1)`, olderThan.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("purge outbox: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,170 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"museum/internal/models"
+)
+
+func TestRelayOutbox_PublishesChangesInOrderAndOnlyOnce(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	if _, err := store.SaveMuseums(ctx, []models.Museum{{Name: "Röhsska Museet", Country: "Sweden", Locality: "Gothenburg"}}); err != nil {
+		t.Fatalf("save museum: %v", err)
+	}
+	var id int64
+	if err := store.pool.QueryRow(ctx, `SELECT id FROM museums`).Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetLocation(ctx, id, 57.6984, 11.9741, false); err != nil {
+		t.Fatalf("set location: %v", err)
+	}
+
+	end := now.AddDate(0, 1, 0)
+	saveAt(t, store, now, listing("rohsska.example", "textiles", "Textiles", &end))
+	later := end.AddDate(0, 1, 0)
+	saveAt(t, store, now.Add(time.Hour), listing("rohsska.example", "textiles", "Textiles", &later))
+	if _, err := store.RetireUnseen(ctx, "rohsska.example", now.Add(2*time.Hour)); err != nil {
+		t.Fatalf("retire: %v", err)
+	}
+
+	// A publish that fails leaves everything where it was.
+	if _, err := store.RelayOutbox(ctx, 100, func(context.Context, []OutboxEvent) error {
+		return errors.New("broker down")
+	}); err == nil {
+		t.Fatal("relay reported success although publishing failed")
+	}
+
+	var published []OutboxEvent
+	publish := func(_ context.Context, events []OutboxEvent) error {
+		published = append(published, events...)
+		return nil
+	}
+	for {
+		n, err := store.RelayOutbox(ctx, 2, publish)
+		if err != nil {
+			t.Fatalf("relay: %v", err)
+		}
+		if n == 0 {
+			break
+		}
+	}
+
+	var types []string
+	for _, e := range published {
+		types = append(types, e.Type)
+	}
+	want := []string{"museum.added", "museum.geocoded", "exhibition.seen", "exhibition.dates_changed", "exhibition.retired"}
+	if !slices.Equal(types, want) {
+		t.Fatalf("published %v, want %v", types, want)
+	}
+	if !slices.IsSortedFunc(published, func(a, b OutboxEvent) int { return int(a.ID - b.ID) }) {
+		t.Error("events were published out of order")
+	}
+
+	var geocoded struct {
+		ID       int64     `json:"id"`
+		Location []float64 `json:"location"`
+	}
+	if err := json.Unmarshal(published[1].Data, &geocoded); err != nil {
+		t.Fatal(err)
+	}
+	if geocoded.ID != id || len(geocoded.Location) != 2 || geocoded.Location[0] != 57.6984 {
+		t.Errorf("geocoded data = %s", published[1].Data)
+	}
+	var moved struct {
+		PreviousEndsOn string `json:"previous_ends_on"`
+	}
+	if err := json.Unmarshal(published[3].Data, &moved); err != nil || moved.PreviousEndsOn != end.Format(time.DateOnly) {
+		t.Errorf("dates_changed data = %s, want the previous end %s", published[3].Data, end.Format(time.DateOnly))
+	}
+
+	// Published events are gone; a relay finds nothing more.
+	if n, err := store.RelayOutbox(ctx, 100, publish); err != nil || n != 0 {
+		t.Errorf("second relay = %d, %v; want nothing left", n, err)
+	}
+}
+
+// A merge says which museum the deleted row went into.
+func TestMergeDuplicates_RecordsMergedEvents(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+
+	if _, err := store.SaveMuseums(ctx, []models.Museum{
+		{Name: "Louvre", Country: "France", WikidataID: "Q19675"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.pool.Exec(ctx,
+		`INSERT INTO museums (name, normalized, country) SELECT name, normalized, country FROM museums`); err != nil {
+		t.Fatal(err)
+	}
+	var keep, drop int64
+	if err := store.pool.QueryRow(ctx, `SELECT min(id), max(id) FROM museums`).Scan(&keep, &drop); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.pool.Exec(ctx, `DELETE FROM outbox_events`); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := store.MergeDuplicates(ctx); err != nil || n != 1 {
+		t.Fatalf("merge = %d, %v", n, err)
+	}
+
+	var (
+		key    string
+		merged int64
+	)
+	if err := store.pool.QueryRow(ctx,
+		`SELECT key, (data->>'merged_into')::bigint FROM outbox_events WHERE type = 'museum.merged'`).
+		Scan(&key, &merged); err != nil {
+		t.Fatalf("no merged event: %v", err)
+	}
+	if key != strconv.FormatInt(drop, 10) || merged != keep {
+		t.Errorf("merged event for %s into %d, want %d into %d", key, merged, drop, keep)
+	}
+}
+
+// Switched off, changes write no events; purged, what waited is gone.
+func TestOutbox_SwitchAndPurge(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+
+	if st, err := store.Outbox(ctx); err != nil || !st.Enabled {
+		t.Fatalf("new outbox = %+v, %v; want it on", st, err)
+	}
+	if _, err := store.SaveMuseums(ctx, []models.Museum{{Name: "Louvre", Country: "France"}}); err != nil {
+		t.Fatal(err)
+	}
+	if was, err := store.SetOutbox(ctx, false); err != nil || !was {
+		t.Fatalf("switch off = %v, %v; want it to have been on", was, err)
+	}
+	if _, err := store.SaveMuseums(ctx, []models.Museum{{Name: "Uffizi", Country: "Italy"}}); err != nil {
+		t.Fatal(err)
+	}
+	st, err := store.Outbox(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Enabled || st.Pending != 1 || st.Oldest.IsZero() {
+		t.Errorf("outbox = %+v, want it off with only the Louvre's event waiting", st)
+	}
+
+	if n, err := store.PurgeOutbox(ctx, time.Hour); err != nil || n != 0 {
+		t.Errorf("purge older than an hour = %d, %v; want nothing that new purged", n, err)
+	}
+	if n, err := store.PurgeOutbox(ctx, 0); err != nil || n != 1 {
+		t.Errorf("purge everything = %d, %v; want the one event", n, err)
+	}
+	if was, err := store.SetOutbox(ctx, true); err != nil || was {
+		t.Errorf("switch on = %v, %v; want it to have been off", was, err)
+	}
+}
@@ -0,0 +1,397 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"museum/internal/models"
+	"museum/internal/override"
+	"museum/internal/search"
+)
+
+// Overrides lists the overrides in force, newest first: every one, or those
+// naming one museum.
+func (s *Store) Overrides(ctx context.Context, museumID int64) ([]override.Override, error) {
+	const stmt = `
+SELECT id, kind, museum_id, coalesce(other_id, 0), coalesce(field, ''), value,
+       coalesce(ref_wikidata_id, ''), ref_name_key, reason, created_by, created_at
+FROM museum_overrides
+WHERE $1 = 0 OR museum_id = $1 OR other_id = $1
+ORDER BY id DESC`
+
+	rows, err := s.pool.Query(ctx, stmt, museumID)
+	if err != nil {
+		return nil, fmt.Errorf("overrides: %w", err)
+	}
+	defer rows.Close()
+
+	var out []override.Override
+	for rows.Next() {
+		var (
+			o       override.Override
+			value   []byte
+			refID   string
+			nameKey *string
+		)
+		if err := rows.Scan(&o.ID, &o.Kind, &o.MuseumID, &o.OtherID, &o.Field, &value,
+			&refID, &nameKey, &o.Reason, &o.CreatedBy, &o.CreatedAt); err != nil {
+			return nil, fmt.Errorf("overrides: %w", err)
+		}
+		o.Value = value
+		if nameKey != nil {
+			o.Ref = &override.Ref{WikidataID: refID, NameKey: *nameKey}
+		}
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
+
+// OverrideRules returns what an incoming record has to be checked against,
+// with each rule resolved to the museum it names as that museum stands now.
+//
+// Resolved here rather than when the override was made, because identities
+// move: a museum merged into one that has since gained a Wikidata id must be
+// folded into it under the id, or the upsert would recreate it beside it.
+func (s *Store) OverrideRules(ctx context.Context) (*override.Rules, error) {
+	const stmt = `
+SELECT o.kind, coalesce(o.ref_wikidata_id, ''), coalesce(o.ref_name_key, ''),
+       k.id IS NOT NULL, coalesce(k.name, ''), coalesce(k.wikidata_id, ''), coalesce(k.country, ''),
+       b.id IS NOT NULL, coalesce(b.name, ''), coalesce(b.wikidata_id, ''), coalesce(b.country, '')
+FROM museum_overrides o
+LEFT JOIN museums k ON k.id = o.museum_id
+LEFT JOIN museums b ON b.id = o.other_id
+WHERE o.kind <> 'pin' OR o.ref_name_key IS NOT NULL`
+
+	rows, err := s.pool.Query(ctx, stmt)
+	if err != nil {
+		return nil, fmt.Errorf("override rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := &override.Rules{}
+	for rows.Next() {
+		var (
+			kind           override.Kind
+			ref            override.Ref
+			hasSubject     bool
+			hasOther       bool
+			subject, other models.Museum
+		)
+		if err := rows.Scan(&kind, &ref.WikidataID, &ref.NameKey,
+			&hasSubject, &subject.Name, &subject.WikidataID, &subject.Country,
+			&hasOther, &other.Name, &other.WikidataID, &other.Country); err != nil {
+			return nil, fmt.Errorf("override rules: %w", err)
+		}
+		switch {
+		case kind == override.Suppress:
+			rules.Suppress(ref)
+		case kind == override.Split && hasSubject && hasOther:
+			rules.KeepApart(override.RefOf(subject), override.RefOf(other))
+		case (kind == override.Merge || kind == override.Pin) && hasSubject:
+			rules.Redirect(ref, subject)
+		}
+	}
+	return rules, rows.Err()
+}
+
+// honour applies the overrides to a batch on its way in: suppressed records
+// are dropped, and redirected ones take the identity of the museum they were
+// merged into.
+func honour(rules *override.Rules, museums []models.Museum) []models.Museum {
+	if rules.Len() == 0 {
+		return museums
+	}
+	out := make([]models.Museum, 0, len(museums))
+	for _, m := range museums {
+		if rules.Suppressed(m) {
+			continue
+		}
+		out = append(out, rules.Apply(m))
+	}
+	return out
+}
+
+// AddOverride puts an override in force and applies it at once: a pin is
+// written to the museum, a merge folds the other museum in, a suppression
+// deletes the museum. Each runs in one transaction, with the museum's history
+// attributed to the override ("override 12") rather than to the session.
+//
+// Pinning the same field again replaces the pin, and splitting a pair already
+// split returns the split in force.
+func (s *Store) AddOverride(ctx context.Context, o override.Override) (override.Override, error) {
+	if err := o.Validate(); err != nil {
+		return override.Override{}, err
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return override.Override{}, fmt.Errorf("add override: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	subject, err := lockMuseum(ctx, tx, o.MuseumID)
+	if err != nil {
+		return override.Override{}, err
+	}
+	var other models.Museum
+	if o.OtherID != 0 {
+		if other, err = lockMuseum(ctx, tx, o.OtherID); err != nil {
+			return override.Override{}, err
+		}
+	}
+
+	switch o.Kind {
+	case override.Pin:
+		err = pin(ctx, tx, &o, subject)
+	case override.Merge:
+		err = forceMerge(ctx, tx, &o, other)
+	case override.Split:
+		err = insertOverride(ctx, tx, &o, nil, nil)
+	case override.Suppress:
+		ref := override.RefOf(subject)
+		if err = insertOverride(ctx, tx, &o, nil, &ref); err == nil {
+			_, err = tx.Exec(ctx, `DELETE FROM museums WHERE id = $1`, o.MuseumID)
+		}
+	}
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "museums_identity_idx" {
+			return override.Override{}, fmt.Errorf("%w: another museum already stands under that name and country; merge the two instead",
+				override.ErrConflict)
+		}
+		return override.Override{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return override.Override{}, fmt.Errorf("add override: %w", err)
+	}
+	s.bumpGeneration(ctx)
+	return o, nil
+}
+
+// lockMuseum reads the fields an override needs of a museum, holding the row
+// until the transaction ends.
+func lockMuseum(ctx context.Context, tx pgx.Tx, id int64) (models.Museum, error) {
+	var m models.Museum
+	err := tx.QueryRow(ctx,
+		`SELECT name, coalesce(wikidata_id, ''), coalesce(country, '') FROM museums WHERE id = $1 FOR UPDATE`, id,
+	).Scan(&m.Name, &m.WikidataID, &m.Country)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.Museum{}, fmt.Errorf("museum %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return models.Museum{}, fmt.Errorf("add override: %w", err)
+	}
+	return m, nil
+}
+
+// insertOverride records o, filling in its id and creation time, and labels
+// the rest of the transaction's writes with it.
+func insertOverride(ctx context.Context, tx pgx.Tx, o *override.Override, assignments map[string]any, ref *override.Ref) error {
+	const stmt = `
+INSERT INTO museum_overrides
+    (kind, museum_id, other_id, field, value, assignments, ref_wikidata_id, ref_name_key, reason, created_by)
+VALUES ($1, $2, nullif($3, 0), nullif($4, ''), $5, $6, nullif($7, ''), $8, $9, $10)
+ON CONFLICT (museum_id, field) WHERE kind = 'pin' DO UPDATE SET
+    value = EXCLUDED.value, assignments = EXCLUDED.assignments,
+    -- The identity a pinned name replaced is the one the sources still use,
+    -- however many times the pin is changed.
+    ref_wikidata_id = coalesce(museum_overrides.ref_wikidata_id, EXCLUDED.ref_wikidata_id),
+    ref_name_key = coalesce(museum_overrides.ref_name_key, EXCLUDED.ref_name_key),
+    reason = EXCLUDED.reason, created_by = EXCLUDED.created_by, created_at = now()
+RETURNING id, created_at`
+
+	var (
+		refID   string
+		nameKey *string
+		value   []byte
+	)
+	if ref != nil {
+		refID, nameKey = ref.WikidataID, &ref.NameKey
+		o.Ref = ref
+	}
+	if o.Kind == override.Pin {
+		value = o.Value
+	}
+	var encoded []byte
+	if assignments != nil {
+		var err error
+		if encoded, err = json.Marshal(assignments); err != nil {
+			return fmt.Errorf("add override: %w", err)
+		}
+	}
+
+	if o.Kind == override.Split {
+		// The split index is over an expression, which ON CONFLICT cannot name
+		// alongside the pin index; a pair already split is simply returned.
+		err := tx.QueryRow(ctx, `
+SELECT id, created_at FROM museum_overrides
+WHERE kind = 'split' AND least(museum_id, other_id) = least($1::bigint, $2::bigint)
+  AND greatest(museum_id, other_id) = greatest($1::bigint, $2::bigint)`, o.MuseumID, o.OtherID,
+		).Scan(&o.ID, &o.CreatedAt)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("add override: %w", err)
+		}
+	}
+
+	if err := tx.QueryRow(ctx, stmt, o.Kind, o.MuseumID, o.OtherID, o.Field, value, encoded,
+		refID, nameKey, o.Reason, o.CreatedBy,
+	).Scan(&o.ID, &o.CreatedAt); err != nil {
+		return fmt.Errorf("add override: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `SELECT set_config('museum.source', $1, true)`,
+		fmt.Sprintf("override %d", o.ID)); err != nil {
+		return fmt.Errorf("add override: %w", err)
+	}
+	return nil
+}
+
+// pin records a pin and writes it to the museum. Touching the row is enough:
+// the trigger that holds every update to its pins does the rest.
+func pin(ctx context.Context, tx pgx.Tx, o *override.Override, subject models.Museum) error {
+	assignments, err := pinAssignments(o.Field, o.Value)
+	if err != nil {
+		return err
+	}
+	// A pinned name or country moves the row to a new identity, and the
+	// sources will go on offering it under the old one.
+	var ref *override.Ref
+	if o.Field == "name" || o.Field == "country" {
+		r := override.RefOf(subject)
+		ref = &r
+	}
+	if err := insertOverride(ctx, tx, o, assignments, ref); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE museums SET updated_at = now() WHERE id = $1`, o.MuseumID); err != nil {
+		return fmt.Errorf("apply pin: %w", err)
+	}
+	return nil
+}
+
+// pinAssignments turns a pinned value into the columns it sets.
+func pinAssignments(field string, value json.RawMessage) (map[string]any, error) {
+	v, err := override.Decode(field, value)
+	if err != nil {
+		return nil, err
+	}
+	switch field {
+	case "name":
+		name := validUTF8(v.(string))
+		return map[string]any{"name": name, "normalized": search.Normalize(name)}, nil
+	case "locality":
+		locality, _ := v.(string)
+		return map[string]any{"locality": nullIfEmpty(locality), "locality_normalized": search.Normalize(locality)}, nil
+	case "location":
+		if v == nil {
+			return map[string]any{"location": nil, "location_approximate": false}, nil
+		}
+		pos := v.([2]float64)
+		return map[string]any{
+			"location":             fmt.Sprintf("SRID=4326;POINT(%v %v)", pos[1], pos[0]),
+			"location_approximate": false,
+		}, nil
+	case "street", "postcode":
+		// Not nullable: an empty address part is an empty string.
+		text, _ := v.(string)
+		return map[string]any{field: text}, nil
+	default:
+		return map[string]any{field: v}, nil
+	}
+}
+
+// forceMerge records a merge and folds the other museum into the subject, by
+// the same field-by-field precedence the automatic merges use.
+func forceMerge(ctx context.Context, tx pgx.Tx, o *override.Override, other models.Museum) error {
+	var apart bool
+	if err := tx.QueryRow(ctx, `SELECT museums_kept_apart($1, $2)`, o.MuseumID, o.OtherID).Scan(&apart); err != nil {
+		return fmt.Errorf("force merge: %w", err)
+	}
+	if apart {
+		return fmt.Errorf("%w: a split keeps museums %d and %d apart; remove it first",
+			override.ErrConflict, o.MuseumID, o.OtherID)
+	}
+
+	ref := override.RefOf(other)
+	if err := insertOverride(ctx, tx, o, nil, &ref); err != nil {
+		return err
+	}
+
+	const fold = `
+WITH merged AS (
+    UPDATE museums k SET
+        aliases = (SELECT coalesce(array_agg(DISTINCT a), '{}')
+                     FROM unnest(k.aliases || d.aliases || ARRAY[d.name]) a WHERE a <> '' AND a <> k.name),
+        aliases_normalized = (SELECT coalesce(array_agg(DISTINCT a), '{}')
+                     FROM unnest(k.aliases_normalized || d.aliases_normalized || ARRAY[d.normalized]) a WHERE a <> ''),
+        sources       = (SELECT coalesce(array_agg(DISTINCT s), '{}') FROM unnest(k.sources || d.sources) s WHERE s <> ''),
+        classes       = (SELECT coalesce(array_agg(DISTINCT c), '{}') FROM unnest(k.classes || d.classes) c WHERE c <> ''),
+        search_text   = k.search_text || ' ' || d.search_text,
+        locality      = coalesce(nullif(k.locality, ''), d.locality),
+        description   = coalesce(nullif(k.description, ''), d.description),
+        website       = coalesce(nullif(k.website, ''), d.website),
+        wikipedia_url = coalesce(nullif(k.wikipedia_url, ''), d.wikipedia_url),
+        page_id       = coalesce(nullif(k.page_id, 0), d.page_id),
+        source_page   = coalesce(nullif(k.source_page, ''), d.source_page),
+        street        = coalesce(nullif(k.street, ''), d.street),
+        postcode      = coalesce(nullif(k.postcode, ''), d.postcode),
+        location      = coalesce(k.location, d.location),
+        verified      = k.verified OR d.verified,
+        sitelinks     = greatest(k.sitelinks, d.sitelinks),
+        updated_at    = now()
+    FROM museums d
+    WHERE k.id = $1 AND d.id = $2
+),
+events AS (
+    INSERT INTO outbox_events (aggregate, key, type, version, data)
+    SELECT 'museum', d.id::text, 'museum.merged', 1,
+           museum_event_data(d) || jsonb_build_object('merged_into', $1::bigint)
+    FROM museums d WHERE d.id = $2
+)
+DELETE FROM museums WHERE id = $2`
+
+	if _, err := tx.Exec(ctx, fold, o.MuseumID, o.OtherID); err != nil {
+		return fmt.Errorf("force merge: %w", err)
+	}
+	// Whatever was merged into the museum just folded in, and whatever was
+	// pinned on it, now belongs to the one it was folded into, by the rule the
+	// automatic merges follow.
+	const hand = `WITH final AS (SELECT $2::bigint AS victim, $1::bigint AS keeper),` + foldOverrides + `
+SELECT 1`
+	if _, err := tx.Exec(ctx, hand, o.MuseumID, o.OtherID); err != nil {
+		return fmt.Errorf("force merge: %w", err)
+	}
+	if err := applyPins(ctx, tx, []int64{o.MuseumID}); err != nil {
+		return fmt.Errorf("force merge: %w", err)
+	}
+	return nil
+}
+
+// RemoveOverride takes an override out of force, returning what it was.
+//
+// Nothing it did is undone. A pinned value stays until a source next changes
+// it; a merged or suppressed museum comes back only when a crawl offers it
+// again. That is deliberate: the undo of a merge is not knowable from here,
+// and the next crawl restores the sources' view anyway.
+func (s *Store) RemoveOverride(ctx context.Context, id int64) (override.Override, error) {
+	var o override.Override
+	err := s.pool.QueryRow(ctx, `
+DELETE FROM museum_overrides WHERE id = $1
+RETURNING id, kind, museum_id, coalesce(other_id, 0), coalesce(field, ''), reason, created_by, created_at`, id,
+	).Scan(&o.ID, &o.Kind, &o.MuseumID, &o.OtherID, &o.Field, &o.Reason, &o.CreatedBy, &o.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return override.Override{}, fmt.Errorf("override %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return override.Override{}, fmt.Errorf("remove override: %w", err)
+	}
+	return o, nil
+}
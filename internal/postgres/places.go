@@ -172,12 +172,23 @@ GROUP BY b.locality, b.centre, b.score`
 	// A weak match is worse than no match: resolving nonsense to a real town
 	// answers confidently with the wrong place, which is harder to notice than
 	// a 404.
-	if score < minLocalityScore {
+	radius, ok := trustedLocality(score, spread)
+	if !ok {
 		return Place{}, ErrPlaceUnknown
 	}
 
 	place.Query = query
 	place.Found = true
-	place.RadiusKm = math.Min(math.Max(spread, minLocalityRadiusKm), maxLocalityRadiusKm)
+	place.RadiusKm = radius
 	return place, nil
 }
+
+// trustedLocality reports whether a town matched with the given similarity can
+// be answered with, and the radius to search around it given how far its
+// museums spread.
+func trustedLocality(score, spreadKm float64) (radiusKm float64, ok bool) {
+	if score < minLocalityScore {
+		return 0, false
+	}
+	return math.Min(math.Max(spreadKm, minLocalityRadiusKm), maxLocalityRadiusKm), true
+}
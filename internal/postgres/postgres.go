@@ -13,7 +13,6 @@ package postgres
 
 import (
 	"context"
-	_ "embed"
 	"errors"
 	"fmt"
 	"log"
@@ -32,31 +31,45 @@ import (
 	"museum/pkg/exhibitions"
 )
 
-//go:embed schema.sql
-var schema string
-
 // ErrNotFound reports a record that does not exist.
 var ErrNotFound = errors.New("not found")
 
 // Store is a connection pool with the catalogue's queries on it.
 type Store struct {
 	pool *pgxpool.Pool
+	// replica, when attached, takes the catalogue's reads; see AttachReplica.
+	replica *replica
 
 	countsMu sync.RWMutex
 	counts   Counts
 	countsAt time.Time
+
+	// generations caches the catalogue generation by the pool it was read
+	// from; see Generation.
+	generationMu sync.RWMutex
+	generations  map[*pgxpool.Pool]cachedGeneration
+
+	geocodeHits geocodeHits
 }
 
-// Open connects and applies the schema.
-//
-// The schema is idempotent and applied on every start rather than through a
-// migration tool: there is one schema, it only grows, and a tool would be more
-// machinery than the problem needs.
+// Open connects. It does not touch the schema: that is MigrateUp's job, run by
+// "museum migrate up", and CheckSchema says whether it has been done.
 func Open(ctx context.Context, dsn string) (*Store, error) {
+	return OpenAs(ctx, dsn, "")
+}
+
+// OpenAs connects under a label naming the run, e.g. "crawl
+// 2026-03-01T02:00:00Z". The label is the session's application_name, so it is
+// what pg_stat_activity shows for the run's queries, and what the museum
+// history records as the source of every change the run makes.
+func OpenAs(ctx context.Context, dsn, source string) (*Store, error) {
 	config, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
 		return nil, fmt.Errorf("parse database URL: %w", err)
 	}
+	if source != "" {
+		config.ConnConfig.RuntimeParams["application_name"] = source
+	}
 	// A crawl writes from several goroutines and the API serves concurrently;
 	// the default of one connection per CPU is fine, but a floor avoids
 	// serialising on small machines.
@@ -84,62 +97,41 @@ SET pg_trgm.word_similarity_threshold = 0.55;`)
 		pool.Close()
 		return nil, fmt.Errorf("ping: %w", err)
 	}
-	if err := applySchema(ctx, pool); err != nil {
-		pool.Close()
-		return nil, err
-	}
-
 	return &Store{pool: pool}, nil
 }
 
-// schemaLock is an arbitrary constant identifying the schema-application lock.
-// Any process applying the schema takes the same one.
-const schemaLock = 0x6D757365756D01 // "museum" and a version byte
-
-// applySchema runs the schema with a lock held, so processes starting together
-// do not race each other through it.
-//
-// They did. Two sweepers started at the same moment deadlocked on the ALTER
-// TABLE and CREATE INDEX statements — Postgres detected it and failed one of
-// them outright, so the process exited rather than starting. The schema is
-// applied on every start by design, and this stack starts several processes at
-// once, so the race was always there; it only became easy to hit once there
-// was a service someone would sensibly run more than one of.
-//
-// An advisory lock rather than a migration table: the schema is idempotent and
-// the only thing needed is that two of them are not interleaved.
-func applySchema(ctx context.Context, pool *pgxpool.Pool) error {
-	tx, err := pool.Begin(ctx)
-	if err != nil {
-		return fmt.Errorf("apply schema: %w", err)
-	}
-	defer tx.Rollback(ctx)
-
-	// Held until the transaction ends, which is what serialises the appliers.
-	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", int64(schemaLock)); err != nil {
-		return fmt.Errorf("apply schema: %w", err)
+// Close writes the geocode cache hits still held and releases the pools.
+func (s *Store) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := s.flushGeocodeHits(ctx); err != nil {
+		log.Printf("postgres: %v", err)
 	}
-	if _, err := tx.Exec(ctx, schema); err != nil {
-		return fmt.Errorf("apply schema: %w", err)
+	cancel()
+	if s.replica != nil {
+		s.replica.pool.Close()
 	}
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("apply schema: %w", err)
-	}
-	return nil
+	s.pool.Close()
 }
 
-// Close releases the pool.
-func (s *Store) Close() { s.pool.Close() }
-
-// Ping checks the connection.
-func (s *Store) Ping(ctx context.Context) error { return s.pool.Ping(ctx) }
+// Ping checks the connection the catalogue's reads would use.
+func (s *Store) Ping(ctx context.Context) error { return s.reader(ctx).Ping(ctx) }
 
 // SaveMuseums upserts a batch, returning how many rows were written.
 //
 // Upserting on identity means a re-crawl updates records in place instead of
 // accumulating copies. A museum that gains a Wikidata id needs one step first —
 // see promoteWikidataIDs — because its identity changes when it does.
+//
+// Overrides come before either: a suppressed record is dropped and a redirected
+// one rewritten onto the museum it was merged into, and pinned fields are held
+// by the database whatever the batch says.
 func (s *Store) SaveMuseums(ctx context.Context, museums []models.Museum) (int64, error) {
+	rules, err := s.OverrideRules(ctx)
+	if err != nil {
+		return 0, err
+	}
+	museums = honour(rules, museums)
+
 	if err := s.promoteWikidataIDs(ctx, museums); err != nil {
 		return 0, err
 	}
@@ -243,6 +235,9 @@ ON CONFLICT (identity) DO UPDATE SET
 
 	written, err := s.execBatch(ctx, batch)
 	if err == nil {
+		if written > 0 {
+			s.bumpGeneration(ctx)
+		}
 		return written, nil
 	}
 
@@ -265,6 +260,9 @@ ON CONFLICT (identity) DO UPDATE SET
 	if rejected > 0 {
 		log.Printf("postgres: %d of %d museums rejected", rejected, len(queries))
 	}
+	if written > 0 {
+		s.bumpGeneration(ctx)
+	}
 	return written, nil
 }
 
@@ -348,6 +346,9 @@ FROM targets t WHERE m.id = t.id`
 // One pass merges one duplicate per keeper, so it repeats until the catalogue
 // is clean. Idempotent, and cheap once there is nothing left to do.
 //
+// A pair a split override keeps apart is never merged, and the overrides of
+// the row discarded go to the keeper, as foldOverrides describes.
+//
 // Aliases gained by a merge are not folded into search_text, which only Go can
 // build: the next crawl or reindex rewrites that column and picks them up.
 func (s *Store) MergeDuplicates(ctx context.Context) (int64, error) {
@@ -361,8 +362,12 @@ WITH pairs AS (
      AND dup.id <> keeper.id
     WHERE coalesce(keeper.wikidata_id, '') <> ''
       AND coalesce(dup.wikidata_id, '') = ''
+      AND NOT museums_kept_apart(keeper.id, dup.id)
     ORDER BY keeper.id, dup.id
 ),
+final AS (
+    SELECT drop_id AS victim, keep_id AS keeper FROM pairs
+),` + foldOverrides + `,
 merged AS (
     UPDATE museums k SET
         locality      = coalesce(nullif(k.locality, ''), d.locality),
@@ -382,22 +387,100 @@ merged AS (
         updated_at    = now()
     FROM museums d JOIN pairs ON pairs.drop_id = d.id
     WHERE k.id = pairs.keep_id
+),
+events AS (
+    INSERT INTO outbox_events (aggregate, key, type, version, data)
+    SELECT 'museum', d.id::text, 'museum.merged', 1,
+           museum_event_data(d) || jsonb_build_object('merged_into', pairs.keep_id)
+    FROM pairs JOIN museums d ON d.id = pairs.drop_id
+    ORDER BY d.id
 )
-DELETE FROM museums WHERE id IN (SELECT drop_id FROM pairs)`
+DELETE FROM museums d USING final f WHERE d.id = f.victim
+RETURNING f.keeper`
 
 	var removed int64
 	for {
-		tag, err := s.pool.Exec(ctx, stmt)
+		n, err := s.runMerge(ctx, stmt)
 		if err != nil {
 			return removed, fmt.Errorf("merge duplicates: %w", err)
 		}
-		if tag.RowsAffected() == 0 {
+		if n == 0 {
+			if removed > 0 {
+				s.bumpGeneration(ctx)
+			}
 			return removed, nil
 		}
-		removed += tag.RowsAffected()
+		removed += n
 	}
 }
 
+// foldOverrides is the part of every merge statement that hands the overrides
+// of the museums it folds away, the victims of its final CTE, to their
+// keepers. A merge moves, so what was folded into a victim stays folded in.
+// So does a pin, so a correction made by hand outlives the row it was made on
+// rather than being overwritten by the keeper's value.
+//
+// Where pins conflict, the keeper's own pin wins: it was made about the
+// museum that survives. Among victims folding into one keeper that pin the
+// same field, the most recent pin wins. The pins that lose are dropped.
+const foldOverrides = `
+victim_pins AS (
+    SELECT o.id,
+           NOT EXISTS (SELECT 1 FROM museum_overrides k
+                       WHERE k.kind = 'pin' AND k.museum_id = f.keeper AND k.field = o.field)
+           AND o.id = max(o.id) OVER (PARTITION BY f.keeper, o.field) AS moves
+    FROM museum_overrides o JOIN final f ON o.museum_id = f.victim
+    WHERE o.kind = 'pin'
+),
+moved_overrides AS (
+    UPDATE museum_overrides o SET museum_id = f.keeper
+    FROM final f
+    WHERE o.museum_id = f.victim
+      AND (o.kind = 'merge' OR o.id IN (SELECT id FROM victim_pins WHERE moves))
+),
+dropped_pins AS (
+    DELETE FROM museum_overrides WHERE id IN (SELECT id FROM victim_pins WHERE NOT moves)
+)`
+
+// runMerge runs one pass of a merge statement that returns the keeper of each
+// museum it deleted, and returns how many it deleted. The keepers given pins
+// by the pass are then written again in the same transaction, because the
+// trigger applying pins sees the overrides as they stood before the statement
+// moved them.
+func (s *Store) runMerge(ctx context.Context, stmt string) (int64, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, stmt)
+	if err != nil {
+		return 0, err
+	}
+	keepers, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return 0, err
+	}
+	if len(keepers) == 0 {
+		return 0, nil
+	}
+	if err := applyPins(ctx, tx, keepers); err != nil {
+		return 0, err
+	}
+	return int64(len(keepers)), tx.Commit(ctx)
+}
+
+// applyPins writes each of the museums that has a pin again, so the trigger
+// holds it to its pins.
+func applyPins(ctx context.Context, tx pgx.Tx, ids []int64) error {
+	_, err := tx.Exec(ctx, `
+UPDATE museums m SET updated_at = now()
+WHERE m.id = ANY($1)
+  AND EXISTS (SELECT 1 FROM museum_overrides o WHERE o.kind = 'pin' AND o.museum_id = m.id)`, ids)
+	return err
+}
+
 // searchText is everything a name query should match, in one normalised string:
 // the name, the alternative names, and the town.
 //
@@ -490,6 +573,9 @@ type Hit struct {
 	DistanceKm float64
 	// Score is set by search queries.
 	Score float64
+	// RetiredAt is set on a museum no source offers any more. Only MuseumByID
+	// returns one: every other query leaves retired museums out.
+	RetiredAt *time.Time
 }
 
 // Page is a slice of results together with the size of the whole set.
@@ -527,6 +613,7 @@ SELECT id, name, coalesce(country,''), coalesce(locality,''), coalesce(descripti
        ST_Distance(location, $1::geography) / 1000.0 AS distance_km
 FROM museums
 WHERE location IS NOT NULL
+  AND retired_at IS NULL
   AND ST_DWithin(location, $1::geography, $2)
   AND (NOT $5::boolean OR verified)
 ORDER BY location <-> $1::geography, id
@@ -534,7 +621,7 @@ LIMIT $3 OFFSET $4`
 
 	point := fmt.Sprintf("SRID=4326;POINT(%v %v)", lon, lat)
 
-	rows, err := s.pool.Query(ctx, stmt, point, radiusKm*1000, limit, offset, verifiedOnly)
+	rows, err := s.reader(ctx).Query(ctx, stmt, point, radiusKm*1000, limit, offset, verifiedOnly)
 	if err != nil {
 		return Page{}, fmt.Errorf("nearby: %w", err)
 	}
@@ -558,18 +645,27 @@ LIMIT $3 OFFSET $4`
 // words inside the name, which is what a person means when they type one word
 // of a museum's title.
 //
-// Every clause in the WHERE is index-backed. Scoring may scan the rows those
-// clauses selected, but nothing may scan the table: an earlier version matched
-// query words with position(), which no index can serve, and the query went
-// from under two milliseconds to over five hundred.
+// Full-text search over the museum's document reaches what no spelling of the
+// name can: "steamship museum gothenburg" finds a ship whose name is a
+// province, through its classes and its description. It also gives the query
+// its syntax. A "quoted phrase" must occur, a -word must not, and both apply to
+// every match, however it was found.
+//
+// Every clause in the WHERE that finds rows is index-backed. Scoring, and the
+// phrase and exclusion filters, may scan the rows those clauses selected, but
+// nothing may scan the table: an earlier version matched query words with
+// position(), which no index can serve, and the query went from under two
+// milliseconds to over five hundred.
 func (s *Store) Search(ctx context.Context, query string, limit, offset int) (Page, error) {
-	normalized := search.Normalize(query)
+	parsed := search.ParseQuery(query)
+	normalized := search.Normalize(parsed.Text)
 	if normalized == "" {
 		return Page{}, nil
 	}
 
 	const stmt = `
-WITH q AS (SELECT $1::text AS term)
+WITH q AS (SELECT $1::text AS term, catalogue_tsquery($4) AS fts,
+                  catalogue_tsquery($5) AS phrases, catalogue_tsquery($6) AS excluded)
 SELECT id, name, coalesce(country,''), coalesce(locality,''), coalesce(description,''),
        coalesce(website,''), coalesce(wikipedia_url,''), coalesce(wikidata_id,''),
        aliases, sources, classes, verified, street, postcode, location_approximate,
@@ -624,17 +720,28 @@ SELECT id, name, coalesce(country,''), coalesce(locality,''), coalesce(descripti
          -- 1.0, a museum with a single article about 0.14.
          + 0.2 * ln(1 + greatest(sitelinks, 0))
          + CASE WHEN location IS NOT NULL THEN 0.01 ELSE 0 END
+         -- Every word of the query in the document. Worth a substring match on
+         -- the name, so a museum found only by what it is described as ranks
+         -- among the near-misses rather than under all of them; the rank adds
+         -- up to as much again, and most where the words are in the name.
+         + CASE WHEN document @@ q.fts
+                THEN 0.5 + ts_rank_cd(document, q.fts, 32) ELSE 0 END
        ) AS score
 FROM museums, q
-WHERE normalized % q.term
-   OR q.term <% normalized
-   OR normalized LIKE q.term || '%'
-   OR search_text % q.term
-   OR aliases_normalized @> ARRAY[q.term]
+WHERE (normalized % q.term
+       OR q.term <% normalized
+       OR normalized LIKE q.term || '%'
+       OR search_text % q.term
+       OR aliases_normalized @> ARRAY[q.term]
+       OR document @@ q.fts)
+  AND ($5 = '' OR document @@ q.phrases)
+  AND ($6 = '' OR NOT document @@ q.excluded)
+  AND retired_at IS NULL
 ORDER BY score DESC, length(normalized), name, id
 LIMIT $2 OFFSET $3`
 
-	rows, err := s.pool.Query(ctx, stmt, normalized, limit, offset)
+	rows, err := s.reader(ctx).Query(ctx, stmt, normalized, limit, offset,
+		query, parsed.PhraseQuery(), parsed.ExclusionQuery())
 	if err != nil {
 		return Page{}, fmt.Errorf("search: %w", err)
 	}
@@ -645,6 +752,8 @@ LIMIT $2 OFFSET $3`
 
 // MuseumByID returns one museum, so a result can be linked to and fetched
 // again. It accepts the numeric id or a Wikidata "Q…" identifier.
+//
+// A retired museum is still returned, marked as such: its links outlive it.
 func (s *Store) MuseumByID(ctx context.Context, id string) (Hit, error) {
 	const stmt = `
 SELECT id, name, coalesce(country,''), coalesce(locality,''), coalesce(description,''),
@@ -661,7 +770,7 @@ LIMIT 1`
 		numeric = &parsed
 	}
 
-	rows, err := s.pool.Query(ctx, stmt, numeric, id)
+	rows, err := s.reader(ctx).Query(ctx, stmt, numeric, id)
 	if err != nil {
 		return Hit{}, fmt.Errorf("museum %q: %w", id, err)
 	}
@@ -674,7 +783,16 @@ LIMIT 1`
 	if len(page.Hits) == 0 {
 		return Hit{}, fmt.Errorf("museum %q: %w", id, ErrNotFound)
 	}
-	return page.Hits[0], nil
+
+	// Read apart from the row because scanPage is shared with the queries that
+	// never return a retired museum. By primary key, so it costs one round trip.
+	hit := page.Hits[0]
+	if err := s.reader(ctx).QueryRow(ctx,
+		`SELECT retired_at FROM museums WHERE id = $1`, hit.ID,
+	).Scan(&hit.RetiredAt); err != nil {
+		return Hit{}, fmt.Errorf("museum %q: %w", id, err)
+	}
+	return hit, nil
 }
 
 // scanHits reads a result set into museums. withDistance says whether the final
@@ -769,7 +887,7 @@ SELECT
     (SELECT max(updated_at) FROM museums)`
 
 	var counts Counts
-	err := s.pool.QueryRow(ctx, stmt).Scan(
+	err := s.reader(ctx).QueryRow(ctx, stmt).Scan(
 		&counts.Museums, &counts.WithCoordinates, &counts.WithWebsite,
 		&counts.Exhibitions, &counts.Countries, &counts.LastUpdated)
 	if err != nil {
@@ -797,13 +915,17 @@ func (s *Store) execBatch(ctx context.Context, batch *pgx.Batch) (int64, error)
 // SaveExhibitions upserts scraped listings, keyed by URL.
 func (s *Store) SaveExhibitions(ctx context.Context, found []exhibitions.Exhibition) (int64, error) {
 	const stmt = `
-INSERT INTO exhibitions (url, title, museum, museum_wikidata_id, starts_on, ends_on, location, source_page, scraped_at, permanent, site, first_seen_at, last_seen_at)
+INSERT INTO exhibitions (url, title, museum, museum_wikidata_id, starts_on, ends_on, location, source_page, scraped_at, permanent, site, first_seen_at, last_seen_at, text_config)
 VALUES ($1, $2, $3, $4, $5, $6,
         CASE WHEN $7::double precision IS NULL THEN NULL
              ELSE ST_SetSRID(ST_MakePoint($8::double precision, $7::double precision), 4326)::geography END,
-        $9, $10, $11, $12, $10, $10)
+        $9, $10, $11, $12, $10, $10,
+        -- The title is stemmed in the language of the venue's country.
+        coalesce((SELECT text_search_config(m.country) FROM museums m WHERE m.identity = nullif($4, '')),
+                 'simple'))
 ON CONFLICT (url) DO UPDATE SET
     title      = EXCLUDED.title,
+    text_config = EXCLUDED.text_config,
     museum     = EXCLUDED.museum,
     starts_on  = EXCLUDED.starts_on,
     ends_on    = EXCLUDED.ends_on,
@@ -864,6 +986,9 @@ ON CONFLICT (url) DO UPDATE SET
 
 	written, err := s.execBatch(ctx, batch)
 	if err == nil {
+		if written > 0 {
+			s.bumpGeneration(ctx)
+		}
 		return written, nil
 	}
 
@@ -887,6 +1012,9 @@ ON CONFLICT (url) DO UPDATE SET
 	if rejected > 0 {
 		log.Printf("postgres: %d of %d exhibitions rejected", rejected, len(queries))
 	}
+	if written > 0 {
+		s.bumpGeneration(ctx)
+	}
 	return written, nil
 }
 
@@ -894,6 +1022,9 @@ ON CONFLICT (url) DO UPDATE SET
 type ExhibitionHit struct {
 	exhibitions.Exhibition
 	DistanceKm float64
+	// Archived says the exhibition was read from the archive. Only the reads
+	// that reach into the past ever set it.
+	Archived bool
 }
 
 // ExhibitionsNearby returns what is on show within radiusKm, soonest to close
@@ -921,7 +1052,7 @@ LIMIT $4`
 
 	point := fmt.Sprintf("SRID=4326;POINT(%v %v)", lon, lat)
 
-	rows, err := s.pool.Query(ctx, stmt, point, radiusKm*1000, includeUpcoming, limit)
+	rows, err := s.reader(ctx).Query(ctx, stmt, point, radiusKm*1000, includeUpcoming, limit)
 	if err != nil {
 		return nil, fmt.Errorf("exhibitions nearby: %w", err)
 	}
@@ -965,14 +1096,20 @@ LIMIT $4`
 // near-misses that are most of what people type. The museum's name is searchable
 // too, so "hasselblad" finds what is on at the Hasselblad Center, but it scores
 // below the title so a show's own name always wins.
+//
+// Full-text search adds the words of a title in whatever order they were typed,
+// stemmed in the venue's language, so "dutch paintings" reaches "Painting in
+// the Dutch Golden Age". Phrases and exclusions apply as they do to museums.
 func (s *Store) SearchExhibitions(ctx context.Context, query string, lat, lon, radiusKm float64, near, includeUpcoming bool, limit, offset int) ([]ExhibitionHit, int64, error) {
-	term := strings.ToLower(strings.TrimSpace(query))
+	parsed := search.ParseQuery(query)
+	term := strings.ToLower(strings.TrimSpace(parsed.Text))
 	if term == "" {
 		return nil, 0, nil
 	}
 
 	const stmt = `
-WITH q AS (SELECT $1::text AS term)
+WITH q AS (SELECT $1::text AS term, catalogue_tsquery($8) AS fts,
+                  catalogue_tsquery($9) AS phrases, catalogue_tsquery($10) AS excluded)
 SELECT url, title, coalesce(museum,''), coalesce(museum_wikidata_id,''),
        starts_on, ends_on, coalesce(source_page,''), scraped_at, permanent,
        ST_Y(location::geometry), ST_X(location::geometry),
@@ -984,7 +1121,9 @@ SELECT url, title, coalesce(museum,''), coalesce(museum_wikidata_id,''),
              -- The venue matches too, but never as strongly as the show.
              WHEN position(q.term in lower(coalesce(museum, ''))) > 0 THEN 0.5
              ELSE 0 END
-        + similarity(lower(title), q.term)) AS score
+        + similarity(lower(title), q.term)
+        + CASE WHEN document @@ q.fts
+               THEN 0.5 + ts_rank_cd(document, q.fts, 32) ELSE 0 END) AS score
 FROM exhibitions, q
 WHERE (ends_on IS NULL OR ends_on >= current_date)
   AND ($4 OR starts_on IS NULL OR starts_on <= current_date)
@@ -997,13 +1136,17 @@ WHERE (ends_on IS NULL OR ends_on >= current_date)
   -- all it needs to be.
   AND (lower(title) % q.term
        OR position(q.term in lower(title)) > 0
-       OR position(q.term in lower(coalesce(museum, ''))) > 0)
+       OR position(q.term in lower(coalesce(museum, ''))) > 0
+       OR document @@ q.fts)
+  AND ($9 = '' OR document @@ q.phrases)
+  AND ($10 = '' OR NOT document @@ q.excluded)
 ORDER BY score DESC, distance_km, ends_on NULLS LAST
 LIMIT $6 OFFSET $7`
 
 	point := fmt.Sprintf("SRID=4326;POINT(%v %v)", lon, lat)
 
-	rows, err := s.pool.Query(ctx, stmt, term, near, point, includeUpcoming, radiusKm*1000, limit, offset)
+	rows, err := s.reader(ctx).Query(ctx, stmt, term, near, point, includeUpcoming, radiusKm*1000, limit, offset,
+		query, parsed.PhraseQuery(), parsed.ExclusionQuery())
 	if err != nil {
 		return nil, 0, fmt.Errorf("search exhibitions %q: %w", query, err)
 	}
@@ -1035,20 +1178,25 @@ LIMIT $6 OFFSET $7`
 	return hits, total, rows.Err()
 }
 
-// EachMuseum streams the whole catalogue, for the audit.
+// EachMuseum streams the whole catalogue, for the audit and the sitemap.
 //
 // Genuinely streams: it calls fn as each row arrives. It used to gather every
 // row into a slice first, so "streaming" 85,000 museums allocated 250 MB and
 // the first callback fired only once the last row had been read.
-func (s *Store) EachMuseum(ctx context.Context, fn func(models.Museum)) error {
+//
+// Rows arrive in id order, so two passes over an unchanged catalogue see the
+// museums in the same sequence — which is what lets the sitemap be split into
+// pages that stay put between requests.
+func (s *Store) EachMuseum(ctx context.Context, fn func(id int64, museum models.Museum)) error {
 	const stmt = `
-SELECT name, coalesce(country,''), coalesce(locality,''), coalesce(description,''),
+SELECT id, name, coalesce(country,''), coalesce(locality,''), coalesce(description,''),
        coalesce(website,''), coalesce(wikipedia_url,''), coalesce(wikidata_id,''),
        aliases, sources, classes, verified, street, postcode,
        ST_Y(location::geometry), ST_X(location::geometry)
-FROM museums`
+FROM museums
+ORDER BY id`
 
-	rows, err := s.pool.Query(ctx, stmt)
+	rows, err := s.reader(ctx).Query(ctx, stmt)
 	if err != nil {
 		return fmt.Errorf("scan catalogue: %w", err)
 	}
@@ -1056,12 +1204,13 @@ FROM museums`
 
 	for rows.Next() {
 		var (
+			id               int64
 			museum           models.Museum
 			lat, lon         *float64
 			street, postcode string
 		)
 		if err := rows.Scan(
-			&museum.Name, &museum.Country, &museum.Locality, &museum.Description,
+			&id, &museum.Name, &museum.Country, &museum.Locality, &museum.Description,
 			&museum.Website, &museum.WikipediaURL, &museum.WikidataID,
 			&museum.AlsoKnownAs, &museum.Sources, &museum.Classes, &museum.Verified,
 			&street, &postcode, &lat, &lon,
@@ -1072,11 +1221,30 @@ FROM museums`
 		if lat != nil && lon != nil {
 			museum.Latitude, museum.Longitude = *lat, *lon
 		}
-		fn(museum)
+		fn(id, museum)
 	}
 	return rows.Err()
 }
 
+// MuseumIDs returns the ids of at most limit current museums after the id
+// after, in order. It is a keyset page, so the last page costs what the first
+// does; retired museums are left out.
+func (s *Store) MuseumIDs(ctx context.Context, after int64, limit int) ([]int64, error) {
+	rows, err := s.reader(ctx).Query(ctx, `
+SELECT id FROM museums
+WHERE retired_at IS NULL AND id > $1
+ORDER BY id
+LIMIT $2`, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("museum ids: %w", err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, fmt.Errorf("museum ids: %w", err)
+	}
+	return ids, nil
+}
+
 // Coverage describes how well an area has been scraped for exhibitions.
 //
 // It exists because an empty result was ambiguous in the worst way: a request
@@ -1129,7 +1297,7 @@ WHERE location IS NOT NULL AND ST_DWithin(location, $1::geography, $2)`
 	point := fmt.Sprintf("SRID=4326;POINT(%v %v)", lon, lat)
 
 	var coverage Coverage
-	if err := s.pool.QueryRow(ctx, stmt, point, radiusKm*1000).Scan(
+	if err := s.reader(ctx).QueryRow(ctx, stmt, point, radiusKm*1000).Scan(
 		&coverage.MuseumsInArea, &coverage.MuseumsWithSite, &coverage.LastScraped,
 	); err != nil {
 		return Coverage{}, fmt.Errorf("exhibition coverage: %w", err)
@@ -1193,9 +1361,85 @@ SET location = ST_SetSRID(ST_MakePoint($3::double precision, $2::double precisio
     updated_at = now()
 WHERE id = $1`
 
-	if _, err := s.pool.Exec(ctx, stmt, id, lat, lon, approximate); err != nil {
+	tag, err := s.pool.Exec(ctx, stmt, id, lat, lon, approximate)
+	if err != nil {
 		return fmt.Errorf("set location for %d: %w", id, err)
 	}
+	if tag.RowsAffected() > 0 {
+		s.bumpGeneration(ctx)
+	}
+	return nil
+}
+
+// Unaddressed is a museum the catalogue can place but has no address for.
+type Unaddressed struct {
+	ID         int64
+	Name       string
+	Locality   string
+	Latitude   float64
+	Longitude  float64
+	SourcePage string
+}
+
+// UnaddressedMuseums returns located museums with neither a street nor a
+// postcode that have not been reverse geocoded yet, optionally narrowed to a
+// town or country. A museum whose position gave no address is marked by
+// SetAddress and not offered again.
+func (s *Store) UnaddressedMuseums(ctx context.Context, locality, country string, limit int) ([]Unaddressed, error) {
+	const stmt = `
+SELECT id, name, coalesce(locality, ''),
+       ST_Y(location::geometry), ST_X(location::geometry), coalesce(source_page, '')
+FROM museums
+WHERE location IS NOT NULL
+  AND street = '' AND postcode = ''
+  AND address_checked_at IS NULL
+  AND retired_at IS NULL
+  AND ($1 = '' OR locality ILIKE '%' || $1 || '%')
+  AND ($2 = '' OR country ILIKE $2)
+ORDER BY sitelinks DESC, id
+LIMIT $3`
+
+	rows, err := s.pool.Query(ctx, stmt, locality, country, limit)
+	if err != nil {
+		return nil, fmt.Errorf("unaddressed museums: %w", err)
+	}
+	defer rows.Close()
+
+	var found []Unaddressed
+	for rows.Next() {
+		var u Unaddressed
+		if err := rows.Scan(&u.ID, &u.Name, &u.Locality, &u.Latitude, &u.Longitude, &u.SourcePage); err != nil {
+			return nil, fmt.Errorf("scan unaddressed: %w", err)
+		}
+		found = append(found, u)
+	}
+	return found, rows.Err()
+}
+
+// SetAddress records the address a museum's position was found to have, and
+// that it was looked for: a zero address marks the museum as asked about so
+// it is not offered up again. A street, postcode or town the museum already
+// has is kept.
+func (s *Store) SetAddress(ctx context.Context, id int64, address models.Address) error {
+	const stmt = `
+UPDATE museums
+SET street = coalesce(nullif(street, ''), $2),
+    postcode = coalesce(nullif(postcode, ''), $3),
+    locality = coalesce(nullif(locality, ''), nullif($4, '')),
+    locality_normalized = CASE WHEN coalesce(locality, '') = '' THEN $5 ELSE locality_normalized END,
+    address_checked_at = now(),
+    updated_at = now()
+WHERE id = $1`
+
+	locality := validUTF8(address.Locality())
+	tag, err := s.pool.Exec(ctx, stmt, id, validUTF8(address.Street()), validUTF8(address.Postcode),
+		locality, search.Normalize(locality))
+	if err != nil {
+		return fmt.Errorf("set address for %d: %w", id, err)
+	}
+	if tag.RowsAffected() > 0 && !address.IsZero() {
+		s.bumpGeneration(ctx)
+	}
 	return nil
 }
 
@@ -1367,6 +1611,7 @@ WHERE m.location IS NULL
 	if err := tx.Commit(ctx); err != nil {
 		return 0, 0, fmt.Errorf("place at town centres: %w", err)
 	}
+	s.bumpGeneration(ctx)
 	return placed, discarded, nil
 }
 
@@ -1391,12 +1636,13 @@ func (s *Store) Points(ctx context.Context, west, south, east, north float64, ha
 SELECT id, ST_Y(location::geometry), ST_X(location::geometry)
 FROM museums
 WHERE location IS NOT NULL
+  AND retired_at IS NULL
   AND (NOT $1::boolean
        OR ST_Intersects(location::geometry, ST_MakeEnvelope($2, $3, $4, $5, 4326)))
 ORDER BY sitelinks DESC, id
 LIMIT $6`
 
-	rows, err := s.pool.Query(ctx, stmt, hasBox, west, south, east, north, limit)
+	rows, err := s.reader(ctx).Query(ctx, stmt, hasBox, west, south, east, north, limit)
 	if err != nil {
 		return nil, fmt.Errorf("points: %w", err)
 	}
@@ -1413,6 +1659,137 @@ LIMIT $6`
 	return points, rows.Err()
 }
 
+// Cluster is a group of museums drawn as one mark.
+type Cluster struct {
+	// Lat and Lon are the centre of the museums in the cluster, not of the grid
+	// cell they fell into, so a cluster sits on the city rather than on a
+	// corner of an arbitrary square.
+	Lat float64
+	Lon float64
+	// Museums is how many the cluster stands for.
+	Museums int64
+	// RepresentativeID and RepresentativeName are the cluster's most prominent
+	// museum: what a label can name, and what a click can open.
+	RepresentativeID   int64
+	RepresentativeName string
+	// Exhibitions is how many listings are on show or coming up in the same
+	// cell, counted from the exhibitions' own positions.
+	Exhibitions int64
+}
+
+// Clustering is the answer to a map view: either every museum in it, or the
+// view grouped into clusters when it holds too many to draw one by one.
+type Clustering struct {
+	// Points is set when the view was sparse enough to send in full.
+	Points []Point
+	// Clusters is set otherwise.
+	Clusters []Cluster
+	// Museums is how many placed museums the view holds, whichever of the two
+	// was returned.
+	Museums int64
+}
+
+// PointClusters answers a map view, grouping museums into a grid of cellDegrees
+// when the view holds more than rawBelow of them.
+//
+// Points alone was forced to choose between truncating and shipping megabytes:
+// at world scale the catalogue holds 154,000 placed museums, and the only way
+// to fit them in one response was to drop all but the 40,000 most prominent —
+// which also dropped every exhibition-bearing venue that happened to be
+// obscure. Grouping in the database sends a few thousand rows for the world
+// while still accounting for every museum in it.
+//
+// Grid snapping rather than ST_ClusterWithin: the grid is one pass over the
+// index, and a cell is stable from one pan to the next, so a cluster does not
+// change shape as the view slides across it. ST_ClusterWithin is quadratic in
+// the worst case and regroups everything on every request.
+func (s *Store) PointClusters(ctx context.Context, west, south, east, north float64, hasBox bool, cellDegrees float64, rawBelow, limit int) (Clustering, error) {
+	const count = `
+SELECT count(*)
+FROM museums
+WHERE location IS NOT NULL
+  AND retired_at IS NULL
+  AND (NOT $1::boolean
+       OR ST_Intersects(location::geometry, ST_MakeEnvelope($2, $3, $4, $5, 4326)))`
+
+	var clustering Clustering
+	if err := s.reader(ctx).QueryRow(ctx, count, hasBox, west, south, east, north).Scan(&clustering.Museums); err != nil {
+		return Clustering{}, fmt.Errorf("point clusters: %w", err)
+	}
+
+	// Sparse enough to draw one by one, so there is nothing to gain from
+	// grouping — and a cluster of one is only a point with more fields.
+	if clustering.Museums <= int64(rawBelow) {
+		points, err := s.Points(ctx, west, south, east, north, hasBox, rawBelow)
+		if err != nil {
+			return Clustering{}, err
+		}
+		clustering.Points = points
+		return clustering, nil
+	}
+
+	// The cell is computed from the raw coordinates with floor() rather than
+	// with ST_SnapToGrid, which rounds to the nearest node and so puts the
+	// cell boundary through the middle of whatever sits on a grid line.
+	//
+	// Exhibitions are counted by their own position in the same grid rather
+	// than joined to their museum: a listing carries a position but not always
+	// a museum the catalogue can identify, and joining by name loses the ones
+	// a merge has since renamed.
+	const stmt = `
+WITH placed AS (
+    SELECT id, name, sitelinks,
+           ST_Y(location::geometry) AS lat, ST_X(location::geometry) AS lon
+    FROM museums
+    WHERE location IS NOT NULL
+      AND retired_at IS NULL
+      AND (NOT $1::boolean
+           OR ST_Intersects(location::geometry, ST_MakeEnvelope($2, $3, $4, $5, 4326)))
+),
+cells AS (
+    SELECT floor(lon / $6) AS cx, floor(lat / $6) AS cy,
+           avg(lat) AS lat, avg(lon) AS lon, count(*) AS museums,
+           (array_agg(id ORDER BY sitelinks DESC, id))[1] AS representative_id,
+           (array_agg(name ORDER BY sitelinks DESC, id))[1] AS representative_name
+    FROM placed
+    GROUP BY 1, 2
+),
+shows AS (
+    SELECT floor(ST_X(location::geometry) / $6) AS cx,
+           floor(ST_Y(location::geometry) / $6) AS cy,
+           count(*) AS exhibitions
+    FROM exhibitions
+    WHERE location IS NOT NULL
+      AND retired_at IS NULL
+      AND (ends_on IS NULL OR ends_on >= current_date)
+      AND (NOT $1::boolean
+           OR ST_Intersects(location::geometry, ST_MakeEnvelope($2, $3, $4, $5, 4326)))
+    GROUP BY 1, 2
+)
+SELECT c.lat, c.lon, c.museums, c.representative_id, c.representative_name,
+       coalesce(s.exhibitions, 0)
+FROM cells c
+LEFT JOIN shows s ON s.cx = c.cx AND s.cy = c.cy
+ORDER BY c.museums DESC, c.representative_id
+LIMIT $7`
+
+	rows, err := s.reader(ctx).Query(ctx, stmt, hasBox, west, south, east, north, cellDegrees, limit)
+	if err != nil {
+		return Clustering{}, fmt.Errorf("point clusters: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c Cluster
+		if err := rows.Scan(&c.Lat, &c.Lon, &c.Museums,
+			&c.RepresentativeID, &c.RepresentativeName, &c.Exhibitions); err != nil {
+			return Clustering{}, fmt.Errorf("scan cluster: %w", err)
+		}
+		clustering.Clusters = append(clustering.Clusters, c)
+	}
+	return clustering, rows.Err()
+}
+
 // MergeDuplicateExhibitions folds repeated listings of one event into a single
 // row spanning all of its dates, and reports how many rows it removed.
 //
@@ -1457,6 +1834,9 @@ WHERE e.museum = g.museum
 	if err != nil {
 		return 0, fmt.Errorf("merge duplicate exhibitions: %w", err)
 	}
+	if tag.RowsAffected() > 0 {
+		s.bumpGeneration(ctx)
+	}
 	return tag.RowsAffected(), nil
 }
 
@@ -1510,6 +1890,9 @@ func (s *Store) PruneNavigationListings(ctx context.Context) (int64, error) {
 	if err != nil {
 		return 0, fmt.Errorf("prune navigation: %w", err)
 	}
+	if tag.RowsAffected() > 0 {
+		s.bumpGeneration(ctx)
+	}
 	return tag.RowsAffected(), nil
 }
 
@@ -1603,6 +1986,8 @@ WITH candidates AS (
       AND coalesce(a.wikidata_id, '') <> ''
       AND (a.location IS NULL OR b.location IS NULL
            OR ST_DWithin(a.location, b.location, 1000))
+      -- Nor against a split made by hand.
+      AND NOT museums_kept_apart(a.id, b.id)
 ),
 unambiguous AS (
     SELECT c.* FROM candidates c
@@ -1618,7 +2003,7 @@ resolved AS (
 final AS (
     SELECT r.victim, r.keeper FROM resolved r
     WHERE NOT EXISTS (SELECT 1 FROM resolved o WHERE o.victim = r.keeper)
-),
+),` + foldOverrides + `,
 merged AS (
     UPDATE museums m SET
         aliases = (SELECT coalesce(array_agg(DISTINCT a), '{}')
@@ -1643,21 +2028,32 @@ merged AS (
         updated_at    = now()
     FROM final f JOIN museums v ON v.id = f.victim
     WHERE m.id = f.keeper
+),
+events AS (
+    INSERT INTO outbox_events (aggregate, key, type, version, data)
+    SELECT 'museum', v.id::text, 'museum.merged', 1,
+           museum_event_data(v) || jsonb_build_object('merged_into', f.keeper)
+    FROM final f JOIN museums v ON v.id = f.victim
+    ORDER BY v.id
 )
-DELETE FROM museums WHERE id IN (SELECT victim FROM final)`
+DELETE FROM museums d USING final f WHERE d.id = f.victim
+RETURNING f.keeper`
 
 	var removed int64
 	// Repeated because one pass merges one victim per keeper, and a museum
 	// recorded under three names needs two.
 	for {
-		tag, err := s.pool.Exec(ctx, stmt)
+		n, err := s.runMerge(ctx, stmt)
 		if err != nil {
 			return removed, fmt.Errorf("merge alias variants: %w", err)
 		}
-		if tag.RowsAffected() == 0 {
+		if n == 0 {
+			if removed > 0 {
+				s.bumpGeneration(ctx)
+			}
 			return removed, nil
 		}
-		removed += tag.RowsAffected()
+		removed += n
 	}
 }
 
@@ -1678,6 +2074,7 @@ DELETE FROM museums WHERE id IN (SELECT victim FROM final)`
 // The radius is deliberately small. Two genuinely different museums can share a
 // building, and 150 m is close enough that a false merge needs both the same
 // words and the same doorway.
+// Where that is still wrong, a split override keeps the pair apart.
 func (s *Store) MergeNameVariants(ctx context.Context) (int64, error) {
 	const stmt = `
 WITH tokens AS (
@@ -1703,6 +2100,7 @@ pairs AS (
     -- The better-documented record survives, so the merge keeps the row more
     -- of the catalogue already points at.
     WHERE (a.sitelinks, -a.id) > (b.sitelinks, -b.id)
+      AND NOT museums_kept_apart(a.id, b.id)
 ),
 -- One keeper per victim: a cluster of three collapses onto a single row rather
 -- than each pair merging separately and leaving fragments.
@@ -1712,7 +2110,7 @@ resolved AS (
 final AS (
     SELECT r.victim, r.keeper FROM resolved r
     WHERE NOT EXISTS (SELECT 1 FROM resolved o WHERE o.victim = r.keeper)
-),
+),` + foldOverrides + `,
 merged AS (
     UPDATE museums m SET
         aliases = (SELECT coalesce(array_agg(DISTINCT a), '{}')
@@ -1733,12 +2131,23 @@ merged AS (
     FROM final f JOIN museums v ON v.id = f.victim
     WHERE m.id = f.keeper
     RETURNING m.id
+),
+events AS (
+    INSERT INTO outbox_events (aggregate, key, type, version, data)
+    SELECT 'museum', v.id::text, 'museum.merged', 1,
+           museum_event_data(v) || jsonb_build_object('merged_into', f.keeper)
+    FROM final f JOIN museums v ON v.id = f.victim
+    ORDER BY v.id
 )
-DELETE FROM museums WHERE id IN (SELECT victim FROM final)`
+DELETE FROM museums d USING final f WHERE d.id = f.victim
+RETURNING f.keeper`
 
-	tag, err := s.pool.Exec(ctx, stmt)
+	removed, err := s.runMerge(ctx, stmt)
 	if err != nil {
 		return 0, fmt.Errorf("merge name variants: %w", err)
 	}
-	return tag.RowsAffected(), nil
+	if removed > 0 {
+		s.bumpGeneration(ctx)
+	}
+	return removed, nil
 }
@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/url"
@@ -15,6 +16,7 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"museum/internal/models"
+	"museum/internal/override"
 	"museum/pkg/exhibitions"
 )
 
@@ -29,6 +31,17 @@ import (
 func testStore(t *testing.T) *Store {
 	t.Helper()
 
+	store := emptyTestStore(t)
+	if _, err := store.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return store
+}
+
+// emptyTestStore is testStore without the migrations applied.
+func emptyTestStore(t *testing.T) *Store {
+	t.Helper()
+
 	dsn := os.Getenv("TEST_DATABASE_URL")
 	if dsn == "" {
 		t.Skip("set TEST_DATABASE_URL to run the database tests")
@@ -781,6 +794,69 @@ func TestUnplacedMuseumsAndSetLocation(t *testing.T) {
 	}
 }
 
+// A located museum with no address is one a visitor can find on a map and not
+// in the street. Its position is asked about once: an answer fills the
+// address, and a miss is remembered rather than asked about on every run.
+func TestUnaddressedMuseumsAndSetAddress(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+
+	if _, err := store.SaveMuseums(ctx, []models.Museum{
+		{Name: "Addressed Museum", Country: "Sweden", Locality: "Gothenburg", WikidataID: "Q1",
+			Latitude: 57.70, Longitude: 11.96, Address: models.Address{Road: "Vasagatan", HouseNumber: "37"}},
+		{Name: "Unaddressed Museum", Country: "Sweden", WikidataID: "Q2",
+			Latitude: 57.69, Longitude: 11.97, SourcePage: "way/28563412"},
+		{Name: "Nowhere Museum", Country: "Sweden", WikidataID: "Q3", Latitude: 57.5, Longitude: 11.5},
+		// Unplaced, so there is no position to ask about.
+		{Name: "Unplaced Museum", Country: "Sweden", Locality: "Gothenburg", WikidataID: "Q4"},
+	}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	pending, err := store.UnaddressedMuseums(ctx, "", "Sweden", 10)
+	if err != nil {
+		t.Fatalf("unaddressed: %v", err)
+	}
+	if len(pending) != 2 {
+		t.Fatalf("unaddressed = %+v, want the two located museums without an address", pending)
+	}
+	var museum, nowhere Unaddressed
+	for _, u := range pending {
+		switch u.Name {
+		case "Unaddressed Museum":
+			museum = u
+		case "Nowhere Museum":
+			nowhere = u
+		}
+	}
+	if museum.SourcePage != "way/28563412" || museum.Latitude != 57.69 {
+		t.Errorf("unaddressed museum = %+v", museum)
+	}
+
+	if err := store.SetAddress(ctx, museum.ID, models.Address{
+		HouseNumber: "1", Road: "Korsgatan", Postcode: "411 16", City: "Gothenburg"}); err != nil {
+		t.Fatalf("set address: %v", err)
+	}
+	if err := store.SetAddress(ctx, nowhere.ID, models.Address{}); err != nil {
+		t.Fatalf("mark checked: %v", err)
+	}
+	if remaining, _ := store.UnaddressedMuseums(ctx, "", "Sweden", 10); len(remaining) != 0 {
+		t.Errorf("still unaddressed: %+v", remaining)
+	}
+
+	hit, err := store.MuseumByID(ctx, strconv.FormatInt(museum.ID, 10))
+	if err != nil {
+		t.Fatalf("museum by id: %v", err)
+	}
+	if hit.Museum.Address.Postcode != "411 16" || hit.Museum.Address.Road != "1 Korsgatan" {
+		t.Errorf("address = %+v", hit.Museum.Address)
+	}
+	// The town came with the address, where the museum had none.
+	if hit.Museum.Locality != "Gothenburg" {
+		t.Errorf("locality = %q, want Gothenburg", hit.Museum.Locality)
+	}
+}
+
 // The unverified tail is names read off list pages that no source confirmed
 // are museums — a list of museums in Maryland yielded "Williamsburg, Virginia".
 // A caller that cannot tolerate them must be able to exclude them.
@@ -1057,7 +1133,7 @@ func TestMergeNameVariants(t *testing.T) {
 	}
 
 	survivors := map[string]bool{}
-	if err := store.EachMuseum(ctx, func(m models.Museum) { survivors[m.Name] = true }); err != nil {
+	if err := store.EachMuseum(ctx, func(_ int64, m models.Museum) { survivors[m.Name] = true }); err != nil {
 		t.Fatalf("read back: %v", err)
 	}
 
@@ -1186,3 +1262,544 @@ func TestMergeAliasVariants(t *testing.T) {
 		}
 	}
 }
+
+func TestPointClusters(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+
+	if _, err := store.SaveMuseums(ctx, []models.Museum{
+		{Name: "Louvre Museum", Country: "France", Latitude: 48.8606, Longitude: 2.3376,
+			WikidataID: "Q19675", Sitelinks: 167},
+		{Name: "Musée d'Orsay", Country: "France", Latitude: 48.8600, Longitude: 2.3266,
+			WikidataID: "Q23402", Sitelinks: 90},
+		{Name: "Rijksmuseum", Country: "Netherlands", Latitude: 52.36, Longitude: 4.885,
+			WikidataID: "Q190804", Sitelinks: 100},
+		{Name: "Unplaced", Country: "France", WikidataID: "Q4"},
+	}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if _, err := store.SaveExhibitions(ctx, []exhibitions.Exhibition{
+		{URL: "https://example.org/louvre/1", Title: "Mona Lisa", Museum: "Louvre Museum",
+			Latitude: 48.8606, Longitude: 2.3376},
+	}); err != nil {
+		t.Fatalf("save exhibitions: %v", err)
+	}
+
+	// Below the threshold the view is sent point by point.
+	sparse, err := store.PointClusters(ctx, 0, 0, 0, 0, false, 1, 10, 100)
+	if err != nil {
+		t.Fatalf("point clusters: %v", err)
+	}
+	if sparse.Clusters != nil || len(sparse.Points) != 3 || sparse.Museums != 3 {
+		t.Fatalf("sparse view = %+v, want three raw points", sparse)
+	}
+
+	// Above it, a one-degree grid puts the two Paris museums in one cell and
+	// Amsterdam in another.
+	dense, err := store.PointClusters(ctx, 0, 0, 0, 0, false, 1, 2, 100)
+	if err != nil {
+		t.Fatalf("point clusters: %v", err)
+	}
+	if len(dense.Clusters) != 2 || dense.Museums != 3 {
+		t.Fatalf("dense view = %+v, want two clusters over three museums", dense)
+	}
+	paris := dense.Clusters[0]
+	if paris.Museums != 2 || paris.RepresentativeName != "Louvre Museum" {
+		t.Errorf("Paris cluster = %+v, want two museums represented by the Louvre", paris)
+	}
+	if paris.Exhibitions != 1 || dense.Clusters[1].Exhibitions != 0 {
+		t.Errorf("exhibitions = %d and %d, want 1 and 0", paris.Exhibitions, dense.Clusters[1].Exhibitions)
+	}
+}
+
+func TestGenerationAdvancesOnWrite(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+
+	before, err := store.Generation(ctx)
+	if err != nil {
+		t.Fatalf("Generation: %v", err)
+	}
+
+	if _, err := store.SaveMuseums(ctx, []models.Museum{{Name: "Tate Modern", Country: "United Kingdom"}}); err != nil {
+		t.Fatalf("SaveMuseums: %v", err)
+	}
+
+	after, err := store.Generation(ctx)
+	if err != nil {
+		t.Fatalf("Generation: %v", err)
+	}
+	if after.Number <= before.Number {
+		t.Errorf("generation %d after a write, was %d; cached responses would outlive the data", after.Number, before.Number)
+	}
+
+	// A write that changes nothing must not throw every cached answer away.
+	if _, err := store.SaveMuseums(ctx, nil); err != nil {
+		t.Fatalf("SaveMuseums: %v", err)
+	}
+	unchanged, err := store.Generation(ctx)
+	if err != nil {
+		t.Fatalf("Generation: %v", err)
+	}
+	if unchanged.Number != after.Number {
+		t.Errorf("an empty save advanced the generation from %d to %d", after.Number, unchanged.Number)
+	}
+}
+
+// schemaShape describes the test schema's tables, columns, indexes and
+// constraints, with the schema's own name taken out so two can be compared.
+func schemaShape(t *testing.T, store *Store) []string {
+	t.Helper()
+	const stmt = `
+SELECT 'column ' || table_name || '.' || column_name || ' ' || data_type || ' ' || is_nullable
+       || ' default=' || coalesce(column_default, '') || ' generated=' || coalesce(generation_expression, '')
+FROM information_schema.columns WHERE table_schema = current_schema()
+UNION ALL
+SELECT 'index ' || indexname || ' ' || replace(indexdef, current_schema() || '.', '')
+FROM pg_indexes WHERE schemaname = current_schema()
+UNION ALL
+SELECT 'constraint ' || c.conrelid::regclass::text || ' ' || c.conname || ' ' || pg_get_constraintdef(c.oid)
+FROM pg_constraint c JOIN pg_namespace n ON n.oid = c.connamespace
+WHERE n.nspname = current_schema()
+ORDER BY 1`
+
+	rows, err := store.pool.Query(context.Background(), stmt)
+	if err != nil {
+		t.Fatalf("read schema: %v", err)
+	}
+	defer rows.Close()
+	var shape []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			t.Fatal(err)
+		}
+		shape = append(shape, line)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return shape
+}
+
+func TestMigrations_FreshAndUpgradedSchemasMatch(t *testing.T) {
+	ctx := context.Background()
+
+	fresh := testStore(t)
+	if err := fresh.CheckSchema(ctx); err != nil {
+		t.Fatalf("CheckSchema on a fresh database: %v", err)
+	}
+
+	// Upgraded in steps, with data in it between them, and with the last step
+	// reverted and reapplied on the way.
+	upgraded := emptyTestStore(t)
+	if err := upgraded.CheckSchema(ctx); !errors.Is(err, ErrSchemaBehind) {
+		t.Errorf("CheckSchema on an empty database = %v, want ErrSchemaBehind", err)
+	}
+	if _, err := upgraded.MigrateUp(ctx, 1); err != nil {
+		t.Fatalf("migrate to 1: %v", err)
+	}
+	if _, err := upgraded.pool.Exec(ctx,
+		`INSERT INTO exhibitions (url, title) VALUES ('https://example.org/a', '2024 03 15 Spring Salon')`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if _, err := upgraded.MigrateUp(ctx, 0); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	if last := migrations[len(migrations)-1]; last.Reversible() {
+		if _, err := upgraded.MigrateDown(ctx, 1); err != nil {
+			t.Fatalf("migrate down: %v", err)
+		}
+		if _, err := upgraded.MigrateUp(ctx, 0); err != nil {
+			t.Fatalf("migrate up again: %v", err)
+		}
+	}
+
+	// And one built the way databases were before migrations: every file run
+	// whole, nothing recorded. Migrating it must adopt it, not fail on it.
+	legacy := emptyTestStore(t)
+	for _, m := range Migrations()[:3] {
+		if _, err := legacy.pool.Exec(ctx, m.Up); err != nil {
+			t.Fatalf("legacy schema: %v", err)
+		}
+	}
+	if _, err := legacy.MigrateUp(ctx, 0); err != nil {
+		t.Fatalf("adopt legacy schema: %v", err)
+	}
+
+	want := schemaShape(t, fresh)
+	for name, store := range map[string]*Store{"upgraded": upgraded, "legacy": legacy} {
+		got := schemaShape(t, store)
+		if !slices.Equal(got, want) {
+			for _, line := range want {
+				if !slices.Contains(got, line) {
+					t.Errorf("%s schema lacks %s", name, line)
+				}
+			}
+			for _, line := range got {
+				if !slices.Contains(want, line) {
+					t.Errorf("%s schema has extra %s", name, line)
+				}
+			}
+		}
+		if err := store.CheckSchema(ctx); err != nil {
+			t.Errorf("CheckSchema on the %s database: %v", name, err)
+		}
+	}
+
+	var title string
+	if err := upgraded.pool.QueryRow(ctx, `SELECT title FROM exhibitions`).Scan(&title); err != nil {
+		t.Fatal(err)
+	}
+	if title != "Spring Salon" {
+		t.Errorf("title = %q; the data migration did not run on the way up", title)
+	}
+}
+
+func TestMigrations_RefuseASchemaFromTheFuture(t *testing.T) {
+	ctx := context.Background()
+	store := testStore(t)
+
+	if _, err := store.pool.Exec(ctx,
+		`INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, 'from_the_future', '')`,
+		SchemaVersion()+1); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CheckSchema(ctx); !errors.Is(err, ErrSchemaAhead) {
+		t.Errorf("CheckSchema = %v, want ErrSchemaAhead", err)
+	}
+	if _, err := store.MigrateDown(ctx, 1); !errors.Is(err, ErrSchemaAhead) {
+		t.Errorf("MigrateDown = %v, want a refusal to revert what this binary did not apply", err)
+	}
+}
+
+// The history is kept by a trigger rather than by SaveMuseums, because the
+// merges update and delete rows in statements of their own. This writes both
+// ways: through the upsert, and behind its back.
+func TestMuseumHistory_RecordsEachFieldAndOutlivesAMerge(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+
+	original := models.Museum{Name: "Tate Modern", Country: "United Kingdom", WikidataID: "Q193375",
+		Website: "https://tate.org.uk"}
+	moved := original
+	moved.Website = "https://www.tate.org.uk/visit/tate-modern"
+
+	if _, err := store.SaveMuseums(ctx, []models.Museum{original}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if _, err := store.SaveMuseums(ctx, []models.Museum{moved}); err != nil {
+		t.Fatalf("save again: %v", err)
+	}
+
+	history, err := store.MuseumHistory(ctx, "Q193375", 100, 0)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	var website *Revision
+	for i, r := range history.Revisions {
+		if r.Source == "" {
+			t.Errorf("revision %d of %s has no source", r.ID, r.Field)
+		}
+		if r.Field == "website" && r.Operation == "update" {
+			website = &history.Revisions[i]
+		}
+	}
+	if website == nil {
+		t.Fatalf("no update recorded for the website in %+v", history.Revisions)
+	}
+	if string(website.Old) != `"https://tate.org.uk"` || string(website.New) != `"https://www.tate.org.uk/visit/tate-modern"` {
+		t.Errorf("website revision = %s -> %s", website.Old, website.New)
+	}
+
+	// An unchanged save records nothing.
+	if _, err := store.SaveMuseums(ctx, []models.Museum{moved}); err != nil {
+		t.Fatalf("save unchanged: %v", err)
+	}
+	again, err := store.MuseumHistory(ctx, "Q193375", 100, 0)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if again.Total != history.Total {
+		t.Errorf("an unchanged save added %d revisions", again.Total-history.Total)
+	}
+
+	// A merged-away row keeps its history under its old id.
+	if _, err := store.SaveMuseums(ctx, []models.Museum{
+		{Name: "Sir John Soane's Museum", Country: "United Kingdom", Sources: []string{"wikipedia-list"}},
+	}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	var loser int64
+	if err := store.pool.QueryRow(ctx, `SELECT id FROM museums WHERE name = 'Sir John Soane''s Museum'`).Scan(&loser); err != nil {
+		t.Fatalf("find row: %v", err)
+	}
+	if _, err := store.pool.Exec(ctx, `DELETE FROM museums WHERE id = $1`, loser); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	gone, err := store.MuseumHistory(ctx, strconv.FormatInt(loser, 10), 100, 0)
+	if err != nil {
+		t.Fatalf("history of a deleted museum: %v", err)
+	}
+	if len(gone.Revisions) == 0 || gone.Revisions[0].Operation != "delete" {
+		t.Errorf("deleted museum's newest revision = %+v, want a delete", gone.Revisions)
+	}
+
+	if _, err := store.MuseumHistory(ctx, "999999999", 10, 0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown museum: err = %v, want ErrNotFound", err)
+	}
+}
+
+// An override is only worth making if the next crawl leaves it alone, so each
+// kind is made and then the source's version is saved over it.
+func TestOverrides_SurviveTheNextCrawl(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+
+	idOf := func(name string) int64 {
+		t.Helper()
+		var id int64
+		if err := store.pool.QueryRow(ctx, `SELECT id FROM museums WHERE name = $1`, name).Scan(&id); err != nil {
+			t.Fatalf("find %s: %v", name, err)
+		}
+		return id
+	}
+	count := func() int64 {
+		t.Helper()
+		var n int64
+		if err := store.pool.QueryRow(ctx, `SELECT count(*) FROM museums`).Scan(&n); err != nil {
+			t.Fatalf("count: %v", err)
+		}
+		return n
+	}
+
+	louvre := models.Museum{Name: "Louvre Museum", Country: "France", WikidataID: "Q19675", Website: "https://louvre.fr"}
+	hotel := models.Museum{Name: "Museum Hotel", Country: "Turkey"}
+	galata := models.Museum{Name: "Galata Museo del Mare", Country: "Italy", WikidataID: "Q1916826"}
+	maritime := models.Museum{Name: "Galata Maritime Museum", Country: "Italy", Website: "https://galatamuseodelmare.it"}
+	cityWithID := models.Museum{Name: "City Museum", Country: "Germany", WikidataID: "Q1"}
+	cityWithout := models.Museum{Name: "City Museum", Country: "Germany", Locality: "Ulm"}
+	crawl := []models.Museum{louvre, hotel, galata, maritime, cityWithID}
+	if _, err := store.SaveMuseums(ctx, crawl); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	// Stored under its own identity, as a second copy MergeDuplicates would fold in.
+	if _, err := store.pool.Exec(ctx, `
+INSERT INTO museums (name, normalized, country, locality) VALUES ('City Museum', 'city museum', 'Germany', 'Ulm')`); err != nil {
+		t.Fatalf("insert the second city museum: %v", err)
+	}
+	var ulm int64
+	if err := store.pool.QueryRow(ctx, `SELECT id FROM museums WHERE locality = 'Ulm'`).Scan(&ulm); err != nil {
+		t.Fatalf("find the second city museum: %v", err)
+	}
+
+	for _, o := range []override.Override{
+		{Kind: override.Pin, MuseumID: idOf("Louvre Museum"), Field: "website", Value: json.RawMessage(`"https://www.louvre.fr/en"`)},
+		{Kind: override.Suppress, MuseumID: idOf("Museum Hotel"), Reason: "a hotel"},
+		{Kind: override.Merge, MuseumID: idOf("Galata Museo del Mare"), OtherID: idOf("Galata Maritime Museum")},
+		{Kind: override.Split, MuseumID: idOf("City Museum"), OtherID: ulm},
+	} {
+		o.CreatedBy = "test"
+		if _, err := store.AddOverride(ctx, o); err != nil {
+			t.Fatalf("add %s: %v", o.Kind, err)
+		}
+	}
+	before := count()
+
+	// The next crawl says what the sources said before.
+	if _, err := store.SaveMuseums(ctx, append(crawl, cityWithout)); err != nil {
+		t.Fatalf("save again: %v", err)
+	}
+	if removed, err := store.MergeDuplicates(ctx); err != nil || removed != 0 {
+		t.Errorf("MergeDuplicates = %d, %v; want the split pair left alone", removed, err)
+	}
+	if after := count(); after != before {
+		t.Errorf("museums = %d after the crawl, want %d: a suppressed or merged museum came back", after, before)
+	}
+
+	hit, err := store.MuseumByID(ctx, "Q19675")
+	if err != nil {
+		t.Fatalf("louvre: %v", err)
+	}
+	if hit.Museum.Website != "https://www.louvre.fr/en" {
+		t.Errorf("pinned website = %q, the crawl overwrote it", hit.Museum.Website)
+	}
+	hit, err = store.MuseumByID(ctx, "Q1916826")
+	if err != nil {
+		t.Fatalf("galata: %v", err)
+	}
+	if hit.Museum.Website != "https://galatamuseodelmare.it" || !slices.Contains(hit.Museum.AlsoKnownAs, "Galata Maritime Museum") {
+		t.Errorf("merged museum = %+v, want the other's website and name", hit.Museum)
+	}
+
+	// A merge across a split is refused rather than quietly undoing it.
+	_, err = store.AddOverride(ctx, override.Override{Kind: override.Merge, MuseumID: idOf("City Museum"), OtherID: ulm, CreatedBy: "test"})
+	if !errors.Is(err, override.ErrConflict) {
+		t.Errorf("merge across a split: err = %v, want ErrConflict", err)
+	}
+
+	history, err := store.MuseumHistory(ctx, "Q19675", 10, 0)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if !strings.HasPrefix(history.Revisions[0].Source, "override ") {
+		t.Errorf("the pin's revision is attributed to %q, want the override", history.Revisions[0].Source)
+	}
+}
+
+// A pin made on a museum the merges later fold away is a correction to the
+// museum, not to the row, so it has to go with the museum to the row it is
+// folded into, rather than be overwritten by that row's value.
+func TestMergeDuplicates_HandsPinsToTheKeeper(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+
+	keeper := models.Museum{Name: "Bergen Museum", Country: "Norway", WikidataID: "Q4891905",
+		Website: "https://old.uib.no", Description: "university museum"}
+	if _, err := store.SaveMuseums(ctx, []models.Museum{keeper}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	var keeperID, victimID int64
+	if err := store.pool.QueryRow(ctx, `SELECT id FROM museums WHERE wikidata_id = 'Q4891905'`).Scan(&keeperID); err != nil {
+		t.Fatalf("find the keeper: %v", err)
+	}
+	// A second copy without the Wikidata id, as a list page would store it.
+	if err := store.pool.QueryRow(ctx, `
+INSERT INTO museums (name, normalized, country) VALUES ('Bergen Museum', 'bergen museum', 'Norway')
+RETURNING id`).Scan(&victimID); err != nil {
+		t.Fatalf("insert the copy: %v", err)
+	}
+
+	for _, o := range []override.Override{
+		{Kind: override.Pin, MuseumID: victimID, Field: "website", Value: json.RawMessage(`"https://www.uib.no/universitetsmuseet"`)},
+		// Both pin the description: the keeper's pin wins.
+		{Kind: override.Pin, MuseumID: victimID, Field: "description", Value: json.RawMessage(`"the copy's description"`)},
+		{Kind: override.Pin, MuseumID: keeperID, Field: "description", Value: json.RawMessage(`"the University Museum of Bergen"`)},
+	} {
+		o.CreatedBy = "test"
+		if _, err := store.AddOverride(ctx, o); err != nil {
+			t.Fatalf("pin %s: %v", o.Field, err)
+		}
+	}
+
+	if removed, err := store.MergeDuplicates(ctx); err != nil || removed != 1 {
+		t.Fatalf("MergeDuplicates = %d, %v; want the copy folded in", removed, err)
+	}
+
+	check := func(when string) {
+		t.Helper()
+		hit, err := store.MuseumByID(ctx, "Q4891905")
+		if err != nil {
+			t.Fatalf("%s: %v", when, err)
+		}
+		if hit.Museum.Website != "https://www.uib.no/universitetsmuseet" {
+			t.Errorf("%s: website = %q, want the pin made on the copy", when, hit.Museum.Website)
+		}
+		if hit.Museum.Description != "the University Museum of Bergen" {
+			t.Errorf("%s: description = %q, want the keeper's own pin", when, hit.Museum.Description)
+		}
+	}
+	check("after the merge")
+
+	overrides, err := store.Overrides(ctx, 0)
+	if err != nil {
+		t.Fatalf("overrides: %v", err)
+	}
+	if len(overrides) != 2 {
+		t.Errorf("overrides = %+v, want the keeper's two pins and the losing one dropped", overrides)
+	}
+	for _, o := range overrides {
+		if o.MuseumID != keeperID {
+			t.Errorf("the %s pin names museum %d, want the keeper %d", o.Field, o.MuseumID, keeperID)
+		}
+	}
+
+	// And the pin holds against the next crawl, as any pin does.
+	if _, err := store.SaveMuseums(ctx, []models.Museum{keeper}); err != nil {
+		t.Fatalf("save again: %v", err)
+	}
+	check("after the next crawl")
+}
+
+// A museum's description and classes are searchable. Bohuslän is a steamship
+// whose name is a province; nothing in it resembles the query, and before the
+// full-text document it could not be found at all.
+func TestSearch_MatchesDescriptionsAndClasses(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+
+	if _, err := store.SaveMuseums(ctx, []models.Museum{
+		{Name: "Bohuslän", Country: "Sweden", Locality: "Gothenburg", WikidataID: "Q10428930",
+			Description: "working life museum in Gothenburg Municipality",
+			Classes:     []string{"steamship", "museum ship"}},
+		{Name: "Gothenburg Museum of Art", Country: "Sweden", Locality: "Gothenburg", WikidataID: "Q1768208",
+			Description: "art museum in Gothenburg, Sweden", Classes: []string{"art museum"}, Sitelinks: 30},
+	}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	found := func(query string) []string {
+		t.Helper()
+		page, err := store.Search(ctx, query, 10, 0)
+		if err != nil {
+			t.Fatalf("search %q: %v", query, err)
+		}
+		var names []string
+		for _, hit := range page.Hits {
+			names = append(names, hit.Museum.Name)
+		}
+		return names
+	}
+
+	if got := found("steamship museum gothenburg"); !slices.Contains(got, "Bohuslän") {
+		t.Errorf("steamship museum gothenburg = %v, want Bohuslän", got)
+	}
+	// Stemmed: "ships" is the class's "ship".
+	if got := found("museum ships"); !slices.Contains(got, "Bohuslän") {
+		t.Errorf("museum ships = %v, want Bohuslän", got)
+	}
+	if got := found("gothenburg museum -art"); slices.Contains(got, "Gothenburg Museum of Art") {
+		t.Errorf("gothenburg museum -art = %v, want the art museum excluded", got)
+	}
+	if got := found(`"working life museum"`); !slices.Equal(got, []string{"Bohuslän"}) {
+		t.Errorf(`"working life museum" = %v, want only Bohuslän`, got)
+	}
+}
+
+// An exhibition's title is stemmed in its venue's language, which is known
+// only from the venue's country.
+func TestSaveExhibitions_TakesTheVenuesLanguage(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+
+	if _, err := store.SaveMuseums(ctx, []models.Museum{{Name: "Louvre Museum", Country: "France",
+		Locality: "Paris", Latitude: 48.8606, Longitude: 2.3376, WikidataID: "Q19675"}}); err != nil {
+		t.Fatalf("save museum: %v", err)
+	}
+	if _, err := store.SaveExhibitions(ctx, []exhibitions.Exhibition{
+		{URL: "https://www.louvre.fr/peintures", Title: "Les peintures flamandes",
+			Museum: "Louvre Museum", MuseumWikidataID: "Q19675"},
+		{URL: "https://example.org/elsewhere", Title: "Paintings from nowhere"},
+	}); err != nil {
+		t.Fatalf("save exhibitions: %v", err)
+	}
+
+	for url, want := range map[string]string{
+		"https://www.louvre.fr/peintures": "french",
+		"https://example.org/elsewhere":   "simple",
+	} {
+		var config string
+		if err := store.pool.QueryRow(ctx,
+			`SELECT text_config::text FROM exhibitions WHERE url = $1`, url).Scan(&config); err != nil {
+			t.Fatal(err)
+		}
+		if config != want {
+			t.Errorf("%s is stemmed as %s, want %s", url, config, want)
+		}
+	}
+
+	hits, _, err := store.SearchExhibitions(ctx, "peinture flamande", 0, 0, 0, false, true, 5, 0)
+	if err != nil || len(hits) == 0 || hits[0].URL != "https://www.louvre.fr/peintures" {
+		t.Errorf("peinture flamande = %v, %v; want the Louvre's show", hits, err)
+	}
+}
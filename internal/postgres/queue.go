@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// This file is the enricher's work queue for running without Kafka: stored
+// objects waiting to be enriched, claimed a few at a time under a lease, and
+// removed once enriched.
+
+// QueuedObject is one stored object claimed from the enrich queue.
+type QueuedObject struct {
+	ID int64
+	// Generation is the one claimed; AckEnrichment needs it back.
+	Generation int64
+	Bucket     string
+	Key        string
+	// Attempts counts the claims, this one included.
+	Attempts int
+}
+
+// EnqueueEnrichment asks for the objects at keys to be enriched, and returns
+// how many it queued or queued again.
+//
+// An object already waiting is not queued twice. One already claimed is
+// queued again with a new generation, and is due at once: the worker holding
+// it may be enriching the content from before the write that queued it.
+func (s *Store) EnqueueEnrichment(ctx context.Context, bucket string, keys []string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	tag, err := s.pool.Exec(ctx, `
+INSERT INTO enrich_queue (bucket, key)
+SELECT $1, k FROM unnest($2::text[]) AS k
+ON CONFLICT (bucket, key) DO UPDATE
+   SET generation   = enrich_queue.generation + 1,
+       enqueued_at  = now(),
+       available_at = now(),
+       attempts     = 0
+ WHERE enrich_queue.available_at > now()`, bucket, keys)
+	if err != nil {
+		return 0, fmt.Errorf("enqueue enrichment: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ClaimEnrichment takes up to limit objects that are due, oldest first, and
+// leases them for leaseFor, the pattern ClaimDueSites uses: SKIP LOCKED so two
+// workers take different rows, and a lease rather than a lock so a worker that
+// dies leaves its rows to fall due again by themselves.
+func (s *Store) ClaimEnrichment(ctx context.Context, limit int, leaseFor time.Duration) ([]QueuedObject, error) {
+	rows, err := s.pool.Query(ctx, `
+WITH due AS (
+    SELECT id FROM enrich_queue
+     WHERE available_at <= now()
+     ORDER BY available_at, id
+     LIMIT $1
+     FOR UPDATE SKIP LOCKED
+)
+UPDATE enrich_queue q
+   SET available_at = now() + make_interval(secs => $2), attempts = q.attempts + 1
+  FROM due
+ WHERE q.id = due.id
+RETURNING q.id, q.generation, q.bucket, q.key, q.attempts`, limit, leaseFor.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("claim enrichment: %w", err)
+	}
+	defer rows.Close()
+
+	var claimed []QueuedObject
+	for rows.Next() {
+		var o QueuedObject
+		if err := rows.Scan(&o.ID, &o.Generation, &o.Bucket, &o.Key, &o.Attempts); err != nil {
+			return nil, fmt.Errorf("scan claimed object: %w", err)
+		}
+		claimed = append(claimed, o)
+	}
+	return claimed, rows.Err()
+}
+
+// AckEnrichment removes an enriched object from the queue, unless it was
+// queued again after it was claimed.
+func (s *Store) AckEnrichment(ctx context.Context, id, generation int64) error {
+	if _, err := s.pool.Exec(ctx, `
+DELETE FROM enrich_queue WHERE id = $1 AND generation = $2`, id, generation); err != nil {
+		return fmt.Errorf("acknowledge enrichment %d: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEnrichQueue_ClaimLeaseAck(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+
+	if n, err := store.EnqueueEnrichment(ctx, "museum", []string{"raw_data/france/louvre.json", "raw_data/italy/uffizi.json"}); err != nil || n != 2 {
+		t.Fatalf("enqueue = %d, %v", n, err)
+	}
+	// Waiting already, so not queued twice.
+	if n, _ := store.EnqueueEnrichment(ctx, "museum", []string{"raw_data/france/louvre.json"}); n != 0 {
+		t.Errorf("queueing a waiting object again = %d, want 0", n)
+	}
+
+	first, err := store.ClaimEnrichment(ctx, 1, time.Hour)
+	if err != nil || len(first) != 1 || first[0].Key != "raw_data/france/louvre.json" || first[0].Attempts != 1 {
+		t.Fatalf("first claim = %+v, %v", first, err)
+	}
+	second, _ := store.ClaimEnrichment(ctx, 10, time.Hour)
+	if len(second) != 1 || second[0].Key != "raw_data/italy/uffizi.json" {
+		t.Fatalf("second claim = %+v, want only what the first left", second)
+	}
+	if again, _ := store.ClaimEnrichment(ctx, 10, time.Hour); len(again) != 0 {
+		t.Errorf("leased objects were claimed again: %+v", again)
+	}
+
+	// Written again while being enriched: the old claim's ack must not
+	// remove the request for the new content.
+	if n, _ := store.EnqueueEnrichment(ctx, "museum", []string{"raw_data/france/louvre.json"}); n != 1 {
+		t.Errorf("queueing a claimed object again = %d, want 1", n)
+	}
+	if err := store.AckEnrichment(ctx, first[0].ID, first[0].Generation); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AckEnrichment(ctx, second[0].ID, second[0].Generation); err != nil {
+		t.Fatal(err)
+	}
+	rest, _ := store.ClaimEnrichment(ctx, 10, time.Hour)
+	if len(rest) != 1 || rest[0].Key != "raw_data/france/louvre.json" || rest[0].Generation != first[0].Generation+1 {
+		t.Errorf("after the acks = %+v, want the Louvre's newer generation", rest)
+	}
+
+	// A lease that runs out makes the object due again.
+	if _, err := store.pool.Exec(ctx, `UPDATE enrich_queue SET available_at = now() - interval '1 second'`); err != nil {
+		t.Fatal(err)
+	}
+	if expired, _ := store.ClaimEnrichment(ctx, 10, time.Hour); len(expired) != 1 || expired[0].Attempts != 2 {
+		t.Errorf("after the lease ran out = %+v", expired)
+	}
+}
@@ -0,0 +1,246 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// A read replica takes the API's reads off the primary.
+//
+// On one pool, every search and map request competed for connections with the
+// sweep claiming sites FOR UPDATE, with exhibition batches and with reindex
+// upserts, and a long write could hold the API's latency hostage. A streaming
+// replica serves the same catalogue a little behind; what matters is knowing
+// how far behind, and not reading from it when that is too far.
+//
+// Only the reads the API makes go to the replica. Everything else — writes,
+// and the reads that decide what to write — stays on the primary, where it
+// sees its own changes.
+
+// replicaCheckEvery is how often the replica's lag is measured. The measure
+// is two function calls on the replica, so the interval is about how long a
+// replica that has fallen behind, or gone, can go on being read from: at most
+// this long, during which reads fail or lag as they would on a primary that
+// did the same.
+const replicaCheckEvery = 2 * time.Second
+
+// replicaCheckTimeout bounds a measurement. A partitioned replica black-holes
+// rather than refusing, and a check that hung would hold every read waiting
+// for its answer.
+const replicaCheckTimeout = time.Second
+
+// errNotMeasured is the replica's state before its lag is first known. Reads
+// go to the primary until it is.
+var errNotMeasured = errors.New("replica lag not yet measured")
+
+// replica is the read pool and what was last learned about it.
+type replica struct {
+	pool   *pgxpool.Pool
+	maxLag time.Duration
+
+	mu        sync.Mutex
+	checking  bool
+	checkedAt time.Time
+	lag       time.Duration
+	err       error
+}
+
+// AttachReplica sends the catalogue's reads to a replica at dsn whenever it is
+// up and no more than maxLag behind the primary, and to the primary whenever
+// it is not. It connects lazily: a replica that is down at startup is simply
+// not read from until it comes up.
+func (s *Store) AttachReplica(ctx context.Context, dsn string, maxLag time.Duration) error {
+	config, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return fmt.Errorf("parse read replica URL: %w", err)
+	}
+	// The same session settings as the primary's: the search thresholds are
+	// per connection, and a replica without them would answer differently.
+	primary := s.pool.Config()
+	config.AfterConnect = primary.AfterConnect
+	if name := primary.ConnConfig.RuntimeParams["application_name"]; name != "" {
+		config.ConnConfig.RuntimeParams["application_name"] = name
+	}
+	if config.MaxConns < primary.MaxConns {
+		config.MaxConns = primary.MaxConns
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return fmt.Errorf("read replica: %w", err)
+	}
+	if s.replica != nil {
+		s.replica.pool.Close()
+	}
+	s.replica = &replica{pool: pool, maxLag: maxLag, err: errNotMeasured}
+	return nil
+}
+
+// reader is the pool a catalogue read goes to: the one pinned to the context
+// by PinReader, or else the replica when there is one that is up and current
+// enough, and otherwise the primary.
+func (s *Store) reader(ctx context.Context) *pgxpool.Pool {
+	if pool, ok := ctx.Value(readerKey{}).(*pgxpool.Pool); ok {
+		return pool
+	}
+	if s.replica == nil || !s.replica.usable(ctx) {
+		return s.pool
+	}
+	return s.replica.pool
+}
+
+// readerKey is the context key PinReader keeps its choice under.
+type readerKey struct{}
+
+// PinReader chooses the pool for every catalogue read made with the returned
+// context, once. A request that reads the catalogue generation for its ETag
+// and then the rows it tags must read both from the same pool: otherwise a
+// check falling between them can tag the primary's rows with the replica's
+// older generation, or the other way round, and a cache keeps the mismatch
+// until the generation next moves.
+func (s *Store) PinReader(ctx context.Context) context.Context {
+	if s.replica == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, readerKey{}, s.reader(ctx))
+}
+
+// usable reports whether the replica may be read from, measuring its lag
+// again when the last measurement is old.
+//
+// One caller measures while the others go on with the last answer, so a slow
+// replica costs one request a second's wait, not every request in flight.
+func (r *replica) usable(ctx context.Context) bool {
+	r.mu.Lock()
+	due := !r.checking && time.Since(r.checkedAt) >= replicaCheckEvery
+	if due {
+		r.checking = true
+	}
+	usable := r.err == nil && r.lag <= r.maxLag
+	r.mu.Unlock()
+
+	if due {
+		lag, err := r.measure(ctx)
+		r.mu.Lock()
+		r.checking, r.checkedAt, r.lag, r.err = false, time.Now(), lag, err
+		usable = err == nil && lag <= r.maxLag
+		r.mu.Unlock()
+	}
+	return usable
+}
+
+// replicaSilenceLimit is how long a replica may go without hearing from the
+// primary before it is taken for cut off. An idle primary still sends a
+// keepalive every half of wal_sender_timeout, thirty seconds by default, so a
+// healthy replica never goes this long in silence.
+const replicaSilenceLimit = time.Minute
+
+// measure asks the replica how far behind the primary it is.
+//
+// The time since the last transaction was replayed is the usual measure, and
+// alone it is wrong on a quiet catalogue: with nothing to replay, the last
+// replay grows old while the replica is perfectly current. A replica that has
+// replayed everything it has received is therefore not behind at all — but
+// only while it is still receiving. One whose WAL receiver has stopped has
+// also replayed all it received, and falls further behind by the second, so
+// the receiver is checked first. A server that is not in recovery is a
+// primary, and current by definition.
+//
+// The receiver's state is hidden from roles without pg_read_all_stats, and a
+// replica whose state cannot be seen is not read from.
+func (r *replica) measure(ctx context.Context) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), replicaCheckTimeout)
+	defer cancel()
+
+	var st replicaState
+	err := r.pool.QueryRow(ctx, `
+SELECT pg_is_in_recovery(),
+       w.pid IS NOT NULL,
+       coalesce(w.status, ''),
+       extract(epoch FROM now() - w.last_msg_receipt_time)::float8,
+       pg_last_wal_receive_lsn() IS NOT DISTINCT FROM pg_last_wal_replay_lsn(),
+       coalesce(extract(epoch FROM now() - pg_last_xact_replay_timestamp()), 0)::float8
+FROM (SELECT 1) AS one
+LEFT JOIN pg_stat_wal_receiver w ON true`).Scan(
+		&st.recovering, &st.receiver, &st.status, &st.silence, &st.caughtUp, &st.replayAge)
+	if err != nil {
+		return 0, fmt.Errorf("read replica: %w", err)
+	}
+	return st.lag()
+}
+
+// replicaState is what the replica says about itself.
+type replicaState struct {
+	recovering bool
+	receiver   bool
+	status     string
+	// silence is the seconds since the primary was last heard from, or nil
+	// where the receiver's state cannot be seen.
+	silence   *float64
+	caughtUp  bool
+	replayAge float64
+}
+
+// lag reads how far behind the replica is, or why it cannot be read from.
+func (st replicaState) lag() (time.Duration, error) {
+	switch {
+	case !st.recovering:
+		return 0, nil
+	case !st.receiver:
+		return 0, errors.New("read replica: not receiving from the primary")
+	case st.status == "":
+		return 0, errors.New("read replica: WAL receiver state hidden; grant pg_read_all_stats to its user")
+	case st.status != "streaming":
+		return 0, fmt.Errorf("read replica: WAL receiver is %s, not streaming", st.status)
+	case st.silence == nil:
+		return 0, errors.New("read replica: nothing heard from the primary yet")
+	case *st.silence > replicaSilenceLimit.Seconds():
+		silence := time.Duration(*st.silence * float64(time.Second)).Round(time.Second)
+		return 0, fmt.Errorf("read replica: nothing heard from the primary for %s", silence)
+	case st.caughtUp:
+		return 0, nil
+	}
+	return time.Duration(st.replayAge * float64(time.Second)), nil
+}
+
+// PoolStatus is one database pool as the readiness probe reports it.
+type PoolStatus struct {
+	// Name is "primary" or "replica".
+	Name string
+	// Err is why the pool cannot be used, or nil.
+	Err error
+	// Lag is how far the replica is behind; zero for the primary.
+	Lag time.Duration
+	// Behind says the replica is up but further behind than allowed.
+	Behind bool
+	// Reads says the catalogue's reads go to this pool now.
+	Reads bool
+}
+
+// Pools reports each pool, measured now: the primary, and the replica when
+// one is attached.
+func (s *Store) Pools(ctx context.Context) []PoolStatus {
+	primary := PoolStatus{Name: "primary", Err: s.pool.Ping(ctx)}
+	if s.replica == nil {
+		primary.Reads = true
+		return []PoolStatus{primary}
+	}
+
+	r := s.replica
+	lag, err := r.measure(ctx)
+	r.mu.Lock()
+	if !r.checking {
+		r.checkedAt, r.lag, r.err = time.Now(), lag, err
+	}
+	r.mu.Unlock()
+
+	replica := PoolStatus{Name: "replica", Err: err, Lag: lag, Behind: err == nil && lag > r.maxLag}
+	replica.Reads = err == nil && !replica.Behind
+	primary.Reads = !replica.Reads
+	return []PoolStatus{primary, replica}
+}
@@ -0,0 +1,146 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"museum/internal/models"
+)
+
+func TestReplicaTakesTheReadsWhileCurrent(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+	if _, err := store.SaveMuseums(ctx, []models.Museum{{Name: "Rijksmuseum", Country: "Netherlands",
+		Latitude: 52.36, Longitude: 4.885, WikidataID: "Q190804"}}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	// The same database stands in for a replica: it is not in recovery, so
+	// it is never behind.
+	if err := store.AttachReplica(ctx, store.pool.Config().ConnString(), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	// The first read finds the lag unmeasured, and measures it.
+	store.reader(ctx)
+	if got := store.reader(ctx); got != store.replica.pool {
+		t.Error("reads stayed on the primary with a current replica attached")
+	}
+	pools := store.Pools(ctx)
+	if len(pools) != 2 || pools[0].Reads || !pools[1].Reads || pools[1].Err != nil {
+		t.Errorf("pools = %+v, want the replica up and taking the reads", pools)
+	}
+	if page, err := store.Search(ctx, "rijksmuseum", 5, 0); err != nil || len(page.Hits) != 1 {
+		t.Errorf("search on the replica = %+v, %v", page.Hits, err)
+	}
+}
+
+func TestReadsFallBackWhenTheReplicaIsDown(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+	if _, err := store.SaveMuseums(ctx, []models.Museum{{Name: "Rijksmuseum", Country: "Netherlands",
+		Latitude: 52.36, Longitude: 4.885, WikidataID: "Q190804"}}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	// Nothing listens on port 1.
+	if err := store.AttachReplica(ctx, "postgres://museum@127.0.0.1:1/museum?connect_timeout=1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if page, err := store.Search(ctx, "rijksmuseum", 5, 0); err != nil || len(page.Hits) != 1 {
+		t.Errorf("search with the replica down = %+v, %v; want it answered by the primary", page.Hits, err)
+	}
+	if err := store.Ping(ctx); err != nil {
+		t.Errorf("ping with the replica down: %v", err)
+	}
+	pools := store.Pools(ctx)
+	if len(pools) != 2 || !pools[0].Reads || pools[1].Err == nil {
+		t.Errorf("pools = %+v, want the replica reported down and the primary taking the reads", pools)
+	}
+}
+
+func TestReplicaStateLag(t *testing.T) {
+	quiet, silent := 5.0, 300.0
+	streaming := replicaState{recovering: true, receiver: true, status: "streaming", silence: &quiet}
+	tests := []struct {
+		name    string
+		state   func(replicaState) replicaState
+		lag     time.Duration
+		wantErr bool
+	}{
+		{"primary", func(st replicaState) replicaState { return replicaState{} }, 0, false},
+		{"caught up", func(st replicaState) replicaState { st.caughtUp, st.replayAge = true, 900; return st }, 0, false},
+		{"replaying", func(st replicaState) replicaState { st.replayAge = 3; return st }, 3 * time.Second, false},
+		// Everything received is replayed, but nothing more is arriving.
+		{"no receiver", func(st replicaState) replicaState { st.receiver, st.status, st.caughtUp = false, "", true; return st }, 0, true},
+		{"hidden", func(st replicaState) replicaState { st.status, st.silence = "", nil; return st }, 0, true},
+		{"reconnecting", func(st replicaState) replicaState { st.status = "waiting"; return st }, 0, true},
+		{"silent", func(st replicaState) replicaState { st.silence, st.caughtUp = &silent, true; return st }, 0, true},
+	}
+	for _, tt := range tests {
+		lag, err := tt.state(streaming).lag()
+		if lag != tt.lag || (err != nil) != tt.wantErr {
+			t.Errorf("%s: lag = %v, %v; want %v, error %v", tt.name, lag, err, tt.lag, tt.wantErr)
+		}
+	}
+}
+
+func TestPinnedReaderHoldsForTheRequest(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+	if err := store.AttachReplica(ctx, store.pool.Config().ConnString(), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	// Pinned before the lag is measured, the request stays on the primary
+	// even once the replica is found current.
+	pinned := store.PinReader(ctx)
+	store.reader(ctx)
+	if got := store.reader(ctx); got != store.replica.pool {
+		t.Fatal("unpinned reads stayed on the primary with a current replica attached")
+	}
+	if got := store.reader(pinned); got != store.pool {
+		t.Error("a pinned read moved to the replica mid-request")
+	}
+}
+
+func TestGenerationIsCachedPerPool(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+	if err := store.AttachReplica(ctx, store.pool.Config().ConnString(), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	onPrimary := store.PinReader(ctx)
+	store.reader(ctx)
+	onReplica := store.PinReader(ctx)
+	if store.reader(onPrimary) == store.reader(onReplica) {
+		t.Fatal("both requests were pinned to one pool")
+	}
+
+	primary, err := store.Generation(onPrimary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Moved behind the store's back, as a replica catching up moves it: the
+	// number cached from the primary must not answer for the replica.
+	if _, err := store.pool.Exec(ctx, `UPDATE catalogue_generation SET generation = generation + 1`); err != nil {
+		t.Fatal(err)
+	}
+	replica, err := store.Generation(onReplica)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replica.Number != primary.Number+1 {
+		t.Errorf("replica generation = %d, want %d read from it rather than the primary's cached %d",
+			replica.Number, primary.Number+1, primary.Number)
+	}
+	if cached, _ := store.Generation(onPrimary); cached.Number != primary.Number {
+		t.Errorf("primary generation = %d, want its own cached %d", cached.Number, primary.Number)
+	}
+
+	store.bumpGeneration(ctx)
+	for name, pinned := range map[string]context.Context{"primary": onPrimary, "replica": onReplica} {
+		if after, _ := store.Generation(pinned); after.Number != primary.Number+2 {
+			t.Errorf("%s generation after a bump = %d, want %d", name, after.Number, primary.Number+2)
+		}
+	}
+}
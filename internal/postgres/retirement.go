@@ -0,0 +1,320 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"museum/internal/override"
+)
+
+// This file is how museums leave the catalogue when their sources stop
+// offering them: which runs each source completed, which museums each run
+// reported, and which museums no source has reported for long enough.
+
+// CrawlRun is what one source yielded in one crawl.
+type CrawlRun struct {
+	ID int64
+	// Source is the tag the source's museums carry in their sources.
+	Source string
+	// Label is the run's label, as the history records it.
+	Label     string
+	StartedAt time.Time
+	// Finished is false for a source whose stream was cut short, by a
+	// cancelled crawl or a deadline. Such a run never counts towards
+	// retirement.
+	Finished bool
+	Seen     int
+	// Complete is decided by RecordCrawlRun.
+	Complete bool
+}
+
+// completeShare is the part of its previous complete run a source must see
+// again for a run to count as complete itself.
+//
+// A source that errors halfway through a query usually still finishes
+// cleanly, with whatever it had by then. Without this, a Wikidata timeout
+// that returned half the museums would count as a run in which the other half
+// vanished.
+const completeShare = 0.9
+
+// refsMatched is the common table expressions that find the museums a list
+// of override.Refs names, their Wikidata ids in $2 and their name keys in $3,
+// as the ids in matched.
+const refsMatched = `seen AS (
+    SELECT DISTINCT qid, split_part(key, '|', 1) AS name, split_part(key, '|', 2) AS country
+    FROM unnest($2::text[], $3::text[]) AS s(qid, key)
+),
+matched AS (
+    SELECT m.id FROM seen JOIN museums m ON m.wikidata_id = seen.qid
+     WHERE seen.qid <> ''
+    UNION
+    SELECT m.id FROM seen JOIN museums m
+        ON m.normalized = seen.name AND coalesce(m.country, '') = seen.country
+     WHERE seen.name <> '' AND (seen.qid = '' OR coalesce(m.wikidata_id, '') = '')
+    UNION
+    SELECT m.id FROM seen JOIN museums m
+        ON m.aliases_normalized @> ARRAY[seen.name] AND coalesce(m.country, '') = seen.country
+     WHERE seen.name <> '' AND (seen.qid = '' OR coalesce(m.wikidata_id, '') = '')
+)`
+
+// RecordCrawlRun records what a source reported in one crawl: the run itself,
+// and a sighting for every museum among seen. A retired museum that has been
+// seen again is restored. It returns the run as recorded and how many museums
+// it restored.
+//
+// A museum is recognised the way an override recognises one: by Wikidata id
+// where both sides have one, and otherwise by name and country, or by an alias
+// and the country for a record the merges folded into another row.
+func (s *Store) RecordCrawlRun(ctx context.Context, run CrawlRun, seen []override.Ref) (CrawlRun, int64, error) {
+	run.Seen = len(seen)
+
+	var previous *int
+	err := s.pool.QueryRow(ctx,
+		`SELECT max(seen) FILTER (WHERE id = (SELECT max(id) FROM crawl_runs WHERE source = $1 AND complete))
+		   FROM crawl_runs WHERE source = $1`, run.Source,
+	).Scan(&previous)
+	if err != nil {
+		return run, 0, fmt.Errorf("record crawl run for %s: %w", run.Source, err)
+	}
+	run.Complete = run.Finished && run.Seen > 0 &&
+		(previous == nil || float64(run.Seen) >= completeShare*float64(*previous))
+
+	qids := make([]string, len(seen))
+	keys := make([]string, len(seen))
+	for i, ref := range seen {
+		qids[i], keys[i] = ref.WikidataID, ref.NameKey
+	}
+
+	const sight = `
+WITH ` + refsMatched + `
+INSERT INTO museum_sightings (museum_id, source, run_id)
+SELECT id, $4, $1 FROM matched
+ON CONFLICT (museum_id, source) DO UPDATE SET run_id = excluded.run_id
+RETURNING museum_id`
+
+	const restore = `
+UPDATE museums SET retired_at = NULL, retired_reason = NULL
+ WHERE id = ANY($1) AND retired_at IS NOT NULL`
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return run, 0, fmt.Errorf("record crawl run for %s: %w", run.Source, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := tx.QueryRow(ctx,
+		`INSERT INTO crawl_runs (source, label, started_at, seen, complete)
+		 VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		run.Source, run.Label, run.StartedAt, run.Seen, run.Complete,
+	).Scan(&run.ID); err != nil {
+		return run, 0, fmt.Errorf("record crawl run for %s: %w", run.Source, err)
+	}
+
+	rows, err := tx.Query(ctx, sight, run.ID, qids, keys, run.Source)
+	if err != nil {
+		return run, 0, fmt.Errorf("record sightings for %s: %w", run.Source, err)
+	}
+	var sighted []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return run, 0, fmt.Errorf("record sightings for %s: %w", run.Source, err)
+		}
+		sighted = append(sighted, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return run, 0, fmt.Errorf("record sightings for %s: %w", run.Source, err)
+	}
+
+	tag, err := tx.Exec(ctx, restore, sighted)
+	if err != nil {
+		return run, 0, fmt.Errorf("restore museums seen by %s: %w", run.Source, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return run, 0, fmt.Errorf("record crawl run for %s: %w", run.Source, err)
+	}
+	if tag.RowsAffected() > 0 {
+		s.bumpGeneration(ctx)
+	}
+	return run, tag.RowsAffected(), nil
+}
+
+// RetireVanishedMuseums retires every museum that each of its sources has
+// left out of at least after consecutive complete runs, and reports how many
+// it retired. It refuses, retiring nothing, when that would be more than most
+// museums; most of zero or less allows any number.
+//
+// A museum is judged only by the sources it carries. One with a source that has
+// never completed a run — a source since disabled, or one that predates run
+// records — is never retired, because nothing can be said about what that
+// source still offers.
+func (s *Store) RetireVanishedMuseums(ctx context.Context, after, most int) (int64, error) {
+	if after <= 0 {
+		return 0, fmt.Errorf("retire vanished museums: after must be at least one run, got %d", after)
+	}
+
+	// missed counts the complete runs of a source since the last one that
+	// reported the museum. A museum never sighted counts every complete run,
+	// which is right: it has been left out of all of them.
+	const stmt = `
+WITH missed AS (
+    SELECT m.id, src,
+           (SELECT count(*) FROM crawl_runs r
+             WHERE r.source = src AND r.complete
+               AND r.id > coalesce((SELECT run_id FROM museum_sightings s
+                                     WHERE s.museum_id = m.id AND s.source = src), 0)) AS runs
+    FROM museums m, unnest(m.sources) AS src
+    WHERE m.retired_at IS NULL
+),
+vanished AS (
+    SELECT id,
+           string_agg(src || ': unseen in the last ' || runs || ' complete runs', '; ' ORDER BY src) AS reason
+    FROM missed
+    GROUP BY id
+    HAVING bool_and(runs >= $1)
+)
+SELECT id, reason FROM vanished`
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("retire vanished museums: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, stmt, after)
+	if err != nil {
+		return 0, fmt.Errorf("retire vanished museums: %w", err)
+	}
+	var (
+		ids     []int64
+		reasons []string
+	)
+	for rows.Next() {
+		var (
+			id     int64
+			reason string
+		)
+		if err := rows.Scan(&id, &reason); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("retire vanished museums: %w", err)
+		}
+		ids, reasons = append(ids, id), append(reasons, reason)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("retire vanished museums: %w", err)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if most > 0 && len(ids) > most {
+		return 0, fmt.Errorf("retire vanished museums: %d museums have vanished, more than the %d allowed at once; "+
+			"a source has more likely changed than that many museums closed", len(ids), most)
+	}
+
+	tag, err := tx.Exec(ctx, `
+UPDATE museums m SET retired_at = now(), retired_reason = v.reason
+  FROM unnest($1::bigint[], $2::text[]) AS v(id, reason)
+ WHERE m.id = v.id`, ids, reasons)
+	if err != nil {
+		return 0, fmt.Errorf("retire vanished museums: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("retire vanished museums: %w", err)
+	}
+	s.bumpGeneration(ctx)
+	return tag.RowsAffected(), nil
+}
+
+// RetireAbsent makes the live catalogue the one present describes: every
+// museum none of present names is retired with reason, and every retired one
+// it names is restored. It returns how many of each.
+//
+// "reindex -as-of" loads the catalogue of a past day over today's, and this
+// is what takes out the museums added since, and brings back the ones retired
+// since. Museums are recognised as RecordCrawlRun recognises them.
+func (s *Store) RetireAbsent(ctx context.Context, present []override.Ref, reason string) (retired, restored int64, err error) {
+	if len(present) == 0 {
+		return 0, 0, fmt.Errorf("retire absent museums: no museums present, which would retire them all")
+	}
+	qids := make([]string, len(present))
+	keys := make([]string, len(present))
+	for i, ref := range present {
+		qids[i], keys[i] = ref.WikidataID, ref.NameKey
+	}
+
+	err = s.pool.QueryRow(ctx, `
+WITH `+refsMatched+`,
+retired AS (
+    UPDATE museums SET retired_at = now(), retired_reason = $1
+     WHERE retired_at IS NULL AND id NOT IN (SELECT id FROM matched)
+    RETURNING id
+),
+restored AS (
+    UPDATE museums SET retired_at = NULL, retired_reason = NULL
+     WHERE retired_at IS NOT NULL AND id IN (SELECT id FROM matched)
+    RETURNING id
+)
+SELECT (SELECT count(*) FROM retired), (SELECT count(*) FROM restored)`,
+		reason, qids, keys).Scan(&retired, &restored)
+	if err != nil {
+		return 0, 0, fmt.Errorf("retire absent museums: %w", err)
+	}
+	if retired+restored > 0 {
+		s.bumpGeneration(ctx)
+	}
+	return retired, restored, nil
+}
+
+// RetiredMuseum is one museum retirement has hidden, with why.
+type RetiredMuseum struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Country   string    `json:"country,omitempty"`
+	RetiredAt time.Time `json:"retired_at"`
+	Reason    string    `json:"reason"`
+	// LastSeen is when each source last reported the museum. A source missing
+	// from it has not reported the museum since runs were first recorded.
+	LastSeen map[string]time.Time `json:"last_seen,omitempty"`
+}
+
+// RetiredMuseums lists the retired museums, most recently retired first.
+func (s *Store) RetiredMuseums(ctx context.Context, limit int) ([]RetiredMuseum, error) {
+	const stmt = `
+SELECT m.id, m.name, coalesce(m.country, ''), m.retired_at, coalesce(m.retired_reason, ''),
+       (SELECT jsonb_object_agg(s.source, r.finished_at)
+          FROM museum_sightings s JOIN crawl_runs r ON r.id = s.run_id
+         WHERE s.museum_id = m.id)
+FROM museums m
+WHERE m.retired_at IS NOT NULL
+ORDER BY m.retired_at DESC, m.id
+LIMIT $1`
+
+	rows, err := s.pool.Query(ctx, stmt, limit)
+	if err != nil {
+		return nil, fmt.Errorf("retired museums: %w", err)
+	}
+	defer rows.Close()
+
+	var out []RetiredMuseum
+	for rows.Next() {
+		var (
+			m        RetiredMuseum
+			lastSeen []byte
+		)
+		if err := rows.Scan(&m.ID, &m.Name, &m.Country, &m.RetiredAt, &m.Reason, &lastSeen); err != nil {
+			return nil, fmt.Errorf("retired museums: %w", err)
+		}
+		if len(lastSeen) > 0 {
+			if err := json.Unmarshal(lastSeen, &m.LastSeen); err != nil {
+				return nil, fmt.Errorf("retired museums: last seen for %d: %w", m.ID, err)
+			}
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,197 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"museum/internal/models"
+	"museum/internal/override"
+)
+
+func TestRetirement_AfterEverySourceDropsAMuseum(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+
+	both := models.Museum{Name: "Musée Carnavalet", Country: "France", WikidataID: "Q1129710",
+		Latitude: 48.8575, Longitude: 2.3624, Sources: []string{"wikidata", "openstreetmap"}}
+	closed := models.Museum{Name: "Musée de la Contrefaçon", Country: "France", WikidataID: "Q3329728",
+		Latitude: 48.8722, Longitude: 2.2806, Sources: []string{"wikidata"}}
+	listed := models.Museum{Name: "Musée de la Poupée", Country: "France",
+		Latitude: 48.8612, Longitude: 2.3531, Sources: []string{"wikipedia-list"}}
+	if _, err := store.SaveMuseums(ctx, []models.Museum{both, closed, listed}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	crawl := func(source string, finished bool, seen ...models.Museum) (CrawlRun, int64) {
+		t.Helper()
+		refs := make([]override.Ref, len(seen))
+		for i, m := range seen {
+			refs[i] = override.RefOf(m)
+		}
+		run, restored, err := store.RecordCrawlRun(ctx,
+			CrawlRun{Source: source, Label: "test", StartedAt: time.Now(), Finished: finished}, refs)
+		if err != nil {
+			t.Fatalf("record %s: %v", source, err)
+		}
+		return run, restored
+	}
+	retire := func() int64 {
+		t.Helper()
+		n, err := store.RetireVanishedMuseums(ctx, 2, 0)
+		if err != nil {
+			t.Fatalf("retire: %v", err)
+		}
+		return n
+	}
+
+	// Carnavalet is still on OpenStreetMap, and openstreetmap has never
+	// completed a run, so Wikidata dropping it cannot retire it. The list
+	// source has never run at all.
+	crawl("wikidata", true, closed, both)
+	crawl("wikidata", true, both, both)
+	if _, restored := crawl("wikidata", false, both); restored != 0 {
+		t.Errorf("restored %d with nothing retired", restored)
+	}
+	if n := retire(); n != 0 {
+		t.Fatalf("retired %d after one complete run without it, want none", n)
+	}
+
+	// A short run counts for nothing: one museum against the two before.
+	if run, _ := crawl("wikidata", true, both); run.Complete {
+		t.Errorf("a run at half the size of the last was complete")
+	}
+	crawl("wikidata", true, both, both)
+	if n := retire(); n != 1 {
+		t.Fatalf("retired %d, want the museum wikidata has dropped twice", n)
+	}
+
+	page, err := store.Search(ctx, "musee de la contrefacon", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, hit := range page.Hits {
+		if hit.Museum.WikidataID == closed.WikidataID {
+			t.Errorf("search returned the retired museum")
+		}
+	}
+	hit, err := store.MuseumByID(ctx, closed.WikidataID)
+	if err != nil {
+		t.Fatalf("a retired museum is still fetched by id: %v", err)
+	}
+	if hit.RetiredAt == nil {
+		t.Errorf("MuseumByID did not mark the museum retired")
+	}
+
+	retired, err := store.RetiredMuseums(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(retired) != 1 || !strings.Contains(retired[0].Reason, "wikidata: unseen in the last 2 complete runs") {
+		t.Fatalf("retired = %+v, want the museum with its reason", retired)
+	}
+	if _, ok := retired[0].LastSeen["wikidata"]; !ok {
+		t.Errorf("last seen = %v, want when wikidata last reported it", retired[0].LastSeen)
+	}
+
+	// Reported again, under a name the merger cleans the same way.
+	if _, restored := crawl("wikidata", true, both, closed); restored != 1 {
+		t.Errorf("restored %d, want the museum wikidata reports again", restored)
+	}
+	if hit, _ := store.MuseumByID(ctx, closed.WikidataID); hit.RetiredAt != nil {
+		t.Errorf("the museum is still retired after reappearing")
+	}
+}
+
+func TestRetirement_RefusesAMassRetirement(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+
+	var museums []models.Museum
+	for _, name := range []string{"Museum A", "Museum B", "Museum C"} {
+		museums = append(museums, models.Museum{Name: name, Country: "Norway", Sources: []string{"wikidata"}})
+	}
+	if _, err := store.SaveMuseums(ctx, museums); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	other := override.Ref{NameKey: override.NameKey("Museum D", "Norway")}
+	if _, _, err := store.RecordCrawlRun(ctx,
+		CrawlRun{Source: "wikidata", Label: "test", StartedAt: time.Now(), Finished: true}, []override.Ref{other}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.RetireVanishedMuseums(ctx, 1, 2); err == nil {
+		t.Fatalf("retired three museums with at most two allowed")
+	}
+	if n, err := store.RetireVanishedMuseums(ctx, 1, 3); err != nil || n != 3 {
+		t.Fatalf("retired %d, %v; want three", n, err)
+	}
+}
+
+// The sitemap's pages leave retired museums out.
+func TestMuseumIDs_LeavesOutRetired(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+	if _, err := store.SaveMuseums(ctx, []models.Museum{
+		{Name: "Louvre", Country: "France", WikidataID: "Q19675"},
+		{Name: "Musée des Monuments Français", Country: "France", WikidataID: "Q3329572"},
+		{Name: "Orsay", Country: "France", WikidataID: "Q23402"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.pool.Exec(ctx, `UPDATE museums SET retired_at = now() WHERE wikidata_id = 'Q3329572'`); err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := store.MuseumIDs(ctx, 0, 10)
+	if err != nil || len(ids) != 2 {
+		t.Fatalf("ids = %v, %v; want the two current museums", ids, err)
+	}
+	if rest, err := store.MuseumIDs(ctx, ids[0], 10); err != nil || len(rest) != 1 || rest[0] != ids[1] {
+		t.Errorf("ids after %d = %v, %v; want only %d", ids[0], rest, err, ids[1])
+	}
+}
+
+func TestRetireAbsent(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+
+	then := models.Museum{Name: "Musée Carnavalet", Country: "France", WikidataID: "Q1129710",
+		Latitude: 48.8575, Longitude: 2.3624, Sources: []string{"wikidata"}}
+	unnamed := models.Museum{Name: "Musée de la Poupée", Country: "France",
+		Latitude: 48.8612, Longitude: 2.3531, Sources: []string{"wikipedia-list"}}
+	since := models.Museum{Name: "Musée de la Libération de Paris", Country: "France", WikidataID: "Q3329602",
+		Latitude: 48.8339, Longitude: 2.3322, Sources: []string{"wikidata"}}
+	if _, err := store.SaveMuseums(ctx, []models.Museum{then, unnamed, since}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if _, err := store.pool.Exec(ctx,
+		`UPDATE museums SET retired_at = now(), retired_reason = 'closed' WHERE wikidata_id = $1`, then.WikidataID); err != nil {
+		t.Fatal(err)
+	}
+
+	retired, restored, err := store.RetireAbsent(ctx,
+		[]override.Ref{override.RefOf(then), override.RefOf(unnamed)}, "absent from the catalogue as of 2026-01-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retired != 1 || restored != 1 {
+		t.Errorf("retired %d and restored %d, want the museum added since retired and the one retired since back", retired, restored)
+	}
+	ids, err := store.MuseumIDs(ctx, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gone, err := store.MuseumByID(ctx, since.WikidataID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || gone.RetiredAt == nil {
+		t.Errorf("%d museums current, the one added since retired at %v; want the two present on the day", len(ids), gone.RetiredAt)
+	}
+
+	if _, _, err := store.RetireAbsent(ctx, nil, "nothing"); err == nil {
+		t.Error("retiring everything was allowed")
+	}
+}
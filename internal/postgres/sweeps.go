@@ -277,6 +277,9 @@ UPDATE exhibitions
 	if err != nil {
 		return 0, fmt.Errorf("retire unseen for %s: %w", site, err)
 	}
+	if tag.RowsAffected() > 0 {
+		s.bumpGeneration(ctx)
+	}
 	return tag.RowsAffected(), nil
 }
 
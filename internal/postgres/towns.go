@@ -0,0 +1,129 @@
+package postgres
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"museum/internal/search"
+	"museum/pkg/geo"
+)
+
+// Towns resolves place names against the towns of a catalogue held outside the
+// database, by LocalityPlace's rules: a country named in the query narrows the
+// towns and is dropped from the term, the leading word of the town is matched
+// by similarity, and the centre and radius come from the town's museums.
+//
+// Add every placed museum, then Resolve. It is not safe to Add while resolving.
+type Towns struct {
+	towns     []town
+	byName    map[string]int
+	countries []string
+}
+
+// town is one locality and where its museums are.
+type town struct {
+	locality string
+	lead     string
+	// positions are the town's placed museums, by lowercased country.
+	positions map[string][][2]float64
+}
+
+// Add records a placed museum's town.
+func (t *Towns) Add(locality, localityNormalized, country string, lat, lon float64) {
+	if localityNormalized == "" {
+		return
+	}
+	if t.byName == nil {
+		t.byName = map[string]int{}
+	}
+	i, ok := t.byName[locality]
+	if !ok {
+		lead, _, _ := strings.Cut(localityNormalized, " ")
+		i = len(t.towns)
+		t.byName[locality] = i
+		t.towns = append(t.towns, town{locality: locality, lead: lead, positions: map[string][][2]float64{}})
+	}
+	country = strings.ToLower(country)
+	t.towns[i].positions[country] = append(t.towns[i].positions[country], [2]float64{lat, lon})
+	if country != "" && !slices.Contains(t.countries, country) {
+		t.countries = append(t.countries, country)
+		// The longest name first, so "guinea-bissau" is not read as "guinea".
+		slices.SortFunc(t.countries, func(a, b string) int {
+			return cmp.Or(cmp.Compare(len(b), len(a)), strings.Compare(a, b))
+		})
+	}
+}
+
+// Resolve matches a normalised place name, returning ErrPlaceUnknown when no
+// town matches well enough to trust.
+func (t *Towns) Resolve(query string) (Place, error) {
+	term, country := query, ""
+	for _, name := range t.countries {
+		if strings.Contains(" "+query+" ", " "+name+" ") {
+			country = name
+			term = strings.TrimSpace(strings.ReplaceAll(" "+query+" ", " "+name+" ", "  "))
+			break
+		}
+	}
+	if term == "" {
+		return Place{}, ErrPlaceUnknown
+	}
+
+	var (
+		best      *town
+		bestScore float64
+		bestAt    [][2]float64
+	)
+	for i := range t.towns {
+		candidate := &t.towns[i]
+		var at [][2]float64
+		if country != "" {
+			at = candidate.positions[country]
+		} else {
+			for _, positions := range candidate.positions {
+				at = append(at, positions...)
+			}
+		}
+		if len(at) == 0 {
+			continue
+		}
+		score := search.Similarity(candidate.lead, term)
+		if score < search.SimilarityThreshold {
+			continue
+		}
+		// Most museums breaks a tie, as in the database.
+		if best == nil || score > bestScore || (score == bestScore && len(at) > len(bestAt)) {
+			best, bestScore, bestAt = candidate, score, at
+		}
+	}
+	if best == nil {
+		return Place{}, ErrPlaceUnknown
+	}
+
+	var lat, lon float64
+	for _, p := range bestAt {
+		lat += p[0]
+		lon += p[1]
+	}
+	lat /= float64(len(bestAt))
+	lon /= float64(len(bestAt))
+
+	// The spread is measured over all of the town's museums, as the database
+	// measures it, not only those in the country named.
+	var spread float64
+	for _, positions := range best.positions {
+		for _, p := range positions {
+			spread = max(spread, geo.DistanceKm(lat, lon, p[0], p[1]))
+		}
+	}
+
+	radius, ok := trustedLocality(bestScore, spread)
+	if !ok {
+		return Place{}, ErrPlaceUnknown
+	}
+	return Place{
+		Query: query, DisplayName: best.locality,
+		Latitude: lat, Longitude: lon, RadiusKm: radius, Found: true,
+	}, nil
+}
@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"errors"
+	"testing"
+
+	"museum/internal/search"
+)
+
+func TestTownsResolve(t *testing.T) {
+	var towns Towns
+	for _, m := range []struct {
+		locality, country string
+		lat, lon          float64
+	}{
+		{"Amsterdam", "Netherlands", 52.3600, 4.8852},
+		{"Amsterdam", "Netherlands", 52.3584, 4.8811},
+		{"Gothenburg Municipality", "Sweden", 57.7089, 11.9746},
+		{"Gotha", "Germany", 50.9489, 10.7018},
+	} {
+		towns.Add(m.locality, search.Normalize(m.locality), m.country, m.lat, m.lon)
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"amsterdaam", "Amsterdam"},
+		{"gothenborg", "Gothenburg Municipality"},
+		{"gothenborg sweden", "Gothenburg Municipality"},
+		{"gothenborg germany", ""},
+		{"zzyzx", ""},
+	}
+	for _, tt := range tests {
+		place, err := towns.Resolve(tt.query)
+		if tt.want == "" {
+			if !errors.Is(err, ErrPlaceUnknown) {
+				t.Errorf("%q = %+v, %v; want ErrPlaceUnknown", tt.query, place, err)
+			}
+			continue
+		}
+		if err != nil || place.DisplayName != tt.want || !place.Found {
+			t.Errorf("%q = %+v, %v; want %s", tt.query, place, err, tt.want)
+		}
+	}
+
+	place, _ := towns.Resolve("amsterdaam")
+	if place.RadiusKm != minLocalityRadiusKm {
+		t.Errorf("radius = %v, want the %v km floor for two museums side by side", place.RadiusKm, minLocalityRadiusKm)
+	}
+}
@@ -171,7 +171,7 @@ func checkPosition(report *Report, m models.Museum) {
 
 	// A record within a kilometre of 0,0 is in the Gulf of Guinea. Museums are
 	// not; a parse that lost its digits is.
-	if distanceKm(m.Latitude, m.Longitude, 0, 0) < 1 {
+	if geo.DistanceKm(m.Latitude, m.Longitude, 0, 0) < 1 {
 		report.add(Finding{
 			Check: CheckNullIsland, Severity: Error, Subject: m.Name,
 			Detail:    "coordinates sit on null island, which means a failed parse rather than a location",
@@ -460,7 +460,7 @@ func checkOutliers(report *Report, byCountry map[string][]models.Museum) {
 
 		distances := make([]float64, len(museums))
 		for i, m := range museums {
-			distances[i] = distanceKm(medianLat, medianLon, m.Latitude, m.Longitude)
+			distances[i] = geo.DistanceKm(medianLat, medianLon, m.Latitude, m.Longitude)
 		}
 
 		// The threshold is the 99th percentile of the country's own spread,
@@ -577,23 +577,6 @@ func sortFindings(findings []Finding) {
 	})
 }
 
-// distanceKm returns the great-circle distance between two points.
-//
-// PostGIS computes distances for every query the API serves; this exists only
-// for the outlier check, which compares a museum against the spread of its
-// country's others in memory rather than per row in SQL.
-func distanceKm(lat1, lon1, lat2, lon2 float64) float64 {
-	const earthRadiusKm = 6371.0
-	rad := math.Pi / 180
-
-	dLat := (lat2 - lat1) * rad
-	dLon := (lon2 - lon1) * rad
-
-	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
-		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
-	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
-}
-
 // normalize reduces a string to its lowercase letters and digits.
 func normalize(s string) string {
 	var b strings.Builder
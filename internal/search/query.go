@@ -0,0 +1,130 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Query is a search as typed, read the way the database's full-text search
+// reads it: "quoted phrases" must occur as written, and a word or phrase with
+// a leading minus must not occur at all.
+//
+// The trigram matching knows nothing of either. Handed "museum -art" whole it
+// would normalise the minus away and rank art museums first, so it is given
+// Text, the words the query looks for, and the phrases and exclusions are
+// applied as filters on top.
+type Query struct {
+	// Text is what the query looks for, phrases included, with the syntax
+	// taken out.
+	Text string
+	// Phrases must each occur, word for word.
+	Phrases []string
+	// Excluded are the words and phrases that must not occur.
+	Excluded []string
+}
+
+// ParseQuery splits a query into what it looks for, its phrases and its
+// exclusions. A minus negates only at the start of a word, so "saint-etienne"
+// is a name and not "saint" without "etienne"; an unclosed quote runs to the
+// end of the query.
+func ParseQuery(s string) Query {
+	var (
+		q     Query
+		text  []string
+		runes = []rune(s)
+	)
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+		negated := false
+		if runes[i] == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+			negated = true
+			i++
+		}
+
+		var part string
+		quoted := runes[i] == '"'
+		if quoted {
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			part = string(runes[i+1 : end])
+			i = end + 1
+		} else {
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && runes[end] != '"' {
+				end++
+			}
+			part = string(runes[i:end])
+			i = end
+		}
+		if Normalize(part) == "" {
+			continue
+		}
+
+		switch {
+		case negated:
+			q.Excluded = append(q.Excluded, part)
+		case quoted:
+			q.Phrases = append(q.Phrases, part)
+			text = append(text, part)
+		default:
+			text = append(text, part)
+		}
+	}
+	q.Text = strings.Join(text, " ")
+	return q
+}
+
+// PhraseQuery is the phrases as the database's query syntax writes them, all
+// of them required, or "" when there are none.
+func (q Query) PhraseQuery() string {
+	return quoteAll(q.Phrases, " ")
+}
+
+// ExclusionQuery is the exclusions as the database's query syntax writes
+// them, any one of them enough to reject a document, or "" when there are
+// none.
+func (q Query) ExclusionQuery() string {
+	return quoteAll(q.Excluded, " or ")
+}
+
+// quoteAll quotes each part, so nothing in it is read as syntax, and joins
+// them. A quoted single word is just that word.
+func quoteAll(parts []string, sep string) string {
+	quoted := make([]string, len(parts))
+	for i, p := range parts {
+		quoted[i] = `"` + strings.ReplaceAll(p, `"`, " ") + `"`
+	}
+	return strings.Join(quoted, sep)
+}
+
+// Admits reports whether a document, normalised, holds every phrase and none
+// of the exclusions, word for word.
+//
+// This is the filter without the stemming the database applies, for the
+// catalogues that search without it: "-ship" rejects "ship" here but not
+// "ships".
+func (q Query) Admits(document string) bool {
+	for _, p := range q.Phrases {
+		if !containsWords(document, Normalize(p)) {
+			return false
+		}
+	}
+	for _, x := range q.Excluded {
+		if containsWords(document, Normalize(x)) {
+			return false
+		}
+	}
+	return true
+}
+
+// MuseumDocument is the text a museum's phrases and exclusions are tested
+// against, normalised: what the database's document holds, unstemmed.
+func MuseumDocument(name string, aliases []string, description string, classes []string, locality string) string {
+	parts := append([]string{name, description, locality}, aliases...)
+	return Normalize(strings.Join(append(parts, classes...), " | "))
+}
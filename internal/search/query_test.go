@@ -0,0 +1,63 @@
+package search
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	cases := []struct {
+		in       string
+		text     string
+		phrases  []string
+		excluded []string
+	}{
+		{in: "steamship museum", text: "steamship museum"},
+		{in: `"working life" museum`, text: "working life museum", phrases: []string{"working life"}},
+		{in: "museum -art", text: "museum", excluded: []string{"art"}},
+		{in: `museum -"modern art" -war`, text: "museum", excluded: []string{"modern art", "war"}},
+		// A hyphen inside a word is part of the name.
+		{in: "saint-etienne museum", text: "saint-etienne museum"},
+		{in: `"unclosed phrase`, text: "unclosed phrase", phrases: []string{"unclosed phrase"}},
+		{in: `- "" museum`, text: "museum"},
+	}
+	for _, tc := range cases {
+		q := ParseQuery(tc.in)
+		if q.Text != tc.text || !slices.Equal(q.Phrases, tc.phrases) || !slices.Equal(q.Excluded, tc.excluded) {
+			t.Errorf("ParseQuery(%q) = %+v, want text %q, phrases %q, excluded %q",
+				tc.in, q, tc.text, tc.phrases, tc.excluded)
+		}
+	}
+}
+
+func TestQueryAdmits(t *testing.T) {
+	doc := MuseumDocument("Bohuslän", nil, "working life museum in Gothenburg Municipality",
+		[]string{"steamship", "museum ship"}, "Gothenburg")
+
+	for query, want := range map[string]bool{
+		"bohuslan":                       true,
+		`"working life"`:                 true,
+		`"life working"`:                 false,
+		"museum -steamship":              false,
+		"museum -steam":                  true,
+		`museum -"museum ship"`:          false,
+		`"gothenburg municipality" -art`: true,
+	} {
+		if got := ParseQuery(query).Admits(doc); got != want {
+			t.Errorf("%q admits %q = %v, want %v", query, doc, got, want)
+		}
+	}
+}
+
+func TestQuerySyntaxForTheDatabase(t *testing.T) {
+	q := ParseQuery(`ships "working life" -"art" -war`)
+	if got, want := q.PhraseQuery(), `"working life"`; got != want {
+		t.Errorf("PhraseQuery = %s, want %s", got, want)
+	}
+	if got, want := q.ExclusionQuery(), `"art" or "war"`; got != want {
+		t.Errorf("ExclusionQuery = %s, want %s", got, want)
+	}
+	if ParseQuery("ships").ExclusionQuery() != "" {
+		t.Error("a query with no exclusions has an exclusion query")
+	}
+}
@@ -0,0 +1,92 @@
+package search
+
+import (
+	"math"
+	"slices"
+	"strings"
+)
+
+// Candidate is a museum as a name query sees it: the normalised forms the
+// catalogue stores beside each record.
+type Candidate struct {
+	Normalized         string
+	SearchText         string
+	LocalityNormalized string
+	// Aliases are the alternative names, each normalised.
+	Aliases   []string
+	Sitelinks int
+	Located   bool
+}
+
+// ScoreMuseum scores a museum against a normalised query as the database's
+// museum search does, and reports whether it matches at all. The weights are
+// the database's, and the reasoning for each is given there.
+func ScoreMuseum(term string, c Candidate) (float64, bool) {
+	if term == "" {
+		return 0, false
+	}
+	aliased := slices.Contains(c.Aliases, term)
+	prefixed := strings.HasPrefix(c.Normalized, term)
+	similar := Similarity(c.Normalized, term)
+	wordSimilar := WordSimilarity(term, c.Normalized)
+
+	if similar < SimilarityThreshold && wordSimilar < WordSimilarityThreshold && !prefixed &&
+		!aliased && Similarity(c.SearchText, term) < SimilarityThreshold {
+		return 0, false
+	}
+
+	var score float64
+	switch {
+	case c.Normalized == term:
+		score = 3.0
+	case aliased:
+		score = 2.5
+	case prefixed:
+		score = 1.5
+	case strings.Contains(c.Normalized, term):
+		score = 0.75
+	}
+	score += 0.7*similar + 0.3*wordSimilar
+	if len(c.LocalityNormalized) >= 4 && containsWords(term, c.LocalityNormalized) {
+		score += 0.4
+	}
+	score += 0.2 * math.Log(1+float64(max(c.Sitelinks, 0)))
+	if c.Located {
+		score += 0.01
+	}
+	return score, true
+}
+
+// ScoreExhibition scores an exhibition against a lowercased query as the
+// database's exhibition search does, and reports whether it matches at all.
+func ScoreExhibition(term, title, museum string) (float64, bool) {
+	if term == "" {
+		return 0, false
+	}
+	title, museum = strings.ToLower(title), strings.ToLower(museum)
+	similar := Similarity(title, term)
+	inTitle := strings.Contains(title, term)
+	inMuseum := strings.Contains(museum, term)
+	if similar < SimilarityThreshold && !inTitle && !inMuseum {
+		return 0, false
+	}
+
+	var score float64
+	switch {
+	case title == term:
+		score = 3.0
+	case strings.HasPrefix(title, term):
+		score = 1.5
+	case inTitle:
+		score = 0.75
+	case inMuseum:
+		score = 0.5
+	}
+	return score + similar, true
+}
+
+// containsWords reports whether phrase occurs in text on word boundaries. Both
+// are normalised, so words are separated by single spaces.
+func containsWords(text, phrase string) bool {
+	return strings.Contains(" "+text+" ", " "+phrase+" ")
+}
@@ -0,0 +1,108 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// The database matches near-misses with pg_trgm. A catalogue served from
+// anywhere else has to match them the same way, or a query that finds the
+// Kunsthaus against Postgres finds nothing against the file on a kiosk. These
+// reproduce pg_trgm's measures closely enough to rank the same museums first:
+// the trigrams are extracted as it extracts them, and the scores follow its
+// definitions.
+
+// Thresholds the database applies to every connection, and so the ones a
+// match elsewhere has to clear.
+const (
+	// SimilarityThreshold is pg_trgm.similarity_threshold, the bar for %.
+	SimilarityThreshold = 0.3
+	// WordSimilarityThreshold is pg_trgm.word_similarity_threshold, the bar
+	// for <%.
+	WordSimilarityThreshold = 0.55
+)
+
+// Trigrams returns the distinct trigrams of s in order of first appearance,
+// as pg_trgm forms them: each run of letters and digits, lowercased, padded
+// with two spaces in front and one behind.
+func Trigrams(s string) []string {
+	var out []string
+	seen := map[string]struct{}{}
+	for _, t := range orderedTrigrams(s) {
+		if _, dup := seen[t]; dup {
+			continue
+		}
+		seen[t] = struct{}{}
+		out = append(out, t)
+	}
+	return out
+}
+
+// orderedTrigrams returns every trigram of s in order, repeats included,
+// which is what an extent for word similarity is cut from.
+func orderedTrigrams(s string) []string {
+	var out []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			out = append(out, string(padded[i:i+3]))
+		}
+	}
+	return out
+}
+
+// Similarity is pg_trgm's similarity: the trigrams two strings share, as a
+// share of all the trigrams either has.
+func Similarity(a, b string) float64 {
+	ta, tb := Trigrams(a), Trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	in := make(map[string]struct{}, len(ta))
+	for _, t := range ta {
+		in[t] = struct{}{}
+	}
+	shared := 0
+	for _, t := range tb {
+		if _, ok := in[t]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+// WordSimilarity is pg_trgm's word_similarity: how well query matches the
+// best continuous extent of text, so a short query is not penalised for
+// everything else a long name says. word_similarity("word", "two words") is
+// 0.8, as in the pg_trgm documentation.
+func WordSimilarity(query, text string) float64 {
+	tq := Trigrams(query)
+	if len(tq) == 0 {
+		return 0
+	}
+	in := make(map[string]struct{}, len(tq))
+	for _, t := range tq {
+		in[t] = struct{}{}
+	}
+
+	ordered := orderedTrigrams(text)
+	best := 0.0
+	for start := range ordered {
+		shared := map[string]struct{}{}
+		extra := map[string]struct{}{}
+		for _, t := range ordered[start:] {
+			if _, ok := in[t]; ok {
+				shared[t] = struct{}{}
+			} else {
+				extra[t] = struct{}{}
+			}
+			score := float64(len(shared)) / float64(len(tq)+len(extra))
+			if score > best {
+				best = score
+			}
+		}
+	}
+	return best
+}
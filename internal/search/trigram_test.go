@@ -0,0 +1,75 @@
+package search
+
+import (
+	"math"
+	"testing"
+)
+
+// The expected values are what pg_trgm itself returns, so a catalogue served
+// without the database ranks as the database does.
+func TestTrigramMeasures(t *testing.T) {
+	cases := []struct {
+		name    string
+		measure func(a, b string) float64
+		a, b    string
+		want    float64
+	}{
+		{"documented similarity", Similarity, "word", "two words", 0.363636},
+		{"documented word similarity", WordSimilarity, "word", "two words", 0.8},
+		{"identical", Similarity, "kunsthaus zurich", "kunsthaus zurich", 1},
+		{"nothing shared", Similarity, "louvre", "prado", 0},
+		{"empty", Similarity, "", "prado", 0},
+		{"case and punctuation ignored", Similarity, "Musée d'Orsay", "musée d orsay", 1},
+		// The two from the search's own comments: whole-name similarity ranks
+		// the Kunsthaus above the National Museum.
+		{"kunsthaus", Similarity, "kunsthaus zurich", "kunstmuseum zurich", 0.5},
+		{"national museum", Similarity, "national museum zurich", "kunstmuseum zurich", 0.4},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.measure(tc.a, tc.b); math.Abs(got-tc.want) > 0.001 {
+				t.Errorf("got %.4f, want %.4f", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestScoreMuseum(t *testing.T) {
+	orsay := Candidate{Normalized: "musee d orsay", SearchText: "musee d orsay paris", LocalityNormalized: "paris",
+		Aliases: []string{"orsay"}, Sitelinks: 90, Located: true}
+	quai := Candidate{Normalized: "musee du quai branly", SearchText: "musee du quai branly paris", LocalityNormalized: "paris", Located: true}
+
+	exact, ok := ScoreMuseum("musee d orsay", orsay)
+	if !ok {
+		t.Fatal("an exact name did not match")
+	}
+	alias, _ := ScoreMuseum("orsay", orsay)
+	typo, ok := ScoreMuseum("muse d orsey", orsay)
+	if !ok {
+		t.Fatal("a near-miss did not match")
+	}
+	if !(exact > alias && alias > typo) {
+		t.Errorf("exact %.2f, alias %.2f, typo %.2f: want them in that order", exact, alias, typo)
+	}
+
+	elsewhere := quai
+	elsewhere.LocalityNormalized, elsewhere.SearchText = "lyon", "musee du quai branly lyon"
+	here, _ := ScoreMuseum("quai branly paris", quai)
+	if there, _ := ScoreMuseum("quai branly paris", elsewhere); here <= there {
+		t.Errorf("the museum in the town the query names scored %.2f, not above %.2f", here, there)
+	}
+	if _, ok := ScoreMuseum("rijksmuseum", quai); ok {
+		t.Errorf("an unrelated name matched")
+	}
+}
+
+func TestScoreExhibition(t *testing.T) {
+	title, _ := ScoreExhibition("vikingr", "Vikingr", "Göteborgs stadsmuseum")
+	venue, ok := ScoreExhibition("stadsmuseum", "Vikingr", "Göteborgs stadsmuseum")
+	if !ok || venue >= title {
+		t.Errorf("venue %.2f (matched %v), title %.2f: the venue must match, below the title", venue, ok, title)
+	}
+	if _, ok := ScoreExhibition("impressionism", "Vikingr", "Göteborgs stadsmuseum"); ok {
+		t.Errorf("an unrelated title matched")
+	}
+}
@@ -15,6 +15,8 @@ import (
 
 	"github.com/minio/minio-go/v7/pkg/notification"
 	"github.com/segmentio/kafka-go"
+
+	"museum/internal/enrich"
 )
 
 // errEmptyKey means a notification referenced an object with a blank key.
@@ -31,6 +33,8 @@ var errEmptyKey = errors.New("empty object key")
 type Iterator[T any] struct {
 	msgIterator MessageIterator
 	loader      LoaderFunc[T]
+	retry       enrich.Retry
+	onFailed    FailureFunc
 }
 
 // NewIterator constructs an Iterator for the provided message source and object
@@ -42,13 +46,26 @@ func NewIterator[T any](iterator MessageIterator, loader LoaderFunc[T]) *Iterato
 	}
 }
 
+// SetRetry sets how a load failing transiently is retried. Without it an
+// object is loaded once.
+func (it *Iterator[T]) SetRetry(r enrich.Retry) { it.retry = r }
+
+// SetOnFailed sets what is told of an object that could not be loaded. The
+// message naming it is committed only if fn returns nil. Kafka commits are
+// cumulative, so a caller whose fn fails should stop consuming: the next
+// message committed would otherwise carry the offset past this one.
+func (it *Iterator[T]) SetOnFailed(fn FailureFunc) { it.onFailed = fn }
+
 // Objects starts a goroutine that deserialises each message as a MinIO
 // notification, loads the referenced object, emits it, and then commits the
 // message offset.
 //
 // Every per-message failure is logged and skipped rather than being fatal: a
 // single malformed event, a MinIO keep-alive with no records, or an object that
-// has since been deleted must not take the consumer down. The output channel is
+// has since been deleted must not take the consumer down. An event that names
+// no object is only logged, there being nothing to try again; an object that
+// could not be loaded is retried as SetRetry allows and then reported to the
+// function SetOnFailed names, under the stage "load". The output channel is
 // closed when the underlying Messages() channel closes or ctx is cancelled.
 func (it *Iterator[T]) Objects(ctx context.Context) <-chan *FetchedObject[T] {
 	out := make(chan *FetchedObject[T])
@@ -91,15 +108,25 @@ func (it *Iterator[T]) Objects(ctx context.Context) <-chan *FetchedObject[T] {
 					continue
 				}
 
-				data, err := it.loader(ctx, record.S3.Bucket.Name, key)
+				bucket := record.S3.Bucket.Name
+				var data T
+				attempts, err := it.retry.Run(ctx, func() (err error) {
+					data, err = it.loader(ctx, bucket, key)
+					return err
+				})
+				if ctx.Err() != nil {
+					return
+				}
 				if err != nil {
-					log.Printf("Skipping %s/%s: %v", record.S3.Bucket.Name, key, err)
-					pending.done()
+					log.Printf("Skipping %s/%s after %d attempts: %v", bucket, key, attempts, err)
+					if it.failed(ctx, bucket, key, enrich.Failure{Stage: LoadStage, Err: err, Attempts: attempts}) {
+						pending.done()
+					}
 					continue
 				}
 
 				select {
-				case out <- &FetchedObject[T]{Data: data, Event: event, Ack: pending.done}:
+				case out <- &FetchedObject[T]{Data: data, Event: event, Bucket: bucket, Key: key, Ack: pending.done}:
 				case <-ctx.Done():
 					return
 				}
@@ -111,6 +138,19 @@ func (it *Iterator[T]) Objects(ctx context.Context) <-chan *FetchedObject[T] {
 	return out
 }
 
+// failed reports an object that could not be loaded, and says whether the
+// message naming it may be committed.
+func (it *Iterator[T]) failed(ctx context.Context, bucket, key string, f enrich.Failure) bool {
+	if it.onFailed == nil {
+		return true
+	}
+	if err := it.onFailed(ctx, bucket, key, f); err != nil {
+		log.Printf("Cannot record the failure of %s/%s: %v (leaving it unacknowledged)", bucket, key, err)
+		return false
+	}
+	return true
+}
+
 // acker counts outstanding acknowledgements for one message and runs commit
 // once the last one arrives. Repeated calls beyond the count are ignored, so a
 // consumer that acknowledges twice cannot commit early.
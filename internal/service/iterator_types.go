@@ -2,8 +2,11 @@ package service
 
 import (
 	"context"
+
 	"github.com/minio/minio-go/v7/pkg/notification"
 	"github.com/segmentio/kafka-go"
+
+	"museum/internal/enrich"
 )
 
 // MessageIterator defines the contract for consuming messages from a Kafka topic.
@@ -44,9 +47,20 @@ type FetchedObject[T any] struct {
 	Data T
 	// Event is the original MinIO/S3 notification event that triggered the fetch.
 	Event notification.Info
+	// Bucket and Key name the object Data was loaded from.
+	Bucket string
+	Key    string
 	// Ack must be called once the consumer has finished with the object. The
 	// Kafka offset is committed only after every object from a message has been
 	// acknowledged, so an interrupted run redelivers unfinished work instead of
 	// dropping it. It is safe to call more than once.
 	Ack func()
 }
+
+// LoadStage is the stage an object that could not be loaded is reported
+// under.
+const LoadStage = "load"
+
+// FailureFunc is told of an object a consumer gave up on: which, in which
+// stage, and why. An error returned means the failure was not recorded.
+type FailureFunc func(ctx context.Context, bucket, key string, f enrich.Failure) error
@@ -0,0 +1,771 @@
+package sqlite
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"museum/internal/models"
+	"museum/internal/postgres"
+	"museum/internal/search"
+	"museum/pkg/geo"
+)
+
+// maxCandidates caps how many rows the full-text index hands to a search for
+// scoring. The index ranks by how many of the query's trigrams a row holds, so
+// anything past this is a weaker match than everything before it.
+const maxCandidates = 2000
+
+// Catalogue answers the API from a catalogue file.
+type Catalogue struct {
+	db     *sql.DB
+	counts postgres.Counts
+	gen    postgres.Generation
+
+	// places holds names resolved while serving. The file is read-only, so
+	// they last as long as the process.
+	placesMu sync.Mutex
+	places   map[string]postgres.Place
+
+	towns     postgres.Towns
+	townsOnce sync.Once
+	townsErr  error
+}
+
+// Open opens a catalogue file for serving.
+func Open(ctx context.Context, path string) (*Catalogue, error) {
+	if !haveDriver() {
+		return nil, ErrNoDriver
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("open catalogue: %w", err)
+	}
+	// Immutable, so SQLite takes no locks and reads without checking for
+	// writers: there are none, the file is replaced whole by a new export.
+	db, err := sql.Open(DriverName, "file:"+path+"?mode=ro&immutable=1")
+	if err != nil {
+		return nil, fmt.Errorf("open catalogue: %w", err)
+	}
+
+	c := &Catalogue{db: db, places: map[string]postgres.Place{}}
+	if err := c.readMeta(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open catalogue %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Close releases the file.
+func (c *Catalogue) Close() error {
+	return c.db.Close()
+}
+
+// readMeta checks the format and reads what the file never changes: its
+// generation and its counts.
+func (c *Catalogue) readMeta(ctx context.Context) error {
+	meta := map[string]string{}
+	rows, err := c.db.QueryContext(ctx, `SELECT key, value FROM meta`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return err
+		}
+		meta[key] = value
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if meta["format"] != formatVersion {
+		return fmt.Errorf("format %q, want %q: export the file again", meta["format"], formatVersion)
+	}
+
+	c.gen.Number, _ = strconv.ParseInt(meta["generation"], 10, 64)
+	c.gen.ChangedAt = parseTime(meta["changed_at"])
+
+	const counts = `
+SELECT
+    (SELECT count(*) FROM museums),
+    (SELECT count(*) FROM museums WHERE lat IS NOT NULL),
+    (SELECT count(*) FROM museums WHERE website <> ''),
+    (SELECT count(*) FROM exhibitions),
+    (SELECT count(DISTINCT country) FROM museums WHERE country <> ''),
+    (SELECT max(updated_at) FROM museums)`
+
+	var updated sql.NullString
+	if err := c.db.QueryRowContext(ctx, counts).Scan(
+		&c.counts.Museums, &c.counts.WithCoordinates, &c.counts.WithWebsite,
+		&c.counts.Exhibitions, &c.counts.Countries, &updated); err != nil {
+		return err
+	}
+	if updated.Valid {
+		at := parseTime(updated.String)
+		c.counts.LastUpdated = &at
+	}
+	return nil
+}
+
+// Ping reports whether the file can still be read.
+func (c *Catalogue) Ping(ctx context.Context) error {
+	return c.db.PingContext(ctx)
+}
+
+// Counts summarises the catalogue. The file does not change, so it was counted
+// once, on opening.
+func (c *Catalogue) Counts(context.Context) (postgres.Counts, error) {
+	return c.counts, nil
+}
+
+// Generation returns the generation the file was exported at.
+func (c *Catalogue) Generation(context.Context) (postgres.Generation, error) {
+	return c.gen, nil
+}
+
+// museumColumns is what every museum query selects, in scanMuseum's order.
+const museumColumns = `m.id, m.name, m.country, m.locality, m.description, m.website, m.wikipedia_url,
+       m.wikidata_id, m.street, m.postcode, m.aliases, m.sources, m.classes, m.verified,
+       m.sitelinks, m.location_approximate, m.lat, m.lon, m.retired_at,
+       m.normalized, m.search_text, m.locality_normalized, m.aliases_normalized`
+
+// row is a museum as read back, with what scoring it needs.
+type row struct {
+	hit       postgres.Hit
+	candidate search.Candidate
+	located   bool
+}
+
+// scanMuseum reads a row selected with museumColumns.
+func scanMuseum(rows *sql.Rows) (row, error) {
+	var (
+		r                                     row
+		m                                     = &r.hit.Museum
+		aliases, sources, classes, normalised string
+		lat, lon                              sql.NullFloat64
+		retired                               sql.NullString
+	)
+	if err := rows.Scan(&r.hit.ID, &m.Name, &m.Country, &m.Locality, &m.Description,
+		&m.Website, &m.WikipediaURL, &m.WikidataID, &m.Address.Road, &m.Address.Postcode,
+		&aliases, &sources, &classes, &m.Verified, &m.Sitelinks, &r.hit.ApproximateLocation,
+		&lat, &lon, &retired,
+		&r.candidate.Normalized, &r.candidate.SearchText, &r.candidate.LocalityNormalized, &normalised,
+	); err != nil {
+		return row{}, fmt.Errorf("scan: %w", err)
+	}
+	m.AlsoKnownAs = parseList(aliases)
+	m.Sources = parseList(sources)
+	m.Classes = parseList(classes)
+	if lat.Valid && lon.Valid {
+		m.Latitude, m.Longitude = lat.Float64, lon.Float64
+		r.located = true
+	}
+	if retired.Valid {
+		at := parseTime(retired.String)
+		r.hit.RetiredAt = &at
+	}
+	r.candidate.Aliases = parseList(normalised)
+	r.candidate.Sitelinks = m.Sitelinks
+	r.candidate.Located = r.located
+	return r, nil
+}
+
+// museums runs a query selecting museumColumns.
+func (c *Catalogue) museums(ctx context.Context, query string, args ...any) ([]row, error) {
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []row
+	for rows.Next() {
+		r, err := scanMuseum(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// NearbyVerified returns the museums within radiusKm of a point, nearest first.
+func (c *Catalogue) NearbyVerified(ctx context.Context, lat, lon, radiusKm float64, limit, offset int, verifiedOnly bool) (postgres.Page, error) {
+	var found []postgres.Hit
+	for _, b := range boxesAround(lat, lon, radiusKm) {
+		rows, err := c.museums(ctx, `
+SELECT `+museumColumns+`
+FROM museums m
+JOIN museums_rtree r ON r.id = m.id
+WHERE r.min_lat <= ? AND r.max_lat >= ? AND r.min_lon <= ? AND r.max_lon >= ?
+  AND m.retired_at IS NULL
+  AND (NOT ? OR m.verified)`,
+			b.north, b.south, b.east, b.west, verifiedOnly)
+		if err != nil {
+			return postgres.Page{}, fmt.Errorf("nearby: %w", err)
+		}
+		for _, r := range rows {
+			// The box is a square around the circle; the corners are not in it.
+			d := geo.DistanceKm(lat, lon, r.hit.Museum.Latitude, r.hit.Museum.Longitude)
+			if d <= radiusKm {
+				r.hit.DistanceKm = d
+				found = append(found, r.hit)
+			}
+		}
+	}
+
+	slices.SortFunc(found, func(a, b postgres.Hit) int {
+		return cmp.Or(cmp.Compare(a.DistanceKm, b.DistanceKm), cmp.Compare(a.ID, b.ID))
+	})
+	return page(found, limit, offset), nil
+}
+
+// Search returns the museums whose name, aliases or locality match a query,
+// best first, scored as the database scores them. Phrases and exclusions
+// apply as they do there, unstemmed; descriptions and classes filter but do
+// not find.
+func (c *Catalogue) Search(ctx context.Context, query string, limit, offset int) (postgres.Page, error) {
+	parsed := search.ParseQuery(query)
+	term := search.Normalize(parsed.Text)
+	if term == "" {
+		return postgres.Page{}, nil
+	}
+
+	// The trigram index finds what shares the query's trigrams, and a range on
+	// the name finds what begins with it: together, everything the database's
+	// WHERE would have let through, which is then scored in full.
+	where := `(m.normalized >= ? AND m.normalized < ?)`
+	args := []any{term, term + "\U0010FFFF"}
+	if match := ftsQuery(term); match != "" {
+		where += `
+   OR m.id IN (SELECT rowid FROM museums_fts WHERE museums_fts MATCH ? ORDER BY rank LIMIT ?)`
+		args = append(args, match, maxCandidates)
+	}
+	rows, err := c.museums(ctx, `
+SELECT `+museumColumns+`
+FROM museums m
+WHERE (`+where+`)
+  AND m.retired_at IS NULL`, args...)
+	if err != nil {
+		return postgres.Page{}, fmt.Errorf("search: %w", err)
+	}
+
+	var found []postgres.Hit
+	for _, r := range rows {
+		score, ok := search.ScoreMuseum(term, r.candidate)
+		if !ok {
+			continue
+		}
+		m := r.hit.Museum
+		if !parsed.Admits(search.MuseumDocument(m.Name, m.AlsoKnownAs, m.Description, m.Classes, m.Locality)) {
+			continue
+		}
+		r.hit.Score = score
+		found = append(found, r.hit)
+	}
+
+	normalized := make(map[int64]string, len(rows))
+	for _, r := range rows {
+		normalized[r.hit.ID] = r.candidate.Normalized
+	}
+	slices.SortFunc(found, func(a, b postgres.Hit) int {
+		return cmp.Or(
+			cmp.Compare(b.Score, a.Score),
+			cmp.Compare(len(normalized[a.ID]), len(normalized[b.ID])),
+			cmp.Compare(a.Museum.Name, b.Museum.Name),
+			cmp.Compare(a.ID, b.ID))
+	})
+	return page(found, limit, offset), nil
+}
+
+// MuseumByID returns one museum by numeric or Wikidata id. A retired museum is
+// returned, marked as such, as the database returns it.
+func (c *Catalogue) MuseumByID(ctx context.Context, id string) (postgres.Hit, error) {
+	var numeric *int64
+	if parsed, err := strconv.ParseInt(id, 10, 64); err == nil {
+		numeric = &parsed
+	}
+	rows, err := c.museums(ctx, `
+SELECT `+museumColumns+`
+FROM museums m
+WHERE m.id = ? OR m.wikidata_id = ?
+LIMIT 1`, numeric, id)
+	if err != nil {
+		return postgres.Hit{}, fmt.Errorf("museum %q: %w", id, err)
+	}
+	if len(rows) == 0 {
+		return postgres.Hit{}, fmt.Errorf("museum %q: %w", id, postgres.ErrNotFound)
+	}
+	return rows[0].hit, nil
+}
+
+// MuseumHistory answers with no revisions: the file is a snapshot of the
+// catalogue, not of how it came to be. A museum the file does not hold is
+// reported as not found.
+func (c *Catalogue) MuseumHistory(ctx context.Context, id string, _, _ int) (postgres.History, error) {
+	hit, err := c.MuseumByID(ctx, id)
+	if err != nil {
+		return postgres.History{}, err
+	}
+	return postgres.History{MuseumID: hit.ID}, nil
+}
+
+// Points returns museum positions for drawing, most prominent first.
+func (c *Catalogue) Points(ctx context.Context, west, south, east, north float64, hasBox bool, limit int) ([]postgres.Point, error) {
+	rows, err := c.db.QueryContext(ctx, `
+SELECT m.id, m.lat, m.lon
+FROM museums m
+WHERE m.lat IS NOT NULL
+  AND m.retired_at IS NULL
+  AND (NOT ? OR m.id IN (SELECT id FROM museums_rtree
+                          WHERE min_lat <= ? AND max_lat >= ? AND min_lon <= ? AND max_lon >= ?))
+ORDER BY m.sitelinks DESC, m.id
+LIMIT ?`, hasBox, north, south, east, west, limit)
+	if err != nil {
+		return nil, fmt.Errorf("points: %w", err)
+	}
+	defer rows.Close()
+
+	points := make([]postgres.Point, 0, min(limit, 4096))
+	for rows.Next() {
+		var p postgres.Point
+		if err := rows.Scan(&p.ID, &p.Lat, &p.Lon); err != nil {
+			return nil, fmt.Errorf("scan point: %w", err)
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+// PointClusters answers a map view, grouping museums into a grid of cellDegrees
+// when the view holds more than rawBelow of them. The grid is the database's:
+// cells are floored, the centre is the mean of the members, and the
+// representative is the most prominent.
+func (c *Catalogue) PointClusters(ctx context.Context, west, south, east, north float64, hasBox bool, cellDegrees float64, rawBelow, limit int) (postgres.Clustering, error) {
+	var clustering postgres.Clustering
+	if err := c.db.QueryRowContext(ctx, `
+SELECT count(*)
+FROM museums m
+WHERE m.lat IS NOT NULL
+  AND m.retired_at IS NULL
+  AND (NOT ? OR m.id IN (SELECT id FROM museums_rtree
+                          WHERE min_lat <= ? AND max_lat >= ? AND min_lon <= ? AND max_lon >= ?))`,
+		hasBox, north, south, east, west).Scan(&clustering.Museums); err != nil {
+		return postgres.Clustering{}, fmt.Errorf("point clusters: %w", err)
+	}
+
+	if clustering.Museums <= int64(rawBelow) {
+		points, err := c.Points(ctx, west, south, east, north, hasBox, rawBelow)
+		if err != nil {
+			return postgres.Clustering{}, err
+		}
+		clustering.Points = points
+		return clustering, nil
+	}
+
+	type cell struct{ x, y float64 }
+	type group struct {
+		latSum, lonSum float64
+		cluster        postgres.Cluster
+		sitelinks      int
+	}
+	groups := map[cell]*group{}
+
+	// Most prominent first, so the first museum into a cell represents it.
+	rows, err := c.db.QueryContext(ctx, `
+SELECT m.id, m.name, m.sitelinks, m.lat, m.lon
+FROM museums m
+WHERE m.lat IS NOT NULL
+  AND m.retired_at IS NULL
+  AND (NOT ? OR m.id IN (SELECT id FROM museums_rtree
+                          WHERE min_lat <= ? AND max_lat >= ? AND min_lon <= ? AND max_lon >= ?))
+ORDER BY m.sitelinks DESC, m.id`, hasBox, north, south, east, west)
+	if err != nil {
+		return postgres.Clustering{}, fmt.Errorf("point clusters: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id        int64
+			name      string
+			sitelinks int
+			lat, lon  float64
+		)
+		if err := rows.Scan(&id, &name, &sitelinks, &lat, &lon); err != nil {
+			return postgres.Clustering{}, fmt.Errorf("scan cluster: %w", err)
+		}
+		key := cell{math.Floor(lon / cellDegrees), math.Floor(lat / cellDegrees)}
+		g, ok := groups[key]
+		if !ok {
+			g = &group{cluster: postgres.Cluster{RepresentativeID: id, RepresentativeName: name}}
+			groups[key] = g
+		}
+		g.latSum += lat
+		g.lonSum += lon
+		g.cluster.Museums++
+	}
+	if err := rows.Err(); err != nil {
+		return postgres.Clustering{}, fmt.Errorf("point clusters: %w", err)
+	}
+
+	shows, err := c.db.QueryContext(ctx, `
+SELECT e.lat, e.lon
+FROM exhibitions e
+WHERE e.lat IS NOT NULL
+  AND (e.ends_on IS NULL OR e.ends_on >= ?)
+  AND (NOT ? OR e.id IN (SELECT id FROM exhibitions_rtree
+                          WHERE min_lat <= ? AND max_lat >= ? AND min_lon <= ? AND max_lon >= ?))`,
+		today(), hasBox, north, south, east, west)
+	if err != nil {
+		return postgres.Clustering{}, fmt.Errorf("point clusters: %w", err)
+	}
+	defer shows.Close()
+	for shows.Next() {
+		var lat, lon float64
+		if err := shows.Scan(&lat, &lon); err != nil {
+			return postgres.Clustering{}, fmt.Errorf("scan cluster: %w", err)
+		}
+		if g, ok := groups[cell{math.Floor(lon / cellDegrees), math.Floor(lat / cellDegrees)}]; ok {
+			g.cluster.Exhibitions++
+		}
+	}
+	if err := shows.Err(); err != nil {
+		return postgres.Clustering{}, fmt.Errorf("point clusters: %w", err)
+	}
+
+	for _, g := range groups {
+		g.cluster.Lat = g.latSum / float64(g.cluster.Museums)
+		g.cluster.Lon = g.lonSum / float64(g.cluster.Museums)
+		clustering.Clusters = append(clustering.Clusters, g.cluster)
+	}
+	slices.SortFunc(clustering.Clusters, func(a, b postgres.Cluster) int {
+		return cmp.Or(cmp.Compare(b.Museums, a.Museums), cmp.Compare(a.RepresentativeID, b.RepresentativeID))
+	})
+	if len(clustering.Clusters) > limit {
+		clustering.Clusters = clustering.Clusters[:limit]
+	}
+	return clustering, nil
+}
+
+// exhibitionColumns is what every exhibition query selects, in scanExhibition's
+// order.
+const exhibitionColumns = `e.url, e.title, e.museum, e.museum_wikidata_id, e.starts_on, e.ends_on,
+       e.source_page, e.scraped_at, e.permanent, e.lat, e.lon`
+
+// exhibitions runs a query selecting exhibitionColumns, marking each listing
+// running or upcoming against today as the database does.
+func (c *Catalogue) exhibitions(ctx context.Context, query string, args ...any) ([]postgres.ExhibitionHit, error) {
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []postgres.ExhibitionHit
+	now := time.Now()
+	for rows.Next() {
+		var (
+			hit          postgres.ExhibitionHit
+			starts, ends sql.NullString
+			scraped      string
+			lat, lon     sql.NullFloat64
+		)
+		if err := rows.Scan(&hit.URL, &hit.Title, &hit.Museum, &hit.MuseumWikidataID,
+			&starts, &ends, &hit.SourcePage, &scraped, &hit.Permanent, &lat, &lon); err != nil {
+			return nil, fmt.Errorf("scan exhibition: %w", err)
+		}
+		hit.Start, hit.End = parseDate(starts), parseDate(ends)
+		hit.ScrapedAt = parseTime(scraped)
+		if lat.Valid && lon.Valid {
+			hit.Latitude, hit.Longitude = lat.Float64, lon.Float64
+		}
+		hit.Running = hit.Permanent || hit.Start == nil || !hit.Start.After(now)
+		hit.Upcoming = !hit.Permanent && hit.Start != nil && hit.Start.After(now)
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+// currentExhibitions is the database's test for a listing worth returning:
+// not over, and started unless upcoming ones were asked for.
+const currentExhibitions = `(e.ends_on IS NULL OR e.ends_on >= ?)
+  AND (? OR e.starts_on IS NULL OR e.starts_on <= ?)`
+
+// ExhibitionsNearby returns what is on show within radiusKm, soonest to close
+// first.
+func (c *Catalogue) ExhibitionsNearby(ctx context.Context, lat, lon, radiusKm float64, includeUpcoming bool, limit int) ([]postgres.ExhibitionHit, error) {
+	day := today()
+	var found []postgres.ExhibitionHit
+	for _, b := range boxesAround(lat, lon, radiusKm) {
+		hits, err := c.exhibitions(ctx, `
+SELECT `+exhibitionColumns+`
+FROM exhibitions e
+JOIN exhibitions_rtree r ON r.id = e.id
+WHERE r.min_lat <= ? AND r.max_lat >= ? AND r.min_lon <= ? AND r.max_lon >= ?
+  AND `+currentExhibitions,
+			b.north, b.south, b.east, b.west, day, includeUpcoming, day)
+		if err != nil {
+			return nil, fmt.Errorf("exhibitions nearby: %w", err)
+		}
+		for _, hit := range hits {
+			d := geo.DistanceKm(lat, lon, hit.Latitude, hit.Longitude)
+			if d <= radiusKm {
+				hit.DistanceKm = d
+				found = append(found, hit)
+			}
+		}
+	}
+
+	slices.SortFunc(found, func(a, b postgres.ExhibitionHit) int {
+		return cmp.Or(compareEnds(a.End, b.End), cmp.Compare(a.DistanceKm, b.DistanceKm))
+	})
+	if len(found) > limit {
+		found = found[:limit]
+	}
+	return found, nil
+}
+
+// SearchExhibitions finds what is on show by name, best match first, scored as
+// the database scores it, with phrases and exclusions as Search applies them.
+func (c *Catalogue) SearchExhibitions(ctx context.Context, query string, lat, lon, radiusKm float64, near, includeUpcoming bool, limit, offset int) ([]postgres.ExhibitionHit, int64, error) {
+	parsed := search.ParseQuery(query)
+	term := strings.ToLower(strings.TrimSpace(parsed.Text))
+	if term == "" {
+		return nil, 0, nil
+	}
+
+	// A term too short for a trigram can still match as a substring, which the
+	// index cannot serve. Listings are few enough to scan for those.
+	day := today()
+	where := `instr(lower(e.title), ?) > 0 OR instr(lower(e.museum), ?) > 0`
+	args := []any{term, term}
+	if match := ftsQuery(term); match != "" {
+		where = `e.id IN (SELECT rowid FROM exhibitions_fts WHERE exhibitions_fts MATCH ? ORDER BY rank LIMIT ?)`
+		args = []any{match, maxCandidates}
+	}
+	hits, err := c.exhibitions(ctx, `
+SELECT `+exhibitionColumns+`
+FROM exhibitions e
+WHERE (`+where+`)
+  AND `+currentExhibitions,
+		append(args, day, includeUpcoming, day)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("search exhibitions %q: %w", query, err)
+	}
+
+	type scored struct {
+		hit   postgres.ExhibitionHit
+		score float64
+	}
+	var found []scored
+	for _, hit := range hits {
+		score, ok := search.ScoreExhibition(term, hit.Title, hit.Museum)
+		if !ok || !parsed.Admits(search.Normalize(hit.Title+" | "+hit.Museum)) {
+			continue
+		}
+		if near {
+			if hit.Latitude == 0 && hit.Longitude == 0 {
+				continue
+			}
+			hit.DistanceKm = geo.DistanceKm(lat, lon, hit.Latitude, hit.Longitude)
+			if hit.DistanceKm > radiusKm {
+				continue
+			}
+		}
+		found = append(found, scored{hit, score})
+	}
+
+	slices.SortFunc(found, func(a, b scored) int {
+		return cmp.Or(cmp.Compare(b.score, a.score),
+			cmp.Compare(a.hit.DistanceKm, b.hit.DistanceKm),
+			compareEnds(a.hit.End, b.hit.End))
+	})
+	total := int64(len(found))
+	found = found[min(offset, len(found)):]
+	found = found[:min(limit, len(found))]
+
+	out := make([]postgres.ExhibitionHit, len(found))
+	for i, s := range found {
+		out[i] = s.hit
+	}
+	return out, total, nil
+}
+
+// ExhibitionCoverage reports what is known about an area: how many museums it
+// holds, how many have a site to read, and when one was last read.
+func (c *Catalogue) ExhibitionCoverage(ctx context.Context, lat, lon, radiusKm float64) (postgres.Coverage, error) {
+	var (
+		coverage postgres.Coverage
+		sites    []string
+	)
+	for _, b := range boxesAround(lat, lon, radiusKm) {
+		rows, err := c.db.QueryContext(ctx, `
+SELECT m.lat, m.lon, m.site
+FROM museums m
+JOIN museums_rtree r ON r.id = m.id
+WHERE r.min_lat <= ? AND r.max_lat >= ? AND r.min_lon <= ? AND r.max_lon >= ?`,
+			b.north, b.south, b.east, b.west)
+		if err != nil {
+			return postgres.Coverage{}, fmt.Errorf("exhibition coverage: %w", err)
+		}
+		for rows.Next() {
+			var (
+				mlat, mlon float64
+				site       string
+			)
+			if err := rows.Scan(&mlat, &mlon, &site); err != nil {
+				rows.Close()
+				return postgres.Coverage{}, fmt.Errorf("exhibition coverage: %w", err)
+			}
+			if geo.DistanceKm(lat, lon, mlat, mlon) > radiusKm {
+				continue
+			}
+			coverage.MuseumsInArea++
+			if site != "" {
+				coverage.MuseumsWithSite++
+				sites = append(sites, site)
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return postgres.Coverage{}, fmt.Errorf("exhibition coverage: %w", err)
+		}
+	}
+	if len(sites) == 0 {
+		return coverage, nil
+	}
+
+	var last sql.NullString
+	if err := c.db.QueryRowContext(ctx,
+		`SELECT max(at) FROM site_attempts WHERE site IN (SELECT value FROM json_each(?))`,
+		jsonList(sites)).Scan(&last); err != nil {
+		return postgres.Coverage{}, fmt.Errorf("exhibition coverage: %w", err)
+	}
+	if last.Valid {
+		at := parseTime(last.String)
+		coverage.LastScraped = &at
+	}
+	return coverage, nil
+}
+
+// EachMuseum streams the whole catalogue in id order.
+func (c *Catalogue) EachMuseum(ctx context.Context, fn func(id int64, museum models.Museum)) error {
+	rows, err := c.db.QueryContext(ctx, `SELECT `+museumColumns+` FROM museums m ORDER BY m.id`)
+	if err != nil {
+		return fmt.Errorf("scan catalogue: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		r, err := scanMuseum(rows)
+		if err != nil {
+			return fmt.Errorf("scan catalogue: %w", err)
+		}
+		fn(r.hit.ID, r.hit.Museum)
+	}
+	return rows.Err()
+}
+
+// MuseumIDs returns the ids of at most limit current museums after the id
+// after, in order.
+func (c *Catalogue) MuseumIDs(ctx context.Context, after int64, limit int) ([]int64, error) {
+	rows, err := c.db.QueryContext(ctx, `
+SELECT id FROM museums
+WHERE retired_at IS NULL AND id > ?
+ORDER BY id
+LIMIT ?`, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("museum ids: %w", err)
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("museum ids: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// page cuts one page from a complete, ordered result.
+func page(hits []postgres.Hit, limit, offset int) postgres.Page {
+	total := int64(len(hits))
+	hits = hits[min(offset, len(hits)):]
+	return postgres.Page{Hits: hits[:min(limit, len(hits))], Total: total}
+}
+
+// ftsQuery turns a term into a full-text query matching any of its trigrams,
+// or "" for a term too short to have one. Each trigram is quoted, so nothing
+// in it is read as query syntax.
+func ftsQuery(term string) string {
+	runes := []rune(term)
+	seen := map[string]bool{}
+	var grams []string
+	for i := 0; i+3 <= len(runes); i++ {
+		gram := string(runes[i : i+3])
+		if seen[gram] {
+			continue
+		}
+		seen[gram] = true
+		grams = append(grams, `"`+strings.ReplaceAll(gram, `"`, `""`)+`"`)
+	}
+	return strings.Join(grams, " OR ")
+}
+
+// compareEnds orders closing dates soonest first, with no closing date last.
+func compareEnds(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	return a.Compare(*b)
+}
+
+// today is the current date as stored.
+func today() string {
+	return time.Now().Format(dateLayout)
+}
+
+// parseList reads a list column.
+func parseList(s string) []string {
+	var values []string
+	if err := json.Unmarshal([]byte(s), &values); err != nil || len(values) == 0 {
+		return nil
+	}
+	return values
+}
+
+// parseTime reads a stored time. The file is written by Writer, so a value
+// that does not parse is a zero time rather than an error.
+func parseTime(s string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, s)
+	return t
+}
+
+// parseDate reads a stored date, nil for none.
+func parseDate(s sql.NullString) *time.Time {
+	if !s.Valid {
+		return nil
+	}
+	d, err := time.Parse(dateLayout, s.String)
+	if err != nil {
+		return nil
+	}
+	return &d
+}
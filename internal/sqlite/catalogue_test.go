@@ -0,0 +1,104 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"museum/internal/api"
+	"museum/internal/catalogtest"
+	"museum/internal/models"
+	"museum/internal/postgres"
+	"museum/pkg/exhibitions"
+)
+
+// testCatalogue writes the museums and listings to a file and opens it.
+func testCatalogue(t *testing.T, museums []models.Museum, shows []exhibitions.Exhibition) *Catalogue {
+	t.Helper()
+	if !haveDriver() {
+		t.Skip("built without a SQLite driver; run with -tags sqlite")
+	}
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "museums.sqlite")
+	w, err := Create(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range museums {
+		if err := w.AddMuseum(ctx, postgres.CatalogueRowOf(int64(i+1), m)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, e := range shows {
+		if err := w.AddExhibition(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := Open(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestCatalogue(t *testing.T) {
+	catalogtest.Run(t, func(t *testing.T, museums []models.Museum, shows []exhibitions.Exhibition) api.Catalogue {
+		return testCatalogue(t, museums, shows)
+	})
+}
+
+func TestOpenWithoutDriver(t *testing.T) {
+	if haveDriver() {
+		t.Skip("a driver is linked in")
+	}
+	if _, err := Open(context.Background(), "museums.sqlite"); err != ErrNoDriver {
+		t.Errorf("Open = %v, want ErrNoDriver", err)
+	}
+}
+
+func TestBoxesAround(t *testing.T) {
+	tests := []struct {
+		name          string
+		lat, lon, km  float64
+		want          int
+		coversLon     float64
+		coversAllLons bool
+	}{
+		{name: "paris", lat: 48.86, lon: 2.34, km: 10, want: 1, coversLon: 2.4},
+		{name: "across the antimeridian", lat: -17.7, lon: 179.9, km: 50, want: 2, coversLon: -179.9},
+		{name: "near the pole", lat: 89.9, lon: 0, km: 50, want: 1, coversAllLons: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			boxes := boxesAround(tt.lat, tt.lon, tt.km)
+			if len(boxes) != tt.want {
+				t.Fatalf("%d boxes, want %d: %+v", len(boxes), tt.want, boxes)
+			}
+			if tt.coversAllLons && (boxes[0].west != -180 || boxes[0].east != 180) {
+				t.Errorf("box %+v does not span every longitude", boxes[0])
+			}
+			covered := false
+			for _, b := range boxes {
+				covered = covered || (b.west <= tt.coversLon && tt.coversLon <= b.east)
+			}
+			if !tt.coversAllLons && !covered {
+				t.Errorf("no box covers longitude %v: %+v", tt.coversLon, boxes)
+			}
+		})
+	}
+}
+
+func TestFTSQuery(t *testing.T) {
+	if got, want := ftsQuery("orsay"), `"ors" OR "rsa" OR "say"`; got != want {
+		t.Errorf("ftsQuery(orsay) = %s, want %s", got, want)
+	}
+	if got := ftsQuery("mo"); got != "" {
+		t.Errorf("ftsQuery(mo) = %q, want none for a term with no trigram", got)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build sqlite
+
+package sqlite
+
+// The pure-Go driver, so a build with SQLite support still needs no C
+// toolchain and cross-compiles for a kiosk like any other build.
+import _ "modernc.org/sqlite"
@@ -0,0 +1,37 @@
+package sqlite
+
+import "math"
+
+// box is a latitude and longitude range for an R-tree query.
+type box struct {
+	south, north, west, east float64
+}
+
+// kmPerDegree is the length of a degree of latitude, and of longitude at the
+// equator.
+const kmPerDegree = 111.32
+
+// boxesAround returns the boxes that together cover a circle: one, or two when
+// the circle crosses the antimeridian. They cover a little more than the
+// circle, so a caller still measures each candidate.
+func boxesAround(lat, lon, radiusKm float64) []box {
+	dLat := radiusKm / kmPerDegree
+	south, north := max(lat-dLat, -90), min(lat+dLat, 90)
+
+	// Near a pole a degree of longitude shrinks to nothing, and a circle that
+	// reaches one spans every longitude.
+	cos := math.Cos(max(math.Abs(south), math.Abs(north)) * math.Pi / 180)
+	if north >= 90 || south <= -90 || cos*kmPerDegree*180 <= radiusKm {
+		return []box{{south, north, -180, 180}}
+	}
+	dLon := radiusKm / (kmPerDegree * cos)
+	west, east := lon-dLon, lon+dLon
+
+	switch {
+	case west < -180:
+		return []box{{south, north, -180, east}, {south, north, west + 360, 180}}
+	case east > 180:
+		return []box{{south, north, west, 180}, {south, north, -180, east - 360}}
+	}
+	return []box{{south, north, west, east}}
+}
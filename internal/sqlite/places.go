@@ -0,0 +1,77 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"museum/internal/postgres"
+)
+
+// LookupPlace returns a place resolved while serving, or one the database had
+// resolved before the export.
+func (c *Catalogue) LookupPlace(ctx context.Context, query string) (postgres.Place, bool, error) {
+	c.placesMu.Lock()
+	place, ok := c.places[query]
+	c.placesMu.Unlock()
+	if ok {
+		return place, true, nil
+	}
+
+	place = postgres.Place{Query: query}
+	err := c.db.QueryRowContext(ctx,
+		`SELECT display_name, lat, lon, radius_km, found FROM places WHERE query = ?`, query,
+	).Scan(&place.DisplayName, &place.Latitude, &place.Longitude, &place.RadiusKm, &place.Found)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return postgres.Place{}, false, nil
+	case err != nil:
+		return postgres.Place{}, false, fmt.Errorf("lookup place %q: %w", query, err)
+	}
+	return place, true, nil
+}
+
+// SavePlace remembers a resolved place until the process exits. The file is
+// never written to.
+func (c *Catalogue) SavePlace(_ context.Context, place postgres.Place) error {
+	c.placesMu.Lock()
+	c.places[place.Query] = place
+	c.placesMu.Unlock()
+	return nil
+}
+
+// LocalityPlace resolves a name against the towns the catalogue holds, by the
+// database's rules.
+func (c *Catalogue) LocalityPlace(ctx context.Context, query string) (postgres.Place, error) {
+	c.townsOnce.Do(func() { c.townsErr = c.loadTowns(ctx) })
+	if c.townsErr != nil {
+		return postgres.Place{}, fmt.Errorf("locality place %q: %w", query, c.townsErr)
+	}
+	return c.towns.Resolve(query)
+}
+
+// loadTowns reads the placed museums' towns, once: the file does not change,
+// and a query would otherwise read every museum.
+func (c *Catalogue) loadTowns(ctx context.Context) error {
+	rows, err := c.db.QueryContext(ctx, `
+SELECT locality, locality_normalized, country, lat, lon
+FROM museums
+WHERE lat IS NOT NULL AND locality_normalized <> ''`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			locality, normalized, country string
+			lat, lon                      float64
+		)
+		if err := rows.Scan(&locality, &normalized, &country, &lat, &lon); err != nil {
+			return err
+		}
+		c.towns.Add(locality, normalized, country, lat, lon)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,130 @@
+// Package sqlite serves the catalogue from a single SQLite file, for machines
+// that cannot run Postgres: a laptop on a train, a kiosk in a museum foyer.
+//
+// The file is a snapshot. "museum export -format sqlite" builds it from the
+// database, and "museum serve -catalogue" answers the API from it, read-only.
+// It answers the same queries the same way: an R-tree stands in for PostGIS
+// and an FTS5 trigram index for pg_trgm, and in both cases the index only
+// narrows the candidates. Distances and scores are then computed in Go with
+// the database's own formulas, so the two backends rank alike. The
+// catalogtest suite runs against both to keep it that way.
+//
+// The package is written against database/sql and links no driver of its own.
+// Building with -tags sqlite links one; a binary built without it reports
+// ErrNoDriver rather than failing somewhere less obvious.
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+)
+
+// DriverName is the database/sql driver the package opens files with.
+const DriverName = "sqlite"
+
+// ErrNoDriver reports a binary built without a SQLite driver.
+var ErrNoDriver = errors.New("this binary was built without SQLite support; rebuild it with -tags sqlite")
+
+// formatVersion is the layout of the file. A file of another version is
+// refused rather than half-read: export it again.
+const formatVersion = "1"
+
+// haveDriver reports whether a SQLite driver is linked in.
+func haveDriver() bool {
+	return slices.Contains(sql.Drivers(), DriverName)
+}
+
+// schema is the file's layout.
+//
+// Lists are JSON arrays, dates are ISO 8601 text and times RFC 3339: the file
+// is meant to be opened with the sqlite3 shell as well as by the API, and
+// those read back unambiguously.
+const schema = `
+CREATE TABLE meta (
+    key   TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
+
+CREATE TABLE museums (
+    id                   INTEGER PRIMARY KEY,
+    name                 TEXT NOT NULL,
+    country              TEXT NOT NULL DEFAULT '',
+    locality             TEXT NOT NULL DEFAULT '',
+    description          TEXT NOT NULL DEFAULT '',
+    website              TEXT NOT NULL DEFAULT '',
+    wikipedia_url        TEXT NOT NULL DEFAULT '',
+    wikidata_id          TEXT NOT NULL DEFAULT '',
+    street               TEXT NOT NULL DEFAULT '',
+    postcode             TEXT NOT NULL DEFAULT '',
+    aliases              TEXT NOT NULL DEFAULT '[]',
+    sources              TEXT NOT NULL DEFAULT '[]',
+    classes              TEXT NOT NULL DEFAULT '[]',
+    verified             INTEGER NOT NULL DEFAULT 0,
+    sitelinks            INTEGER NOT NULL DEFAULT 0,
+    location_approximate INTEGER NOT NULL DEFAULT 0,
+    lat                  REAL,
+    lon                  REAL,
+    retired_at           TEXT,
+    updated_at           TEXT NOT NULL,
+    -- The derived columns, as Postgres stores them, so a search here matches
+    -- what a search there matches.
+    normalized           TEXT NOT NULL,
+    search_text          TEXT NOT NULL,
+    locality_normalized  TEXT NOT NULL DEFAULT '',
+    aliases_normalized   TEXT NOT NULL DEFAULT '[]',
+    site                 TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX museums_wikidata_idx ON museums (wikidata_id) WHERE wikidata_id <> '';
+CREATE INDEX museums_prominence_idx ON museums (sitelinks DESC, id) WHERE lat IS NOT NULL;
+CREATE INDEX museums_normalized_idx ON museums (normalized);
+
+-- A museum is a point, so each box is degenerate. The R-tree is still what
+-- turns a radius query into a few hundred candidates instead of a scan.
+CREATE VIRTUAL TABLE museums_rtree USING rtree (id, min_lat, max_lat, min_lon, max_lon);
+
+-- Trigrams, like pg_trgm, over the same text the database's trigram index
+-- covers: the name, the aliases and the town, normalised.
+CREATE VIRTUAL TABLE museums_fts USING fts5 (
+    search_text, content = 'museums', content_rowid = 'id', tokenize = 'trigram'
+);
+
+CREATE TABLE exhibitions (
+    id                 INTEGER PRIMARY KEY,
+    url                TEXT NOT NULL UNIQUE,
+    title              TEXT NOT NULL,
+    museum             TEXT NOT NULL DEFAULT '',
+    museum_wikidata_id TEXT NOT NULL DEFAULT '',
+    starts_on          TEXT,
+    ends_on            TEXT,
+    source_page        TEXT NOT NULL DEFAULT '',
+    scraped_at         TEXT NOT NULL,
+    permanent          INTEGER NOT NULL DEFAULT 0,
+    lat                REAL,
+    lon                REAL
+);
+
+CREATE VIRTUAL TABLE exhibitions_rtree USING rtree (id, min_lat, max_lat, min_lon, max_lon);
+
+CREATE VIRTUAL TABLE exhibitions_fts USING fts5 (
+    title, museum, content = 'exhibitions', content_rowid = 'id', tokenize = 'trigram'
+);
+
+-- When each site was last read, for the coverage report.
+CREATE TABLE site_attempts (
+    site TEXT PRIMARY KEY,
+    at   TEXT NOT NULL
+);
+
+-- Place names the database had resolved, so the file answers "Paris" without
+-- a geocoder.
+CREATE TABLE places (
+    query        TEXT PRIMARY KEY,
+    display_name TEXT NOT NULL,
+    lat          REAL NOT NULL,
+    lon          REAL NOT NULL,
+    radius_km    REAL NOT NULL,
+    found        INTEGER NOT NULL
+);
+`
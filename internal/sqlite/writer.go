@@ -0,0 +1,264 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"museum/internal/postgres"
+	"museum/pkg/exhibitions"
+)
+
+// dateLayout is how exhibition dates are stored.
+const dateLayout = time.DateOnly
+
+// Writer builds a catalogue file.
+//
+// It writes beside the destination and renames into place on Close, so a
+// server opening the path sees the previous file or the finished one, never a
+// half-written one, and an export that fails leaves nothing behind.
+type Writer struct {
+	db   *sql.DB
+	tx   *sql.Tx
+	path string
+	tmp  string
+
+	museum, museumBox, exhibition, exhibitionBox, site, place *sql.Stmt
+}
+
+// Create starts a catalogue file at path, replacing any there once it is
+// closed.
+func Create(ctx context.Context, path string) (*Writer, error) {
+	if !haveDriver() {
+		return nil, ErrNoDriver
+	}
+
+	tmp := path + ".tmp"
+	if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("create %s: %w", path, err)
+	}
+	db, err := sql.Open(DriverName, "file:"+tmp)
+	if err != nil {
+		return nil, fmt.Errorf("create %s: %w", path, err)
+	}
+	// One connection: a transaction and the statements prepared on it must
+	// share it, and the file is written by nothing else.
+	db.SetMaxOpenConns(1)
+
+	w := &Writer{db: db, path: path, tmp: tmp}
+	if err := w.start(ctx); err != nil {
+		w.Abort()
+		return nil, fmt.Errorf("create %s: %w", path, err)
+	}
+	return w, nil
+}
+
+// start lays out the schema and prepares the inserts, all in one transaction:
+// a single commit is many times faster than one per row.
+func (w *Writer) start(ctx context.Context) error {
+	if _, err := w.db.ExecContext(ctx, `PRAGMA journal_mode = OFF; PRAGMA synchronous = OFF`); err != nil {
+		return err
+	}
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	w.tx = tx
+
+	if _, err := tx.ExecContext(ctx, schema); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO meta (key, value) VALUES ('format', ?), ('exported_at', ?)`,
+		formatVersion, formatTime(time.Now())); err != nil {
+		return err
+	}
+
+	for _, prepared := range []struct {
+		stmt **sql.Stmt
+		sql  string
+	}{
+		{&w.museum, `
+INSERT INTO museums (id, name, country, locality, description, website, wikipedia_url, wikidata_id,
+                     street, postcode, aliases, sources, classes, verified, sitelinks,
+                     location_approximate, lat, lon, retired_at, updated_at,
+                     normalized, search_text, locality_normalized, aliases_normalized, site)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`},
+		{&w.museumBox, `INSERT INTO museums_rtree (id, min_lat, max_lat, min_lon, max_lon) VALUES (?, ?, ?, ?, ?)`},
+		{&w.exhibition, `
+INSERT INTO exhibitions (url, title, museum, museum_wikidata_id, starts_on, ends_on,
+                         source_page, scraped_at, permanent, lat, lon)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (url) DO NOTHING`},
+		{&w.exhibitionBox, `INSERT INTO exhibitions_rtree (id, min_lat, max_lat, min_lon, max_lon) VALUES (?, ?, ?, ?, ?)`},
+		{&w.site, `INSERT OR REPLACE INTO site_attempts (site, at) VALUES (?, ?)`},
+		{&w.place, `
+INSERT OR REPLACE INTO places (query, display_name, lat, lon, radius_km, found)
+VALUES (?, ?, ?, ?, ?, ?)`},
+	} {
+		stmt, err := tx.PrepareContext(ctx, prepared.sql)
+		if err != nil {
+			return err
+		}
+		*prepared.stmt = stmt
+	}
+	return nil
+}
+
+// AddMuseum writes one museum.
+func (w *Writer) AddMuseum(ctx context.Context, row postgres.CatalogueRow) error {
+	m := row.Museum
+	var lat, lon *float64
+	if m.Latitude != 0 || m.Longitude != 0 {
+		lat, lon = &m.Latitude, &m.Longitude
+	}
+	var retired *string
+	if row.RetiredAt != nil {
+		at := formatTime(*row.RetiredAt)
+		retired = &at
+	}
+
+	if _, err := w.museum.ExecContext(ctx,
+		row.ID, m.Name, m.Country, m.Locality, m.Description, m.Website, m.WikipediaURL, m.WikidataID,
+		m.Address.Road, m.Address.Postcode, jsonList(m.AlsoKnownAs), jsonList(m.Sources), jsonList(m.Classes),
+		m.Verified, m.Sitelinks, row.ApproximateLocation, lat, lon, retired, formatTime(row.UpdatedAt),
+		row.Normalized, row.SearchText, row.LocalityNormalized, jsonList(row.AliasesNormalized), row.Site,
+	); err != nil {
+		return fmt.Errorf("write museum %d: %w", row.ID, err)
+	}
+	if lat != nil {
+		if _, err := w.museumBox.ExecContext(ctx, row.ID, *lat, *lat, *lon, *lon); err != nil {
+			return fmt.Errorf("write museum %d: %w", row.ID, err)
+		}
+	}
+	return nil
+}
+
+// AddExhibition writes one listing. A URL already written is skipped, as the
+// database keys listings by URL.
+func (w *Writer) AddExhibition(ctx context.Context, e exhibitions.Exhibition) error {
+	var lat, lon *float64
+	if e.Latitude != 0 || e.Longitude != 0 {
+		lat, lon = &e.Latitude, &e.Longitude
+	}
+	scraped := e.ScrapedAt
+	if scraped.IsZero() {
+		scraped = time.Now()
+	}
+
+	result, err := w.exhibition.ExecContext(ctx,
+		e.URL, e.Title, e.Museum, e.MuseumWikidataID, formatDate(e.Start), formatDate(e.End),
+		e.SourcePage, formatTime(scraped), e.Permanent, lat, lon)
+	if err != nil {
+		return fmt.Errorf("write exhibition %s: %w", e.URL, err)
+	}
+	if written, _ := result.RowsAffected(); written == 0 || lat == nil {
+		return nil
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("write exhibition %s: %w", e.URL, err)
+	}
+	if _, err := w.exhibitionBox.ExecContext(ctx, id, *lat, *lat, *lon, *lon); err != nil {
+		return fmt.Errorf("write exhibition %s: %w", e.URL, err)
+	}
+	return nil
+}
+
+// AddSiteAttempt records when a museum site was last read.
+func (w *Writer) AddSiteAttempt(ctx context.Context, site string, at time.Time) error {
+	if _, err := w.site.ExecContext(ctx, site, formatTime(at)); err != nil {
+		return fmt.Errorf("write site %s: %w", site, err)
+	}
+	return nil
+}
+
+// AddPlace records a resolved place name.
+func (w *Writer) AddPlace(ctx context.Context, p postgres.Place) error {
+	if _, err := w.place.ExecContext(ctx,
+		p.Query, p.DisplayName, p.Latitude, p.Longitude, p.RadiusKm, p.Found); err != nil {
+		return fmt.Errorf("write place %q: %w", p.Query, err)
+	}
+	return nil
+}
+
+// SetGeneration records the generation the file was taken at, so responses
+// served from it carry the same validators the database would have given.
+func (w *Writer) SetGeneration(ctx context.Context, g postgres.Generation) error {
+	if _, err := w.tx.ExecContext(ctx,
+		`INSERT OR REPLACE INTO meta (key, value) VALUES ('generation', ?), ('changed_at', ?)`,
+		strconv.FormatInt(g.Number, 10), formatTime(g.ChangedAt)); err != nil {
+		return fmt.Errorf("write generation: %w", err)
+	}
+	return nil
+}
+
+// Close indexes what was written, commits it and moves the file into place.
+func (w *Writer) Close(ctx context.Context) error {
+	// The full-text indexes are external-content tables, built in one pass at
+	// the end rather than maintained row by row.
+	for _, rebuild := range []string{
+		`INSERT INTO museums_fts (museums_fts) VALUES ('rebuild')`,
+		`INSERT INTO exhibitions_fts (exhibitions_fts) VALUES ('rebuild')`,
+	} {
+		if _, err := w.tx.ExecContext(ctx, rebuild); err != nil {
+			w.Abort()
+			return fmt.Errorf("index %s: %w", w.path, err)
+		}
+	}
+	if err := w.tx.Commit(); err != nil {
+		w.Abort()
+		return fmt.Errorf("commit %s: %w", w.path, err)
+	}
+	w.tx = nil
+	if _, err := w.db.ExecContext(ctx, `VACUUM`); err != nil {
+		w.Abort()
+		return fmt.Errorf("compact %s: %w", w.path, err)
+	}
+	if err := w.db.Close(); err != nil {
+		w.Abort()
+		return fmt.Errorf("close %s: %w", w.path, err)
+	}
+	if err := os.Rename(w.tmp, w.path); err != nil {
+		os.Remove(w.tmp)
+		return fmt.Errorf("move %s into place: %w", w.path, err)
+	}
+	return nil
+}
+
+// Abort discards the file. Safe to call after a failed Close.
+func (w *Writer) Abort() {
+	if w.tx != nil {
+		w.tx.Rollback()
+		w.tx = nil
+	}
+	w.db.Close()
+	os.Remove(w.tmp)
+}
+
+// jsonList encodes a list column. A nil list is stored as an empty one.
+func jsonList(values []string) string {
+	if values == nil {
+		values = []string{}
+	}
+	encoded, _ := json.Marshal(values)
+	return string(encoded)
+}
+
+// formatTime renders a stored time.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// formatDate renders a stored date, nil for none.
+func formatDate(d *time.Time) *string {
+	if d == nil {
+		return nil
+	}
+	s := d.Format(dateLayout)
+	return &s
+}
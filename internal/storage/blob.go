@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"time"
+)
+
+// Blob is the object store underneath S3Service and Bucket: bytes at keys in
+// a bucket, and nothing about what they hold.
+//
+// Everything above it was written against MinIO's client, so every command —
+// reindex and verify included, which only ever read — needed a running MinIO.
+// The interface is what the pipeline actually uses of one, small enough that a
+// directory on disk can provide it too.
+type Blob interface {
+	// Put writes r at key with attrs, overwriting whatever was there. A size
+	// of -1 means the length is not known in advance.
+	Put(ctx context.Context, bucket, key string, r io.Reader, size int64, attrs Attrs) error
+	// PutIfAbsent writes data at key unless an object is already there, and
+	// reports whether it wrote.
+	PutIfAbsent(ctx context.Context, bucket, key string, data []byte, attrs Attrs) (bool, error)
+	// Get returns the object at key for reading, as stored: compressed
+	// content is not decompressed. A missing object yields an error wrapping
+	// ErrNotFound.
+	Get(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+	// Stat returns the attributes the object at key was written with. A
+	// missing object yields an error wrapping ErrNotFound.
+	Stat(ctx context.Context, bucket, key string) (Attrs, error)
+	// ETag returns the hex MD5 of the object's content, as S3 reports it for
+	// an object uploaded in one part.
+	ETag(ctx context.Context, bucket, key string) (string, error)
+	// List calls fn with every key under prefix, stopping at the first error
+	// fn returns.
+	List(ctx context.Context, bucket, prefix string, fn func(key string) error) error
+	// ListInfo is List with each object's size and modification time, which
+	// a listing reports without reading the objects.
+	ListInfo(ctx context.Context, bucket, prefix string, fn func(ObjectInfo) error) error
+	// Delete removes the object at key. Deleting one that does not exist is
+	// not an error.
+	Delete(ctx context.Context, bucket, key string) error
+	// EnsureBucket creates the bucket if it does not exist.
+	EnsureBucket(ctx context.Context, bucket, region string) error
+}
+
+// Attrs are what an object carries besides its content.
+type Attrs struct {
+	// ContentEncoding is how the content is compressed, as the
+	// Content-Encoding header names it; empty for not at all.
+	ContentEncoding string
+	// Metadata is the object's user metadata. Names are lower case.
+	Metadata map[string]string
+}
+
+// ObjectInfo is what a listing says about an object.
+type ObjectInfo struct {
+	Key      string
+	Size     int64
+	Modified time.Time
+}
+
+// OpenBlob opens the store a location names, and returns the bucket in it to
+// use: "s3://bucket" for a bucket on the S3 endpoint the MINIO_* variables
+// describe, "file:///path" for a directory.
+//
+// A directory is a single bucket, so its bucket is the directory's own name,
+// for the logs; the name callers pass back is not used to find anything.
+func OpenBlob(location string) (Blob, string, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, "", fmt.Errorf("object store %q: %w", location, err)
+	}
+	switch u.Scheme {
+	case "s3":
+		if u.Host == "" {
+			return nil, "", fmt.Errorf("object store %q names no bucket", location)
+		}
+		blob, err := newMinioBlob()
+		if err != nil {
+			return nil, "", err
+		}
+		return blob, u.Host, nil
+	case "file":
+		if u.Host != "" && u.Host != "localhost" {
+			return nil, "", fmt.Errorf("object store %q: a file URL's path starts after three slashes", location)
+		}
+		if u.Path == "" {
+			return nil, "", fmt.Errorf("object store %q names no directory", location)
+		}
+		blob, err := NewFileBlob(u.Path)
+		if err != nil {
+			return nil, "", err
+		}
+		return blob, filepath.Base(blob.root), nil
+	default:
+		return nil, "", errors.New("object store must be s3://bucket or file:///path, got " + location)
+	}
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+)
+
+// Bucket reads and writes a bucket's objects as bytes, for the callers that
+// move objects around rather than decode them: a backup copies the catalogue's
+// objects without caring what they hold.
+type Bucket struct {
+	blob Blob
+	name string
+}
+
+// Bucket returns the named bucket in the same store.
+func (s *S3Service[T]) Bucket(name string) *Bucket {
+	return &Bucket{blob: s.blob, name: name}
+}
+
+// Name is the bucket's name.
+func (b *Bucket) Name() string { return b.name }
+
+// Put writes r at key, overwriting whatever was there. A size of -1 means
+// the length is not known in advance, and the object is uploaded in parts.
+func (b *Bucket) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	return b.blob.Put(ctx, b.name, key, r, size, Attrs{})
+}
+
+// Open returns the object at key for reading. A missing object yields an
+// error wrapping ErrNotFound.
+func (b *Bucket) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return b.blob.Get(ctx, b.name, key)
+}
+
+// ETag returns the object's entity tag: the hex MD5 of its content for an
+// object uploaded in one part, which is every object the pipeline writes.
+func (b *Bucket) ETag(ctx context.Context, key string) (string, error) {
+	return b.blob.ETag(ctx, b.name, key)
+}
+
+// List calls fn with the key of every object under prefix, stopping at the
+// first error fn returns.
+func (b *Bucket) List(ctx context.Context, prefix string, fn func(key string) error) error {
+	return b.blob.List(ctx, b.name, prefix, fn)
+}
+
+// Remove deletes the object at key. Deleting one that does not exist is not
+// an error.
+func (b *Bucket) Remove(ctx context.Context, key string) error {
+	return b.blob.Delete(ctx, b.name, key)
+}
+
+// contentType labels an object by its key, so the console shows a JSON
+// object as one and not as a download.
+func contentType(key string) string {
+	switch {
+	case strings.HasSuffix(key, ".json"):
+		return "application/json"
+	case strings.HasSuffix(key, ".gz"):
+		return "application/gzip"
+	default:
+		return "application/octet-stream"
+	}
+}
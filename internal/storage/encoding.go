@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Encoding is how an S3Service compresses what it writes.
+type Encoding string
+
+const (
+	Identity Encoding = ""
+	Gzip     Encoding = "gzip"
+	Zstd     Encoding = "zstd"
+)
+
+// SumMetadata is the user metadata holding the SHA-256 of an object's
+// canonical JSON, which is what tells a rewrite of the same record from a
+// changed one without reading the object back.
+const SumMetadata = "json-sha256"
+
+// ParseEncoding reads an encoding's name: identity (or none, or nothing),
+// gzip or zstd.
+func ParseEncoding(name string) (Encoding, error) {
+	switch name {
+	case "", "none", "identity":
+		return Identity, nil
+	case "gzip":
+		return Gzip, nil
+	case "zstd":
+		return Zstd, nil
+	default:
+		return Identity, fmt.Errorf("unknown encoding %q: want identity, gzip or zstd", name)
+	}
+}
+
+// Both are safe for concurrent use through EncodeAll and DecodeAll, and
+// expensive enough to build that one of each is kept.
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// encode compresses data.
+func (e Encoding) encode(data []byte) ([]byte, error) {
+	switch e {
+	case Gzip:
+		var buf bytes.Buffer
+		zipped := gzip.NewWriter(&buf)
+		if _, err := zipped.Write(data); err != nil {
+			return nil, err
+		}
+		if err := zipped.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case Zstd:
+		return zstdEncoder.EncodeAll(data, nil), nil
+	default:
+		return data, nil
+	}
+}
+
+// Magic numbers the compressed formats begin with. JSON begins with neither.
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// decode returns an object's content, decompressed if it was compressed.
+//
+// The format is read from the content's own first bytes rather than from the
+// object's Content-Encoding: a backup restores an object's bytes without its
+// headers, and an object written before compression was has none to read.
+func decode(data []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, zstdMagic):
+		return zstdDecoder.DecodeAll(data, nil)
+	case bytes.HasPrefix(data, gzipMagic):
+		unzipped, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(unzipped)
+	default:
+		return data, nil
+	}
+}
+
+// canonicalSum is the hex SHA-256 of data's canonical JSON: decoded with its
+// numbers kept as written, and encoded again with object keys sorted and
+// nothing escaped that need not be. A record written with its fields in
+// another order, or compressed another way, has the same sum.
+func canonicalSum(data []byte) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+	return contentSum(buf.Bytes()), nil
+}
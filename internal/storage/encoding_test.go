@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncoding(t *testing.T) {
+	ctx := context.Background()
+	blob, err := NewFileBlob(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	const louvre = "raw_data/france/louvre.json"
+
+	for _, tc := range []struct {
+		name  string
+		magic []byte
+	}{
+		{"identity", []byte("{")},
+		{"gzip", gzipMagic},
+		{"zstd", zstdMagic},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			encoding, err := ParseEncoding(tc.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := NewService(blob, func(r record) string { return r.Key })
+			s.SetEncoding(encoding)
+			if err := s.PutObject(ctx, "museum", record{louvre, "Louvre"}); err != nil {
+				t.Fatal(err)
+			}
+
+			stored, _ := os.ReadFile(filepath.Join(blob.Root(), filepath.FromSlash(louvre)))
+			if !bytes.HasPrefix(stored, tc.magic) {
+				t.Errorf("stored % x, want it to begin % x", stored[:min(4, len(stored))], tc.magic)
+			}
+			attrs, err := blob.Stat(ctx, "museum", louvre)
+			if err != nil || attrs.ContentEncoding != string(encoding) || len(attrs.Metadata[SumMetadata]) != 64 {
+				t.Errorf("attributes %+v, %v", attrs, err)
+			}
+			if got, err := s.GetObject(ctx, "museum", louvre); err != nil || got.Name != "Louvre" {
+				t.Errorf("read back %+v, %v", got, err)
+			}
+		})
+	}
+	if _, err := ParseEncoding("brotli"); err == nil {
+		t.Error("an unknown encoding was accepted")
+	}
+}
+
+func TestStoreObject_ComparesContent(t *testing.T) {
+	ctx := context.Background()
+	blob, err := NewFileBlob(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewService(blob, func(r record) string { return r.Key })
+	s.SetEncoding(Zstd)
+	const louvre, orsay = "raw_data/france/louvre.json", "raw_data/france/orsay.json"
+
+	store := func(r record) bool {
+		t.Helper()
+		wrote, err := s.StoreObject(ctx, "museum", r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return wrote
+	}
+	if !store(record{louvre, "Louvre"}) {
+		t.Error("a new record was not written")
+	}
+	if store(record{louvre, "Louvre"}) {
+		t.Error("the same record was written again")
+	}
+	if !store(record{louvre, "Musée du Louvre"}) {
+		t.Error("a changed record was not rewritten")
+	}
+	if got, _ := s.GetObject(ctx, "museum", louvre); got.Name != "Musée du Louvre" {
+		t.Errorf("after the rewrite the record says %q", got.Name)
+	}
+
+	// Written before sums were kept, plainly, with its fields the other way
+	// round: the same record all the same.
+	body := `{"name":"Orsay","key":"` + orsay + `"}`
+	if err := blob.Put(ctx, "museum", orsay, strings.NewReader(body), int64(len(body)), Attrs{}); err != nil {
+		t.Fatal(err)
+	}
+	if store(record{orsay, "Orsay"}) {
+		t.Error("a record stored before sums were kept was rewritten unchanged")
+	}
+	if !store(record{orsay, "Musée d'Orsay"}) {
+		t.Error("a changed record stored before sums were kept was not rewritten")
+	}
+}
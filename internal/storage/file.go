@@ -0,0 +1,467 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// journalName is the change journal's file in the root. Names starting with a
+// dot are never keys, so it cannot collide with an object.
+const journalName = ".journal"
+
+// attrsDir holds the attributes of every object written with any, as JSON at
+// the object's own key: a file has nowhere else to keep a Content-Encoding or
+// user metadata. Hidden like the journal, it is never listed.
+const attrsDir = ".attrs"
+
+// FileBlob is a Blob in a directory: each object a file at its key's path,
+// under the same raw_data/ and enriched_data/ layout the bucket has, so a
+// crawl can be run into a folder and reindexed from it with no MinIO at all.
+//
+// A write goes to a temporary file beside its destination and is renamed into
+// place, so a reader sees the old object or the new one and never half of
+// either, and a crash leaves at worst a stray temporary file, which nothing
+// lists. Every write and delete is appended to a change journal: a bucket
+// announces its changes through notifications, and a directory has no one to
+// announce them to. Followers read it with Changes, each keeping its place
+// with SetCursor.
+//
+// A directory is one bucket: the bucket names callers pass are accepted and
+// not used.
+type FileBlob struct {
+	root string
+
+	// journalMu serialises this process's appends. Appends from different
+	// processes are each one short write to a file opened O_APPEND, which
+	// the operating system does not interleave.
+	journalMu sync.Mutex
+}
+
+// NewFileBlob returns the store rooted at dir, creating the directory if it
+// does not exist.
+func NewFileBlob(dir string) (*FileBlob, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("object store %s: %w", dir, err)
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("object store %s: %w", dir, err)
+	}
+	return &FileBlob{root: root}, nil
+}
+
+// Root is the directory the store is in.
+func (f *FileBlob) Root() string { return f.root }
+
+// path is where key's object lives. Keys are slash-separated like an
+// object store's; one that would leave the root, or name a hidden file, is
+// refused rather than cleaned into something else.
+func (f *FileBlob) path(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return "", fmt.Errorf("object key %q is not a relative path", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == ".." || strings.HasPrefix(part, ".") {
+			return "", fmt.Errorf("object key %q cannot be stored in a directory", key)
+		}
+	}
+	return filepath.Join(f.root, filepath.FromSlash(key)), nil
+}
+
+// Put drops the old attributes before it writes and adds the new ones after,
+// so an object is never seen with attributes that are not its own: at worst,
+// for a moment, with none.
+func (f *FileBlob) Put(ctx context.Context, _, key string, r io.Reader, _ int64, attrs Attrs) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	dest, err := f.path(key)
+	if err != nil {
+		return err
+	}
+	if err := f.removeAttrs(key); err != nil {
+		return fmt.Errorf("put object %q: %w", key, err)
+	}
+	tmp, size, err := f.writeTemp(dest, r)
+	if err != nil {
+		return fmt.Errorf("put object %q: %w", key, err)
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("put object %q: %w", key, err)
+	}
+	if err := f.writeAttrs(key, attrs); err != nil {
+		return fmt.Errorf("put object %q: %w", key, err)
+	}
+	return f.record(Change{Op: "put", Key: key, Size: size})
+}
+
+// PutIfAbsent links the written file into place rather than renaming it: a
+// link fails when the name exists, so of two writers racing for one key
+// exactly one writes it.
+func (f *FileBlob) PutIfAbsent(ctx context.Context, _, key string, data []byte, attrs Attrs) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	dest, err := f.path(key)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(dest); err == nil {
+		return false, nil
+	}
+	tmp, size, err := f.writeTemp(dest, bytes.NewReader(data))
+	if err != nil {
+		return false, fmt.Errorf("put object %q: %w", key, err)
+	}
+	defer os.Remove(tmp)
+	if err := os.Link(tmp, dest); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("put object %q: %w", key, err)
+	}
+	if err := f.writeAttrs(key, attrs); err != nil {
+		return true, fmt.Errorf("put object %q: %w", key, err)
+	}
+	return true, f.record(Change{Op: "put", Key: key, Size: size})
+}
+
+// fileAttrs is Attrs as an attributes file holds them.
+type fileAttrs struct {
+	ContentEncoding string            `json:"content_encoding,omitempty"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+}
+
+// attrsPath is where the attributes of key, already checked by path, are.
+func (f *FileBlob) attrsPath(key string) string {
+	return filepath.Join(f.root, attrsDir, filepath.FromSlash(key))
+}
+
+// writeAttrs keeps an object's attributes, if it has any.
+func (f *FileBlob) writeAttrs(key string, attrs Attrs) error {
+	if attrs.ContentEncoding == "" && len(attrs.Metadata) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(fileAttrs{ContentEncoding: attrs.ContentEncoding, Metadata: attrs.Metadata})
+	if err != nil {
+		return err
+	}
+	dest := f.attrsPath(key)
+	tmp, _, err := f.writeTemp(dest, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// removeAttrs drops an object's attributes.
+func (f *FileBlob) removeAttrs(key string) error {
+	if err := os.Remove(f.attrsPath(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (f *FileBlob) Stat(_ context.Context, _, key string) (Attrs, error) {
+	p, err := f.path(key)
+	if err != nil {
+		return Attrs{}, err
+	}
+	if _, err := os.Stat(p); errors.Is(err, fs.ErrNotExist) {
+		return Attrs{}, fmt.Errorf("stat object %q: %w", key, ErrNotFound)
+	} else if err != nil {
+		return Attrs{}, fmt.Errorf("stat object %q: %w", key, err)
+	}
+	data, err := os.ReadFile(f.attrsPath(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Attrs{}, nil
+	}
+	if err != nil {
+		return Attrs{}, fmt.Errorf("stat object %q: %w", key, err)
+	}
+	var attrs fileAttrs
+	if err := json.Unmarshal(data, &attrs); err != nil {
+		return Attrs{}, fmt.Errorf("stat object %q: %w", key, err)
+	}
+	return Attrs{ContentEncoding: attrs.ContentEncoding, Metadata: attrs.Metadata}, nil
+}
+
+// writeTemp writes r to a temporary file in dest's directory, synced, and
+// returns its name and length.
+func (f *FileBlob) writeTemp(dest string, r io.Reader) (string, int64, error) {
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return "", 0, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".tmp-*")
+	if err != nil {
+		return "", 0, err
+	}
+	size, err := io.Copy(tmp, r)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		// CreateTemp makes files only the owner can read; an object store's
+		// objects are for whoever can read the store.
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", 0, err
+	}
+	return tmp.Name(), size, nil
+}
+
+func (f *FileBlob) Get(_ context.Context, _, key string) (io.ReadCloser, error) {
+	p, err := f.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("get object %q: %w", key, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get object %q: %w", key, err)
+	}
+	return file, nil
+}
+
+func (f *FileBlob) ETag(ctx context.Context, bucket, key string) (string, error) {
+	r, err := f.Get(ctx, bucket, key)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	sum := md5.New()
+	if _, err := io.Copy(sum, r); err != nil {
+		return "", fmt.Errorf("stat object %q: %w", key, err)
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+func (f *FileBlob) List(ctx context.Context, bucket, prefix string, fn func(key string) error) error {
+	return f.ListInfo(ctx, bucket, prefix, func(info ObjectInfo) error { return fn(info.Key) })
+}
+
+// ListInfo walks the directory the prefix ends in, in lexical order, and
+// skips whatever is hidden: the journal, and temporary files not yet renamed.
+func (f *FileBlob) ListInfo(ctx context.Context, _, prefix string, fn func(ObjectInfo) error) error {
+	var fnErr error
+	dir := f.root
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		dir = filepath.Join(f.root, filepath.FromSlash(prefix[:i]))
+	}
+	err := filepath.WalkDir(dir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if strings.HasPrefix(entry.Name(), ".") && p != dir {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(f.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			// Deleted between being walked and being looked at.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// Kept apart so it reaches the caller as fn returned it.
+		fnErr = fn(ObjectInfo{Key: key, Size: info.Size(), Modified: info.ModTime()})
+		return fnErr
+	})
+	if err != nil && err != fnErr && ctx.Err() == nil {
+		return fmt.Errorf("list %s/%s: %w", f.root, prefix, err)
+	}
+	return err
+}
+
+func (f *FileBlob) Delete(_ context.Context, _, key string) error {
+	p, err := f.path(key)
+	if err != nil {
+		return err
+	}
+	switch err := os.Remove(p); {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case err != nil:
+		return fmt.Errorf("remove object %q: %w", key, err)
+	}
+	if err := f.removeAttrs(key); err != nil {
+		return fmt.Errorf("remove object %q: %w", key, err)
+	}
+	return f.record(Change{Op: "delete", Key: key})
+}
+
+func (f *FileBlob) EnsureBucket(context.Context, string, string) error {
+	if err := os.MkdirAll(f.root, 0o755); err != nil {
+		return fmt.Errorf("create object store %s: %w", f.root, err)
+	}
+	return nil
+}
+
+// Change is one entry in a FileBlob's journal.
+type Change struct {
+	// Op is "put" or "delete".
+	Op   string    `json:"op"`
+	Key  string    `json:"key"`
+	Size int64     `json:"size,omitempty"`
+	At   time.Time `json:"at"`
+
+	// Next is the offset of the entry after this one: where a follower that
+	// has dealt with this one resumes. It is where the entry ends, not part
+	// of it.
+	Next int64 `json:"-"`
+}
+
+// record appends a change to the journal.
+func (f *FileBlob) record(c Change) error {
+	c.At = time.Now().UTC()
+	line, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	f.journalMu.Lock()
+	defer f.journalMu.Unlock()
+	journal, err := os.OpenFile(filepath.Join(f.root, journalName), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("journal %s %q: %w", c.Op, c.Key, err)
+	}
+	_, err = journal.Write(append(line, '\n'))
+	if closeErr := journal.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("journal %s %q: %w", c.Op, c.Key, err)
+	}
+	return nil
+}
+
+// Changes calls fn with each change journalled from offset on, and returns
+// the offset to resume from: a follower keeps it, and asks again from there.
+// An entry still being written is left for the next call.
+func (f *FileBlob) Changes(offset int64, fn func(Change) error) (int64, error) {
+	journal, err := os.Open(filepath.Join(f.root, journalName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return offset, nil
+	}
+	if err != nil {
+		return offset, fmt.Errorf("read journal: %w", err)
+	}
+	defer journal.Close()
+	if _, err := journal.Seek(offset, io.SeekStart); err != nil {
+		return offset, fmt.Errorf("read journal: %w", err)
+	}
+
+	r := bufio.NewReader(journal)
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return offset, nil
+		}
+		if err != nil {
+			return offset, fmt.Errorf("read journal: %w", err)
+		}
+		var c Change
+		if err := json.Unmarshal(line, &c); err != nil {
+			return offset, fmt.Errorf("read journal at %d: %w", offset, err)
+		}
+		c.Next = offset + int64(len(line))
+		if err := fn(c); err != nil {
+			return offset, err
+		}
+		offset = c.Next
+	}
+}
+
+// Cursor returns the journal offset the follower called name last saved, or
+// 0 for one that has saved none.
+func (f *FileBlob) Cursor(name string) (int64, error) {
+	p, err := f.cursorPath(name)
+	if err != nil {
+		return 0, err
+	}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("read journal cursor %s: %w", name, err)
+	}
+	offset, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("read journal cursor %s: %w", name, err)
+	}
+	return offset, nil
+}
+
+// SetCursor saves the journal offset the follower called name resumes from,
+// replacing the one before it in one rename.
+func (f *FileBlob) SetCursor(name string, offset int64) error {
+	p, err := f.cursorPath(name)
+	if err != nil {
+		return err
+	}
+	tmp, _, err := f.writeTemp(p, strings.NewReader(strconv.FormatInt(offset, 10)+"\n"))
+	if err != nil {
+		return fmt.Errorf("save journal cursor %s: %w", name, err)
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("save journal cursor %s: %w", name, err)
+	}
+	return nil
+}
+
+// cursorPath is where the follower called name keeps its offset: beside the
+// journal, and hidden like it.
+func (f *FileBlob) cursorPath(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\.`) {
+		return "", fmt.Errorf("journal cursor name %q is not a plain name", name)
+	}
+	return filepath.Join(f.root, journalName+"-"+name), nil
+}
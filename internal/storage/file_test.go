@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFileBlob(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	blob, err := NewFileBlob(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	put := func(key, body string) {
+		t.Helper()
+		if err := blob.Put(ctx, "museum", key, strings.NewReader(body), int64(len(body)), Attrs{}); err != nil {
+			t.Fatalf("put %s: %v", key, err)
+		}
+	}
+	read := func(key string) string {
+		t.Helper()
+		r, err := blob.Get(ctx, "museum", key)
+		if err != nil {
+			t.Fatalf("get %s: %v", key, err)
+		}
+		defer r.Close()
+		data, _ := io.ReadAll(r)
+		return string(data)
+	}
+
+	put("raw_data/france/louvre.json", `{"name":"Louvre"}`)
+	put("raw_data/france/orsay.json", `{"name":"Orsay"}`)
+	put("enriched_data/france/louvre.json", `{}`)
+	put("raw_data/france/louvre.json", `{"name":"Louvre Museum"}`)
+	if got := read("raw_data/france/louvre.json"); got != `{"name":"Louvre Museum"}` {
+		t.Errorf("after an overwrite = %s", got)
+	}
+	// The layout is the bucket's, so the folder can be read without the tool.
+	if _, err := os.Stat(filepath.Join(dir, "raw_data", "france", "orsay.json")); err != nil {
+		t.Errorf("object not at its key's path: %v", err)
+	}
+
+	wrote, err := blob.PutIfAbsent(ctx, "museum", "raw_data/france/orsay.json", []byte(`{"name":"changed"}`), Attrs{})
+	if err != nil || wrote || read("raw_data/france/orsay.json") != `{"name":"Orsay"}` {
+		t.Errorf("put if absent over an object = %v, %v; want it left alone", wrote, err)
+	}
+	if wrote, err := blob.PutIfAbsent(ctx, "museum", "raw_data/italy/uffizi.json", []byte(`{}`), Attrs{}); err != nil || !wrote {
+		t.Errorf("put if absent on a new key = %v, %v", wrote, err)
+	}
+
+	var keys []string
+	if err := blob.List(ctx, "museum", "raw_data/", func(key string) error {
+		keys = append(keys, key)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"raw_data/france/louvre.json", "raw_data/france/orsay.json", "raw_data/italy/uffizi.json"}
+	if !slices.Equal(keys, want) {
+		t.Errorf("listed %v, want %v and no journal or temporary files", keys, want)
+	}
+	var partial []string
+	blob.List(ctx, "museum", "raw_data/france/l", func(key string) error {
+		partial = append(partial, key)
+		return nil
+	})
+	if !slices.Equal(partial, want[:1]) {
+		t.Errorf("a prefix ending mid-name listed %v", partial)
+	}
+	stop := errors.New("stop")
+	if err := blob.List(ctx, "museum", "", func(string) error { return stop }); err != stop {
+		t.Errorf("list returned %v, want fn's own error", err)
+	}
+
+	if err := blob.Delete(ctx, "museum", "raw_data/france/orsay.json"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blob.Get(ctx, "museum", "raw_data/france/orsay.json"); !IsNotFound(err) {
+		t.Errorf("get after delete = %v, want not found", err)
+	}
+	if err := blob.Delete(ctx, "museum", "raw_data/france/orsay.json"); err != nil {
+		t.Errorf("deleting a missing object = %v, want nil", err)
+	}
+
+	for _, bad := range []string{"../outside.json", "/etc/passwd", "raw_data//x.json", ".journal", "raw_data/.tmp-1"} {
+		if err := blob.Put(ctx, "museum", bad, strings.NewReader("x"), 1, Attrs{}); err == nil {
+			t.Errorf("put %q was accepted", bad)
+		}
+	}
+
+	// The journal has every change in order, and a follower resumes where it
+	// stopped.
+	var ops []string
+	var last int64
+	offset, err := blob.Changes(0, func(c Change) error {
+		ops = append(ops, c.Op+" "+c.Key)
+		last = c.Next
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last != offset {
+		t.Errorf("last entry ends at %d, want the %d resumed from", last, offset)
+	}
+	wantOps := []string{
+		"put raw_data/france/louvre.json", "put raw_data/france/orsay.json",
+		"put enriched_data/france/louvre.json", "put raw_data/france/louvre.json",
+		"put raw_data/italy/uffizi.json", "delete raw_data/france/orsay.json",
+	}
+	if !slices.Equal(ops, wantOps) {
+		t.Errorf("journal = %v, want %v", ops, wantOps)
+	}
+	put("raw_data/spain/prado.json", `{}`)
+	ops = nil
+	if _, err := blob.Changes(offset, func(c Change) error {
+		ops = append(ops, c.Op+" "+c.Key)
+		return nil
+	}); err != nil || !slices.Equal(ops, []string{"put raw_data/spain/prado.json"}) {
+		t.Errorf("changes since %d = %v, %v; want only the new one", offset, ops, err)
+	}
+
+	// A follower's cursor is kept beside the journal, and never listed.
+	if saved, err := blob.Cursor("enrich"); err != nil || saved != 0 {
+		t.Errorf("unsaved cursor = %d, %v; want 0", saved, err)
+	}
+	if err := blob.SetCursor("enrich", offset); err != nil {
+		t.Fatal(err)
+	}
+	if saved, err := blob.Cursor("enrich"); err != nil || saved != offset {
+		t.Errorf("cursor = %d, %v; want %d", saved, err, offset)
+	}
+	if err := blob.SetCursor("../enrich", offset); err == nil {
+		t.Error("a cursor name with a path was accepted")
+	}
+	blob.List(ctx, "museum", "", func(key string) error {
+		if strings.HasPrefix(key, ".") {
+			t.Errorf("listed %s", key)
+		}
+		return nil
+	})
+
+	if tag, err := blob.ETag(ctx, "museum", "enriched_data/france/louvre.json"); err != nil || tag != "99914b932bd37a50b983c5e7c90ae93b" {
+		t.Errorf("etag = %s, %v; want the MD5 of {}", tag, err)
+	}
+}
+
+func TestOpenBlob(t *testing.T) {
+	dir := t.TempDir()
+	blob, bucket, err := OpenBlob("file://" + filepath.Join(dir, "catalogue"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file, ok := blob.(*FileBlob); !ok || file.Root() != filepath.Join(dir, "catalogue") || bucket != "catalogue" {
+		t.Errorf("file URL opened %T in bucket %q", blob, bucket)
+	}
+	for _, bad := range []string{"file://relative/path", "file://", "s3://", "gs://bucket", "museum"} {
+		if _, _, err := OpenBlob(bad); err == nil {
+			t.Errorf("OpenBlob(%q) accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"museum/internal/keys"
+)
+
+// Writes under the raw and enriched prefixes keep what they replace.
+//
+// A museum's raw record is rewritten when a crawl finds it changed, and the
+// enriched one on every enrichment; until now each rewrite lost what the
+// sources had said before, so "what did Wikidata say about this museum last
+// month" had no answer. Every version written is also written under
+// history/, named by when and by its content's hash, which makes the history
+// the same in a bucket and in a directory, and needs nothing from the store
+// that S3 versioning would.
+
+// Version is one kept version of an object.
+type Version struct {
+	// Key is where the version itself is stored.
+	Key string
+	// At is when it was written; zero for the content an object had before
+	// history was kept.
+	At time.Time
+	// Sum is a prefix of the SHA-256 of its content.
+	Sum string
+}
+
+// Versions lists the kept versions of key, oldest first.
+func (s *S3Service[T]) Versions(ctx context.Context, bucketName, key string) ([]Version, error) {
+	var versions []Version
+	err := s.blob.List(ctx, bucketName, keys.VersionsOf(key), func(version string) error {
+		of, at, sum, ok := keys.ParseVersion(version)
+		if ok && of == key {
+			versions = append(versions, Version{Key: version, At: at, Sum: sum})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(versions, func(a, b Version) int { return a.At.Compare(b.At) })
+	return versions, nil
+}
+
+// keepVersion records data, written with attrs, as key's newest version,
+// unless it already is. Versions are named by the sum of their canonical JSON,
+// so the same record compressed another way is not a new version.
+//
+// An object written before history was kept has no versions. Its content is
+// kept first, as the version from before, so the first rewrite under history
+// loses nothing either.
+func (s *S3Service[T]) keepVersion(ctx context.Context, bucketName, key string, data []byte, attrs Attrs) error {
+	versions, err := s.Versions(ctx, bucketName, key)
+	if err != nil {
+		return fmt.Errorf("history of %q: %w", key, err)
+	}
+	sum := attrs.Metadata[SumMetadata]
+	if n := len(versions); n > 0 {
+		if strings.HasPrefix(sum, versions[n-1].Sum) {
+			return nil
+		}
+	} else {
+		switch before, err := s.readAll(ctx, bucketName, key); {
+		case errors.Is(err, ErrNotFound):
+		case err != nil:
+			return fmt.Errorf("history of %q: %w", key, err)
+		default:
+			if earlier, err := canonicalSum(before); err == nil && earlier != sum {
+				if err := s.blob.Put(ctx, bucketName, keys.Version(key, time.Time{}, earlier),
+					bytes.NewReader(before), int64(len(before)), Attrs{Metadata: map[string]string{SumMetadata: earlier}}); err != nil {
+					return fmt.Errorf("history of %q: %w", key, err)
+				}
+			}
+		}
+	}
+
+	if err := s.blob.Put(ctx, bucketName, keys.Version(key, time.Now(), sum),
+		bytes.NewReader(data), int64(len(data)), attrs); err != nil {
+		return fmt.Errorf("history of %q: %w", key, err)
+	}
+	return nil
+}
+
+// readAll reads the whole object at key, decompressed.
+func (s *S3Service[T]) readAll(ctx context.Context, bucketName, key string) ([]byte, error) {
+	r, err := s.blob.Get(ctx, bucketName, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("get object %q: %w", key, err)
+	}
+	if data, err = decode(data); err != nil {
+		return nil, fmt.Errorf("decompress object %q: %w", key, err)
+	}
+	return data, nil
+}
+
+// contentSum is the hex SHA-256 of data.
+func contentSum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// EachObjectAsOf calls fn for every object under prefix as it stood at a
+// moment: for each key, the newest version written by then. A key with no
+// versions at all was last written before history was kept, and is read as
+// it is now; one whose versions all came later did not exist yet.
+//
+// A key deleted since is still found, from its versions. Deletions are not
+// recorded, so one deleted before the moment is found too.
+func (s *S3Service[T]) EachObjectAsOf(ctx context.Context, bucketName, prefix string, at time.Time, fn func(key string, value T)) error {
+	var live []string
+	if err := s.blob.List(ctx, bucketName, prefix, func(key string) error {
+		live = append(live, key)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// Newest version by then, and whether a key has any versions at all.
+	var (
+		chosen    = map[string]Version{}
+		versioned = map[string]bool{}
+	)
+	if err := s.blob.List(ctx, bucketName, keys.HistoryPrefix+"/"+prefix, func(version string) error {
+		key, written, sum, ok := keys.ParseVersion(version)
+		if !ok {
+			return nil
+		}
+		versioned[key] = true
+		if written.After(at) {
+			return nil
+		}
+		if current, ok := chosen[key]; !ok || written.After(current.At) {
+			chosen[key] = Version{Key: version, At: written, Sum: sum}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return s.fetchEach(ctx, bucketName, prefix, func(visit func(key, from string) error) error {
+		for _, key := range live {
+			if !versioned[key] {
+				if err := visit(key, key); err != nil {
+					return err
+				}
+			}
+		}
+		for key, version := range chosen {
+			if err := visit(key, version.Key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, fn)
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"museum/internal/keys"
+)
+
+type record struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+func TestHistory(t *testing.T) {
+	ctx := context.Background()
+	blob, err := NewFileBlob(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewService(blob, func(r record) string { return r.Key })
+	const louvre, orsay = "raw_data/france/louvre.json", "raw_data/france/orsay.json"
+
+	// Orsay was written before history was kept.
+	if err := blob.Put(ctx, "museum", orsay, strings.NewReader(`{"key":"`+orsay+`","name":"Orsay"}`), -1, Attrs{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.StoreObject(ctx, "museum", record{Key: louvre, Name: "Louvre"}); err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now()
+	if err := s.PutObject(ctx, "museum", record{Key: louvre, Name: "Louvre Museum"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutObject(ctx, "museum", record{Key: louvre, Name: "Louvre Museum"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutObject(ctx, "museum", record{Key: orsay, Name: "Musée d'Orsay"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutJSON(ctx, "museum", "geo/index.json", map[string]int{}); err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := s.Versions(ctx, "museum", louvre)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || !versions[0].At.Before(versions[1].At) {
+		t.Fatalf("louvre has versions %v, want two, oldest first, the unchanged rewrite not kept", versions)
+	}
+	versions, _ = s.Versions(ctx, "museum", orsay)
+	if len(versions) != 2 || !versions[0].At.IsZero() {
+		t.Fatalf("orsay has versions %v, want what it held before history and its rewrite", versions)
+	}
+	if versions, _ := s.Versions(ctx, "museum", "geo/index.json"); len(versions) != 0 {
+		t.Errorf("an unversioned key kept %v", versions)
+	}
+
+	asOf := func(at time.Time) map[string]string {
+		var (
+			mu    sync.Mutex
+			names = map[string]string{}
+		)
+		if err := s.EachObjectAsOf(ctx, "museum", keys.RawPrefix+"/", at, func(key string, r record) {
+			mu.Lock()
+			defer mu.Unlock()
+			names[key] = r.Name
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return names
+	}
+	if got := asOf(before); got[louvre] != "Louvre" || got[orsay] != "Orsay" || len(got) != 2 {
+		t.Errorf("as of before the rewrites = %v", got)
+	}
+	if got := asOf(time.Now()); got[louvre] != "Louvre Museum" || got[orsay] != "Musée d'Orsay" {
+		t.Errorf("as of now = %v", got)
+	}
+	if got := asOf(time.Time{}.Add(time.Hour)); got[orsay] != "Orsay" || len(got) != 1 {
+		t.Errorf("as of before the Louvre was written = %v, want only Orsay", got)
+	}
+}
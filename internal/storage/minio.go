@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// streamPartSize is the part size for an upload of unknown length. Left to
+// the SDK, an unsized upload is split on the assumption it might be 5 TB, and
+// each part is buffered in memory: half a gigabyte per upload in flight.
+const streamPartSize = 16 << 20
+
+// minioBlob is a Blob on an S3 endpoint, MinIO's or anyone's.
+type minioBlob struct {
+	client *minio.Client
+}
+
+// newMinioBlob connects with the MINIO_* environment variables.
+func newMinioBlob() (*minioBlob, error) {
+	var (
+		endpoint  = os.Getenv("MINIO_ENDPOINT")
+		accessKey = os.Getenv("MINIO_ACCESS_KEY")
+		secretKey = os.Getenv("MINIO_SECRET_KEY")
+		useSSL    = os.Getenv("MINIO_USE_SSL") == "true"
+	)
+
+	if endpoint == "" || accessKey == "" || secretKey == "" {
+		return nil, errors.New("MINIO_ENDPOINT, MINIO_ACCESS_KEY and MINIO_SECRET_KEY must all be set")
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: useSSL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create MinIO client: %w", err)
+	}
+
+	log.Println("Connected to MinIO:", endpoint)
+	return &minioBlob{client: client}, nil
+}
+
+func (b *minioBlob) Put(ctx context.Context, bucket, key string, r io.Reader, size int64, attrs Attrs) error {
+	opts := minio.PutObjectOptions{
+		ContentType:     contentType(key),
+		ContentEncoding: attrs.ContentEncoding,
+		UserMetadata:    attrs.Metadata,
+	}
+	if size < 0 {
+		opts.PartSize = streamPartSize
+	}
+	if _, err := b.client.PutObject(ctx, bucket, key, r, size, opts); err != nil {
+		return fmt.Errorf("put object %q: %w", key, err)
+	}
+	return nil
+}
+
+// PutIfAbsent looks before it writes, so two writers racing for one key can
+// both write it. The pipeline's writers of a key all write the same record,
+// which makes the race harmless; the cost of closing it would be a conditional
+// PUT not every S3 implementation honours.
+func (b *minioBlob) PutIfAbsent(ctx context.Context, bucket, key string, data []byte, attrs Attrs) (bool, error) {
+	switch _, err := b.client.StatObject(ctx, bucket, key, minio.StatObjectOptions{}); {
+	case err == nil:
+		return false, nil
+	case !isNotFound(err):
+		return false, fmt.Errorf("stat object %q: %w", key, err)
+	}
+	if err := b.Put(ctx, bucket, key, bytes.NewReader(data), int64(len(data)), attrs); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (b *minioBlob) Get(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	obj, err := b.client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, asNotFound(fmt.Errorf("get object %q: %w", key, err))
+	}
+	// MinIO defers the request until the first read; Stat makes it now, so
+	// absence is reported here rather than halfway into the caller's read.
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		return nil, asNotFound(fmt.Errorf("get object %q: %w", key, err))
+	}
+	return obj, nil
+}
+
+func (b *minioBlob) Stat(ctx context.Context, bucket, key string) (Attrs, error) {
+	info, err := b.client.StatObject(ctx, bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return Attrs{}, asNotFound(fmt.Errorf("stat object %q: %w", key, err))
+	}
+	attrs := Attrs{ContentEncoding: info.Metadata.Get("Content-Encoding")}
+	// The SDK hands user metadata back with its names in header case.
+	for name, value := range info.UserMetadata {
+		if attrs.Metadata == nil {
+			attrs.Metadata = map[string]string{}
+		}
+		attrs.Metadata[strings.ToLower(name)] = value
+	}
+	return attrs, nil
+}
+
+func (b *minioBlob) ETag(ctx context.Context, bucket, key string) (string, error) {
+	info, err := b.client.StatObject(ctx, bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return "", asNotFound(fmt.Errorf("stat object %q: %w", key, err))
+	}
+	return strings.Trim(info.ETag, `"`), nil
+}
+
+func (b *minioBlob) List(ctx context.Context, bucket, prefix string, fn func(key string) error) error {
+	return b.ListInfo(ctx, bucket, prefix, func(info ObjectInfo) error { return fn(info.Key) })
+}
+
+func (b *minioBlob) ListInfo(ctx context.Context, bucket, prefix string, fn func(ObjectInfo) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for object := range b.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return fmt.Errorf("list %s/%s: %w", bucket, prefix, object.Err)
+		}
+		if err := fn(ObjectInfo{Key: object.Key, Size: object.Size, Modified: object.LastModified}); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+func (b *minioBlob) Delete(ctx context.Context, bucket, key string) error {
+	if err := b.client.RemoveObject(ctx, bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("remove object %q: %w", key, err)
+	}
+	return nil
+}
+
+func (b *minioBlob) EnsureBucket(ctx context.Context, bucket, region string) error {
+	exists, err := b.client.BucketExists(ctx, bucket)
+	if err != nil {
+		return fmt.Errorf("check bucket %q: %w", bucket, err)
+	}
+	if exists {
+		return nil
+	}
+	if err := b.client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{Region: region}); err != nil {
+		return fmt.Errorf("create bucket %q: %w", bucket, err)
+	}
+	return nil
+}
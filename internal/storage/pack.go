@@ -0,0 +1,410 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"museum/internal/keys"
+)
+
+// Packed snapshots of the loose objects.
+//
+// Reading the catalogue one object at a time is 86,000 GETs: six seconds
+// against a MinIO on the same machine and minutes against S3. The loose
+// objects stay, because the bucket notifications fire from them and the
+// enricher reads them, and beside them each country is packed into one
+// gzipped NDJSON file, so the whole catalogue is a few hundred GETs.
+//
+// A pack is never trusted on its own word. The manifest records, for each
+// object packed, the size and modification time the listing gave for the
+// loose object it was packed from, and a reader uses the packed copy only when
+// a listing now says the same — the check rsync makes — and reads the loose
+// object otherwise. A pack left behind by a write, a restore, or a crawl that
+// never repacked costs reads, never correctness.
+
+// PackManifest says what a prefix's packs hold, by shard.
+type PackManifest struct {
+	Shards map[string]PackShard `json:"shards"`
+}
+
+// PackShard is one pack.
+type PackShard struct {
+	// SHA256 is the pack's, as stored: a pack overwritten by a writer racing
+	// the one that wrote the manifest is noticed, and not used.
+	SHA256  string                  `json:"sha256"`
+	Written time.Time               `json:"written"`
+	Objects map[string]PackedObject `json:"objects"`
+}
+
+// PackedObject is one object in a pack.
+type PackedObject struct {
+	// Sum is a prefix of the SHA-256 of its content.
+	Sum string `json:"sum"`
+	// Size and Modified are what the listing said of the loose object.
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// current reports whether the loose object a listing describes is the one
+// that was packed.
+func (s PackShard) current(info ObjectInfo) bool {
+	packed, ok := s.Objects[info.Key]
+	return ok && packed.Size == info.Size && packed.Modified.Equal(info.Modified)
+}
+
+// packLine is one line of a pack.
+type packLine struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// PackStats is what bringing a prefix's packs up to date did.
+type PackStats struct {
+	Shards    int
+	Rewritten int
+	// Fetched is the loose objects read because no pack held them as they
+	// are now.
+	Fetched int
+}
+
+// Pack brings the packs of prefix, a whole one such as keys.RawPrefix, up to
+// date with the loose objects under it. A shard whose loose objects are all as
+// packed is left alone, so repacking an unchanged catalogue is one listing and
+// one read of the manifest.
+func (b *Bucket) Pack(ctx context.Context, prefix string) (PackStats, error) {
+	var stats PackStats
+	prefix = strings.TrimSuffix(prefix, "/")
+	loose, err := looseShards(ctx, b.blob, b.name, prefix)
+	if err != nil {
+		return stats, err
+	}
+	manifest, err := readPackManifest(ctx, b.blob, b.name, prefix)
+	if err != nil {
+		log.Printf("Repacking %s from scratch: %v", prefix, err)
+		manifest = PackManifest{}
+	}
+	if manifest.Shards == nil {
+		manifest.Shards = map[string]PackShard{}
+	}
+
+	for _, shard := range slices.Sorted(maps.Keys(loose)) {
+		if shard == "" {
+			continue
+		}
+		stats.Shards++
+		objects := loose[shard]
+		entry, had := manifest.Shards[shard]
+		if had && len(entry.Objects) == len(objects) && allCurrent(entry, objects) {
+			continue
+		}
+
+		var packed map[string][]byte
+		if had {
+			if packed, err = readPack(ctx, b.blob, b.name, prefix, shard, entry); err != nil {
+				log.Printf("Repacking %s from its loose objects: %v", keys.Pack(prefix, shard), err)
+			}
+		}
+		contents := make(map[string][]byte, len(objects))
+		var fetch []string
+		for key, info := range objects {
+			if data, ok := packed[key]; ok && entry.current(info) {
+				contents[key] = data
+			} else {
+				fetch = append(fetch, key)
+			}
+		}
+		if err := fetchBytes(ctx, b.blob, b.name, fetch, contents); err != nil {
+			return stats, err
+		}
+		stats.Fetched += len(fetch)
+
+		written, err := writePack(ctx, b.blob, b.name, prefix, shard, objects, contents)
+		if err != nil {
+			return stats, err
+		}
+		manifest.Shards[shard] = written
+		stats.Rewritten++
+	}
+
+	// A country whose last museum has gone takes its pack with it.
+	for shard := range manifest.Shards {
+		if _, ok := loose[shard]; !ok {
+			if err := b.blob.Delete(ctx, b.name, keys.Pack(prefix, shard)); err != nil {
+				return stats, err
+			}
+			delete(manifest.Shards, shard)
+			stats.Rewritten++
+		}
+	}
+
+	if stats.Rewritten == 0 {
+		return stats, nil
+	}
+	return stats, writePackManifest(ctx, b.blob, b.name, prefix, manifest)
+}
+
+// EachObjectPacked is EachObject read from the packs under keys.PackPrefix:
+// each object from its pack where the pack holds it as it is now, and loose
+// where it does not. The prefix is a whole one, as keys.RawPrefix.
+func (s *S3Service[T]) EachObjectPacked(ctx context.Context, bucketName, prefix string, fn func(key string, value T)) error {
+	prefix = strings.TrimSuffix(prefix, "/")
+	loose, err := looseShards(ctx, s.blob, bucketName, prefix)
+	if err != nil {
+		return err
+	}
+	manifest, err := readPackManifest(ctx, s.blob, bucketName, prefix)
+	if err != nil {
+		log.Printf("Reading %s without its packs: %v", prefix, err)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		slots    = make(chan struct{}, storeConcurrency)
+		mu       sync.Mutex
+		stale    []string
+		fromPack atomic.Int64
+		packs    atomic.Int64
+	)
+	unpacked := func(more ...string) {
+		mu.Lock()
+		defer mu.Unlock()
+		stale = append(stale, more...)
+	}
+	for shard, objects := range loose {
+		entry, ok := manifest.Shards[shard]
+		if shard == "" || !ok {
+			unpacked(slices.Collect(maps.Keys(objects))...)
+			continue
+		}
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		}
+		wg.Go(func() {
+			defer func() { <-slots }()
+
+			packed, err := readPack(ctx, s.blob, bucketName, prefix, shard, entry)
+			if err != nil {
+				log.Printf("Reading %s/%s loose: %v", prefix, shard, err)
+				unpacked(slices.Collect(maps.Keys(objects))...)
+				return
+			}
+			packs.Add(1)
+			for key, info := range objects {
+				var value T
+				data, ok := packed[key]
+				if !ok || !entry.current(info) || json.Unmarshal(data, &value) != nil {
+					unpacked(key)
+					continue
+				}
+				fromPack.Add(1)
+				fn(key, value)
+			}
+		})
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	log.Printf("Read %d objects under %s from %d packs; reading %d more loose",
+		fromPack.Load(), prefix, packs.Load(), len(stale))
+	return s.fetchEach(ctx, bucketName, prefix, func(visit func(key, from string) error) error {
+		for _, key := range stale {
+			if err := visit(key, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, fn)
+}
+
+// looseShards lists the loose objects under prefix by the shard each is
+// packed in; those in none are under "".
+func looseShards(ctx context.Context, blob Blob, bucketName, prefix string) (map[string]map[string]ObjectInfo, error) {
+	shards := map[string]map[string]ObjectInfo{}
+	err := blob.ListInfo(ctx, bucketName, prefix+"/", func(info ObjectInfo) error {
+		shard, _ := keys.PackShard(info.Key)
+		if shards[shard] == nil {
+			shards[shard] = map[string]ObjectInfo{}
+		}
+		shards[shard][info.Key] = info
+		return nil
+	})
+	return shards, err
+}
+
+// allCurrent reports whether a shard's pack holds every one of its loose
+// objects as they are now.
+func allCurrent(entry PackShard, objects map[string]ObjectInfo) bool {
+	for _, info := range objects {
+		if !entry.current(info) {
+			return false
+		}
+	}
+	return true
+}
+
+// readPackManifest reads prefix's manifest. A prefix never packed has an
+// empty one.
+func readPackManifest(ctx context.Context, blob Blob, bucketName, prefix string) (PackManifest, error) {
+	var manifest PackManifest
+	key := keys.PackManifest(prefix)
+	r, err := blob.Get(ctx, bucketName, key)
+	if errors.Is(err, ErrNotFound) {
+		return manifest, nil
+	}
+	if err != nil {
+		return manifest, err
+	}
+	defer r.Close()
+	unzipped, err := gzip.NewReader(r)
+	if err != nil {
+		return manifest, fmt.Errorf("read %s: %w", key, err)
+	}
+	if err := json.NewDecoder(unzipped).Decode(&manifest); err != nil {
+		return manifest, fmt.Errorf("read %s: %w", key, err)
+	}
+	return manifest, nil
+}
+
+// writePackManifest writes prefix's manifest, after the packs it describes.
+func writePackManifest(ctx context.Context, blob Blob, bucketName, prefix string, manifest PackManifest) error {
+	var buf bytes.Buffer
+	zipped := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zipped).Encode(manifest); err != nil {
+		return err
+	}
+	if err := zipped.Close(); err != nil {
+		return err
+	}
+	return blob.Put(ctx, bucketName, keys.PackManifest(prefix), &buf, int64(buf.Len()), Attrs{})
+}
+
+// readPack reads a shard's pack, refusing one that is not the pack its
+// manifest entry describes.
+func readPack(ctx context.Context, blob Blob, bucketName, prefix, shard string, entry PackShard) (map[string][]byte, error) {
+	key := keys.Pack(prefix, shard)
+	r, err := blob.Get(ctx, bucketName, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", key, err)
+	}
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != entry.SHA256 {
+		return nil, fmt.Errorf("%s is not the pack its manifest describes", key)
+	}
+
+	unzipped, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", key, err)
+	}
+	packed := make(map[string][]byte, len(entry.Objects))
+	decoder := json.NewDecoder(unzipped)
+	for {
+		var line packLine
+		switch err := decoder.Decode(&line); {
+		case errors.Is(err, io.EOF):
+			return packed, nil
+		case err != nil:
+			return nil, fmt.Errorf("read %s: %w", key, err)
+		}
+		packed[line.Key] = line.Value
+	}
+}
+
+// writePack writes a shard's pack, one line per object in key order, and
+// returns its manifest entry.
+func writePack(ctx context.Context, blob Blob, bucketName, prefix, shard string, objects map[string]ObjectInfo, contents map[string][]byte) (PackShard, error) {
+	entry := PackShard{Written: time.Now().UTC(), Objects: make(map[string]PackedObject, len(contents))}
+	var buf bytes.Buffer
+	zipped := gzip.NewWriter(&buf)
+	encoder := json.NewEncoder(zipped)
+	// Left as they were written, so a packed copy is the loose one's bytes.
+	encoder.SetEscapeHTML(false)
+	for _, key := range slices.Sorted(maps.Keys(contents)) {
+		data := contents[key]
+		if err := encoder.Encode(packLine{Key: key, Value: data}); err != nil {
+			// Not JSON, so not something a reader could decode either:
+			// left out, and read loose like anything else not packed.
+			log.Printf("Not packing %s: %v", key, err)
+			continue
+		}
+		info := objects[key]
+		entry.Objects[key] = PackedObject{Sum: contentSum(data)[:16], Size: info.Size, Modified: info.Modified}
+	}
+	if err := zipped.Close(); err != nil {
+		return entry, err
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	entry.SHA256 = hex.EncodeToString(sum[:])
+	if err := blob.Put(ctx, bucketName, keys.Pack(prefix, shard), &buf, int64(buf.Len()), Attrs{}); err != nil {
+		return entry, err
+	}
+	return entry, nil
+}
+
+// fetchBytes reads the loose objects at want into contents, concurrently. One
+// deleted since it was listed is left out.
+func fetchBytes(ctx context.Context, blob Blob, bucketName string, want []string, contents map[string][]byte) error {
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		slots = make(chan struct{}, storeConcurrency)
+		first error
+	)
+	for _, key := range want {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		}
+		wg.Go(func() {
+			defer func() { <-slots }()
+
+			r, err := blob.Get(ctx, bucketName, key)
+			var data []byte
+			if err == nil {
+				data, err = io.ReadAll(r)
+				r.Close()
+			}
+			if err == nil {
+				// Packed as JSON, whatever the loose object is compressed with.
+				data, err = decode(data)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case errors.Is(err, ErrNotFound):
+			case err != nil:
+				if first == nil {
+					first = err
+				}
+			default:
+				contents[key] = data
+			}
+		})
+	}
+	wg.Wait()
+	return first
+}
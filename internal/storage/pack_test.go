@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"museum/internal/keys"
+)
+
+func TestPack(t *testing.T) {
+	ctx := context.Background()
+	blob, err := NewFileBlob(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewService(blob, func(r record) string { return r.Key })
+	bucket := s.Bucket("museum")
+	const louvre, orsay, uffizi = "raw_data/france/louvre.json", "raw_data/france/orsay.json", "raw_data/italy/uffizi.json"
+	for _, r := range []record{{louvre, "Louvre"}, {orsay, "Orsay"}, {uffizi, "Uffizi"}} {
+		if _, err := s.StoreObject(ctx, "museum", r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	read := func() map[string]string {
+		t.Helper()
+		var (
+			mu    sync.Mutex
+			names = map[string]string{}
+		)
+		if err := s.EachObjectPacked(ctx, "museum", keys.RawPrefix+"/", func(key string, r record) {
+			mu.Lock()
+			defer mu.Unlock()
+			names[key] = r.Name
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return names
+	}
+	if got := read(); len(got) != 3 {
+		t.Errorf("read %v before anything was packed", got)
+	}
+
+	stats, err := bucket.Pack(ctx, keys.RawPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (PackStats{Shards: 2, Rewritten: 2, Fetched: 3}) {
+		t.Errorf("first pack = %+v", stats)
+	}
+	if stats, _ := bucket.Pack(ctx, keys.RawPrefix); stats.Rewritten != 0 || stats.Fetched != 0 {
+		t.Errorf("repacking what had not changed = %+v", stats)
+	}
+
+	// A loose object rewritten since is read as it is now, not as packed.
+	if err := s.PutObject(ctx, "museum", record{louvre, "Musée du Louvre"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(); got[louvre] != "Musée du Louvre" || got[orsay] != "Orsay" || got[uffizi] != "Uffizi" {
+		t.Errorf("read %v after a rewrite", got)
+	}
+	if stats, _ := bucket.Pack(ctx, keys.RawPrefix); stats.Rewritten != 1 || stats.Fetched != 1 {
+		t.Errorf("repacking after one rewrite = %+v, want one shard and one object read", stats)
+	}
+
+	// A pack that is not the one its manifest describes is not used.
+	if err := os.WriteFile(filepath.Join(blob.Root(), filepath.FromSlash(keys.Pack(keys.RawPrefix, "italy"))), []byte("junk"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(); got[uffizi] != "Uffizi" || len(got) != 3 {
+		t.Errorf("read %v with a damaged pack", got)
+	}
+
+	// A country with nothing left loses its pack.
+	if err := blob.Delete(ctx, "museum", uffizi); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bucket.Pack(ctx, keys.RawPrefix); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(blob.Root(), filepath.FromSlash(keys.Pack(keys.RawPrefix, "italy")))); !os.IsNotExist(err) {
+		t.Errorf("the emptied country's pack is still there: %v", err)
+	}
+	if got := read(); len(got) != 2 {
+		t.Errorf("read %v after a deletion", got)
+	}
+}
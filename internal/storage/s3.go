@@ -9,12 +9,12 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"sync"
 	"sync/atomic"
 
 	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"museum/internal/keys"
 )
 
 // storeConcurrency bounds the number of in-flight uploads. The producer side is
@@ -24,91 +24,129 @@ import (
 const storeConcurrency = 16
 
 // S3Service reads and writes values of type T as JSON objects, deriving each
-// object's key from the value itself.
+// object's key from the value itself. The name is older than the Blob
+// underneath, which need not be S3 at all.
 type S3Service[T any] struct {
-	client  *minio.Client
-	keyFunc func(value T) string
+	blob     Blob
+	keyFunc  func(value T) string
+	encoding Encoding
 }
 
 // NewS3Service builds a client from the MINIO_* environment variables.
 func NewS3Service[T any](keyFunc func(value T) string) (*S3Service[T], error) {
-	var (
-		endpoint  = os.Getenv("MINIO_ENDPOINT")
-		accessKey = os.Getenv("MINIO_ACCESS_KEY")
-		secretKey = os.Getenv("MINIO_SECRET_KEY")
-		useSSL    = os.Getenv("MINIO_USE_SSL") == "true"
-	)
-
-	if endpoint == "" || accessKey == "" || secretKey == "" {
-		return nil, errors.New("MINIO_ENDPOINT, MINIO_ACCESS_KEY and MINIO_SECRET_KEY must all be set")
-	}
-
-	client, err := minio.New(endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
-		Secure: useSSL,
-	})
+	blob, err := newMinioBlob()
 	if err != nil {
-		return nil, fmt.Errorf("create MinIO client: %w", err)
+		return nil, err
 	}
+	return NewService(blob, keyFunc), nil
+}
 
-	log.Println("Connected to MinIO:", endpoint)
-	return &S3Service[T]{client: client, keyFunc: keyFunc}, nil
+// NewService reads and writes values of type T in blob.
+func NewService[T any](blob Blob, keyFunc func(value T) string) *S3Service[T] {
+	return &S3Service[T]{blob: blob, keyFunc: keyFunc}
 }
 
+// Blob is the store the service reads and writes, for opening a service of
+// another record type on the same one.
+func (s *S3Service[T]) Blob() Blob { return s.blob }
+
+// SetEncoding makes the service compress what it writes from now on. What it
+// reads is decompressed whatever it was written with.
+func (s *S3Service[T]) SetEncoding(e Encoding) { s.encoding = e }
+
+// Encoding is how the service compresses what it writes.
+func (s *S3Service[T]) Encoding() Encoding { return s.encoding }
+
 // EnsureBucket creates bucketName in the given region if it does not exist.
 func (s *S3Service[T]) EnsureBucket(ctx context.Context, bucketName, region string) error {
-	exists, err := s.client.BucketExists(ctx, bucketName)
-	if err != nil {
-		return fmt.Errorf("check bucket %q: %w", bucketName, err)
-	}
-	if exists {
-		return nil
-	}
-	if err := s.client.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{Region: region}); err != nil {
-		return fmt.Errorf("create bucket %q: %w", bucketName, err)
-	}
-	return nil
+	return s.blob.EnsureBucket(ctx, bucketName, region)
 }
 
-// PutObject writes value as JSON, overwriting whatever was at its key.
+// PutObject writes value as JSON, overwriting whatever was at its key. Under
+// the raw and enriched prefixes what it overwrites is kept, as history.
 func (s *S3Service[T]) PutObject(ctx context.Context, bucketName string, value T) error {
-	key := s.keyFunc(value)
+	return s.PutJSON(ctx, bucketName, s.keyFunc(value), value)
+}
 
-	data, err := json.Marshal(value)
+// StoreObject writes value unless the object at its key already holds the
+// same record, and reports whether it wrote.
+//
+// It used to write only to a key with nothing there, so a crawl never
+// updated a record its sources had changed. Comparing content instead means
+// a crawl that changes nothing writes nothing, and so sends the enricher
+// nothing, while one that does change a record rewrites it.
+func (s *S3Service[T]) StoreObject(ctx context.Context, bucketName string, value T) (bool, error) {
+	key := s.keyFunc(value)
+	data, attrs, err := s.encode(key, value)
 	if err != nil {
-		return fmt.Errorf("marshal object for key %q: %w", key, err)
+		return false, err
 	}
 
-	_, err = s.client.PutObject(
-		ctx,
-		bucketName,
-		key,
-		bytes.NewReader(data),
-		int64(len(data)),
-		minio.PutObjectOptions{ContentType: "application/json"},
-	)
-	if err != nil {
-		return fmt.Errorf("put object %q: %w", key, err)
+	same, err := s.holds(ctx, bucketName, key, attrs.Metadata[SumMetadata])
+	switch {
+	case errors.Is(err, ErrNotFound):
+		written, err := s.blob.PutIfAbsent(ctx, bucketName, key, data, attrs)
+		if err != nil || !written || !keys.Versioned(key) {
+			return written, err
+		}
+		return true, s.keepVersion(ctx, bucketName, key, data, attrs)
+	case err != nil:
+		return false, err
+	case same:
+		return false, nil
 	}
-	return nil
+	return true, s.put(ctx, bucketName, key, data, attrs)
 }
 
-// StoreObject writes value only when no object already exists at its key. It
-// reports whether a write happened.
-func (s *S3Service[T]) StoreObject(ctx context.Context, bucketName string, value T) (bool, error) {
-	key := s.keyFunc(value)
-
-	switch _, err := s.client.StatObject(ctx, bucketName, key, minio.StatObjectOptions{}); {
-	case err == nil:
+// holds reports whether the object at key is the record whose canonical sum
+// is sum. An object written before sums were kept is read to find out.
+func (s *S3Service[T]) holds(ctx context.Context, bucketName, key, sum string) (bool, error) {
+	attrs, err := s.blob.Stat(ctx, bucketName, key)
+	if err != nil {
+		return false, err
+	}
+	if stored := attrs.Metadata[SumMetadata]; stored != "" {
+		return stored == sum, nil
+	}
+	data, err := s.readAll(ctx, bucketName, key)
+	if err != nil {
+		return false, err
+	}
+	stored, err := canonicalSum(data)
+	if err != nil {
+		// Not JSON, so not this record.
 		return false, nil
-	case !isNotFound(err):
-		return false, fmt.Errorf("stat object %q: %w", key, err)
 	}
+	return stored == sum, nil
+}
 
-	if err := s.PutObject(ctx, bucketName, value); err != nil {
-		return false, err
+// encode marshals value for key, compressed as the service writes, and
+// returns the attributes to write it with.
+func (s *S3Service[T]) encode(key string, value any) ([]byte, Attrs, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, Attrs{}, fmt.Errorf("marshal object for key %q: %w", key, err)
+	}
+	sum, err := canonicalSum(data)
+	if err != nil {
+		return nil, Attrs{}, fmt.Errorf("marshal object for key %q: %w", key, err)
+	}
+	encoded, err := s.encoding.encode(data)
+	if err != nil {
+		return nil, Attrs{}, fmt.Errorf("compress object for key %q: %w", key, err)
 	}
-	return true, nil
+	return encoded, Attrs{ContentEncoding: string(s.encoding), Metadata: map[string]string{SumMetadata: sum}}, nil
+}
+
+// put writes encoded content at key, keeping what it replaces under the raw
+// and enriched prefixes.
+func (s *S3Service[T]) put(ctx context.Context, bucketName, key string, data []byte, attrs Attrs) error {
+	if keys.Versioned(key) {
+		if err := s.keepVersion(ctx, bucketName, key, data, attrs); err != nil {
+			return err
+		}
+	}
+	return s.blob.Put(ctx, bucketName, key, bytes.NewReader(data), int64(len(data)), attrs)
 }
 
 // ErrNotFound reports that an object or bucket does not exist. Reads wrap the
@@ -156,7 +194,11 @@ func asNotFound(err error) error {
 // StoreFromChannel writes every value from values until the channel closes or
 // ctx is cancelled, and returns the number of objects actually written.
 // Uploads run concurrently, bounded by storeConcurrency.
-func (s *S3Service[T]) StoreFromChannel(ctx context.Context, bucketName string, values <-chan T) int {
+//
+// written, when not nil, is called with the key of every object written, from
+// several goroutines at once; an unchanged record is not written and not
+// reported.
+func (s *S3Service[T]) StoreFromChannel(ctx context.Context, bucketName string, values <-chan T, written func(key string)) int {
 	var (
 		wg      sync.WaitGroup
 		stored  atomic.Int64
@@ -179,12 +221,15 @@ loop:
 			defer wg.Done()
 			defer func() { <-slots }()
 
-			switch written, err := s.StoreObject(ctx, bucketName, val); {
+			switch changed, err := s.StoreObject(ctx, bucketName, val); {
 			case err != nil:
 				failed.Add(1)
 				log.Printf("Error storing object: %v", err)
-			case written:
+			case changed:
 				stored.Add(1)
+				if written != nil {
+					written(s.keyFunc(val))
+				}
 			default:
 				skipped.Add(1)
 			}
@@ -192,7 +237,7 @@ loop:
 	}
 
 	wg.Wait()
-	log.Printf("Stored %d objects in bucket %q (%d already present, %d failed)",
+	log.Printf("Stored %d objects in bucket %q (%d unchanged, %d failed)",
 		stored.Load(), bucketName, skipped.Load(), failed.Load())
 	return int(stored.Load())
 }
@@ -204,45 +249,46 @@ loop:
 // time is dominated by round trips. fn may be called from several goroutines,
 // so it must be safe for concurrent use.
 func (s *S3Service[T]) EachObject(ctx context.Context, bucketName, prefix string, fn func(key string, value T)) error {
+	return s.fetchEach(ctx, bucketName, prefix, func(visit func(key, from string) error) error {
+		return s.blob.List(ctx, bucketName, prefix, func(key string) error { return visit(key, key) })
+	}, fn)
+}
+
+// fetchEach fetches, concurrently, every object list visits, and calls fn
+// with each under the key it is visited as. from is where the content is
+// read: the key itself for a live object, a version's key for one as it
+// stood.
+func (s *S3Service[T]) fetchEach(ctx context.Context, bucketName, prefix string, list func(visit func(key, from string) error) error, fn func(key string, value T)) error {
 	var (
 		wg     sync.WaitGroup
 		slots  = make(chan struct{}, storeConcurrency)
 		failed atomic.Int64
 	)
 
-	objects := s.client.ListObjects(ctx, bucketName, minio.ListObjectsOptions{
-		Prefix:    prefix,
-		Recursive: true,
-	})
-
-	for object := range objects {
-		if object.Err != nil {
-			return fmt.Errorf("list %s/%s: %w", bucketName, prefix, object.Err)
-		}
-
+	err := list(func(key, from string) error {
 		select {
 		case slots <- struct{}{}:
 		case <-ctx.Done():
-			wg.Wait()
 			return ctx.Err()
 		}
 
-		wg.Add(1)
-		go func(key string) {
-			defer wg.Done()
+		wg.Go(func() {
 			defer func() { <-slots }()
 
-			value, err := s.GetObject(ctx, bucketName, key)
+			value, err := s.GetObject(ctx, bucketName, from)
 			if err != nil {
 				failed.Add(1)
-				log.Printf("Skipping %s: %v", key, err)
+				log.Printf("Skipping %s: %v", from, err)
 				return
 			}
 			fn(key, *value)
-		}(object.Key)
-	}
-
+		})
+		return nil
+	})
 	wg.Wait()
+	if err != nil {
+		return err
+	}
 	if n := failed.Load(); n > 0 {
 		log.Printf("%d objects under %s could not be read", n, prefix)
 	}
@@ -253,35 +299,22 @@ func (s *S3Service[T]) EachObject(ctx context.Context, bucketName, prefix string
 // used for derived objects such as the geo index, whose keys come from the data
 // rather than from a single record.
 func (s *S3Service[T]) PutJSON(ctx context.Context, bucketName, key string, value any) error {
-	data, err := json.Marshal(value)
+	data, attrs, err := s.encode(key, value)
 	if err != nil {
-		return fmt.Errorf("marshal object for key %q: %w", key, err)
+		return err
 	}
-
-	_, err = s.client.PutObject(
-		ctx, bucketName, key,
-		bytes.NewReader(data), int64(len(data)),
-		minio.PutObjectOptions{ContentType: "application/json"},
-	)
-	if err != nil {
-		return fmt.Errorf("put object %q: %w", key, err)
-	}
-	return nil
+	return s.put(ctx, bucketName, key, data, attrs)
 }
 
 // GetJSON fetches and decodes an arbitrary JSON object at key. A missing
 // object yields an error wrapping ErrNotFound.
 func (s *S3Service[T]) GetJSON(ctx context.Context, bucketName, key string, out any) error {
-	obj, err := s.client.GetObject(ctx, bucketName, key, minio.GetObjectOptions{})
+	data, err := s.readAll(ctx, bucketName, key)
 	if err != nil {
-		return asNotFound(fmt.Errorf("get object %q: %w", key, err))
+		return err
 	}
-	defer obj.Close()
-
-	if err := json.NewDecoder(obj).Decode(out); err != nil {
-		// MinIO defers the request until the body is read, so a missing object
-		// surfaces here rather than from GetObject.
-		return asNotFound(fmt.Errorf("decode object %q: %w", key, err))
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decode object %q: %w", key, err)
 	}
 	return nil
 }
@@ -292,15 +325,9 @@ func IsNotFound(err error) bool { return isNotFound(err) }
 
 // GetObject fetches and decodes the JSON object at key.
 func (s *S3Service[T]) GetObject(ctx context.Context, bucketName, key string) (*T, error) {
-	obj, err := s.client.GetObject(ctx, bucketName, key, minio.GetObjectOptions{})
-	if err != nil {
-		return nil, asNotFound(fmt.Errorf("get object %q: %w", key, err))
-	}
-	defer obj.Close()
-
 	var value T
-	if err := json.NewDecoder(obj).Decode(&value); err != nil {
-		return nil, asNotFound(fmt.Errorf("decode object %q: %w", key, err))
+	if err := s.GetJSON(ctx, bucketName, key, &value); err != nil {
+		return nil, err
 	}
 	return &value, nil
 }
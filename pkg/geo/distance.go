@@ -0,0 +1,23 @@
+package geo
+
+import "math"
+
+// earthRadiusKm is the Earth's mean radius.
+const earthRadiusKm = 6371.0
+
+// DistanceKm returns the great-circle distance between two points, in
+// kilometres, by the haversine formula on a spherical Earth.
+//
+// PostGIS measures the distances the database answers with. This is for
+// everything that measures in memory instead, so all of it agrees.
+func DistanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	// Rounding can push a for antipodal points a hair past 1.
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
@@ -0,0 +1,26 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistanceKm(t *testing.T) {
+	cases := []struct {
+		name                   string
+		lat1, lon1, lat2, lon2 float64
+		want                   float64
+	}{
+		{"same point", 48.8606, 2.3376, 48.8606, 2.3376, 0},
+		{"Louvre to Orsay", 48.8606, 2.3376, 48.8600, 2.3266, 0.81},
+		{"Paris to London", 48.8566, 2.3522, 51.5074, -0.1278, 343.6},
+		{"antipodes", 0, 0, 0, 180, math.Pi * earthRadiusKm},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := DistanceKm(tc.lat1, tc.lon1, tc.lat2, tc.lon2); math.Abs(got-tc.want) > 0.01*tc.want+0.01 {
+				t.Errorf("DistanceKm = %.3f, want about %.3f", got, tc.want)
+			}
+		})
+	}
+}
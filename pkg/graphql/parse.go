@@ -0,0 +1,925 @@
+// Package graphql parses GraphQL query documents.
+//
+// It reads the executable half of the language only — operations, fields,
+// arguments, variables, fragments and directives — which is everything a
+// server needs to answer a query. Schema definitions, and type checking
+// against a schema, are the executor's business.
+//
+// Fragments are expanded while parsing, so a caller walks one tree of fields
+// per operation and never sees a spread. A type condition is kept on the
+// fields a fragment contributes, for an executor with more than one possible
+// type at a position to check.
+package graphql
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxDepth bounds how deeply selections and values may nest while parsing. The
+// parser is recursive, and a document of ten thousand opening braces should be
+// an error rather than a stack.
+const maxDepth = 64
+
+// Document is a parsed request: its operations, with fragments expanded.
+type Document struct {
+	Operations []*Operation
+}
+
+// Operation is one query, mutation or subscription.
+type Operation struct {
+	// Kind is "query", "mutation" or "subscription". The shorthand form — a
+	// bare selection set — is a query.
+	Kind       string
+	Name       string
+	Variables  []VariableDefinition
+	Selections []*Field
+}
+
+// VariableDefinition declares one of an operation's variables.
+type VariableDefinition struct {
+	Name string
+	// Type is the declared type as written, e.g. "Int!" or "[String]".
+	Type    string
+	NonNull bool
+	// Default is the literal default, or nil when none was given.
+	Default    Value
+	HasDefault bool
+}
+
+// Field is one selection: a field, with whatever was selected beneath it.
+type Field struct {
+	Alias      string
+	Name       string
+	Arguments  []Argument
+	Directives []Directive
+	Selections []*Field
+	// On is the type condition of the fragment this field came from, or ""
+	// when it was selected directly.
+	On   string
+	Line int
+}
+
+// ResponseKey is the name the field's value is reported under.
+func (f *Field) ResponseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// Argument is one name: value pair.
+type Argument struct {
+	Name  string
+	Value Value
+}
+
+// Directive is an annotation such as @include(if: $flag).
+type Directive struct {
+	Name      string
+	Arguments []Argument
+}
+
+// Value is an argument or default value as written: int64, float64, string,
+// bool, nil, Enum, Variable, []Value or map[string]Value.
+type Value any
+
+// Variable refers to one of the operation's variables.
+type Variable string
+
+// Enum is an unquoted enum value.
+type Enum string
+
+// Error is a syntax error, with where it was found.
+type Error struct {
+	Message      string
+	Line, Column int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("syntax error at %d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// Operation returns the operation a request names, or the only one when it
+// names none.
+func (d *Document) Operation(name string) (*Operation, error) {
+	if name == "" {
+		if len(d.Operations) != 1 {
+			return nil, errors.New("operationName is required when a document has more than one operation")
+		}
+		return d.Operations[0], nil
+	}
+	for _, op := range d.Operations {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("no operation named %q", name)
+}
+
+// Parse reads a document.
+func Parse(source string) (*Document, error) {
+	p := &parser{lex: lexer{src: source, line: 1, col: 1}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	doc := &Document{}
+	fragments := map[string]*fragment{}
+
+	for p.tok.kind != tokEOF {
+		switch {
+		case p.tok.is(tokPunct, "{"):
+			selections, err := p.selectionSet(0)
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, &Operation{Kind: "query", Selections: selections})
+
+		case p.tok.kind == tokName && (p.tok.text == "query" || p.tok.text == "mutation" || p.tok.text == "subscription"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, op)
+
+		case p.tok.is(tokName, "fragment"):
+			frag, err := p.fragmentDefinition()
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := fragments[frag.name]; dup {
+				return nil, p.errorf("fragment %q is defined twice", frag.name)
+			}
+			fragments[frag.name] = frag
+
+		default:
+			return nil, p.errorf("expected an operation or a fragment, found %s", p.tok)
+		}
+	}
+
+	if len(doc.Operations) == 0 {
+		return nil, p.errorf("document has no operations")
+	}
+	seen := map[string]bool{}
+	for _, op := range doc.Operations {
+		if op.Name == "" && len(doc.Operations) > 1 {
+			return nil, errors.New("an anonymous operation must be the only one in its document")
+		}
+		if op.Name != "" && seen[op.Name] {
+			return nil, fmt.Errorf("operation %q is defined twice", op.Name)
+		}
+		seen[op.Name] = true
+
+		expanded, err := expand(op.Selections, fragments, map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+		op.Selections = expanded
+	}
+	return doc, nil
+}
+
+// fragment is a named fragment before expansion. Until expand replaces them,
+// spreads sit in the field list as placeholder fields named spreadPrefix plus
+// the fragment's name — or spreadPrefix alone for an inline fragment.
+type fragment struct {
+	name       string
+	on         string
+	selections []*Field
+}
+
+// spreadPrefix marks a placeholder field standing for a fragment spread. Not
+// a valid name, so it cannot collide with a real field.
+const spreadPrefix = "..."
+
+func expand(fields []*Field, fragments map[string]*fragment, active map[string]bool) ([]*Field, error) {
+	var out []*Field
+	for _, f := range fields {
+		if !strings.HasPrefix(f.Name, spreadPrefix) {
+			children, err := expand(f.Selections, fragments, active)
+			if err != nil {
+				return nil, err
+			}
+			f.Selections = children
+			out = append(out, f)
+			continue
+		}
+
+		var (
+			on         = f.On
+			selections = f.Selections
+			name       = strings.TrimPrefix(f.Name, spreadPrefix)
+		)
+		if name != "" {
+			frag, ok := fragments[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown fragment %q", name)
+			}
+			if active[name] {
+				return nil, fmt.Errorf("fragment %q spreads itself", name)
+			}
+			active[name] = true
+			on, selections = frag.on, frag.selections
+		}
+
+		inner, err := expand(cloneFields(selections), fragments, active)
+		if name != "" {
+			delete(active, name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, field := range inner {
+			if field.On == "" {
+				field.On = on
+			}
+			// A directive on the spread applies to everything it brings in.
+			field.Directives = append(append([]Directive(nil), f.Directives...), field.Directives...)
+			out = append(out, field)
+		}
+	}
+	return out, nil
+}
+
+// cloneFields copies a selection tree, so one fragment spread in two places
+// expands into two independent subtrees.
+func cloneFields(fields []*Field) []*Field {
+	out := make([]*Field, len(fields))
+	for i, f := range fields {
+		copied := *f
+		copied.Selections = cloneFields(f.Selections)
+		out[i] = &copied
+	}
+	return out
+}
+
+type parser struct {
+	lex lexer
+	tok token
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return &Error{Message: fmt.Sprintf(format, args...), Line: p.tok.line, Column: p.tok.col}
+}
+
+func (p *parser) expect(kind tokenKind, text string) error {
+	if !p.tok.is(kind, text) {
+		return p.errorf("expected %q, found %s", text, p.tok)
+	}
+	return p.advance()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokName {
+		return "", p.errorf("expected a name, found %s", p.tok)
+	}
+	name := p.tok.text
+	return name, p.advance()
+}
+
+func (p *parser) operation() (*Operation, error) {
+	op := &Operation{Kind: p.tok.text}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokName {
+		op.Name = p.tok.text
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if p.tok.is(tokPunct, "(") {
+		defs, err := p.variableDefinitions()
+		if err != nil {
+			return nil, err
+		}
+		op.Variables = defs
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	selections, err := p.selectionSet(0)
+	if err != nil {
+		return nil, err
+	}
+	op.Selections = selections
+	return op, nil
+}
+
+func (p *parser) variableDefinitions() ([]VariableDefinition, error) {
+	if err := p.expect(tokPunct, "("); err != nil {
+		return nil, err
+	}
+	var defs []VariableDefinition
+	for !p.tok.is(tokPunct, ")") {
+		if err := p.expect(tokPunct, "$"); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokPunct, ":"); err != nil {
+			return nil, err
+		}
+		typ, nonNull, err := p.typeRef(0)
+		if err != nil {
+			return nil, err
+		}
+		def := VariableDefinition{Name: name, Type: typ, NonNull: nonNull}
+		if p.tok.is(tokPunct, "=") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			value, err := p.value(true, 0)
+			if err != nil {
+				return nil, err
+			}
+			def.Default, def.HasDefault = value, true
+		}
+		defs = append(defs, def)
+	}
+	return defs, p.advance()
+}
+
+// typeRef reads a type as written, reporting whether its outermost layer is
+// non-null.
+func (p *parser) typeRef(depth int) (string, bool, error) {
+	if depth > maxDepth {
+		return "", false, p.errorf("type nests too deeply")
+	}
+	var text string
+	if p.tok.is(tokPunct, "[") {
+		if err := p.advance(); err != nil {
+			return "", false, err
+		}
+		inner, _, err := p.typeRef(depth + 1)
+		if err != nil {
+			return "", false, err
+		}
+		if err := p.expect(tokPunct, "]"); err != nil {
+			return "", false, err
+		}
+		text = "[" + inner + "]"
+	} else {
+		name, err := p.name()
+		if err != nil {
+			return "", false, err
+		}
+		text = name
+	}
+	if p.tok.is(tokPunct, "!") {
+		if err := p.advance(); err != nil {
+			return "", false, err
+		}
+		return text + "!", true, nil
+	}
+	return text, false, nil
+}
+
+func (p *parser) fragmentDefinition() (*fragment, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if name == "on" {
+		return nil, p.errorf("a fragment cannot be named \"on\"")
+	}
+	if err := p.expect(tokName, "on"); err != nil {
+		return nil, err
+	}
+	on, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	selections, err := p.selectionSet(0)
+	if err != nil {
+		return nil, err
+	}
+	return &fragment{name: name, on: on, selections: selections}, nil
+}
+
+func (p *parser) selectionSet(depth int) ([]*Field, error) {
+	if depth > maxDepth {
+		return nil, p.errorf("selections nest too deeply")
+	}
+	if err := p.expect(tokPunct, "{"); err != nil {
+		return nil, err
+	}
+	var fields []*Field
+	for !p.tok.is(tokPunct, "}") {
+		if p.tok.kind == tokEOF {
+			return nil, p.errorf("unterminated selection set")
+		}
+		field, err := p.selection(depth)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return nil, p.errorf("empty selection set")
+	}
+	return fields, p.advance()
+}
+
+func (p *parser) selection(depth int) (*Field, error) {
+	line := p.tok.line
+
+	if p.tok.is(tokPunct, "...") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		// A named spread, unless the name is "on" — that starts an inline
+		// fragment with a type condition.
+		if p.tok.kind == tokName && p.tok.text != "on" {
+			name, _ := p.name()
+			directives, err := p.directives()
+			if err != nil {
+				return nil, err
+			}
+			return &Field{Name: spreadPrefix + name, Directives: directives, Line: line}, nil
+		}
+		var on string
+		if p.tok.is(tokName, "on") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			on = name
+		}
+		directives, err := p.directives()
+		if err != nil {
+			return nil, err
+		}
+		selections, err := p.selectionSet(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &Field{Name: spreadPrefix, On: on, Directives: directives, Selections: selections, Line: line}, nil
+	}
+
+	field := &Field{Line: line}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.is(tokPunct, ":") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		field.Alias = name
+		if name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	field.Name = name
+
+	if p.tok.is(tokPunct, "(") {
+		if field.Arguments, err = p.arguments(); err != nil {
+			return nil, err
+		}
+	}
+	if field.Directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if p.tok.is(tokPunct, "{") {
+		if field.Selections, err = p.selectionSet(depth + 1); err != nil {
+			return nil, err
+		}
+	}
+	return field, nil
+}
+
+func (p *parser) arguments() ([]Argument, error) {
+	if err := p.expect(tokPunct, "("); err != nil {
+		return nil, err
+	}
+	var args []Argument
+	for !p.tok.is(tokPunct, ")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokPunct, ":"); err != nil {
+			return nil, err
+		}
+		value, err := p.value(false, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, seen := range args {
+			if seen.Name == name {
+				return nil, p.errorf("argument %q is given twice", name)
+			}
+		}
+		args = append(args, Argument{Name: name, Value: value})
+	}
+	if len(args) == 0 {
+		return nil, p.errorf("empty argument list")
+	}
+	return args, p.advance()
+}
+
+func (p *parser) directives() ([]Directive, error) {
+	var directives []Directive
+	for p.tok.is(tokPunct, "@") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		directive := Directive{Name: name}
+		if p.tok.is(tokPunct, "(") {
+			if directive.Arguments, err = p.arguments(); err != nil {
+				return nil, err
+			}
+		}
+		directives = append(directives, directive)
+	}
+	return directives, nil
+}
+
+// value reads a literal. A constant value — a variable's default — may not
+// refer to another variable.
+func (p *parser) value(constant bool, depth int) (Value, error) {
+	if depth > maxDepth {
+		return nil, p.errorf("value nests too deeply")
+	}
+	tok := p.tok
+	switch {
+	case tok.is(tokPunct, "$"):
+		if constant {
+			return nil, p.errorf("a default value cannot refer to a variable")
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		return Variable(name), nil
+
+	case tok.kind == tokInt:
+		parsed, err := strconv.ParseInt(tok.text, 10, 64)
+		if err != nil {
+			return nil, p.errorf("integer %s is out of range", tok.text)
+		}
+		return parsed, p.advance()
+
+	case tok.kind == tokFloat:
+		parsed, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, p.errorf("number %s is out of range", tok.text)
+		}
+		return parsed, p.advance()
+
+	case tok.kind == tokString:
+		return tok.text, p.advance()
+
+	case tok.kind == tokName:
+		var value Value
+		switch tok.text {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		case "null":
+			value = nil
+		default:
+			value = Enum(tok.text)
+		}
+		return value, p.advance()
+
+	case tok.is(tokPunct, "["):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		list := []Value{}
+		for !p.tok.is(tokPunct, "]") {
+			if p.tok.kind == tokEOF {
+				return nil, p.errorf("unterminated list")
+			}
+			item, err := p.value(constant, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		return list, p.advance()
+
+	case tok.is(tokPunct, "{"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		object := map[string]Value{}
+		for !p.tok.is(tokPunct, "}") {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(tokPunct, ":"); err != nil {
+				return nil, err
+			}
+			item, err := p.value(constant, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			object[name] = item
+		}
+		return object, p.advance()
+	}
+	return nil, p.errorf("expected a value, found %s", tok)
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type token struct {
+	kind      tokenKind
+	text      string
+	line, col int
+}
+
+func (t token) is(kind tokenKind, text string) bool { return t.kind == kind && t.text == text }
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of document"
+	case tokString:
+		return "a string"
+	default:
+		return strconv.Quote(t.text)
+	}
+}
+
+type lexer struct {
+	src       string
+	pos       int
+	line, col int
+}
+
+func (l *lexer) errorf(format string, args ...any) error {
+	return &Error{Message: fmt.Sprintf(format, args...), Line: l.line, Column: l.col}
+}
+
+// bump consumes n bytes that contain no line break.
+func (l *lexer) bump(n int) {
+	l.pos += n
+	l.col += n
+}
+
+func (l *lexer) next() (token, error) {
+	l.skipIgnored()
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, line: l.line, col: l.col}, nil
+	}
+
+	start, line, col := l.pos, l.line, l.col
+	c := l.src[l.pos]
+
+	switch {
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.bump(3)
+		return token{kind: tokPunct, text: "...", line: line, col: col}, nil
+
+	case strings.IndexByte("!$&():=@[]{}|", c) >= 0:
+		l.bump(1)
+		return token{kind: tokPunct, text: string(c), line: line, col: col}, nil
+
+	case c == '_' || isLetter(c):
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.bump(1)
+		}
+		return token{kind: tokName, text: l.src[start:l.pos], line: line, col: col}, nil
+
+	case c == '-' || isDigit(c):
+		return l.number(line, col)
+
+	case c == '"':
+		if strings.HasPrefix(l.src[l.pos:], `"""`) {
+			return l.blockString(line, col)
+		}
+		return l.string(line, col)
+	}
+
+	r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+	return token{}, l.errorf("unexpected character %q", r)
+}
+
+// skipIgnored passes over whitespace, commas, comments and a byte-order mark,
+// none of which mean anything in GraphQL.
+func (l *lexer) skipIgnored() {
+	for l.pos < len(l.src) {
+		switch c := l.src[l.pos]; {
+		case c == '\n':
+			l.pos++
+			l.line, l.col = l.line+1, 1
+		case c == ' ' || c == '\t' || c == '\r' || c == ',':
+			l.bump(1)
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.bump(1)
+			}
+		case strings.HasPrefix(l.src[l.pos:], "\uFEFF"):
+			l.bump(len("\uFEFF"))
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) number(line, col int) (token, error) {
+	start := l.pos
+	if l.src[l.pos] == '-' {
+		l.bump(1)
+	}
+	digits := func() int {
+		n := 0
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.bump(1)
+			n++
+		}
+		return n
+	}
+	if digits() == 0 {
+		return token{}, l.errorf("expected a digit")
+	}
+	kind := tokInt
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		l.bump(1)
+		kind = tokFloat
+		if digits() == 0 {
+			return token{}, l.errorf("expected a digit after the decimal point")
+		}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		l.bump(1)
+		kind = tokFloat
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.bump(1)
+		}
+		if digits() == 0 {
+			return token{}, l.errorf("expected a digit in the exponent")
+		}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos])) {
+		return token{}, l.errorf("a number cannot run into a name")
+	}
+	return token{kind: kind, text: l.src[start:l.pos], line: line, col: col}, nil
+}
+
+func (l *lexer) string(line, col int) (token, error) {
+	l.bump(1)
+	var b strings.Builder
+	for {
+		if l.pos >= len(l.src) || l.src[l.pos] == '\n' {
+			return token{}, l.errorf("unterminated string")
+		}
+		c := l.src[l.pos]
+		switch {
+		case c == '"':
+			l.bump(1)
+			return token{kind: tokString, text: b.String(), line: line, col: col}, nil
+
+		case c == '\\':
+			if l.pos+1 >= len(l.src) {
+				return token{}, l.errorf("unterminated string")
+			}
+			escaped := l.src[l.pos+1]
+			l.bump(2)
+			switch escaped {
+			case '"', '\\', '/':
+				b.WriteByte(escaped)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if l.pos+4 > len(l.src) {
+					return token{}, l.errorf("incomplete unicode escape")
+				}
+				code, err := strconv.ParseUint(l.src[l.pos:l.pos+4], 16, 32)
+				if err != nil {
+					return token{}, l.errorf("invalid unicode escape")
+				}
+				l.bump(4)
+				b.WriteRune(rune(code))
+			default:
+				return token{}, l.errorf("invalid escape \\%c", escaped)
+			}
+
+		default:
+			r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+			if r == utf8.RuneError && size == 1 {
+				return token{}, l.errorf("invalid UTF-8 in string")
+			}
+			b.WriteString(l.src[l.pos : l.pos+size])
+			l.bump(size)
+		}
+	}
+}
+
+// blockString reads a """triple-quoted""" string. Its common indentation is
+// removed and its leading and trailing blank lines dropped, as the
+// specification requires.
+func (l *lexer) blockString(line, col int) (token, error) {
+	l.bump(3)
+	var raw strings.Builder
+	for {
+		if l.pos >= len(l.src) {
+			return token{}, l.errorf("unterminated block string")
+		}
+		rest := l.src[l.pos:]
+		switch {
+		case strings.HasPrefix(rest, `"""`):
+			l.bump(3)
+			return token{kind: tokString, text: dedentBlock(raw.String()), line: line, col: col}, nil
+		case strings.HasPrefix(rest, `\"""`):
+			raw.WriteString(`"""`)
+			l.bump(4)
+		case rest[0] == '\n':
+			raw.WriteByte('\n')
+			l.pos++
+			l.line, l.col = l.line+1, 1
+		default:
+			raw.WriteByte(rest[0])
+			l.bump(1)
+		}
+	}
+}
+
+func dedentBlock(raw string) string {
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+
+	common := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			continue
+		}
+		if indent := len(line) - len(trimmed); common < 0 || indent < common {
+			common = indent
+		}
+	}
+	if common > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= common {
+				lines[i] = lines[i][common:]
+			} else {
+				lines[i] = strings.TrimLeft(lines[i], " \t")
+			}
+		}
+	}
+
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isLetter(c byte) bool { return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
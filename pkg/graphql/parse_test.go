@@ -0,0 +1,130 @@
+package graphql
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse_OperationWithVariablesAndArguments(t *testing.T) {
+	doc, err := Parse(`
+		# The museum card's query.
+		query Card($id: ID!, $limit: Int = 5) {
+			museum(id: $id) {
+				name
+				where: address { locality }
+				exhibitions(limit: $limit, upcoming: true) { title }
+			}
+		}`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	op, err := doc.Operation("")
+	if err != nil {
+		t.Fatalf("Operation: %v", err)
+	}
+	if op.Kind != "query" || op.Name != "Card" {
+		t.Errorf("operation = %s %s", op.Kind, op.Name)
+	}
+
+	wantVars := []VariableDefinition{
+		{Name: "id", Type: "ID!", NonNull: true},
+		{Name: "limit", Type: "Int", Default: int64(5), HasDefault: true},
+	}
+	if !reflect.DeepEqual(op.Variables, wantVars) {
+		t.Errorf("variables = %+v, want %+v", op.Variables, wantVars)
+	}
+
+	museum := op.Selections[0]
+	if museum.Name != "museum" || museum.Arguments[0].Value != Variable("id") {
+		t.Errorf("museum field = %+v", museum)
+	}
+	if where := museum.Selections[1]; where.Alias != "where" || where.Name != "address" || where.ResponseKey() != "where" {
+		t.Errorf("aliased field = %+v", where)
+	}
+	exhibitions := museum.Selections[2]
+	if exhibitions.Arguments[1].Value != true {
+		t.Errorf("upcoming = %#v, want true", exhibitions.Arguments[1].Value)
+	}
+}
+
+func TestParse_ShorthandAndLiterals(t *testing.T) {
+	doc, err := Parse(`{ museums(lat: 57.7, lon: -11.97e0, place: "Göteborg \"centre\"", tags: [1, 2], near: {km: 3}, x: null, order: NAME) { id } }`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	args := doc.Operations[0].Selections[0].Arguments
+	want := []Value{57.7, -11.97, `Göteborg "centre"`, []Value{int64(1), int64(2)}, map[string]Value{"km": int64(3)}, nil, Enum("NAME")}
+	for i, arg := range args {
+		if !reflect.DeepEqual(arg.Value, want[i]) {
+			t.Errorf("%s = %#v, want %#v", arg.Name, arg.Value, want[i])
+		}
+	}
+}
+
+func TestParse_ExpandsFragments(t *testing.T) {
+	doc, err := Parse(`
+		query { search(q: "vasa") { ...Basics ... on Museum @include(if: $full) { website } } }
+		fragment Basics on Museum { id name }`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	fields := doc.Operations[0].Selections[0].Selections
+	var names []string
+	for _, f := range fields {
+		names = append(names, f.Name)
+		if f.On != "Museum" {
+			t.Errorf("%s: type condition %q, want Museum", f.Name, f.On)
+		}
+	}
+	if !reflect.DeepEqual(names, []string{"id", "name", "website"}) {
+		t.Errorf("fields = %v", names)
+	}
+	if len(fields[2].Directives) != 1 || fields[2].Directives[0].Name != "include" {
+		t.Errorf("the inline fragment's directive did not reach its field: %+v", fields[2].Directives)
+	}
+}
+
+func TestParse_Rejects(t *testing.T) {
+	for name, source := range map[string]string{
+		"unterminated":       `{ museum(id: "1") { name }`,
+		"empty selection":    `{ museum { } }`,
+		"unknown fragment":   `{ ...Missing }`,
+		"fragment cycle":     `{ ...A } fragment A on Q { ...B } fragment B on Q { ...A }`,
+		"duplicate argument": `{ museum(id: 1, id: 2) { name } }`,
+		"bad escape":         `{ search(q: "\q") { id } }`,
+		"two anonymous":      `{ a } { b }`,
+		"variable default":   `query ($a: Int = $b) { a }`,
+		"stray character":    `{ a % }`,
+		"number into name":   `{ a(x: 12abc) }`,
+		"too deep":           "{" + strings.Repeat("a {", 100) + "b" + strings.Repeat("}", 101),
+	} {
+		if _, err := Parse(source); err == nil {
+			t.Errorf("%s: parsed without error", name)
+		}
+	}
+}
+
+func TestParse_SyntaxErrorsSayWhere(t *testing.T) {
+	_, err := Parse("{\n  museum(id: )\n}")
+	var syntax *Error
+	if !errors.As(err, &syntax) {
+		t.Fatalf("err = %v, want a syntax error", err)
+	}
+	if syntax.Line != 2 {
+		t.Errorf("line = %d, want 2", syntax.Line)
+	}
+}
+
+func TestParse_BlockString(t *testing.T) {
+	doc, err := Parse("{ search(q: \"\"\"\n    first\n      second\n  \"\"\") { id } }")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got := doc.Operations[0].Selections[0].Arguments[0].Value; got != "first\n  second" {
+		t.Errorf("block string = %q", got)
+	}
+}
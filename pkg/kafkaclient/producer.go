@@ -0,0 +1,49 @@
+package kafkaclient
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaWriter defines the interface for a Kafka message writer.
+// This allows for easy mocking in unit tests.
+type KafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// KafkaProducer publishes messages to whichever topic each message names.
+type KafkaProducer struct {
+	writer KafkaWriter
+}
+
+// NewKafkaProducer creates a producer for the broker.
+//
+// Messages with the same key go to the same partition, so a consumer sees the
+// messages about one thing in the order they were written, and a write returns
+// only once every in-sync replica has the messages.
+func NewKafkaProducer(broker string) *KafkaProducer {
+	return &KafkaProducer{writer: &kafka.Writer{
+		Addr:         kafka.TCP(broker),
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		// Topics are named per message, and the first message of a kind
+		// creates its topic.
+		AllowAutoTopicCreation: true,
+	}}
+}
+
+// Publish writes the messages, in order, and returns once they are all
+// acknowledged. Each message must name its topic.
+func (kp *KafkaProducer) Publish(ctx context.Context, msgs ...kafka.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	return kp.writer.WriteMessages(ctx, msgs...)
+}
+
+// Close flushes and closes the producer.
+func (kp *KafkaProducer) Close() error {
+	return kp.writer.Close()
+}
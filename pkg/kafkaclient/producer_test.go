@@ -0,0 +1,74 @@
+package kafkaclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// mockWriter records what is written to it, and fails when told to.
+type mockWriter struct {
+	written []kafka.Message
+	calls   int
+	fail    error
+	closed  bool
+}
+
+func (mw *mockWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	mw.calls++
+	if mw.fail != nil {
+		return mw.fail
+	}
+	mw.written = append(mw.written, msgs...)
+	return nil
+}
+
+func (mw *mockWriter) Close() error {
+	mw.closed = true
+	return nil
+}
+
+// TestKafkaProducer_PublishesInOrder verifies that a batch is written in one call, in order.
+func TestKafkaProducer_PublishesInOrder(t *testing.T) {
+	writer := &mockWriter{}
+	producer := &KafkaProducer{writer: writer}
+
+	msgs := []kafka.Message{
+		{Topic: "catalogue.museum", Key: []byte("1"), Value: []byte("added")},
+		{Topic: "catalogue.museum", Key: []byte("1"), Value: []byte("geocoded")},
+		{Topic: "catalogue.exhibition", Key: []byte("https://example.org/a"), Value: []byte("seen")},
+	}
+	if err := producer.Publish(context.Background(), msgs...); err != nil {
+		t.Fatalf("Publish() failed: %v", err)
+	}
+	if writer.calls != 1 {
+		t.Errorf("Expected one write, got %d", writer.calls)
+	}
+	for i, msg := range writer.written {
+		if string(msg.Value) != string(msgs[i].Value) {
+			t.Errorf("Message %d: expected %q, got %q", i, msgs[i].Value, msg.Value)
+		}
+	}
+
+	// Nothing to publish is not a write.
+	if err := producer.Publish(context.Background()); err != nil || writer.calls != 1 {
+		t.Errorf("Publish() with no messages wrote: calls=%d, err=%v", writer.calls, err)
+	}
+
+	if err := producer.Close(); err != nil || !writer.closed {
+		t.Errorf("Close() did not close the writer: %v", err)
+	}
+}
+
+// TestKafkaProducer_ReturnsWriteErrors verifies that a failed write is reported to the caller.
+func TestKafkaProducer_ReturnsWriteErrors(t *testing.T) {
+	broken := errors.New("kafka: leader not available")
+	producer := &KafkaProducer{writer: &mockWriter{fail: broken}}
+
+	err := producer.Publish(context.Background(), kafka.Message{Topic: "catalogue.museum", Value: []byte("x")})
+	if !errors.Is(err, broken) {
+		t.Errorf("Expected %v, got %v", broken, err)
+	}
+}
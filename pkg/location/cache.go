@@ -0,0 +1,189 @@
+package location
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheStore keeps geocoders' answers, by provider, kind of question and
+// normalised key. A nil response is a remembered "nothing found". Lookups
+// report false for a key not held or held past its expiry.
+type CacheStore interface {
+	CachedGeocode(ctx context.Context, provider, kind, key string) (response []byte, ok bool, err error)
+	CacheGeocode(ctx context.Context, provider, kind, key string, response []byte, ttl time.Duration) error
+}
+
+// The kinds of question a cached geocoder remembers answers to.
+const (
+	CacheSearch  = "search"
+	CacheReverse = "reverse"
+	CacheLookup  = "lookup"
+	CacheDetails = "details"
+)
+
+// CacheTTL is how long answers are kept: what was found, and what was not.
+// Misses are kept for less, because the museum nobody had mapped last month
+// may have been mapped since.
+type CacheTTL struct {
+	Found    time.Duration
+	NotFound time.Duration
+}
+
+// DefaultCacheTTL keeps answers for three months and misses for a week.
+var DefaultCacheTTL = CacheTTL{Found: 90 * 24 * time.Hour, NotFound: 7 * 24 * time.Hour}
+
+// Cached wraps a geocoder so each answer it gives is remembered in store,
+// under provider, and asked for again only once it expires. A re-crawl then
+// re-enriches from the cache rather than at a request a second.
+//
+// The wrapper answers the same questions the geocoder does: one that can
+// reverse geocode still can, and Nominatim's element lookup and place details
+// are cached alongside its search. Failures other than "nothing found" are
+// not remembered, and a cache that cannot be read or written costs a request
+// to the geocoder rather than failing one.
+func Cached(provider string, g Geocoder, store CacheStore, ttl CacheTTL) Geocoder {
+	c := &cached{provider: provider, geocoder: g, store: store, ttl: ttl}
+	reverse, canReverse := g.(ReverseGeocoder)
+	lookup, canLookup := g.(ElementLookup)
+	details, hasDetails := g.(Detailer)
+	switch {
+	case canReverse && canLookup && hasDetails:
+		return &cachedOSM{cachedReverse{c, reverse}, lookup, details}
+	case canReverse:
+		return &cachedReverse{c, reverse}
+	}
+	return c
+}
+
+// cached is a geocoder that can only search.
+type cached struct {
+	provider string
+	geocoder Geocoder
+	store    CacheStore
+	ttl      CacheTTL
+}
+
+// Geocode answers from the cache, or asks the geocoder and remembers.
+func (c *cached) Geocode(ctx context.Context, query string) (*NominatimLocation, error) {
+	return remember(ctx, c, CacheSearch, CacheKey(query), func() (*NominatimLocation, error) {
+		return c.geocoder.Geocode(ctx, query)
+	})
+}
+
+// cachedReverse is a cached geocoder that can reverse geocode, such as Photon.
+type cachedReverse struct {
+	*cached
+	reverse ReverseGeocoder
+}
+
+// Reverse answers from the cache, or asks the geocoder and remembers.
+// Positions are keyed to five decimal places, about a metre.
+func (c *cachedReverse) Reverse(ctx context.Context, lat, lon float64) (*NominatimLocation, error) {
+	key := strconv.FormatFloat(lat, 'f', 5, 64) + "," + strconv.FormatFloat(lon, 'f', 5, 64)
+	return remember(ctx, c.cached, CacheReverse, key, func() (*NominatimLocation, error) {
+		return c.reverse.Reverse(ctx, lat, lon)
+	})
+}
+
+// cachedOSM is a cached geocoder with everything Nominatim has: search,
+// reverse geocoding, element lookup and place details.
+type cachedOSM struct {
+	cachedReverse
+	lookup  ElementLookup
+	details Detailer
+}
+
+// Lookup answers from the cache, or asks the geocoder and remembers.
+func (c *cachedOSM) Lookup(ctx context.Context, osmType string, osmID int64) (*NominatimLocation, error) {
+	key, err := elementKey(osmType, osmID)
+	if err != nil {
+		return nil, err
+	}
+	return remember(ctx, c.cached, CacheLookup, key, func() (*NominatimLocation, error) {
+		return c.lookup.Lookup(ctx, osmType, osmID)
+	})
+}
+
+// PlaceDetails answers from the cache, or asks the geocoder and remembers.
+func (c *cachedOSM) PlaceDetails(ctx context.Context, osmType string, osmID int64) (*NominatimDetailsResponse, error) {
+	key, err := elementKey(osmType, osmID)
+	if err != nil {
+		return nil, err
+	}
+	return remember(ctx, c.cached, CacheDetails, key, func() (*NominatimDetailsResponse, error) {
+		return c.details.PlaceDetails(ctx, osmType, osmID)
+	})
+}
+
+// remember returns the cached answer for key, or asks and caches what ask
+// answers: the answer itself, or that there was none.
+func remember[T any](ctx context.Context, c *cached, kind, key string, ask func() (*T, error)) (*T, error) {
+	response, ok, err := c.store.CachedGeocode(ctx, c.provider, kind, key)
+	if err != nil {
+		log.Printf("location: geocode cache unavailable: %v", err)
+	}
+	if ok {
+		if response == nil {
+			return nil, fmt.Errorf("%s %s %q (cached): %w", c.provider, kind, key, ErrNoResults)
+		}
+		var answer T
+		if err := json.Unmarshal(response, &answer); err == nil {
+			return &answer, nil
+		}
+		// An entry written by an older shape of the answer is asked again.
+	}
+
+	answer, err := ask()
+	switch {
+	case errors.Is(err, ErrNoResults):
+		c.put(ctx, kind, key, nil, c.ttl.NotFound)
+		return nil, err
+	case err != nil:
+		return nil, err
+	}
+	if response, err := json.Marshal(answer); err == nil {
+		c.put(ctx, kind, key, response, c.ttl.Found)
+	}
+	return answer, nil
+}
+
+// put writes an entry, treating a failure as unimportant: the answer is
+// already known, and the only cost is asking again later.
+func (c *cached) put(ctx context.Context, kind, key string, response []byte, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	if err := c.store.CacheGeocode(ctx, c.provider, kind, key, response, ttl); err != nil {
+		log.Printf("location: cannot cache %s %s %q: %v", c.provider, kind, key, err)
+	}
+}
+
+// CacheKey normalises a search query for the cache: case, spacing and empty
+// parts are dropped, so "Paris,  France" and "paris, france" are one entry.
+// Accents are kept. Geocoders read them, and Münster and Munster are
+// different towns.
+func CacheKey(query string) string {
+	var parts []string
+	for part := range strings.SplitSeq(query, ",") {
+		if part = strings.Join(strings.Fields(strings.ToLower(part)), " "); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// elementKey is the cache key for an OpenStreetMap element, "W123", so the
+// long and short forms of its type share one entry.
+func elementKey(osmType string, osmID int64) (string, error) {
+	code, err := osmTypeCode(osmType)
+	if err != nil {
+		return "", err
+	}
+	return code + strconv.FormatInt(osmID, 10), nil
+}
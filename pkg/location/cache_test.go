@@ -0,0 +1,91 @@
+package location_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"museum/pkg/location"
+)
+
+// memoryCache is a CacheStore in a map, ignoring expiry.
+type memoryCache map[string][]byte
+
+func (m memoryCache) CachedGeocode(_ context.Context, provider, kind, key string) ([]byte, bool, error) {
+	response, ok := m[provider+"/"+kind+"/"+key]
+	return response, ok, nil
+}
+
+func (m memoryCache) CacheGeocode(_ context.Context, provider, kind, key string, response []byte, _ time.Duration) error {
+	m[provider+"/"+kind+"/"+key] = response
+	return nil
+}
+
+// counting is a geocoder that knows only Gothenburg, and counts what it is
+// asked.
+type counting struct{ asked int }
+
+func (c *counting) Geocode(_ context.Context, query string) (*location.NominatimLocation, error) {
+	c.asked++
+	if location.CacheKey(query) != "gothenburg, sweden" {
+		return nil, location.ErrNoResults
+	}
+	return &location.NominatimLocation{Name: "Gothenburg", Lat: "57.70716", Lon: "11.96679", Geocoder: "photon"}, nil
+}
+
+func TestCached(t *testing.T) {
+	store := memoryCache{}
+	upstream := &counting{}
+	g := location.Cached("photon", upstream, store, location.DefaultCacheTTL)
+	ctx := context.Background()
+
+	for _, query := range []string{"Gothenburg, Sweden", "gothenburg,  SWEDEN", " Gothenburg ,Sweden"} {
+		found, err := g.Geocode(ctx, query)
+		if err != nil {
+			t.Fatalf("%q: %v", query, err)
+		}
+		if found.Name != "Gothenburg" || found.Geocoder != "photon" {
+			t.Errorf("%q = %+v", query, found)
+		}
+	}
+	if upstream.asked != 1 {
+		t.Errorf("asked upstream %d times, want once for one query spelled three ways", upstream.asked)
+	}
+
+	// What was not found is remembered too.
+	for range 2 {
+		if _, err := g.Geocode(ctx, "Atlantis"); !errors.Is(err, location.ErrNoResults) {
+			t.Fatalf("err = %v, want ErrNoResults", err)
+		}
+	}
+	if upstream.asked != 2 {
+		t.Errorf("asked upstream %d times, want the miss asked once", upstream.asked)
+	}
+	if response, ok := store["photon/search/atlantis"]; !ok || response != nil {
+		t.Errorf("miss cached as %q, %v", response, ok)
+	}
+
+	// A geocoder that cannot reverse geocode is not made to look as if it can.
+	if _, ok := g.(location.ReverseGeocoder); ok {
+		t.Error("a cached search-only geocoder reverse geocodes")
+	}
+	if _, ok := location.Cached("nominatim", location.NewNominatim("http://nominatim.invalid", 0), store,
+		location.DefaultCacheTTL).(location.Detailer); !ok {
+		t.Error("cached Nominatim lost its place details")
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	cases := map[string]string{
+		"Paris, France":         "paris, france",
+		"  paris ,,  FRANCE  ":  "paris, france",
+		"Münster, Germany":      "münster, germany",
+		"Musée  d'Orsay, Paris": "musée d'orsay, paris",
+	}
+	for in, want := range cases {
+		if got := location.CacheKey(in); got != want {
+			t.Errorf("CacheKey(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
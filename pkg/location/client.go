@@ -7,28 +7,34 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"museum/internal/ratelimit"
 )
 
-// ErrNoResults means the query was valid but Nominatim matched nothing.
+// ErrNoResults means the query was valid but the geocoder matched nothing.
 var ErrNoResults = errors.New("no results")
 
+// ErrRejected means the geocoder refused the request as it stands, with a
+// client error other than 429. Asking again will not help.
+var ErrRejected = errors.New("request rejected")
+
 const (
-	// baseURL is the public Nominatim instance.
-	baseURL = "https://nominatim.openstreetmap.org"
+	// PublicNominatim is the public Nominatim instance, whose usage policy
+	// the rate limit below is set by.
+	PublicNominatim = "https://nominatim.openstreetmap.org"
 
 	// defaultUserAgent identifies this application. Nominatim rejects requests
 	// carrying a generic agent such as Go's default "Go-http-client/1.1", which
 	// is why this must never be left empty. Override it with NOMINATIM_USER_AGENT.
 	defaultUserAgent = "museum-pipeline/1.0 (https://github.com/example/museum)"
 
-	// minInterval is the shortest gap allowed between requests, per Nominatim's
-	// usage policy of at most one request per second.
-	minInterval = 1100 * time.Millisecond
+	// NominatimInterval is the shortest gap allowed between requests to the
+	// public instance, per its usage policy of at most one request per second.
+	NominatimInterval = 1100 * time.Millisecond
 
-	// maxInterval is how far apart requests are spaced once Nominatim has
+	// maxInterval is how far apart requests are spaced once a server has
 	// refused some. Slow, but a slow run places every museum and a throttled
 	// one places none.
 	maxInterval = 30 * time.Second
@@ -51,20 +57,47 @@ var userAgent = func() string {
 	return defaultUserAgent
 }()
 
-// gate serialises outbound requests to respect the rate limit. It is package
-// level because the limit applies per endpoint, not per caller: the enrichment
-// pipeline runs steps concurrently and would otherwise burst.
-var gate = ratelimit.NewGate(minInterval, maxInterval)
+// gates holds one gate per endpoint, so outbound requests respect its rate
+// limit. It is package level because the limit applies per endpoint, not per
+// caller: the enrichment pipeline runs steps concurrently, and two geocoders
+// built for the same server would otherwise each think they had it to
+// themselves.
+var (
+	gatesMu sync.Mutex
+	gates   = map[string]*ratelimit.Gate{}
+)
+
+// gateFor returns the gate for baseURL, creating it with a floor of min if it
+// is the first asked for. A later caller asking for a different floor gets
+// the first one's.
+func gateFor(baseURL string, min time.Duration) *ratelimit.Gate {
+	gatesMu.Lock()
+	defer gatesMu.Unlock()
+	gate, ok := gates[baseURL]
+	if !ok {
+		gate = ratelimit.NewGate(min, max(min, maxInterval))
+		gates[baseURL] = gate
+	}
+	return gate
+}
+
+// endpoint is one geocoding server and the gate its requests pass through.
+type endpoint struct {
+	// name is the service, as errors name it: "nominatim", "photon".
+	name    string
+	baseURL string
+	gate    *ratelimit.Gate
+}
 
-// get performs a rate-limited, properly identified GET against Nominatim and
-// decodes the JSON response into out.
+// get performs a rate-limited, properly identified GET against the endpoint
+// and decodes the JSON response into out.
 //
 // A refusal is retried rather than returned. There was no retry here at all,
 // so a single 429 failed the lookup outright: locating a few hundred museums
 // gave up on every one of them the moment Nominatim began throttling, and
 // reported them as unlocatable when they were merely asked for too quickly.
-func get(ctx context.Context, path string, params interface{ Encode() string }, out any) error {
-	requestURL := baseURL + path + "?" + params.Encode()
+func (e endpoint) get(ctx context.Context, path string, params interface{ Encode() string }, out any) error {
+	requestURL := e.baseURL + path + "?" + params.Encode()
 
 	var (
 		lastErr error
@@ -79,20 +112,20 @@ func get(ctx context.Context, path string, params interface{ Encode() string },
 				return err
 			}
 		}
-		if err := gate.Wait(ctx); err != nil {
+		if err := e.gate.Wait(ctx); err != nil {
 			return err
 		}
 
-		retryable, retryAfter, err := doRequest(ctx, requestURL, out)
+		retryable, retryAfter, err := e.doRequest(ctx, requestURL, out)
 		if err == nil {
-			gate.SpeedUp()
+			e.gate.SpeedUp()
 			return nil
 		}
 		lastErr = err
 		if !retryable {
 			return err
 		}
-		gate.SlowDown()
+		e.gate.SlowDown()
 		if retryAfter > wait {
 			wait = retryAfter
 		}
@@ -101,7 +134,7 @@ func get(ctx context.Context, path string, params interface{ Encode() string },
 }
 
 // doRequest issues one request, reporting whether a failure is worth retrying.
-func doRequest(ctx context.Context, requestURL string, out any) (retryable bool, retryAfter time.Duration, err error) {
+func (e endpoint) doRequest(ctx context.Context, requestURL string, out any) (retryable bool, retryAfter time.Duration, err error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
 	if err != nil {
 		return false, 0, fmt.Errorf("build request: %w", err)
@@ -112,20 +145,22 @@ func doRequest(ctx context.Context, requestURL string, out any) (retryable bool,
 	resp, err := httpClient.Do(req)
 	if err != nil {
 		// A cancelled context is deliberate; anything else may be transient.
-		return ctx.Err() == nil, 0, fmt.Errorf("call nominatim: %w", err)
+		return ctx.Err() == nil, 0, fmt.Errorf("call %s: %w", e.name, err)
 	}
 	defer resp.Body.Close()
 
 	switch {
 	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
 		return true, ratelimit.RetryAfter(resp.Header.Get("Retry-After")),
-			fmt.Errorf("nominatim returned %s", resp.Status)
+			fmt.Errorf("%s returned %s", e.name, resp.Status)
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return false, 0, fmt.Errorf("%s returned %s: %w", e.name, resp.Status, ErrRejected)
 	case resp.StatusCode != http.StatusOK:
-		return false, 0, fmt.Errorf("nominatim returned %s", resp.Status)
+		return false, 0, fmt.Errorf("%s returned %s", e.name, resp.Status)
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
-		return false, 0, fmt.Errorf("decode nominatim response: %w", err)
+		return false, 0, fmt.Errorf("decode %s response: %w", e.name, err)
 	}
 	return false, 0, nil
 }
@@ -36,12 +36,18 @@ type NominatimDetailsResponse struct {
 	Icon string `json:"icon"`
 }
 
+// Detailer fetches the full OpenStreetMap record of a place. Only Nominatim
+// has one to give.
+type Detailer interface {
+	PlaceDetails(ctx context.Context, osmType string, osmID int64) (*NominatimDetailsResponse, error)
+}
+
 // PlaceDetails fetches the full record for an OSM object.
 //
 // osmType is the single-letter form Nominatim expects ("N", "W" or "R"); the
 // long form returned by a search ("node", "way", "relation") is accepted too
 // and converted.
-func PlaceDetails(ctx context.Context, osmType string, osmID int64) (*NominatimDetailsResponse, error) {
+func (n *Nominatim) PlaceDetails(ctx context.Context, osmType string, osmID int64) (*NominatimDetailsResponse, error) {
 	shortType, err := osmTypeCode(osmType)
 	if err != nil {
 		return nil, err
@@ -57,7 +63,7 @@ func PlaceDetails(ctx context.Context, osmType string, osmID int64) (*NominatimD
 	params.Set("format", "json")
 
 	var details NominatimDetailsResponse
-	if err := get(ctx, "/details", params, &details); err != nil {
+	if err := n.get(ctx, "/details", params, &details); err != nil {
 		return nil, fmt.Errorf("place details %s%d: %w", shortType, osmID, err)
 	}
 	return &details, nil
@@ -76,6 +82,6 @@ func osmTypeCode(osmType string) (string, error) {
 	case "relation":
 		return "R", nil
 	default:
-		return "", fmt.Errorf("unknown osm type %q", osmType)
+		return "", fmt.Errorf("unknown osm type %q: %w", osmType, ErrRejected)
 	}
 }
@@ -0,0 +1,77 @@
+package location
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Geocoder turns a place name into a location. It returns ErrNoResults when
+// it knows nothing about the query, and an error wrapping ErrRejected when
+// the query can never be answered as it stands.
+type Geocoder interface {
+	Geocode(ctx context.Context, query string) (*NominatimLocation, error)
+}
+
+// GeocoderFunc adapts a function to a Geocoder.
+type GeocoderFunc func(ctx context.Context, query string) (*NominatimLocation, error)
+
+// Geocode calls f.
+func (f GeocoderFunc) Geocode(ctx context.Context, query string) (*NominatimLocation, error) {
+	return f(ctx, query)
+}
+
+// Chain asks its geocoders in turn until one knows the place.
+//
+// A geocoder that fails is passed over like one that found nothing, so a
+// Photon server that is down falls back to Nominatim rather than stopping the
+// run. What the chain reports when none answers is the most hopeful of their
+// failures: a transient one if any failed that way, since asking again later
+// may succeed; otherwise a rejection; and ErrNoResults only when every
+// geocoder looked and found nothing.
+type Chain struct {
+	geocoders []Geocoder
+}
+
+// NewChain returns a chain asking geocoders in the order given.
+func NewChain(geocoders ...Geocoder) *Chain {
+	return &Chain{geocoders: geocoders}
+}
+
+// Geocode asks each geocoder in turn and returns the first answer.
+func (c *Chain) Geocode(ctx context.Context, query string) (*NominatimLocation, error) {
+	return c.first(ctx, fmt.Sprintf("geocode %q", query), func(g Geocoder) (*NominatimLocation, error) {
+		return g.Geocode(ctx, query)
+	})
+}
+
+// first returns the first answer ask gets from the chain's geocoders, or the
+// most hopeful of their failures. what describes the question, for an error
+// saying nothing was found.
+func (c *Chain) first(ctx context.Context, what string, ask func(Geocoder) (*NominatimLocation, error)) (*NominatimLocation, error) {
+	var transient, rejected error
+	for _, g := range c.geocoders {
+		found, err := ask(g)
+		if err == nil {
+			return found, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		switch {
+		case errors.Is(err, ErrNoResults):
+		case errors.Is(err, ErrRejected):
+			rejected = cmp.Or(rejected, err)
+		default:
+			transient = cmp.Or(transient, err)
+		}
+	}
+	switch {
+	case transient != nil:
+		return nil, transient
+	case rejected != nil:
+		return nil, rejected
+	}
+	return nil, fmt.Errorf("%s: %w", what, ErrNoResults)
+}
@@ -0,0 +1,79 @@
+package location_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"museum/pkg/location"
+)
+
+// answers is a geocoder that answers every query with err, or with a place
+// named after it.
+func answers(name string, err error) location.Geocoder {
+	return location.GeocoderFunc(func(_ context.Context, query string) (*location.NominatimLocation, error) {
+		if err != nil {
+			return nil, fmt.Errorf("%s: geocode %q: %w", name, query, err)
+		}
+		return &location.NominatimLocation{Name: query, Geocoder: name}, nil
+	})
+}
+
+func TestChain(t *testing.T) {
+	down := errors.New("connection refused")
+	tests := []struct {
+		name      string
+		chain     []location.Geocoder
+		wantFrom  string
+		wantErr   error
+		transient bool
+	}{
+		{"first answer wins", []location.Geocoder{answers("photon", nil), answers("nominatim", nil)}, "photon", nil, false},
+		{"nothing found falls through", []location.Geocoder{answers("photon", location.ErrNoResults), answers("nominatim", nil)}, "nominatim", nil, false},
+		{"a failure falls through", []location.Geocoder{answers("photon", down), answers("nominatim", nil)}, "nominatim", nil, false},
+		{"nothing anywhere", []location.Geocoder{answers("photon", location.ErrNoResults), answers("geonames", location.ErrNoResults)}, "", location.ErrNoResults, false},
+		{"a failure outranks nothing found", []location.Geocoder{answers("photon", down), answers("geonames", location.ErrNoResults)}, "", down, true},
+		{"a rejection outranks nothing found", []location.Geocoder{answers("nominatim", location.ErrRejected), answers("geonames", location.ErrNoResults)}, "", location.ErrRejected, false},
+		{"a failure outranks a rejection", []location.Geocoder{answers("nominatim", location.ErrRejected), answers("photon", down)}, "", down, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := location.NewChain(tt.chain...).Geocode(context.Background(), "Gothenburg")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				if tt.transient && (errors.Is(err, location.ErrNoResults) || errors.Is(err, location.ErrRejected)) {
+					t.Errorf("err = %v, which reads as final", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found.Geocoder != tt.wantFrom {
+				t.Errorf("answered by %s, want %s", found.Geocoder, tt.wantFrom)
+			}
+		})
+	}
+}
+
+func TestChain_StopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	asked := false
+	cancelling := location.GeocoderFunc(func(ctx context.Context, _ string) (*location.NominatimLocation, error) {
+		cancel()
+		return nil, ctx.Err()
+	})
+	never := location.GeocoderFunc(func(context.Context, string) (*location.NominatimLocation, error) {
+		asked = true
+		return &location.NominatimLocation{}, nil
+	})
+	if _, err := location.NewChain(cancelling, never).Geocode(ctx, "Gothenburg"); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want the cancellation", err)
+	}
+	if asked {
+		t.Error("the chain kept asking after it was cancelled")
+	}
+}
@@ -0,0 +1,246 @@
+package location
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"museum/internal/models"
+	"museum/internal/search"
+	"museum/pkg/geo"
+)
+
+// GeoNames geocodes offline, from a GeoNames dump held in memory.
+//
+// It knows towns and countries, not museums, so it answers with the centre of
+// the most specific place a query names: "Röhsska museet, Gothenburg, Sweden"
+// is placed in Gothenburg. That is approximate, and says so, but it is
+// instant, costs no one's rate limit, and is the answer Nominatim would have
+// fallen back to anyway for the many museums OpenStreetMap does not know.
+//
+// cities500 (every place of 500 people or more, about 200,000 of them) is the
+// dump to load. allCountries works too, but only its populated places and
+// countries are kept, and even those take a few gigabytes.
+type GeoNames struct {
+	// places are the settlements, by every normalised name they go by.
+	places map[string][]geoName
+	// countries are by ISO code: the country's own record where the dump
+	// has one, and otherwise the mean position of its places.
+	countries map[string]geoName
+}
+
+// geoName is one place in the dump.
+type geoName struct {
+	name       string
+	lat, lon   float64
+	country    string
+	feature    string
+	population int64
+}
+
+// GeoNames dump columns, per the format in the dump's readme.
+const (
+	gnName           = 1
+	gnASCIIName      = 2
+	gnAlternateNames = 3
+	gnLatitude       = 4
+	gnLongitude      = 5
+	gnFeatureClass   = 6
+	gnFeatureCode    = 7
+	gnCountryCode    = 8
+	gnPopulation     = 14
+	gnColumns        = 19
+)
+
+// LoadGeoNames reads a GeoNames dump from path: the tab-separated text file,
+// or the zip it is published in.
+func LoadGeoNames(path string) (*GeoNames, error) {
+	if strings.HasSuffix(path, ".zip") {
+		return loadGeoNamesZip(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("load geonames: %w", err)
+	}
+	defer f.Close()
+	return ReadGeoNames(f)
+}
+
+// loadGeoNamesZip reads the dump out of the zip GeoNames publishes it in,
+// which holds the dump and, for some, a readme.
+func loadGeoNamesZip(name string) (*GeoNames, error) {
+	archive, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, fmt.Errorf("load geonames: %w", err)
+	}
+	defer archive.Close()
+	for _, file := range archive.File {
+		if path.Ext(file.Name) != ".txt" || strings.HasPrefix(path.Base(file.Name), "readme") {
+			continue
+		}
+		r, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("load geonames: %w", err)
+		}
+		defer r.Close()
+		return ReadGeoNames(r)
+	}
+	return nil, fmt.Errorf("load geonames: no dump in %s", name)
+}
+
+// ReadGeoNames reads a GeoNames dump.
+func ReadGeoNames(r io.Reader) (*GeoNames, error) {
+	g := &GeoNames{places: map[string][]geoName{}, countries: map[string]geoName{}}
+
+	// The mean position of each country's places, for the countries the dump
+	// has no record of their own for.
+	type sum struct {
+		lat, lon float64
+		n        int
+	}
+	sums := map[string]*sum{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < gnColumns {
+			return nil, fmt.Errorf("read geonames: line %d has %d columns, want %d", line, len(fields), gnColumns)
+		}
+		class, code := fields[gnFeatureClass], fields[gnFeatureCode]
+		isCountry := class == "A" && strings.HasPrefix(code, "PCL")
+		if class != "P" && !isCountry {
+			continue
+		}
+
+		lat, latErr := strconv.ParseFloat(fields[gnLatitude], 64)
+		lon, lonErr := strconv.ParseFloat(fields[gnLongitude], 64)
+		if latErr != nil || lonErr != nil {
+			return nil, fmt.Errorf("read geonames: line %d has unusable coordinates", line)
+		}
+		population, _ := strconv.ParseInt(fields[gnPopulation], 10, 64)
+		place := geoName{
+			name:       fields[gnName],
+			lat:        lat,
+			lon:        lon,
+			country:    fields[gnCountryCode],
+			feature:    code,
+			population: population,
+		}
+
+		if isCountry {
+			// An independent state's record wins over a dependency's that
+			// shares its code.
+			if known, ok := g.countries[place.country]; !ok || code == "PCLI" && known.feature != "PCLI" {
+				g.countries[place.country] = place
+			}
+			continue
+		}
+
+		seen := map[string]bool{}
+		names := append([]string{fields[gnName], fields[gnASCIIName]}, strings.Split(fields[gnAlternateNames], ",")...)
+		for _, name := range names {
+			key := search.Normalize(name)
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			g.places[key] = append(g.places[key], place)
+		}
+		s := sums[place.country]
+		if s == nil {
+			s = &sum{}
+			sums[place.country] = s
+		}
+		s.lat, s.lon, s.n = s.lat+lat, s.lon+lon, s.n+1
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read geonames: %w", err)
+	}
+
+	for code, s := range sums {
+		if _, ok := g.countries[code]; !ok {
+			g.countries[code] = geoName{name: code, lat: s.lat / float64(s.n), lon: s.lon / float64(s.n), country: code}
+		}
+	}
+	return g, nil
+}
+
+// Geocode places the query at the most specific place it names.
+//
+// The query is read the way the pipeline builds one, "name, town, country":
+// a trailing country narrows the search to it, then the parts before are
+// tried from the last, and the first that names a place wins, the most
+// populous where several share the name. A query naming only a country is
+// placed at the country. It returns ErrNoResults for a query naming nothing
+// the dump knows.
+func (g *GeoNames) Geocode(_ context.Context, query string) (*NominatimLocation, error) {
+	var parts []string
+	for part := range strings.SplitSeq(query, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	var country, countryName string
+	if n := len(parts); n > 0 {
+		if code, ok := geo.ISOCode(parts[n-1]); ok {
+			country = code
+			countryName, _ = geo.Canonical(parts[n-1])
+			parts = parts[:n-1]
+		}
+	}
+
+	for i := len(parts) - 1; i >= 0; i-- {
+		var best *geoName
+		for _, candidate := range g.places[search.Normalize(parts[i])] {
+			if country != "" && candidate.country != country {
+				continue
+			}
+			if best == nil || candidate.population > best.population {
+				best = &candidate
+			}
+		}
+		if best != nil {
+			return g.location(*best, "city", countryName), nil
+		}
+	}
+
+	if country != "" {
+		if centre, ok := g.countries[country]; ok {
+			centre.name = countryName
+			return g.location(centre, "country", countryName), nil
+		}
+	}
+	return nil, fmt.Errorf("geocode %q: %w", query, ErrNoResults)
+}
+
+// location is a place as a geocoder answers with it.
+func (g *GeoNames) location(place geoName, kind, countryName string) *NominatimLocation {
+	found := &NominatimLocation{
+		Lat:         strconv.FormatFloat(place.lat, 'f', -1, 64),
+		Lon:         strconv.FormatFloat(place.lon, 'f', -1, 64),
+		Class:       "place",
+		Type:        kind,
+		AddressType: kind,
+		Name:        place.name,
+		DisplayName: joinNonEmpty(place.name, countryName),
+		Geocoder:    "geonames",
+		Address: models.Address{
+			Country:     countryName,
+			CountryCode: strings.ToLower(place.country),
+		},
+	}
+	if kind == "city" {
+		found.Address.City = place.name
+	}
+	return found
+}
@@ -0,0 +1,65 @@
+package location_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"museum/pkg/location"
+)
+
+// dump is a few rows of a GeoNames dump: two Gothenburgs, a Paris that is not
+// the one anyone means, a country, and a region that is neither.
+var dump = strings.Join([]string{
+	"2711537\tGothenburg\tGothenburg\tGeteborg,Goeteborg,Göteborg\t57.70716\t11.96679\tP\tPPLA\tSE\t\t28\t1480\t\t\t572799\t\t10\tEurope/Stockholm\t2023-01-01",
+	"4696045\tGothenburg\tGothenburg\t\t40.93\t-99.99\tP\tPPL\tUS\t\tNE\t047\t\t\t3400\t\t771\tAmerica/Chicago\t2023-01-01",
+	"2988507\tParis\tParis\tLutece,Paname\t48.85341\t2.3488\tP\tPPLC\tFR\t\t11\t75\t751\t75056\t2138551\t\t42\tEurope/Paris\t2023-01-01",
+	"4717560\tParis\tParis\t\t33.66094\t-95.55551\tP\tPPLA2\tUS\t\tTX\t277\t\t\t24782\t\t177\tAmerica/Chicago\t2023-01-01",
+	"2661886\tSweden\tSweden\tSverige\t62\t15\tA\tPCLI\tSE\t\t00\t\t\t\t10183175\t\t186\tEurope/Stockholm\t2023-01-01",
+	"3017382\tRegion Ile-de-France\tRegion Ile-de-France\t\t48.5\t2.5\tA\tADM1\tFR\t\t11\t\t\t\t11959807\t\t144\tEurope/Paris\t2023-01-01",
+}, "\n")
+
+func TestGeoNames(t *testing.T) {
+	g, err := location.ReadGeoNames(strings.NewReader(dump))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		query    string
+		lat, lon float64
+		kind     string
+	}{
+		// The town a museum is in, in the country named.
+		{"Röhsska museet, Göteborg, Sweden", 57.70716, 11.96679, "city"},
+		// The most populous of the places sharing a name, with no country to
+		// choose by.
+		{"Paris", 48.85341, 2.3488, "city"},
+		{"Paris, United States", 33.66094, -95.55551, "city"},
+		// The country's own record, when no part names a town in it.
+		{"Unknown Museum, Sweden", 62, 15, "country"},
+		// The mean of the country's places, when the dump has no record of it.
+		{"Unknown Museum, Nowhere, France", 48.85341, 2.3488, "country"},
+	}
+	for _, tt := range tests {
+		found, err := g.Geocode(ctx, tt.query)
+		if err != nil {
+			t.Errorf("%q: %v", tt.query, err)
+			continue
+		}
+		lat, lon, _ := found.Coordinates()
+		if lat != tt.lat || lon != tt.lon || found.Type != tt.kind || !found.Approximate() {
+			t.Errorf("%q = %v, %v (%s, approximate %v), want %v, %v (%s)",
+				tt.query, lat, lon, found.Type, found.Approximate(), tt.lat, tt.lon, tt.kind)
+		}
+	}
+
+	if _, err := g.Geocode(ctx, "Atlantis"); !errors.Is(err, location.ErrNoResults) {
+		t.Errorf("Atlantis: err = %v, want ErrNoResults", err)
+	}
+	if _, err := location.ReadGeoNames(strings.NewReader("2711537\tGothenburg\n")); err == nil {
+		t.Error("a truncated row was read without complaint")
+	}
+}
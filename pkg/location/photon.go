@@ -0,0 +1,136 @@
+package location
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"museum/internal/models"
+)
+
+// Photon geocodes against a Photon server, or anything answering Photon's
+// API. Photon is built from the same OpenStreetMap data as Nominatim but has
+// no usage policy of its own when it is run locally, so a self-hosted one
+// places museums as fast as it answers.
+type Photon struct {
+	endpoint
+}
+
+// NewPhoton returns a geocoder for the Photon server at baseURL, spacing
+// requests at least minInterval apart.
+func NewPhoton(baseURL string, minInterval time.Duration) *Photon {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	return &Photon{endpoint{name: "photon", baseURL: baseURL, gate: gateFor(baseURL, minInterval)}}
+}
+
+// photonResponse is the GeoJSON Photon answers with.
+type photonResponse struct {
+	Features []struct {
+		Geometry struct {
+			// Coordinates are longitude first, as GeoJSON has them.
+			Coordinates []float64 `json:"coordinates"`
+		} `json:"geometry"`
+		Properties struct {
+			OsmType     string `json:"osm_type"`
+			OsmID       int64  `json:"osm_id"`
+			OsmKey      string `json:"osm_key"`
+			OsmValue    string `json:"osm_value"`
+			Type        string `json:"type"`
+			Name        string `json:"name"`
+			HouseNumber string `json:"housenumber"`
+			Street      string `json:"street"`
+			Postcode    string `json:"postcode"`
+			District    string `json:"district"`
+			City        string `json:"city"`
+			State       string `json:"state"`
+			Country     string `json:"country"`
+			CountryCode string `json:"countrycode"`
+			// Extent is the bounding box: west, north, east, south.
+			Extent []float64 `json:"extent"`
+		} `json:"properties"`
+	} `json:"features"`
+}
+
+// Geocode looks up a place name and returns the best matching result.
+// It returns ErrNoResults when Photon knows nothing about the query.
+func (p *Photon) Geocode(ctx context.Context, query string) (*NominatimLocation, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("limit", "1")
+	params.Set("lang", "en")
+
+	var results photonResponse
+	if err := p.get(ctx, "/api", params, &results); err != nil {
+		return nil, fmt.Errorf("geocode %q: %w", query, err)
+	}
+	found, ok := p.location(results)
+	if !ok {
+		return nil, fmt.Errorf("geocode %q: %w", query, ErrNoResults)
+	}
+	return found, nil
+}
+
+// location translates Photon's best answer, reporting false if it had none.
+func (p *Photon) location(results photonResponse) (*NominatimLocation, bool) {
+	if len(results.Features) == 0 || len(results.Features[0].Geometry.Coordinates) != 2 {
+		return nil, false
+	}
+
+	feature := results.Features[0]
+	props := feature.Properties
+	found := &NominatimLocation{
+		OsmType:  photonOSMTypes[props.OsmType],
+		OsmID:    props.OsmID,
+		Lat:      strconv.FormatFloat(feature.Geometry.Coordinates[1], 'f', -1, 64),
+		Lon:      strconv.FormatFloat(feature.Geometry.Coordinates[0], 'f', -1, 64),
+		Class:    props.OsmKey,
+		Type:     props.OsmValue,
+		Name:     props.Name,
+		Geocoder: p.name,
+		Address: models.Address{
+			HouseNumber: props.HouseNumber,
+			Road:        props.Street,
+			Suburb:      props.District,
+			City:        props.City,
+			State:       props.State,
+			Postcode:    props.Postcode,
+			Country:     props.Country,
+			CountryCode: strings.ToLower(props.CountryCode),
+		},
+	}
+	// Photon calls a settlement's own record a city; its address fields are
+	// then about the places around it.
+	if props.Type == "city" && found.Address.City == "" {
+		found.Address.City = props.Name
+	}
+	found.DisplayName = joinNonEmpty(props.Name, found.Address.Street(), props.Postcode, props.City, props.Country)
+	if len(props.Extent) == 4 {
+		west, north, east, south := props.Extent[0], props.Extent[1], props.Extent[2], props.Extent[3]
+		found.BoundingBox = []string{
+			strconv.FormatFloat(min(north, south), 'f', -1, 64),
+			strconv.FormatFloat(max(north, south), 'f', -1, 64),
+			strconv.FormatFloat(min(west, east), 'f', -1, 64),
+			strconv.FormatFloat(max(west, east), 'f', -1, 64),
+		}
+	}
+	return found, true
+}
+
+// photonOSMTypes maps Photon's element types to the long form Nominatim's
+// search answers with.
+var photonOSMTypes = map[string]string{"N": "node", "W": "way", "R": "relation"}
+
+// joinNonEmpty joins the parts that say something, the way Nominatim builds a
+// display name.
+func joinNonEmpty(parts ...string) string {
+	kept := parts[:0]
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" && (len(kept) == 0 || kept[len(kept)-1] != part) {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, ", ")
+}
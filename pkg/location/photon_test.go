@@ -0,0 +1,61 @@
+package location_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"museum/pkg/location"
+)
+
+func TestPhoton_TranslatesIntoNominatimsShape(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api" || r.URL.Query().Get("q") == "" {
+			http.Error(w, "missing query", http.StatusBadRequest)
+			return
+		}
+		if r.URL.Query().Get("q") == "Nowhere At All" {
+			w.Write([]byte(`{"type":"FeatureCollection","features":[]}`))
+			return
+		}
+		w.Write([]byte(`{"type":"FeatureCollection","features":[{"type":"Feature",
+			"geometry":{"type":"Point","coordinates":[11.9741,57.6984]},
+			"properties":{"osm_type":"W","osm_id":28563412,"osm_key":"tourism","osm_value":"museum",
+				"type":"house","name":"Röhsska museet","housenumber":"37","street":"Vasagatan",
+				"postcode":"411 37","city":"Gothenburg","country":"Sweden","countrycode":"SE",
+				"extent":[11.9735,57.6988,11.9748,57.6980]}}]}`))
+	}))
+	defer server.Close()
+	photon := location.NewPhoton(server.URL+"/", 0)
+
+	found, err := photon.Geocode(context.Background(), "Röhsska museet, Gothenburg, Sweden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lat, lon, err := found.Coordinates()
+	if err != nil || lat != 57.6984 || lon != 11.9741 {
+		t.Errorf("coordinates = %v, %v, %v", lat, lon, err)
+	}
+	if found.OsmType != "way" || found.OsmID != 28563412 || found.Class != "tourism" || found.Approximate() {
+		t.Errorf("element = %s %d (%s), approximate %v", found.OsmType, found.OsmID, found.Class, found.Approximate())
+	}
+	if found.Address.Street() != "37 Vasagatan" || found.Locality() != "Gothenburg" || found.Address.CountryCode != "se" {
+		t.Errorf("address = %+v", found.Address)
+	}
+	if want := []string{"57.698", "57.6988", "11.9735", "11.9748"}; len(found.BoundingBox) != 4 || found.BoundingBox[0] != want[0] ||
+		found.BoundingBox[1] != want[1] || found.BoundingBox[2] != want[2] || found.BoundingBox[3] != want[3] {
+		t.Errorf("bounding box = %v, want %v", found.BoundingBox, want)
+	}
+	if found.Geocoder != "photon" {
+		t.Errorf("geocoder = %q", found.Geocoder)
+	}
+
+	if _, err := photon.Geocode(context.Background(), "Nowhere At All"); !errors.Is(err, location.ErrNoResults) {
+		t.Errorf("unknown place: err = %v, want ErrNoResults", err)
+	}
+	if _, err := photon.Geocode(context.Background(), ""); !errors.Is(err, location.ErrRejected) {
+		t.Errorf("empty query: err = %v, want ErrRejected", err)
+	}
+}
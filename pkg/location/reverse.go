@@ -0,0 +1,118 @@
+package location
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// ReverseGeocoder finds what is at a position: the building, and the address
+// it has. It returns ErrNoResults where there is nothing to find, at sea or in
+// a place no map has addresses for.
+type ReverseGeocoder interface {
+	Reverse(ctx context.Context, lat, lon float64) (*NominatimLocation, error)
+}
+
+// ElementLookup reads an OpenStreetMap element as a geocoder would have
+// found it. A museum that came from OpenStreetMap is asked for by its own
+// element rather than by its position, which could as well land on the shop
+// next door.
+type ElementLookup interface {
+	Lookup(ctx context.Context, osmType string, osmID int64) (*NominatimLocation, error)
+}
+
+// Reverse returns what Nominatim has at the position, down to the building.
+func (n *Nominatim) Reverse(ctx context.Context, lat, lon float64) (*NominatimLocation, error) {
+	params := url.Values{}
+	params.Set("lat", strconv.FormatFloat(lat, 'f', -1, 64))
+	params.Set("lon", strconv.FormatFloat(lon, 'f', -1, 64))
+	params.Set("format", "json")
+	params.Set("addressdetails", "1")
+	params.Set("extratags", "1")
+	params.Set("zoom", "18")
+	params.Set("accept-language", "en")
+
+	// Nothing at the position is an error in the body, not in the status.
+	var result struct {
+		NominatimLocation
+		Error string `json:"error"`
+	}
+	if err := n.get(ctx, "/reverse", params, &result); err != nil {
+		return nil, fmt.Errorf("reverse geocode %.6f,%.6f: %w", lat, lon, err)
+	}
+	if result.Error != "" || result.Lat == "" {
+		return nil, fmt.Errorf("reverse geocode %.6f,%.6f: %w", lat, lon, ErrNoResults)
+	}
+	found := &result.NominatimLocation
+	found.Geocoder = n.name
+	return found, nil
+}
+
+// Lookup returns an OpenStreetMap element as Nominatim's search would have.
+func (n *Nominatim) Lookup(ctx context.Context, osmType string, osmID int64) (*NominatimLocation, error) {
+	shortType, err := osmTypeCode(osmType)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("osm_ids", shortType+strconv.FormatInt(osmID, 10))
+	params.Set("format", "json")
+	params.Set("addressdetails", "1")
+	params.Set("extratags", "1")
+	params.Set("accept-language", "en")
+
+	var results NominatimResponse
+	if err := n.get(ctx, "/lookup", params, &results); err != nil {
+		return nil, fmt.Errorf("look up %s%d: %w", shortType, osmID, err)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("look up %s%d: %w", shortType, osmID, ErrNoResults)
+	}
+	found := &results[0]
+	found.Geocoder = n.name
+	return found, nil
+}
+
+// Reverse returns what Photon has nearest the position.
+func (p *Photon) Reverse(ctx context.Context, lat, lon float64) (*NominatimLocation, error) {
+	params := url.Values{}
+	params.Set("lat", strconv.FormatFloat(lat, 'f', -1, 64))
+	params.Set("lon", strconv.FormatFloat(lon, 'f', -1, 64))
+	params.Set("limit", "1")
+	params.Set("lang", "en")
+
+	var results photonResponse
+	if err := p.get(ctx, "/reverse", params, &results); err != nil {
+		return nil, fmt.Errorf("reverse geocode %.6f,%.6f: %w", lat, lon, err)
+	}
+	found, ok := p.location(results)
+	if !ok {
+		return nil, fmt.Errorf("reverse geocode %.6f,%.6f: %w", lat, lon, ErrNoResults)
+	}
+	return found, nil
+}
+
+// Reverse asks each geocoder that can reverse geocode in turn, passing over
+// failures the way Geocode does.
+func (c *Chain) Reverse(ctx context.Context, lat, lon float64) (*NominatimLocation, error) {
+	return c.first(ctx, fmt.Sprintf("reverse geocode %.6f,%.6f", lat, lon),
+		func(g Geocoder) (*NominatimLocation, error) {
+			reverse, ok := g.(ReverseGeocoder)
+			if !ok {
+				return nil, ErrNoResults
+			}
+			return reverse.Reverse(ctx, lat, lon)
+		})
+}
+
+// Reverses reports whether any geocoder in the chain can reverse geocode.
+func (c *Chain) Reverses() bool {
+	for _, g := range c.geocoders {
+		if _, ok := g.(ReverseGeocoder); ok {
+			return true
+		}
+	}
+	return false
+}
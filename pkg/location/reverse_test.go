@@ -0,0 +1,95 @@
+package location_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"museum/pkg/location"
+)
+
+func TestNominatim_ReverseAndLookup(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case r.URL.Path == "/reverse" && q.Get("lat") == "0":
+			// Nominatim reports nothing at a position with a 200.
+			w.Write([]byte(`{"error":"Unable to geocode"}`))
+		case r.URL.Path == "/reverse":
+			w.Write([]byte(`{"osm_type":"way","osm_id":28563412,"lat":"57.6984","lon":"11.9741",
+				"class":"tourism","type":"museum","name":"Röhsska museet",
+				"address":{"house_number":"37","road":"Vasagatan","postcode":"411 37","city":"Gothenburg","country_code":"se"}}`))
+		case r.URL.Path == "/lookup" && q.Get("osm_ids") == "W28563412":
+			w.Write([]byte(`[{"osm_type":"way","osm_id":28563412,"lat":"57.6984","lon":"11.9741",
+				"class":"tourism","type":"museum","address":{"road":"Vasagatan","city":"Gothenburg"}}]`))
+		case r.URL.Path == "/lookup":
+			w.Write([]byte(`[]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	nominatim := location.NewNominatim(server.URL, 0)
+	ctx := context.Background()
+
+	found, err := nominatim.Reverse(ctx, 57.6984, 11.9741)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Address.Street() != "37 Vasagatan" || found.Address.Postcode != "411 37" || found.Geocoder != "nominatim" {
+		t.Errorf("reverse = %+v", found)
+	}
+	if _, err := nominatim.Reverse(ctx, 0, 0); !errors.Is(err, location.ErrNoResults) {
+		t.Errorf("reverse at sea: err = %v, want ErrNoResults", err)
+	}
+
+	found, err = nominatim.Lookup(ctx, "way", 28563412)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.OsmID != 28563412 || found.Address.Road != "Vasagatan" {
+		t.Errorf("lookup = %+v", found)
+	}
+	if _, err := nominatim.Lookup(ctx, "node", 1); !errors.Is(err, location.ErrNoResults) {
+		t.Errorf("lookup of a deleted element: err = %v, want ErrNoResults", err)
+	}
+	if _, err := nominatim.Lookup(ctx, "changeset", 1); !errors.Is(err, location.ErrRejected) {
+		t.Errorf("lookup of a changeset: err = %v, want ErrRejected", err)
+	}
+}
+
+func TestChain_ReverseSkipsGeocodersThatCannot(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/reverse" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"type":"FeatureCollection","features":[{"type":"Feature",
+			"geometry":{"type":"Point","coordinates":[11.9741,57.6984]},
+			"properties":{"osm_type":"W","osm_id":28563412,"osm_key":"tourism","osm_value":"museum",
+				"name":"Röhsska museet","street":"Vasagatan","city":"Gothenburg","countrycode":"SE"}}]}`))
+	}))
+	defer server.Close()
+
+	chain := location.NewChain(answers("geonames", nil), location.NewPhoton(server.URL, 0))
+	if !chain.Reverses() {
+		t.Fatal("chain with Photon does not reverse geocode")
+	}
+	found, err := chain.Reverse(context.Background(), 57.6984, 11.9741)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Geocoder != "photon" || found.Address.Road != "Vasagatan" {
+		t.Errorf("reverse = %+v", found)
+	}
+
+	offline := location.NewChain(answers("geonames", nil))
+	if offline.Reverses() {
+		t.Error("chain without a reverse geocoder claims to reverse")
+	}
+	if _, err := offline.Reverse(context.Background(), 57.6984, 11.9741); !errors.Is(err, location.ErrNoResults) {
+		t.Errorf("err = %v, want ErrNoResults", err)
+	}
+}
@@ -1,4 +1,6 @@
-// Package location geocodes place names against Nominatim (OpenStreetMap).
+// Package location geocodes place names: against Nominatim (OpenStreetMap),
+// a Photon server, or a GeoNames dump held in memory, alone or one after
+// another.
 //
 // Nominatim's usage policy requires a descriptive User-Agent identifying the
 // application and allows at most one request per second. Both are enforced
@@ -11,11 +13,15 @@ import (
 	"fmt"
 	"net/url"
 	"strconv"
+	"time"
 
 	"museum/internal/models"
 )
 
-// NominatimLocation holds enriched info about a place.
+// NominatimLocation holds enriched info about a place. It is what every
+// geocoder answers with, whichever it asked: the rest of the pipeline was
+// written against Nominatim's answers, and the others are translated into the
+// same shape rather than each caller learning three.
 type NominatimLocation struct {
 	PlaceID     int64   `json:"place_id"`
 	Licence     string  `json:"licence"`
@@ -36,6 +42,9 @@ type NominatimLocation struct {
 	Address     models.Address    `json:"address"`
 	ExtraTags   map[string]string `json:"extratags"`
 	BoundingBox []string          `json:"boundingbox"`
+	// Geocoder names the provider that answered, so an enriched record says
+	// where its position came from.
+	Geocoder string `json:"geocoder,omitempty"`
 }
 
 // NominatimResponse is the shape of a Nominatim search response.
@@ -53,6 +62,13 @@ func (l NominatimLocation) Coordinates() (lat, lon float64, err error) {
 	return lat, lon, nil
 }
 
+// Approximate reports whether the match is an area — a town, a region, a
+// country — rather than the thing asked for. A museum placed by one is at the
+// area's centre, not at its door.
+func (l NominatimLocation) Approximate() bool {
+	return l.Class == "place" || l.Class == "boundary"
+}
+
 // Locality returns the most specific settlement name Nominatim supplied.
 func (l NominatimLocation) Locality() string { return l.Address.Locality() }
 
@@ -66,9 +82,25 @@ func (l NominatimLocation) Website() string {
 	return ""
 }
 
+// Nominatim geocodes against a Nominatim server: the public instance, or one
+// of your own.
+type Nominatim struct {
+	endpoint
+}
+
+// NewNominatim returns a geocoder for the Nominatim server at baseURL,
+// spacing requests at least minInterval apart. The public instance is never
+// asked more often than its policy allows, whatever minInterval says.
+func NewNominatim(baseURL string, minInterval time.Duration) *Nominatim {
+	if baseURL == PublicNominatim {
+		minInterval = max(minInterval, NominatimInterval)
+	}
+	return &Nominatim{endpoint{name: "nominatim", baseURL: baseURL, gate: gateFor(baseURL, minInterval)}}
+}
+
 // Geocode looks up a place name and returns the best matching result.
 // It returns ErrNoResults when Nominatim knows nothing about the query.
-func Geocode(ctx context.Context, query string) (*NominatimLocation, error) {
+func (n *Nominatim) Geocode(ctx context.Context, query string) (*NominatimLocation, error) {
 	params := url.Values{}
 	params.Set("q", query)
 	params.Set("format", "json")
@@ -78,11 +110,13 @@ func Geocode(ctx context.Context, query string) (*NominatimLocation, error) {
 	params.Set("accept-language", "en")
 
 	var results NominatimResponse
-	if err := get(ctx, "/search", params, &results); err != nil {
+	if err := n.get(ctx, "/search", params, &results); err != nil {
 		return nil, fmt.Errorf("geocode %q: %w", query, err)
 	}
 	if len(results) == 0 {
 		return nil, fmt.Errorf("geocode %q: %w", query, ErrNoResults)
 	}
-	return &results[0], nil
+	found := &results[0]
+	found.Geocoder = n.name
+	return found, nil
 }
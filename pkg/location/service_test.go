@@ -41,9 +41,10 @@ func TestGeocode_RealAPI(t *testing.T) {
 		},
 	}
 
+	nominatim := location.NewNominatim(location.PublicNominatim, location.NominatimInterval)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := location.Geocode(context.Background(), tt.query)
+			got, err := nominatim.Geocode(context.Background(), tt.query)
 			if err != nil {
 				t.Fatalf("Geocode(%q) returned error: %v", tt.query, err)
 			}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"slices"
+	"strconv"
 	"strings"
 
 	"museum/internal/models"
@@ -223,3 +224,27 @@ func wikipediaURL(tag string) string {
 	}
 	return "https://en.wikipedia.org/wiki/" + strings.ReplaceAll(title, " ", "_")
 }
+
+// ElementOf reads the OpenStreetMap element a museum's SourcePage names: the
+// "node/123" this package records, or the element's page on openstreetmap.org.
+// It reports false for a page that names no element, such as the Wikipedia
+// list a museum was found in.
+func ElementOf(sourcePage string) (osmType string, id int64, ok bool) {
+	page := strings.TrimSpace(sourcePage)
+	if rest, found := strings.CutPrefix(page, "https://"); found {
+		host, path, _ := strings.Cut(rest, "/")
+		if host != "www.openstreetmap.org" && host != "openstreetmap.org" {
+			return "", 0, false
+		}
+		page = path
+	}
+	osmType, number, found := strings.Cut(page, "/")
+	if !found || osmType != "node" && osmType != "way" && osmType != "relation" {
+		return "", 0, false
+	}
+	id, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || id <= 0 {
+		return "", 0, false
+	}
+	return osmType, id, true
+}
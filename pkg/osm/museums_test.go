@@ -127,6 +127,30 @@ func TestWikipediaURL(t *testing.T) {
 	}
 }
 
+func TestElementOf(t *testing.T) {
+	cases := []struct {
+		page   string
+		wantOK bool
+		typ    string
+		id     int64
+	}{
+		{"node/123", true, "node", 123},
+		{"way/4567", true, "way", 4567},
+		{"https://www.openstreetmap.org/relation/89", true, "relation", 89},
+		{"https://en.wikipedia.org/wiki/List_of_museums_in_Sweden", false, "", 0},
+		{"https://www.openstreetmap.org/changeset/89", false, "", 0},
+		{"node/", false, "", 0},
+		{"node/-1", false, "", 0},
+		{"", false, "", 0},
+	}
+	for _, tc := range cases {
+		typ, id, ok := ElementOf(tc.page)
+		if ok != tc.wantOK || typ != tc.typ || id != tc.id {
+			t.Errorf("ElementOf(%q) = %q, %d, %v; want %q, %d, %v", tc.page, typ, id, ok, tc.typ, tc.id, tc.wantOK)
+		}
+	}
+}
+
 // TestCountryMuseumsLive checks the Overpass query against a small country.
 func TestCountryMuseumsLive(t *testing.T) {
 	if testing.Short() {
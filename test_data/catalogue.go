@@ -0,0 +1,12 @@
+package test_data
+
+import _ "embed"
+
+// Catalogue is a small catalogue in the form "museum export -format ndjson"
+// writes: twenty-one real museums in four cities, one of them unplaced, and a
+// handful of exhibitions. The museums' positions are real; the exhibitions are
+// invented, and dated as if read on 15 January 2026 so a loader can move them
+// to the present. It is what "museum serve -demo" serves.
+//
+//go:embed catalogue.ndjson
+var Catalogue []byte